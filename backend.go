@@ -0,0 +1,185 @@
+package opcda
+
+import (
+	"github.com/huskar-t/opcda/com"
+)
+
+// Transport creates the server backend that an OPCServer talks to.
+// The COM transport uses the Windows COM runtime, the DCOM transport speaks DCE-RPC directly and works on every platform.
+// credentials is nil when the connection runs as the current user.
+type Transport interface {
+	Connect(progID, node string, credentials *Credentials) (ServerBackend, error)
+}
+
+// ServerBackend mirrors IOPCServer.
+type ServerBackend interface {
+	AddGroup(
+		name string,
+		active bool,
+		requestedUpdateRate uint32,
+		clientGroup uint32,
+		timeBias *int32,
+		percentDeadband *float32,
+		localeID uint32,
+	) (serverGroup uint32, revisedUpdateRate uint32, group GroupBackend, err error)
+	GetStatus() (*com.ServerStatus, error)
+	RemoveGroup(serverGroup uint32, force bool) error
+	QueryCommon() (CommonBackend, error)
+	QueryItemProperties() (ItemPropertiesBackend, error)
+	QueryBrowseServerAddressSpace() (BrowseServerAddressSpaceBackend, error)
+	// QueryBrowse returns the OPC DA 3.0 browse interface, it fails on servers that only implement DA 2.0.
+	QueryBrowse() (BrowseBackend, error)
+	// QueryItemIO returns the OPC DA 3.0 IOPCItemIO interface, it fails on servers that only implement DA 2.0.
+	QueryItemIO() (ItemIOBackend, error)
+	// AdviseShutdown registers onShutdown with the IOPCShutdown connection point of the server.
+	AdviseShutdown(onShutdown func(reason string)) (cookie uint32, err error)
+	UnadviseShutdown(cookie uint32) error
+	Release()
+}
+
+// CommonBackend mirrors IOPCCommon.
+type CommonBackend interface {
+	SetLocaleID(localeID uint32) error
+	GetLocaleID() (uint32, error)
+	QueryAvailableLocaleIDs() ([]uint32, error)
+	GetErrorString(errorCode uint32) (string, error)
+	SetClientName(name string) error
+	Release()
+}
+
+// ItemPropertiesBackend mirrors IOPCItemProperties.
+type ItemPropertiesBackend interface {
+	QueryAvailableProperties(itemID string) (propertyIDs []uint32, descriptions []string, dataTypes []uint16, err error)
+	GetItemProperties(itemID string, propertyIDs []uint32) (data []interface{}, errors []int32, err error)
+	LookupItemIDs(itemID string, propertyIDs []uint32) (itemIDs []string, errors []int32, err error)
+	Release()
+}
+
+// BrowseServerAddressSpaceBackend mirrors IOPCBrowseServerAddressSpace.
+type BrowseServerAddressSpaceBackend interface {
+	QueryOrganization() (com.OPCNAMESPACETYPE, error)
+	ChangeBrowsePosition(direction com.OPCBROWSEDIRECTION, name string) error
+	BrowseOPCItemIDs(browseType com.OPCBROWSETYPE, filter string, dataType uint16, accessRights uint32) ([]string, error)
+	GetItemID(itemDataID string) (string, error)
+	Release()
+}
+
+// BrowseBackend mirrors IOPCBrowse.
+type BrowseBackend interface {
+	GetProperties(itemIDs []string, returnPropertyValues bool, propertyIDs []uint32) ([]*com.ItemProperties, error)
+	Browse(
+		itemID string,
+		continuationPoint string,
+		maxElements uint32,
+		browseFilter com.OPCBROWSEFILTER,
+		elementNameFilter string,
+		vendorFilter string,
+		returnAllProperties bool,
+		returnPropertyValues bool,
+		propertyIDs []uint32,
+	) (moreElements bool, newContinuationPoint string, elements []*com.BrowseElement, err error)
+	Release()
+}
+
+// ItemIOBackend mirrors IOPCItemIO.
+type ItemIOBackend interface {
+	Read(itemIDs []string, maxAges []uint32) ([]*com.ItemState, []int32, error)
+	WriteVQT(itemIDs []string, items []*com.ItemVQT) ([]int32, error)
+	Release()
+}
+
+// GroupBackend mirrors IOPCGroupStateMgt, the other group interfaces are obtained through the Query methods.
+type GroupBackend interface {
+	GetState() (updateRate uint32, active bool, name string, timeBias int32, percentDeadband float32, localeID uint32, clientGroup uint32, serverGroup uint32, err error)
+	SetState(requestedUpdateRate *uint32, active *bool, timeBias *int32, percentDeadband *float32, localeID *uint32, clientGroup *uint32) (revisedUpdateRate uint32, err error)
+	SetName(name string) error
+	QuerySyncIO() (SyncIOBackend, error)
+	QueryAsyncIO2() (AsyncIO2Backend, error)
+	// QuerySyncIO2 and QueryAsyncIO3 fail when the server does not implement OPC DA 3.0.
+	QuerySyncIO2() (SyncIO2Backend, error)
+	QueryAsyncIO3() (AsyncIO3Backend, error)
+	QueryItemMgt() (ItemMgtBackend, error)
+	// QueryItemDeadbandMgt and QueryItemSamplingMgt fail when the server does not implement OPC DA 3.0.
+	QueryItemDeadbandMgt() (ItemDeadbandMgtBackend, error)
+	QueryItemSamplingMgt() (ItemSamplingMgtBackend, error)
+	// QueryGroupStateMgt2 fails when the server does not implement OPC DA 3.0.
+	QueryGroupStateMgt2() (GroupStateMgt2Backend, error)
+	// AdviseDataCallback registers callback with the IOPCDataCallback connection point of the group.
+	AdviseDataCallback(callback DataCallback) (cookie uint32, err error)
+	UnadviseDataCallback(cookie uint32) error
+	Release()
+}
+
+// GroupStateMgt2Backend mirrors the methods IOPCGroupStateMgt2 adds to IOPCGroupStateMgt.
+type GroupStateMgt2Backend interface {
+	SetKeepAlive(keepAliveTime uint32) (revisedKeepAliveTime uint32, err error)
+	GetKeepAlive() (uint32, error)
+	Release()
+}
+
+// SyncIOBackend mirrors IOPCSyncIO.
+type SyncIOBackend interface {
+	Read(source com.OPCDATASOURCE, serverHandles []uint32) ([]*com.ItemState, []int32, error)
+	Write(serverHandles []uint32, values []interface{}) ([]int32, error)
+	Release()
+}
+
+// AsyncIO2Backend mirrors IOPCAsyncIO2.
+type AsyncIO2Backend interface {
+	Read(serverHandles []uint32, transactionID uint32) (cancelID uint32, errors []int32, err error)
+	Write(serverHandles []uint32, values []interface{}, transactionID uint32) (cancelID uint32, errors []int32, err error)
+	Refresh2(source com.OPCDATASOURCE, transactionID uint32) (cancelID uint32, err error)
+	Cancel2(cancelID uint32) error
+	Release()
+}
+
+// SyncIO2Backend mirrors the methods IOPCSyncIO2 adds to IOPCSyncIO.
+type SyncIO2Backend interface {
+	ReadMaxAge(serverHandles []uint32, maxAges []uint32) ([]*com.ItemState, []int32, error)
+	WriteVQT(serverHandles []uint32, items []*com.ItemVQT) ([]int32, error)
+	Release()
+}
+
+// AsyncIO3Backend mirrors the methods IOPCAsyncIO3 adds to IOPCAsyncIO2.
+type AsyncIO3Backend interface {
+	ReadMaxAge(serverHandles []uint32, maxAges []uint32, transactionID uint32) (cancelID uint32, errors []int32, err error)
+	WriteVQT(serverHandles []uint32, items []*com.ItemVQT, transactionID uint32) (cancelID uint32, errors []int32, err error)
+	Release()
+}
+
+// ItemMgtBackend mirrors IOPCItemMgt.
+type ItemMgtBackend interface {
+	AddItems(items []com.ItemDefinition) ([]com.TagOPCITEMRESULTStruct, []int32, error)
+	ValidateItems(items []com.ItemDefinition, blobUpdate bool) ([]com.TagOPCITEMRESULTStruct, []int32, error)
+	RemoveItems(serverHandles []uint32) ([]int32, error)
+	SetActiveState(serverHandles []uint32, active bool) ([]int32, error)
+	SetClientHandles(serverHandles []uint32, clientHandles []uint32) ([]int32, error)
+	SetDatatypes(serverHandles []uint32, requestedDataTypes []com.VT) ([]int32, error)
+	Release()
+}
+
+// ItemDeadbandMgtBackend mirrors IOPCItemDeadbandMgt.
+type ItemDeadbandMgtBackend interface {
+	SetItemDeadband(serverHandles []uint32, percentDeadbands []float32) ([]int32, error)
+	GetItemDeadband(serverHandles []uint32) ([]float32, []int32, error)
+	ClearItemDeadband(serverHandles []uint32) ([]int32, error)
+	Release()
+}
+
+// ItemSamplingMgtBackend mirrors IOPCItemSamplingMgt.
+type ItemSamplingMgtBackend interface {
+	SetItemSamplingRate(serverHandles []uint32, requestedSamplingRates []uint32) (revisedSamplingRates []uint32, errors []int32, err error)
+	GetItemSamplingRate(serverHandles []uint32) ([]uint32, []int32, error)
+	ClearItemSamplingRate(serverHandles []uint32) ([]int32, error)
+	SetItemBufferEnable(serverHandles []uint32, enable []bool) ([]int32, error)
+	GetItemBufferEnable(serverHandles []uint32) ([]bool, []int32, error)
+	Release()
+}
+
+// DataCallback mirrors IOPCDataCallback, backends deliver group events to it.
+type DataCallback interface {
+	OnDataChange(data *CDataChangeCallBackData)
+	OnReadComplete(data *CReadCompleteCallBackData)
+	OnWriteComplete(data *CWriteCompleteCallBackData)
+	OnCancelComplete(data *CCancelCompleteCallBackData)
+}
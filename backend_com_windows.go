@@ -0,0 +1,543 @@
+package opcda
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/huskar-t/opcda/com"
+
+	"golang.org/x/sys/windows"
+)
+
+// COMTransport returns the transport backed by the Windows COM runtime, it is the default transport on Windows.
+// Connect runs its calls on an executor with COM initialized, see WithExecutor, also when it is decorated with
+// InterceptTransport. Another wrapper such as a TransportFunc hides it, com.Initialize must then be called before
+// connecting or an executor passed with WithExecutor.
+func COMTransport() Transport {
+	return comTransport{}
+}
+
+func defaultTransport() Transport {
+	return comTransport{}
+}
+
+// defaultExecutor starts an executor initialized with config for the COM transport and its decorators, an MTA when
+// config is nil. The other transports are called directly.
+func defaultExecutor(transport Transport, config *com.InitConfig) (*com.Executor, error) {
+	if !needsExecutor(transport) {
+		return nil, nil
+	}
+	if config == nil {
+		return com.NewExecutor()
+	}
+	return com.NewExecutor(com.WithInitConfig(config))
+}
+
+type comTransport struct{}
+
+// needsExecutor reports that the COM runtime has to be initialized on the calling thread
+func (comTransport) needsExecutor() bool {
+	return true
+}
+
+func (comTransport) Connect(progID, node string, credentials *Credentials) (ServerBackend, error) {
+	location := com.CLSCTX_LOCAL_SERVER
+	if !com.IsLocal(node) {
+		location = com.CLSCTX_REMOTE_SERVER
+	}
+	var auth *com.COAUTHINFO
+	if credentials != nil && location == com.CLSCTX_REMOTE_SERVER {
+		auth = com.NewCOAUTHINFO(credentials.Domain, credentials.User, credentials.Password, credentials.authnLevel(), credentials.impLevel())
+	}
+	clsid, err := getClsID(progID, node, location, auth)
+	if err != nil {
+		return nil, NewOPCWrapperError("get clsid", err)
+	}
+	iUnknownServer, err := com.MakeCOMObjectWithAuth(node, location, clsid, &com.IID_IOPCServer, auth)
+	if err != nil {
+		return nil, NewOPCWrapperError("make com object IOPCServer", err)
+	}
+	if auth != nil {
+		// remote QueryInterface calls go through the IUnknown of the proxy manager
+		iUnknown, err := queryInterface(iUnknownServer, com.IID_IUnknown, auth)
+		if err == nil {
+			iUnknown.Release()
+			err = setBlanket(iUnknownServer, auth)
+		}
+		if err != nil {
+			iUnknownServer.Release()
+			return nil, NewOPCWrapperError("set proxy blanket", err)
+		}
+	}
+	return &comServer{IOPCServer: &com.IOPCServer{IUnknown: iUnknownServer}, auth: auth}, nil
+}
+
+// setBlanket applies the explicit credentials to a proxy, it does nothing when the connection runs as the current user
+func setBlanket(iUnknown *com.IUnknown, auth *com.COAUTHINFO) error {
+	if auth == nil {
+		return nil
+	}
+	return com.CoSetProxyBlanket(iUnknown, auth)
+}
+
+// queryInterface queries iid and applies the explicit credentials to the new proxy
+func queryInterface(iUnknown *com.IUnknown, iid *windows.GUID, auth *com.COAUTHINFO) (*com.IUnknown, error) {
+	var result *com.IUnknown
+	err := iUnknown.QueryInterface(iid, unsafe.Pointer(&result))
+	if err != nil {
+		return nil, err
+	}
+	if err = setBlanket(result, auth); err != nil {
+		result.Release()
+		return nil, err
+	}
+	return result, nil
+}
+
+type comServer struct {
+	*com.IOPCServer
+	// auth keeps the explicit credentials of the proxies reachable, nil for the current user
+	auth *com.COAUTHINFO
+	// events keeps the advised receivers reachable while the server holds them
+	events map[uint32]*ShutdownEventReceiver
+}
+
+func (s *comServer) AddGroup(name string, active bool, requestedUpdateRate uint32, clientGroup uint32, timeBias *int32, percentDeadband *float32, localeID uint32) (uint32, uint32, GroupBackend, error) {
+	serverGroup, revisedUpdateRate, iUnknown, err := s.IOPCServer.AddGroup(name, active, requestedUpdateRate, clientGroup, timeBias, percentDeadband, localeID, &com.IID_IOPCGroupStateMgt)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if err = setBlanket(iUnknown, s.auth); err != nil {
+		iUnknown.Release()
+		return 0, 0, nil, err
+	}
+	return serverGroup, revisedUpdateRate, &comGroup{IOPCGroupStateMgt: &com.IOPCGroupStateMgt{IUnknown: iUnknown}, auth: s.auth}, nil
+}
+
+func (s *comServer) QueryCommon() (CommonBackend, error) {
+	iUnknown, err := queryInterface(s.IUnknown, &com.IID_IOPCCommon, s.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comCommon{&com.IOPCCommon{IUnknown: iUnknown}}, nil
+}
+
+func (s *comServer) QueryItemProperties() (ItemPropertiesBackend, error) {
+	iUnknown, err := queryInterface(s.IUnknown, &com.IID_IOPCItemProperties, s.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comItemProperties{&com.IOPCItemProperties{IUnknown: iUnknown}}, nil
+}
+
+func (s *comServer) QueryBrowseServerAddressSpace() (BrowseServerAddressSpaceBackend, error) {
+	iUnknown, err := queryInterface(s.IUnknown, &com.IID_IOPCBrowseServerAddressSpace, s.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comBrowseServerAddressSpace{&com.IOPCBrowseServerAddressSpace{IUnknown: iUnknown}}, nil
+}
+
+func (s *comServer) QueryBrowse() (BrowseBackend, error) {
+	iUnknown, err := queryInterface(s.IUnknown, &com.IID_IOPCBrowse, s.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comBrowse{&com.IOPCBrowse{IUnknown: iUnknown}}, nil
+}
+
+func (s *comServer) QueryItemIO() (ItemIOBackend, error) {
+	iUnknown, err := queryInterface(s.IUnknown, &com.IID_IOPCItemIO, s.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comItemIO{&com.IOPCItemIO{IUnknown: iUnknown}}, nil
+}
+
+func (s *comServer) AdviseShutdown(onShutdown func(reason string)) (uint32, error) {
+	event := newShutdownEventReceiver(onShutdown)
+	cookie, err := advise(s.IUnknown, s.auth, &IID_IOPCShutdown, unsafe.Pointer(event))
+	if err != nil {
+		return 0, err
+	}
+	if s.events == nil {
+		s.events = make(map[uint32]*ShutdownEventReceiver)
+	}
+	s.events[cookie] = event
+	return cookie, nil
+}
+
+func (s *comServer) UnadviseShutdown(cookie uint32) error {
+	err := unadvise(s.IUnknown, s.auth, &IID_IOPCShutdown, cookie)
+	delete(s.events, cookie)
+	return err
+}
+
+func (s *comServer) Release() {
+	s.IOPCServer.Release()
+}
+
+type comCommon struct {
+	*com.IOPCCommon
+}
+
+func (c comCommon) Release() {
+	c.IOPCCommon.Release()
+}
+
+type comItemProperties struct {
+	*com.IOPCItemProperties
+}
+
+func (p comItemProperties) Release() {
+	p.IOPCItemProperties.Release()
+}
+
+type comBrowseServerAddressSpace struct {
+	*com.IOPCBrowseServerAddressSpace
+}
+
+func (b comBrowseServerAddressSpace) Release() {
+	b.IOPCBrowseServerAddressSpace.Release()
+}
+
+type comBrowse struct {
+	*com.IOPCBrowse
+}
+
+func (b comBrowse) Release() {
+	b.IOPCBrowse.Release()
+}
+
+type comItemIO struct {
+	*com.IOPCItemIO
+}
+
+func (i comItemIO) WriteVQT(itemIDs []string, items []*com.ItemVQT) ([]int32, error) {
+	vqts, clear, err := newTagItemVQTs(items)
+	if err != nil {
+		return nil, err
+	}
+	defer clear()
+	return i.IOPCItemIO.WriteVQT(itemIDs, vqts)
+}
+
+func (i comItemIO) Release() {
+	i.IOPCItemIO.Release()
+}
+
+type comGroup struct {
+	*com.IOPCGroupStateMgt
+	auth *com.COAUTHINFO
+	// events keeps the advised receivers reachable while the server holds them
+	events map[uint32]*DataEventReceiver
+}
+
+func (g *comGroup) SetState(requestedUpdateRate *uint32, active *bool, timeBias *int32, percentDeadband *float32, localeID *uint32, clientGroup *uint32) (uint32, error) {
+	var pActive *int32
+	if active != nil {
+		v := com.BoolToComBOOL(*active)
+		pActive = &v
+	}
+	return g.IOPCGroupStateMgt.SetState(requestedUpdateRate, pActive, timeBias, percentDeadband, localeID, clientGroup)
+}
+
+func (g *comGroup) QuerySyncIO() (SyncIOBackend, error) {
+	iUnknown, err := queryInterface(g.IUnknown, &com.IID_IOPCSyncIO, g.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comSyncIO{&com.IOPCSyncIO{IUnknown: iUnknown}}, nil
+}
+
+func (g *comGroup) QueryAsyncIO2() (AsyncIO2Backend, error) {
+	iUnknown, err := queryInterface(g.IUnknown, &com.IID_IOPCAsyncIO2, g.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comAsyncIO2{&com.IOPCAsyncIO2{IUnknown: iUnknown}}, nil
+}
+
+func (g *comGroup) QuerySyncIO2() (SyncIO2Backend, error) {
+	iUnknown, err := queryInterface(g.IUnknown, &com.IID_IOPCSyncIO2, g.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comSyncIO2{&com.IOPCSyncIO2{IUnknown: iUnknown}}, nil
+}
+
+func (g *comGroup) QueryAsyncIO3() (AsyncIO3Backend, error) {
+	iUnknown, err := queryInterface(g.IUnknown, &com.IID_IOPCAsyncIO3, g.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comAsyncIO3{&com.IOPCAsyncIO3{IUnknown: iUnknown}}, nil
+}
+
+func (g *comGroup) QueryItemMgt() (ItemMgtBackend, error) {
+	iUnknown, err := queryInterface(g.IUnknown, &com.IID_IOPCItemMgt, g.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comItemMgt{&com.IOPCItemMgt{IUnknown: iUnknown}}, nil
+}
+
+func (g *comGroup) QueryItemDeadbandMgt() (ItemDeadbandMgtBackend, error) {
+	iUnknown, err := queryInterface(g.IUnknown, &com.IID_IOPCItemDeadbandMgt, g.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comItemDeadbandMgt{&com.IOPCItemDeadbandMgt{IUnknown: iUnknown}}, nil
+}
+
+func (g *comGroup) QueryGroupStateMgt2() (GroupStateMgt2Backend, error) {
+	iUnknown, err := queryInterface(g.IUnknown, &com.IID_IOPCGroupStateMgt2, g.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comGroupStateMgt2{&com.IOPCGroupStateMgt2{IUnknown: iUnknown}}, nil
+}
+
+func (g *comGroup) QueryItemSamplingMgt() (ItemSamplingMgtBackend, error) {
+	iUnknown, err := queryInterface(g.IUnknown, &com.IID_IOPCItemSamplingMgt, g.auth)
+	if err != nil {
+		return nil, err
+	}
+	return comItemSamplingMgt{&com.IOPCItemSamplingMgt{IUnknown: iUnknown}}, nil
+}
+
+func (g *comGroup) AdviseDataCallback(callback DataCallback) (uint32, error) {
+	event := newDataEventReceiver(callback)
+	cookie, err := advise(g.IUnknown, g.auth, &IID_IOPCDataCallback, unsafe.Pointer(event))
+	if err != nil {
+		return 0, err
+	}
+	if g.events == nil {
+		g.events = make(map[uint32]*DataEventReceiver)
+	}
+	g.events[cookie] = event
+	return cookie, nil
+}
+
+func (g *comGroup) UnadviseDataCallback(cookie uint32) error {
+	err := unadvise(g.IUnknown, g.auth, &IID_IOPCDataCallback, cookie)
+	delete(g.events, cookie)
+	return err
+}
+
+func (g *comGroup) Release() {
+	g.IOPCGroupStateMgt.Release()
+}
+
+type comSyncIO struct {
+	*com.IOPCSyncIO
+}
+
+func (s comSyncIO) Write(serverHandles []uint32, values []interface{}) ([]int32, error) {
+	variants, clear, err := newVariants(values)
+	if err != nil {
+		return nil, err
+	}
+	defer clear()
+	return s.IOPCSyncIO.Write(serverHandles, variants)
+}
+
+func (s comSyncIO) Release() {
+	s.IOPCSyncIO.Release()
+}
+
+type comAsyncIO2 struct {
+	*com.IOPCAsyncIO2
+}
+
+func (a comAsyncIO2) Write(serverHandles []uint32, values []interface{}, transactionID uint32) (uint32, []int32, error) {
+	variants, clear, err := newVariants(values)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer clear()
+	return a.IOPCAsyncIO2.Write(serverHandles, variants, transactionID)
+}
+
+func (a comAsyncIO2) Release() {
+	a.IOPCAsyncIO2.Release()
+}
+
+type comSyncIO2 struct {
+	*com.IOPCSyncIO2
+}
+
+func (s comSyncIO2) WriteVQT(serverHandles []uint32, items []*com.ItemVQT) ([]int32, error) {
+	vqts, clear, err := newTagItemVQTs(items)
+	if err != nil {
+		return nil, err
+	}
+	defer clear()
+	return s.IOPCSyncIO2.WriteVQT(serverHandles, vqts)
+}
+
+func (s comSyncIO2) Release() {
+	s.IOPCSyncIO2.Release()
+}
+
+type comAsyncIO3 struct {
+	*com.IOPCAsyncIO3
+}
+
+func (a comAsyncIO3) WriteVQT(serverHandles []uint32, items []*com.ItemVQT, transactionID uint32) (uint32, []int32, error) {
+	vqts, clear, err := newTagItemVQTs(items)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer clear()
+	return a.IOPCAsyncIO3.WriteVQT(serverHandles, vqts, transactionID)
+}
+
+func (a comAsyncIO3) Release() {
+	a.IOPCAsyncIO3.Release()
+}
+
+type comItemDeadbandMgt struct {
+	*com.IOPCItemDeadbandMgt
+}
+
+func (m comItemDeadbandMgt) Release() {
+	m.IOPCItemDeadbandMgt.Release()
+}
+
+type comGroupStateMgt2 struct {
+	*com.IOPCGroupStateMgt2
+}
+
+func (m comGroupStateMgt2) Release() {
+	m.IOPCGroupStateMgt2.Release()
+}
+
+type comItemSamplingMgt struct {
+	*com.IOPCItemSamplingMgt
+}
+
+func (m comItemSamplingMgt) Release() {
+	m.IOPCItemSamplingMgt.Release()
+}
+
+type comItemMgt struct {
+	*com.IOPCItemMgt
+}
+
+func (m comItemMgt) AddItems(items []com.ItemDefinition) ([]com.TagOPCITEMRESULTStruct, []int32, error) {
+	return m.IOPCItemMgt.AddItems(toTagOPCITEMDEF(items))
+}
+
+func (m comItemMgt) ValidateItems(items []com.ItemDefinition, blobUpdate bool) ([]com.TagOPCITEMRESULTStruct, []int32, error) {
+	return m.IOPCItemMgt.ValidateItems(toTagOPCITEMDEF(items), blobUpdate)
+}
+
+func (m comItemMgt) Release() {
+	m.IOPCItemMgt.Release()
+}
+
+func toTagOPCITEMDEF(items []com.ItemDefinition) []com.TagOPCITEMDEF {
+	definitions := make([]com.TagOPCITEMDEF, len(items))
+	for i, item := range items {
+		definitions[i] = com.TagOPCITEMDEF{
+			SzAccessPath: windows.StringToUTF16Ptr(item.AccessPath),
+			SzItemID:     windows.StringToUTF16Ptr(item.ItemID),
+			BActive:      com.BoolToComBOOL(item.Active),
+			HClient:      item.ClientHandle,
+			DwBlobSize:   uint32(len(item.Blob)),
+			VtRequested:  uint16(item.RequestedDataType),
+		}
+		if len(item.Blob) > 0 {
+			definitions[i].PBlob = &item.Blob[0]
+		}
+	}
+	return definitions
+}
+
+// newVariants converts values to VARIANTs, clear must be called once the VARIANTs are no longer used
+func newVariants(values []interface{}) (variants []com.VARIANT, clear func(), err error) {
+	variants = make([]com.VARIANT, len(values))
+	variantWrappers := make([]*com.VariantWrapper, 0, len(values))
+	clear = func() {
+		for _, variant := range variantWrappers {
+			err := variant.Clear()
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+	for i, v := range values {
+		variant, err := com.NewVariant(v)
+		if err != nil {
+			clear()
+			return nil, nil, err
+		}
+		variantWrappers = append(variantWrappers, variant)
+		variants[i] = *variant.Variant
+	}
+	return variants, clear, nil
+}
+
+// newTagItemVQTs converts the items to OPCITEMVQT, clear releases the variants
+func newTagItemVQTs(items []*com.ItemVQT) (vqts []com.TagOPCITEMVQT, clear func(), err error) {
+	values := make([]interface{}, len(items))
+	for i, item := range items {
+		values[i] = item.Value
+	}
+	variants, clear, err := newVariants(values)
+	if err != nil {
+		return nil, nil, err
+	}
+	vqts = make([]com.TagOPCITEMVQT, len(items))
+	for i, item := range items {
+		vqts[i].VDataValue = variants[i]
+		vqts[i].BQualitySpecified = com.BoolToComBOOL(item.QualitySpecified)
+		vqts[i].WQuality = uint16(item.Quality)
+		vqts[i].BTimeStampSpecified = com.BoolToComBOOL(item.TimestampSpecified)
+		if item.TimestampSpecified {
+			vqts[i].FtTimeStamp = windows.NsecToFiletime(item.Timestamp.UnixNano())
+		}
+	}
+	return vqts, clear, nil
+}
+
+func findConnectionPoint(iUnknown *com.IUnknown, auth *com.COAUTHINFO, iid *windows.GUID) (*com.IConnectionPoint, error) {
+	iUnknownContainer, err := queryInterface(iUnknown, &com.IID_IConnectionPointContainer, auth)
+	if err != nil {
+		return nil, NewOPCWrapperError("query interface IConnectionPointContainer", err)
+	}
+	container := &com.IConnectionPointContainer{IUnknown: iUnknownContainer}
+	defer container.Release()
+	point, err := container.FindConnectionPoint(iid)
+	if err != nil {
+		return nil, NewOPCWrapperError("container find connect point", err)
+	}
+	if err = setBlanket(point.IUnknown, auth); err != nil {
+		point.Release()
+		return nil, NewOPCWrapperError("set proxy blanket", err)
+	}
+	return point, nil
+}
+
+func advise(iUnknown *com.IUnknown, auth *com.COAUTHINFO, iid *windows.GUID, sink unsafe.Pointer) (uint32, error) {
+	point, err := findConnectionPoint(iUnknown, auth, iid)
+	if err != nil {
+		return 0, err
+	}
+	defer point.Release()
+	cookie, err := point.Advise((*com.IUnknown)(sink))
+	if err != nil {
+		return 0, NewOPCWrapperError("point advise", err)
+	}
+	return cookie, nil
+}
+
+func unadvise(iUnknown *com.IUnknown, auth *com.COAUTHINFO, iid *windows.GUID, cookie uint32) error {
+	point, err := findConnectionPoint(iUnknown, auth, iid)
+	if err != nil {
+		return err
+	}
+	defer point.Release()
+	return point.Unadvise(cookie)
+}
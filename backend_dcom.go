@@ -0,0 +1,309 @@
+package opcda
+
+import (
+	"errors"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom"
+	"github.com/huskar-t/opcda/dcom/ntlm"
+)
+
+// ErrCallbackNotSupported is returned by the DCOM transport for the operations that need the server to call back into the client,
+// such as data change subscriptions, asynchronous IO and shutdown notifications.
+var ErrCallbackNotSupported = errors.New("server callbacks are not supported by the DCOM transport")
+
+// DCOMTransport returns the transport that speaks DCOM over TCP without the Windows COM runtime, it is the default transport outside Windows.
+// progID may also be a CLSID, which avoids the OpcEnum lookup. cfg may be nil.
+func DCOMTransport(cfg *dcom.Config) Transport {
+	return dcomTransport{cfg: cfg}
+}
+
+type dcomTransport struct {
+	cfg *dcom.Config
+}
+
+func (t dcomTransport) Connect(progID, node string, credentials *Credentials) (ServerBackend, error) {
+	host := node
+	if host == "" {
+		host = "localhost"
+	}
+	cfg := &dcom.Config{}
+	if t.cfg != nil {
+		*cfg = *t.cfg
+	}
+	if credentials != nil {
+		cfg.Credentials = &ntlm.Credentials{Domain: credentials.Domain, User: credentials.User, Password: credentials.Password}
+		cfg.AuthnLevel = credentials.authnLevel()
+		cfg.ImpLevel = credentials.impLevel()
+	}
+	client, err := dcom.Dial(host, cfg)
+	if err != nil {
+		return nil, NewOPCWrapperError("dcom dial", err)
+	}
+	clsid, err := dcomCLSID(client, progID)
+	if err != nil {
+		client.Close()
+		return nil, NewOPCWrapperError("get clsid", err)
+	}
+	object, err := client.CreateInstance(clsid, com.IID_IOPCServer)
+	if err != nil {
+		client.Close()
+		return nil, NewOPCWrapperError("make com object IOPCServer", err)
+	}
+	return &dcomServer{IOPCServer: &dcom.IOPCServer{Object: object}}, nil
+}
+
+// dcomCLSID resolves progID with the OpcEnum service of the remote machine unless it is already a CLSID
+func dcomCLSID(client *dcom.Client, progID string) (com.GUID, error) {
+	if clsid, err := com.ParseGUID(progID); err == nil {
+		return clsid, nil
+	}
+	object, err := client.CreateInstance(com.CLSID_OpcServerList, com.IID_IOPCServerList)
+	if err != nil {
+		return com.GUID{}, err
+	}
+	serverList := &dcom.IOPCServerList{Object: object}
+	defer serverList.Release()
+	clsid, err := serverList.CLSIDFromProgID(progID)
+	if err != nil {
+		return com.GUID{}, err
+	}
+	return *clsid, nil
+}
+
+type dcomServer struct {
+	*dcom.IOPCServer
+}
+
+func (s *dcomServer) AddGroup(name string, active bool, requestedUpdateRate uint32, clientGroup uint32, timeBias *int32, percentDeadband *float32, localeID uint32) (uint32, uint32, GroupBackend, error) {
+	serverGroup, revisedUpdateRate, object, err := s.IOPCServer.AddGroup(name, active, requestedUpdateRate, clientGroup, timeBias, percentDeadband, localeID, &com.IID_IOPCGroupStateMgt)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return serverGroup, revisedUpdateRate, &dcomGroup{IOPCGroupStateMgt: &dcom.IOPCGroupStateMgt{Object: object}}, nil
+}
+
+func (s *dcomServer) QueryCommon() (CommonBackend, error) {
+	object, err := s.QueryInterface(com.IID_IOPCCommon)
+	if err != nil {
+		return nil, err
+	}
+	return dcomCommon{&dcom.IOPCCommon{Object: object}}, nil
+}
+
+func (s *dcomServer) QueryItemProperties() (ItemPropertiesBackend, error) {
+	object, err := s.QueryInterface(com.IID_IOPCItemProperties)
+	if err != nil {
+		return nil, err
+	}
+	return dcomItemProperties{&dcom.IOPCItemProperties{Object: object}}, nil
+}
+
+func (s *dcomServer) QueryBrowseServerAddressSpace() (BrowseServerAddressSpaceBackend, error) {
+	object, err := s.QueryInterface(com.IID_IOPCBrowseServerAddressSpace)
+	if err != nil {
+		return nil, err
+	}
+	return dcomBrowseServerAddressSpace{&dcom.IOPCBrowseServerAddressSpace{Object: object}}, nil
+}
+
+func (s *dcomServer) QueryBrowse() (BrowseBackend, error) {
+	object, err := s.QueryInterface(com.IID_IOPCBrowse)
+	if err != nil {
+		return nil, err
+	}
+	return dcomBrowse{&dcom.IOPCBrowse{Object: object}}, nil
+}
+
+func (s *dcomServer) QueryItemIO() (ItemIOBackend, error) {
+	object, err := s.QueryInterface(com.IID_IOPCItemIO)
+	if err != nil {
+		return nil, err
+	}
+	return dcomItemIO{&dcom.IOPCItemIO{Object: object}}, nil
+}
+
+func (s *dcomServer) AdviseShutdown(onShutdown func(reason string)) (uint32, error) {
+	return 0, ErrCallbackNotSupported
+}
+
+func (s *dcomServer) UnadviseShutdown(cookie uint32) error {
+	return ErrCallbackNotSupported
+}
+
+// Release releases the server and closes the connections, it must be the last interface released
+func (s *dcomServer) Release() {
+	s.IOPCServer.Release()
+	s.Client().Close()
+}
+
+type dcomCommon struct {
+	*dcom.IOPCCommon
+}
+
+func (c dcomCommon) Release() {
+	c.IOPCCommon.Release()
+}
+
+type dcomItemProperties struct {
+	*dcom.IOPCItemProperties
+}
+
+func (p dcomItemProperties) Release() {
+	p.IOPCItemProperties.Release()
+}
+
+type dcomBrowseServerAddressSpace struct {
+	*dcom.IOPCBrowseServerAddressSpace
+}
+
+func (b dcomBrowseServerAddressSpace) Release() {
+	b.IOPCBrowseServerAddressSpace.Release()
+}
+
+type dcomBrowse struct {
+	*dcom.IOPCBrowse
+}
+
+func (b dcomBrowse) Release() {
+	b.IOPCBrowse.Release()
+}
+
+type dcomItemIO struct {
+	*dcom.IOPCItemIO
+}
+
+func (i dcomItemIO) Release() {
+	i.IOPCItemIO.Release()
+}
+
+type dcomGroup struct {
+	*dcom.IOPCGroupStateMgt
+}
+
+func (g *dcomGroup) SetState(requestedUpdateRate *uint32, active *bool, timeBias *int32, percentDeadband *float32, localeID *uint32, clientGroup *uint32) (uint32, error) {
+	var pActive *int32
+	if active != nil {
+		v := com.BoolToComBOOL(*active)
+		pActive = &v
+	}
+	return g.IOPCGroupStateMgt.SetState(requestedUpdateRate, pActive, timeBias, percentDeadband, localeID, clientGroup)
+}
+
+func (g *dcomGroup) QuerySyncIO() (SyncIOBackend, error) {
+	object, err := g.QueryInterface(com.IID_IOPCSyncIO)
+	if err != nil {
+		return nil, err
+	}
+	return dcomSyncIO{&dcom.IOPCSyncIO{Object: object}}, nil
+}
+
+// QueryAsyncIO2 fails with ErrCallbackNotSupported, the results of IOPCAsyncIO2 are only delivered through IOPCDataCallback
+func (g *dcomGroup) QueryAsyncIO2() (AsyncIO2Backend, error) {
+	return nil, ErrCallbackNotSupported
+}
+
+func (g *dcomGroup) QuerySyncIO2() (SyncIO2Backend, error) {
+	object, err := g.QueryInterface(com.IID_IOPCSyncIO2)
+	if err != nil {
+		return nil, err
+	}
+	return dcomSyncIO2{&dcom.IOPCSyncIO2{Object: object}}, nil
+}
+
+// QueryAsyncIO3 fails with ErrCallbackNotSupported, the results of IOPCAsyncIO3 are only delivered through IOPCDataCallback
+func (g *dcomGroup) QueryAsyncIO3() (AsyncIO3Backend, error) {
+	return nil, ErrCallbackNotSupported
+}
+
+func (g *dcomGroup) QueryItemMgt() (ItemMgtBackend, error) {
+	object, err := g.QueryInterface(com.IID_IOPCItemMgt)
+	if err != nil {
+		return nil, err
+	}
+	return dcomItemMgt{&dcom.IOPCItemMgt{Object: object}}, nil
+}
+
+func (g *dcomGroup) QueryItemDeadbandMgt() (ItemDeadbandMgtBackend, error) {
+	object, err := g.QueryInterface(com.IID_IOPCItemDeadbandMgt)
+	if err != nil {
+		return nil, err
+	}
+	return dcomItemDeadbandMgt{&dcom.IOPCItemDeadbandMgt{Object: object}}, nil
+}
+
+func (g *dcomGroup) QueryGroupStateMgt2() (GroupStateMgt2Backend, error) {
+	object, err := g.QueryInterface(com.IID_IOPCGroupStateMgt2)
+	if err != nil {
+		return nil, err
+	}
+	return dcomGroupStateMgt2{&dcom.IOPCGroupStateMgt2{Object: object}}, nil
+}
+
+func (g *dcomGroup) QueryItemSamplingMgt() (ItemSamplingMgtBackend, error) {
+	object, err := g.QueryInterface(com.IID_IOPCItemSamplingMgt)
+	if err != nil {
+		return nil, err
+	}
+	return dcomItemSamplingMgt{&dcom.IOPCItemSamplingMgt{Object: object}}, nil
+}
+
+func (g *dcomGroup) AdviseDataCallback(callback DataCallback) (uint32, error) {
+	return 0, ErrCallbackNotSupported
+}
+
+func (g *dcomGroup) UnadviseDataCallback(cookie uint32) error {
+	return ErrCallbackNotSupported
+}
+
+func (g *dcomGroup) Release() {
+	g.IOPCGroupStateMgt.Release()
+}
+
+type dcomSyncIO struct {
+	*dcom.IOPCSyncIO
+}
+
+func (s dcomSyncIO) Release() {
+	s.IOPCSyncIO.Release()
+}
+
+type dcomSyncIO2 struct {
+	*dcom.IOPCSyncIO2
+}
+
+func (s dcomSyncIO2) Release() {
+	s.IOPCSyncIO2.Release()
+}
+
+type dcomItemDeadbandMgt struct {
+	*dcom.IOPCItemDeadbandMgt
+}
+
+func (m dcomItemDeadbandMgt) Release() {
+	m.IOPCItemDeadbandMgt.Release()
+}
+
+type dcomGroupStateMgt2 struct {
+	*dcom.IOPCGroupStateMgt2
+}
+
+func (m dcomGroupStateMgt2) Release() {
+	m.IOPCGroupStateMgt2.Release()
+}
+
+type dcomItemSamplingMgt struct {
+	*dcom.IOPCItemSamplingMgt
+}
+
+func (m dcomItemSamplingMgt) Release() {
+	m.IOPCItemSamplingMgt.Release()
+}
+
+type dcomItemMgt struct {
+	*dcom.IOPCItemMgt
+}
+
+func (m dcomItemMgt) Release() {
+	m.IOPCItemMgt.Release()
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package opcda
+
+import (
+	"github.com/huskar-t/opcda/com"
+)
+
+func defaultTransport() Transport {
+	return dcomTransport{}
+}
+
+// defaultExecutor returns nil, the DCOM transport does not need the COM runtime
+func defaultExecutor(transport Transport, config *com.InitConfig) (*com.Executor, error) {
+	return nil, nil
+}
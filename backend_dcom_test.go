@@ -0,0 +1,1150 @@
+package opcda
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom"
+	"github.com/huskar-t/opcda/dcom/dcomtest"
+	"github.com/huskar-t/opcda/dcom/ndr"
+	"github.com/huskar-t/opcda/dcom/ntlm"
+	"github.com/huskar-t/opcda/dcom/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const standInCLSID = "{8E1E1C5D-3E6A-4A1B-9E0B-2F6C7A1D4B01}"
+
+// standInOPC is a minimal OPC DA server answering the ORPC calls made by the DCOM transport
+type standInOPC struct {
+	*dcomtest.Server
+	lock       sync.Mutex
+	values     map[string]interface{}
+	groups     map[uint32]*standInGroup
+	nextGroup  uint32
+	clientName string
+}
+
+type standInGroup struct {
+	name         string
+	clientHandle uint32
+	serverHandle uint32
+	updateRate   uint32
+	active       bool
+	items        map[uint32]string
+	nextItem     uint32
+	deadbands    map[uint32]float32
+	rates        map[uint32]uint32
+	buffered     map[uint32]bool
+	keepAlive    uint32
+}
+
+func newStandInOPC(t *testing.T) *standInOPC {
+	server, err := dcomtest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		server.Close()
+	})
+	s := &standInOPC{
+		Server: server,
+		values: map[string]interface{}{
+			"Int":   int32(7),
+			"Float": float64(1.5),
+			"Text":  "hello",
+			"Bool":  true,
+		},
+		groups: make(map[uint32]*standInGroup),
+	}
+	clsid, err := com.ParseGUID(standInCLSID)
+	require.NoError(t, err)
+	server.RegisterClass(clsid, func() *dcomtest.Object {
+		return dcomtest.NewObject().
+			Implement(com.IID_IOPCServer, s.serverCall).
+			Implement(com.IID_IOPCCommon, s.commonCall).
+			Implement(com.IID_IOPCItemProperties, s.itemPropertiesCall).
+			Implement(com.IID_IOPCBrowse, s.browseCall).
+			Implement(com.IID_IOPCItemIO, s.itemIOCall)
+	})
+	return s
+}
+
+func canonicalType(value interface{}) com.VT {
+	switch value.(type) {
+	case int32:
+		return com.VT_I4
+	case float64:
+		return com.VT_R8
+	case string:
+		return com.VT_BSTR
+	case bool:
+		return com.VT_BOOL
+	}
+	return com.VT_EMPTY
+}
+
+func writeStandInErrors(w *ndr.Writer, errors []int32) {
+	w.WritePointer(true)
+	w.WriteUint32(uint32(len(errors)))
+	for _, e := range errors {
+		w.WriteInt32(e)
+	}
+}
+
+func (s *standInOPC) serverCall(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+	switch opnum {
+	case 3:
+		name := r.ReadString()
+		active := r.ReadInt32() != 0
+		rate := r.ReadUint32()
+		clientHandle := r.ReadUint32()
+		if r.ReadPointer() {
+			r.ReadInt32()
+		}
+		if r.ReadPointer() {
+			r.ReadFloat32()
+		}
+		r.ReadUint32()
+		iid := r.ReadGUID()
+		s.lock.Lock()
+		s.nextGroup++
+		group := &standInGroup{name: name, clientHandle: clientHandle, serverHandle: s.nextGroup, updateRate: rate, active: active, items: make(map[uint32]string), deadbands: make(map[uint32]float32), rates: make(map[uint32]uint32), buffered: make(map[uint32]bool)}
+		s.groups[group.serverHandle] = group
+		s.lock.Unlock()
+		object := dcomtest.NewObject().
+			Implement(com.IID_IOPCGroupStateMgt, s.groupStateCall(group)).
+			Implement(com.IID_IOPCGroupStateMgt2, s.groupStateCall(group)).
+			Implement(com.IID_IOPCSyncIO, s.syncIOCall(group)).
+			Implement(com.IID_IOPCSyncIO2, s.syncIO2Call(group)).
+			Implement(com.IID_IOPCItemMgt, s.itemMgtCall(group)).
+			Implement(com.IID_IOPCItemDeadbandMgt, s.itemDeadbandMgtCall(group)).
+			Implement(com.IID_IOPCItemSamplingMgt, s.itemSamplingMgtCall(group))
+		objRef, err := s.Export(object, iid)
+		if err != nil {
+			return uint32(dcom.ENoInterface)
+		}
+		w.WriteUint32(group.serverHandle)
+		w.WriteUint32(rate)
+		dcom.WriteInterfacePointer(w, objRef)
+		return 0
+	case 6:
+		s.lock.Lock()
+		groupCount := len(s.groups)
+		s.lock.Unlock()
+		w.WritePointer(true)
+		start := dcom.TimeToFileTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+		for i := 0; i < 3; i++ {
+			w.WriteUint32(uint32(start))
+			w.WriteUint32(uint32(start >> 32))
+		}
+		w.WriteUint16(1)
+		w.WriteUint32(uint32(groupCount))
+		w.WriteUint32(0xffffffff)
+		w.WriteUint16(1)
+		w.WriteUint16(2)
+		w.WriteUint16(3)
+		w.WriteUint16(0)
+		w.WritePointer(true)
+		w.WriteString("opcda stand-in")
+		return 0
+	case 7:
+		handle := r.ReadUint32()
+		r.ReadInt32()
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if _, ok := s.groups[handle]; !ok {
+			return uint32(dcom.EInvalidArg)
+		}
+		delete(s.groups, handle)
+		return 0
+	}
+	return uint32(dcom.ENotImpl)
+}
+
+func (s *standInOPC) commonCall(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+	switch opnum {
+	case 3:
+		r.ReadUint32()
+		return 0
+	case 4:
+		w.WriteUint32(0x409)
+		return 0
+	case 5:
+		w.WriteUint32(2)
+		w.WritePointer(true)
+		w.WriteUint32Array([]uint32{0x409, 0x804})
+		return 0
+	case 6:
+		code := r.ReadUint32()
+		w.WritePointer(true)
+		w.WriteString(fmt.Sprintf("stand-in error 0x%08X", code))
+		return 0
+	case 7:
+		name := r.ReadString()
+		s.lock.Lock()
+		s.clientName = name
+		s.lock.Unlock()
+		return 0
+	}
+	return uint32(dcom.ENotImpl)
+}
+
+func (s *standInOPC) itemPropertiesCall(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+	switch opnum {
+	case 3:
+		r.ReadString()
+		w.WriteUint32(2)
+		w.WritePointer(true)
+		w.WriteUint32Array([]uint32{1, 5})
+		w.WritePointer(true)
+		w.WriteUint32(2)
+		for _, description := range []string{"Item Canonical DataType", "Item Access Rights"} {
+			description := description
+			w.WritePointer(true)
+			w.Defer(func() {
+				w.WriteString(description)
+			})
+		}
+		w.Flush()
+		w.WritePointer(true)
+		w.WriteUint32(2)
+		w.WriteUint16(uint16(com.VT_I2))
+		w.WriteUint16(uint16(com.VT_I4))
+		return 0
+	case 4:
+		itemID := r.ReadString()
+		r.ReadUint32()
+		ids := r.ReadUint32Array()
+		s.lock.Lock()
+		value, ok := s.values[itemID]
+		s.lock.Unlock()
+		errors := make([]int32, len(ids))
+		w.WritePointer(true)
+		w.WriteUint32(uint32(len(ids)))
+		for i, id := range ids {
+			var property interface{}
+			switch {
+			case !ok:
+				errors[i] = int32(OPCUnknownItemID)
+			case id == 1:
+				property = int16(canonicalType(value))
+			case id == 5:
+				property = int32(OPC_READABLE | OPC_WRITEABLE)
+			default:
+				errors[i] = int32(OPCInvalidPID)
+			}
+			w.WritePointer(true)
+			w.Defer(func() {
+				_ = dcom.WriteVariant(w, property)
+			})
+		}
+		w.Flush()
+		writeStandInErrors(w, errors)
+		return 0
+	}
+	return uint32(dcom.ENotImpl)
+}
+
+// writeStandInProperties writes an embedded OPCITEMPROPERTIES with the properties 1 and 5 of an item
+func (s *standInOPC) writeStandInProperties(w *ndr.Writer, itemID string, ids []uint32, returnValues bool) {
+	s.lock.Lock()
+	value, ok := s.values[itemID]
+	s.lock.Unlock()
+	if !ok {
+		w.WriteInt32(int32(OPCUnknownItemID))
+		w.WriteUint32(0)
+		w.WritePointer(false)
+		w.WriteUint32(0)
+		return
+	}
+	var hr int32
+	for _, id := range ids {
+		if id != 1 && id != 5 {
+			hr = 1
+		}
+	}
+	w.WriteInt32(hr)
+	w.WriteUint32(uint32(len(ids)))
+	w.WritePointer(len(ids) > 0)
+	w.WriteUint32(0)
+	if len(ids) == 0 {
+		return
+	}
+	w.Defer(func() {
+		w.WriteUint32(uint32(len(ids)))
+		for _, id := range ids {
+			var property interface{}
+			var errorID int32
+			description := ""
+			dataType := com.VT_EMPTY
+			switch id {
+			case 1:
+				property, description, dataType = int16(canonicalType(value)), "Item Canonical DataType", com.VT_I2
+			case 5:
+				property, description, dataType = int32(OPC_READABLE|OPC_WRITEABLE), "Item Access Rights", com.VT_I4
+			default:
+				errorID = int32(OPCInvalidPID)
+			}
+			if !returnValues {
+				property = nil
+			}
+			w.WriteUint16(uint16(dataType))
+			w.WriteUint16(0)
+			w.WriteUint32(id)
+			w.WritePointer(false)
+			w.WritePointer(true)
+			w.Defer(func() {
+				w.WriteString(description)
+			})
+			w.WritePointer(true)
+			w.Defer(func() {
+				_ = dcom.WriteVariant(w, property)
+			})
+			w.WriteInt32(errorID)
+			w.WriteUint32(0)
+		}
+	})
+}
+
+// readStandInItemIDs reads the dwCount and the [size_is(dwCount)] LPCWSTR* arguments of a call
+func readStandInItemIDs(r *ndr.Reader) []string {
+	n := int(r.ReadUint32())
+	r.ReadUint32()
+	itemIDs := make([]string, n)
+	for i := range itemIDs {
+		if r.ReadPointer() {
+			i := i
+			r.Defer(func() {
+				itemIDs[i] = r.ReadString()
+			})
+		}
+	}
+	r.Flush()
+	return itemIDs
+}
+
+func (s *standInOPC) browseCall(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+	switch opnum {
+	case 3:
+		itemIDs := readStandInItemIDs(r)
+		n := len(itemIDs)
+		returnValues := r.ReadInt32() != 0
+		r.ReadUint32()
+		ids := r.ReadUint32Array()
+		if len(ids) == 0 {
+			ids = []uint32{1, 5}
+		}
+		w.WritePointer(true)
+		w.WriteUint32(uint32(n))
+		for _, itemID := range itemIDs {
+			s.writeStandInProperties(w, itemID, ids, returnValues)
+		}
+		w.Flush()
+		return 0
+	case 4:
+		itemID := r.ReadString()
+		continuationPoint := r.ReadUniqueString()
+		maxElements := int(r.ReadUint32())
+		filter := r.ReadUint16()
+		r.ReadString()
+		r.ReadString()
+		returnAll := r.ReadInt32() != 0
+		returnValues := r.ReadInt32() != 0
+		r.ReadUint32()
+		ids := r.ReadUint32Array()
+		if returnAll {
+			ids = []uint32{1, 5}
+		}
+		var names []string
+		if itemID == "" && filter != uint16(OPC_BROWSE_FILTER_BRANCHES) {
+			s.lock.Lock()
+			for name := range s.values {
+				names = append(names, name)
+			}
+			s.lock.Unlock()
+			sort.Strings(names)
+		}
+		start, _ := strconv.Atoi(continuationPoint)
+		if start > len(names) {
+			return OPCInvalidContinuationPoint
+		}
+		names = names[start:]
+		next := ""
+		if maxElements > 0 && len(names) > maxElements {
+			names = names[:maxElements]
+			next = strconv.Itoa(start + maxElements)
+		}
+		w.WriteUniqueString(next, next == "")
+		w.WriteInt32(0)
+		w.WriteUint32(uint32(len(names)))
+		w.WritePointer(true)
+		w.WriteUint32(uint32(len(names)))
+		for _, name := range names {
+			name := name
+			w.WritePointer(true)
+			w.Defer(func() {
+				w.WriteString(name)
+			})
+			w.WritePointer(true)
+			w.Defer(func() {
+				w.WriteString(name)
+			})
+			w.WriteUint32(OPC_BROWSE_ISITEM)
+			w.WriteUint32(0)
+			s.writeStandInProperties(w, name, ids, returnValues)
+		}
+		w.Flush()
+		return 0
+	}
+	return uint32(dcom.ENotImpl)
+}
+
+// writeStandInItemValues writes the values, qualities and timestamps returned by the Read methods of OPC DA 3.0
+func writeStandInItemValues(w *ndr.Writer, values []interface{}) {
+	timestamp := dcom.TimeToFileTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	w.WritePointer(true)
+	w.WriteUint32(uint32(len(values)))
+	for _, value := range values {
+		value := value
+		w.WritePointer(true)
+		w.Defer(func() {
+			_ = dcom.WriteVariant(w, value)
+		})
+	}
+	w.Flush()
+	w.WritePointer(true)
+	w.WriteUint32(uint32(len(values)))
+	for range values {
+		w.WriteUint16(0xC0)
+	}
+	w.WritePointer(true)
+	w.WriteUint32(uint32(len(values)))
+	for range values {
+		w.WriteUint32(uint32(timestamp))
+		w.WriteUint32(uint32(timestamp >> 32))
+	}
+}
+
+// readStandInItemVQTs reads an OPCITEMVQT array, the quality of an item is good when it is not specified
+func readStandInItemVQTs(r *ndr.Reader) ([]interface{}, []uint16) {
+	n := r.ReadUint32()
+	values := make([]interface{}, n)
+	qualities := make([]uint16, n)
+	for i := range values {
+		if r.ReadPointer() {
+			i := i
+			r.Defer(func() {
+				values[i], _ = dcom.ReadVariant(r)
+			})
+		}
+		qualitySpecified := r.ReadInt32() != 0
+		qualities[i] = r.ReadUint16()
+		if !qualitySpecified {
+			qualities[i] = 0xC0
+		}
+		r.ReadUint16()
+		r.ReadInt32()
+		r.ReadUint32()
+		r.ReadUint32()
+		r.ReadUint32()
+	}
+	r.Flush()
+	return values, qualities
+}
+
+// readItems returns the values of items, the stand-in only knows good qualities
+func (s *standInOPC) readItems(itemIDs []string) ([]interface{}, []int32) {
+	values := make([]interface{}, len(itemIDs))
+	errors := make([]int32, len(itemIDs))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, itemID := range itemIDs {
+		value, ok := s.values[itemID]
+		if !ok {
+			errors[i] = int32(OPCUnknownItemID)
+			continue
+		}
+		values[i] = value
+	}
+	return values, errors
+}
+
+// writeItems stores the values of items, other qualities than good are rejected
+func (s *standInOPC) writeItems(itemIDs []string, values []interface{}, qualities []uint16) []int32 {
+	errors := make([]int32, len(itemIDs))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, itemID := range itemIDs {
+		current, ok := s.values[itemID]
+		switch {
+		case !ok:
+			errors[i] = int32(OPCUnknownItemID)
+		case qualities[i] != 0xC0:
+			errors[i] = int32(OPCBadType)
+		case canonicalType(values[i]) != canonicalType(current):
+			errors[i] = int32(OPCBadType)
+		default:
+			s.values[itemID] = values[i]
+		}
+	}
+	return errors
+}
+
+func (s *standInOPC) itemIOCall(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+	switch opnum {
+	case 3:
+		itemIDs := readStandInItemIDs(r)
+		r.ReadUint32Array()
+		values, errors := s.readItems(itemIDs)
+		writeStandInItemValues(w, values)
+		writeStandInErrors(w, errors)
+		return 0
+	case 4:
+		itemIDs := readStandInItemIDs(r)
+		values, qualities := readStandInItemVQTs(r)
+		writeStandInErrors(w, s.writeItems(itemIDs, values, qualities))
+		return 0
+	}
+	return uint32(dcom.ENotImpl)
+}
+
+// syncIO2Call implements the methods of IOPCSyncIO2 on top of IOPCSyncIO, an unknown handle reports an unknown item
+func (s *standInOPC) syncIO2Call(group *standInGroup) dcomtest.Handler {
+	itemIDs := func(handles []uint32) []string {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		ids := make([]string, len(handles))
+		for i, handle := range handles {
+			ids[i] = group.items[handle]
+		}
+		return ids
+	}
+	syncIO := s.syncIOCall(group)
+	return func(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+		switch opnum {
+		case 5:
+			r.ReadUint32()
+			handles := r.ReadUint32Array()
+			r.ReadUint32Array()
+			values, errors := s.readItems(itemIDs(handles))
+			writeStandInItemValues(w, values)
+			writeStandInErrors(w, errors)
+			return 0
+		case 6:
+			r.ReadUint32()
+			handles := r.ReadUint32Array()
+			values, qualities := readStandInItemVQTs(r)
+			writeStandInErrors(w, s.writeItems(itemIDs(handles), values, qualities))
+			return 0
+		}
+		return syncIO(opnum, r, w)
+	}
+}
+
+func (s *standInOPC) itemDeadbandMgtCall(group *standInGroup) dcomtest.Handler {
+	return func(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+		r.ReadUint32()
+		handles := r.ReadUint32Array()
+		errors := make([]int32, len(handles))
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		switch opnum {
+		case 3:
+			n := r.ReadUint32()
+			for i := uint32(0); i < n; i++ {
+				deadband := r.ReadFloat32()
+				if _, ok := group.items[handles[i]]; !ok {
+					errors[i] = int32(OPCInvalidHandle)
+					continue
+				}
+				group.deadbands[handles[i]] = deadband
+			}
+		case 4:
+			w.WritePointer(true)
+			w.WriteUint32(uint32(len(handles)))
+			for i, handle := range handles {
+				deadband, ok := group.deadbands[handle]
+				if !ok {
+					errors[i] = int32(OPCDeadbandNotSet)
+				}
+				w.WriteFloat32(deadband)
+			}
+		case 5:
+			for i, handle := range handles {
+				if _, ok := group.deadbands[handle]; !ok {
+					errors[i] = int32(OPCDeadbandNotSet)
+					continue
+				}
+				delete(group.deadbands, handle)
+			}
+		default:
+			return uint32(dcom.ENotImpl)
+		}
+		writeStandInErrors(w, errors)
+		return 0
+	}
+}
+
+func (s *standInOPC) itemSamplingMgtCall(group *standInGroup) dcomtest.Handler {
+	return func(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+		r.ReadUint32()
+		handles := r.ReadUint32Array()
+		errors := make([]int32, len(handles))
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		switch opnum {
+		case 3:
+			rates := r.ReadUint32Array()
+			w.WritePointer(true)
+			w.WriteUint32(uint32(len(handles)))
+			for i, handle := range handles {
+				rate := rates[i]
+				if rate < 50 {
+					rate = 50
+					errors[i] = int32(OPCUnsupportedRate)
+				}
+				group.rates[handle] = rate
+				w.WriteUint32(rate)
+			}
+		case 4, 5:
+			if opnum == 4 {
+				w.WritePointer(true)
+				w.WriteUint32(uint32(len(handles)))
+			}
+			for i, handle := range handles {
+				rate, ok := group.rates[handle]
+				if !ok {
+					errors[i] = int32(OPCRateNotSet)
+				}
+				if opnum == 4 {
+					w.WriteUint32(rate)
+				} else {
+					delete(group.rates, handle)
+				}
+			}
+		case 6:
+			n := r.ReadUint32()
+			for i := uint32(0); i < n; i++ {
+				group.buffered[handles[i]] = r.ReadInt32() != 0
+			}
+		case 7:
+			w.WritePointer(true)
+			w.WriteUint32(uint32(len(handles)))
+			for _, handle := range handles {
+				w.WriteInt32(com.BoolToComBOOL(group.buffered[handle]))
+			}
+		default:
+			return uint32(dcom.ENotImpl)
+		}
+		writeStandInErrors(w, errors)
+		return 0
+	}
+}
+
+func (s *standInOPC) groupStateCall(group *standInGroup) dcomtest.Handler {
+	return func(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		switch opnum {
+		case 3:
+			w.WriteUint32(group.updateRate)
+			w.WriteInt32(com.BoolToComBOOL(group.active))
+			w.WritePointer(true)
+			w.WriteString(group.name)
+			w.WriteInt32(0)
+			w.WriteFloat32(0)
+			w.WriteUint32(0x409)
+			w.WriteUint32(group.clientHandle)
+			w.WriteUint32(group.serverHandle)
+			return 0
+		case 4:
+			if r.ReadPointer() {
+				group.updateRate = r.ReadUint32()
+			}
+			if r.ReadPointer() {
+				group.active = r.ReadInt32() != 0
+			}
+			if r.ReadPointer() {
+				r.ReadInt32()
+			}
+			if r.ReadPointer() {
+				r.ReadFloat32()
+			}
+			if r.ReadPointer() {
+				r.ReadUint32()
+			}
+			if r.ReadPointer() {
+				group.clientHandle = r.ReadUint32()
+			}
+			w.WriteUint32(group.updateRate)
+			return 0
+		case 5:
+			group.name = r.ReadString()
+			return 0
+		case 7:
+			group.keepAlive = r.ReadUint32()
+			if group.keepAlive != 0 && group.keepAlive < 1000 {
+				group.keepAlive = 1000
+			}
+			w.WriteUint32(group.keepAlive)
+			return 0
+		case 8:
+			w.WriteUint32(group.keepAlive)
+			return 0
+		}
+		return uint32(dcom.ENotImpl)
+	}
+}
+
+func (s *standInOPC) syncIOCall(group *standInGroup) dcomtest.Handler {
+	return func(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+		switch opnum {
+		case 3:
+			r.ReadUint16()
+			r.ReadUint32()
+			handles := r.ReadUint32Array()
+			timestamp := dcom.TimeToFileTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+			errors := make([]int32, len(handles))
+			s.lock.Lock()
+			w.WritePointer(true)
+			w.WriteUint32(uint32(len(handles)))
+			for i, handle := range handles {
+				var value interface{}
+				itemID, ok := group.items[handle]
+				if ok {
+					value = s.values[itemID]
+				} else {
+					errors[i] = int32(OPCInvalidHandle)
+				}
+				w.WriteUint32(handle + 100)
+				w.WriteUint32(uint32(timestamp))
+				w.WriteUint32(uint32(timestamp >> 32))
+				w.WriteUint16(0xC0)
+				w.WriteUint16(0)
+				w.WritePointer(true)
+				w.Defer(func() {
+					_ = dcom.WriteVariant(w, value)
+				})
+			}
+			s.lock.Unlock()
+			w.Flush()
+			writeStandInErrors(w, errors)
+			return 0
+		case 4:
+			r.ReadUint32()
+			handles := r.ReadUint32Array()
+			n := r.ReadCount(4)
+			values := make([]interface{}, n)
+			for i := range values {
+				if r.ReadPointer() {
+					i := i
+					r.Defer(func() {
+						values[i], _ = dcom.ReadVariant(r)
+					})
+				}
+			}
+			r.Flush()
+			errors := make([]int32, len(handles))
+			s.lock.Lock()
+			for i, handle := range handles {
+				itemID, ok := group.items[handle]
+				switch {
+				case !ok:
+					errors[i] = int32(OPCInvalidHandle)
+				case canonicalType(values[i]) != canonicalType(s.values[itemID]):
+					errors[i] = int32(OPCBadType)
+				default:
+					s.values[itemID] = values[i]
+				}
+			}
+			s.lock.Unlock()
+			writeStandInErrors(w, errors)
+			return 0
+		}
+		return uint32(dcom.ENotImpl)
+	}
+}
+
+func (s *standInOPC) itemMgtCall(group *standInGroup) dcomtest.Handler {
+	return func(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+		switch opnum {
+		case 3:
+			r.ReadUint32()
+			n := r.ReadCount(28)
+			itemIDs := make([]string, n)
+			for i := 0; i < n; i++ {
+				i := i
+				if r.ReadPointer() {
+					r.Defer(func() {
+						r.ReadString()
+					})
+				}
+				if r.ReadPointer() {
+					r.Defer(func() {
+						itemIDs[i] = r.ReadString()
+					})
+				}
+				r.ReadInt32()
+				r.ReadUint32()
+				r.ReadUint32()
+				if r.ReadPointer() {
+					r.Defer(func() {
+						r.ReadBytes(r.ReadCount(1))
+					})
+				}
+				r.ReadUint16()
+				r.ReadUint16()
+			}
+			r.Flush()
+			errors := make([]int32, n)
+			s.lock.Lock()
+			w.WritePointer(true)
+			w.WriteUint32(uint32(n))
+			for i, itemID := range itemIDs {
+				value, ok := s.values[itemID]
+				var handle uint32
+				if ok {
+					group.nextItem++
+					handle = group.nextItem
+					group.items[handle] = itemID
+				} else {
+					errors[i] = int32(OPCUnknownItemID)
+				}
+				w.WriteUint32(handle)
+				w.WriteUint16(uint16(canonicalType(value)))
+				w.WriteUint16(0)
+				w.WriteUint32(OPC_READABLE | OPC_WRITEABLE)
+				w.WriteUint32(0)
+				w.WritePointer(false)
+			}
+			s.lock.Unlock()
+			writeStandInErrors(w, errors)
+			return 0
+		case 5:
+			r.ReadUint32()
+			handles := r.ReadUint32Array()
+			errors := make([]int32, len(handles))
+			s.lock.Lock()
+			for i, handle := range handles {
+				if _, ok := group.items[handle]; !ok {
+					errors[i] = int32(OPCInvalidHandle)
+				}
+				delete(group.items, handle)
+			}
+			s.lock.Unlock()
+			writeStandInErrors(w, errors)
+			return 0
+		}
+		return uint32(dcom.ENotImpl)
+	}
+}
+
+func TestDCOMTransport(t *testing.T) {
+	standIn := newStandInOPC(t)
+	server, err := Connect(standInCLSID, standIn.Host(), WithTransport(DCOMTransport(standIn.Config())))
+	require.NoError(t, err)
+
+	vendor, err := server.GetVendorInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "opcda stand-in", vendor)
+	state, err := server.GetServerState()
+	require.NoError(t, err)
+	assert.Equal(t, com.OPCServerState(1), state)
+	startTime, err := server.GetStartTime()
+	require.NoError(t, err)
+	assert.True(t, startTime.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+	build, err := server.GetBuildNumber()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(3), build)
+
+	localeID, err := server.GetLocaleID()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x409), localeID)
+	localeIDs, err := server.QueryAvailableLocaleIDs()
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{0x409, 0x804}, localeIDs)
+	require.NoError(t, server.SetClientName("dcom test"))
+	assert.Equal(t, "dcom test", standIn.clientName)
+
+	ids, descriptions, types, err := server.QueryAvailableProperties("Int")
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{1, 5}, ids)
+	assert.Equal(t, []string{"Item Canonical DataType", "Item Access Rights"}, descriptions)
+	assert.Equal(t, []uint16{uint16(com.VT_I2), uint16(com.VT_I4)}, types)
+	properties, propertyErrors, err := server.GetItemProperties("Float", []uint32{1, 5, 7})
+	require.NoError(t, err)
+	assert.Equal(t, int16(com.VT_R8), properties[0])
+	assert.Equal(t, int32(OPC_READABLE|OPC_WRITEABLE), properties[1])
+	assert.Nil(t, propertyErrors[0])
+	assert.Equal(t, &OPCError{ErrorCode: int32(OPCInvalidPID), ErrorMessage: "stand-in error 0xC0040203"}, propertyErrors[2])
+
+	group, err := server.GetOPCGroups().Add("group1")
+	require.NoError(t, err)
+	updateRate, err := group.GetUpdateRate()
+	require.NoError(t, err)
+	assert.Equal(t, server.GetOPCGroups().GetDefaultGroupUpdateRate(), updateRate)
+	require.NoError(t, group.SetUpdateRate(500))
+	updateRate, err = group.GetUpdateRate()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(500), updateRate)
+
+	items, errs, err := group.OPCItems().AddItems([]string{"Int", "Float", "Text", "Missing"})
+	require.NoError(t, err)
+	require.Len(t, items, 4)
+	assert.Nil(t, errs[0])
+	assert.Equal(t, com.VT_R8, items[1].GetCanonicalDataType())
+	assert.Nil(t, items[3])
+	assert.Equal(t, &OPCError{ErrorCode: int32(OPCUnknownItemID), ErrorMessage: "stand-in error 0xC0040007"}, errs[3])
+
+	value, quality, timestamp, err := items[0].Read(OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, int32(7), value)
+	assert.Equal(t, com.QualityGood, quality)
+	assert.True(t, timestamp.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	require.NoError(t, items[0].Write(int32(42)))
+	value, _, _, err = items[0].Read(OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, int32(42), value)
+	assert.Error(t, items[0].Write("not an int"))
+
+	writeErrors, err := group.SyncWrite([]uint32{items[1].GetServerHandle(), items[2].GetServerHandle()}, []interface{}{2.5, "world"})
+	require.NoError(t, err)
+	assert.Equal(t, []error{nil, nil}, writeErrors)
+	states, readErrors, err := group.SyncRead(OPC_DS_CACHE, []uint32{items[1].GetServerHandle(), items[2].GetServerHandle()})
+	require.NoError(t, err)
+	assert.Equal(t, []error{nil, nil}, readErrors)
+	assert.Equal(t, 2.5, states[0].Value)
+	assert.Equal(t, "world", states[1].Value)
+
+	assert.ErrorIs(t, group.RegisterDataChange(make(chan *DataChangeCallBackData)), ErrCallbackNotSupported)
+	assert.ErrorIs(t, server.RegisterServerShutDown(make(chan string)), ErrCallbackNotSupported)
+
+	group.OPCItems().Remove([]uint32{items[2].GetServerHandle()})
+	assert.Equal(t, 2, group.OPCItems().GetCount())
+	require.NoError(t, server.GetOPCGroups().Remove(group.GetServerHandle()))
+	assert.Equal(t, 0, server.GetOPCGroups().GetCount())
+
+	require.NoError(t, server.Disconnect())
+	assert.Equal(t, 0, standIn.References())
+}
+
+func TestDCOMTransportBrowse(t *testing.T) {
+	standIn := newStandInOPC(t)
+	server, err := Connect(standInCLSID, standIn.Host(), WithTransport(DCOMTransport(standIn.Config())))
+	require.NoError(t, err)
+	defer server.Disconnect()
+
+	result, err := server.Browse("", nil, 3, true)
+	require.NoError(t, err)
+	assert.Equal(t, "3", result.ContinuationPoint)
+	require.Len(t, result.Elements, 3)
+	element := result.Elements[0]
+	assert.Equal(t, "Bool", element.Name)
+	assert.Equal(t, "Bool", element.ItemID)
+	assert.True(t, element.IsItem)
+	assert.False(t, element.HasChildren)
+	require.NoError(t, element.Err)
+	require.Len(t, element.Properties, 2)
+	assert.Equal(t, &ItemProperty{PropertyID: 1, Description: "Item Canonical DataType", DataType: uint16(com.VT_I2), Value: int16(com.VT_BOOL)}, element.Properties[0])
+	assert.Equal(t, int32(OPC_READABLE|OPC_WRITEABLE), element.Properties[1].Value)
+	assert.Equal(t, "Float", result.Elements[1].Name)
+	assert.Equal(t, int16(com.VT_I4), result.Elements[2].Properties[0].Value)
+	result, err = result.Next()
+	require.NoError(t, err)
+	require.Len(t, result.Elements, 1)
+	assert.Equal(t, "Text", result.Elements[0].Name)
+	assert.Empty(t, result.ContinuationPoint)
+
+	result, err = server.Browse("", &BrowseFilter{Type: OPC_BROWSE_FILTER_BRANCHES}, 0, false)
+	require.NoError(t, err)
+	assert.Empty(t, result.Elements)
+
+	properties, errs, err := server.GetProperties([]string{"Text", "Missing"}, []uint32{5, 7})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	require.Len(t, properties[0], 2)
+	assert.Equal(t, int32(OPC_READABLE|OPC_WRITEABLE), properties[0][0].Value)
+	assert.Equal(t, &OPCError{ErrorCode: int32(OPCInvalidPID), ErrorMessage: "stand-in error 0xC0040203"}, properties[0][1].Err)
+	assert.Nil(t, properties[1])
+	assert.Equal(t, &OPCError{ErrorCode: int32(OPCUnknownItemID), ErrorMessage: "stand-in error 0xC0040007"}, errs[1])
+}
+
+func TestDCOMTransportItemIO(t *testing.T) {
+	standIn := newStandInOPC(t)
+	server, err := Connect(standInCLSID, standIn.Host(), WithTransport(DCOMTransport(standIn.Config())))
+	require.NoError(t, err)
+	defer server.Disconnect()
+
+	states, errs, err := server.ReadItems([]string{"Int", "Text", "Missing"}, []uint32{0, 1000, 0})
+	require.NoError(t, err)
+	require.Len(t, states, 3)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, int32(7), states[0].Value)
+	assert.Equal(t, com.QualityGood, states[0].Quality)
+	assert.True(t, states[0].Timestamp.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+	assert.Equal(t, "hello", states[1].Value)
+	assert.Nil(t, states[2])
+	assert.Equal(t, &OPCError{ErrorCode: int32(OPCUnknownItemID), ErrorMessage: "stand-in error 0xC0040007"}, errs[2])
+
+	errs, err = server.WriteItemsVQT(
+		[]string{"Int", "Float", "Text"},
+		[]interface{}{int32(9), "wrong", "bye"},
+		[]com.Quality{com.QualityGood, com.QualityGood, com.QualityOutOfService},
+		[]time.Time{time.Now(), {}, {}},
+	)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+	assert.Error(t, errs[2])
+	states, _, err = server.ReadItems([]string{"Int", "Text"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(9), states[0].Value)
+	assert.Equal(t, "hello", states[1].Value)
+}
+
+func TestDCOMTransportSyncIO2(t *testing.T) {
+	standIn := newStandInOPC(t)
+	server, err := Connect(standInCLSID, standIn.Host(), WithTransport(DCOMTransport(standIn.Config())))
+	require.NoError(t, err)
+	defer server.Disconnect()
+	group, err := server.GetOPCGroups().Add("group")
+	require.NoError(t, err)
+	assert.True(t, group.SupportsSyncIO2())
+	// the asynchronous interfaces report their results through the data callback
+	assert.False(t, group.SupportsAsyncIO3())
+	items, _, err := group.OPCItems().AddItems([]string{"Int", "Text"})
+	require.NoError(t, err)
+	handles := []uint32{items[0].GetServerHandle(), items[1].GetServerHandle()}
+
+	states, errs, err := group.SyncReadMaxAge(handles, []uint32{0, 0xFFFFFFFF})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, int32(7), states[0].Value)
+	assert.Equal(t, "hello", states[1].Value)
+	assert.True(t, states[1].Timestamp.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	errs, err = group.SyncWriteVQT(handles, []interface{}{int32(11), "bye"}, []com.Quality{com.QualityGood, com.QualityOutOfService}, nil)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+	states, _, err = group.SyncReadMaxAge(handles, []uint32{0, 0})
+	require.NoError(t, err)
+	assert.Equal(t, int32(11), states[0].Value)
+	assert.Equal(t, "hello", states[1].Value)
+
+	_, _, err = group.AsyncReadMaxAge(handles, []uint32{0, 0}, 1)
+	assert.ErrorIs(t, err, ErrCallbackNotSupported)
+	_, _, err = group.AsyncRead(handles, 1)
+	assert.ErrorIs(t, err, ErrCallbackNotSupported)
+	_, err = group.AsyncRefresh(OPC_DS_CACHE, 1)
+	assert.ErrorIs(t, err, ErrCallbackNotSupported)
+	assert.ErrorIs(t, group.AsyncCancel(1), ErrCallbackNotSupported)
+}
+
+func TestDCOMTransportItemDeadband(t *testing.T) {
+	standIn := newStandInOPC(t)
+	server, err := Connect(standInCLSID, standIn.Host(), WithTransport(DCOMTransport(standIn.Config())))
+	require.NoError(t, err)
+	defer server.Disconnect()
+	group, err := server.GetOPCGroups().Add("group")
+	require.NoError(t, err)
+	assert.True(t, group.SupportsItemDeadband())
+	item, err := group.OPCItems().AddItem("Float")
+	require.NoError(t, err)
+
+	_, err = item.GetDeadband()
+	assert.Equal(t, &OPCError{ErrorCode: int32(OPCDeadbandNotSet), ErrorMessage: "stand-in error 0xC0040400"}, err)
+	require.NoError(t, item.SetDeadband(2.5))
+	deadband, err := item.GetDeadband()
+	require.NoError(t, err)
+	assert.Equal(t, float32(2.5), deadband)
+	require.NoError(t, item.ClearDeadband())
+	assert.Error(t, item.ClearDeadband())
+}
+
+func TestDCOMTransportItemSampling(t *testing.T) {
+	standIn := newStandInOPC(t)
+	server, err := Connect(standInCLSID, standIn.Host(), WithTransport(DCOMTransport(standIn.Config())))
+	require.NoError(t, err)
+	defer server.Disconnect()
+	group, err := server.GetOPCGroups().Add("group")
+	require.NoError(t, err)
+	assert.True(t, group.SupportsItemSampling())
+	item, err := group.OPCItems().AddItem("Float")
+	require.NoError(t, err)
+
+	_, err = item.GetSamplingRate()
+	assert.Equal(t, &OPCError{ErrorCode: int32(OPCRateNotSet), ErrorMessage: "stand-in error 0xC0040402"}, err)
+	rate, err := item.SetSamplingRate(20)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(50), rate)
+	rate, err = item.GetSamplingRate()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(50), rate)
+	require.NoError(t, item.ClearSamplingRate())
+	assert.Error(t, item.ClearSamplingRate())
+
+	require.NoError(t, item.SetBufferEnable(true))
+	enable, err := item.GetBufferEnable()
+	require.NoError(t, err)
+	assert.True(t, enable)
+}
+
+func TestDCOMTransportKeepAlive(t *testing.T) {
+	standIn := newStandInOPC(t)
+	server, err := Connect(standInCLSID, standIn.Host(), WithTransport(DCOMTransport(standIn.Config())))
+	require.NoError(t, err)
+	defer server.Disconnect()
+	group, err := server.GetOPCGroups().Add("group")
+	require.NoError(t, err)
+	assert.True(t, group.SupportsKeepAlive())
+
+	revised, err := group.SetKeepAlive(100)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1000), revised)
+	keepAlive, err := group.GetKeepAlive()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1000), keepAlive)
+	// the watchdog needs the data callback
+	_, err = group.WatchStall(context.Background(), 0)
+	assert.ErrorIs(t, err, ErrCallbackNotSupported)
+}
+
+func TestDCOMTransportUnknownClass(t *testing.T) {
+	standIn := newStandInOPC(t)
+	_, err := Connect("{00000000-0000-0000-0000-000000000001}", standIn.Host(), WithTransport(DCOMTransport(standIn.Config())))
+	assert.ErrorIs(t, err, dcom.EClassNotReg)
+}
+
+func TestDCOMTransportCredentials(t *testing.T) {
+	standIn := newStandInOPC(t)
+	standIn.RequireCredentials(&ntlm.Credentials{Domain: "WORKGROUP", User: "opc", Password: "secret"})
+	transport := WithTransport(DCOMTransport(standIn.Config()))
+
+	for _, opts := range [][]ConnectOption{
+		{transport, WithCredentials(&Credentials{Domain: "WORKGROUP", User: "opc", Password: "wrong"})},
+		{transport},
+	} {
+		_, err := Connect(standInCLSID, standIn.Host(), opts...)
+		var fault *rpc.FaultError
+		require.ErrorAs(t, err, &fault)
+		assert.Equal(t, uint32(rpc.FaultAccessDenied), fault.Status)
+	}
+
+	server, err := Connect(standInCLSID, standIn.Host(), transport, WithCredentials(&Credentials{Domain: "WORKGROUP", User: "opc", Password: "secret"}))
+	require.NoError(t, err)
+	group, err := server.GetOPCGroups().Add("group")
+	require.NoError(t, err)
+	items, _, err := group.OPCItems().AddItems([]string{"Text"})
+	require.NoError(t, err)
+	value, _, _, err := items[0].Read(OPC_DS_CACHE)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", value)
+	require.NoError(t, server.Disconnect())
+}
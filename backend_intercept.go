@@ -0,0 +1,805 @@
+package opcda
+
+import (
+	"github.com/huskar-t/opcda/com"
+)
+
+// TransportFunc adapts a function to a Transport, which makes mock transports a one-liner
+type TransportFunc func(progID, node string, credentials *Credentials) (ServerBackend, error)
+
+// Connect calls f
+func (f TransportFunc) Connect(progID, node string, credentials *Credentials) (ServerBackend, error) {
+	return f(progID, node, credentials)
+}
+
+// Interceptor is called for every backend call made through a transport returned by InterceptTransport.
+// method names the interface and the method, such as "IOPCSyncIO.Read". invoke performs the call and returns its error,
+// it may be called more than once to retry, the results of the last invocation are returned to the caller.
+// Release and the callbacks from the server are not intercepted.
+type Interceptor func(method string, invoke func() error) error
+
+// InterceptTransport decorates transport so that Connect and every call on the backends it creates go through interceptor.
+// Decorators for logging, retries or metrics are interceptors.
+func InterceptTransport(transport Transport, interceptor Interceptor) Transport {
+	return interceptTransport(transport, interceptor, nil)
+}
+
+// executorNeeder is implemented by the transports whose calls need the COM runtime initialized on the calling thread,
+// Connect starts an executor for them. The decorators of this package forward it.
+type executorNeeder interface {
+	needsExecutor() bool
+}
+
+// needsExecutor reports whether the calls of transport need the COM runtime initialized on the calling thread
+func needsExecutor(transport Transport) bool {
+	needer, ok := transport.(executorNeeder)
+	return ok && needer.needsExecutor()
+}
+
+// decoratedTransport is a TransportFunc decorating inner, it forwards executorNeeder to inner
+type decoratedTransport struct {
+	TransportFunc
+	inner Transport
+}
+
+func (t decoratedTransport) needsExecutor() bool {
+	return needsExecutor(t.inner)
+}
+
+// interceptTransport decorates transport like InterceptTransport, Release goes through release when it is not nil
+func interceptTransport(transport Transport, interceptor Interceptor, release Interceptor) Transport {
+	return decoratedTransport{inner: transport, TransportFunc: func(progID, node string, credentials *Credentials) (ServerBackend, error) {
+		var server ServerBackend
+		err := interceptor("Transport.Connect", func() (err error) {
+			server, err = transport.Connect(progID, node, credentials)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &interceptedServer{ServerBackend: server, intercept: interceptor, release: release}, nil
+	}}
+}
+
+// executorTransport decorates transport so that Connect and every call on the backends it creates, Release included,
+// run on the threads of executor. The callbacks into an STA are queued, its thread must not wait for their receivers.
+func executorTransport(transport Transport, executor *com.Executor) Transport {
+	if executor.GetApartment() == com.SingleThreaded {
+		transport = queueCallbacks(transport)
+	}
+	interceptor := func(method string, invoke func() error) error {
+		return executor.Do(invoke)
+	}
+	return interceptTransport(transport, interceptor, interceptor)
+}
+
+// interceptRelease calls release through interceptor, or directly when interceptor is nil
+func interceptRelease(interceptor Interceptor, method string, release func()) {
+	if interceptor == nil {
+		release()
+		return
+	}
+	_ = interceptor(method, func() error {
+		release()
+		return nil
+	})
+}
+
+type interceptedServer struct {
+	ServerBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (s *interceptedServer) Release() {
+	interceptRelease(s.release, "IOPCServer.Release", s.ServerBackend.Release)
+}
+
+func (s *interceptedServer) AddGroup(name string, active bool, requestedUpdateRate uint32, clientGroup uint32, timeBias *int32, percentDeadband *float32, localeID uint32) (serverGroup uint32, revisedUpdateRate uint32, group GroupBackend, err error) {
+	err = s.intercept("IOPCServer.AddGroup", func() (err error) {
+		serverGroup, revisedUpdateRate, group, err = s.ServerBackend.AddGroup(name, active, requestedUpdateRate, clientGroup, timeBias, percentDeadband, localeID)
+		return err
+	})
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return serverGroup, revisedUpdateRate, &interceptedGroup{GroupBackend: group, intercept: s.intercept, release: s.release}, nil
+}
+
+func (s *interceptedServer) GetStatus() (status *com.ServerStatus, err error) {
+	err = s.intercept("IOPCServer.GetStatus", func() (err error) {
+		status, err = s.ServerBackend.GetStatus()
+		return err
+	})
+	return
+}
+
+func (s *interceptedServer) RemoveGroup(serverGroup uint32, force bool) error {
+	return s.intercept("IOPCServer.RemoveGroup", func() error {
+		return s.ServerBackend.RemoveGroup(serverGroup, force)
+	})
+}
+
+func (s *interceptedServer) QueryCommon() (CommonBackend, error) {
+	var common CommonBackend
+	err := s.intercept("IOPCServer.QueryCommon", func() (err error) {
+		common, err = s.ServerBackend.QueryCommon()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedCommon{CommonBackend: common, intercept: s.intercept, release: s.release}, nil
+}
+
+func (s *interceptedServer) QueryItemProperties() (ItemPropertiesBackend, error) {
+	var properties ItemPropertiesBackend
+	err := s.intercept("IOPCServer.QueryItemProperties", func() (err error) {
+		properties, err = s.ServerBackend.QueryItemProperties()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedItemProperties{ItemPropertiesBackend: properties, intercept: s.intercept, release: s.release}, nil
+}
+
+func (s *interceptedServer) QueryBrowseServerAddressSpace() (BrowseServerAddressSpaceBackend, error) {
+	var browse BrowseServerAddressSpaceBackend
+	err := s.intercept("IOPCServer.QueryBrowseServerAddressSpace", func() (err error) {
+		browse, err = s.ServerBackend.QueryBrowseServerAddressSpace()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedBrowseServerAddressSpace{BrowseServerAddressSpaceBackend: browse, intercept: s.intercept, release: s.release}, nil
+}
+
+func (s *interceptedServer) QueryBrowse() (BrowseBackend, error) {
+	var browse BrowseBackend
+	err := s.intercept("IOPCServer.QueryBrowse", func() (err error) {
+		browse, err = s.ServerBackend.QueryBrowse()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedBrowse{BrowseBackend: browse, intercept: s.intercept, release: s.release}, nil
+}
+
+func (s *interceptedServer) QueryItemIO() (ItemIOBackend, error) {
+	var itemIO ItemIOBackend
+	err := s.intercept("IOPCServer.QueryItemIO", func() (err error) {
+		itemIO, err = s.ServerBackend.QueryItemIO()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedItemIO{ItemIOBackend: itemIO, intercept: s.intercept, release: s.release}, nil
+}
+
+func (s *interceptedServer) AdviseShutdown(onShutdown func(reason string)) (cookie uint32, err error) {
+	err = s.intercept("IOPCServer.AdviseShutdown", func() (err error) {
+		cookie, err = s.ServerBackend.AdviseShutdown(onShutdown)
+		return err
+	})
+	return
+}
+
+func (s *interceptedServer) UnadviseShutdown(cookie uint32) error {
+	return s.intercept("IOPCServer.UnadviseShutdown", func() error {
+		return s.ServerBackend.UnadviseShutdown(cookie)
+	})
+}
+
+type interceptedCommon struct {
+	CommonBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (c *interceptedCommon) Release() {
+	interceptRelease(c.release, "IOPCCommon.Release", c.CommonBackend.Release)
+}
+
+func (c *interceptedCommon) SetLocaleID(localeID uint32) error {
+	return c.intercept("IOPCCommon.SetLocaleID", func() error {
+		return c.CommonBackend.SetLocaleID(localeID)
+	})
+}
+
+func (c *interceptedCommon) GetLocaleID() (localeID uint32, err error) {
+	err = c.intercept("IOPCCommon.GetLocaleID", func() (err error) {
+		localeID, err = c.CommonBackend.GetLocaleID()
+		return err
+	})
+	return
+}
+
+func (c *interceptedCommon) QueryAvailableLocaleIDs() (localeIDs []uint32, err error) {
+	err = c.intercept("IOPCCommon.QueryAvailableLocaleIDs", func() (err error) {
+		localeIDs, err = c.CommonBackend.QueryAvailableLocaleIDs()
+		return err
+	})
+	return
+}
+
+func (c *interceptedCommon) GetErrorString(errorCode uint32) (message string, err error) {
+	err = c.intercept("IOPCCommon.GetErrorString", func() (err error) {
+		message, err = c.CommonBackend.GetErrorString(errorCode)
+		return err
+	})
+	return
+}
+
+func (c *interceptedCommon) SetClientName(name string) error {
+	return c.intercept("IOPCCommon.SetClientName", func() error {
+		return c.CommonBackend.SetClientName(name)
+	})
+}
+
+type interceptedItemProperties struct {
+	ItemPropertiesBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (p *interceptedItemProperties) Release() {
+	interceptRelease(p.release, "IOPCItemProperties.Release", p.ItemPropertiesBackend.Release)
+}
+
+func (p *interceptedItemProperties) QueryAvailableProperties(itemID string) (propertyIDs []uint32, descriptions []string, dataTypes []uint16, err error) {
+	err = p.intercept("IOPCItemProperties.QueryAvailableProperties", func() (err error) {
+		propertyIDs, descriptions, dataTypes, err = p.ItemPropertiesBackend.QueryAvailableProperties(itemID)
+		return err
+	})
+	return
+}
+
+func (p *interceptedItemProperties) GetItemProperties(itemID string, propertyIDs []uint32) (data []interface{}, errors []int32, err error) {
+	err = p.intercept("IOPCItemProperties.GetItemProperties", func() (err error) {
+		data, errors, err = p.ItemPropertiesBackend.GetItemProperties(itemID, propertyIDs)
+		return err
+	})
+	return
+}
+
+func (p *interceptedItemProperties) LookupItemIDs(itemID string, propertyIDs []uint32) (itemIDs []string, errors []int32, err error) {
+	err = p.intercept("IOPCItemProperties.LookupItemIDs", func() (err error) {
+		itemIDs, errors, err = p.ItemPropertiesBackend.LookupItemIDs(itemID, propertyIDs)
+		return err
+	})
+	return
+}
+
+type interceptedBrowseServerAddressSpace struct {
+	BrowseServerAddressSpaceBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (b *interceptedBrowseServerAddressSpace) Release() {
+	interceptRelease(b.release, "IOPCBrowseServerAddressSpace.Release", b.BrowseServerAddressSpaceBackend.Release)
+}
+
+func (b *interceptedBrowseServerAddressSpace) QueryOrganization() (organization com.OPCNAMESPACETYPE, err error) {
+	err = b.intercept("IOPCBrowseServerAddressSpace.QueryOrganization", func() (err error) {
+		organization, err = b.BrowseServerAddressSpaceBackend.QueryOrganization()
+		return err
+	})
+	return
+}
+
+func (b *interceptedBrowseServerAddressSpace) ChangeBrowsePosition(direction com.OPCBROWSEDIRECTION, name string) error {
+	return b.intercept("IOPCBrowseServerAddressSpace.ChangeBrowsePosition", func() error {
+		return b.BrowseServerAddressSpaceBackend.ChangeBrowsePosition(direction, name)
+	})
+}
+
+func (b *interceptedBrowseServerAddressSpace) BrowseOPCItemIDs(browseType com.OPCBROWSETYPE, filter string, dataType uint16, accessRights uint32) (names []string, err error) {
+	err = b.intercept("IOPCBrowseServerAddressSpace.BrowseOPCItemIDs", func() (err error) {
+		names, err = b.BrowseServerAddressSpaceBackend.BrowseOPCItemIDs(browseType, filter, dataType, accessRights)
+		return err
+	})
+	return
+}
+
+func (b *interceptedBrowseServerAddressSpace) GetItemID(itemDataID string) (itemID string, err error) {
+	err = b.intercept("IOPCBrowseServerAddressSpace.GetItemID", func() (err error) {
+		itemID, err = b.BrowseServerAddressSpaceBackend.GetItemID(itemDataID)
+		return err
+	})
+	return
+}
+
+type interceptedBrowse struct {
+	BrowseBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (b *interceptedBrowse) Release() {
+	interceptRelease(b.release, "IOPCBrowse.Release", b.BrowseBackend.Release)
+}
+
+func (b *interceptedBrowse) GetProperties(itemIDs []string, returnPropertyValues bool, propertyIDs []uint32) (properties []*com.ItemProperties, err error) {
+	err = b.intercept("IOPCBrowse.GetProperties", func() (err error) {
+		properties, err = b.BrowseBackend.GetProperties(itemIDs, returnPropertyValues, propertyIDs)
+		return err
+	})
+	return
+}
+
+func (b *interceptedBrowse) Browse(
+	itemID string,
+	continuationPoint string,
+	maxElements uint32,
+	browseFilter com.OPCBROWSEFILTER,
+	elementNameFilter string,
+	vendorFilter string,
+	returnAllProperties bool,
+	returnPropertyValues bool,
+	propertyIDs []uint32,
+) (moreElements bool, newContinuationPoint string, elements []*com.BrowseElement, err error) {
+	err = b.intercept("IOPCBrowse.Browse", func() (err error) {
+		moreElements, newContinuationPoint, elements, err = b.BrowseBackend.Browse(itemID, continuationPoint, maxElements, browseFilter, elementNameFilter, vendorFilter, returnAllProperties, returnPropertyValues, propertyIDs)
+		return err
+	})
+	return
+}
+
+type interceptedItemIO struct {
+	ItemIOBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (i *interceptedItemIO) Release() {
+	interceptRelease(i.release, "IOPCItemIO.Release", i.ItemIOBackend.Release)
+}
+
+func (i *interceptedItemIO) Read(itemIDs []string, maxAges []uint32) (states []*com.ItemState, errors []int32, err error) {
+	err = i.intercept("IOPCItemIO.Read", func() (err error) {
+		states, errors, err = i.ItemIOBackend.Read(itemIDs, maxAges)
+		return err
+	})
+	return
+}
+
+func (i *interceptedItemIO) WriteVQT(itemIDs []string, items []*com.ItemVQT) (errors []int32, err error) {
+	err = i.intercept("IOPCItemIO.WriteVQT", func() (err error) {
+		errors, err = i.ItemIOBackend.WriteVQT(itemIDs, items)
+		return err
+	})
+	return
+}
+
+type interceptedGroup struct {
+	GroupBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (g *interceptedGroup) Release() {
+	interceptRelease(g.release, "IOPCGroupStateMgt.Release", g.GroupBackend.Release)
+}
+
+func (g *interceptedGroup) GetState() (updateRate uint32, active bool, name string, timeBias int32, percentDeadband float32, localeID uint32, clientGroup uint32, serverGroup uint32, err error) {
+	err = g.intercept("IOPCGroupStateMgt.GetState", func() (err error) {
+		updateRate, active, name, timeBias, percentDeadband, localeID, clientGroup, serverGroup, err = g.GroupBackend.GetState()
+		return err
+	})
+	return
+}
+
+func (g *interceptedGroup) SetState(requestedUpdateRate *uint32, active *bool, timeBias *int32, percentDeadband *float32, localeID *uint32, clientGroup *uint32) (revisedUpdateRate uint32, err error) {
+	err = g.intercept("IOPCGroupStateMgt.SetState", func() (err error) {
+		revisedUpdateRate, err = g.GroupBackend.SetState(requestedUpdateRate, active, timeBias, percentDeadband, localeID, clientGroup)
+		return err
+	})
+	return
+}
+
+func (g *interceptedGroup) SetName(name string) error {
+	return g.intercept("IOPCGroupStateMgt.SetName", func() error {
+		return g.GroupBackend.SetName(name)
+	})
+}
+
+func (g *interceptedGroup) QuerySyncIO() (SyncIOBackend, error) {
+	var syncIO SyncIOBackend
+	err := g.intercept("IOPCGroupStateMgt.QuerySyncIO", func() (err error) {
+		syncIO, err = g.GroupBackend.QuerySyncIO()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedSyncIO{SyncIOBackend: syncIO, intercept: g.intercept, release: g.release}, nil
+}
+
+func (g *interceptedGroup) QueryAsyncIO2() (AsyncIO2Backend, error) {
+	var asyncIO2 AsyncIO2Backend
+	err := g.intercept("IOPCGroupStateMgt.QueryAsyncIO2", func() (err error) {
+		asyncIO2, err = g.GroupBackend.QueryAsyncIO2()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedAsyncIO2{AsyncIO2Backend: asyncIO2, intercept: g.intercept, release: g.release}, nil
+}
+
+func (g *interceptedGroup) QuerySyncIO2() (SyncIO2Backend, error) {
+	var syncIO2 SyncIO2Backend
+	err := g.intercept("IOPCGroupStateMgt.QuerySyncIO2", func() (err error) {
+		syncIO2, err = g.GroupBackend.QuerySyncIO2()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedSyncIO2{SyncIO2Backend: syncIO2, intercept: g.intercept, release: g.release}, nil
+}
+
+func (g *interceptedGroup) QueryAsyncIO3() (AsyncIO3Backend, error) {
+	var asyncIO3 AsyncIO3Backend
+	err := g.intercept("IOPCGroupStateMgt.QueryAsyncIO3", func() (err error) {
+		asyncIO3, err = g.GroupBackend.QueryAsyncIO3()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedAsyncIO3{AsyncIO3Backend: asyncIO3, intercept: g.intercept, release: g.release}, nil
+}
+
+func (g *interceptedGroup) QueryItemMgt() (ItemMgtBackend, error) {
+	var itemMgt ItemMgtBackend
+	err := g.intercept("IOPCGroupStateMgt.QueryItemMgt", func() (err error) {
+		itemMgt, err = g.GroupBackend.QueryItemMgt()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedItemMgt{ItemMgtBackend: itemMgt, intercept: g.intercept, release: g.release}, nil
+}
+
+func (g *interceptedGroup) QueryItemDeadbandMgt() (ItemDeadbandMgtBackend, error) {
+	var deadbandMgt ItemDeadbandMgtBackend
+	err := g.intercept("IOPCGroupStateMgt.QueryItemDeadbandMgt", func() (err error) {
+		deadbandMgt, err = g.GroupBackend.QueryItemDeadbandMgt()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedItemDeadbandMgt{ItemDeadbandMgtBackend: deadbandMgt, intercept: g.intercept, release: g.release}, nil
+}
+
+func (g *interceptedGroup) QueryItemSamplingMgt() (ItemSamplingMgtBackend, error) {
+	var samplingMgt ItemSamplingMgtBackend
+	err := g.intercept("IOPCGroupStateMgt.QueryItemSamplingMgt", func() (err error) {
+		samplingMgt, err = g.GroupBackend.QueryItemSamplingMgt()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedItemSamplingMgt{ItemSamplingMgtBackend: samplingMgt, intercept: g.intercept, release: g.release}, nil
+}
+
+func (g *interceptedGroup) QueryGroupStateMgt2() (GroupStateMgt2Backend, error) {
+	var stateMgt2 GroupStateMgt2Backend
+	err := g.intercept("IOPCGroupStateMgt.QueryGroupStateMgt2", func() (err error) {
+		stateMgt2, err = g.GroupBackend.QueryGroupStateMgt2()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedGroupStateMgt2{GroupStateMgt2Backend: stateMgt2, intercept: g.intercept, release: g.release}, nil
+}
+
+func (g *interceptedGroup) AdviseDataCallback(callback DataCallback) (cookie uint32, err error) {
+	err = g.intercept("IOPCGroupStateMgt.AdviseDataCallback", func() (err error) {
+		cookie, err = g.GroupBackend.AdviseDataCallback(callback)
+		return err
+	})
+	return
+}
+
+func (g *interceptedGroup) UnadviseDataCallback(cookie uint32) error {
+	return g.intercept("IOPCGroupStateMgt.UnadviseDataCallback", func() error {
+		return g.GroupBackend.UnadviseDataCallback(cookie)
+	})
+}
+
+type interceptedSyncIO struct {
+	SyncIOBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (s *interceptedSyncIO) Release() {
+	interceptRelease(s.release, "IOPCSyncIO.Release", s.SyncIOBackend.Release)
+}
+
+func (s *interceptedSyncIO) Read(source com.OPCDATASOURCE, serverHandles []uint32) (states []*com.ItemState, errors []int32, err error) {
+	err = s.intercept("IOPCSyncIO.Read", func() (err error) {
+		states, errors, err = s.SyncIOBackend.Read(source, serverHandles)
+		return err
+	})
+	return
+}
+
+func (s *interceptedSyncIO) Write(serverHandles []uint32, values []interface{}) (errors []int32, err error) {
+	err = s.intercept("IOPCSyncIO.Write", func() (err error) {
+		errors, err = s.SyncIOBackend.Write(serverHandles, values)
+		return err
+	})
+	return
+}
+
+type interceptedSyncIO2 struct {
+	SyncIO2Backend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (s *interceptedSyncIO2) Release() {
+	interceptRelease(s.release, "IOPCSyncIO2.Release", s.SyncIO2Backend.Release)
+}
+
+func (s *interceptedSyncIO2) ReadMaxAge(serverHandles []uint32, maxAges []uint32) (states []*com.ItemState, errors []int32, err error) {
+	err = s.intercept("IOPCSyncIO2.ReadMaxAge", func() (err error) {
+		states, errors, err = s.SyncIO2Backend.ReadMaxAge(serverHandles, maxAges)
+		return err
+	})
+	return
+}
+
+func (s *interceptedSyncIO2) WriteVQT(serverHandles []uint32, items []*com.ItemVQT) (errors []int32, err error) {
+	err = s.intercept("IOPCSyncIO2.WriteVQT", func() (err error) {
+		errors, err = s.SyncIO2Backend.WriteVQT(serverHandles, items)
+		return err
+	})
+	return
+}
+
+type interceptedAsyncIO2 struct {
+	AsyncIO2Backend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (a *interceptedAsyncIO2) Release() {
+	interceptRelease(a.release, "IOPCAsyncIO2.Release", a.AsyncIO2Backend.Release)
+}
+
+func (a *interceptedAsyncIO2) Read(serverHandles []uint32, transactionID uint32) (cancelID uint32, errors []int32, err error) {
+	err = a.intercept("IOPCAsyncIO2.Read", func() (err error) {
+		cancelID, errors, err = a.AsyncIO2Backend.Read(serverHandles, transactionID)
+		return err
+	})
+	return
+}
+
+func (a *interceptedAsyncIO2) Write(serverHandles []uint32, values []interface{}, transactionID uint32) (cancelID uint32, errors []int32, err error) {
+	err = a.intercept("IOPCAsyncIO2.Write", func() (err error) {
+		cancelID, errors, err = a.AsyncIO2Backend.Write(serverHandles, values, transactionID)
+		return err
+	})
+	return
+}
+
+func (a *interceptedAsyncIO2) Refresh2(source com.OPCDATASOURCE, transactionID uint32) (cancelID uint32, err error) {
+	err = a.intercept("IOPCAsyncIO2.Refresh2", func() (err error) {
+		cancelID, err = a.AsyncIO2Backend.Refresh2(source, transactionID)
+		return err
+	})
+	return
+}
+
+func (a *interceptedAsyncIO2) Cancel2(cancelID uint32) error {
+	return a.intercept("IOPCAsyncIO2.Cancel2", func() error {
+		return a.AsyncIO2Backend.Cancel2(cancelID)
+	})
+}
+
+type interceptedAsyncIO3 struct {
+	AsyncIO3Backend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (a *interceptedAsyncIO3) Release() {
+	interceptRelease(a.release, "IOPCAsyncIO3.Release", a.AsyncIO3Backend.Release)
+}
+
+func (a *interceptedAsyncIO3) ReadMaxAge(serverHandles []uint32, maxAges []uint32, transactionID uint32) (cancelID uint32, errors []int32, err error) {
+	err = a.intercept("IOPCAsyncIO3.ReadMaxAge", func() (err error) {
+		cancelID, errors, err = a.AsyncIO3Backend.ReadMaxAge(serverHandles, maxAges, transactionID)
+		return err
+	})
+	return
+}
+
+func (a *interceptedAsyncIO3) WriteVQT(serverHandles []uint32, items []*com.ItemVQT, transactionID uint32) (cancelID uint32, errors []int32, err error) {
+	err = a.intercept("IOPCAsyncIO3.WriteVQT", func() (err error) {
+		cancelID, errors, err = a.AsyncIO3Backend.WriteVQT(serverHandles, items, transactionID)
+		return err
+	})
+	return
+}
+
+type interceptedItemMgt struct {
+	ItemMgtBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (m *interceptedItemMgt) Release() {
+	interceptRelease(m.release, "IOPCItemMgt.Release", m.ItemMgtBackend.Release)
+}
+
+func (m *interceptedItemMgt) AddItems(items []com.ItemDefinition) (results []com.TagOPCITEMRESULTStruct, errors []int32, err error) {
+	err = m.intercept("IOPCItemMgt.AddItems", func() (err error) {
+		results, errors, err = m.ItemMgtBackend.AddItems(items)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemMgt) ValidateItems(items []com.ItemDefinition, blobUpdate bool) (results []com.TagOPCITEMRESULTStruct, errors []int32, err error) {
+	err = m.intercept("IOPCItemMgt.ValidateItems", func() (err error) {
+		results, errors, err = m.ItemMgtBackend.ValidateItems(items, blobUpdate)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemMgt) RemoveItems(serverHandles []uint32) (errors []int32, err error) {
+	err = m.intercept("IOPCItemMgt.RemoveItems", func() (err error) {
+		errors, err = m.ItemMgtBackend.RemoveItems(serverHandles)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemMgt) SetActiveState(serverHandles []uint32, active bool) (errors []int32, err error) {
+	err = m.intercept("IOPCItemMgt.SetActiveState", func() (err error) {
+		errors, err = m.ItemMgtBackend.SetActiveState(serverHandles, active)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemMgt) SetClientHandles(serverHandles []uint32, clientHandles []uint32) (errors []int32, err error) {
+	err = m.intercept("IOPCItemMgt.SetClientHandles", func() (err error) {
+		errors, err = m.ItemMgtBackend.SetClientHandles(serverHandles, clientHandles)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemMgt) SetDatatypes(serverHandles []uint32, requestedDataTypes []com.VT) (errors []int32, err error) {
+	err = m.intercept("IOPCItemMgt.SetDatatypes", func() (err error) {
+		errors, err = m.ItemMgtBackend.SetDatatypes(serverHandles, requestedDataTypes)
+		return err
+	})
+	return
+}
+
+type interceptedItemDeadbandMgt struct {
+	ItemDeadbandMgtBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (m *interceptedItemDeadbandMgt) Release() {
+	interceptRelease(m.release, "IOPCItemDeadbandMgt.Release", m.ItemDeadbandMgtBackend.Release)
+}
+
+func (m *interceptedItemDeadbandMgt) SetItemDeadband(serverHandles []uint32, percentDeadbands []float32) (errors []int32, err error) {
+	err = m.intercept("IOPCItemDeadbandMgt.SetItemDeadband", func() (err error) {
+		errors, err = m.ItemDeadbandMgtBackend.SetItemDeadband(serverHandles, percentDeadbands)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemDeadbandMgt) GetItemDeadband(serverHandles []uint32) (percentDeadbands []float32, errors []int32, err error) {
+	err = m.intercept("IOPCItemDeadbandMgt.GetItemDeadband", func() (err error) {
+		percentDeadbands, errors, err = m.ItemDeadbandMgtBackend.GetItemDeadband(serverHandles)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemDeadbandMgt) ClearItemDeadband(serverHandles []uint32) (errors []int32, err error) {
+	err = m.intercept("IOPCItemDeadbandMgt.ClearItemDeadband", func() (err error) {
+		errors, err = m.ItemDeadbandMgtBackend.ClearItemDeadband(serverHandles)
+		return err
+	})
+	return
+}
+
+type interceptedItemSamplingMgt struct {
+	ItemSamplingMgtBackend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (m *interceptedItemSamplingMgt) Release() {
+	interceptRelease(m.release, "IOPCItemSamplingMgt.Release", m.ItemSamplingMgtBackend.Release)
+}
+
+func (m *interceptedItemSamplingMgt) SetItemSamplingRate(serverHandles []uint32, requestedSamplingRates []uint32) (revisedSamplingRates []uint32, errors []int32, err error) {
+	err = m.intercept("IOPCItemSamplingMgt.SetItemSamplingRate", func() (err error) {
+		revisedSamplingRates, errors, err = m.ItemSamplingMgtBackend.SetItemSamplingRate(serverHandles, requestedSamplingRates)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemSamplingMgt) GetItemSamplingRate(serverHandles []uint32) (samplingRates []uint32, errors []int32, err error) {
+	err = m.intercept("IOPCItemSamplingMgt.GetItemSamplingRate", func() (err error) {
+		samplingRates, errors, err = m.ItemSamplingMgtBackend.GetItemSamplingRate(serverHandles)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemSamplingMgt) ClearItemSamplingRate(serverHandles []uint32) (errors []int32, err error) {
+	err = m.intercept("IOPCItemSamplingMgt.ClearItemSamplingRate", func() (err error) {
+		errors, err = m.ItemSamplingMgtBackend.ClearItemSamplingRate(serverHandles)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemSamplingMgt) SetItemBufferEnable(serverHandles []uint32, enable []bool) (errors []int32, err error) {
+	err = m.intercept("IOPCItemSamplingMgt.SetItemBufferEnable", func() (err error) {
+		errors, err = m.ItemSamplingMgtBackend.SetItemBufferEnable(serverHandles, enable)
+		return err
+	})
+	return
+}
+
+func (m *interceptedItemSamplingMgt) GetItemBufferEnable(serverHandles []uint32) (enable []bool, errors []int32, err error) {
+	err = m.intercept("IOPCItemSamplingMgt.GetItemBufferEnable", func() (err error) {
+		enable, errors, err = m.ItemSamplingMgtBackend.GetItemBufferEnable(serverHandles)
+		return err
+	})
+	return
+}
+
+type interceptedGroupStateMgt2 struct {
+	GroupStateMgt2Backend
+	intercept Interceptor
+	release   Interceptor
+}
+
+func (m *interceptedGroupStateMgt2) Release() {
+	interceptRelease(m.release, "IOPCGroupStateMgt2.Release", m.GroupStateMgt2Backend.Release)
+}
+
+func (m *interceptedGroupStateMgt2) SetKeepAlive(keepAliveTime uint32) (revisedKeepAliveTime uint32, err error) {
+	err = m.intercept("IOPCGroupStateMgt2.SetKeepAlive", func() (err error) {
+		revisedKeepAliveTime, err = m.GroupStateMgt2Backend.SetKeepAlive(keepAliveTime)
+		return err
+	})
+	return
+}
+
+func (m *interceptedGroupStateMgt2) GetKeepAlive() (keepAliveTime uint32, err error) {
+	err = m.intercept("IOPCGroupStateMgt2.GetKeepAlive", func() (err error) {
+		keepAliveTime, err = m.GroupStateMgt2Backend.GetKeepAlive()
+		return err
+	})
+	return
+}
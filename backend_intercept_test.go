@@ -0,0 +1,91 @@
+package opcda_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/opcsim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransportFunc(t *testing.T) {
+	errUnreachable := errors.New("unreachable")
+	var node string
+	transport := opcda.TransportFunc(func(progID, n string, credentials *opcda.Credentials) (opcda.ServerBackend, error) {
+		node = n
+		return nil, errUnreachable
+	})
+	_, err := opcda.Connect(opcsim.DefaultProgID, "plc", opcda.WithTransport(transport))
+	assert.ErrorIs(t, err, errUnreachable)
+	assert.Equal(t, "plc", node)
+}
+
+func TestInterceptTransport(t *testing.T) {
+	recorder := &methodRecorder{}
+	transport := opcda.InterceptTransport(newSim(t), recorder.intercept)
+	server, err := opcda.Connect(opcsim.DefaultProgID, "", opcda.WithTransport(transport))
+	require.NoError(t, err)
+	group := addGroup(t, server, "intercept")
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Int4")
+	require.NoError(t, err)
+	require.NoError(t, item.Write(int32(3)))
+	value, _, _, err := item.Read(opcda.OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), value)
+	require.NoError(t, server.Disconnect())
+
+	assert.Equal(t, []string{
+		"Transport.Connect",
+		"IOPCServer.QueryCommon",
+		"IOPCServer.QueryItemProperties",
+		"IOPCServer.AddGroup",
+		"IOPCGroupStateMgt.QuerySyncIO",
+		"IOPCGroupStateMgt.QueryAsyncIO2",
+		"IOPCGroupStateMgt.QueryItemMgt",
+		"IOPCGroupStateMgt.QuerySyncIO2",
+		"IOPCGroupStateMgt.QueryAsyncIO3",
+		"IOPCGroupStateMgt.QueryItemDeadbandMgt",
+		"IOPCGroupStateMgt.QueryItemSamplingMgt",
+		"IOPCGroupStateMgt.QueryGroupStateMgt2",
+		"IOPCItemMgt.AddItems",
+		"IOPCSyncIO.Write",
+		"IOPCSyncIO.Read",
+	}, recorder.get())
+}
+
+func TestInterceptTransportRetry(t *testing.T) {
+	errTransient := errors.New("transient")
+	failures := 0
+	flaky := opcda.InterceptTransport(newSim(t), func(method string, invoke func() error) error {
+		if method == "IOPCSyncIO.Read" && failures < 2 {
+			failures++
+			return errTransient
+		}
+		return invoke()
+	})
+	retry := opcda.InterceptTransport(flaky, func(method string, invoke func() error) error {
+		var err error
+		for attempt := 0; attempt < 3; attempt++ {
+			if err = invoke(); !errors.Is(err, errTransient) {
+				return err
+			}
+		}
+		return err
+	})
+	server, err := opcda.Connect(opcsim.DefaultProgID, "", opcda.WithTransport(retry))
+	require.NoError(t, err)
+	defer server.Disconnect()
+	group := addGroup(t, server, "retry")
+	item, err := group.OPCItems().AddItem("Bucket Brigade.String")
+	require.NoError(t, err)
+	value, _, _, err := item.Read(opcda.OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, "", value)
+	assert.Equal(t, 2, failures)
+
+	_, err = opcda.Connect("Unknown", "", opcda.WithTransport(retry))
+	var opcErr *opcda.OPCError
+	assert.ErrorAs(t, err, &opcErr)
+}
@@ -0,0 +1,355 @@
+package opcda
+
+import (
+	"strconv"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+// BrowseFilter selects the elements returned by OPCServer.Browse, a nil filter returns every element
+type BrowseFilter struct {
+	// Type is OPC_BROWSE_FILTER_ALL, OPC_BROWSE_FILTER_BRANCHES or OPC_BROWSE_FILTER_ITEMS, all elements when zero
+	Type com.OPCBROWSEFILTER
+	// ElementName filters the element names with the wildcards of the server
+	ElementName string
+	// Vendor is a vendor specific filter, it is ignored by servers without OPC DA 3.0
+	Vendor string
+	// PropertyIDs selects the properties returned with the items, every property when empty
+	PropertyIDs []uint32
+}
+
+// ItemProperty is a property of an item returned by OPCServer.Browse and OPCServer.GetProperties
+type ItemProperty struct {
+	PropertyID  uint32
+	Description string
+	DataType    uint16
+	// ItemID is the item ID of the property, empty when the property cannot be added to a group
+	ItemID string
+	Value  interface{}
+	Err    error
+}
+
+// BrowseElement is a branch or an item of the address space
+type BrowseElement struct {
+	Name        string
+	ItemID      string
+	HasChildren bool
+	IsItem      bool
+	// Properties is set for items when Browse is called with returnProperties
+	Properties []*ItemProperty
+	// Err is the error of the property retrieval of the item
+	Err error
+}
+
+// BrowseResult is a page of elements returned by OPCServer.Browse
+type BrowseResult struct {
+	Elements []*BrowseElement
+	// ContinuationPoint is not empty when the server has more elements, Next returns them
+	ContinuationPoint string
+	// MoreElements reports that the server has more elements but does not support continuation points
+	MoreElements bool
+
+	server  *OPCServer
+	request browseRequest
+}
+
+type browseRequest struct {
+	itemID           string
+	filter           BrowseFilter
+	maxElements      uint32
+	returnProperties bool
+}
+
+// Browse returns the children of itemID, the root of the address space when itemID is empty.
+// maxElements limits the number of returned elements, zero means no limit, BrowseResult.Next returns the rest.
+// With returnProperties the properties of the items are returned with their values.
+// The server is browsed with the OPC DA 3.0 IOPCBrowse when available and with IOPCBrowseServerAddressSpace
+// otherwise. The fallback moves the browse position the server shares with the browsers created by CreateBrowser and
+// moves it back afterwards, the browsers must not be used concurrently.
+func (s *OPCServer) Browse(itemID string, filter *BrowseFilter, maxElements uint32, returnProperties bool) (*BrowseResult, error) {
+	request := browseRequest{
+		itemID:           itemID,
+		maxElements:      maxElements,
+		returnProperties: returnProperties,
+	}
+	if filter != nil {
+		request.filter = *filter
+	}
+	if request.filter.Type == 0 {
+		request.filter.Type = OPC_BROWSE_FILTER_ALL
+	}
+	return s.browse(&request, "")
+}
+
+// Next returns the elements after the continuation point, it returns nil when ContinuationPoint is empty
+func (r *BrowseResult) Next() (*BrowseResult, error) {
+	if r.ContinuationPoint == "" {
+		return nil, nil
+	}
+	return r.server.browse(&r.request, r.ContinuationPoint)
+}
+
+func (s *OPCServer) browse(request *browseRequest, continuationPoint string) (*BrowseResult, error) {
+	if browse := s.queryBrowse(); browse != nil {
+		return s.browse3(browse, request, continuationPoint)
+	}
+	return s.browse2(request, continuationPoint)
+}
+
+// queryBrowse returns the IOPCBrowse of the server, nil when the server does not implement it
+func (s *OPCServer) queryBrowse() BrowseBackend {
+	s.queryLock.Lock()
+	defer s.queryLock.Unlock()
+	if !s.browseQueried {
+		s.browseQueried = true
+		browse, err := s.iServer.QueryBrowse()
+		if err == nil {
+			s.iBrowse = browse
+		}
+	}
+	return s.iBrowse
+}
+
+func (s *OPCServer) browse3(browse BrowseBackend, request *browseRequest, continuationPoint string) (*BrowseResult, error) {
+	filter := &request.filter
+	var propertyIDs []uint32
+	if request.returnProperties {
+		propertyIDs = filter.PropertyIDs
+	}
+	moreElements, next, elements, err := browse.Browse(
+		request.itemID,
+		continuationPoint,
+		request.maxElements,
+		filter.Type,
+		filter.ElementName,
+		filter.Vendor,
+		request.returnProperties && len(propertyIDs) == 0,
+		request.returnProperties,
+		propertyIDs,
+	)
+	if err != nil {
+		return nil, NewOPCWrapperError("browse", err)
+	}
+	result := &BrowseResult{
+		Elements:          make([]*BrowseElement, len(elements)),
+		ContinuationPoint: next,
+		MoreElements:      moreElements,
+		server:            s,
+		request:           *request,
+	}
+	for i, e := range elements {
+		element := &BrowseElement{
+			Name:        e.Name,
+			ItemID:      e.ItemID,
+			HasChildren: e.Flags&OPC_BROWSE_HASCHILDREN != 0,
+			IsItem:      e.Flags&OPC_BROWSE_ISITEM != 0,
+		}
+		if request.returnProperties && element.IsItem {
+			element.Properties, element.Err = s.itemProperties(&e.Properties)
+		}
+		result.Elements[i] = element
+	}
+	return result, nil
+}
+
+// browse2 collects the elements with IOPCBrowseServerAddressSpace, the continuation point is the offset of the next element
+func (s *OPCServer) browse2(request *browseRequest, continuationPoint string) (*BrowseResult, error) {
+	start := 0
+	if continuationPoint != "" {
+		var err error
+		start, err = strconv.Atoi(continuationPoint)
+		if err != nil || start < 0 {
+			return nil, &OPCError{ErrorCode: int32(OPCInvalidContinuationPoint)}
+		}
+	}
+	browser, err := s.iServer.QueryBrowseServerAddressSpace()
+	if err != nil {
+		return nil, NewOPCWrapperError("query interface IOPCBrowseServerAddressSpace", err)
+	}
+	defer browser.Release()
+	children, err := browseChildren(browser, request.itemID, request.filter.ElementName)
+	if err != nil {
+		return nil, err
+	}
+	var elements []*BrowseElement
+	for _, element := range children {
+		switch request.filter.Type {
+		case OPC_BROWSE_FILTER_BRANCHES:
+			if !element.HasChildren {
+				continue
+			}
+		case OPC_BROWSE_FILTER_ITEMS:
+			if !element.IsItem {
+				continue
+			}
+		}
+		elements = append(elements, element)
+	}
+	if start > len(elements) {
+		return nil, &OPCError{ErrorCode: int32(OPCInvalidContinuationPoint)}
+	}
+	elements = elements[start:]
+	result := &BrowseResult{server: s, request: *request}
+	if request.maxElements > 0 && uint32(len(elements)) > request.maxElements {
+		elements = elements[:request.maxElements]
+		result.ContinuationPoint = strconv.Itoa(start + len(elements))
+	}
+	if request.returnProperties {
+		for _, element := range elements {
+			if element.IsItem {
+				element.Properties, element.Err = s.itemProperties2(element.ItemID, request.filter.PropertyIDs)
+			}
+		}
+	}
+	result.Elements = elements
+	return result, nil
+}
+
+// browseChildren returns the branches followed by the leaves below itemID
+func browseChildren(browser BrowseServerAddressSpaceBackend, itemID string, nameFilter string) ([]*BrowseElement, error) {
+	organization, err := browser.QueryOrganization()
+	if err != nil {
+		return nil, NewOPCWrapperError("query organization", err)
+	}
+	if organization == OPC_NS_FLAT {
+		if itemID != "" {
+			return nil, nil
+		}
+		itemIDs, err := browser.BrowseOPCItemIDs(OPC_FLAT, nameFilter, uint16(com.VT_EMPTY), 0)
+		if err != nil {
+			return nil, NewOPCWrapperError("browse items", err)
+		}
+		elements := make([]*BrowseElement, len(itemIDs))
+		for i, id := range itemIDs {
+			elements[i] = &BrowseElement{Name: id, ItemID: id, IsItem: true}
+		}
+		return elements, nil
+	}
+	// the position is shared with the browsers of the server, it is restored, or the root when it is unknown
+	position, err := browser.GetItemID("")
+	if err != nil {
+		position = ""
+	}
+	defer browser.ChangeBrowsePosition(OPC_BROWSE_TO, position)
+	if err = browser.ChangeBrowsePosition(OPC_BROWSE_TO, itemID); err != nil {
+		return nil, NewOPCWrapperError("change browse position", err)
+	}
+	branches, err := browser.BrowseOPCItemIDs(OPC_BRANCH, nameFilter, uint16(com.VT_EMPTY), 0)
+	if err != nil {
+		return nil, NewOPCWrapperError("browse branches", err)
+	}
+	leaves, err := browser.BrowseOPCItemIDs(OPC_LEAF, nameFilter, uint16(com.VT_EMPTY), 0)
+	if err != nil {
+		return nil, NewOPCWrapperError("browse leaves", err)
+	}
+	var elements []*BrowseElement
+	byName := make(map[string]*BrowseElement)
+	for _, name := range branches {
+		element := &BrowseElement{Name: name, HasChildren: true}
+		byName[name] = element
+		elements = append(elements, element)
+	}
+	for _, name := range leaves {
+		if element, ok := byName[name]; ok {
+			element.IsItem = true
+			continue
+		}
+		element := &BrowseElement{Name: name, IsItem: true}
+		byName[name] = element
+		elements = append(elements, element)
+	}
+	for _, element := range elements {
+		element.ItemID, err = browser.GetItemID(element.Name)
+		if err != nil {
+			return nil, NewOPCWrapperError("get item id", err)
+		}
+	}
+	return elements, nil
+}
+
+// GetProperties returns the properties of the items, every property of an item when propertyIDs is empty.
+// IOPCBrowse is used when available and IOPCItemProperties otherwise.
+func (s *OPCServer) GetProperties(itemIDs []string, propertyIDs []uint32) (properties [][]*ItemProperty, errors []error, err error) {
+	properties = make([][]*ItemProperty, len(itemIDs))
+	errors = make([]error, len(itemIDs))
+	if browse := s.queryBrowse(); browse != nil {
+		var result []*com.ItemProperties
+		result, err = browse.GetProperties(itemIDs, true, propertyIDs)
+		if err != nil {
+			return nil, nil, NewOPCWrapperError("get properties", err)
+		}
+		for i := range result {
+			if i < len(properties) {
+				properties[i], errors[i] = s.itemProperties(result[i])
+			}
+		}
+		return properties, errors, nil
+	}
+	for i, itemID := range itemIDs {
+		properties[i], errors[i] = s.itemProperties2(itemID, propertyIDs)
+	}
+	return properties, errors, nil
+}
+
+// itemProperties converts the properties returned by IOPCBrowse
+func (s *OPCServer) itemProperties(p *com.ItemProperties) ([]*ItemProperty, error) {
+	if p.Error < 0 {
+		return nil, s.errors([]int32{p.Error})[0]
+	}
+	errs := make([]int32, len(p.Properties))
+	for i, property := range p.Properties {
+		errs[i] = property.Error
+	}
+	errors := s.errors(errs)
+	properties := make([]*ItemProperty, len(p.Properties))
+	for i, property := range p.Properties {
+		properties[i] = &ItemProperty{
+			PropertyID:  property.PropertyID,
+			Description: property.Description,
+			DataType:    property.DataType,
+			ItemID:      property.ItemID,
+			Value:       property.Value,
+			Err:         errors[i],
+		}
+	}
+	return properties, nil
+}
+
+// itemProperties2 reads the properties of an item with IOPCItemProperties, every property when propertyIDs is empty
+func (s *OPCServer) itemProperties2(itemID string, propertyIDs []uint32) ([]*ItemProperty, error) {
+	ids, descriptions, dataTypes, err := s.iItemProperty.QueryAvailableProperties(itemID)
+	if err != nil {
+		return nil, err
+	}
+	if len(propertyIDs) == 0 {
+		propertyIDs = ids
+	}
+	if len(propertyIDs) == 0 {
+		return nil, nil
+	}
+	properties := make([]*ItemProperty, len(propertyIDs))
+	for i, id := range propertyIDs {
+		properties[i] = &ItemProperty{PropertyID: id}
+		for j := range ids {
+			if ids[j] == id && j < len(descriptions) && j < len(dataTypes) {
+				properties[i].Description = descriptions[j]
+				properties[i].DataType = dataTypes[j]
+				break
+			}
+		}
+	}
+	values, errs, err := s.iItemProperty.GetItemProperties(itemID, propertyIDs)
+	if err != nil {
+		return nil, err
+	}
+	errors := s.errors(errs)
+	for i := range properties {
+		if i < len(values) {
+			properties[i].Value = values[i]
+		}
+		if i < len(errors) {
+			properties[i].Err = errors[i]
+		}
+	}
+	return properties, nil
+}
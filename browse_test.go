@@ -0,0 +1,176 @@
+package opcda_test
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/opcsim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// methodRecorder records the methods of the backend calls, its intercept is passed to wrapTransport
+type methodRecorder struct {
+	lock    sync.Mutex
+	methods []string
+}
+
+func (r *methodRecorder) intercept(method string, invoke func() error) error {
+	r.lock.Lock()
+	r.methods = append(r.methods, method)
+	r.lock.Unlock()
+	return invoke()
+}
+
+func (r *methodRecorder) get() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.methods...)
+}
+
+func elementNames(elements []*opcda.BrowseElement) []string {
+	names := make([]string, len(elements))
+	for i, element := range elements {
+		names[i] = element.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestBrowse(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    []opcsim.Option
+		method  string
+		missing string
+	}{
+		{name: "IOPCBrowse", method: "IOPCBrowse.Browse", missing: "IOPCBrowseServerAddressSpace.BrowseOPCItemIDs"},
+		{name: "fallback", opts: []opcsim.Option{opcsim.WithoutDA3()}, method: "IOPCBrowseServerAddressSpace.BrowseOPCItemIDs", missing: "IOPCBrowse.Browse"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := &methodRecorder{}
+			_, server := connectSim(t, withSimOptions(tc.opts...), withDecorator(wrapTransport(recorder.intercept, nil)))
+
+			root, err := server.Browse("", nil, 0, false)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"Bucket Brigade", "Random", "Saw-toothed Waves", "Sine Waves", "Write Only"}, elementNames(root.Elements))
+			for _, element := range root.Elements {
+				assert.True(t, element.HasChildren)
+				assert.False(t, element.IsItem)
+				assert.Equal(t, element.Name, element.ItemID)
+			}
+			assert.Empty(t, root.ContinuationPoint)
+			next, err := root.Next()
+			require.NoError(t, err)
+			assert.Nil(t, next)
+
+			all, err := server.Browse("Random", &opcda.BrowseFilter{Type: opcda.OPC_BROWSE_FILTER_ITEMS}, 0, false)
+			require.NoError(t, err)
+			require.NotEmpty(t, all.Elements)
+			for _, element := range all.Elements {
+				assert.True(t, element.IsItem)
+				assert.Equal(t, "Random."+element.Name, element.ItemID)
+				assert.Nil(t, element.Properties)
+			}
+
+			var paged []*opcda.BrowseElement
+			pages := 0
+			page, err := server.Browse("Random", &opcda.BrowseFilter{Type: opcda.OPC_BROWSE_FILTER_ITEMS}, 3, false)
+			for page != nil {
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(page.Elements), 3)
+				paged = append(paged, page.Elements...)
+				pages++
+				page, err = page.Next()
+			}
+			require.NoError(t, err)
+			assert.Equal(t, elementNames(all.Elements), elementNames(paged))
+			assert.Equal(t, (len(all.Elements)+2)/3, pages)
+
+			filtered, err := server.Browse("Bucket Brigade", &opcda.BrowseFilter{ElementName: "Int*"}, 0, true)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"Int1", "Int2", "Int4"}, elementNames(filtered.Elements))
+			for _, element := range filtered.Elements {
+				require.NoError(t, element.Err)
+				require.NotEmpty(t, element.Properties)
+				assert.Equal(t, uint32(1), element.Properties[0].PropertyID)
+				assert.Equal(t, "Item Canonical Data Type", element.Properties[0].Description)
+			}
+
+			selected, err := server.Browse("Write Only", &opcda.BrowseFilter{PropertyIDs: []uint32{5, 8}}, 0, true)
+			require.NoError(t, err)
+			require.Len(t, selected.Elements, 1)
+			properties := selected.Elements[0].Properties
+			require.Len(t, properties, 2)
+			assert.Equal(t, int32(opcda.OPC_WRITEABLE), properties[0].Value)
+			assert.NoError(t, properties[0].Err)
+			var opcErr *opcda.OPCError
+			require.ErrorAs(t, properties[1].Err, &opcErr)
+			assert.Equal(t, int32(opcda.OPCInvalidPID), opcErr.ErrorCode)
+
+			branches, err := server.Browse("", &opcda.BrowseFilter{Type: opcda.OPC_BROWSE_FILTER_ITEMS}, 0, false)
+			require.NoError(t, err)
+			assert.Empty(t, branches.Elements)
+
+			_, err = server.Browse("Unknown", nil, 0, false)
+			assert.Error(t, err)
+
+			assert.Contains(t, recorder.get(), tc.method)
+			assert.NotContains(t, recorder.get(), tc.missing)
+		})
+	}
+}
+
+func TestGetProperties(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []opcsim.Option
+	}{
+		{name: "IOPCBrowse"},
+		{name: "fallback", opts: []opcsim.Option{opcsim.WithoutDA3()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, server := connectSim(t, withSimOptions(tc.opts...))
+
+			properties, errs, err := server.GetProperties([]string{"Bucket Brigade.Int4", "Unknown"}, []uint32{1, 5})
+			require.NoError(t, err)
+			require.Len(t, properties, 2)
+			require.Len(t, errs, 2)
+			assert.NoError(t, errs[0])
+			require.Len(t, properties[0], 2)
+			assert.Equal(t, int16(3), properties[0][0].Value)
+			assert.Equal(t, int32(opcda.OPC_READABLE|opcda.OPC_WRITEABLE), properties[0][1].Value)
+			assert.Equal(t, "Item Access Rights", properties[0][1].Description)
+			assert.Error(t, errs[1])
+
+			properties, errs, err = server.GetProperties([]string{"Sine Waves.Real8"}, nil)
+			require.NoError(t, err)
+			assert.NoError(t, errs[0])
+			ids := make([]uint32, len(properties[0]))
+			for i, property := range properties[0] {
+				assert.NoError(t, property.Err)
+				ids[i] = property.PropertyID
+			}
+			assert.Equal(t, []uint32{1, 2, 3, 4, 5, 6, 7, 8}, ids)
+		})
+	}
+}
+
+func TestBrowseKeepsPosition(t *testing.T) {
+	_, server := connectSim(t, withSimOptions(opcsim.WithoutDA3()))
+	browser, err := server.CreateBrowser()
+	require.NoError(t, err)
+	defer browser.Release()
+	require.NoError(t, browser.MoveDown("Bucket Brigade"))
+
+	// the fallback shares the position of the browser and moves it back
+	_, err = server.Browse("Random", nil, 0, false)
+	require.NoError(t, err)
+	_, err = server.Browse("Unknown", nil, 0, false)
+	assert.Error(t, err)
+	position, err := browser.GetCurrentPosition()
+	assert.NoError(t, err)
+	assert.Equal(t, "Bucket Brigade", position)
+}
@@ -0,0 +1,89 @@
+package opcda
+
+import (
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+type CDataChangeCallBackData struct {
+	TransID           uint32
+	GroupHandle       uint32
+	MasterQuality     int32
+	MasterErr         int32
+	ItemClientHandles []uint32
+	Values            []interface{}
+	Qualities         []com.Quality
+	TimeStamps        []time.Time
+	Errors            []int32
+	// DecodeErrors holds the error of the values that could not be decoded, their Errors are com.BadVarType. It is
+	// nil when every value was decoded.
+	DecodeErrors []error
+}
+
+type CReadCompleteCallBackData struct {
+	TransID           uint32
+	GroupHandle       uint32
+	MasterQuality     int32
+	MasterErr         int32
+	ItemClientHandles []uint32
+	Values            []interface{}
+	Qualities         []com.Quality
+	TimeStamps        []time.Time
+	Errors            []int32
+	// DecodeErrors holds the error of the values that could not be decoded, their Errors are com.BadVarType. It is
+	// nil when every value was decoded.
+	DecodeErrors []error
+}
+
+type CWriteCompleteCallBackData struct {
+	TransID           uint32
+	GroupHandle       uint32
+	MasterErr         int32
+	ItemClientHandles []uint32
+	Errors            []int32
+}
+
+type CCancelCompleteCallBackData struct {
+	TransID     uint32
+	GroupHandle uint32
+}
+
+// channelDataCallback is a DataCallback that forwards every event to a channel. The events are dropped once done is
+// closed, when the callback is unadvised and the loop that receives them has stopped, so that a late callback of the
+// server does not block.
+type channelDataCallback struct {
+	done                   <-chan struct{}
+	dataChangeReceiver     chan *CDataChangeCallBackData
+	readCompleteReceiver   chan *CReadCompleteCallBackData
+	writeCompleteReceiver  chan *CWriteCompleteCallBackData
+	cancelCompleteReceiver chan *CCancelCompleteCallBackData
+}
+
+func (c *channelDataCallback) OnDataChange(data *CDataChangeCallBackData) {
+	select {
+	case c.dataChangeReceiver <- data:
+	case <-c.done:
+	}
+}
+
+func (c *channelDataCallback) OnReadComplete(data *CReadCompleteCallBackData) {
+	select {
+	case c.readCompleteReceiver <- data:
+	case <-c.done:
+	}
+}
+
+func (c *channelDataCallback) OnWriteComplete(data *CWriteCompleteCallBackData) {
+	select {
+	case c.writeCompleteReceiver <- data:
+	case <-c.done:
+	}
+}
+
+func (c *channelDataCallback) OnCancelComplete(data *CCancelCompleteCallBackData) {
+	select {
+	case c.cancelCompleteReceiver <- data:
+	case <-c.done:
+	}
+}
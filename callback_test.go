@@ -0,0 +1,90 @@
+package opcda_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturedCallback keeps the data callback the groups of a simulated server advise, its wrap is passed to
+// wrapTransport
+type capturedCallback struct {
+	lock     sync.Mutex
+	callback opcda.DataCallback
+}
+
+func (c *capturedCallback) wrap(group opcda.GroupBackend) opcda.GroupBackend {
+	return &capturedGroup{GroupBackend: group, captured: c}
+}
+
+func (c *capturedCallback) get() opcda.DataCallback {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.callback
+}
+
+type capturedGroup struct {
+	opcda.GroupBackend
+	captured *capturedCallback
+}
+
+func (g *capturedGroup) AdviseDataCallback(callback opcda.DataCallback) (uint32, error) {
+	g.captured.lock.Lock()
+	g.captured.callback = callback
+	g.captured.lock.Unlock()
+	return g.GroupBackend.AdviseDataCallback(callback)
+}
+
+func TestCallbackDecodeErrors(t *testing.T) {
+	captured := &capturedCallback{}
+	_, server := connectSim(t, withDecorator(wrapTransport(nil, captured.wrap)))
+	group := addGroup(t, server, "decode")
+	dataChange := make(chan *opcda.DataChangeCallBackData, 10)
+	require.NoError(t, group.RegisterDataChange(dataChange))
+	readComplete := make(chan *opcda.ReadCompleteCallBackData, 10)
+	require.NoError(t, group.RegisterReadComplete(readComplete))
+	callback := captured.get()
+	require.NotNil(t, callback)
+
+	decodeErr := &com.DecodeError{VT: com.VT_UNKNOWN, Err: com.ErrUnsupportedType}
+	callback.OnDataChange(&opcda.CDataChangeCallBackData{
+		TransID:           42,
+		ItemClientHandles: []uint32{1, 2},
+		Values:            []interface{}{int32(1), nil},
+		Qualities:         []com.Quality{com.QualityGood, com.QualityBad},
+		TimeStamps:        []time.Time{{}, {}},
+		Errors:            []int32{0, com.BadVarType},
+		DecodeErrors:      []error{nil, decodeErr},
+	})
+	select {
+	case data := <-dataChange:
+		assert.Equal(t, uint32(42), data.TransID)
+		assert.NoError(t, data.Errors[0])
+		assert.ErrorIs(t, data.Errors[1], com.ErrUnsupportedType)
+		assert.Equal(t, decodeErr, data.Errors[1])
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	callback.OnReadComplete(&opcda.CReadCompleteCallBackData{
+		TransID:           43,
+		ItemClientHandles: []uint32{1},
+		Values:            []interface{}{nil},
+		Qualities:         []com.Quality{com.QualityBad},
+		TimeStamps:        []time.Time{{}},
+		Errors:            []int32{com.BadVarType},
+		DecodeErrors:      []error{decodeErr},
+	})
+	select {
+	case data := <-readComplete:
+		assert.Equal(t, uint32(43), data.TransID)
+		assert.Equal(t, decodeErr, data.Errors[0])
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+}
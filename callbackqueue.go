@@ -0,0 +1,196 @@
+package opcda
+
+import (
+	"sync"
+)
+
+// maxQueuedCallbacks is the number of callbacks a callbackQueue holds while its receiver is busy, the callbacks that
+// arrive while it is full are dropped like the events of the DropNewest delivery policy
+const maxQueuedCallbacks = 4096
+
+// callbackQueue runs the callbacks of a server in order on a goroutine of its own. The callbacks into an STA are
+// dispatched by the message pump of its thread, which must not wait for the receivers of the callbacks: a receiver
+// that calls the server would wait for the thread it blocks.
+type callbackQueue struct {
+	// lock guards pending and closed
+	lock    sync.Mutex
+	pending []func()
+	closed  bool
+	wakeup  chan struct{}
+}
+
+func newCallbackQueue() *callbackQueue {
+	q := &callbackQueue{wakeup: make(chan struct{}, 1)}
+	go q.run()
+	return q
+}
+
+// push queues a callback without blocking, it is dropped when the queue is full or closed
+func (q *callbackQueue) push(callback func()) {
+	q.lock.Lock()
+	if q.closed || len(q.pending) >= maxQueuedCallbacks {
+		q.lock.Unlock()
+		return
+	}
+	q.pending = append(q.pending, callback)
+	q.lock.Unlock()
+	q.wake()
+}
+
+// close drops the pending callbacks and stops the goroutine once the running callback returns
+func (q *callbackQueue) close() {
+	q.lock.Lock()
+	q.closed = true
+	q.pending = nil
+	q.lock.Unlock()
+	q.wake()
+}
+
+func (q *callbackQueue) wake() {
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (q *callbackQueue) run() {
+	for range q.wakeup {
+		for {
+			q.lock.Lock()
+			if q.closed {
+				q.lock.Unlock()
+				return
+			}
+			if len(q.pending) == 0 {
+				q.lock.Unlock()
+				break
+			}
+			callback := q.pending[0]
+			q.pending[0] = nil
+			q.pending = q.pending[1:]
+			q.lock.Unlock()
+			callback()
+		}
+	}
+}
+
+// queueCallbacks decorates transport so that the callbacks of the backends it creates go through a callbackQueue for
+// each advise, they are used on the thread of an STA executor
+func queueCallbacks(transport Transport) Transport {
+	return decoratedTransport{inner: transport, TransportFunc: func(progID, node string, credentials *Credentials) (ServerBackend, error) {
+		server, err := transport.Connect(progID, node, credentials)
+		if err != nil {
+			return nil, err
+		}
+		return &queuedServer{ServerBackend: server}, nil
+	}}
+}
+
+// queues are the callback queues of the advises of a backend by cookie
+type queues struct {
+	lock   sync.Mutex
+	queues map[uint32]*callbackQueue
+}
+
+func (q *queues) add(cookie uint32, queue *callbackQueue) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.queues == nil {
+		q.queues = make(map[uint32]*callbackQueue)
+	}
+	q.queues[cookie] = queue
+}
+
+func (q *queues) remove(cookie uint32) {
+	q.lock.Lock()
+	queue := q.queues[cookie]
+	delete(q.queues, cookie)
+	q.lock.Unlock()
+	if queue != nil {
+		queue.close()
+	}
+}
+
+type queuedServer struct {
+	ServerBackend
+	shutdown queues
+}
+
+func (s *queuedServer) AddGroup(name string, active bool, requestedUpdateRate uint32, clientGroup uint32, timeBias *int32, percentDeadband *float32, localeID uint32) (uint32, uint32, GroupBackend, error) {
+	serverGroup, revisedUpdateRate, group, err := s.ServerBackend.AddGroup(name, active, requestedUpdateRate, clientGroup, timeBias, percentDeadband, localeID)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return serverGroup, revisedUpdateRate, &queuedGroup{GroupBackend: group}, nil
+}
+
+func (s *queuedServer) AdviseShutdown(onShutdown func(reason string)) (uint32, error) {
+	queue := newCallbackQueue()
+	cookie, err := s.ServerBackend.AdviseShutdown(func(reason string) {
+		queue.push(func() {
+			onShutdown(reason)
+		})
+	})
+	if err != nil {
+		queue.close()
+		return 0, err
+	}
+	s.shutdown.add(cookie, queue)
+	return cookie, nil
+}
+
+func (s *queuedServer) UnadviseShutdown(cookie uint32) error {
+	defer s.shutdown.remove(cookie)
+	return s.ServerBackend.UnadviseShutdown(cookie)
+}
+
+type queuedGroup struct {
+	GroupBackend
+	data queues
+}
+
+func (g *queuedGroup) AdviseDataCallback(callback DataCallback) (uint32, error) {
+	queued := &queuedDataCallback{callback: callback, queue: newCallbackQueue()}
+	cookie, err := g.GroupBackend.AdviseDataCallback(queued)
+	if err != nil {
+		queued.queue.close()
+		return 0, err
+	}
+	g.data.add(cookie, queued.queue)
+	return cookie, nil
+}
+
+func (g *queuedGroup) UnadviseDataCallback(cookie uint32) error {
+	defer g.data.remove(cookie)
+	return g.GroupBackend.UnadviseDataCallback(cookie)
+}
+
+// queuedDataCallback hands the events of a data callback to a callbackQueue
+type queuedDataCallback struct {
+	callback DataCallback
+	queue    *callbackQueue
+}
+
+func (c *queuedDataCallback) OnDataChange(data *CDataChangeCallBackData) {
+	c.queue.push(func() {
+		c.callback.OnDataChange(data)
+	})
+}
+
+func (c *queuedDataCallback) OnReadComplete(data *CReadCompleteCallBackData) {
+	c.queue.push(func() {
+		c.callback.OnReadComplete(data)
+	})
+}
+
+func (c *queuedDataCallback) OnWriteComplete(data *CWriteCompleteCallBackData) {
+	c.queue.push(func() {
+		c.callback.OnWriteComplete(data)
+	})
+}
+
+func (c *queuedDataCallback) OnCancelComplete(data *CCancelCompleteCallBackData) {
+	c.queue.push(func() {
+		c.callback.OnCancelComplete(data)
+	})
+}
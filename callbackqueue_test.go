@@ -0,0 +1,83 @@
+package opcda_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleThreadedCallbacks(t *testing.T) {
+	executor, err := com.NewExecutor(com.WithInitConfig(&com.InitConfig{Apartment: com.SingleThreaded}))
+	require.NoError(t, err)
+	t.Cleanup(executor.Close)
+	captured := &capturedCallback{}
+	_, server := connectSim(t, withDecorator(wrapTransport(nil, captured.wrap)), withConnectOptions(opcda.WithExecutor(executor)))
+	group := addGroup(t, server, "sta")
+	ch := make(chan *opcda.DataChangeCallBackData)
+	require.NoError(t, group.RegisterDataChange(ch, opcda.WithDeliveryPolicy(opcda.Block)))
+
+	// the callbacks return at once although the receiver blocks the delivery
+	const count = 300
+	callback := captured.get()
+	fired := make(chan struct{})
+	go func() {
+		defer close(fired)
+		for i := uint32(1); i <= count; i++ {
+			callback.OnDataChange(dataChange(i, 1, float64(i)))
+		}
+	}()
+	select {
+	case <-fired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the callbacks blocked the thread of the server")
+	}
+	for i := uint32(1); i <= count; i++ {
+		select {
+		case data := <-ch:
+			assert.Equal(t, i, data.TransID)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+
+	// the calls go through the thread of the executor while it dispatches the callbacks
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	require.NoError(t, item.Write(8.5))
+	value, _, _, err := item.Read(opcda.OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, 8.5, value)
+}
+
+func TestSingleThreadedCallbacksFull(t *testing.T) {
+	executor, err := com.NewExecutor(com.WithInitConfig(&com.InitConfig{Apartment: com.SingleThreaded}))
+	require.NoError(t, err)
+	t.Cleanup(executor.Close)
+	captured := &capturedCallback{}
+	_, server := connectSim(t, withDecorator(wrapTransport(nil, captured.wrap)), withConnectOptions(opcda.WithExecutor(executor)))
+	group := addGroup(t, server, "sta")
+	ch := make(chan *opcda.DataChangeCallBackData)
+	require.NoError(t, group.RegisterDataChange(ch, opcda.WithDeliveryPolicy(opcda.Block)))
+
+	// the queue holds 4096 callbacks besides the one waiting for the receiver, the later ones are dropped
+	callback := captured.get()
+	for i := uint32(1); i <= 5000; i++ {
+		callback.OnDataChange(dataChange(i, 1, float64(i)))
+	}
+	var received uint32
+	for done := false; !done; {
+		select {
+		case data := <-ch:
+			received++
+			assert.Equal(t, received, data.TransID)
+		case <-time.After(200 * time.Millisecond):
+			done = true
+		}
+	}
+	assert.GreaterOrEqual(t, received, uint32(4096))
+	assert.LessOrEqual(t, received, uint32(4097))
+}
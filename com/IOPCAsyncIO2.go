@@ -1,19 +1,13 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
 	"syscall"
 	"unsafe"
-
-	"golang.org/x/sys/windows"
 )
 
-var IID_IOPCAsyncIO2 = windows.GUID{
-	Data1: 0x39c13a71,
-	Data2: 0x011e,
-	Data3: 0x11d0,
-	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
-}
-
 type IOPCAsyncIO2Vtbl struct {
 	IUnknownVtbl
 	Read      uintptr
@@ -0,0 +1,109 @@
+//go:build windows
+// +build windows
+
+package com
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type IOPCAsyncIO3Vtbl struct {
+	IOPCAsyncIO2Vtbl
+	ReadMaxAge    uintptr
+	WriteVQT      uintptr
+	RefreshMaxAge uintptr
+}
+
+type IOPCAsyncIO3 struct {
+	*IUnknown
+}
+
+func (sl *IOPCAsyncIO3) Vtbl() *IOPCAsyncIO3Vtbl {
+	return (*IOPCAsyncIO3Vtbl)(unsafe.Pointer(sl.IUnknown.LpVtbl))
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE ReadMaxAge(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][in] */ DWORD *pdwMaxAge,
+//            /* [in] */ DWORD dwTransactionID,
+//            /* [out] */ DWORD *pdwCancelID,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCAsyncIO3) ReadMaxAge(serverHandles []uint32, maxAges []uint32, dwTransactionID uint32) (pdwCancelID uint32, ppErrors []int32, err error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return
+	}
+	if len(maxAges) != count {
+		err = syscall.Errno(windows.E_INVALIDARG)
+		return
+	}
+	var pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().ReadMaxAge,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&maxAges[0])),
+		uintptr(dwTransactionID),
+		uintptr(unsafe.Pointer(&pdwCancelID)),
+		uintptr(unsafe.Pointer(&pErrors)))
+	if int32(r0) < 0 {
+		err = syscall.Errno(r0)
+		return
+	}
+	ppErrors = readErrors(count, pErrors)
+	return
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE WriteVQT(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][in] */ OPCITEMVQT *pItemVQT,
+//            /* [in] */ DWORD dwTransactionID,
+//            /* [out] */ DWORD *pdwCancelID,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCAsyncIO3) WriteVQT(serverHandles []uint32, items []TagOPCITEMVQT, dwTransactionID uint32) (pdwCancelID uint32, ppErrors []int32, err error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return
+	}
+	if len(items) != count {
+		err = syscall.Errno(windows.E_INVALIDARG)
+		return
+	}
+	var pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().WriteVQT,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&items[0])),
+		uintptr(dwTransactionID),
+		uintptr(unsafe.Pointer(&pdwCancelID)),
+		uintptr(unsafe.Pointer(&pErrors)))
+	if int32(r0) < 0 {
+		err = syscall.Errno(r0)
+		return
+	}
+	ppErrors = readErrors(count, pErrors)
+	return
+}
+
+// readErrors converts and frees the HRESULT array of count items returned by a call
+func readErrors(count int, pErrors unsafe.Pointer) []int32 {
+	if pErrors == nil {
+		return nil
+	}
+	defer CoTaskMemFree(pErrors)
+	errors := make([]int32, count)
+	for i := 0; i < count; i++ {
+		errors[i] = *(*int32)(unsafe.Pointer(uintptr(pErrors) + uintptr(i)*4))
+	}
+	return errors
+}
@@ -0,0 +1,206 @@
+//go:build windows
+// +build windows
+
+package com
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type IOPCBrowse struct {
+	*IUnknown
+}
+
+type IOPCBrowseVtbl struct {
+	IUnknownVtbl
+	GetProperties uintptr
+	Browse        uintptr
+}
+
+func (v *IOPCBrowse) Vtbl() *IOPCBrowseVtbl {
+	return (*IOPCBrowseVtbl)(unsafe.Pointer(v.IUnknown.LpVtbl))
+}
+
+type tagOPCITEMPROPERTY struct {
+	VtDataType    uint16
+	WReserved     uint16
+	DwPropertyID  uint32
+	SzItemID      *uint16
+	SzDescription *uint16
+	VValue        VARIANT
+	HrErrorID     int32
+	DwReserved    uint32
+}
+
+type tagOPCITEMPROPERTIES struct {
+	HrErrorID       int32
+	DwNumProperties uint32
+	PItemProperties unsafe.Pointer
+	DwReserved      uint32
+}
+
+type tagOPCBROWSEELEMENT struct {
+	SzName         *uint16
+	SzItemID       *uint16
+	DwFlagValue    uint32
+	DwReserved     uint32
+	ItemProperties tagOPCITEMPROPERTIES
+}
+
+func (v *IOPCBrowse) GetProperties(pszItemIDs []string, bReturnPropertyValues bool, pdwPropertyIDs []uint32) (ppItemProperties []*ItemProperties, err error) {
+	count := len(pszItemIDs)
+	if count == 0 {
+		return
+	}
+	itemIDs, err := utf16PtrArray(pszItemIDs)
+	if err != nil {
+		return
+	}
+	var pPropertyIDs *uint32
+	if len(pdwPropertyIDs) > 0 {
+		pPropertyIDs = &pdwPropertyIDs[0]
+	}
+	var pItemProperties unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		v.Vtbl().GetProperties,
+		uintptr(unsafe.Pointer(v.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&itemIDs[0])),
+		uintptr(BoolToComBOOL(bReturnPropertyValues)),
+		uintptr(len(pdwPropertyIDs)),
+		uintptr(unsafe.Pointer(pPropertyIDs)),
+		uintptr(unsafe.Pointer(&pItemProperties)))
+	if int32(r0) < 0 {
+		return nil, syscall.Errno(r0)
+	}
+	defer CoTaskMemFree(pItemProperties)
+	ppItemProperties = make([]*ItemProperties, count)
+	for i := 0; i < count; i++ {
+		properties := (*tagOPCITEMPROPERTIES)(unsafe.Pointer(uintptr(pItemProperties) + uintptr(i)*unsafe.Sizeof(tagOPCITEMPROPERTIES{})))
+		ppItemProperties[i] = &ItemProperties{}
+		properties.read(ppItemProperties[i])
+	}
+	return
+}
+
+func (v *IOPCBrowse) Browse(
+	szItemID string,
+	pszContinuationPoint string,
+	dwMaxElementsReturned uint32,
+	dwBrowseFilter OPCBROWSEFILTER,
+	szElementNameFilter string,
+	szVendorFilter string,
+	bReturnAllProperties bool,
+	bReturnPropertyValues bool,
+	pdwPropertyIDs []uint32,
+) (pbMoreElements bool, newContinuationPoint string, ppBrowseElements []*BrowseElement, err error) {
+	var pItemID, pElementNameFilter, pVendorFilter *uint16
+	pItemID, err = syscall.UTF16PtrFromString(szItemID)
+	if err != nil {
+		return
+	}
+	pElementNameFilter, err = syscall.UTF16PtrFromString(szElementNameFilter)
+	if err != nil {
+		return
+	}
+	pVendorFilter, err = syscall.UTF16PtrFromString(szVendorFilter)
+	if err != nil {
+		return
+	}
+	// the continuation point is [in, out], the server may free it so it is allocated with CoTaskMemAlloc
+	var pContinuationPoint unsafe.Pointer
+	if pszContinuationPoint != "" {
+		var chars []uint16
+		chars, err = syscall.UTF16FromString(pszContinuationPoint)
+		if err != nil {
+			return
+		}
+		pContinuationPoint = CoTaskMemAlloc(uintptr(len(chars)) * 2)
+		if pContinuationPoint == nil {
+			err = syscall.Errno(windows.E_OUTOFMEMORY)
+			return
+		}
+		copy(unsafe.Slice((*uint16)(pContinuationPoint), len(chars)), chars)
+	}
+	defer func() {
+		CoTaskMemFree(pContinuationPoint)
+	}()
+	var pPropertyIDs *uint32
+	if len(pdwPropertyIDs) > 0 {
+		pPropertyIDs = &pdwPropertyIDs[0]
+	}
+	var bMoreElements int32
+	var dwCount uint32
+	var pBrowseElements unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		v.Vtbl().Browse,
+		uintptr(unsafe.Pointer(v.IUnknown)),
+		uintptr(unsafe.Pointer(pItemID)),
+		uintptr(unsafe.Pointer(&pContinuationPoint)),
+		uintptr(dwMaxElementsReturned),
+		uintptr(dwBrowseFilter),
+		uintptr(unsafe.Pointer(pElementNameFilter)),
+		uintptr(unsafe.Pointer(pVendorFilter)),
+		uintptr(BoolToComBOOL(bReturnAllProperties)),
+		uintptr(BoolToComBOOL(bReturnPropertyValues)),
+		uintptr(len(pdwPropertyIDs)),
+		uintptr(unsafe.Pointer(pPropertyIDs)),
+		uintptr(unsafe.Pointer(&bMoreElements)),
+		uintptr(unsafe.Pointer(&dwCount)),
+		uintptr(unsafe.Pointer(&pBrowseElements)))
+	if int32(r0) < 0 {
+		err = syscall.Errno(r0)
+		return
+	}
+	defer CoTaskMemFree(pBrowseElements)
+	pbMoreElements = bMoreElements != 0
+	if pContinuationPoint != nil {
+		newContinuationPoint = windows.UTF16PtrToString((*uint16)(pContinuationPoint))
+	}
+	ppBrowseElements = make([]*BrowseElement, dwCount)
+	for i := uint32(0); i < dwCount; i++ {
+		element := (*tagOPCBROWSEELEMENT)(unsafe.Pointer(uintptr(pBrowseElements) + uintptr(i)*unsafe.Sizeof(tagOPCBROWSEELEMENT{})))
+		ppBrowseElements[i] = &BrowseElement{
+			Name:   windows.UTF16PtrToString(element.SzName),
+			ItemID: windows.UTF16PtrToString(element.SzItemID),
+			Flags:  element.DwFlagValue,
+		}
+		CoTaskMemFree(unsafe.Pointer(element.SzName))
+		CoTaskMemFree(unsafe.Pointer(element.SzItemID))
+		element.ItemProperties.read(&ppBrowseElements[i].Properties)
+	}
+	return
+}
+
+// read converts an OPCITEMPROPERTIES and frees the memory allocated by the server
+func (p *tagOPCITEMPROPERTIES) read(properties *ItemProperties) {
+	properties.Error = p.HrErrorID
+	if p.PItemProperties == nil {
+		return
+	}
+	defer CoTaskMemFree(p.PItemProperties)
+	properties.Properties = make([]*ItemProperty, p.DwNumProperties)
+	for i := uint32(0); i < p.DwNumProperties; i++ {
+		property := (*tagOPCITEMPROPERTY)(unsafe.Pointer(uintptr(p.PItemProperties) + uintptr(i)*unsafe.Sizeof(tagOPCITEMPROPERTY{})))
+		properties.Properties[i] = &ItemProperty{
+			DataType:    property.VtDataType,
+			PropertyID:  property.DwPropertyID,
+			ItemID:      windows.UTF16PtrToString(property.SzItemID),
+			Description: windows.UTF16PtrToString(property.SzDescription),
+			Error:       property.HrErrorID,
+		}
+		if property.HrErrorID >= 0 {
+			value, err := property.VValue.Value()
+			if err != nil {
+				properties.Properties[i].Error = BadVarType
+			}
+			properties.Properties[i].Value = value
+		}
+		property.VValue.Clear()
+		CoTaskMemFree(unsafe.Pointer(property.SzItemID))
+		CoTaskMemFree(unsafe.Pointer(property.SzDescription))
+	}
+}
@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
@@ -7,13 +10,6 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-var IID_IOPCBrowseServerAddressSpace = windows.GUID{
-	Data1: 0x39c13a4f,
-	Data2: 0x011e,
-	Data3: 0x11d0,
-	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
-}
-
 type IOPCBrowseServerAddressSpace struct {
 	*IUnknown
 }
@@ -31,8 +27,6 @@ func (v *IOPCBrowseServerAddressSpace) Vtbl() *IOPCBrowseServerAddressSpaceVtbl
 	return (*IOPCBrowseServerAddressSpaceVtbl)(unsafe.Pointer(v.IUnknown.LpVtbl))
 }
 
-type OPCNAMESPACETYPE uint32
-
 func (v *IOPCBrowseServerAddressSpace) QueryOrganization() (pNameSpaceType OPCNAMESPACETYPE, err error) {
 	r0, _, _ := syscall.SyscallN(
 		v.Vtbl().QueryOrganization,
@@ -45,8 +39,6 @@ func (v *IOPCBrowseServerAddressSpace) QueryOrganization() (pNameSpaceType OPCNA
 	return
 }
 
-type OPCBROWSEDIRECTION uint32
-
 func (v *IOPCBrowseServerAddressSpace) ChangeBrowsePosition(dwBrowseDirection OPCBROWSEDIRECTION, szString string) (err error) {
 	var pName *uint16
 	pName, err = syscall.UTF16PtrFromString(szString)
@@ -66,8 +58,6 @@ func (v *IOPCBrowseServerAddressSpace) ChangeBrowsePosition(dwBrowseDirection OP
 	return
 }
 
-type OPCBROWSETYPE uint32
-
 func (v *IOPCBrowseServerAddressSpace) BrowseOPCItemIDs(dwBrowseFilterType OPCBROWSETYPE, szFilterCriteria string, vtDataTypeFilter uint16, dwAccessRightsFilter uint32) (result []string, err error) {
 	var pString *IUnknown
 	var pName *uint16
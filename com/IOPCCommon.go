@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
@@ -7,13 +10,6 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-var IID_IOPCCommon = windows.GUID{
-	Data1: 0xF31DFDE2,
-	Data2: 0x07B6,
-	Data3: 0x11d2,
-	Data4: [8]byte{0xB2, 0xD8, 0x00, 0x60, 0x08, 0x3B, 0xA1, 0xFB},
-}
-
 type IOPCCommon struct {
 	*IUnknown
 }
@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
@@ -7,13 +10,6 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-var IID_IOPCGroupStateMgt = windows.GUID{
-	Data1: 0x39c13a50,
-	Data2: 0x011e,
-	Data3: 0x11d0,
-	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
-}
-
 type IOPCGroupStateMgtVtbl struct {
 	IUnknownVtbl
 	GetState   uintptr
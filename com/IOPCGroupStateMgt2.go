@@ -0,0 +1,55 @@
+//go:build windows
+// +build windows
+
+package com
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+type IOPCGroupStateMgt2Vtbl struct {
+	IOPCGroupStateMgtVtbl
+	SetKeepAlive uintptr
+	GetKeepAlive uintptr
+}
+
+type IOPCGroupStateMgt2 struct {
+	*IUnknown
+}
+
+func (sl *IOPCGroupStateMgt2) Vtbl() *IOPCGroupStateMgt2Vtbl {
+	return (*IOPCGroupStateMgt2Vtbl)(unsafe.Pointer(sl.IUnknown.LpVtbl))
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE SetKeepAlive(
+//            /* [in] */ DWORD dwKeepAliveTime,
+//            /* [out] */ DWORD *pdwRevisedKeepAliveTime) = 0;
+
+func (sl *IOPCGroupStateMgt2) SetKeepAlive(keepAliveTime uint32) (uint32, error) {
+	var revised uint32
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().SetKeepAlive,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(keepAliveTime),
+		uintptr(unsafe.Pointer(&revised)))
+	if int32(r0) < 0 {
+		return 0, syscall.Errno(r0)
+	}
+	return revised, nil
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE GetKeepAlive(
+//            /* [out] */ DWORD *pdwKeepAliveTime) = 0;
+
+func (sl *IOPCGroupStateMgt2) GetKeepAlive() (uint32, error) {
+	var keepAliveTime uint32
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().GetKeepAlive,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(unsafe.Pointer(&keepAliveTime)))
+	if int32(r0) < 0 {
+		return 0, syscall.Errno(r0)
+	}
+	return keepAliveTime, nil
+}
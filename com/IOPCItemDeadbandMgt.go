@@ -0,0 +1,107 @@
+//go:build windows
+// +build windows
+
+package com
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type IOPCItemDeadbandMgtVtbl struct {
+	IUnknownVtbl
+	SetItemDeadband   uintptr
+	GetItemDeadband   uintptr
+	ClearItemDeadband uintptr
+}
+
+type IOPCItemDeadbandMgt struct {
+	*IUnknown
+}
+
+func (sl *IOPCItemDeadbandMgt) Vtbl() *IOPCItemDeadbandMgtVtbl {
+	return (*IOPCItemDeadbandMgtVtbl)(unsafe.Pointer(sl.IUnknown.LpVtbl))
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE SetItemDeadband(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][in] */ FLOAT *pPercentDeadband,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCItemDeadbandMgt) SetItemDeadband(serverHandles []uint32, percentDeadbands []float32) ([]int32, error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return nil, nil
+	}
+	if len(percentDeadbands) != count {
+		return nil, syscall.Errno(windows.E_INVALIDARG)
+	}
+	var pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().SetItemDeadband,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&percentDeadbands[0])),
+		uintptr(unsafe.Pointer(&pErrors)))
+	if int32(r0) < 0 {
+		return nil, syscall.Errno(r0)
+	}
+	return readErrors(count, pErrors), nil
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE GetItemDeadband(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][size_is][out] */ FLOAT **ppPercentDeadband,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCItemDeadbandMgt) GetItemDeadband(serverHandles []uint32) ([]float32, []int32, error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return nil, nil, nil
+	}
+	var pPercentDeadbands, pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().GetItemDeadband,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&pPercentDeadbands)),
+		uintptr(unsafe.Pointer(&pErrors)))
+	if int32(r0) < 0 {
+		return nil, nil, syscall.Errno(r0)
+	}
+	defer CoTaskMemFree(pPercentDeadbands)
+	deadbands := make([]float32, count)
+	for i := 0; i < count; i++ {
+		deadbands[i] = *(*float32)(unsafe.Pointer(uintptr(pPercentDeadbands) + uintptr(i)*4))
+	}
+	return deadbands, readErrors(count, pErrors), nil
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE ClearItemDeadband(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCItemDeadbandMgt) ClearItemDeadband(serverHandles []uint32) ([]int32, error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return nil, nil
+	}
+	var pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().ClearItemDeadband,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&pErrors)))
+	if int32(r0) < 0 {
+		return nil, syscall.Errno(r0)
+	}
+	return readErrors(count, pErrors), nil
+}
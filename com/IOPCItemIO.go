@@ -0,0 +1,148 @@
+//go:build windows
+// +build windows
+
+package com
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type IOPCItemIO struct {
+	*IUnknown
+}
+
+type IOPCItemIOVtbl struct {
+	IUnknownVtbl
+	Read     uintptr
+	WriteVQT uintptr
+}
+
+func (v *IOPCItemIO) Vtbl() *IOPCItemIOVtbl {
+	return (*IOPCItemIOVtbl)(unsafe.Pointer(v.IUnknown.LpVtbl))
+}
+
+type TagOPCITEMVQT struct {
+	VDataValue          VARIANT
+	BQualitySpecified   int32
+	WQuality            uint16
+	WReserved           uint16
+	BTimeStampSpecified int32
+	DwReserved          uint32
+	FtTimeStamp         windows.Filetime
+}
+
+// utf16PtrArray converts item IDs to the LPCWSTR array of a call
+func utf16PtrArray(values []string) ([]*uint16, error) {
+	pointers := make([]*uint16, len(values))
+	for i, value := range values {
+		p, err := syscall.UTF16PtrFromString(value)
+		if err != nil {
+			return nil, err
+		}
+		pointers[i] = p
+	}
+	return pointers, nil
+}
+
+func (v *IOPCItemIO) Read(pszItemIDs []string, pdwMaxAge []uint32) ([]*ItemState, []int32, error) {
+	count := len(pszItemIDs)
+	if count == 0 {
+		return nil, nil, nil
+	}
+	if len(pdwMaxAge) != count {
+		return nil, nil, syscall.Errno(windows.E_INVALIDARG)
+	}
+	itemIDs, err := utf16PtrArray(pszItemIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	var pValues, pQualities, pTimestamps, pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		v.Vtbl().Read,
+		uintptr(unsafe.Pointer(v.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&itemIDs[0])),
+		uintptr(unsafe.Pointer(&pdwMaxAge[0])),
+		uintptr(unsafe.Pointer(&pValues)),
+		uintptr(unsafe.Pointer(&pQualities)),
+		uintptr(unsafe.Pointer(&pTimestamps)),
+		uintptr(unsafe.Pointer(&pErrors)),
+	)
+	if int32(r0) < 0 {
+		return nil, nil, syscall.Errno(r0)
+	}
+	states, errors := readItemValues(count, pValues, pQualities, pTimestamps, pErrors)
+	return states, errors, nil
+}
+
+// readItemValues converts the values, qualities, timestamps and errors returned by the Read methods of OPC DA 3.0
+// and frees them, the state of a failed item is nil
+func readItemValues(count int, pValues, pQualities, pTimestamps, pErrors unsafe.Pointer) ([]*ItemState, []int32) {
+	defer func() {
+		CoTaskMemFree(pValues)
+		CoTaskMemFree(pQualities)
+		CoTaskMemFree(pTimestamps)
+		CoTaskMemFree(pErrors)
+	}()
+	states := make([]*ItemState, count)
+	errors := make([]int32, count)
+	for i := 0; i < count; i++ {
+		errNo := *(*int32)(unsafe.Pointer(uintptr(pErrors) + uintptr(i)*4))
+		variant := (*VARIANT)(unsafe.Pointer(uintptr(pValues) + uintptr(i)*unsafe.Sizeof(VARIANT{})))
+		var value interface{}
+		if errNo >= 0 {
+			var err error
+			value, err = variant.Value()
+			if err != nil {
+				errNo = BadVarType
+			}
+		}
+		if errNo >= 0 {
+			timestamp := *(*windows.Filetime)(unsafe.Pointer(uintptr(pTimestamps) + uintptr(i)*unsafe.Sizeof(windows.Filetime{})))
+			states[i] = &ItemState{
+				Value:     value,
+				Quality:   *(*Quality)(unsafe.Pointer(uintptr(pQualities) + uintptr(i)*2)),
+				Timestamp: time.Unix(0, timestamp.Nanoseconds()),
+			}
+		}
+		variant.Clear()
+		errors[i] = errNo
+	}
+	return states, errors
+}
+
+func (v *IOPCItemIO) WriteVQT(pszItemIDs []string, pItemVQT []TagOPCITEMVQT) ([]int32, error) {
+	count := len(pszItemIDs)
+	if count == 0 {
+		return nil, nil
+	}
+	if len(pItemVQT) != count {
+		return nil, syscall.Errno(windows.E_INVALIDARG)
+	}
+	itemIDs, err := utf16PtrArray(pszItemIDs)
+	if err != nil {
+		return nil, err
+	}
+	var pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		v.Vtbl().WriteVQT,
+		uintptr(unsafe.Pointer(v.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&itemIDs[0])),
+		uintptr(unsafe.Pointer(&pItemVQT[0])),
+		uintptr(unsafe.Pointer(&pErrors)),
+	)
+	if int32(r0) < 0 {
+		return nil, syscall.Errno(r0)
+	}
+	defer CoTaskMemFree(pErrors)
+	errors := make([]int32, count)
+	for i := 0; i < count; i++ {
+		errors[i] = *(*int32)(unsafe.Pointer(uintptr(pErrors) + uintptr(i)*4))
+	}
+	return errors, nil
+}
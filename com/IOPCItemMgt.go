@@ -1,19 +1,13 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
 	"syscall"
 	"unsafe"
-
-	"golang.org/x/sys/windows"
 )
 
-var IID_IOPCItemMgt = windows.GUID{
-	Data1: 0x39c13a54,
-	Data2: 0x011e,
-	Data3: 0x11d0,
-	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
-}
-
 type IOPCItemMgtVtbl struct {
 	IUnknownVtbl
 	AddItems         uintptr
@@ -53,13 +47,6 @@ type TagOPCITEMRESULT struct {
 	PBlob          *byte
 }
 
-type TagOPCITEMRESULTStruct struct {
-	Server       uint32
-	NativeType   uint16
-	AccessRights uint32
-	Blob         []byte
-}
-
 func (result *TagOPCITEMRESULT) CloneToStruct() TagOPCITEMRESULTStruct {
 	var blob []byte
 	if result.DwBlobSize > 0 {
@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
@@ -7,13 +10,6 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-var IID_IOPCItemProperties = windows.GUID{
-	Data1: 0x39c13a72,
-	Data2: 0x011e,
-	Data3: 0x11d0,
-	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
-}
-
 type IOPCItemProperties struct {
 	*IUnknown
 }
@@ -107,7 +103,11 @@ func (v *IOPCItemProperties) GetItemProperties(szItemID string, propertyIDs []ui
 		variant := *(*VARIANT)(unsafe.Pointer(uintptr(pData) + uintptr(i)*unsafe.Sizeof(VARIANT{})))
 		errNo := *(*int32)(unsafe.Pointer(uintptr(pErrors) + uintptr(i)*4))
 		if errNo >= 0 {
-			ppvData[i] = variant.Value()
+			value, err := variant.Value()
+			if err != nil {
+				errNo = BadVarType
+			}
+			ppvData[i] = value
 		}
 		variant.Clear()
 		ppErrors[i] = int32(errNo)
@@ -0,0 +1,178 @@
+//go:build windows
+// +build windows
+
+package com
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type IOPCItemSamplingMgtVtbl struct {
+	IUnknownVtbl
+	SetItemSamplingRate   uintptr
+	GetItemSamplingRate   uintptr
+	ClearItemSamplingRate uintptr
+	SetItemBufferEnable   uintptr
+	GetItemBufferEnable   uintptr
+}
+
+type IOPCItemSamplingMgt struct {
+	*IUnknown
+}
+
+func (sl *IOPCItemSamplingMgt) Vtbl() *IOPCItemSamplingMgtVtbl {
+	return (*IOPCItemSamplingMgtVtbl)(unsafe.Pointer(sl.IUnknown.LpVtbl))
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE SetItemSamplingRate(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][in] */ DWORD *pdwRequestedSamplingRate,
+//            /* [size_is][size_is][out] */ DWORD **ppdwRevisedSamplingRate,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCItemSamplingMgt) SetItemSamplingRate(serverHandles []uint32, requestedSamplingRates []uint32) ([]uint32, []int32, error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return nil, nil, nil
+	}
+	if len(requestedSamplingRates) != count {
+		return nil, nil, syscall.Errno(windows.E_INVALIDARG)
+	}
+	var pRevisedSamplingRates, pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().SetItemSamplingRate,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&requestedSamplingRates[0])),
+		uintptr(unsafe.Pointer(&pRevisedSamplingRates)),
+		uintptr(unsafe.Pointer(&pErrors)))
+	if int32(r0) < 0 {
+		return nil, nil, syscall.Errno(r0)
+	}
+	return readSamplingRates(count, pRevisedSamplingRates), readErrors(count, pErrors), nil
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE GetItemSamplingRate(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][size_is][out] */ DWORD **ppdwSamplingRate,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCItemSamplingMgt) GetItemSamplingRate(serverHandles []uint32) ([]uint32, []int32, error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return nil, nil, nil
+	}
+	var pSamplingRates, pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().GetItemSamplingRate,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&pSamplingRates)),
+		uintptr(unsafe.Pointer(&pErrors)))
+	if int32(r0) < 0 {
+		return nil, nil, syscall.Errno(r0)
+	}
+	return readSamplingRates(count, pSamplingRates), readErrors(count, pErrors), nil
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE ClearItemSamplingRate(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCItemSamplingMgt) ClearItemSamplingRate(serverHandles []uint32) ([]int32, error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return nil, nil
+	}
+	var pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().ClearItemSamplingRate,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&pErrors)))
+	if int32(r0) < 0 {
+		return nil, syscall.Errno(r0)
+	}
+	return readErrors(count, pErrors), nil
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE SetItemBufferEnable(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][in] */ BOOL *pbEnable,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCItemSamplingMgt) SetItemBufferEnable(serverHandles []uint32, enable []bool) ([]int32, error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return nil, nil
+	}
+	if len(enable) != count {
+		return nil, syscall.Errno(windows.E_INVALIDARG)
+	}
+	bEnable := make([]int32, count)
+	for i, e := range enable {
+		bEnable[i] = BoolToComBOOL(e)
+	}
+	var pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().SetItemBufferEnable,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&bEnable[0])),
+		uintptr(unsafe.Pointer(&pErrors)))
+	if int32(r0) < 0 {
+		return nil, syscall.Errno(r0)
+	}
+	return readErrors(count, pErrors), nil
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE GetItemBufferEnable(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][size_is][out] */ BOOL **ppbEnable,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCItemSamplingMgt) GetItemBufferEnable(serverHandles []uint32) ([]bool, []int32, error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return nil, nil, nil
+	}
+	var pEnable, pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().GetItemBufferEnable,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&pEnable)),
+		uintptr(unsafe.Pointer(&pErrors)))
+	if int32(r0) < 0 {
+		return nil, nil, syscall.Errno(r0)
+	}
+	defer CoTaskMemFree(pEnable)
+	enable := make([]bool, count)
+	for i := 0; i < count; i++ {
+		enable[i] = *(*int32)(unsafe.Pointer(uintptr(pEnable) + uintptr(i)*4)) != 0
+	}
+	return enable, readErrors(count, pErrors), nil
+}
+
+// readSamplingRates copies and frees a DWORD array of sampling rates
+func readSamplingRates(count int, pSamplingRates unsafe.Pointer) []uint32 {
+	defer CoTaskMemFree(pSamplingRates)
+	rates := make([]uint32, count)
+	for i := 0; i < count; i++ {
+		rates[i] = *(*uint32)(unsafe.Pointer(uintptr(pSamplingRates) + uintptr(i)*4))
+	}
+	return rates
+}
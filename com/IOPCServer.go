@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
@@ -8,13 +11,6 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-var IID_IOPCServer = windows.GUID{
-	Data1: 0x39c13a4d,
-	Data2: 0x011e,
-	Data3: 0x11d0,
-	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
-}
-
 type IOPCServer struct {
 	*IUnknown
 }
@@ -72,15 +68,6 @@ func (v *IOPCServer) AddGroup(
 	return
 }
 
-func BoolToComBOOL(b bool) int32 {
-	if b {
-		return 1
-	}
-	return 0
-}
-
-type OPCServerState uint32
-
 type OPCSERVERSTATUS struct {
 	FtStartTime      windows.Filetime
 	FtCurrentTime    windows.Filetime
@@ -95,20 +82,6 @@ type OPCSERVERSTATUS struct {
 	SzVendorInfo     *uint16
 }
 
-type ServerStatus struct {
-	StartTime      time.Time
-	CurrentTime    time.Time
-	LastUpdateTime time.Time
-	ServerState    OPCServerState
-	GroupCount     uint32
-	BandWidth      uint32
-	MajorVersion   uint16
-	MinorVersion   uint16
-	BuildNumber    uint16
-	Reserved       uint16
-	VendorInfo     string
-}
-
 func (v *IOPCServer) GetStatus() (status *ServerStatus, err error) {
 	var pStatus *OPCSERVERSTATUS
 	r0, _, _ := syscall.SyscallN(
@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
@@ -7,20 +10,6 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-var CLSID_OpcServerList = windows.GUID{
-	Data1: 0x13486D51,
-	Data2: 0x4821,
-	Data3: 0x11D2,
-	Data4: [8]byte{0xA4, 0x94, 0x3C, 0xB3, 0x06, 0xC1, 0x00, 0x00},
-}
-
-var IID_IOPCServerList = windows.GUID{
-	Data1: 0x13486D50,
-	Data2: 0x4821,
-	Data3: 0x11D2,
-	Data4: [8]byte{0xA4, 0x94, 0x3C, 0xB3, 0x06, 0xC1, 0x00, 0x00},
-}
-
 type IOPCServerListVtbl struct {
 	IUnknownVtbl
 	EnumClassesOfCategories uintptr
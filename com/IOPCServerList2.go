@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
@@ -7,13 +10,6 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-var IID_IOPCServerList2 = windows.GUID{
-	Data1: 0x9DD0B56C,
-	Data2: 0xAD9E,
-	Data3: 0x43ee,
-	Data4: [8]byte{0x83, 0x05, 0x48, 0x7F, 0x31, 0x88, 0xBF, 0x7A},
-}
-
 type IOPCServerList2 struct {
 	*IUnknown
 }
@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
@@ -8,13 +11,6 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-var IID_IOPCSyncIO = windows.GUID{
-	Data1: 0x39c13a52,
-	Data2: 0x011e,
-	Data3: 0x11d0,
-	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
-}
-
 type IOPCSyncIOVtbl struct {
 	IUnknownVtbl
 	Read  uintptr
@@ -29,8 +25,6 @@ func (sl *IOPCSyncIO) Vtbl() *IOPCSyncIOVtbl {
 	return (*IOPCSyncIOVtbl)(unsafe.Pointer(sl.IUnknown.LpVtbl))
 }
 
-type OPCDATASOURCE int32
-
 type TagOPCITEMSTATE struct {
 	HClient    uint32
 	FTimestamp windows.Filetime
@@ -39,13 +33,6 @@ type TagOPCITEMSTATE struct {
 	VDataValue VARIANT
 }
 
-type ItemState struct {
-	Value        interface{}
-	Quality      uint16
-	Timestamp    time.Time
-	ClientHandle int32
-}
-
 func (sl *IOPCSyncIO) Read(source OPCDATASOURCE, serverHandles []uint32) ([]*ItemState, []int32, error) {
 	var pErrors unsafe.Pointer
 	var pValues unsafe.Pointer
@@ -71,10 +58,18 @@ func (sl *IOPCSyncIO) Read(source OPCDATASOURCE, serverHandles []uint32) ([]*Ite
 	for i := 0; i < count; i++ {
 		errNo := *(*int32)(unsafe.Pointer(uintptr(pErrors) + uintptr(i)*4))
 		value := *(*TagOPCITEMSTATE)(unsafe.Pointer(uintptr(pValues) + uintptr(i)*unsafe.Sizeof(TagOPCITEMSTATE{})))
+		var data interface{}
+		if errNo >= 0 {
+			var err error
+			data, err = value.VDataValue.Value()
+			if err != nil {
+				errNo = BadVarType
+			}
+		}
 		if errNo >= 0 {
 			returnValues[i] = &ItemState{
-				Value:        value.VDataValue.Value(),
-				Quality:      value.WQuality,
+				Value:        data,
+				Quality:      Quality(value.WQuality),
 				Timestamp:    time.Unix(0, value.FTimestamp.Nanoseconds()),
 				ClientHandle: int32(value.HClient),
 			}
@@ -0,0 +1,90 @@
+//go:build windows
+// +build windows
+
+package com
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+type IOPCSyncIO2Vtbl struct {
+	IOPCSyncIOVtbl
+	ReadMaxAge uintptr
+	WriteVQT   uintptr
+}
+
+type IOPCSyncIO2 struct {
+	*IUnknown
+}
+
+func (sl *IOPCSyncIO2) Vtbl() *IOPCSyncIO2Vtbl {
+	return (*IOPCSyncIO2Vtbl)(unsafe.Pointer(sl.IUnknown.LpVtbl))
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE ReadMaxAge(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][in] */ DWORD *pdwMaxAge,
+//            /* [size_is][size_is][out] */ VARIANT **ppvValues,
+//            /* [size_is][size_is][out] */ WORD **ppwQualities,
+//            /* [size_is][size_is][out] */ FILETIME **ppftTimeStamps,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCSyncIO2) ReadMaxAge(serverHandles []uint32, maxAges []uint32) ([]*ItemState, []int32, error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return nil, nil, nil
+	}
+	if len(maxAges) != count {
+		return nil, nil, syscall.Errno(windows.E_INVALIDARG)
+	}
+	var pValues, pQualities, pTimestamps, pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().ReadMaxAge,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&maxAges[0])),
+		uintptr(unsafe.Pointer(&pValues)),
+		uintptr(unsafe.Pointer(&pQualities)),
+		uintptr(unsafe.Pointer(&pTimestamps)),
+		uintptr(unsafe.Pointer(&pErrors)),
+	)
+	if int32(r0) < 0 {
+		return nil, nil, syscall.Errno(r0)
+	}
+	states, errors := readItemValues(count, pValues, pQualities, pTimestamps, pErrors)
+	return states, errors, nil
+}
+
+//        virtual HRESULT STDMETHODCALLTYPE WriteVQT(
+//            /* [in] */ DWORD dwCount,
+//            /* [size_is][in] */ OPCHANDLE *phServer,
+//            /* [size_is][in] */ OPCITEMVQT *pItemVQT,
+//            /* [size_is][size_is][out] */ HRESULT **ppErrors) = 0;
+
+func (sl *IOPCSyncIO2) WriteVQT(serverHandles []uint32, items []TagOPCITEMVQT) ([]int32, error) {
+	count := len(serverHandles)
+	if count == 0 {
+		return nil, nil
+	}
+	if len(items) != count {
+		return nil, syscall.Errno(windows.E_INVALIDARG)
+	}
+	var pErrors unsafe.Pointer
+	r0, _, _ := syscall.SyscallN(
+		sl.Vtbl().WriteVQT,
+		uintptr(unsafe.Pointer(sl.IUnknown)),
+		uintptr(count),
+		uintptr(unsafe.Pointer(&serverHandles[0])),
+		uintptr(unsafe.Pointer(&items[0])),
+		uintptr(unsafe.Pointer(&pErrors)),
+	)
+	if int32(r0) < 0 {
+		return nil, syscall.Errno(r0)
+	}
+	return readErrors(count, pErrors), nil
+}
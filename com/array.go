@@ -0,0 +1,207 @@
+package com
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// SafeArrayBound is the number of elements and the lower bound of a dimension of a SAFEARRAY
+type SafeArrayBound struct {
+	Elements   uint32
+	LowerBound int32
+}
+
+// Array is a SAFEARRAY of more than one dimension. One dimensional SAFEARRAYs are decoded as plain slices.
+type Array struct {
+	// Dims are the bounds of the dimensions from the leftmost, which is the first index of SafeArrayGetElement
+	Dims []SafeArrayBound
+	// Values is a slice of the element type, for example []float64, that holds the elements in the SAFEARRAY
+	// order: the leftmost dimension varies fastest
+	Values interface{}
+}
+
+var arrayElementTypes = map[reflect.Type]VT{
+	reflect.TypeOf(false):                      VT_BOOL,
+	reflect.TypeOf(int8(0)):                    VT_I1,
+	reflect.TypeOf(uint8(0)):                   VT_UI1,
+	reflect.TypeOf(int16(0)):                   VT_I2,
+	reflect.TypeOf(uint16(0)):                  VT_UI2,
+	reflect.TypeOf(int32(0)):                   VT_I4,
+	reflect.TypeOf(uint32(0)):                  VT_UI4,
+	reflect.TypeOf(int64(0)):                   VT_I8,
+	reflect.TypeOf(uint64(0)):                  VT_UI8,
+	reflect.TypeOf(int(0)):                     VT_INT,
+	reflect.TypeOf(uint(0)):                    VT_UINT,
+	reflect.TypeOf(float32(0)):                 VT_R4,
+	reflect.TypeOf(float64(0)):                 VT_R8,
+	reflect.TypeOf(""):                         VT_BSTR,
+	reflect.TypeOf(time.Time{}):                VT_DATE,
+	reflect.TypeOf(Currency(0)):                VT_CY,
+	reflect.TypeOf(Decimal{}):                  VT_DECIMAL,
+	reflect.TypeOf(SCode(0)):                   VT_ERROR,
+	reflect.TypeOf((*interface{})(nil)).Elem(): VT_VARIANT,
+}
+
+// NewArray converts nested slices such as [][]float64 to an Array with lower bounds of zero, nested[i][j] becomes the
+// element (i, j). The slices must be rectangular.
+func NewArray(nested interface{}) (Array, error) {
+	v := reflect.ValueOf(nested)
+	if v.Kind() != reflect.Slice {
+		return Array{}, fmt.Errorf("com: %T is not a slice", nested)
+	}
+	// the shape is taken from the first element of every level
+	var dims []SafeArrayBound
+	t := v.Type()
+	for e := v; ; {
+		dims = append(dims, SafeArrayBound{Elements: uint32(e.Len())})
+		t = t.Elem()
+		if t.Kind() != reflect.Slice {
+			break
+		}
+		if e.Len() > 0 {
+			e = e.Index(0)
+		} else {
+			e = reflect.Zero(t)
+		}
+	}
+	if _, ok := arrayElementTypes[t]; !ok {
+		return Array{}, fmt.Errorf("com: unsupported array element type %s", t)
+	}
+	a := Array{Dims: dims}
+	values := reflect.MakeSlice(reflect.SliceOf(t), a.Len(), a.Len())
+	var fill func(v reflect.Value, dim int, offset int, stride int) error
+	fill = func(v reflect.Value, dim int, offset int, stride int) error {
+		if v.Len() != int(dims[dim].Elements) {
+			return errors.New("com: the slices of an array must be rectangular")
+		}
+		for i := 0; i < v.Len(); i++ {
+			if dim == len(dims)-1 {
+				values.Index(offset + i*stride).Set(v.Index(i))
+				continue
+			}
+			err := fill(v.Index(i), dim+1, offset+i*stride, stride*int(dims[dim].Elements))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := fill(v, 0, 0, 1)
+	if err != nil {
+		return Array{}, err
+	}
+	a.Values = values.Interface()
+	return a, nil
+}
+
+// ElementType returns the VARIANT type of the elements
+func (a Array) ElementType() (VT, error) {
+	t := reflect.TypeOf(a.Values)
+	if t == nil || t.Kind() != reflect.Slice {
+		return 0, fmt.Errorf("com: array values of type %T are not a slice", a.Values)
+	}
+	vt, ok := arrayElementTypes[t.Elem()]
+	if !ok {
+		return 0, fmt.Errorf("com: unsupported array element type %s", t.Elem())
+	}
+	return vt, nil
+}
+
+// Shape returns the number of elements of every dimension
+func (a Array) Shape() []int {
+	shape := make([]int, len(a.Dims))
+	for i, d := range a.Dims {
+		shape[i] = int(d.Elements)
+	}
+	return shape
+}
+
+// Len returns the number of elements
+func (a Array) Len() int {
+	if len(a.Dims) == 0 {
+		return 0
+	}
+	n := 1
+	for _, d := range a.Dims {
+		n *= int(d.Elements)
+	}
+	return n
+}
+
+// Validate checks that Values holds the elements of Dims
+func (a Array) Validate() error {
+	if len(a.Dims) == 0 {
+		return errors.New("com: array without dimensions")
+	}
+	if _, err := a.ElementType(); err != nil {
+		return err
+	}
+	if n := reflect.ValueOf(a.Values).Len(); n != a.Len() {
+		return fmt.Errorf("com: array holds %d values but its bounds describe %d", n, a.Len())
+	}
+	return nil
+}
+
+// Offset returns the position in Values of the element at indices, which include the lower bounds
+func (a Array) Offset(indices ...int32) (int, error) {
+	if len(indices) != len(a.Dims) {
+		return 0, fmt.Errorf("com: %d indices for an array of %d dimensions", len(indices), len(a.Dims))
+	}
+	offset, stride := 0, 1
+	for i, d := range a.Dims {
+		index := int64(indices[i]) - int64(d.LowerBound)
+		if index < 0 || index >= int64(d.Elements) {
+			return 0, fmt.Errorf("com: index %d of dimension %d is out of bounds", indices[i], i)
+		}
+		offset += int(index) * stride
+		stride *= int(d.Elements)
+	}
+	return offset, nil
+}
+
+// At returns the element at indices, which include the lower bounds
+func (a Array) At(indices ...int32) (interface{}, error) {
+	offset, err := a.Offset(indices...)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(a.Values).Index(offset).Interface(), nil
+}
+
+// Nested returns the elements as nested slices, for example [][]float64, where nested[i][j] is the element at
+// (Dims[0].LowerBound+i, Dims[1].LowerBound+j)
+func (a Array) Nested() (interface{}, error) {
+	err := a.Validate()
+	if err != nil {
+		return nil, err
+	}
+	values := reflect.ValueOf(a.Values)
+	types := make([]reflect.Type, len(a.Dims))
+	t := values.Type()
+	for i := len(a.Dims) - 1; i >= 0; i-- {
+		types[i] = t
+		t = reflect.SliceOf(t)
+	}
+	var build func(dim int, offset int, stride int) reflect.Value
+	build = func(dim int, offset int, stride int) reflect.Value {
+		n := int(a.Dims[dim].Elements)
+		out := reflect.MakeSlice(types[dim], n, n)
+		for i := 0; i < n; i++ {
+			if dim == len(a.Dims)-1 {
+				out.Index(i).Set(values.Index(offset + i*stride))
+			} else {
+				out.Index(i).Set(build(dim+1, offset+i*stride, stride*n))
+			}
+		}
+		return out
+	}
+	return build(0, 0, 1).Interface(), nil
+}
+
+// IsNestedSlice reports whether value is a slice of slices, which NewArray converts
+func IsNestedSlice(value interface{}) bool {
+	t := reflect.TypeOf(value)
+	return t != nil && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Slice
+}
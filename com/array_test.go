@@ -0,0 +1,85 @@
+package com
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewArray(t *testing.T) {
+	a, err := NewArray([][]float64{{1, 2, 3}, {4, 5, 6}})
+	require.NoError(t, err)
+	assert.Equal(t, []SafeArrayBound{{Elements: 2}, {Elements: 3}}, a.Dims)
+	// the leftmost dimension varies fastest
+	assert.Equal(t, []float64{1, 4, 2, 5, 3, 6}, a.Values)
+	assert.Equal(t, []int{2, 3}, a.Shape())
+	assert.Equal(t, 6, a.Len())
+	vt, err := a.ElementType()
+	require.NoError(t, err)
+	assert.Equal(t, VT_R8, vt)
+	v, err := a.At(1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 6.0, v)
+	nested, err := a.Nested()
+	require.NoError(t, err)
+	assert.Equal(t, [][]float64{{1, 2, 3}, {4, 5, 6}}, nested)
+
+	a, err = NewArray([][][]interface{}{{{1, "a"}}, {{nil, 2.5}}})
+	require.NoError(t, err)
+	assert.Equal(t, []SafeArrayBound{{Elements: 2}, {Elements: 1}, {Elements: 2}}, a.Dims)
+	assert.Equal(t, []interface{}{1, nil, "a", 2.5}, a.Values)
+
+	a, err = NewArray([][]int32{{}, {}})
+	require.NoError(t, err)
+	assert.Equal(t, []SafeArrayBound{{Elements: 2}, {Elements: 0}}, a.Dims)
+	assert.Equal(t, []int32{}, a.Values)
+
+	_, err = NewArray([][]int32{{1, 2}, {3}})
+	assert.Error(t, err)
+	_, err = NewArray([][]struct{}{{{}}})
+	assert.Error(t, err)
+	_, err = NewArray(1)
+	assert.Error(t, err)
+}
+
+func TestArrayBounds(t *testing.T) {
+	a := Array{
+		Dims:   []SafeArrayBound{{Elements: 2, LowerBound: 1}, {Elements: 2, LowerBound: -1}},
+		Values: []string{"a", "b", "c", "d"},
+	}
+	require.NoError(t, a.Validate())
+	for _, c := range []struct {
+		indices []int32
+		want    string
+	}{
+		{[]int32{1, -1}, "a"},
+		{[]int32{2, -1}, "b"},
+		{[]int32{1, 0}, "c"},
+		{[]int32{2, 0}, "d"},
+	} {
+		v, err := a.At(c.indices...)
+		require.NoError(t, err)
+		assert.Equal(t, c.want, v, "%v", c.indices)
+	}
+	_, err := a.At(0, 0)
+	assert.Error(t, err)
+	_, err = a.At(1)
+	assert.Error(t, err)
+	nested, err := a.Nested()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "c"}, {"b", "d"}}, nested)
+
+	assert.Error(t, Array{Dims: []SafeArrayBound{{Elements: 3}}, Values: []string{"a"}}.Validate())
+	assert.Error(t, Array{Values: []string{}}.Validate())
+	assert.Error(t, Array{Dims: []SafeArrayBound{{Elements: 1}}, Values: []struct{}{{}}}.Validate())
+	assert.Error(t, Array{Dims: []SafeArrayBound{{Elements: 1}}, Values: 1}.Validate())
+}
+
+func TestIsNestedSlice(t *testing.T) {
+	assert.True(t, IsNestedSlice([][]int32{}))
+	assert.True(t, IsNestedSlice([][]uint8{}))
+	assert.False(t, IsNestedSlice([]int32{}))
+	assert.False(t, IsNestedSlice([]interface{}{[]int32{}}))
+	assert.False(t, IsNestedSlice(nil))
+}
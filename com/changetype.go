@@ -0,0 +1,417 @@
+package com
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTypeMismatch is returned by ChangeType for a conversion VariantChangeType rejects with DISP_E_TYPEMISMATCH
+	ErrTypeMismatch = errors.New("com: type mismatch")
+	// ErrOverflow is returned by ChangeType for a value out of the range of the requested type, DISP_E_OVERFLOW
+	ErrOverflow = errors.New("com: overflow")
+)
+
+// the layouts of the dates ChangeType formats and parses, the first one that fits is used for formatting
+const (
+	dateTimeLayout = "2006-01-02 15:04:05"
+	dateLayout     = "2006-01-02"
+	timeLayout     = "15:04:05"
+)
+
+var parsedDateLayouts = []string{dateTimeLayout, "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05", time.RFC3339Nano,
+	dateLayout, timeLayout}
+
+// integerRanges are the ranges of the integer types
+var integerRanges = map[VT][2]*big.Int{
+	VT_I1:   {big.NewInt(math.MinInt8), big.NewInt(math.MaxInt8)},
+	VT_I2:   {big.NewInt(math.MinInt16), big.NewInt(math.MaxInt16)},
+	VT_I4:   {big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)},
+	VT_INT:  {big.NewInt(math.MinInt32), big.NewInt(math.MaxInt32)},
+	VT_I8:   {big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)},
+	VT_UI1:  {big.NewInt(0), big.NewInt(math.MaxUint8)},
+	VT_UI2:  {big.NewInt(0), big.NewInt(math.MaxUint16)},
+	VT_UI4:  {big.NewInt(0), big.NewInt(math.MaxUint32)},
+	VT_UINT: {big.NewInt(0), big.NewInt(math.MaxUint32)},
+	VT_UI8:  {big.NewInt(0), new(big.Int).SetUint64(math.MaxUint64)},
+}
+
+// ChangeType converts value to the VARIANT type vt with the rules of VariantChangeType without flags:
+//   - numbers are rounded half to even to integers, a value out of the range of vt fails with ErrOverflow
+//   - true is -1, it sets every bit of an unsigned integer, and every number but zero is true
+//   - strings are parsed as numbers, "True" and "False" or dates. Numbers, booleans and dates are formatted without
+//     a locale, a boolean as "-1" or "0" and a date as "2006-01-02 15:04:05" without the zero date or time of day.
+//   - a DATE converts to and from the days since 1899-12-30 like OLE automation dates
+//   - nil, which is VT_EMPTY, converts to the zero value of every type but VT_ERROR
+//   - an array converts element by element to an array of the same shape, the elements of a VT_VARIANT array are
+//     kept
+//
+// A VT_BYREF value is converted to the type of the value it refers to. VT_EMPTY keeps value. The conversions
+// VariantChangeType does not make, such as VT_ERROR to another type or a scalar to an array, fail with
+// ErrTypeMismatch.
+func ChangeType(value interface{}, vt VT) (interface{}, error) {
+	if vt == VT_EMPTY {
+		return value, nil
+	}
+	from, err := variantType(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTypeMismatch, err)
+	}
+	switch {
+	case from&VT_BYREF != 0:
+		return ChangeType(reflect.ValueOf(value).Elem().Interface(), vt)
+	case from == vt:
+		return value, nil
+	case vt&VT_ARRAY != 0 && from&VT_ARRAY != 0:
+		return changeArrayType(value, vt&^VT_ARRAY)
+	case vt&^VT_TYPEMASK != 0 || from&VT_ARRAY != 0:
+		return nil, typeMismatch(value, vt)
+	}
+	return changeScalarType(value, vt)
+}
+
+func typeMismatch(value interface{}, vt VT) error {
+	return fmt.Errorf("%w: %T to VARIANT type 0x%x", ErrTypeMismatch, value, uint16(vt))
+}
+
+// changeArrayType converts the elements of a slice, nested slices or an Array to vt, the result has the form of value
+func changeArrayType(value interface{}, vt VT) (interface{}, error) {
+	t, ok := elementTypes[vt]
+	if !ok {
+		return nil, typeMismatch(value, VT_ARRAY|vt)
+	}
+	array, isArray := value.(Array)
+	if !isArray {
+		var err error
+		array, err = toArray(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrTypeMismatch, err)
+		}
+	}
+	in := reflect.ValueOf(array.Values)
+	out := reflect.MakeSlice(reflect.SliceOf(t), in.Len(), in.Len())
+	for i := 0; i < in.Len(); i++ {
+		element := in.Index(i).Interface()
+		if vt != VT_VARIANT {
+			var err error
+			element, err = ChangeType(element, vt)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if element != nil {
+			out.Index(i).Set(reflect.ValueOf(element))
+		}
+	}
+	switch {
+	case isArray:
+		return Array{Dims: array.Dims, Values: out.Interface()}, nil
+	case IsNestedSlice(value):
+		return Array{Dims: array.Dims, Values: out.Interface()}.Nested()
+	}
+	return out.Interface(), nil
+}
+
+//gocyclo:ignore
+func changeScalarType(value interface{}, vt VT) (interface{}, error) {
+	if _, ok := value.(SCode); ok || vt == VT_ERROR {
+		return nil, typeMismatch(value, vt)
+	}
+	switch vt {
+	case VT_BSTR:
+		return formatValue(value)
+	case VT_BOOL:
+		return toBool(value)
+	case VT_DATE:
+		return toDate(value)
+	case VT_R4, VT_R8:
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		if vt == VT_R8 {
+			return f, nil
+		}
+		if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%w: %v to VT_R4", ErrOverflow, value)
+		}
+		return float32(f), nil
+	}
+	r, err := toRat(value)
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := value.(bool); ok && b {
+		if bounds, ok := integerRanges[vt]; ok && bounds[0].Sign() == 0 {
+			// VARIANT_TRUE is -1, an unsigned integer keeps its bits
+			r.SetInt(bounds[1])
+		}
+	}
+	switch vt {
+	case VT_CY:
+		c, err := NewCurrency(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v to VT_CY", ErrOverflow, value)
+		}
+		return c, nil
+	case VT_DECIMAL:
+		d, err := NewDecimal(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v to VT_DECIMAL", ErrOverflow, value)
+		}
+		return d, nil
+	}
+	bounds, ok := integerRanges[vt]
+	if !ok {
+		return nil, typeMismatch(value, vt)
+	}
+	n := roundScaled(r, 0)
+	if n.Cmp(bounds[0]) < 0 || n.Cmp(bounds[1]) > 0 {
+		return nil, fmt.Errorf("%w: %v to VARIANT type 0x%x", ErrOverflow, value, uint16(vt))
+	}
+	switch vt {
+	case VT_I1:
+		return int8(n.Int64()), nil
+	case VT_I2:
+		return int16(n.Int64()), nil
+	case VT_I4:
+		return int32(n.Int64()), nil
+	case VT_INT:
+		return int(n.Int64()), nil
+	case VT_I8:
+		return n.Int64(), nil
+	case VT_UI1:
+		return uint8(n.Uint64()), nil
+	case VT_UI2:
+		return uint16(n.Uint64()), nil
+	case VT_UI4:
+		return uint32(n.Uint64()), nil
+	case VT_UINT:
+		return uint(n.Uint64()), nil
+	}
+	return n.Uint64(), nil
+}
+
+// toRat returns the exact number of a value, true is -1 and a date the days since 1899-12-30
+//
+//gocyclo:ignore
+func toRat(value interface{}) (*big.Rat, error) {
+	switch v := value.(type) {
+	case nil:
+		return new(big.Rat), nil
+	case bool:
+		if v {
+			return big.NewRat(-1, 1), nil
+		}
+		return new(big.Rat), nil
+	case int8:
+		return big.NewRat(int64(v), 1), nil
+	case int16:
+		return big.NewRat(int64(v), 1), nil
+	case int32:
+		return big.NewRat(int64(v), 1), nil
+	case int64:
+		return big.NewRat(v, 1), nil
+	case int:
+		return big.NewRat(int64(v), 1), nil
+	case uint8:
+		return big.NewRat(int64(v), 1), nil
+	case uint16:
+		return big.NewRat(int64(v), 1), nil
+	case uint32:
+		return big.NewRat(int64(v), 1), nil
+	case uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v)), nil
+	case uint:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(uint64(v))), nil
+	case float32:
+		return floatRat(float64(v))
+	case float64:
+		return floatRat(v)
+	case Currency:
+		return v.Rat(), nil
+	case Decimal:
+		return v.Rat(), nil
+	case time.Time:
+		date, err := encodeOLEDate(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrOverflow, err)
+		}
+		return floatRat(date)
+	case string:
+		return parseNumber(v)
+	}
+	return nil, typeMismatch(value, VT_R8)
+}
+
+func floatRat(f float64) (*big.Rat, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("%w: %v", ErrOverflow, f)
+	}
+	return new(big.Rat).SetFloat64(f), nil
+}
+
+// toFloat returns the nearest float64 of a value, NaN and infinities are kept
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	r, err := toRat(value)
+	if err != nil {
+		return 0, err
+	}
+	f, _ := r.Float64()
+	if math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%w: %v to VT_R8", ErrOverflow, value)
+	}
+	return f, nil
+}
+
+// parseNumber parses a decimal number with an optional exponent, or a hexadecimal "&H" or octal "&O" integer
+func parseNumber(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 2 && s[0] == '&' {
+		base := 0
+		switch s[1] {
+		case 'H', 'h':
+			base = 16
+		case 'O', 'o':
+			base = 8
+		}
+		if n, ok := new(big.Int).SetString(s[2:], base); ok && base != 0 && n.Sign() >= 0 {
+			return new(big.Rat).SetInt(n), nil
+		}
+		return nil, fmt.Errorf("%w: %q is not a number", ErrTypeMismatch, s)
+	}
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(s), "e")
+	if !isDecimal(strings.TrimLeft(mantissa, "+-"), true) ||
+		hasExponent && !isDecimal(strings.TrimLeft(exponent, "+-"), false) {
+		return nil, fmt.Errorf("%w: %q is not a number", ErrTypeMismatch, s)
+	}
+	if !hasExponent {
+		r, _ := new(big.Rat).SetString(s)
+		return r, nil
+	}
+	// the exponent is applied by ParseFloat, big.Rat would compute any power of ten
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && math.IsInf(f, 0) {
+		return nil, fmt.Errorf("%w: %q", ErrOverflow, s)
+	}
+	return new(big.Rat).SetFloat64(f), nil
+}
+
+// isDecimal reports whether s holds digits with at most one decimal point and at least one digit
+func isDecimal(s string, point bool) bool {
+	digits := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] >= '0' && s[i] <= '9':
+			digits++
+		case s[i] == '.' && point:
+			point = false
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case float32:
+		return v != 0, nil
+	case float64:
+		return v != 0, nil
+	case string:
+		s := strings.TrimSpace(v)
+		switch {
+		case strings.EqualFold(s, "true"):
+			return true, nil
+		case strings.EqualFold(s, "false"):
+			return false, nil
+		}
+	}
+	r, err := toRat(value)
+	if err != nil {
+		return false, err
+	}
+	return r.Sign() != 0, nil
+}
+
+func toDate(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range parsedDateLayouts {
+			t, err := time.Parse(layout, s)
+			if err != nil {
+				continue
+			}
+			if layout == timeLayout {
+				// a time of day is on the zero date
+				t = oleEpoch.Add(t.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)))
+			}
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("%w: %q is not a date", ErrTypeMismatch, v)
+	}
+	date, err := toFloat(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := decodeOLEDate(date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrOverflow, err)
+	}
+	return t, nil
+}
+
+// formatValue formats a value like VarBstrFromR8 and the other VarBstrFrom functions without a locale
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case bool:
+		if v {
+			return "-1", nil
+		}
+		return "0", nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'G', 7, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'G', 15, 64), nil
+	case time.Time:
+		return formatDate(v), nil
+	case Currency, Decimal:
+		return fmt.Sprint(v), nil
+	}
+	if _, err := toRat(value); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(value), nil
+}
+
+// formatDate leaves out the time of day at midnight and the date on 1899-12-30, the zero date
+func formatDate(t time.Time) string {
+	t = t.UTC()
+	switch {
+	case t.Year() == oleEpoch.Year() && t.YearDay() == oleEpoch.YearDay():
+		return t.Format(timeLayout)
+	case t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0:
+		return t.Format(dateLayout)
+	}
+	return t.Format(dateTimeLayout)
+}
@@ -0,0 +1,73 @@
+package com
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeType(t *testing.T) {
+	date := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	variant := interface{}(int16(7))
+	cases := []struct {
+		value interface{}
+		vt    VT
+		want  interface{}
+	}{
+		{2.5, VT_I4, int32(2)}, {3.5, VT_I4, int32(4)}, {-2.5, VT_I2, int16(-2)}, {float32(1.5), VT_UI1, uint8(2)},
+		{int32(300), VT_I2, int16(300)}, {uint64(math.MaxUint64), VT_UI8, uint64(math.MaxUint64)},
+		{int64(math.MinInt64), VT_R8, float64(math.MinInt64)}, {int8(-3), VT_INT, -3}, {uint16(9), VT_UINT, uint(9)},
+		{true, VT_I4, int32(-1)}, {true, VT_UI1, uint8(0xff)}, {true, VT_UI8, uint64(math.MaxUint64)},
+		{false, VT_R8, 0.0}, {0.1, VT_BOOL, true}, {int32(0), VT_BOOL, false},
+		{2.5, VT_BSTR, "2.5"}, {1e20, VT_BSTR, "1E+20"}, {float32(0.1), VT_BSTR, "0.1"}, {int32(-12), VT_BSTR, "-12"},
+		{true, VT_BSTR, "-1"}, {false, VT_BSTR, "0"}, {Currency(125000), VT_BSTR, "12.5"},
+		{date, VT_BSTR, "2024-05-06 12:00:00"}, {date.Truncate(24 * time.Hour), VT_BSTR, "2024-05-06"},
+		{" 42 ", VT_I4, int32(42)}, {"-1.5e2", VT_R8, -150.0}, {"&HFF", VT_UI1, uint8(255)}, {"&o17", VT_I2, int16(15)},
+		{"0.125", VT_R4, float32(0.125)}, {"True", VT_BOOL, true}, {"false", VT_BOOL, false}, {"2", VT_BOOL, true},
+		{"12.34567", VT_CY, Currency(123457)}, {"1.50", VT_DECIMAL, Decimal{Scale: 1, Lo64: 15}},
+		{"2024-05-06 12:00:00", VT_DATE, date}, {"2024-05-06T12:00:00Z", VT_DATE, date},
+		{"06:00:00", VT_DATE, time.Date(1899, 12, 30, 6, 0, 0, 0, time.UTC)},
+		{45418.5, VT_DATE, date}, {date, VT_R8, 45418.5}, {date, VT_I4, int32(45418)},
+		{nil, VT_I4, int32(0)}, {nil, VT_BSTR, ""}, {nil, VT_BOOL, false}, {nil, VT_DATE, oleEpoch},
+		{&variant, VT_R8, 7.0}, {SCode(-1), VT_ERROR, SCode(-1)}, {"text", VT_EMPTY, "text"},
+		{[]float64{1.5, 2.5}, VT_ARRAY | VT_I4, []int32{2, 2}},
+		{[]interface{}{int8(1), "2", nil}, VT_ARRAY | VT_BSTR, []string{"1", "2", ""}},
+		{[]int32{1}, VT_ARRAY | VT_VARIANT, []interface{}{int32(1)}},
+		{[][]int16{{1, 2}, {3, 4}}, VT_ARRAY | VT_R8, [][]float64{{1, 2}, {3, 4}}},
+		{Array{Dims: []SafeArrayBound{{Elements: 1, LowerBound: 1}, {Elements: 2}}, Values: []bool{true, false}},
+			VT_ARRAY | VT_I2,
+			Array{Dims: []SafeArrayBound{{Elements: 1, LowerBound: 1}, {Elements: 2}}, Values: []int16{-1, 0}}},
+	}
+	for _, c := range cases {
+		got, err := ChangeType(c.value, c.vt)
+		if assert.NoError(t, err, "%#v to 0x%x", c.value, c.vt) {
+			assert.Equal(t, c.want, got, "%#v to 0x%x", c.value, c.vt)
+		}
+	}
+}
+
+func TestChangeTypeErrors(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		vt    VT
+		err   error
+	}{
+		{int32(128), VT_I1, ErrOverflow}, {-0.6, VT_UI4, ErrOverflow}, {math.NaN(), VT_I4, ErrOverflow},
+		{math.Inf(1), VT_CY, ErrOverflow}, {1e300, VT_R4, ErrOverflow}, {"1e400", VT_R8, ErrOverflow},
+		{uint64(math.MaxUint64), VT_I8, ErrOverflow}, {1e20, VT_CY, ErrOverflow}, {1e10, VT_DATE, ErrOverflow},
+		{"abc", VT_I4, ErrTypeMismatch}, {"1/3", VT_R8, ErrTypeMismatch}, {"0x10", VT_I4, ErrTypeMismatch},
+		{"&H", VT_I4, ErrTypeMismatch}, {"1.2.3", VT_R8, ErrTypeMismatch}, {"yes", VT_BOOL, ErrTypeMismatch},
+		{"tomorrow", VT_DATE, ErrTypeMismatch}, {SCode(1), VT_I4, ErrTypeMismatch}, {int32(1), VT_ERROR, ErrTypeMismatch},
+		{nil, VT_ERROR, ErrTypeMismatch}, {int32(1), VT_ARRAY | VT_I4, ErrTypeMismatch},
+		{[]int32{1}, VT_I4, ErrTypeMismatch}, {[]int32{300}, VT_ARRAY | VT_I1, ErrOverflow},
+		{int32(1), VT_UNKNOWN, ErrTypeMismatch}, {struct{}{}, VT_I4, ErrTypeMismatch},
+	}
+	for _, c := range cases {
+		_, err := ChangeType(c.value, c.vt)
+		require.Error(t, err, "%#v to 0x%x", c.value, c.vt)
+		assert.ErrorIs(t, err, c.err, "%#v to 0x%x", c.value, c.vt)
+	}
+}
@@ -1,7 +1,9 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
-	"errors"
 	"fmt"
 	"strings"
 	"syscall"
@@ -14,17 +16,16 @@ var (
 	modOle32                    = windows.NewLazySystemDLL("ole32.dll")
 	procCoCreateInstanceEx      = modOle32.NewProc("CoCreateInstanceEx")
 	procCoInitializeSecurity    = modOle32.NewProc("CoInitializeSecurity")
+	procCoSetProxyBlanket       = modOle32.NewProc("CoSetProxyBlanket")
+	procCoTaskMemAlloc          = modOle32.NewProc("CoTaskMemAlloc")
 	modOleaut32                 = windows.NewLazySystemDLL("oleaut32.dll")
 	procVariantClear            = modOleaut32.NewProc("VariantClear")
 	procVariantTimeToSystemTime = modOleaut32.NewProc("VariantTimeToSystemTime")
 	procSystemTimeToVariantTime = modOleaut32.NewProc("SystemTimeToVariantTime")
-	procSafeArrayGetVarType     = modOleaut32.NewProc("SafeArrayGetVartype")
 	procSafeArrayGetLBound      = modOleaut32.NewProc("SafeArrayGetLBound")
 	procSafeArrayGetUBound      = modOleaut32.NewProc("SafeArrayGetUBound")
-	procSafeArrayGetElement     = modOleaut32.NewProc("SafeArrayGetElement")
 	procSysAllocStringLen       = modOleaut32.NewProc("SysAllocStringLen")
-	procSafeArrayCreateVector   = modOleaut32.NewProc("SafeArrayCreateVector")
-	procSafeArrayPutElement     = modOleaut32.NewProc("SafeArrayPutElement")
+	procSafeArrayCreate         = modOleaut32.NewProc("SafeArrayCreate")
 	procSysFreeString           = modOleaut32.NewProc("SysFreeString")
 )
 
@@ -35,6 +36,19 @@ func CoTaskMemFree(pv unsafe.Pointer) {
 	windows.CoTaskMemFree(pv)
 }
 
+// CoTaskMemAlloc allocates memory that the callee of a COM call may free or reallocate
+func CoTaskMemAlloc(size uintptr) unsafe.Pointer {
+	r0, _, _ := procCoTaskMemAlloc.Call(size)
+	return allocation(r0)
+}
+
+// allocation converts the address returned by a COM allocator to a pointer. The memory is allocated by COM outside
+// the Go heap, so the garbage collector neither moves nor frees it and the conversion is safe, this is the only place
+// where such addresses are converted.
+func allocation(address uintptr) unsafe.Pointer {
+	return unsafe.Pointer(address)
+}
+
 type CLSCTX uint32
 
 const (
@@ -62,6 +76,39 @@ type COAUTHINFO struct {
 	DwCapabilities       uint32
 }
 
+// NewCOAUTHINFO returns the NTLM authentication information for an explicit account.
+// The result must stay reachable while the proxies it is applied to are in use.
+func NewCOAUTHINFO(domain, user, password string, authnLevel, impLevel uint32) *COAUTHINFO {
+	userPtr, userLength := utf16PtrLength(user)
+	domainPtr, domainLength := utf16PtrLength(domain)
+	passwordPtr, passwordLength := utf16PtrLength(password)
+	return &COAUTHINFO{
+		DwAuthnSvc:           RPC_C_AUTHN_WINNT,
+		DwAuthzSvc:           RPC_C_AUTHZ_NONE,
+		DwAuthnLevel:         authnLevel,
+		DwImpersonationLevel: impLevel,
+		PAuthIdentityData: &COAUTHIDENTITY{
+			User:           userPtr,
+			UserLength:     userLength,
+			Domain:         domainPtr,
+			DomainLength:   domainLength,
+			Password:       passwordPtr,
+			PasswordLength: passwordLength,
+			Flags:          SEC_WINNT_AUTH_IDENTITY_UNICODE,
+		},
+		DwCapabilities: EOAC_NONE,
+	}
+}
+
+// utf16PtrLength returns s as a UTF-16 string and its length without the terminating zero
+func utf16PtrLength(s string) (*uint16, uint32) {
+	chars, err := windows.UTF16FromString(s)
+	if err != nil {
+		chars = []uint16{0}
+	}
+	return &chars[0], uint32(len(chars) - 1)
+}
+
 type COSERVERINFO struct {
 	DwReserved1 uint32
 	PwszName    *uint16
@@ -91,14 +138,6 @@ func VariantClear(pvarg *VARIANT) (err error) {
 	return
 }
 
-func safeArrayGetVarType(safeArray *SafeArray) (varType uint16, err error) {
-	r0, _, _ := syscall.SyscallN(procSafeArrayGetVarType.Addr(), uintptr(unsafe.Pointer(safeArray)), uintptr(unsafe.Pointer(&varType)))
-	if r0 != 0 {
-		err = syscall.Errno(r0)
-	}
-	return
-}
-
 func safeArrayGetLBound(safeArray *SafeArray, dimension uint32) (lowerBound int32, err error) {
 	r0, _, _ := syscall.SyscallN(
 		procSafeArrayGetLBound.Addr(),
@@ -125,50 +164,25 @@ func safeArrayGetUBound(safeArray *SafeArray, dimension uint32) (upperBound int3
 	return
 }
 
-func safeArrayGetElement(safeArray *SafeArray, index int32, pv unsafe.Pointer) (err error) {
-	r0, _, _ := syscall.SyscallN(
-		procSafeArrayGetElement.Addr(),
-		uintptr(unsafe.Pointer(safeArray)),
-		uintptr(unsafe.Pointer(&index)),
-		uintptr(pv))
-	if int32(r0) < 0 {
-		err = syscall.Errno(r0)
-	}
-	return
-}
-
 func SysAllocStringLen(v string) (ss *uint16) {
 	u := windows.StringToUTF16(v)
 	pss, _, _ := procSysAllocStringLen.Call(uintptr(unsafe.Pointer(&u[0])), uintptr(len(u)-1))
-	ss = (*uint16)(unsafe.Pointer(pss))
+	ss = (*uint16)(allocation(pss))
 	return
 }
 
-func safeArrayCreateVector(variantType VT, lowerBound int32, length uint32) (safearray *SafeArray, err error) {
+// safeArrayCreate creates a SAFEARRAY with the bounds of every dimension from the leftmost
+func safeArrayCreate(variantType VT, bounds []SafeArrayBound) (*SafeArray, error) {
 	r0, _, err := syscall.SyscallN(
-		procSafeArrayCreateVector.Addr(),
+		procSafeArrayCreate.Addr(),
 		uintptr(variantType),
-		uintptr(lowerBound),
-		uintptr(length),
+		uintptr(len(bounds)),
+		uintptr(unsafe.Pointer(&bounds[0])),
 	)
-	if !errors.Is(err, windows.ERROR_SUCCESS) {
+	if r0 == 0 {
 		return nil, err
 	}
-	safearray = (*SafeArray)(unsafe.Pointer(r0))
-	return safearray, nil
-}
-
-func safeArrayPutElement(safearray *SafeArray, index int64, element uintptr) (err error) {
-	r0, _, _ := syscall.SyscallN(
-		procSafeArrayPutElement.Addr(),
-		uintptr(unsafe.Pointer(safearray)),
-		uintptr(unsafe.Pointer(&index)),
-		element,
-	)
-	if r0 != 0 {
-		err = syscall.Errno(r0)
-	}
-	return
+	return (*SafeArray)(allocation(r0)), nil
 }
 
 func SysFreeString(v *uint16) (err error) {
@@ -183,6 +197,12 @@ func SysFreeString(v *uint16) (err error) {
 }
 
 func MakeCOMObjectEx(hostname string, serverLocation CLSCTX, requestedClass *windows.GUID, requestedInterface *windows.GUID) (*IUnknown, error) {
+	return MakeCOMObjectWithAuth(hostname, serverLocation, requestedClass, requestedInterface, nil)
+}
+
+// MakeCOMObjectWithAuth is MakeCOMObjectEx activating a remote server with authInfo, authInfo may be nil.
+// The returned proxy does not inherit authInfo, CoSetProxyBlanket must be called on it.
+func MakeCOMObjectWithAuth(hostname string, serverLocation CLSCTX, requestedClass *windows.GUID, requestedInterface *windows.GUID, authInfo *COAUTHINFO) (*IUnknown, error) {
 	reqInterface := MULTI_QI{
 		PIID: requestedInterface,
 		PItf: nil,
@@ -191,7 +211,8 @@ func MakeCOMObjectEx(hostname string, serverLocation CLSCTX, requestedClass *win
 	var serverInfoPtr *COSERVERINFO = nil
 	if serverLocation != CLSCTX_LOCAL_SERVER {
 		serverInfoPtr = &COSERVERINFO{
-			PwszName: windows.StringToUTF16Ptr(hostname),
+			PwszName:  windows.StringToUTF16Ptr(hostname),
+			PAuthInfo: authInfo,
 		}
 	}
 	err := CoCreateInstanceEx(requestedClass, nil, serverLocation, serverInfoPtr, 1, &reqInterface)
@@ -204,6 +225,23 @@ func MakeCOMObjectEx(hostname string, serverLocation CLSCTX, requestedClass *win
 	return reqInterface.PItf, nil
 }
 
+// CoSetProxyBlanket sets the authentication information used by the calls made through proxy
+func CoSetProxyBlanket(proxy *IUnknown, authInfo *COAUTHINFO) error {
+	r0, _, _ := syscall.SyscallN(procCoSetProxyBlanket.Addr(),
+		uintptr(unsafe.Pointer(proxy)),
+		uintptr(authInfo.DwAuthnSvc),
+		uintptr(authInfo.DwAuthzSvc),
+		uintptr(unsafe.Pointer(authInfo.PwszServerPrincName)),
+		uintptr(authInfo.DwAuthnLevel),
+		uintptr(authInfo.DwImpersonationLevel),
+		uintptr(unsafe.Pointer(authInfo.PAuthIdentityData)),
+		uintptr(authInfo.DwCapabilities))
+	if r0 != 0 {
+		return syscall.Errno(r0)
+	}
+	return nil
+}
+
 func IsLocal(host string) bool {
 	if host == "" || host == "localhost" || host == "127.0.0.1" {
 		return true
@@ -215,28 +253,10 @@ func IsLocal(host string) bool {
 	return strings.ToLower(name) == strings.ToLower(host)
 }
 
-// Initialize initialize COM with COINIT_MULTITHREADED
-func Initialize() error {
-	config := DefaultInitConfig()
-	return InitializeWithConfig(config)
-}
-
-type InitConfig struct {
-	AuthLevel    uint32
-	ImpLevel     uint32
-	Capabilities uint32
-}
-
-func DefaultInitConfig() *InitConfig {
-	return &InitConfig{
-		AuthLevel:    RPC_C_AUTHN_LEVEL_NONE,
-		ImpLevel:     RPC_C_IMP_LEVEL_IMPERSONATE,
-		Capabilities: EOAC_NONE,
-	}
-}
-
+// InitializeWithConfig initializes COM on the calling thread in the apartment of config and sets the security of the
+// process
 func InitializeWithConfig(config *InitConfig) error {
-	err := windows.CoInitializeEx(0, windows.COINIT_MULTITHREADED)
+	err := windows.CoInitializeEx(0, coInit(config.Apartment))
 	if err != nil {
 		return fmt.Errorf("call CoInitializeEx error: %s", err)
 	}
@@ -253,20 +273,6 @@ func Uninitialize() {
 	windows.CoUninitialize()
 }
 
-func IsEqualGUID(guid1 *windows.GUID, guid2 *windows.GUID) bool {
-	return guid1.Data1 == guid2.Data1 &&
-		guid1.Data2 == guid2.Data2 &&
-		guid1.Data3 == guid2.Data3 &&
-		guid1.Data4[0] == guid2.Data4[0] &&
-		guid1.Data4[1] == guid2.Data4[1] &&
-		guid1.Data4[2] == guid2.Data4[2] &&
-		guid1.Data4[3] == guid2.Data4[3] &&
-		guid1.Data4[4] == guid2.Data4[4] &&
-		guid1.Data4[5] == guid2.Data4[5] &&
-		guid1.Data4[6] == guid2.Data4[6] &&
-		guid1.Data4[7] == guid2.Data4[7]
-}
-
 func CoInitializeSecurity(authnLevel, impLevel, capabilities uint32) (err error) {
 	cAuthSvc := int32(-1)
 	r0, _, _ := procCoInitializeSecurity.Call(
@@ -72,8 +72,22 @@ const (
 	E_PENDING      = 0x8000000A
 
 	CO_E_CLASSSTRING = 0x800401F3
+
+	DISP_E_BADVARTYPE = 0x80020008
 )
 
+// SAFEARRAY features
+const (
+	FADF_HAVEVARTYPE = 0x0080
+	FADF_BSTR        = 0x0100
+	FADF_UNKNOWN     = 0x0200
+	FADF_DISPATCH    = 0x0400
+	FADF_VARIANT     = 0x0800
+)
+
+// BadVarType is DISP_E_BADVARTYPE as the item error of the values that cannot be decoded
+const BadVarType int32 = DISP_E_BADVARTYPE - 1<<32
+
 // authentication level constants
 const (
 	RPC_C_AUTHN_LEVEL_DEFAULT       uint32 = 0
@@ -85,6 +99,15 @@ const (
 	RPC_C_AUTHN_LEVEL_PKT_PRIVACY   uint32 = 6
 )
 
+// authentication service constants
+const (
+	RPC_C_AUTHN_WINNT uint32 = 10
+	RPC_C_AUTHZ_NONE  uint32 = 0
+)
+
+// SEC_WINNT_AUTH_IDENTITY_UNICODE marks the strings of COAUTHIDENTITY as UTF-16
+const SEC_WINNT_AUTH_IDENTITY_UNICODE uint32 = 2
+
 // impersonation level constants
 const (
 	RPC_C_IMP_LEVEL_DEFAULT     uint32 = 0
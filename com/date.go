@@ -0,0 +1,65 @@
+package com
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// oleEpoch is day zero of OLE automation dates
+var oleEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// the range of the dates oleaut32 converts, from the year 100 to the end of the year 9999
+const (
+	minOLEDate = -657434
+	maxOLEDate = 2958466
+)
+
+const secondsPerDay = 24 * 60 * 60
+
+// OLEDateToTime converts an OLE automation date, the fraction is the time of day even for negative dates
+func OLEDateToTime(date float64) time.Time {
+	days := math.Trunc(date)
+	fraction := math.Abs(date - days)
+	ms := math.Round(fraction * 24 * 60 * 60 * 1000)
+	return oleEpoch.AddDate(0, 0, int(days)).Add(time.Duration(ms) * time.Millisecond)
+}
+
+// TimeToOLEDate converts t to an OLE automation date, it is the inverse of OLEDateToTime
+func TimeToOLEDate(t time.Time) float64 {
+	t = t.UTC()
+	// the days are counted in seconds, a time.Duration overflows 292 years from the epoch
+	seconds := t.Unix() - oleEpoch.Unix()
+	days := seconds / secondsPerDay
+	if seconds%secondsPerDay < 0 {
+		days--
+	}
+	midnight := oleEpoch.AddDate(0, 0, int(days))
+	fraction := float64(t.Sub(midnight)) / float64(24*time.Hour)
+	if days < 0 && fraction > 0 {
+		return float64(days) - fraction
+	}
+	return float64(days) + fraction
+}
+
+// decodeOLEDate converts an OLE automation date and rejects the dates oleaut32 does not convert
+func decodeOLEDate(date float64) (time.Time, error) {
+	if !(date > minOLEDate-1 && date < maxOLEDate) {
+		return time.Time{}, fmt.Errorf("com: date %v out of range", date)
+	}
+	t := OLEDateToTime(date)
+	if t.Year() > 9999 {
+		// the last millisecond of 9999 is rounded up
+		return time.Time{}, fmt.Errorf("com: date %v out of range", date)
+	}
+	return t, nil
+}
+
+// encodeOLEDate converts t to an OLE automation date and rejects the times oleaut32 does not convert
+func encodeOLEDate(t time.Time) (float64, error) {
+	date := TimeToOLEDate(t)
+	if !(date > minOLEDate-1 && date < maxOLEDate) {
+		return 0, fmt.Errorf("com: time %v out of the DATE range", t)
+	}
+	return date, nil
+}
@@ -0,0 +1,160 @@
+package com
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Currency is a VT_CY value, a 64 bit integer scaled by 10000
+type Currency int64
+
+// Decimal is a VT_DECIMAL value, a 96 bit unsigned integer Hi32:Lo64 with a sign, divided by 10 to the power of Scale.
+// Scale is between 0 and 28.
+type Decimal struct {
+	Scale    uint8
+	Negative bool
+	Hi32     uint32
+	Lo64     uint64
+}
+
+// SCode is a VT_ERROR value, servers use DISP_E_PARAMNOTFOUND (0x80020004) for a missing value
+type SCode int32
+
+const (
+	currencyScale   = 4
+	maxDecimalScale = 28
+	decimalBits     = 96
+)
+
+var (
+	errCurrencyRange = errors.New("com: value out of the currency range")
+	errDecimalRange  = errors.New("com: value out of the decimal range")
+)
+
+// NewCurrency converts r to a Currency, extra decimal places are rounded half to even like VarCyFromR8 does
+func NewCurrency(r *big.Rat) (Currency, error) {
+	n := roundScaled(r, currencyScale)
+	if !n.IsInt64() {
+		return 0, errCurrencyRange
+	}
+	return Currency(n.Int64()), nil
+}
+
+// Rat returns the exact value of c
+func (c Currency) Rat() *big.Rat {
+	return big.NewRat(int64(c), 10000)
+}
+
+// Float64 returns the nearest float64 of c
+func (c Currency) Float64() float64 {
+	f, _ := c.Rat().Float64()
+	return f
+}
+
+// String formats c without trailing zeros, for example "-12.5"
+func (c Currency) String() string {
+	s := formatScaled(big.NewInt(int64(c)), currencyScale)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// NewDecimal converts r to a Decimal with the smallest scale that represents it exactly. A value that needs more
+// than 28 decimal places or 96 bits is rounded half to even at the largest scale that fits.
+func NewDecimal(r *big.Rat) (Decimal, error) {
+	for scale := 0; scale <= maxDecimalScale; scale++ {
+		n := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(scale)))
+		if !n.IsInt() {
+			continue
+		}
+		if n.Num().BitLen() > decimalBits {
+			// a larger scale only needs more bits
+			break
+		}
+		return newDecimal(n.Num(), scale), nil
+	}
+	for scale := maxDecimalScale; scale >= 0; scale-- {
+		n := roundScaled(r, scale)
+		if n.BitLen() <= decimalBits {
+			return newDecimal(n, scale), nil
+		}
+	}
+	return Decimal{}, errDecimalRange
+}
+
+func newDecimal(n *big.Int, scale int) Decimal {
+	abs := new(big.Int).Abs(n)
+	lo := new(big.Int).And(abs, new(big.Int).SetUint64(^uint64(0)))
+	return Decimal{
+		Scale:    uint8(scale),
+		Negative: n.Sign() < 0,
+		Hi32:     uint32(new(big.Int).Rsh(abs, 64).Uint64()),
+		Lo64:     lo.Uint64(),
+	}
+}
+
+// mantissa returns the signed integer of d before the scale is applied
+func (d Decimal) mantissa() *big.Int {
+	n := new(big.Int).SetUint64(uint64(d.Hi32))
+	n.Lsh(n, 64)
+	n.Or(n, new(big.Int).SetUint64(d.Lo64))
+	if d.Negative {
+		n.Neg(n)
+	}
+	return n
+}
+
+// Rat returns the exact value of d
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.mantissa(), pow10(int(d.Scale)))
+}
+
+// Float64 returns the nearest float64 of d
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// String formats d with Scale decimal places, for example "1.50"
+func (d Decimal) String() string {
+	return formatScaled(d.mantissa(), int(d.Scale))
+}
+
+func (s SCode) String() string {
+	return fmt.Sprintf("SCode(0x%08X)", uint32(s))
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundScaled returns r multiplied by 10^scale and rounded half to even
+func roundScaled(r *big.Rat, scale int) *big.Int {
+	n := new(big.Int).Mul(r.Num(), pow10(scale))
+	q, m := new(big.Int).QuoRem(n, r.Denom(), new(big.Int))
+	m.Abs(m).Lsh(m, 1)
+	if c := m.Cmp(r.Denom()); c > 0 || c == 0 && q.Bit(0) == 1 {
+		if n.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// formatScaled formats n divided by 10^scale with scale decimal places
+func formatScaled(n *big.Int, scale int) string {
+	digits := new(big.Int).Abs(n).String()
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	if scale > 0 {
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if n.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
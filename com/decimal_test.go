@@ -0,0 +1,73 @@
+package com
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rat(t *testing.T, s string) *big.Rat {
+	r, ok := new(big.Rat).SetString(s)
+	require.True(t, ok, s)
+	return r
+}
+
+func TestCurrency(t *testing.T) {
+	for _, c := range []struct {
+		value string
+		want  Currency
+		text  string
+	}{
+		{"0", 0, "0"},
+		{"12.5", 125000, "12.5"},
+		{"-0.0001", -1, "-0.0001"},
+		{"1.00005", 10000, "1"},
+		{"1.00015", 10002, "1.0002"},
+		{"-1.00015", -10002, "-1.0002"},
+		{"922337203685477.5807", 1<<63 - 1, "922337203685477.5807"},
+	} {
+		got, err := NewCurrency(rat(t, c.value))
+		require.NoError(t, err, c.value)
+		assert.Equal(t, c.want, got, c.value)
+		assert.Equal(t, c.text, got.String(), c.value)
+	}
+	_, err := NewCurrency(rat(t, "922337203685477.5808"))
+	assert.Error(t, err)
+	assert.Equal(t, 0, Currency(-125000).Rat().Cmp(rat(t, "-12.5")))
+	assert.Equal(t, 12.5, Currency(125000).Float64())
+}
+
+func TestDecimal(t *testing.T) {
+	for _, c := range []struct {
+		value string
+		want  Decimal
+		text  string
+	}{
+		{"0", Decimal{}, "0"},
+		{"1.5", Decimal{Scale: 1, Lo64: 15}, "1.5"},
+		{"-0.001", Decimal{Scale: 3, Negative: true, Lo64: 1}, "-0.001"},
+		{"79228162514264337593543950335", Decimal{Hi32: 0xFFFFFFFF, Lo64: 0xFFFFFFFFFFFFFFFF}, "79228162514264337593543950335"},
+		{"1/3", Decimal{Scale: 28, Hi32: 0xAC544CA, Lo64: 0x14B700CB05555555}, "0.3333333333333333333333333333"},
+		{"2/3", Decimal{Scale: 28, Hi32: 0x158A8994, Lo64: 0x296E01960AAAAAAB}, "0.6666666666666666666666666667"},
+	} {
+		got, err := NewDecimal(rat(t, c.value))
+		require.NoError(t, err, c.value)
+		assert.Equal(t, c.want, got, c.value)
+		assert.Equal(t, c.text, got.String(), c.value)
+	}
+	_, err := NewDecimal(rat(t, "79228162514264337593543950336"))
+	assert.Error(t, err)
+	// an exact value that needs too many bits is rounded at a smaller scale
+	d, err := NewDecimal(rat(t, "7922816251426433759354395033.51"))
+	require.NoError(t, err)
+	assert.Equal(t, "7922816251426433759354395033.5", d.String())
+	assert.Equal(t, "1.50", Decimal{Scale: 2, Lo64: 150}.String())
+	assert.Equal(t, 0, Decimal{Scale: 2, Negative: true, Lo64: 150}.Rat().Cmp(rat(t, "-1.5")))
+	assert.Equal(t, -1.5, Decimal{Scale: 2, Negative: true, Lo64: 150}.Float64())
+}
+
+func TestSCode(t *testing.T) {
+	assert.Equal(t, "SCode(0x80020004)", SCode(-2147352572).String())
+}
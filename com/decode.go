@@ -0,0 +1,36 @@
+package com
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedType is the cause of a DecodeError for a VARIANT type, or a SAFEARRAY element type, that is not
+// decoded
+var ErrUnsupportedType = errors.New("com: unsupported VARIANT type")
+
+// DecodeError is returned for a VARIANT that cannot be decoded, the item that holds it gets the error instead of a
+// value
+type DecodeError struct {
+	// VT is the type of the VARIANT, for a VARIANT nested in an array or a reference it is the type of the nested one
+	VT  VT
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("com: decode VARIANT of type 0x%x: %v", uint16(e.VT), e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// NewDecodeError wraps err in a DecodeError for a VARIANT of type vt, an err that already is a DecodeError is
+// returned unchanged so that it keeps the type of the innermost VARIANT
+func NewDecodeError(vt VT, err error) error {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return err
+	}
+	return &DecodeError{VT: vt, Err: err}
+}
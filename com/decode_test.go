@@ -0,0 +1,20 @@
+package com
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeError(t *testing.T) {
+	err := NewDecodeError(VT_ARRAY|VT_VARIANT, ErrUnsupportedType)
+	assert.EqualError(t, err, "com: decode VARIANT of type 0x200c: com: unsupported VARIANT type")
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	// the type of the innermost VARIANT is kept
+	nested := NewDecodeError(VT_BYREF|VT_VARIANT, fmt.Errorf("element 2: %w", err))
+	var decodeErr *DecodeError
+	assert.True(t, errors.As(nested, &decodeErr))
+	assert.Equal(t, VT_ARRAY|VT_VARIANT, decodeErr.VT)
+}
@@ -0,0 +1,221 @@
+package com
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Apartment is the COM apartment the threads of an Executor enter
+type Apartment int
+
+const (
+	// MultiThreaded enters the multithreaded apartment, COINIT_MULTITHREADED
+	MultiThreaded Apartment = iota
+	// SingleThreaded enters a single-threaded apartment, COINIT_APARTMENTTHREADED. An STA executor has one thread
+	// since the objects of an STA may only be called on the thread that created them.
+	SingleThreaded
+)
+
+func (a Apartment) String() string {
+	switch a {
+	case MultiThreaded:
+		return "MTA"
+	case SingleThreaded:
+		return "STA"
+	default:
+		return fmt.Sprintf("Apartment(%d)", int(a))
+	}
+}
+
+// ErrExecutorClosed is returned by Executor.Do once the executor is closed
+var ErrExecutorClosed = errors.New("the executor is closed")
+
+// threads sets up the OS threads of an Executor, it initializes the COM runtime on Windows and is stubbed in tests
+type threads interface {
+	// initialize is called on a locked OS thread before it runs calls
+	initialize(apartment Apartment, config *InitConfig) error
+	// uninitialize is called on the same thread once the executor is closed
+	uninitialize()
+	// newLoop returns the message loop of an STA thread, it is called on the thread after initialize
+	newLoop() (messageLoop, error)
+}
+
+type executorOptions struct {
+	apartment    Apartment
+	apartmentSet bool
+	workers      int
+	config       *InitConfig
+}
+
+// ExecutorOption configures NewExecutor
+type ExecutorOption func(*executorOptions)
+
+// WithApartment selects the apartment the threads enter, the Apartment of the InitConfig by default
+func WithApartment(apartment Apartment) ExecutorOption {
+	return func(o *executorOptions) {
+		o.apartment = apartment
+		o.apartmentSet = true
+	}
+}
+
+// WithWorkers sets the number of threads of an MTA executor, 1 by default
+func WithWorkers(workers int) ExecutorOption {
+	return func(o *executorOptions) {
+		o.workers = workers
+	}
+}
+
+// WithInitConfig sets the security passed to CoInitializeSecurity, DefaultInitConfig by default. The security of a
+// process is set once, it is kept when COM was initialized before.
+func WithInitConfig(config *InitConfig) ExecutorOption {
+	return func(o *executorOptions) {
+		o.config = config
+	}
+}
+
+// Executor runs calls on OS threads that are locked to their goroutine and have COM initialized, so that the calls
+// do not depend on the thread the calling goroutine happens to run on. The thread of an STA executor runs a message
+// pump while it waits for calls, through which COM delivers the callbacks of the servers.
+type Executor struct {
+	apartment Apartment
+	calls     chan *executorCall
+	// lock guards closed, calls are sent under the read lock so that Close does not close calls under a sender
+	lock   sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type executorCall struct {
+	f     func() error
+	err   error
+	panic interface{}
+	done  chan struct{}
+}
+
+// NewExecutor starts the threads of an executor and initializes COM on each of them
+func NewExecutor(opts ...ExecutorOption) (*Executor, error) {
+	return newExecutor(comThreads{}, opts...)
+}
+
+func newExecutor(threads threads, opts ...ExecutorOption) (*Executor, error) {
+	options := &executorOptions{workers: 1}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.config == nil {
+		options.config = DefaultInitConfig()
+	}
+	if !options.apartmentSet {
+		options.apartment = options.config.Apartment
+	}
+	switch {
+	case options.apartment != MultiThreaded && options.apartment != SingleThreaded:
+		return nil, fmt.Errorf("unknown apartment %s", options.apartment)
+	case options.workers < 1:
+		return nil, fmt.Errorf("invalid number of workers %d", options.workers)
+	case options.apartment == SingleThreaded && options.workers != 1:
+		return nil, fmt.Errorf("an STA executor has one worker, got %d", options.workers)
+	}
+	e := &Executor{
+		apartment: options.apartment,
+		calls:     make(chan *executorCall),
+	}
+	started := make(chan error, options.workers)
+	for i := 0; i < options.workers; i++ {
+		e.wg.Add(1)
+		go e.work(threads, options, started)
+	}
+	var err error
+	for i := 0; i < options.workers; i++ {
+		if startErr := <-started; startErr != nil && err == nil {
+			err = startErr
+		}
+	}
+	if err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// work runs the calls on a locked thread until the executor is closed. The thread stays locked when the goroutine
+// exits, so that it is terminated instead of going back to the scheduler with the state of COM.
+func (e *Executor) work(threads threads, options *executorOptions, started chan<- error) {
+	defer e.wg.Done()
+	runtime.LockOSThread()
+	if err := threads.initialize(options.apartment, options.config); err != nil {
+		started <- err
+		return
+	}
+	defer threads.uninitialize()
+	if options.apartment == SingleThreaded {
+		loop, err := threads.newLoop()
+		if err != nil {
+			started <- err
+			return
+		}
+		defer loop.close()
+		started <- nil
+		pump := newMessagePump(loop)
+		// the calls are handed to the pump, the thread cannot wait for a channel and its messages at once
+		go func() {
+			for call := range e.calls {
+				pump.push(call)
+			}
+			pump.close()
+		}()
+		pump.run()
+		return
+	}
+	started <- nil
+	for call := range e.calls {
+		call.run()
+	}
+}
+
+func (c *executorCall) run() {
+	defer close(c.done)
+	defer func() {
+		// the panic is raised again on the calling goroutine
+		c.panic = recover()
+	}()
+	c.err = c.f()
+}
+
+// GetApartment get the apartment the threads of the executor entered
+func (e *Executor) GetApartment() Apartment {
+	return e.apartment
+}
+
+// Do runs f on a thread of the executor and returns its error, a panic of f is raised again in the caller. f must
+// not call Do on the same executor, the call would wait for a thread that is busy with f. Do returns
+// ErrExecutorClosed without running f once the executor is closed.
+func (e *Executor) Do(f func() error) error {
+	call := &executorCall{f: f, done: make(chan struct{})}
+	e.lock.RLock()
+	if e.closed {
+		e.lock.RUnlock()
+		return ErrExecutorClosed
+	}
+	e.calls <- call
+	e.lock.RUnlock()
+	<-call.done
+	if call.panic != nil {
+		panic(call.panic)
+	}
+	return call.err
+}
+
+// Close stops the executor. The calls already submitted are run, then COM is uninitialized on every thread before
+// Close returns. Close may be called more than once but not from a call of the executor.
+func (e *Executor) Close() {
+	e.lock.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.calls)
+	}
+	e.lock.Unlock()
+	e.wg.Wait()
+}
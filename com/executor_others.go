@@ -0,0 +1,44 @@
+//go:build !windows
+// +build !windows
+
+package com
+
+// comThreads does nothing on platforms without COM
+type comThreads struct{}
+
+func (comThreads) initialize(apartment Apartment, config *InitConfig) error {
+	return nil
+}
+
+func (comThreads) uninitialize() {
+}
+
+func (comThreads) newLoop() (messageLoop, error) {
+	return newChanLoop(), nil
+}
+
+// chanLoop is the message loop of a thread without window messages, it only waits to be woken up
+type chanLoop struct {
+	wakeup chan struct{}
+}
+
+func newChanLoop() *chanLoop {
+	return &chanLoop{wakeup: make(chan struct{}, 1)}
+}
+
+func (l *chanLoop) wait() {
+	<-l.wakeup
+}
+
+func (l *chanLoop) dispatch() {
+}
+
+func (l *chanLoop) wake() {
+	select {
+	case l.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (l *chanLoop) close() {
+}
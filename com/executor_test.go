@@ -0,0 +1,134 @@
+package com
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubThreads counts the threads an Executor initializes and fails them on demand
+type stubThreads struct {
+	initialized   atomic.Int32
+	uninitialized atomic.Int32
+	apartment     atomic.Int32
+	fail          error
+}
+
+func (s *stubThreads) initialize(apartment Apartment, config *InitConfig) error {
+	if s.fail != nil && s.initialized.Load() > 0 {
+		return s.fail
+	}
+	s.apartment.Store(int32(apartment))
+	s.initialized.Add(1)
+	return nil
+}
+
+func (s *stubThreads) uninitialize() {
+	s.uninitialized.Add(1)
+}
+
+func (s *stubThreads) newLoop() (messageLoop, error) {
+	return newStubLoop(), nil
+}
+
+func TestExecutor(t *testing.T) {
+	threads := &stubThreads{}
+	e, err := newExecutor(threads, WithWorkers(3))
+	require.NoError(t, err)
+	assert.Equal(t, MultiThreaded, e.GetApartment())
+	assert.Equal(t, int32(3), threads.initialized.Load())
+
+	errCall := errors.New("call")
+	assert.ErrorIs(t, e.Do(func() error { return errCall }), errCall)
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = e.Do(func() error { panic("boom") })
+	})
+
+	// the workers run calls concurrently
+	var wg sync.WaitGroup
+	var running atomic.Int32
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, e.Do(func() error {
+				running.Add(1)
+				<-release
+				return nil
+			}))
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return running.Load() == 3
+	}, 5*time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	e.Close()
+	e.Close()
+	assert.Equal(t, int32(3), threads.uninitialized.Load())
+	assert.ErrorIs(t, e.Do(func() error { return nil }), ErrExecutorClosed)
+}
+
+func TestExecutorClose(t *testing.T) {
+	threads := &stubThreads{}
+	e, err := newExecutor(threads, WithApartment(SingleThreaded))
+	require.NoError(t, err)
+	assert.Equal(t, int32(SingleThreaded), threads.apartment.Load())
+
+	// the calls submitted before Close run before the thread is uninitialized
+	block := make(chan struct{})
+	var ran atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, e.Do(func() error {
+				<-block
+				assert.Equal(t, int32(0), threads.uninitialized.Load())
+				ran.Add(1)
+				return nil
+			}))
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		e.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned with pending calls")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(block)
+	<-closed
+	wg.Wait()
+	assert.Equal(t, int32(5), ran.Load())
+	assert.Equal(t, int32(1), threads.uninitialized.Load())
+}
+
+func TestExecutorOptions(t *testing.T) {
+	_, err := newExecutor(&stubThreads{}, WithApartment(SingleThreaded), WithWorkers(2))
+	assert.Error(t, err)
+	_, err = newExecutor(&stubThreads{}, WithWorkers(0))
+	assert.Error(t, err)
+	_, err = newExecutor(&stubThreads{}, WithApartment(Apartment(5)))
+	assert.Error(t, err)
+	assert.Equal(t, "Apartment(5)", Apartment(5).String())
+
+	// the threads that started are uninitialized when another one fails
+	errInit := errors.New("init")
+	threads := &stubThreads{fail: errInit}
+	_, err = newExecutor(threads, WithWorkers(4))
+	assert.ErrorIs(t, err, errInit)
+	assert.Equal(t, threads.initialized.Load(), threads.uninitialized.Load())
+}
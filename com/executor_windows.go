@@ -0,0 +1,112 @@
+//go:build windows
+// +build windows
+
+package com
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	modUser32                       = windows.NewLazySystemDLL("user32.dll")
+	procMsgWaitForMultipleObjectsEx = modUser32.NewProc("MsgWaitForMultipleObjectsEx")
+	procPeekMessageW                = modUser32.NewProc("PeekMessageW")
+	procTranslateMessage            = modUser32.NewProc("TranslateMessage")
+	procDispatchMessageW            = modUser32.NewProc("DispatchMessageW")
+)
+
+const (
+	// rpcETooLate is returned by CoInitializeSecurity when the security of the process is already set
+	rpcETooLate = syscall.Errno(0x80010119)
+
+	qsAllInput         = 0x04ff
+	mwmoInputAvailable = 0x0004
+	pmRemove           = 0x0001
+)
+
+// coInit returns the CoInitializeEx flag of an apartment
+func coInit(apartment Apartment) uint32 {
+	if apartment == SingleThreaded {
+		return windows.COINIT_APARTMENTTHREADED
+	}
+	return windows.COINIT_MULTITHREADED
+}
+
+// comThreads initializes COM on the threads of an Executor
+type comThreads struct{}
+
+func (comThreads) initialize(apartment Apartment, config *InitConfig) error {
+	err := windows.CoInitializeEx(0, coInit(apartment))
+	if err != nil {
+		return fmt.Errorf("call CoInitializeEx error: %s", err)
+	}
+	err = CoInitializeSecurity(config.AuthLevel, config.ImpLevel, config.Capabilities)
+	if err != nil && err != rpcETooLate {
+		Uninitialize()
+		return fmt.Errorf("call CoInitializeSecurity error: %s", err)
+	}
+	return nil
+}
+
+func (comThreads) uninitialize() {
+	Uninitialize()
+}
+
+func (comThreads) newLoop() (messageLoop, error) {
+	event, err := windows.CreateEvent(nil, 0, 0, nil)
+	if err != nil {
+		return nil, fmt.Errorf("call CreateEvent error: %s", err)
+	}
+	return &windowsLoop{event: event}, nil
+}
+
+// msg is the MSG structure of a window message
+type msg struct {
+	hwnd     uintptr
+	message  uint32
+	wParam   uintptr
+	lParam   uintptr
+	time     uint32
+	pt       struct{ x, y int32 }
+	lPrivate uint32
+}
+
+// windowsLoop waits for the message queue of the thread and an auto-reset event that wake sets
+type windowsLoop struct {
+	event windows.Handle
+}
+
+func (l *windowsLoop) wait() {
+	// the failures are not retried here, the pump dispatches and checks its calls again
+	_, _, _ = syscall.SyscallN(procMsgWaitForMultipleObjectsEx.Addr(),
+		1,
+		uintptr(unsafe.Pointer(&l.event)),
+		uintptr(windows.INFINITE),
+		qsAllInput,
+		mwmoInputAvailable)
+}
+
+func (l *windowsLoop) dispatch() {
+	var m msg
+	for {
+		r0, _, _ := syscall.SyscallN(procPeekMessageW.Addr(), uintptr(unsafe.Pointer(&m)), 0, 0, 0, pmRemove)
+		if r0 == 0 {
+			return
+		}
+		// WM_QUIT is ignored, the thread lives as long as its executor
+		_, _, _ = syscall.SyscallN(procTranslateMessage.Addr(), uintptr(unsafe.Pointer(&m)))
+		_, _, _ = syscall.SyscallN(procDispatchMessageW.Addr(), uintptr(unsafe.Pointer(&m)))
+	}
+}
+
+func (l *windowsLoop) wake() {
+	_ = windows.SetEvent(l.event)
+}
+
+func (l *windowsLoop) close() {
+	_ = windows.CloseHandle(l.event)
+}
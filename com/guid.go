@@ -0,0 +1,40 @@
+package com
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+func IsEqualGUID(guid1 *GUID, guid2 *GUID) bool {
+	return guid1.Data1 == guid2.Data1 &&
+		guid1.Data2 == guid2.Data2 &&
+		guid1.Data3 == guid2.Data3 &&
+		guid1.Data4[0] == guid2.Data4[0] &&
+		guid1.Data4[1] == guid2.Data4[1] &&
+		guid1.Data4[2] == guid2.Data4[2] &&
+		guid1.Data4[3] == guid2.Data4[3] &&
+		guid1.Data4[4] == guid2.Data4[4] &&
+		guid1.Data4[5] == guid2.Data4[5] &&
+		guid1.Data4[6] == guid2.Data4[6] &&
+		guid1.Data4[7] == guid2.Data4[7]
+}
+
+// ParseGUID parses a GUID in the form XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX, with or without braces.
+// Unlike windows.GUIDFromString it does not call into ole32 and works on every platform.
+func ParseGUID(s string) (GUID, error) {
+	var guid GUID
+	str := strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+	if len(str) != 36 || str[8] != '-' || str[13] != '-' || str[18] != '-' || str[23] != '-' {
+		return guid, fmt.Errorf("invalid GUID %q", s)
+	}
+	b, err := hex.DecodeString(str[0:8] + str[9:13] + str[14:18] + str[19:23] + str[24:36])
+	if err != nil {
+		return guid, fmt.Errorf("invalid GUID %q: %w", s, err)
+	}
+	guid.Data1 = uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	guid.Data2 = uint16(b[4])<<8 | uint16(b[5])
+	guid.Data3 = uint16(b[6])<<8 | uint16(b[7])
+	copy(guid.Data4[:], b[8:])
+	return guid, nil
+}
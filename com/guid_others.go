@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package com
+
+import "fmt"
+
+// GUID has the same layout as the Windows GUID type.
+type GUID struct {
+	Data1 uint32
+	Data2 uint16
+	Data3 uint16
+	Data4 [8]byte
+}
+
+// String returns the canonical registry format of the GUID, for example {00000000-0000-0000-C000-000000000046}.
+func (guid GUID) String() string {
+	return fmt.Sprintf("{%08X-%04X-%04X-%02X%02X-%02X%02X%02X%02X%02X%02X}",
+		guid.Data1, guid.Data2, guid.Data3,
+		guid.Data4[0], guid.Data4[1], guid.Data4[2], guid.Data4[3],
+		guid.Data4[4], guid.Data4[5], guid.Data4[6], guid.Data4[7])
+}
@@ -0,0 +1,6 @@
+package com
+
+import "golang.org/x/sys/windows"
+
+// GUID is the Windows GUID type.
+type GUID = windows.GUID
@@ -0,0 +1,142 @@
+package com
+
+var IID_IUnknown = &GUID{
+	Data1: 0x00000000,
+	Data2: 0x0000,
+	Data3: 0x0000,
+	Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46},
+}
+
+// B196B284-BAB4-101A-B69C-00AA00341D07
+var IID_IConnectionPointContainer = GUID{
+	Data1: 0xB196B284,
+	Data2: 0xBAB4,
+	Data3: 0x101A,
+	Data4: [8]byte{0xB6, 0x9C, 0x00, 0xAA, 0x00, 0x34, 0x1D, 0x07},
+}
+
+var IID_IOPCServer = GUID{
+	Data1: 0x39c13a4d,
+	Data2: 0x011e,
+	Data3: 0x11d0,
+	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
+}
+
+var IID_IOPCCommon = GUID{
+	Data1: 0xF31DFDE2,
+	Data2: 0x07B6,
+	Data3: 0x11d2,
+	Data4: [8]byte{0xB2, 0xD8, 0x00, 0x60, 0x08, 0x3B, 0xA1, 0xFB},
+}
+
+var IID_IOPCItemProperties = GUID{
+	Data1: 0x39c13a72,
+	Data2: 0x011e,
+	Data3: 0x11d0,
+	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
+}
+
+var IID_IOPCBrowseServerAddressSpace = GUID{
+	Data1: 0x39c13a4f,
+	Data2: 0x011e,
+	Data3: 0x11d0,
+	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
+}
+
+var IID_IOPCBrowse = GUID{
+	Data1: 0x39227004,
+	Data2: 0xa18f,
+	Data3: 0x4b57,
+	Data4: [8]byte{0x8b, 0x0a, 0x52, 0x35, 0x67, 0x0f, 0x44, 0x68},
+}
+
+var IID_IOPCItemIO = GUID{
+	Data1: 0x85c0b427,
+	Data2: 0x2893,
+	Data3: 0x4cbc,
+	Data4: [8]byte{0xbd, 0x78, 0xe5, 0xfc, 0x51, 0x46, 0xf0, 0x8f},
+}
+
+var IID_IOPCGroupStateMgt = GUID{
+	Data1: 0x39c13a50,
+	Data2: 0x011e,
+	Data3: 0x11d0,
+	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
+}
+
+var IID_IOPCGroupStateMgt2 = GUID{
+	Data1: 0x8e368666,
+	Data2: 0xd72e,
+	Data3: 0x4f78,
+	Data4: [8]byte{0x87, 0xed, 0x64, 0x76, 0x11, 0xc6, 0x1c, 0x9f},
+}
+
+var IID_IOPCSyncIO = GUID{
+	Data1: 0x39c13a52,
+	Data2: 0x011e,
+	Data3: 0x11d0,
+	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
+}
+
+var IID_IOPCSyncIO2 = GUID{
+	Data1: 0x730f5f0f,
+	Data2: 0x55b1,
+	Data3: 0x4c81,
+	Data4: [8]byte{0x9e, 0x18, 0xff, 0x8a, 0x09, 0x04, 0xe1, 0xfa},
+}
+
+var IID_IOPCItemMgt = GUID{
+	Data1: 0x39c13a54,
+	Data2: 0x011e,
+	Data3: 0x11d0,
+	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
+}
+
+var IID_IOPCItemDeadbandMgt = GUID{
+	Data1: 0x5946da93,
+	Data2: 0x8b39,
+	Data3: 0x4ec8,
+	Data4: [8]byte{0xab, 0x3d, 0xaa, 0x73, 0xdf, 0x5b, 0xc8, 0x6f},
+}
+
+var IID_IOPCItemSamplingMgt = GUID{
+	Data1: 0x3e22d313,
+	Data2: 0xf08b,
+	Data3: 0x41a5,
+	Data4: [8]byte{0x86, 0xc8, 0x95, 0xe9, 0x5c, 0xb4, 0x9f, 0xfc},
+}
+
+var IID_IOPCAsyncIO2 = GUID{
+	Data1: 0x39c13a71,
+	Data2: 0x011e,
+	Data3: 0x11d0,
+	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
+}
+
+var IID_IOPCAsyncIO3 = GUID{
+	Data1: 0x0967b97b,
+	Data2: 0x36ef,
+	Data3: 0x423e,
+	Data4: [8]byte{0xb6, 0xf8, 0x6b, 0xff, 0x1e, 0x40, 0xd3, 0x9d},
+}
+
+var CLSID_OpcServerList = GUID{
+	Data1: 0x13486D51,
+	Data2: 0x4821,
+	Data3: 0x11D2,
+	Data4: [8]byte{0xA4, 0x94, 0x3C, 0xB3, 0x06, 0xC1, 0x00, 0x00},
+}
+
+var IID_IOPCServerList = GUID{
+	Data1: 0x13486D50,
+	Data2: 0x4821,
+	Data3: 0x11D2,
+	Data4: [8]byte{0xA4, 0x94, 0x3C, 0xB3, 0x06, 0xC1, 0x00, 0x00},
+}
+
+var IID_IOPCServerList2 = GUID{
+	Data1: 0x9DD0B56C,
+	Data2: 0xAD9E,
+	Data3: 0x43ee,
+	Data4: [8]byte{0x83, 0x05, 0x48, 0x7F, 0x31, 0x88, 0xBF, 0x7A},
+}
@@ -0,0 +1,24 @@
+package com
+
+// Initialize initialize COM with COINIT_MULTITHREADED
+func Initialize() error {
+	config := DefaultInitConfig()
+	return InitializeWithConfig(config)
+}
+
+type InitConfig struct {
+	AuthLevel    uint32
+	ImpLevel     uint32
+	Capabilities uint32
+	// Apartment is the apartment the thread enters, MultiThreaded by default. A thread that enters an STA must stay
+	// locked to its goroutine and dispatch its window messages to receive callbacks, an STA Executor does both.
+	Apartment Apartment
+}
+
+func DefaultInitConfig() *InitConfig {
+	return &InitConfig{
+		AuthLevel:    RPC_C_AUTHN_LEVEL_NONE,
+		ImpLevel:     RPC_C_IMP_LEVEL_IMPERSONATE,
+		Capabilities: EOAC_NONE,
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package com
+
+// InitializeWithConfig does nothing on platforms without COM, the DCOM transport does not need a COM runtime.
+func InitializeWithConfig(config *InitConfig) error {
+	return nil
+}
+
+// Uninitialize does nothing on platforms without COM.
+func Uninitialize() {
+}
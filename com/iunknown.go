@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
@@ -7,13 +10,6 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-var IID_IUnknown = &windows.GUID{
-	Data1: 0x00000000,
-	Data2: 0x0000,
-	Data3: 0x0000,
-	Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46},
-}
-
 type IUnknownVtbl struct {
 	QueryInterface uintptr
 	AddRef         uintptr
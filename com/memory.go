@@ -0,0 +1,66 @@
+package com
+
+import "unsafe"
+
+// ptrSize is the size of the pointers in the memory layouts, VARIANTs and SAFEARRAYs have the layout of the running
+// process
+const ptrSize = int(unsafe.Sizeof(uintptr(0)))
+
+// VariantSize is the size of a VARIANT in memory, 16 bytes for 32 bit and 24 bytes for 64 bit processes
+const VariantSize = 8 + 2*ptrSize
+
+const (
+	decimalSize = 16
+	// safeArraySize is the size of a SAFEARRAY descriptor without the bounds, which follow it
+	safeArraySize = 8 + 2*ptrSize
+	// safeArrayDataOffset is the offset of pvData in a SAFEARRAY descriptor
+	safeArrayDataOffset = safeArraySize - ptrSize
+)
+
+// Memory gives DecodeVariant access to the memory a VARIANT refers to, such as BSTRs and SAFEARRAYs.
+// The pointers of the memory are only read from its pointer fields with Pointer and offset with Bytes, so that the
+// memory of the process never converts an integer to a pointer.
+type Memory interface {
+	// Pointer returns the pointer held by the pointer field at the start of b, which is memory of the VARIANT or
+	// returned by Bytes. It fails for a pointer outside of the memory.
+	Pointer(b []byte) (unsafe.Pointer, error)
+	// Bytes returns the n bytes at offset from p, writes to the result change the memory. It fails for memory that
+	// cannot be accessed.
+	Bytes(p unsafe.Pointer, offset, n int) ([]byte, error)
+}
+
+// Allocator allocates the memory EncodeVariant refers to, the memory of BSTRs and SAFEARRAYs is freed by clearing the
+// VARIANT
+type Allocator interface {
+	Memory
+	// PutPointer writes p to the pointer field at the start of b
+	PutPointer(b []byte, p unsafe.Pointer)
+	// AllocString returns a BSTR that holds s
+	AllocString(s string) (unsafe.Pointer, error)
+	// AllocArray returns a SAFEARRAY of vt elements with zeroed data, the bounds start from the leftmost dimension
+	AllocArray(vt VT, bounds []SafeArrayBound) (unsafe.Pointer, error)
+	// AllocReference returns a zeroed VARIANT that holds the value of a VT_BYREF VARIANT. Clearing the VT_BYREF
+	// VARIANT does not free it, the Allocator clears and frees it.
+	AllocReference() (unsafe.Pointer, error)
+}
+
+// elementSize returns the size of a SAFEARRAY element of type vt, zero for types that are not supported
+func elementSize(vt VT) int {
+	switch vt {
+	case VT_I1, VT_UI1:
+		return 1
+	case VT_I2, VT_UI2, VT_BOOL:
+		return 2
+	case VT_I4, VT_UI4, VT_INT, VT_UINT, VT_R4, VT_ERROR:
+		return 4
+	case VT_I8, VT_UI8, VT_R8, VT_DATE, VT_CY:
+		return 8
+	case VT_BSTR:
+		return ptrSize
+	case VT_DECIMAL:
+		return decimalSize
+	case VT_VARIANT:
+		return VariantSize
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package com
+
+import (
+	"sync"
+)
+
+// messageLoop is the message queue of an STA thread. COM delivers the calls into an STA, such as the callbacks of a
+// server, as window messages that the thread has to dispatch. It is the Windows message queue of the thread and is
+// stubbed in tests.
+type messageLoop interface {
+	// wait blocks until a message is posted to the thread or wake is called
+	wait()
+	// dispatch dispatches the messages pending on the thread without blocking
+	dispatch()
+	// wake wakes wait up, it may be called from any goroutine and is not lost when wait is not blocked yet
+	wake()
+	// close releases the loop, it is called on the thread
+	close()
+}
+
+// messagePump runs the calls of an STA executor on its thread and dispatches the window messages of the thread
+// between them, so that the callbacks of the servers are delivered while the thread waits for calls
+type messagePump struct {
+	loop messageLoop
+	// lock guards calls and closed
+	lock   sync.Mutex
+	calls  []*executorCall
+	closed bool
+}
+
+func newMessagePump(loop messageLoop) *messagePump {
+	return &messagePump{loop: loop}
+}
+
+// push queues a call and wakes the thread
+func (p *messagePump) push(call *executorCall) {
+	p.lock.Lock()
+	p.calls = append(p.calls, call)
+	p.lock.Unlock()
+	p.loop.wake()
+}
+
+// close makes run return once the queued calls have run
+func (p *messagePump) close() {
+	p.lock.Lock()
+	p.closed = true
+	p.lock.Unlock()
+	p.loop.wake()
+}
+
+// next takes the next queued call, nil when there is none
+func (p *messagePump) next() (call *executorCall, closed bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.calls) == 0 {
+		return nil, p.closed
+	}
+	call = p.calls[0]
+	p.calls[0] = nil
+	p.calls = p.calls[1:]
+	return call, p.closed
+}
+
+// run is the loop of the thread, it dispatches the pending messages before each call and waits for messages and calls
+// when there is nothing to do, until the pump is closed and its calls have run
+func (p *messagePump) run() {
+	for {
+		p.loop.dispatch()
+		call, closed := p.next()
+		switch {
+		case call != nil:
+			call.run()
+		case closed:
+			return
+		default:
+			p.loop.wait()
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package com
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubLoop is a message queue that messages are posted to from any goroutine, like PostThreadMessage
+type stubLoop struct {
+	lock     sync.Mutex
+	messages []func()
+	wakeup   chan struct{}
+}
+
+func newStubLoop() *stubLoop {
+	return &stubLoop{wakeup: make(chan struct{}, 1)}
+}
+
+// post queues a message that is run when the loop dispatches
+func (l *stubLoop) post(message func()) {
+	l.lock.Lock()
+	l.messages = append(l.messages, message)
+	l.lock.Unlock()
+	l.wake()
+}
+
+func (l *stubLoop) wait() {
+	<-l.wakeup
+}
+
+func (l *stubLoop) dispatch() {
+	l.lock.Lock()
+	messages := l.messages
+	l.messages = nil
+	l.lock.Unlock()
+	for _, message := range messages {
+		message()
+	}
+}
+
+func (l *stubLoop) wake() {
+	select {
+	case l.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (l *stubLoop) close() {
+}
+
+// pushCall queues f on the pump and returns its call
+func pushCall(p *messagePump, f func() error) *executorCall {
+	call := &executorCall{f: f, done: make(chan struct{})}
+	p.push(call)
+	return call
+}
+
+func TestMessagePump(t *testing.T) {
+	loop := newStubLoop()
+	pump := newMessagePump(loop)
+	returned := make(chan struct{})
+	go func() {
+		pump.run()
+		close(returned)
+	}()
+
+	// the messages are dispatched while the pump waits for calls
+	dispatched := make(chan int, 10)
+	loop.post(func() { dispatched <- 1 })
+	select {
+	case n := <-dispatched:
+		assert.Equal(t, 1, n)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	// the calls run in order and the messages posted by a call are dispatched before the next one
+	var order []int
+	first := pushCall(pump, func() error {
+		order = append(order, 1)
+		loop.post(func() { order = append(order, 2) })
+		return nil
+	})
+	second := pushCall(pump, func() error {
+		order = append(order, 3)
+		return nil
+	})
+	<-first.done
+	<-second.done
+	assert.Equal(t, []int{1, 2, 3}, order)
+
+	// the calls queued before close run before run returns
+	block := make(chan struct{})
+	blocked := pushCall(pump, func() error {
+		<-block
+		return nil
+	})
+	last := pushCall(pump, func() error { return nil })
+	pump.close()
+	select {
+	case <-returned:
+		t.Fatal("run returned with pending calls")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(block)
+	<-blocked.done
+	<-last.done
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestExecutorSingleThreaded(t *testing.T) {
+	loop := newStubLoop()
+	threads := &loopThreads{loop: loop}
+	e, err := newExecutor(threads, WithInitConfig(&InitConfig{Apartment: SingleThreaded}))
+	require.NoError(t, err)
+	assert.Equal(t, SingleThreaded, e.GetApartment())
+
+	// a callback posted to the thread is dispatched by the pump between the calls
+	dispatched := make(chan struct{})
+	loop.post(func() { close(dispatched) })
+	select {
+	case <-dispatched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	var ran []int
+	for i := 0; i < 3; i++ {
+		i := i
+		require.NoError(t, e.Do(func() error {
+			ran = append(ran, i)
+			return nil
+		}))
+	}
+	assert.Equal(t, []int{0, 1, 2}, ran)
+	e.Close()
+	assert.True(t, threads.closed)
+	assert.ErrorIs(t, e.Do(func() error { return nil }), ErrExecutorClosed)
+}
+
+// loopThreads hands a stub loop to an STA executor
+type loopThreads struct {
+	stubThreads
+	loop   *stubLoop
+	closed bool
+}
+
+func (l *loopThreads) newLoop() (messageLoop, error) {
+	return &closingLoop{stubLoop: l.loop, closed: &l.closed}, nil
+}
+
+type closingLoop struct {
+	*stubLoop
+	closed *bool
+}
+
+func (l *closingLoop) close() {
+	*l.closed = true
+}
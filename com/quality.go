@@ -0,0 +1,151 @@
+package com
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Quality is an OPC quality word. The low byte is laid out as QQSSSSLL: the major quality, the sub-status
+// and the limit. The high byte is vendor specific.
+type Quality uint16
+
+// QualityLimit is the limit field of a Quality
+type QualityLimit uint16
+
+const (
+	qualityMajorMask  = 0xC0
+	qualityStatusMask = 0xFC
+	qualityLimitMask  = 0x03
+)
+
+// The major qualities, they are also the non-specific sub-status of their major quality
+const (
+	QualityBad       Quality = 0x00
+	QualityUncertain Quality = 0x40
+	QualityGood      Quality = 0xC0
+)
+
+// The sub-status values, including their major quality
+const (
+	QualityConfigError           Quality = 0x04
+	QualityNotConnected          Quality = 0x08
+	QualityDeviceFailure         Quality = 0x0C
+	QualitySensorFailure         Quality = 0x10
+	QualityLastKnownValue        Quality = 0x14
+	QualityCommFailure           Quality = 0x18
+	QualityOutOfService          Quality = 0x1C
+	QualityWaitingForInitialData Quality = 0x20
+
+	QualityLastUsableValue   Quality = 0x44
+	QualitySensorNotAccurate Quality = 0x50
+	QualityEGUExceeded       Quality = 0x54
+	QualitySubNormal         Quality = 0x58
+
+	QualityLocalOverride Quality = 0xD8
+)
+
+const (
+	// LimitOK the value is free to move up or down
+	LimitOK QualityLimit = 0
+	// LimitLow the value has pegged at some lower limit
+	LimitLow QualityLimit = 1
+	// LimitHigh the value has pegged at some high limit
+	LimitHigh QualityLimit = 2
+	// LimitConstant the value is a constant and cannot move
+	LimitConstant QualityLimit = 3
+)
+
+var qualityNames = map[Quality]string{
+	QualityBad:                   "Bad",
+	QualityUncertain:             "Uncertain",
+	QualityGood:                  "Good",
+	QualityConfigError:           "ConfigError",
+	QualityNotConnected:          "NotConnected",
+	QualityDeviceFailure:         "DeviceFailure",
+	QualitySensorFailure:         "SensorFailure",
+	QualityLastKnownValue:        "LastKnownValue",
+	QualityCommFailure:           "CommFailure",
+	QualityOutOfService:          "OutOfService",
+	QualityWaitingForInitialData: "WaitingForInitialData",
+	QualityLastUsableValue:       "LastUsableValue",
+	QualitySensorNotAccurate:     "SensorNotAccurate",
+	QualityEGUExceeded:           "EGUExceeded",
+	QualitySubNormal:             "SubNormal",
+	QualityLocalOverride:         "LocalOverride",
+}
+
+// Major returns the major quality, QualityGood, QualityUncertain, QualityBad or the reserved 0x80
+func (q Quality) Major() Quality {
+	return q & qualityMajorMask
+}
+
+// SubStatus returns the sub-status including the major quality, it equals Major when the sub-status is non-specific
+func (q Quality) SubStatus() Quality {
+	return q & qualityStatusMask
+}
+
+// Limit returns the limit field
+func (q Quality) Limit() QualityLimit {
+	return QualityLimit(q & qualityLimitMask)
+}
+
+// Vendor returns the vendor specific high byte
+func (q Quality) Vendor() uint8 {
+	return uint8(q >> 8)
+}
+
+// IsGood reports whether the major quality is good
+func (q Quality) IsGood() bool {
+	return q.Major() == QualityGood
+}
+
+// IsUncertain reports whether the major quality is uncertain
+func (q Quality) IsUncertain() bool {
+	return q.Major() == QualityUncertain
+}
+
+// IsBad reports whether the major quality is bad
+func (q Quality) IsBad() bool {
+	return q.Major() == QualityBad
+}
+
+// String returns the major quality followed by the specific sub-status, the limit and the vendor bits when set,
+// for example "Uncertain (EGUExceeded, High)"
+func (q Quality) String() string {
+	major, ok := qualityNames[q.Major()]
+	if !ok {
+		major = fmt.Sprintf("Major(0x%02X)", uint16(q.Major()))
+	}
+	var details []string
+	if status := q.SubStatus(); status != q.Major() {
+		name, ok := qualityNames[status]
+		if !ok {
+			name = fmt.Sprintf("SubStatus(0x%02X)", uint16(status))
+		}
+		details = append(details, name)
+	}
+	if q.Limit() != LimitOK {
+		details = append(details, q.Limit().String())
+	}
+	if q.Vendor() != 0 {
+		details = append(details, fmt.Sprintf("Vendor(0x%02X)", q.Vendor()))
+	}
+	if len(details) == 0 {
+		return major
+	}
+	return major + " (" + strings.Join(details, ", ") + ")"
+}
+
+func (l QualityLimit) String() string {
+	switch l {
+	case LimitOK:
+		return "OK"
+	case LimitLow:
+		return "Low"
+	case LimitHigh:
+		return "High"
+	case LimitConstant:
+		return "Constant"
+	}
+	return fmt.Sprintf("QualityLimit(%d)", uint16(l))
+}
@@ -0,0 +1,98 @@
+package com
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQualityString(t *testing.T) {
+	for _, c := range []struct {
+		quality Quality
+		want    string
+	}{
+		{QualityBad, "Bad"},
+		{QualityUncertain, "Uncertain"},
+		{QualityGood, "Good"},
+		{0x80, "Major(0x80)"},
+		{QualityConfigError, "Bad (ConfigError)"},
+		{QualityNotConnected, "Bad (NotConnected)"},
+		{QualityDeviceFailure, "Bad (DeviceFailure)"},
+		{QualitySensorFailure, "Bad (SensorFailure)"},
+		{QualityLastKnownValue, "Bad (LastKnownValue)"},
+		{QualityCommFailure, "Bad (CommFailure)"},
+		{QualityOutOfService, "Bad (OutOfService)"},
+		{QualityWaitingForInitialData, "Bad (WaitingForInitialData)"},
+		{QualityLastUsableValue, "Uncertain (LastUsableValue)"},
+		{QualitySensorNotAccurate, "Uncertain (SensorNotAccurate)"},
+		{QualityEGUExceeded, "Uncertain (EGUExceeded)"},
+		{QualitySubNormal, "Uncertain (SubNormal)"},
+		{QualityLocalOverride, "Good (LocalOverride)"},
+		{0x24, "Bad (SubStatus(0x24))"},
+		{QualityEGUExceeded | Quality(LimitHigh), "Uncertain (EGUExceeded, High)"},
+		{QualityGood | Quality(LimitConstant), "Good (Constant)"},
+		{QualityBad | Quality(LimitLow), "Bad (Low)"},
+		{0x12C0, "Good (Vendor(0x12))"},
+		{0xFFFF, "Good (SubStatus(0xFC), Constant, Vendor(0xFF))"},
+	} {
+		assert.Equal(t, c.want, c.quality.String(), "0x%04X", uint16(c.quality))
+	}
+}
+
+func TestQualityDecode(t *testing.T) {
+	for _, c := range []struct {
+		quality   Quality
+		major     Quality
+		subStatus Quality
+		limit     QualityLimit
+		vendor    uint8
+	}{
+		{0x0000, QualityBad, QualityBad, LimitOK, 0},
+		{0x0019, QualityBad, QualityCommFailure, LimitLow, 0},
+		{0x0056, QualityUncertain, QualityEGUExceeded, LimitHigh, 0},
+		{0x00DB, QualityGood, QualityLocalOverride, LimitConstant, 0},
+		{0xAB5A, QualityUncertain, QualitySubNormal, LimitHigh, 0xAB},
+	} {
+		assert.Equal(t, c.major, c.quality.Major())
+		assert.Equal(t, c.subStatus, c.quality.SubStatus())
+		assert.Equal(t, c.limit, c.quality.Limit())
+		assert.Equal(t, c.vendor, c.quality.Vendor())
+	}
+}
+
+// TestQualityAll checks the decoding of every quality word
+func TestQualityAll(t *testing.T) {
+	for i := 0; i <= 0xFFFF; i++ {
+		q := Quality(i)
+		major := Quality(i & 0xC0)
+		if q.Major() != major || q.SubStatus() != Quality(i&0xFC) || q.Limit() != QualityLimit(i&0x03) || q.Vendor() != uint8(i>>8) {
+			t.Fatalf("0x%04X decoded as %v %v %v %v", i, q.Major(), q.SubStatus(), q.Limit(), q.Vendor())
+		}
+		if q.SubStatus().Major() != major || q.Major().SubStatus() != major {
+			t.Fatalf("0x%04X sub-status does not include the major quality", i)
+		}
+		if q.SubStatus()|Quality(q.Limit())|Quality(q.Vendor())<<8 != q {
+			t.Fatalf("0x%04X does not recompose", i)
+		}
+		flags := 0
+		for _, set := range []bool{q.IsGood(), q.IsUncertain(), q.IsBad()} {
+			if set {
+				flags++
+			}
+		}
+		if major == 0x80 && flags != 0 || major != 0x80 && flags != 1 {
+			t.Fatalf("0x%04X has %d major flags", i, flags)
+		}
+		if q.String() == "" {
+			t.Fatalf("0x%04X has no name", i)
+		}
+	}
+}
+
+func TestQualityLimitString(t *testing.T) {
+	assert.Equal(t, "OK", LimitOK.String())
+	assert.Equal(t, "Low", LimitLow.String())
+	assert.Equal(t, "High", LimitHigh.String())
+	assert.Equal(t, "Constant", LimitConstant.String())
+	assert.Equal(t, "QualityLimit(4)", QualityLimit(4).String())
+}
@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package com
 
 import (
@@ -40,14 +43,6 @@ func (p *IConnectionPoint) Unadvise(dwCookie uint32) error {
 	return nil
 }
 
-// B196B284-BAB4-101A-B69C-00AA00341D07
-var IID_IConnectionPointContainer = windows.GUID{
-	Data1: 0xB196B284,
-	Data2: 0xBAB4,
-	Data3: 0x101A,
-	Data4: [8]byte{0xB6, 0x9C, 0x00, 0xAA, 0x00, 0x34, 0x1D, 0x07},
-}
-
 type IConnectionPointContainerVtbl struct {
 	IUnknownVtbl
 	EnumConnectionPoints uintptr
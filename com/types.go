@@ -0,0 +1,93 @@
+package com
+
+import "time"
+
+func BoolToComBOOL(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type OPCServerState uint32
+
+type ServerStatus struct {
+	StartTime      time.Time
+	CurrentTime    time.Time
+	LastUpdateTime time.Time
+	ServerState    OPCServerState
+	GroupCount     uint32
+	BandWidth      uint32
+	MajorVersion   uint16
+	MinorVersion   uint16
+	BuildNumber    uint16
+	Reserved       uint16
+	VendorInfo     string
+}
+
+type OPCDATASOURCE int32
+
+type ItemState struct {
+	Value        interface{}
+	Quality      Quality
+	Timestamp    time.Time
+	ClientHandle int32
+}
+
+// ItemDefinition is the Go representation of OPCITEMDEF
+type ItemDefinition struct {
+	AccessPath        string
+	ItemID            string
+	Active            bool
+	ClientHandle      uint32
+	Blob              []byte
+	RequestedDataType VT
+}
+
+// ItemVQT is the Go representation of OPCITEMVQT, the quality and timestamp are written only when specified
+type ItemVQT struct {
+	Value              interface{}
+	QualitySpecified   bool
+	Quality            Quality
+	TimestampSpecified bool
+	Timestamp          time.Time
+}
+
+type TagOPCITEMRESULTStruct struct {
+	Server       uint32
+	NativeType   uint16
+	AccessRights uint32
+	Blob         []byte
+}
+
+type OPCNAMESPACETYPE uint32
+
+type OPCBROWSEDIRECTION uint32
+
+type OPCBROWSETYPE uint32
+
+type OPCBROWSEFILTER uint32
+
+// ItemProperty is the Go representation of OPCITEMPROPERTY
+type ItemProperty struct {
+	DataType    uint16
+	PropertyID  uint32
+	ItemID      string
+	Description string
+	Value       interface{}
+	Error       int32
+}
+
+// ItemProperties is the Go representation of OPCITEMPROPERTIES
+type ItemProperties struct {
+	Error      int32
+	Properties []*ItemProperty
+}
+
+// BrowseElement is the Go representation of OPCBROWSEELEMENT
+type BrowseElement struct {
+	Name       string
+	ItemID     string
+	Flags      uint32
+	Properties ItemProperties
+}
@@ -0,0 +1,618 @@
+package com
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var le = binary.LittleEndian
+
+// maxDecodeDepth limits the nesting of VARIANTs, the memory of a malformed VARIANT may refer to itself
+const maxDecodeDepth = 32
+
+// maxArrayBytes limits the data of a SAFEARRAY
+const maxArrayBytes = math.MaxInt32
+
+var errDecodeDepth = errors.New("com: VARIANTs nested too deeply")
+
+// elementTypes are the Go types of the SAFEARRAY elements
+var elementTypes = func() map[VT]reflect.Type {
+	types := make(map[VT]reflect.Type, len(arrayElementTypes))
+	for t, vt := range arrayElementTypes {
+		types[vt] = t
+	}
+	return types
+}()
+
+// DecodeVariant decodes the VARIANT held by the first VariantSize bytes of b, the memory it refers to is read from m.
+// A value that cannot be decoded returns a *DecodeError.
+func DecodeVariant(b []byte, m Memory) (interface{}, error) {
+	d := &variantDecoder{m: m}
+	return d.variant(b)
+}
+
+// DecodeSafeArray decodes the SAFEARRAY at p like a VARIANT of type VT_ARRAY|vt, the type stored with the
+// SAFEARRAY takes precedence over vt
+func DecodeSafeArray(p unsafe.Pointer, vt VT, m Memory) (interface{}, error) {
+	d := &variantDecoder{m: m}
+	value, err := d.safeArray(p, vt)
+	if err != nil {
+		return nil, NewDecodeError(VT_ARRAY|vt, err)
+	}
+	return value, nil
+}
+
+type variantDecoder struct {
+	m     Memory
+	depth int
+}
+
+func (d *variantDecoder) variant(b []byte) (interface{}, error) {
+	if len(b) < VariantSize {
+		return nil, &DecodeError{Err: fmt.Errorf("com: VARIANT of %d bytes", len(b))}
+	}
+	vt := VT(le.Uint16(b))
+	value, err := d.value(vt, b)
+	if err != nil {
+		return nil, NewDecodeError(vt, err)
+	}
+	return value, nil
+}
+
+func (d *variantDecoder) value(vt VT, b []byte) (interface{}, error) {
+	d.depth++
+	defer func() {
+		d.depth--
+	}()
+	if d.depth > maxDecodeDepth {
+		return nil, errDecodeDepth
+	}
+	switch {
+	case vt == VT_EMPTY || vt == VT_NULL:
+		return nil, nil
+	case vt == VT_DECIMAL:
+		// a DECIMAL fills the whole VARIANT, its reserved field holds the type
+		return decodeDecimal(b), nil
+	case vt&VT_BYREF != 0:
+		p, err := d.m.Pointer(b[8:])
+		if err != nil {
+			return nil, err
+		}
+		return d.reference(vt&^VT_BYREF, p)
+	case vt&VT_ARRAY != 0:
+		p, err := d.m.Pointer(b[8:])
+		if err != nil {
+			return nil, err
+		}
+		return d.safeArray(p, vt&^VT_ARRAY)
+	}
+	return d.scalar(vt, b[8:])
+}
+
+// reference decodes the value a VT_BYREF VARIANT refers to, a null reference decodes as nil
+func (d *variantDecoder) reference(vt VT, p unsafe.Pointer) (interface{}, error) {
+	if p == nil {
+		return nil, nil
+	}
+	size := elementSize(vt)
+	if vt&VT_ARRAY != 0 {
+		size = ptrSize
+	}
+	if size == 0 {
+		return nil, ErrUnsupportedType
+	}
+	b, err := d.m.Bytes(p, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case vt == VT_VARIANT:
+		return d.variant(b)
+	case vt == VT_DECIMAL:
+		return decodeDecimal(b), nil
+	case vt&VT_ARRAY != 0:
+		array, err := d.m.Pointer(b)
+		if err != nil {
+			return nil, err
+		}
+		return d.safeArray(array, vt&^VT_ARRAY)
+	}
+	return d.scalar(vt, b)
+}
+
+// scalar decodes a value of type vt from the memory of the value, such as the union of a VARIANT
+//
+//gocyclo:ignore
+func (d *variantDecoder) scalar(vt VT, b []byte) (interface{}, error) {
+	if size := elementSize(vt); size == 0 || len(b) < size {
+		return nil, ErrUnsupportedType
+	}
+	switch vt {
+	case VT_I1:
+		return int8(b[0]), nil
+	case VT_UI1:
+		return b[0], nil
+	case VT_I2:
+		return int16(le.Uint16(b)), nil
+	case VT_UI2:
+		return le.Uint16(b), nil
+	case VT_BOOL:
+		return le.Uint16(b) != 0, nil
+	case VT_I4:
+		return int32(le.Uint32(b)), nil
+	case VT_UI4:
+		return le.Uint32(b), nil
+	case VT_INT:
+		return int(int32(le.Uint32(b))), nil
+	case VT_UINT:
+		return uint(le.Uint32(b)), nil
+	case VT_ERROR:
+		return SCode(le.Uint32(b)), nil
+	case VT_R4:
+		return math.Float32frombits(le.Uint32(b)), nil
+	case VT_I8:
+		return int64(le.Uint64(b)), nil
+	case VT_UI8:
+		return le.Uint64(b), nil
+	case VT_R8:
+		return math.Float64frombits(le.Uint64(b)), nil
+	case VT_CY:
+		return Currency(le.Uint64(b)), nil
+	case VT_DATE:
+		return decodeOLEDate(math.Float64frombits(le.Uint64(b)))
+	case VT_BSTR:
+		p, err := d.m.Pointer(b)
+		if err != nil {
+			return nil, err
+		}
+		return d.bstr(p)
+	}
+	return nil, ErrUnsupportedType
+}
+
+// bstr reads the BSTR at p, its byte length is stored before it. A null BSTR is empty.
+func (d *variantDecoder) bstr(p unsafe.Pointer) (string, error) {
+	if p == nil {
+		return "", nil
+	}
+	prefix, err := d.m.Bytes(p, -4, 4)
+	if err != nil {
+		return "", err
+	}
+	n := le.Uint32(prefix)
+	if n > maxArrayBytes {
+		return "", fmt.Errorf("com: BSTR of %d bytes", n)
+	}
+	b, err := d.m.Bytes(p, 0, int(n))
+	if err != nil {
+		return "", err
+	}
+	chars := make([]uint16, n/2)
+	for i := range chars {
+		chars[i] = le.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(chars)), nil
+}
+
+// safeArray decodes the SAFEARRAY at p, a SAFEARRAY of more than one dimension is returned as an Array. The type
+// of the elements is the type stored with the SAFEARRAY, otherwise vt.
+//
+//gocyclo:ignore
+func (d *variantDecoder) safeArray(p unsafe.Pointer, vt VT) (interface{}, error) {
+	if p == nil {
+		return nil, nil
+	}
+	header, err := d.m.Bytes(p, 0, safeArraySize)
+	if err != nil {
+		return nil, err
+	}
+	dims := int(le.Uint16(header))
+	features := le.Uint16(header[2:])
+	size := int(le.Uint32(header[4:]))
+	data, err := d.m.Pointer(header[safeArrayDataOffset:])
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case features&FADF_HAVEVARTYPE != 0:
+		// the type is stored before the descriptor
+		b, err := d.m.Bytes(p, -4, 4)
+		if err != nil {
+			return nil, err
+		}
+		vt = VT(le.Uint32(b))
+	case features&FADF_BSTR != 0:
+		vt = VT_BSTR
+	case features&FADF_VARIANT != 0:
+		vt = VT_VARIANT
+	case features&(FADF_UNKNOWN|FADF_DISPATCH) != 0:
+		vt = VT_UNKNOWN
+	}
+	t, ok := elementTypes[vt]
+	if !ok {
+		return nil, fmt.Errorf("%w: SAFEARRAY element type 0x%x", ErrUnsupportedType, uint16(vt))
+	}
+	if size != elementSize(vt) {
+		return nil, fmt.Errorf("com: SAFEARRAY elements of %d bytes for type 0x%x", size, uint16(vt))
+	}
+	b, err := d.m.Bytes(p, safeArraySize, dims*8)
+	if err != nil {
+		return nil, err
+	}
+	bounds := make([]SafeArrayBound, dims)
+	total := uint64(1)
+	for i := range bounds {
+		// the bounds are stored from the rightmost dimension
+		bound := b[(dims-1-i)*8:]
+		bounds[i] = SafeArrayBound{Elements: le.Uint32(bound), LowerBound: int32(le.Uint32(bound[4:]))}
+		total *= uint64(bounds[i].Elements)
+		if total > maxArrayBytes {
+			total = maxArrayBytes + 1
+		}
+	}
+	if dims == 0 {
+		total = 0
+	}
+	if total*uint64(size) > maxArrayBytes {
+		return nil, fmt.Errorf("com: SAFEARRAY of %d elements is too large", total)
+	}
+	n := int(total)
+	var elements []byte
+	if n > 0 {
+		if data == nil {
+			return nil, errors.New("com: SAFEARRAY without data")
+		}
+		elements, err = d.m.Bytes(data, 0, n*size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	values := reflect.MakeSlice(reflect.SliceOf(t), n, n)
+	for i := 0; i < n; i++ {
+		b := elements[i*size : (i+1)*size]
+		var value interface{}
+		switch vt {
+		case VT_VARIANT:
+			value, err = d.variant(b)
+		case VT_DECIMAL:
+			value = decodeDecimal(b)
+		default:
+			value, err = d.scalar(vt, b)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			values.Index(i).Set(reflect.ValueOf(value))
+		}
+	}
+	if dims < 2 {
+		return values.Interface(), nil
+	}
+	return Array{Dims: bounds, Values: values.Interface()}, nil
+}
+
+// decodeDecimal decodes the memory layout of a DECIMAL
+func decodeDecimal(b []byte) Decimal {
+	return Decimal{
+		Scale:    b[2],
+		Negative: b[3]&0x80 != 0,
+		Hi32:     le.Uint32(b[4:]),
+		Lo64:     le.Uint64(b[8:]),
+	}
+}
+
+func putDecimal(b []byte, d Decimal) {
+	b[2] = d.Scale
+	b[3] = 0
+	if d.Negative {
+		b[3] = 0x80
+	}
+	le.PutUint32(b[4:], d.Hi32)
+	le.PutUint64(b[8:], d.Lo64)
+}
+
+// EncodeVariant writes value to the VARIANT held by the first VariantSize bytes of b, which is VT_EMPTY. The memory
+// the VARIANT refers to is allocated with a. After a failure b may refer to some of it and has to be cleared.
+func EncodeVariant(b []byte, value interface{}, a Allocator) error {
+	if len(b) < VariantSize {
+		return fmt.Errorf("com: VARIANT of %d bytes", len(b))
+	}
+	vt, err := variantType(value)
+	if err != nil {
+		return err
+	}
+	e := &variantEncoder{a: a}
+	return e.variant(b, vt, value)
+}
+
+type variantEncoder struct {
+	a Allocator
+}
+
+func (e *variantEncoder) variant(b []byte, vt VT, value interface{}) error {
+	switch {
+	case vt == VT_EMPTY:
+		return nil
+	case vt == VT_DECIMAL:
+		// a DECIMAL fills the whole VARIANT, its reserved field holds the type
+		putDecimal(b, value.(Decimal))
+	case vt&VT_BYREF != 0:
+		return e.reference(b, vt, value)
+	case vt&VT_ARRAY != 0:
+		return e.safeArray(b, vt, value)
+	default:
+		err := e.scalar(b[8:], value)
+		if err != nil {
+			return err
+		}
+	}
+	le.PutUint16(b, uint16(vt))
+	return nil
+}
+
+// reference writes a VT_BYREF VARIANT, the value it refers to is held by a VARIANT of the Allocator
+func (e *variantEncoder) reference(b []byte, vt VT, value interface{}) error {
+	p, err := e.a.AllocReference()
+	if err != nil {
+		return err
+	}
+	ref, err := e.a.Bytes(p, 0, VariantSize)
+	if err != nil {
+		return err
+	}
+	vt &^= VT_BYREF
+	switch vt {
+	case VT_VARIANT, VT_DECIMAL:
+		e.a.PutPointer(b[8:], p)
+	default:
+		// the value is in the union of the VARIANT
+		e.a.PutPointer(b[8:], unsafe.Pointer(&ref[8]))
+	}
+	le.PutUint16(b, uint16(VT_BYREF|vt))
+	referenced := reflect.ValueOf(value).Elem().Interface()
+	if vt == VT_VARIANT {
+		vt, err = variantType(referenced)
+		if err != nil {
+			return err
+		}
+	}
+	return e.variant(ref, vt, referenced)
+}
+
+// safeArray writes a VT_ARRAY VARIANT, the SAFEARRAY is referred to by b before its elements are written so that
+// clearing b frees them
+func (e *variantEncoder) safeArray(b []byte, vt VT, value interface{}) error {
+	array, ok := value.(Array)
+	if !ok {
+		var err error
+		array, err = toArray(value)
+		if err != nil {
+			return err
+		}
+	}
+	vt &^= VT_ARRAY
+	p, err := e.a.AllocArray(vt, array.Dims)
+	if err != nil {
+		return err
+	}
+	e.a.PutPointer(b[8:], p)
+	le.PutUint16(b, uint16(VT_ARRAY|vt))
+	header, err := e.a.Bytes(p, 0, safeArraySize)
+	if err != nil {
+		return err
+	}
+	values := reflect.ValueOf(array.Values)
+	size := elementSize(vt)
+	n := values.Len()
+	if n == 0 {
+		return nil
+	}
+	data, err := e.a.Pointer(header[safeArrayDataOffset:])
+	if err != nil {
+		return err
+	}
+	elements, err := e.a.Bytes(data, 0, n*size)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		b := elements[i*size : (i+1)*size]
+		value := values.Index(i).Interface()
+		switch vt {
+		case VT_VARIANT:
+			elementType, err := variantType(value)
+			if err != nil {
+				return err
+			}
+			err = e.variant(b, elementType, value)
+			if err != nil {
+				return err
+			}
+		case VT_DECIMAL:
+			putDecimal(b, value.(Decimal))
+		default:
+			err = e.scalar(b, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// toArray converts a slice or nested slices to an Array
+func toArray(value interface{}) (Array, error) {
+	if IsNestedSlice(value) {
+		return NewArray(value)
+	}
+	n := reflect.ValueOf(value).Len()
+	return Array{Dims: []SafeArrayBound{{Elements: uint32(n)}}, Values: value}, nil
+}
+
+// scalar writes the memory of a value such as the union of a VARIANT
+//
+//gocyclo:ignore
+func (e *variantEncoder) scalar(b []byte, value interface{}) error {
+	switch v := value.(type) {
+	case int8:
+		b[0] = uint8(v)
+	case uint8:
+		b[0] = v
+	case int16:
+		le.PutUint16(b, uint16(v))
+	case uint16:
+		le.PutUint16(b, v)
+	case bool:
+		if v {
+			le.PutUint16(b, 0xffff)
+		} else {
+			le.PutUint16(b, 0)
+		}
+	case int32:
+		le.PutUint32(b, uint32(v))
+	case uint32:
+		le.PutUint32(b, v)
+	case int:
+		le.PutUint32(b, uint32(int32(v)))
+	case uint:
+		le.PutUint32(b, uint32(v))
+	case SCode:
+		le.PutUint32(b, uint32(v))
+	case float32:
+		le.PutUint32(b, math.Float32bits(v))
+	case int64:
+		le.PutUint64(b, uint64(v))
+	case uint64:
+		le.PutUint64(b, v)
+	case float64:
+		le.PutUint64(b, math.Float64bits(v))
+	case Currency:
+		le.PutUint64(b, uint64(v))
+	case time.Time:
+		date, err := encodeOLEDate(v)
+		if err != nil {
+			return err
+		}
+		le.PutUint64(b, math.Float64bits(date))
+	case string:
+		p, err := e.a.AllocString(v)
+		if err != nil {
+			return err
+		}
+		e.a.PutPointer(b, p)
+	default:
+		return fmt.Errorf("unsupported type: %T", value)
+	}
+	return nil
+}
+
+// VariantType returns the type of the VARIANT EncodeVariant writes for value
+func VariantType(value interface{}) (VT, error) {
+	return variantType(value)
+}
+
+// variantType returns the VARIANT type of value, a pointer is written as a VT_BYREF of the type it points to and a
+// *interface{} as a VT_BYREF|VT_VARIANT
+//
+//gocyclo:ignore
+func variantType(value interface{}) (VT, error) {
+	switch v := value.(type) {
+	case nil:
+		return VT_EMPTY, nil
+	case int8:
+		return VT_I1, nil
+	case uint8:
+		return VT_UI1, nil
+	case int16:
+		return VT_I2, nil
+	case uint16:
+		return VT_UI2, nil
+	case int32:
+		return VT_I4, nil
+	case uint32:
+		return VT_UI4, nil
+	case int64:
+		return VT_I8, nil
+	case uint64:
+		return VT_UI8, nil
+	case int:
+		return VT_INT, nil
+	case uint:
+		return VT_UINT, nil
+	case float32:
+		return VT_R4, nil
+	case float64:
+		return VT_R8, nil
+	case string:
+		return VT_BSTR, nil
+	case time.Time:
+		return VT_DATE, nil
+	case bool:
+		return VT_BOOL, nil
+	case Currency:
+		return VT_CY, nil
+	case Decimal:
+		return VT_DECIMAL, nil
+	case SCode:
+		return VT_ERROR, nil
+	case Array:
+		return arrayType(v)
+	case *interface{}:
+		if v == nil {
+			return 0, errors.New("nil VT_BYREF pointer")
+		}
+		if _, err := variantType(*v); err != nil {
+			return 0, err
+		}
+		return VT_BYREF | VT_VARIANT, nil
+	}
+	t := reflect.TypeOf(value)
+	switch {
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Slice:
+		array, err := NewArray(value)
+		if err != nil {
+			return 0, err
+		}
+		return arrayType(array)
+	case t.Kind() == reflect.Slice:
+		return arrayType(Array{Dims: []SafeArrayBound{{Elements: uint32(reflect.ValueOf(value).Len())}}, Values: value})
+	case t.Kind() == reflect.Ptr:
+		p := reflect.ValueOf(value)
+		if p.IsNil() {
+			return 0, errors.New("nil VT_BYREF pointer")
+		}
+		vt, err := variantType(p.Elem().Interface())
+		if err != nil {
+			return 0, err
+		}
+		if vt&VT_BYREF != 0 || vt == VT_EMPTY {
+			return 0, fmt.Errorf("unsupported type: %T", value)
+		}
+		return VT_BYREF | vt, nil
+	}
+	return 0, fmt.Errorf("unsupported type: %T", value)
+}
+
+// arrayType returns the type of a VARIANT that holds a, the elements of a VT_VARIANT array are checked
+func arrayType(a Array) (VT, error) {
+	err := a.Validate()
+	if err != nil {
+		return 0, err
+	}
+	vt, _ := a.ElementType()
+	if vt == VT_VARIANT {
+		for _, e := range a.Values.([]interface{}) {
+			if _, err := variantType(e); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return VT_ARRAY | vt, nil
+}
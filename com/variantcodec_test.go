@@ -0,0 +1,371 @@
+package com
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMemoryBase = 0x10000
+
+var (
+	errTestMemory          = errors.New("address out of the test memory")
+	errTestMemoryExhausted = errors.New("test memory exhausted")
+)
+
+// testMemory is a Memory and an Allocator over a buffer at testMemoryBase that lays values out like oleaut32. Its
+// pointer fields hold addresses from testMemoryBase, Pointer maps them to the buffer.
+type testMemory struct {
+	buf []byte
+}
+
+func newTestMemory(size int) *testMemory {
+	return &testMemory{buf: make([]byte, 0, size)}
+}
+
+// at returns the n bytes at addr
+func (m *testMemory) at(addr uint64, n int) ([]byte, error) {
+	if n < 0 || addr < testMemoryBase || addr-testMemoryBase > uint64(len(m.buf)) ||
+		uint64(n) > uint64(len(m.buf))-(addr-testMemoryBase) {
+		return nil, errTestMemory
+	}
+	offset := addr - testMemoryBase
+	return m.buf[offset : offset+uint64(n)], nil
+}
+
+// address returns the address of p, which points into the buffer
+func (m *testMemory) address(p unsafe.Pointer) uint64 {
+	return testMemoryBase + uint64(uintptr(p)-uintptr(unsafe.Pointer(&m.buf[0])))
+}
+
+func (m *testMemory) Pointer(b []byte) (unsafe.Pointer, error) {
+	addr := readPointer(b)
+	if addr == 0 {
+		return nil, nil
+	}
+	if addr < testMemoryBase || addr-testMemoryBase >= uint64(len(m.buf)) {
+		return nil, errTestMemory
+	}
+	return unsafe.Pointer(&m.buf[addr-testMemoryBase]), nil
+}
+
+func (m *testMemory) Bytes(p unsafe.Pointer, offset, n int) ([]byte, error) {
+	if len(m.buf) == 0 {
+		return nil, errTestMemory
+	}
+	start := int64(uintptr(p)-uintptr(unsafe.Pointer(&m.buf[0]))) + int64(offset)
+	if n < 0 || start < 0 || start > int64(len(m.buf)) || int64(n) > int64(len(m.buf))-start {
+		return nil, errTestMemory
+	}
+	return m.buf[start : start+int64(n)], nil
+}
+
+func (m *testMemory) PutPointer(b []byte, p unsafe.Pointer) {
+	if p == nil {
+		putPointer(b, 0)
+		return
+	}
+	putPointer(b, m.address(p))
+}
+
+// alloc returns n zeroed bytes aligned to 8, the buffer never grows so that the results of Bytes stay valid
+func (m *testMemory) alloc(n int) (unsafe.Pointer, error) {
+	start := (len(m.buf) + 7) &^ 7
+	if start+n > cap(m.buf) || start >= cap(m.buf) {
+		return nil, errTestMemoryExhausted
+	}
+	m.buf = m.buf[:start+n]
+	for i := start; i < start+n; i++ {
+		m.buf[i] = 0
+	}
+	return unsafe.Pointer(&m.buf[:cap(m.buf)][start]), nil
+}
+
+func (m *testMemory) AllocString(s string) (unsafe.Pointer, error) {
+	chars := utf16.Encode([]rune(s))
+	p, err := m.alloc(4 + 2*len(chars) + 2)
+	if err != nil {
+		return nil, err
+	}
+	b, _ := m.Bytes(p, 0, 4+2*len(chars)+2)
+	le.PutUint32(b, uint32(2*len(chars)))
+	for i, c := range chars {
+		le.PutUint16(b[4+2*i:], c)
+	}
+	return unsafe.Pointer(&b[4]), nil
+}
+
+func (m *testMemory) AllocArray(vt VT, bounds []SafeArrayBound) (unsafe.Pointer, error) {
+	features := uint16(FADF_HAVEVARTYPE)
+	switch vt {
+	case VT_BSTR:
+		features |= FADF_BSTR
+	case VT_VARIANT:
+		features |= FADF_VARIANT
+	}
+	size := elementSize(vt)
+	total := 1
+	for _, bound := range bounds {
+		total *= int(bound.Elements)
+	}
+	data, err := m.alloc(total * size)
+	if err != nil {
+		return nil, err
+	}
+	// the type is stored in the 4 bytes before the descriptor
+	p, err := m.alloc(4 + safeArraySize + 8*len(bounds))
+	if err != nil {
+		return nil, err
+	}
+	b, _ := m.Bytes(p, 0, 4+safeArraySize+8*len(bounds))
+	le.PutUint32(b, uint32(vt))
+	descriptor := b[4:]
+	le.PutUint16(descriptor, uint16(len(bounds)))
+	le.PutUint16(descriptor[2:], features)
+	le.PutUint32(descriptor[4:], uint32(size))
+	m.PutPointer(descriptor[safeArrayDataOffset:], data)
+	for i, bound := range bounds {
+		b := descriptor[safeArraySize+8*(len(bounds)-1-i):]
+		le.PutUint32(b, bound.Elements)
+		le.PutUint32(b[4:], uint32(bound.LowerBound))
+	}
+	return unsafe.Pointer(&descriptor[0]), nil
+}
+
+func (m *testMemory) AllocReference() (unsafe.Pointer, error) {
+	return m.alloc(VariantSize)
+}
+
+func readPointer(b []byte) uint64 {
+	if ptrSize == 4 {
+		return uint64(le.Uint32(b))
+	}
+	return le.Uint64(b)
+}
+
+func putPointer(b []byte, addr uint64) {
+	if ptrSize == 4 {
+		le.PutUint32(b, uint32(addr))
+		return
+	}
+	le.PutUint64(b, addr)
+}
+
+// encode returns the memory of value, the VARIANT is at testMemoryBase
+func encode(t testing.TB, value interface{}) *testMemory {
+	m := newTestMemory(1 << 16)
+	p, err := m.alloc(VariantSize)
+	require.NoError(t, err)
+	b, _ := m.Bytes(p, 0, VariantSize)
+	require.NoError(t, EncodeVariant(b, value, m), "%T", value)
+	return m
+}
+
+func TestVariantCodecRoundTrip(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC)
+	var variant interface{} = []interface{}{"a", int16(2)}
+	i4 := int32(-7)
+	text := "referenced"
+	array := []float64{1.5}
+	decimal := Decimal{Scale: 1, Lo64: 15}
+	values := []struct {
+		value interface{}
+		want  interface{}
+	}{
+		{nil, nil},
+		{int8(-1), int8(-1)}, {uint8(2), uint8(2)}, {int16(-3), int16(-3)}, {uint16(4), uint16(4)},
+		{int32(-5), int32(-5)}, {uint32(6), uint32(6)}, {int64(-7), int64(-7)}, {uint64(8), uint64(8)},
+		{-9, -9}, {uint(10), uint(10)}, {float32(1.5), float32(1.5)}, {2.5, 2.5},
+		{"text 中文 😀", "text 中文 😀"}, {"", ""}, {now, now}, {true, true}, {false, false},
+		{Currency(-125000), Currency(-125000)}, {SCode(-2147352572), SCode(-2147352572)},
+		{Decimal{Scale: 2, Negative: true, Hi32: 1, Lo64: 150}, Decimal{Scale: 2, Negative: true, Hi32: 1, Lo64: 150}},
+		{[]int8{-1, 2}, []int8{-1, 2}}, {[]uint8{3}, []uint8{3}}, {[]int16{-5}, []int16{-5}},
+		{[]uint16{6}, []uint16{6}}, {[]int32{-7, 8}, []int32{-7, 8}}, {[]uint32{9}, []uint32{9}},
+		{[]int64{-10}, []int64{-10}}, {[]uint64{11}, []uint64{11}}, {[]int{-12}, []int{-12}},
+		{[]uint{13}, []uint{13}}, {[]float32{1.5}, []float32{1.5}}, {[]float64{2.5, 3.5}, []float64{2.5, 3.5}},
+		{[]string{"a", "", "bc"}, []string{"a", "", "bc"}}, {[]bool{true, false}, []bool{true, false}},
+		{[]time.Time{now}, []time.Time{now}}, {[]Currency{1, -2}, []Currency{1, -2}},
+		{[]SCode{0, -2147467259}, []SCode{0, -2147467259}}, {[]Decimal{decimal}, []Decimal{decimal}},
+		{[]int32{}, []int32{}},
+		{
+			[]interface{}{int32(1), "two", nil, decimal, []interface{}{"nested"}},
+			[]interface{}{int32(1), "two", nil, decimal, []interface{}{"nested"}},
+		},
+		{
+			[][]int16{{1, 2, 3}, {4, 5, 6}},
+			Array{Dims: []SafeArrayBound{{Elements: 2}, {Elements: 3}}, Values: []int16{1, 4, 2, 5, 3, 6}},
+		},
+		{
+			Array{Dims: []SafeArrayBound{{Elements: 1, LowerBound: -1}, {Elements: 2, LowerBound: 1}}, Values: []string{"a", "b"}},
+			Array{Dims: []SafeArrayBound{{Elements: 1, LowerBound: -1}, {Elements: 2, LowerBound: 1}}, Values: []string{"a", "b"}},
+		},
+		{&i4, i4}, {&text, text}, {&array, array}, {&decimal, decimal}, {&variant, variant},
+	}
+	for _, v := range values {
+		m := encode(t, v.value)
+		b, _ := m.at(testMemoryBase, VariantSize)
+		got, err := DecodeVariant(b, m)
+		require.NoError(t, err, "%T", v.value)
+		assert.Equal(t, v.want, got, "%T", v.value)
+	}
+}
+
+func TestVariantCodecLayout(t *testing.T) {
+	m := encode(t, "ab")
+	b, _ := m.at(testMemoryBase, VariantSize)
+	assert.Equal(t, VT_BSTR, VT(le.Uint16(b)))
+	bstr := readPointer(b[8:])
+	prefix, err := m.at(bstr-4, 8)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{4, 0, 0, 0, 'a', 0, 'b', 0}, prefix)
+
+	m = encode(t, Decimal{Scale: 3, Negative: true, Hi32: 2, Lo64: 5})
+	b, _ = m.at(testMemoryBase, VariantSize)
+	assert.Equal(t, []byte{byte(VT_DECIMAL), 0, 3, 0x80, 2, 0, 0, 0, 5, 0, 0, 0, 0, 0, 0, 0}, b[:16])
+
+	// the bounds of the descriptor start from the rightmost dimension
+	m = encode(t, Array{Dims: []SafeArrayBound{{Elements: 2, LowerBound: 1}, {Elements: 3, LowerBound: 5}}, Values: make([]int32, 6)})
+	b, _ = m.at(testMemoryBase, VariantSize)
+	assert.Equal(t, VT_ARRAY|VT_I4, VT(le.Uint16(b)))
+	addr := readPointer(b[8:])
+	descriptor, err := m.at(addr-4, 4+safeArraySize+16)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(VT_I4), le.Uint32(descriptor))
+	assert.Equal(t, uint16(2), le.Uint16(descriptor[4:]))
+	assert.Equal(t, uint16(FADF_HAVEVARTYPE), le.Uint16(descriptor[6:]))
+	assert.Equal(t, uint32(4), le.Uint32(descriptor[8:]))
+	assert.Equal(t, []byte{3, 0, 0, 0, 5, 0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0}, descriptor[4+safeArraySize:])
+}
+
+func TestVariantCodecFeatures(t *testing.T) {
+	// a SAFEARRAY without FADF_HAVEVARTYPE takes the type of its features or of the VARIANT
+	for _, c := range []struct {
+		value    interface{}
+		features uint16
+		vt       VT
+	}{
+		{[]string{"x"}, FADF_BSTR, VT_ARRAY | VT_BSTR},
+		{[]interface{}{int32(1)}, FADF_VARIANT, VT_ARRAY | VT_VARIANT},
+		{[]int32{1}, 0, VT_ARRAY | VT_I4},
+	} {
+		m := encode(t, c.value)
+		b, _ := m.at(testMemoryBase, VariantSize)
+		descriptor, _ := m.at(readPointer(b[8:]), safeArraySize)
+		le.PutUint16(descriptor[2:], c.features)
+		le.PutUint16(b, uint16(c.vt))
+		got, err := DecodeVariant(b, m)
+		require.NoError(t, err, "%T", c.value)
+		assert.Equal(t, c.value, got)
+	}
+}
+
+func TestVariantCodecDecodeErrors(t *testing.T) {
+	decode := func(m *testMemory) error {
+		b, _ := m.at(testMemoryBase, VariantSize)
+		_, err := DecodeVariant(b, m)
+		var decodeErr *DecodeError
+		assert.ErrorAs(t, err, &decodeErr)
+		return err
+	}
+	m := encode(t, int32(1))
+	b, _ := m.at(testMemoryBase, VariantSize)
+	le.PutUint16(b, uint16(VT_UNKNOWN))
+	assert.ErrorIs(t, decode(m), ErrUnsupportedType)
+
+	// a date out of the range of oleaut32
+	m = encode(t, 1.0)
+	b, _ = m.at(testMemoryBase, VariantSize)
+	le.PutUint16(b, uint16(VT_DATE))
+	le.PutUint64(b[8:], math.Float64bits(math.NaN()))
+	assert.Error(t, decode(m))
+
+	// a VT_BYREF|VT_VARIANT that refers to itself
+	m = encode(t, int32(1))
+	b, _ = m.at(testMemoryBase, VariantSize)
+	le.PutUint16(b, uint16(VT_BYREF|VT_VARIANT))
+	putPointer(b[8:], testMemoryBase)
+	assert.ErrorIs(t, decode(m), errDecodeDepth)
+
+	// the element of a VT_VARIANT array fails the array, the error has the type of the element
+	m = encode(t, []interface{}{int32(1), "x"})
+	b, _ = m.at(testMemoryBase, VariantSize)
+	descriptor, _ := m.at(readPointer(b[8:]), safeArraySize)
+	element, _ := m.at(readPointer(descriptor[safeArrayDataOffset:])+uint64(VariantSize), VariantSize)
+	le.PutUint16(element, uint16(VT_DISPATCH))
+	err := decode(m)
+	var decodeErr *DecodeError
+	require.ErrorAs(t, err, &decodeErr)
+	assert.Equal(t, VT_DISPATCH, decodeErr.VT)
+
+	// the element size does not match the type
+	m = encode(t, []int32{1})
+	b, _ = m.at(testMemoryBase, VariantSize)
+	descriptor, _ = m.at(readPointer(b[8:]), safeArraySize)
+	le.PutUint32(descriptor[4:], 2)
+	assert.Error(t, decode(m))
+
+	// bounds that describe more elements than the memory holds
+	m = encode(t, []int32{1})
+	b, _ = m.at(testMemoryBase, VariantSize)
+	bounds, _ := m.at(readPointer(b[8:])+uint64(safeArraySize), 8)
+	le.PutUint32(bounds, 0xffffffff)
+	assert.Error(t, decode(m))
+
+	// a BSTR outside of the memory
+	m = encode(t, "x")
+	b, _ = m.at(testMemoryBase, VariantSize)
+	putPointer(b[8:], 0x100)
+	assert.ErrorIs(t, decode(m), errTestMemory)
+}
+
+func TestVariantCodecEncodeErrors(t *testing.T) {
+	for _, value := range []interface{}{
+		struct{}{},
+		[]struct{}{},
+		[]interface{}{1, struct{}{}},
+		[][]int32{{1}, {2, 3}},
+		Array{Dims: []SafeArrayBound{{Elements: 2}}, Values: []int32{1}},
+		(*int32)(nil),
+		(*interface{})(nil),
+		new(struct{}),
+		time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC),
+		[]time.Time{time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		m := newTestMemory(1 << 12)
+		b := make([]byte, VariantSize)
+		assert.Error(t, EncodeVariant(b, value, m), "%T", value)
+	}
+}
+
+func FuzzDecodeVariant(f *testing.F) {
+	for _, value := range []interface{}{
+		int32(1), "text", 2.5, Decimal{Scale: 1, Lo64: 15}, []string{"a", "b"}, []interface{}{int8(1), "x", nil},
+		[][]float64{{1, 2}, {3, 4}}, time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+	} {
+		f.Add(encode(f, value).buf)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) < VariantSize {
+			return
+		}
+		m := &testMemory{buf: data}
+		value, err := DecodeVariant(data, m)
+		if err != nil {
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("%v is not a DecodeError", err)
+			}
+			return
+		}
+		// a decoded value can be written again
+		if err := EncodeVariant(make([]byte, VariantSize), value, newTestMemory(1<<20)); err != nil && !errors.Is(err, errTestMemoryExhausted) {
+			t.Fatalf("encode %#v: %v", value, err)
+		}
+	})
+}
@@ -2,31 +2,36 @@ package opcda
 
 import (
 	"github.com/huskar-t/opcda/com"
-
-	"golang.org/x/sys/windows"
 )
 
-var IID_CATID_OPCDAServer10 = windows.GUID{
+var IID_CATID_OPCDAServer10 = com.GUID{
 	Data1: 0x63D5F430,
 	Data2: 0xCFE4,
 	Data3: 0x11d1,
 	Data4: [8]byte{0xB2, 0xC8, 0x00, 0x60, 0x08, 0x3B, 0xA1, 0xFB},
 }
 
-var IID_CATID_OPCDAServer20 = windows.GUID{
+var IID_CATID_OPCDAServer20 = com.GUID{
 	Data1: 0x63D5F432,
 	Data2: 0xCFE4,
 	Data3: 0x11d1,
 	Data4: [8]byte{0xB2, 0xC8, 0x00, 0x60, 0x08, 0x3B, 0xA1, 0xFB},
 }
 
-var IID_IOPCShutdown = windows.GUID{
+var IID_IOPCShutdown = com.GUID{
 	Data1: 0xF31DFDE1,
 	Data2: 0x07B6,
 	Data3: 0x11d2,
 	Data4: [8]byte{0xB2, 0xD8, 0x00, 0x60, 0x08, 0x3B, 0xA1, 0xFB},
 }
 
+var IID_IOPCDataCallback = com.GUID{
+	Data1: 0x39c13a70,
+	Data2: 0x011e,
+	Data3: 0x11d0,
+	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
+}
+
 const (
 	OPC_READABLE uint32 = 0x1
 
@@ -64,6 +69,17 @@ const (
 	OPC_FLAT   com.OPCBROWSETYPE = OPC_LEAF + 1
 )
 
+const (
+	OPC_BROWSE_FILTER_ALL      com.OPCBROWSEFILTER = 1
+	OPC_BROWSE_FILTER_BRANCHES com.OPCBROWSEFILTER = OPC_BROWSE_FILTER_ALL + 1
+	OPC_BROWSE_FILTER_ITEMS    com.OPCBROWSEFILTER = OPC_BROWSE_FILTER_BRANCHES + 1
+)
+
+const (
+	OPC_BROWSE_HASCHILDREN uint32 = 0x1
+	OPC_BROWSE_ISITEM      uint32 = 0x2
+)
+
 const (
 	OPC_ENUM_PRIVATE_CONNECTIONS = 1
 	OPC_ENUM_PUBLIC_CONNECTIONS  = OPC_ENUM_PRIVATE_CONNECTIONS + 1
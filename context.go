@@ -0,0 +1,373 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+// ErrConnectionSuspect is returned by the context-aware calls of a connection while a call abandoned for its context
+// has not returned yet
+var ErrConnectionSuspect = errors.New("the connection is suspect, an abandoned call has not returned")
+
+// connectionState tracks the calls of a connection abandoned for their context
+type connectionState struct {
+	// abandoned is the number of abandoned calls that have not returned
+	abandoned atomic.Int32
+	// suspect is set when a call is abandoned and cleared when the last abandoned call returns
+	suspect atomic.Bool
+}
+
+// GetIsSuspect get whether a call of the connection was abandoned because its context was done before the server
+// answered. It is cleared once every abandoned call has returned.
+func (s *OPCServer) GetIsSuspect() bool {
+	return s.state.suspect.Load()
+}
+
+// callContext runs call in a goroutine and waits for it or ctx. When ctx is done first the connection is marked
+// suspect and the error of ctx is returned at once, the call goes on and abandon, when not nil, is called with its
+// results once it returns to release what it created, before the connection is cleared. While an abandoned call has
+// not returned the calls fail with ErrConnectionSuspect instead of piling up. state may be nil.
+func callContext[T any](ctx context.Context, state *connectionState, call func() (T, error), abandon func(T)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if state != nil && state.abandoned.Load() > 0 {
+		return zero, ErrConnectionSuspect
+	}
+	type result struct {
+		value T
+		err   error
+	}
+	// lock guards finished and abandoned, the buffer lets a finished call return before its result is received
+	var lock sync.Mutex
+	var finished, abandoned bool
+	done := make(chan result, 1)
+	go func() {
+		value, err := call()
+		lock.Lock()
+		if !abandoned {
+			finished = true
+			lock.Unlock()
+			done <- result{value: value, err: err}
+			return
+		}
+		lock.Unlock()
+		if err == nil && abandon != nil {
+			abandon(value)
+		}
+		// the connection answers again whether the call failed or not
+		if state != nil && state.abandoned.Add(-1) == 0 {
+			state.suspect.Store(false)
+		}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if finished {
+		// the call returned meanwhile
+		r := <-done
+		return r.value, r.err
+	}
+	abandoned = true
+	if state != nil {
+		state.suspect.Store(true)
+		state.abandoned.Add(1)
+	}
+	return zero, ctx.Err()
+}
+
+// pair holds the first two results of a call with three results for callContext
+type pair[A, B any] struct {
+	a A
+	b B
+}
+
+// triple holds the first three results of a call with four results for callContext
+type triple[A, B, C any] struct {
+	a A
+	b B
+	c C
+}
+
+// ConnectContext connects like Connect and returns the error of ctx when it is done before the connection is made.
+// A connection made afterwards is disconnected.
+func ConnectContext(ctx context.Context, progID, node string, opts ...ConnectOption) (*OPCServer, error) {
+	return callContext(ctx, nil, func() (*OPCServer, error) {
+		return Connect(progID, node, opts...)
+	}, func(server *OPCServer) {
+		server.Disconnect()
+	})
+}
+
+// GetStatusContext returns the status of the server like GetStatus. When ctx is done before the server answers the
+// connection is marked suspect and the error of ctx is returned, see GetIsSuspect.
+func (s *OPCServer) GetStatusContext(ctx context.Context) (*com.ServerStatus, error) {
+	return callContext(ctx, &s.state, s.GetStatus, nil)
+}
+
+// GetItemPropertiesContext returns the properties of an item like GetItemProperties, see GetStatusContext for ctx
+func (s *OPCServer) GetItemPropertiesContext(ctx context.Context, itemID string, propertyIDs []uint32) ([]interface{}, []error, error) {
+	r, err := callContext(ctx, &s.state, func() (r pair[[]interface{}, []error], err error) {
+		r.a, r.b, err = s.GetItemProperties(itemID, propertyIDs)
+		return
+	}, nil)
+	return r.a, r.b, err
+}
+
+// ReadItemsContext reads items like ReadItems, see GetStatusContext for ctx
+func (s *OPCServer) ReadItemsContext(ctx context.Context, itemIDs []string, maxAges []uint32) ([]*com.ItemState, []error, error) {
+	r, err := callContext(ctx, &s.state, func() (r pair[[]*com.ItemState, []error], err error) {
+		r.a, r.b, err = s.ReadItems(itemIDs, maxAges)
+		return
+	}, nil)
+	return r.a, r.b, err
+}
+
+// WriteItemsVQTContext writes items like WriteItemsVQT, see GetStatusContext for ctx. The values may still be written
+// after ctx is done.
+func (s *OPCServer) WriteItemsVQTContext(ctx context.Context, itemIDs []string, values []interface{}, qualities []com.Quality, timestamps []time.Time) ([]error, error) {
+	return callContext(ctx, &s.state, func() ([]error, error) {
+		return s.WriteItemsVQT(itemIDs, values, qualities, timestamps)
+	}, nil)
+}
+
+// BrowseContext browses the address space like Browse, see GetStatusContext for ctx
+func (s *OPCServer) BrowseContext(ctx context.Context, itemID string, filter *BrowseFilter, maxElements uint32, returnProperties bool) (*BrowseResult, error) {
+	return callContext(ctx, &s.state, func() (*BrowseResult, error) {
+		return s.Browse(itemID, filter, maxElements, returnProperties)
+	}, nil)
+}
+
+// QueryAvailablePropertiesContext returns the properties of an item like QueryAvailableProperties, see
+// GetStatusContext for ctx
+func (s *OPCServer) QueryAvailablePropertiesContext(ctx context.Context, itemID string) ([]uint32, []string, []uint16, error) {
+	r, err := callContext(ctx, &s.state, func() (r triple[[]uint32, []string, []uint16], err error) {
+		r.a, r.b, r.c, err = s.QueryAvailableProperties(itemID)
+		return
+	}, nil)
+	return r.a, r.b, r.c, err
+}
+
+// LookupItemIDsContext returns the item IDs of properties like LookupItemIDs, see GetStatusContext for ctx
+func (s *OPCServer) LookupItemIDsContext(ctx context.Context, itemID string, propertyIDs []uint32) ([]string, []error, error) {
+	r, err := callContext(ctx, &s.state, func() (r pair[[]string, []error], err error) {
+		r.a, r.b, err = s.LookupItemIDs(itemID, propertyIDs)
+		return
+	}, nil)
+	return r.a, r.b, err
+}
+
+// DisconnectContext disconnects like Disconnect and returns the error of ctx when it is done first, the disconnect
+// goes on. It runs while the connection is suspect.
+func (s *OPCServer) DisconnectContext(ctx context.Context) error {
+	_, err := callContext(ctx, nil, func() (struct{}, error) {
+		return struct{}{}, s.Disconnect()
+	}, nil)
+	return err
+}
+
+// AddContext adds a group like Add, see GetStatusContext for ctx. A group added after ctx is done is removed.
+func (gs *OPCGroups) AddContext(ctx context.Context, szName string) (*OPCGroup, error) {
+	return callContext(ctx, gs.parent.connectionState(), func() (*OPCGroup, error) {
+		return gs.Add(szName)
+	}, func(group *OPCGroup) {
+		gs.Remove(group.GetServerHandle())
+	})
+}
+
+// RemoveContext removes a group like Remove, see GetStatusContext for ctx. The group may still be removed after ctx
+// is done.
+func (gs *OPCGroups) RemoveContext(ctx context.Context, serverHandle uint32) error {
+	_, err := callContext(ctx, gs.parent.connectionState(), func() (struct{}, error) {
+		return struct{}{}, gs.Remove(serverHandle)
+	}, nil)
+	return err
+}
+
+// AddItemsContext adds items like AddItems, see GetStatusContext for ctx. Items added after ctx is done are
+// removed.
+func (is *OPCItems) AddItemsContext(ctx context.Context, tags []string) ([]*OPCItem, []error, error) {
+	r, err := callContext(ctx, is.connectionState(), func() (r pair[[]*OPCItem, []error], err error) {
+		r.a, r.b, err = is.AddItems(tags)
+		return
+	}, func(r pair[[]*OPCItem, []error]) {
+		var handles []uint32
+		for _, item := range r.a {
+			if item != nil {
+				handles = append(handles, item.GetServerHandle())
+			}
+		}
+		is.Remove(handles)
+	})
+	return r.a, r.b, err
+}
+
+// RemoveContext removes items like Remove, see GetStatusContext for ctx. The items may still be removed after ctx is
+// done.
+func (is *OPCItems) RemoveContext(ctx context.Context, serverHandles []uint32) error {
+	_, err := callContext(ctx, is.connectionState(), func() (struct{}, error) {
+		is.Remove(serverHandles)
+		return struct{}{}, nil
+	}, nil)
+	return err
+}
+
+// ValidateContext validates items like Validate, see GetStatusContext for ctx
+func (is *OPCItems) ValidateContext(ctx context.Context, tags []string, requestedDataTypes *[]com.VT, accessPaths *[]string) ([]error, error) {
+	return callContext(ctx, is.connectionState(), func() ([]error, error) {
+		return is.Validate(tags, requestedDataTypes, accessPaths)
+	}, nil)
+}
+
+// SetActiveContext activates or deactivates items like SetActive, see GetStatusContext for ctx. The items may still
+// change after ctx is done.
+func (is *OPCItems) SetActiveContext(ctx context.Context, serverHandles []uint32, active bool) ([]error, error) {
+	return callContext(ctx, is.connectionState(), func() ([]error, error) {
+		return is.SetActive(serverHandles, active), nil
+	}, nil)
+}
+
+// SyncReadContext reads items like SyncRead, see GetStatusContext for ctx
+func (g *OPCGroup) SyncReadContext(ctx context.Context, source com.OPCDATASOURCE, serverHandles []uint32) ([]*com.ItemState, []error, error) {
+	r, err := callContext(ctx, g.connectionState(), func() (r pair[[]*com.ItemState, []error], err error) {
+		r.a, r.b, err = g.SyncRead(source, serverHandles)
+		return
+	}, nil)
+	return r.a, r.b, err
+}
+
+// SyncWriteContext writes items like SyncWrite, see GetStatusContext for ctx. The values may still be written
+// after ctx is done.
+func (g *OPCGroup) SyncWriteContext(ctx context.Context, serverHandles []uint32, values []interface{}) ([]error, error) {
+	return callContext(ctx, g.connectionState(), func() ([]error, error) {
+		return g.SyncWrite(serverHandles, values)
+	}, nil)
+}
+
+// SyncReadMaxAgeContext reads items like SyncReadMaxAge, see GetStatusContext for ctx
+func (g *OPCGroup) SyncReadMaxAgeContext(ctx context.Context, serverHandles []uint32, maxAges []uint32) ([]*com.ItemState, []error, error) {
+	r, err := callContext(ctx, g.connectionState(), func() (r pair[[]*com.ItemState, []error], err error) {
+		r.a, r.b, err = g.SyncReadMaxAge(serverHandles, maxAges)
+		return
+	}, nil)
+	return r.a, r.b, err
+}
+
+// SyncWriteVQTContext writes items like SyncWriteVQT, see SyncWriteContext for ctx
+func (g *OPCGroup) SyncWriteVQTContext(ctx context.Context, serverHandles []uint32, values []interface{}, qualities []com.Quality, timestamps []time.Time) ([]error, error) {
+	return callContext(ctx, g.connectionState(), func() ([]error, error) {
+		return g.SyncWriteVQT(serverHandles, values, qualities, timestamps)
+	}, nil)
+}
+
+// AsyncReadContext starts a read like AsyncRead, see GetStatusContext for ctx. A transaction started after ctx is
+// done is cancelled.
+func (g *OPCGroup) AsyncReadContext(ctx context.Context, serverHandles []uint32, clientTransactionID uint32) (uint32, []error, error) {
+	r, err := callContext(ctx, g.connectionState(), func() (r pair[uint32, []error], err error) {
+		r.a, r.b, err = g.AsyncRead(serverHandles, clientTransactionID)
+		return
+	}, g.cancelStarted)
+	return r.a, r.b, err
+}
+
+// AsyncWriteContext starts a write like AsyncWrite, see AsyncReadContext for ctx
+func (g *OPCGroup) AsyncWriteContext(ctx context.Context, serverHandles []uint32, values []interface{}, clientTransactionID uint32) (uint32, []error, error) {
+	r, err := callContext(ctx, g.connectionState(), func() (r pair[uint32, []error], err error) {
+		r.a, r.b, err = g.AsyncWrite(serverHandles, values, clientTransactionID)
+		return
+	}, g.cancelStarted)
+	return r.a, r.b, err
+}
+
+// AsyncRefreshContext starts a refresh like AsyncRefresh, see AsyncReadContext for ctx
+func (g *OPCGroup) AsyncRefreshContext(ctx context.Context, source com.OPCDATASOURCE, clientTransactionID uint32) (uint32, error) {
+	return callContext(ctx, g.connectionState(), func() (uint32, error) {
+		return g.AsyncRefresh(source, clientTransactionID)
+	}, func(cancelID uint32) {
+		_ = g.AsyncCancel(cancelID)
+	})
+}
+
+// AsyncCancelContext cancels a transaction like AsyncCancel, see GetStatusContext for ctx
+func (g *OPCGroup) AsyncCancelContext(ctx context.Context, cancelID uint32) error {
+	_, err := callContext(ctx, g.connectionState(), func() (struct{}, error) {
+		return struct{}{}, g.AsyncCancel(cancelID)
+	}, nil)
+	return err
+}
+
+// AsyncReadMaxAgeContext starts a read like AsyncReadMaxAge, see AsyncReadContext for ctx
+func (g *OPCGroup) AsyncReadMaxAgeContext(ctx context.Context, serverHandles []uint32, maxAges []uint32, clientTransactionID uint32) (uint32, []error, error) {
+	r, err := callContext(ctx, g.connectionState(), func() (r pair[uint32, []error], err error) {
+		r.a, r.b, err = g.AsyncReadMaxAge(serverHandles, maxAges, clientTransactionID)
+		return
+	}, g.cancelStarted)
+	return r.a, r.b, err
+}
+
+// AsyncWriteVQTContext starts a write like AsyncWriteVQT, see AsyncReadContext for ctx
+func (g *OPCGroup) AsyncWriteVQTContext(ctx context.Context, serverHandles []uint32, values []interface{}, qualities []com.Quality, timestamps []time.Time, clientTransactionID uint32) (uint32, []error, error) {
+	r, err := callContext(ctx, g.connectionState(), func() (r pair[uint32, []error], err error) {
+		r.a, r.b, err = g.AsyncWriteVQT(serverHandles, values, qualities, timestamps, clientTransactionID)
+		return
+	}, g.cancelStarted)
+	return r.a, r.b, err
+}
+
+// cancelStarted cancels an abandoned transaction when one of its items succeeded, the server started none otherwise
+func (g *OPCGroup) cancelStarted(r pair[uint32, []error]) {
+	if anySucceeded(r.b) {
+		_ = g.AsyncCancel(r.a)
+	}
+}
+
+// ReadContext reads the item like Read, see GetStatusContext for ctx
+func (i *OPCItem) ReadContext(ctx context.Context, source com.OPCDATASOURCE) (value interface{}, quality com.Quality, timestamp time.Time, err error) {
+	state, err := callContext(ctx, i.parent.connectionState(), func() (state com.ItemState, err error) {
+		state.Value, state.Quality, state.Timestamp, err = i.Read(source)
+		return
+	}, nil)
+	return state.Value, state.Quality, state.Timestamp, err
+}
+
+// WriteContext writes the item like Write, see SyncWriteContext for ctx
+func (i *OPCItem) WriteContext(ctx context.Context, value interface{}) error {
+	_, err := callContext(ctx, i.parent.connectionState(), func() (struct{}, error) {
+		return struct{}{}, i.Write(value)
+	}, nil)
+	return err
+}
+
+// connectionState returns the state of the connection of the server, nil when there is none
+func (s *OPCServer) connectionState() *connectionState {
+	if s == nil {
+		return nil
+	}
+	return &s.state
+}
+
+// connectionState returns the state of the connection of the group, nil when there is none
+func (g *OPCGroup) connectionState() *connectionState {
+	if g == nil || g.parent == nil {
+		return nil
+	}
+	return g.parent.parent.connectionState()
+}
+
+// connectionState returns the state of the connection of the items, nil when there is none
+func (is *OPCItems) connectionState() *connectionState {
+	if is == nil {
+		return nil
+	}
+	return is.parent.connectionState()
+}
@@ -0,0 +1,206 @@
+package opcda_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/opcsim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blocker blocks the backend calls of a method until they are unblocked, like a remote host that disappeared. Its
+// intercept method is an Interceptor, see wrapTransport.
+type blocker struct {
+	lock    sync.Mutex
+	method  string
+	release chan struct{}
+}
+
+func (b *blocker) intercept(method string, invoke func() error) error {
+	b.lock.Lock()
+	release := b.release
+	if method != b.method {
+		release = nil
+	}
+	b.lock.Unlock()
+	if release != nil {
+		<-release
+	}
+	return invoke()
+}
+
+// block blocks the calls of method until unblock is called
+func (b *blocker) block(method string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.method, b.release = method, make(chan struct{})
+}
+
+func (b *blocker) unblock() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	close(b.release)
+	b.method, b.release = "", nil
+}
+
+func TestContextDeadline(t *testing.T) {
+	blocking := &blocker{}
+	_, server := connectSim(t, withDecorator(wrapTransport(blocking.intercept, nil)))
+	group, err := server.GetOPCGroups().AddContext(context.Background(), "context")
+	require.NoError(t, err)
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	require.NoError(t, item.WriteContext(context.Background(), 1.5))
+
+	blocking.block("IOPCSyncIO.Read")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, _, err = item.ReadContext(ctx, opcda.OPC_DS_DEVICE)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, server.GetIsSuspect())
+	// the calls fail at once while the abandoned call has not returned
+	_, _, err = group.SyncReadContext(context.Background(), opcda.OPC_DS_DEVICE, []uint32{item.GetServerHandle()})
+	assert.ErrorIs(t, err, opcda.ErrConnectionSuspect)
+
+	blocking.unblock()
+	require.Eventually(t, func() bool {
+		return !server.GetIsSuspect()
+	}, 5*time.Second, time.Millisecond)
+	states, errs, err := group.SyncReadContext(context.Background(), opcda.OPC_DS_DEVICE, []uint32{item.GetServerHandle()})
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	assert.Equal(t, 1.5, states[0].Value)
+	status, err := server.GetStatusContext(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, status)
+}
+
+func TestContextAbandonedAdd(t *testing.T) {
+	blocking := &blocker{}
+	sim, server := connectSim(t, withDecorator(wrapTransport(blocking.intercept, nil)))
+	group := addGroup(t, server, "context")
+
+	blocking.block("IOPCItemMgt.AddItems")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := group.OPCItems().AddItemsContext(ctx, []string{"Bucket Brigade.Real8", "Random.Int4"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	blocking.unblock()
+	// the items the abandoned call added are removed
+	require.Eventually(t, func() bool {
+		return !server.GetIsSuspect()
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, 0, group.OPCItems().GetCount())
+
+	blocking.block("Transport.Connect")
+	defer blocking.unblock()
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = opcda.ConnectContext(ctx, opcsim.DefaultProgID, "", opcda.WithTransport(wrapTransport(blocking.intercept, nil)(sim)))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestContextFailedAbandonedCall(t *testing.T) {
+	blocking := &blocker{}
+	_, server := connectSim(t, withDecorator(wrapTransport(blocking.intercept, nil)))
+
+	// the call fails for an unknown item, the connection is cleared all the same once it returns
+	blocking.block("IOPCItemProperties.GetItemProperties")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := server.GetItemPropertiesContext(ctx, "Unknown", []uint32{1})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, server.GetIsSuspect())
+	blocking.unblock()
+	require.Eventually(t, func() bool {
+		return !server.GetIsSuspect()
+	}, 5*time.Second, time.Millisecond)
+	_, _, err = server.GetItemPropertiesContext(context.Background(), "Unknown", []uint32{1})
+	assert.Error(t, err)
+}
+
+func TestContextAbandonedAsyncRead(t *testing.T) {
+	blocking := &blocker{}
+	_, server := connectSim(t, withSimOptions(opcsim.WithLatency(time.Second)), withDecorator(wrapTransport(blocking.intercept, nil)))
+	group := addGroup(t, server, "context")
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	cancelComplete := make(chan *opcda.CancelCompleteCallBackData, 1)
+	require.NoError(t, group.RegisterCancelComplete(cancelComplete))
+
+	// the transaction the abandoned call started is cancelled
+	blocking.block("IOPCAsyncIO2.Read")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err = group.AsyncReadContext(ctx, []uint32{item.GetServerHandle()}, 7)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	blocking.unblock()
+	select {
+	case data := <-cancelComplete:
+		assert.Equal(t, uint32(7), data.TransID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestContextVariants(t *testing.T) {
+	_, server := connectSim(t)
+	ctx := context.Background()
+
+	result, err := server.BrowseContext(ctx, "", nil, 0, false)
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Elements)
+	propertyIDs, _, _, err := server.QueryAvailablePropertiesContext(ctx, "Bucket Brigade.Real8")
+	require.NoError(t, err)
+	assert.NotEmpty(t, propertyIDs)
+	_, errs, err := server.LookupItemIDsContext(ctx, "Bucket Brigade.Real8", propertyIDs[:1])
+	require.NoError(t, err)
+	assert.Len(t, errs, 1)
+
+	group := addGroup(t, server, "context")
+	items := group.OPCItems()
+	errs, err = items.ValidateContext(ctx, []string{"Bucket Brigade.Real8", "Unknown"}, nil, nil)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+	item, err := items.AddItem("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	handles := []uint32{item.GetServerHandle()}
+	errs, err = items.SetActiveContext(ctx, handles, false)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.False(t, item.GetIsActive())
+
+	errs, err = group.SyncWriteVQTContext(ctx, handles, []interface{}{3.5}, nil, nil)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	states, errs, err := group.SyncReadMaxAgeContext(ctx, handles, []uint32{0})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, 3.5, states[0].Value)
+
+	readComplete := make(chan *opcda.ReadCompleteCallBackData, 1)
+	require.NoError(t, group.RegisterReadComplete(readComplete))
+	_, errs, err = group.AsyncReadContext(ctx, handles, 9)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	select {
+	case data := <-readComplete:
+		assert.Equal(t, uint32(9), data.TransID)
+		assert.Equal(t, []interface{}{3.5}, data.Values)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	require.NoError(t, items.RemoveContext(ctx, handles))
+	assert.Equal(t, 0, items.GetCount())
+	require.NoError(t, server.GetOPCGroups().RemoveContext(ctx, group.GetServerHandle()))
+	assert.Equal(t, 0, server.GetOPCGroups().GetCount())
+	assert.NoError(t, server.DisconnectContext(ctx))
+}
@@ -0,0 +1,216 @@
+package opcda
+
+import (
+	"errors"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+// GetClientConversion get whether the client converts the values of the items whose requested data type the server
+// rejects, see SetClientConversion
+func (is *OPCItems) GetClientConversion() bool {
+	is.RLock()
+	defer is.RUnlock()
+	return is.clientConversion
+}
+
+// SetClientConversion set whether the client converts the values of the items whose requested data type the server
+// rejects with OPC_E_BADTYPE. Such an item is added, or keeps being read, with its canonical data type and the client
+// converts its values with com.ChangeType: the values read and received in callbacks to the requested data type and
+// the values written to the canonical data type. It applies to the items added and the requested data types set
+// afterwards.
+func (is *OPCItems) SetClientConversion(clientConversion bool) {
+	is.Lock()
+	defer is.Unlock()
+	is.clientConversion = clientConversion
+}
+
+// addConverted adds the items whose requested data type the server rejects again with their canonical data type when
+// client conversion is enabled, their results and errors are replaced. It returns which items the client converts.
+// The caller holds the lock.
+func (is *OPCItems) addConverted(items []com.ItemDefinition, results []com.TagOPCITEMRESULTStruct, errs []int32) []bool {
+	if !is.clientConversion {
+		return nil
+	}
+	var retry []com.ItemDefinition
+	var index []int
+	for j, item := range items {
+		if errs[j] == int32(OPCBadType) && item.RequestedDataType != com.VT_EMPTY {
+			item.RequestedDataType = com.VT_EMPTY
+			retry = append(retry, item)
+			index = append(index, j)
+		}
+	}
+	if len(retry) == 0 {
+		return nil
+	}
+	retryResults, retryErrs, err := is.itemMgt.AddItems(retry)
+	if err != nil {
+		// the items keep the error of the requested data type
+		return nil
+	}
+	converted := make([]bool, len(items))
+	for k, j := range index {
+		if retryErrs[k] >= 0 {
+			results[j], errs[j], converted[j] = retryResults[k], retryErrs[k], true
+		}
+	}
+	return converted
+}
+
+// setRequestedDataType records the requested data type of an item and whether the client converts its values
+func (is *OPCItems) setRequestedDataType(item *OPCItem, requestedDataType com.VT, clientConverted bool) {
+	is.Lock()
+	defer is.Unlock()
+	item.requestedDataType = requestedDataType
+	item.clientConverted = clientConverted
+	if clientConverted {
+		is.converted[item.serverHandle] = item
+	} else {
+		delete(is.converted, item.serverHandle)
+	}
+}
+
+// conversionError is the item error of a value the client cannot convert, the error the server reports for it
+func (is *OPCItems) conversionError(err error) error {
+	if errors.Is(err, com.ErrOverflow) {
+		return is.getError(int32(OPCRange))
+	}
+	return is.getError(int32(OPCBadType))
+}
+
+// convertRead converts a value read from the server to the requested data type of the item
+func (i *OPCItem) convertRead(value interface{}) (interface{}, error) {
+	if !i.clientConverted {
+		return value, nil
+	}
+	converted, err := com.ChangeType(value, i.requestedDataType)
+	if err != nil {
+		return nil, i.parent.conversionError(err)
+	}
+	return converted, nil
+}
+
+// convertWrite converts a value written to the item to its canonical data type
+func (i *OPCItem) convertWrite(value interface{}) (interface{}, error) {
+	if !i.clientConverted {
+		return value, nil
+	}
+	converted, err := com.ChangeType(value, i.nativeDataType)
+	if err != nil {
+		return nil, i.parent.conversionError(err)
+	}
+	return converted, nil
+}
+
+// convertStates converts the values read by server handle in place, an item whose value cannot be converted gets the
+// conversion error and a bad quality
+func (is *OPCItems) convertStates(serverHandles []uint32, states []*com.ItemState, errs []error) {
+	is.RLock()
+	defer is.RUnlock()
+	if len(is.converted) == 0 {
+		return
+	}
+	for i, handle := range serverHandles {
+		item, ok := is.converted[handle]
+		if !ok || i >= len(states) || states[i] == nil || errs[i] != nil {
+			continue
+		}
+		states[i].Value, errs[i] = item.convertRead(states[i].Value)
+		if errs[i] != nil {
+			states[i].Quality = com.QualityBad
+		}
+	}
+}
+
+// convertCallback converts the values of a callback by client handle, it returns copies of values and qualities
+// when it changes them
+func (is *OPCItems) convertCallback(clientHandles []uint32, values []interface{}, qualities []com.Quality, errs []error) ([]interface{}, []com.Quality) {
+	is.RLock()
+	defer is.RUnlock()
+	if len(is.converted) == 0 {
+		return values, qualities
+	}
+	items := make(map[uint32]*OPCItem, len(is.converted))
+	for _, item := range is.converted {
+		items[item.clientHandle] = item
+	}
+	values = append([]interface{}(nil), values...)
+	qualities = append([]com.Quality(nil), qualities...)
+	for i, handle := range clientHandles {
+		item, ok := items[handle]
+		if !ok || i >= len(values) || i >= len(errs) || errs[i] != nil {
+			continue
+		}
+		values[i], errs[i] = item.convertRead(values[i])
+		if errs[i] != nil && i < len(qualities) {
+			qualities[i] = com.QualityBad
+		}
+	}
+	return values, qualities
+}
+
+// convertWrites converts the values written by server handle to the canonical data types. It returns the server
+// handles and values of the items whose value could be converted with their positions, the other items get the
+// conversion error.
+func (is *OPCItems) convertWrites(serverHandles []uint32, values []interface{}) ([]uint32, []interface{}, []int, []error) {
+	errs := make([]error, len(serverHandles))
+	index := make([]int, len(serverHandles))
+	for i := range index {
+		index[i] = i
+	}
+	is.RLock()
+	defer is.RUnlock()
+	if len(is.converted) == 0 || len(values) != len(serverHandles) {
+		return serverHandles, values, index, errs
+	}
+	handles := make([]uint32, 0, len(serverHandles))
+	converted := make([]interface{}, 0, len(values))
+	index = index[:0]
+	for i, handle := range serverHandles {
+		value := values[i]
+		if item, ok := is.converted[handle]; ok {
+			var err error
+			value, err = item.convertWrite(value)
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+		}
+		handles = append(handles, handle)
+		converted = append(converted, value)
+		index = append(index, i)
+	}
+	return handles, converted, index, errs
+}
+
+// writeConverted makes a write call with the values converted by convertWrites, write gets the positions of the items
+// it writes in the original call. The item errors of the call are merged with the conversion errors.
+func (g *OPCGroup) writeConverted(serverHandles []uint32, values []interface{}, write func(serverHandles []uint32, values []interface{}, index []int) ([]int32, error)) ([]error, error) {
+	handles, converted, index, errs := g.items.convertWrites(serverHandles, values)
+	if len(handles) == 0 && len(serverHandles) > 0 {
+		// no value could be converted, the server is not called
+		return errs, nil
+	}
+	codes, err := write(handles, converted, index)
+	if err != nil {
+		return nil, err
+	}
+	for k, i := range index {
+		if k < len(codes) && codes[k] < 0 {
+			errs[i] = g.getError(codes[k])
+		}
+	}
+	return errs, nil
+}
+
+// convertedVQTs returns the VQTs of the items at index with the converted values
+func convertedVQTs(vqts []*com.ItemVQT, values []interface{}, index []int) []*com.ItemVQT {
+	converted := make([]*com.ItemVQT, len(index))
+	for k, i := range index {
+		vqt := *vqts[i]
+		vqt.Value = values[k]
+		converted[k] = &vqt
+	}
+	return converted
+}
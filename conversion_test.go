@@ -0,0 +1,139 @@
+package opcda_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// strictGroup is the group of a server that converts nothing, it rejects every requested data type with
+// OPC_E_BADTYPE
+type strictGroup struct {
+	opcda.GroupBackend
+}
+
+func newStrictGroup(group opcda.GroupBackend) opcda.GroupBackend {
+	return &strictGroup{GroupBackend: group}
+}
+
+func (g *strictGroup) QueryItemMgt() (opcda.ItemMgtBackend, error) {
+	itemMgt, err := g.GroupBackend.QueryItemMgt()
+	if err != nil {
+		return nil, err
+	}
+	return &strictItemMgt{ItemMgtBackend: itemMgt}, nil
+}
+
+type strictItemMgt struct {
+	opcda.ItemMgtBackend
+}
+
+func (m *strictItemMgt) AddItems(items []com.ItemDefinition) ([]com.TagOPCITEMRESULTStruct, []int32, error) {
+	results, errs, err := m.ItemMgtBackend.AddItems(items)
+	if err != nil {
+		return nil, nil, err
+	}
+	var remove []uint32
+	for i, item := range items {
+		if errs[i] >= 0 && item.RequestedDataType != com.VT_EMPTY {
+			remove = append(remove, results[i].Server)
+			results[i], errs[i] = com.TagOPCITEMRESULTStruct{}, int32(opcda.OPCBadType)
+		}
+	}
+	if len(remove) > 0 {
+		_, err = m.ItemMgtBackend.RemoveItems(remove)
+	}
+	return results, errs, err
+}
+
+func (m *strictItemMgt) SetDatatypes(serverHandles []uint32, requestedDataTypes []com.VT) ([]int32, error) {
+	errs := make([]int32, len(serverHandles))
+	for i, vt := range requestedDataTypes {
+		if vt != com.VT_EMPTY {
+			errs[i] = int32(opcda.OPCBadType)
+		}
+	}
+	return errs, nil
+}
+
+func assertItemError(t *testing.T, code uint32, err error) {
+	t.Helper()
+	var opcErr *opcda.OPCError
+	if assert.ErrorAs(t, err, &opcErr) {
+		assert.Equal(t, int32(code), opcErr.ErrorCode)
+	}
+}
+
+func TestClientConversion(t *testing.T) {
+	sim, server := connectSim(t, withDecorator(wrapTransport(nil, newStrictGroup)))
+	require.NoError(t, sim.SetValue("Bucket Brigade.Real8", 2.5))
+	group := addGroup(t, server, "conversion")
+	items := group.OPCItems()
+	items.SetDefaultRequestedDataType(com.VT_BSTR)
+	_, err := items.AddItem("Bucket Brigade.Real8")
+	assertItemError(t, opcda.OPCBadType, err)
+
+	items.SetClientConversion(true)
+	assert.True(t, items.GetClientConversion())
+	item, err := items.AddItem("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	assert.True(t, item.GetClientConverted())
+	assert.Equal(t, com.VT_BSTR, item.GetRequestedDataType())
+	assert.Equal(t, com.VT_R8, item.GetCanonicalDataType())
+	value, _, _, err := item.Read(opcda.OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, "2.5", value)
+
+	// writes are converted to the canonical data type
+	require.NoError(t, item.Write("-12.25"))
+	stored, _, _, err := sim.Value("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	assert.Equal(t, -12.25, stored)
+	errs, err := group.SyncWrite([]uint32{item.GetServerHandle()}, []interface{}{"text"})
+	require.NoError(t, err)
+	assertItemError(t, opcda.OPCBadType, errs[0])
+	stored, _, _, err = sim.Value("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	assert.Equal(t, -12.25, stored)
+
+	require.NoError(t, item.SetRequestedDataType(com.VT_I1))
+	states, errs, err := group.SyncRead(opcda.OPC_DS_DEVICE, []uint32{item.GetServerHandle()})
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	assert.Equal(t, int8(-12), states[0].Value)
+	require.NoError(t, sim.SetValue("Bucket Brigade.Real8", 1000.0))
+	states, errs, err = group.SyncRead(opcda.OPC_DS_DEVICE, []uint32{item.GetServerHandle()})
+	require.NoError(t, err)
+	assertItemError(t, opcda.OPCRange, errs[0])
+	assert.Nil(t, states[0].Value)
+	assert.Equal(t, com.QualityBad, states[0].Quality)
+
+	// data changes are converted like reads
+	require.NoError(t, item.SetRequestedDataType(com.VT_BOOL))
+	dataChange := make(chan *opcda.DataChangeCallBackData, 10)
+	require.NoError(t, group.RegisterDataChange(dataChange))
+	require.NoError(t, sim.SetValue("Bucket Brigade.Real8", 0.0))
+	// the first data change may still hold the previous value
+	for changed := false; !changed; {
+		select {
+		case data := <-dataChange:
+			require.Equal(t, []uint32{item.GetClientHandle()}, data.ItemClientHandles)
+			require.NoError(t, data.Errors[0])
+			require.IsType(t, false, data.Values[0])
+			changed = !data.Values[0].(bool)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+
+	// the item is no longer converted once the server accepts its requested data type
+	require.NoError(t, item.SetRequestedDataType(com.VT_EMPTY))
+	assert.False(t, item.GetClientConverted())
+	value, _, _, err = item.Read(opcda.OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, value)
+}
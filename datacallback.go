@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package opcda
 
 import (
@@ -10,21 +13,11 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-var IID_IOPCDataCallback = windows.GUID{
-	Data1: 0x39c13a70,
-	Data2: 0x011e,
-	Data3: 0x11d0,
-	Data4: [8]byte{0x96, 0x75, 0x00, 0x20, 0xaf, 0xd8, 0xad, 0xb3},
-}
-
 type DataEventReceiver struct {
-	lpVtbl                 *DataEventReceiverVtbl
-	ref                    int32
-	clsid                  *windows.GUID
-	dataChangeReceiver     chan *CDataChangeCallBackData
-	readCompleteReceiver   chan *CReadCompleteCallBackData
-	writeCompleteReceiver  chan *CWriteCompleteCallBackData
-	cancelCompleteReceiver chan *CCancelCompleteCallBackData
+	lpVtbl   *DataEventReceiverVtbl
+	ref      int32
+	clsid    *windows.GUID
+	callback DataCallback
 }
 
 type DataEventReceiverVtbl struct {
@@ -43,6 +36,15 @@ func NewDataEventReceiver(
 	writeCompleteReceiver chan *CWriteCompleteCallBackData,
 	cancelCompleteReceiver chan *CCancelCompleteCallBackData,
 ) *DataEventReceiver {
+	return newDataEventReceiver(&channelDataCallback{
+		dataChangeReceiver:     dataChangeReceiver,
+		readCompleteReceiver:   readCompleteReceiver,
+		writeCompleteReceiver:  writeCompleteReceiver,
+		cancelCompleteReceiver: cancelCompleteReceiver,
+	})
+}
+
+func newDataEventReceiver(callback DataCallback) *DataEventReceiver {
 	return &DataEventReceiver{
 		lpVtbl: &DataEventReceiverVtbl{
 			pQueryInterface:   syscall.NewCallback(DataQueryInterface),
@@ -53,12 +55,9 @@ func NewDataEventReceiver(
 			pOnWriteComplete:  syscall.NewCallback(DataOnWriteComplete),
 			pOnCancelComplete: syscall.NewCallback(DataOnCancelComplete),
 		},
-		ref:                    0,
-		clsid:                  &IID_IOPCDataCallback,
-		dataChangeReceiver:     dataChangeReceiver,
-		readCompleteReceiver:   readCompleteReceiver,
-		writeCompleteReceiver:  writeCompleteReceiver,
-		cancelCompleteReceiver: cancelCompleteReceiver,
+		ref:      0,
+		clsid:    &IID_IOPCDataCallback,
+		callback: callback,
 	}
 }
 
@@ -85,33 +84,30 @@ func DataRelease(this unsafe.Pointer) uintptr {
 	return uintptr(er.ref)
 }
 
-type CDataChangeCallBackData struct {
-	TransID           uint32
-	GroupHandle       uint32
-	MasterQuality     int32
-	MasterErr         int32
-	ItemClientHandles []uint32
-	Values            []interface{}
-	Qualities         []uint16
-	TimeStamps        []time.Time
-	Errors            []int32
-}
-
 func DataOnDataChange(this unsafe.Pointer, dwTransid uint32, hGroup uint32, hrMasterquality int32, hrMastererror int32, dwCount uint32, phClientItems unsafe.Pointer, pvValues unsafe.Pointer, pwQualities unsafe.Pointer, pftTimeStamps unsafe.Pointer, pErrors unsafe.Pointer) uintptr {
 	er := (*DataEventReceiver)(this)
 	clientHandles := make([]uint32, dwCount)
 	values := make([]interface{}, dwCount)
-	qualities := make([]uint16, dwCount)
+	qualities := make([]com.Quality, dwCount)
 	timestamps := make([]time.Time, dwCount)
 	errors := make([]int32, dwCount)
+	var decodeErrors []error
 	for i := 0; i < int(dwCount); i++ {
 		clientHandles[i] = *(*uint32)(unsafe.Pointer(uintptr(phClientItems) + uintptr(i)*unsafe.Sizeof(uint32(0))))
 		variant := *(*com.VARIANT)(unsafe.Pointer(uintptr(pvValues) + uintptr(i)*unsafe.Sizeof(com.VARIANT{})))
-		values[i] = variant.Value()
-		qualities[i] = *(*uint16)(unsafe.Pointer(uintptr(pwQualities) + uintptr(i)*unsafe.Sizeof(uint16(0))))
+		qualities[i] = *(*com.Quality)(unsafe.Pointer(uintptr(pwQualities) + uintptr(i)*unsafe.Sizeof(uint16(0))))
 		ft := *(*windows.Filetime)(unsafe.Pointer(uintptr(pftTimeStamps) + uintptr(i)*unsafe.Sizeof(windows.Filetime{})))
 		timestamps[i] = time.Unix(0, ft.Nanoseconds())
 		errors[i] = *(*int32)(unsafe.Pointer(uintptr(pErrors) + uintptr(i)*unsafe.Sizeof(int32(0))))
+		value, err := variant.Value()
+		if err != nil && errors[i] >= 0 {
+			if decodeErrors == nil {
+				decodeErrors = make([]error, dwCount)
+			}
+			decodeErrors[i] = err
+			errors[i] = com.BadVarType
+		}
+		values[i] = value
 	}
 	cb := &CDataChangeCallBackData{
 		TransID:           dwTransid,
@@ -123,38 +119,36 @@ func DataOnDataChange(this unsafe.Pointer, dwTransid uint32, hGroup uint32, hrMa
 		Qualities:         qualities,
 		TimeStamps:        timestamps,
 		Errors:            errors,
+		DecodeErrors:      decodeErrors,
 	}
-	er.dataChangeReceiver <- cb
+	er.callback.OnDataChange(cb)
 	return com.S_OK
 }
 
-type CReadCompleteCallBackData struct {
-	TransID           uint32
-	GroupHandle       uint32
-	MasterQuality     int32
-	MasterErr         int32
-	ItemClientHandles []uint32
-	Values            []interface{}
-	Qualities         []uint16
-	TimeStamps        []time.Time
-	Errors            []int32
-}
-
 func DataOnReadComplete(this unsafe.Pointer, dwTransid uint32, hGroup uint32, hrMasterquality int32, hrMastererror int32, dwCount uint32, phClientItems unsafe.Pointer, pvValues unsafe.Pointer, pwQualities unsafe.Pointer, pftTimeStamps unsafe.Pointer, pErrors unsafe.Pointer) uintptr {
 	er := (*DataEventReceiver)(this)
 	clientHandles := make([]uint32, dwCount)
 	values := make([]interface{}, dwCount)
-	qualities := make([]uint16, dwCount)
+	qualities := make([]com.Quality, dwCount)
 	timestamps := make([]time.Time, dwCount)
 	errors := make([]int32, dwCount)
+	var decodeErrors []error
 	for i := 0; i < int(dwCount); i++ {
 		clientHandles[i] = *(*uint32)(unsafe.Pointer(uintptr(phClientItems) + uintptr(i)*unsafe.Sizeof(uint32(0))))
 		variant := *(*com.VARIANT)(unsafe.Pointer(uintptr(pvValues) + uintptr(i)*unsafe.Sizeof(com.VARIANT{})))
-		values[i] = variant.Value()
-		qualities[i] = *(*uint16)(unsafe.Pointer(uintptr(pwQualities) + uintptr(i)*unsafe.Sizeof(uint16(0))))
+		qualities[i] = *(*com.Quality)(unsafe.Pointer(uintptr(pwQualities) + uintptr(i)*unsafe.Sizeof(uint16(0))))
 		ft := *(*windows.Filetime)(unsafe.Pointer(uintptr(pftTimeStamps) + uintptr(i)*unsafe.Sizeof(windows.Filetime{})))
 		timestamps[i] = time.Unix(0, ft.Nanoseconds())
 		errors[i] = *(*int32)(unsafe.Pointer(uintptr(pErrors) + uintptr(i)*unsafe.Sizeof(int32(0))))
+		value, err := variant.Value()
+		if err != nil && errors[i] >= 0 {
+			if decodeErrors == nil {
+				decodeErrors = make([]error, dwCount)
+			}
+			decodeErrors[i] = err
+			errors[i] = com.BadVarType
+		}
+		values[i] = value
 	}
 	cb := &CReadCompleteCallBackData{
 		TransID:           dwTransid,
@@ -166,19 +160,12 @@ func DataOnReadComplete(this unsafe.Pointer, dwTransid uint32, hGroup uint32, hr
 		Qualities:         qualities,
 		TimeStamps:        timestamps,
 		Errors:            errors,
+		DecodeErrors:      decodeErrors,
 	}
-	er.readCompleteReceiver <- cb
+	er.callback.OnReadComplete(cb)
 	return com.S_OK
 }
 
-type CWriteCompleteCallBackData struct {
-	TransID           uint32
-	GroupHandle       uint32
-	MasterErr         int32
-	ItemClientHandles []uint32
-	Errors            []int32
-}
-
 func DataOnWriteComplete(this unsafe.Pointer, dwTransid uint32, hGroup uint32, hrMastererr int32, dwCount uint32, pClienthandles unsafe.Pointer, pErrors unsafe.Pointer) uintptr {
 	er := (*DataEventReceiver)(this)
 	clientHandles := make([]uint32, dwCount)
@@ -194,21 +181,16 @@ func DataOnWriteComplete(this unsafe.Pointer, dwTransid uint32, hGroup uint32, h
 		ItemClientHandles: clientHandles,
 		Errors:            errors,
 	}
-	er.writeCompleteReceiver <- cb
+	er.callback.OnWriteComplete(cb)
 	return com.S_OK
 }
 
-type CCancelCompleteCallBackData struct {
-	TransID     uint32
-	GroupHandle uint32
-}
-
 func DataOnCancelComplete(this unsafe.Pointer, dwTransid uint32, hGroup uint32) uintptr {
 	er := (*DataEventReceiver)(this)
 	cb := &CCancelCompleteCallBackData{
 		TransID:     dwTransid,
 		GroupHandle: hGroup,
 	}
-	er.cancelCompleteReceiver <- cb
+	er.callback.OnCancelComplete(cb)
 	return com.S_OK
 }
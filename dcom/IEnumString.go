@@ -0,0 +1,38 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IEnumString is the ORPC proxy of IEnumString
+type IEnumString struct {
+	*Object
+}
+
+const opIEnumStringNext = 3
+
+// Next calls RemoteNext, fewer than celt strings are returned at the end of the enumeration
+func (sl *IEnumString) Next(celt uint32) (result []string, err error) {
+	err = sl.Call(opIEnumStringNext, func(w *ndr.Writer) {
+		w.WriteUint32(celt)
+	}, func(r *ndr.Reader) {
+		r.ReadCount(0)
+		r.ReadUint32()
+		n := r.ReadCount(4)
+		result = make([]string, n)
+		for i := range result {
+			if r.ReadPointer() {
+				i := i
+				r.Defer(func() {
+					result[i] = r.ReadString()
+				})
+			}
+		}
+		r.Flush()
+		fetched := r.ReadUint32()
+		if int(fetched) < len(result) {
+			result = result[:fetched]
+		}
+	})
+	return
+}
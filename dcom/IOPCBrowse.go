@@ -0,0 +1,136 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCBrowse is the ORPC proxy of the OPC DA 3.0 IOPCBrowse
+type IOPCBrowse struct {
+	*Object
+}
+
+const (
+	opIOPCBrowseGetProperties = 3
+	opIOPCBrowseBrowse        = 4
+)
+
+func (v *IOPCBrowse) GetProperties(pszItemIDs []string, bReturnPropertyValues bool, pdwPropertyIDs []uint32) (ppItemProperties []*com.ItemProperties, err error) {
+	err = v.Call(opIOPCBrowseGetProperties, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(pszItemIDs)))
+		writeStringArray(w, pszItemIDs)
+		writeBOOL(w, bReturnPropertyValues)
+		w.WriteUint32(uint32(len(pdwPropertyIDs)))
+		w.WriteUint32Array(pdwPropertyIDs)
+	}, func(r *ndr.Reader) {
+		if !r.ReadPointer() {
+			return
+		}
+		n := r.ReadCount(16)
+		ppItemProperties = make([]*com.ItemProperties, n)
+		for i := range ppItemProperties {
+			ppItemProperties[i] = &com.ItemProperties{}
+			readItemProperties(r, ppItemProperties[i])
+		}
+		r.Flush()
+	})
+	return
+}
+
+func (v *IOPCBrowse) Browse(
+	szItemID string,
+	pszContinuationPoint string,
+	dwMaxElementsReturned uint32,
+	dwBrowseFilter com.OPCBROWSEFILTER,
+	szElementNameFilter string,
+	szVendorFilter string,
+	bReturnAllProperties bool,
+	bReturnPropertyValues bool,
+	pdwPropertyIDs []uint32,
+) (pbMoreElements bool, newContinuationPoint string, ppBrowseElements []*com.BrowseElement, err error) {
+	err = v.Call(opIOPCBrowseBrowse, func(w *ndr.Writer) {
+		w.WriteString(szItemID)
+		w.WriteUniqueString(pszContinuationPoint, pszContinuationPoint == "")
+		w.WriteUint32(dwMaxElementsReturned)
+		w.WriteUint16(uint16(dwBrowseFilter))
+		w.WriteString(szElementNameFilter)
+		w.WriteString(szVendorFilter)
+		writeBOOL(w, bReturnAllProperties)
+		writeBOOL(w, bReturnPropertyValues)
+		w.WriteUint32(uint32(len(pdwPropertyIDs)))
+		w.WriteUint32Array(pdwPropertyIDs)
+	}, func(r *ndr.Reader) {
+		newContinuationPoint = r.ReadUniqueString()
+		pbMoreElements = r.ReadInt32() != 0
+		r.ReadUint32()
+		if !r.ReadPointer() {
+			return
+		}
+		n := r.ReadCount(32)
+		ppBrowseElements = make([]*com.BrowseElement, n)
+		for i := range ppBrowseElements {
+			element := &com.BrowseElement{}
+			ppBrowseElements[i] = element
+			if r.ReadPointer() {
+				r.Defer(func() {
+					element.Name = r.ReadString()
+				})
+			}
+			if r.ReadPointer() {
+				r.Defer(func() {
+					element.ItemID = r.ReadString()
+				})
+			}
+			element.Flags = r.ReadUint32()
+			r.ReadUint32()
+			readItemProperties(r, &element.Properties)
+		}
+		r.Flush()
+	})
+	return
+}
+
+// readItemProperties reads an embedded OPCITEMPROPERTIES, the property array is deferred to the next Flush
+func readItemProperties(r *ndr.Reader, properties *com.ItemProperties) {
+	properties.Error = r.ReadInt32()
+	r.ReadUint32()
+	present := r.ReadPointer()
+	r.ReadUint32()
+	if !present {
+		return
+	}
+	r.Defer(func() {
+		n := r.ReadCount(28)
+		properties.Properties = make([]*com.ItemProperty, n)
+		for i := range properties.Properties {
+			property := &com.ItemProperty{}
+			properties.Properties[i] = property
+			property.DataType = r.ReadUint16()
+			r.ReadUint16()
+			property.PropertyID = r.ReadUint32()
+			if r.ReadPointer() {
+				r.Defer(func() {
+					property.ItemID = r.ReadString()
+				})
+			}
+			if r.ReadPointer() {
+				r.Defer(func() {
+					property.Description = r.ReadString()
+				})
+			}
+			if r.ReadPointer() {
+				r.Defer(func() {
+					value, err := ReadVariant(r)
+					if property.Error >= 0 {
+						property.Value = value
+						if err != nil {
+							property.Error = com.BadVarType
+						}
+					}
+				})
+			}
+			property.Error = r.ReadInt32()
+			r.ReadUint32()
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCBrowseServerAddressSpace is the ORPC proxy of IOPCBrowseServerAddressSpace
+type IOPCBrowseServerAddressSpace struct {
+	*Object
+}
+
+const (
+	opIOPCBrowseQueryOrganization    = 3
+	opIOPCBrowseChangeBrowsePosition = 4
+	opIOPCBrowseBrowseOPCItemIDs     = 5
+	opIOPCBrowseGetItemID            = 6
+)
+
+func (v *IOPCBrowseServerAddressSpace) QueryOrganization() (pNameSpaceType com.OPCNAMESPACETYPE, err error) {
+	err = v.Call(opIOPCBrowseQueryOrganization, nil, func(r *ndr.Reader) {
+		pNameSpaceType = com.OPCNAMESPACETYPE(r.ReadUint16())
+	})
+	return
+}
+
+func (v *IOPCBrowseServerAddressSpace) ChangeBrowsePosition(dwBrowseDirection com.OPCBROWSEDIRECTION, szString string) (err error) {
+	return v.Call(opIOPCBrowseChangeBrowsePosition, func(w *ndr.Writer) {
+		w.WriteUint16(uint16(dwBrowseDirection))
+		w.WriteString(szString)
+	}, nil)
+}
+
+func (v *IOPCBrowseServerAddressSpace) BrowseOPCItemIDs(dwBrowseFilterType com.OPCBROWSETYPE, szFilterCriteria string, vtDataTypeFilter uint16, dwAccessRightsFilter uint32) (result []string, err error) {
+	var objRef []byte
+	err = v.Call(opIOPCBrowseBrowseOPCItemIDs, func(w *ndr.Writer) {
+		w.WriteUint16(uint16(dwBrowseFilterType))
+		w.WriteString(szFilterCriteria)
+		w.WriteUint16(vtDataTypeFilter)
+		w.WriteUint32(dwAccessRightsFilter)
+	}, func(r *ndr.Reader) {
+		objRef = ReadInterfacePointer(r)
+	})
+	if err != nil || objRef == nil {
+		return
+	}
+	object, err := v.Client().UnmarshalInterface(objRef)
+	if err != nil {
+		return
+	}
+	enum := &IEnumString{object}
+	defer func() {
+		enum.Release()
+	}()
+	for {
+		var batch []string
+		batch, err = enum.Next(100)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, batch...)
+		if len(batch) < 100 {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (v *IOPCBrowseServerAddressSpace) GetItemID(szItemDataID string) (szItemID string, err error) {
+	err = v.Call(opIOPCBrowseGetItemID, func(w *ndr.Writer) {
+		w.WriteString(szItemDataID)
+	}, func(r *ndr.Reader) {
+		szItemID = r.ReadUniqueString()
+	})
+	return
+}
@@ -0,0 +1,56 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCCommon is the ORPC proxy of IOPCCommon
+type IOPCCommon struct {
+	*Object
+}
+
+const (
+	opIOPCCommonSetLocaleID             = 3
+	opIOPCCommonGetLocaleID             = 4
+	opIOPCCommonQueryAvailableLocaleIDs = 5
+	opIOPCCommonGetErrorString          = 6
+	opIOPCCommonSetClientName           = 7
+)
+
+func (v *IOPCCommon) SetLocaleID(dwLcid uint32) (err error) {
+	return v.Call(opIOPCCommonSetLocaleID, func(w *ndr.Writer) {
+		w.WriteUint32(dwLcid)
+	}, nil)
+}
+
+func (v *IOPCCommon) GetLocaleID() (pdwLcid uint32, err error) {
+	err = v.Call(opIOPCCommonGetLocaleID, nil, func(r *ndr.Reader) {
+		pdwLcid = r.ReadUint32()
+	})
+	return
+}
+
+func (v *IOPCCommon) QueryAvailableLocaleIDs() (result []uint32, err error) {
+	err = v.Call(opIOPCCommonQueryAvailableLocaleIDs, nil, func(r *ndr.Reader) {
+		r.ReadUint32()
+		if r.ReadPointer() {
+			result = r.ReadUint32Array()
+		}
+	})
+	return
+}
+
+func (v *IOPCCommon) GetErrorString(dwError uint32) (str string, err error) {
+	err = v.Call(opIOPCCommonGetErrorString, func(w *ndr.Writer) {
+		w.WriteUint32(dwError)
+	}, func(r *ndr.Reader) {
+		str = r.ReadUniqueString()
+	})
+	return
+}
+
+func (v *IOPCCommon) SetClientName(szName string) (err error) {
+	return v.Call(opIOPCCommonSetClientName, func(w *ndr.Writer) {
+		w.WriteString(szName)
+	}, nil)
+}
@@ -0,0 +1,62 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCGroupStateMgt is the ORPC proxy of IOPCGroupStateMgt
+type IOPCGroupStateMgt struct {
+	*Object
+}
+
+const (
+	opIOPCGroupStateMgtGetState = 3
+	opIOPCGroupStateMgtSetState = 4
+	opIOPCGroupStateMgtSetName  = 5
+)
+
+func (sl *IOPCGroupStateMgt) GetState() (pUpdateRate uint32, pActive bool, ppName string, pTimeBias int32, pPercentDeadband float32, pLCID uint32, phClientGroup uint32, phServerGroup uint32, err error) {
+	err = sl.Call(opIOPCGroupStateMgtGetState, nil, func(r *ndr.Reader) {
+		pUpdateRate = r.ReadUint32()
+		pActive = r.ReadInt32() != 0
+		ppName = r.ReadUniqueString()
+		pTimeBias = r.ReadInt32()
+		pPercentDeadband = r.ReadFloat32()
+		pLCID = r.ReadUint32()
+		phClientGroup = r.ReadUint32()
+		phServerGroup = r.ReadUint32()
+	})
+	return
+}
+
+func (sl *IOPCGroupStateMgt) SetState(requestedUpdateRate *uint32, pActive *int32, pTimeBias *int32, pPercentDeadband *float32, pLCID *uint32, phClientGroup *uint32) (pRevisedUpdateRate uint32, err error) {
+	err = sl.Call(opIOPCGroupStateMgtSetState, func(w *ndr.Writer) {
+		if w.WritePointer(requestedUpdateRate != nil) {
+			w.WriteUint32(*requestedUpdateRate)
+		}
+		if w.WritePointer(pActive != nil) {
+			w.WriteInt32(*pActive)
+		}
+		if w.WritePointer(pTimeBias != nil) {
+			w.WriteInt32(*pTimeBias)
+		}
+		if w.WritePointer(pPercentDeadband != nil) {
+			w.WriteFloat32(*pPercentDeadband)
+		}
+		if w.WritePointer(pLCID != nil) {
+			w.WriteUint32(*pLCID)
+		}
+		if w.WritePointer(phClientGroup != nil) {
+			w.WriteUint32(*phClientGroup)
+		}
+	}, func(r *ndr.Reader) {
+		pRevisedUpdateRate = r.ReadUint32()
+	})
+	return
+}
+
+func (sl *IOPCGroupStateMgt) SetName(szName string) error {
+	return sl.Call(opIOPCGroupStateMgtSetName, func(w *ndr.Writer) {
+		w.WriteString(szName)
+	}, nil)
+}
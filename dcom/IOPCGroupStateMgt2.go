@@ -0,0 +1,31 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCGroupStateMgt2 is the ORPC proxy of the methods the OPC DA 3.0 IOPCGroupStateMgt2 adds to IOPCGroupStateMgt
+type IOPCGroupStateMgt2 struct {
+	*Object
+}
+
+const (
+	opIOPCGroupStateMgt2SetKeepAlive = 7
+	opIOPCGroupStateMgt2GetKeepAlive = 8
+)
+
+func (sl *IOPCGroupStateMgt2) SetKeepAlive(keepAliveTime uint32) (revised uint32, err error) {
+	err = sl.Call(opIOPCGroupStateMgt2SetKeepAlive, func(w *ndr.Writer) {
+		w.WriteUint32(keepAliveTime)
+	}, func(r *ndr.Reader) {
+		revised = r.ReadUint32()
+	})
+	return
+}
+
+func (sl *IOPCGroupStateMgt2) GetKeepAlive() (keepAliveTime uint32, err error) {
+	err = sl.Call(opIOPCGroupStateMgt2GetKeepAlive, nil, func(r *ndr.Reader) {
+		keepAliveTime = r.ReadUint32()
+	})
+	return
+}
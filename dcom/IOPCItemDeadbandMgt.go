@@ -0,0 +1,70 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCItemDeadbandMgt is the ORPC proxy of the OPC DA 3.0 IOPCItemDeadbandMgt
+type IOPCItemDeadbandMgt struct {
+	*Object
+}
+
+const (
+	opIOPCItemDeadbandMgtSetItemDeadband   = 3
+	opIOPCItemDeadbandMgtGetItemDeadband   = 4
+	opIOPCItemDeadbandMgtClearItemDeadband = 5
+)
+
+func (sl *IOPCItemDeadbandMgt) SetItemDeadband(serverHandles []uint32, percentDeadbands []float32) ([]int32, error) {
+	var errors []int32
+	err := sl.Call(opIOPCItemDeadbandMgtSetItemDeadband, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+		w.WriteUint32(uint32(len(percentDeadbands)))
+		for _, deadband := range percentDeadbands {
+			w.WriteFloat32(deadband)
+		}
+	}, func(r *ndr.Reader) {
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
+
+func (sl *IOPCItemDeadbandMgt) GetItemDeadband(serverHandles []uint32) ([]float32, []int32, error) {
+	var deadbands []float32
+	var errors []int32
+	err := sl.Call(opIOPCItemDeadbandMgtGetItemDeadband, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+	}, func(r *ndr.Reader) {
+		if r.ReadPointer() {
+			n := r.ReadCount(4)
+			deadbands = make([]float32, n)
+			for i := range deadbands {
+				deadbands[i] = r.ReadFloat32()
+			}
+		}
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return deadbands, errors, nil
+}
+
+func (sl *IOPCItemDeadbandMgt) ClearItemDeadband(serverHandles []uint32) ([]int32, error) {
+	var errors []int32
+	err := sl.Call(opIOPCItemDeadbandMgtClearItemDeadband, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+	}, func(r *ndr.Reader) {
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
@@ -0,0 +1,125 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCItemIO is the ORPC proxy of the OPC DA 3.0 IOPCItemIO
+type IOPCItemIO struct {
+	*Object
+}
+
+const (
+	opIOPCItemIORead     = 3
+	opIOPCItemIOWriteVQT = 4
+)
+
+func (v *IOPCItemIO) Read(pszItemIDs []string, pdwMaxAge []uint32) ([]*com.ItemState, []int32, error) {
+	var states []*com.ItemState
+	var errors []int32
+	err := v.Call(opIOPCItemIORead, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(pszItemIDs)))
+		writeStringArray(w, pszItemIDs)
+		w.WriteUint32Array(pdwMaxAge)
+	}, func(r *ndr.Reader) {
+		states, errors = readItemValues(r)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return states, errors, nil
+}
+
+// readItemValues reads the values, qualities, timestamps and errors returned by the Read methods of OPC DA 3.0,
+// the state of a failed item is nil
+func readItemValues(r *ndr.Reader) ([]*com.ItemState, []int32) {
+	values, decodeErrors := readVariantArray(r)
+	var qualities []uint16
+	if r.ReadPointer() {
+		n := r.ReadCount(2)
+		qualities = make([]uint16, n)
+		for i := range qualities {
+			qualities[i] = r.ReadUint16()
+		}
+	}
+	var timestamps []uint64
+	if r.ReadPointer() {
+		n := r.ReadCount(8)
+		timestamps = make([]uint64, n)
+		for i := range timestamps {
+			timestamps[i] = readFileTime(r)
+		}
+	}
+	errors := readHRESULTArray(r)
+	setDecodeErrors(errors, decodeErrors)
+	states := make([]*com.ItemState, len(errors))
+	for i := range states {
+		if errors[i] < 0 {
+			continue
+		}
+		state := &com.ItemState{}
+		if i < len(values) {
+			state.Value = values[i]
+		}
+		if i < len(qualities) {
+			state.Quality = com.Quality(qualities[i])
+		}
+		if i < len(timestamps) {
+			state.Timestamp = FileTimeToTime(timestamps[i])
+		}
+		states[i] = state
+	}
+	return states, errors
+}
+
+func (v *IOPCItemIO) WriteVQT(pszItemIDs []string, pItemVQT []*com.ItemVQT) ([]int32, error) {
+	if err := checkItemVQTs(pItemVQT); err != nil {
+		return nil, err
+	}
+	var errors []int32
+	err := v.Call(opIOPCItemIOWriteVQT, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(pszItemIDs)))
+		writeStringArray(w, pszItemIDs)
+		writeItemVQTs(w, pItemVQT)
+	}, func(r *ndr.Reader) {
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
+
+// checkItemVQTs reports the first value that cannot be sent as a VARIANT
+func checkItemVQTs(items []*com.ItemVQT) error {
+	for _, item := range items {
+		if _, err := variantType(item.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeItemVQTs writes a [in, size_is(dwCount)] OPCITEMVQT* array, the values must pass checkItemVQTs
+func writeItemVQTs(w *ndr.Writer, items []*com.ItemVQT) {
+	w.WriteUint32(uint32(len(items)))
+	for _, item := range items {
+		value := item.Value
+		w.WritePointer(true)
+		w.Defer(func() {
+			_ = WriteVariant(w, value)
+		})
+		writeBOOL(w, item.QualitySpecified)
+		w.WriteUint16(uint16(item.Quality))
+		w.WriteUint16(0)
+		writeBOOL(w, item.TimestampSpecified)
+		w.WriteUint32(0)
+		var timestamp uint64
+		if item.TimestampSpecified {
+			timestamp = TimeToFileTime(item.Timestamp)
+		}
+		writeFileTime(w, timestamp)
+	}
+	w.Flush()
+}
@@ -0,0 +1,150 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCItemMgt is the ORPC proxy of IOPCItemMgt
+type IOPCItemMgt struct {
+	*Object
+}
+
+const (
+	opIOPCItemMgtAddItems         = 3
+	opIOPCItemMgtValidateItems    = 4
+	opIOPCItemMgtRemoveItems      = 5
+	opIOPCItemMgtSetActiveState   = 6
+	opIOPCItemMgtSetClientHandles = 7
+	opIOPCItemMgtSetDatatypes     = 8
+)
+
+func (sl *IOPCItemMgt) AddItems(items []com.ItemDefinition) ([]com.TagOPCITEMRESULTStruct, []int32, error) {
+	var results []com.TagOPCITEMRESULTStruct
+	var errors []int32
+	err := sl.Call(opIOPCItemMgtAddItems, func(w *ndr.Writer) {
+		writeItemDefinitions(w, items)
+	}, func(r *ndr.Reader) {
+		results = readItemResults(r)
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return results, errors, nil
+}
+
+func (sl *IOPCItemMgt) ValidateItems(items []com.ItemDefinition, bBlobUpdate bool) ([]com.TagOPCITEMRESULTStruct, []int32, error) {
+	var results []com.TagOPCITEMRESULTStruct
+	var errors []int32
+	err := sl.Call(opIOPCItemMgtValidateItems, func(w *ndr.Writer) {
+		writeItemDefinitions(w, items)
+		writeBOOL(w, bBlobUpdate)
+	}, func(r *ndr.Reader) {
+		results = readItemResults(r)
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return results, errors, nil
+}
+
+func (sl *IOPCItemMgt) RemoveItems(phServer []uint32) ([]int32, error) {
+	return sl.callErrors(opIOPCItemMgtRemoveItems, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(phServer)))
+		w.WriteUint32Array(phServer)
+	})
+}
+
+func (sl *IOPCItemMgt) SetActiveState(phServer []uint32, bActive bool) ([]int32, error) {
+	return sl.callErrors(opIOPCItemMgtSetActiveState, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(phServer)))
+		w.WriteUint32Array(phServer)
+		writeBOOL(w, bActive)
+	})
+}
+
+func (sl *IOPCItemMgt) SetClientHandles(phServer []uint32, phClient []uint32) ([]int32, error) {
+	return sl.callErrors(opIOPCItemMgtSetClientHandles, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(phServer)))
+		w.WriteUint32Array(phServer)
+		w.WriteUint32Array(phClient)
+	})
+}
+
+func (sl *IOPCItemMgt) SetDatatypes(phServer []uint32, pRequestedDatatypes []com.VT) ([]int32, error) {
+	return sl.callErrors(opIOPCItemMgtSetDatatypes, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(phServer)))
+		w.WriteUint32Array(phServer)
+		w.WriteUint32(uint32(len(pRequestedDatatypes)))
+		for _, vt := range pRequestedDatatypes {
+			w.WriteUint16(uint16(vt))
+		}
+	})
+}
+
+// callErrors invokes a method whose only [out] parameter is HRESULT** ppErrors
+func (sl *IOPCItemMgt) callErrors(opnum uint16, in func(w *ndr.Writer)) ([]int32, error) {
+	var errors []int32
+	err := sl.Call(opnum, in, func(r *ndr.Reader) {
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
+
+// writeItemDefinitions writes dwCount and the OPCITEMDEF array
+func writeItemDefinitions(w *ndr.Writer, items []com.ItemDefinition) {
+	w.WriteUint32(uint32(len(items)))
+	w.WriteUint32(uint32(len(items)))
+	for i := range items {
+		item := &items[i]
+		w.WritePointer(true)
+		w.Defer(func() {
+			w.WriteString(item.AccessPath)
+		})
+		w.WritePointer(true)
+		w.Defer(func() {
+			w.WriteString(item.ItemID)
+		})
+		writeBOOL(w, item.Active)
+		w.WriteUint32(item.ClientHandle)
+		w.WriteUint32(uint32(len(item.Blob)))
+		if w.WritePointer(len(item.Blob) > 0) {
+			w.Defer(func() {
+				w.WriteUint32(uint32(len(item.Blob)))
+				w.WriteBytes(item.Blob)
+			})
+		}
+		w.WriteUint16(uint16(item.RequestedDataType))
+		w.WriteUint16(0)
+	}
+	w.Flush()
+}
+
+// readItemResults reads a [out, size_is(,dwCount)] OPCITEMRESULT** array
+func readItemResults(r *ndr.Reader) []com.TagOPCITEMRESULTStruct {
+	if !r.ReadPointer() {
+		return nil
+	}
+	n := r.ReadCount(20)
+	results := make([]com.TagOPCITEMRESULTStruct, n)
+	for i := range results {
+		result := &results[i]
+		result.Server = r.ReadUint32()
+		result.NativeType = r.ReadUint16()
+		r.ReadUint16()
+		result.AccessRights = r.ReadUint32()
+		r.ReadUint32()
+		if r.ReadPointer() {
+			r.Defer(func() {
+				result.Blob = r.ReadBytes(r.ReadCount(1))
+			})
+		}
+	}
+	r.Flush()
+	return results
+}
@@ -0,0 +1,142 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCItemProperties is the ORPC proxy of IOPCItemProperties
+type IOPCItemProperties struct {
+	*Object
+}
+
+const (
+	opIOPCItemPropertiesQueryAvailableProperties = 3
+	opIOPCItemPropertiesGetItemProperties        = 4
+	opIOPCItemPropertiesLookupItemIDs            = 5
+)
+
+func (v *IOPCItemProperties) QueryAvailableProperties(szItemID string) (ppPropertyIDs []uint32, ppDescriptions []string, ppvtDataTypes []uint16, err error) {
+	err = v.Call(opIOPCItemPropertiesQueryAvailableProperties, func(w *ndr.Writer) {
+		w.WriteString(szItemID)
+	}, func(r *ndr.Reader) {
+		r.ReadUint32()
+		if r.ReadPointer() {
+			ppPropertyIDs = r.ReadUint32Array()
+		}
+		ppDescriptions = readStringArray(r)
+		if r.ReadPointer() {
+			n := r.ReadCount(2)
+			ppvtDataTypes = make([]uint16, n)
+			for i := range ppvtDataTypes {
+				ppvtDataTypes[i] = r.ReadUint16()
+			}
+		}
+	})
+	return
+}
+
+func (v *IOPCItemProperties) GetItemProperties(szItemID string, propertyIDs []uint32) (ppvData []interface{}, ppErrors []int32, err error) {
+	err = v.Call(opIOPCItemPropertiesGetItemProperties, func(w *ndr.Writer) {
+		w.WriteString(szItemID)
+		w.WriteUint32(uint32(len(propertyIDs)))
+		w.WriteUint32Array(propertyIDs)
+	}, func(r *ndr.Reader) {
+		var decodeErrors []error
+		ppvData, decodeErrors = readVariantArray(r)
+		ppErrors = readHRESULTArray(r)
+		setDecodeErrors(ppErrors, decodeErrors)
+	})
+	for i := range ppErrors {
+		if ppErrors[i] < 0 && i < len(ppvData) {
+			ppvData[i] = nil
+		}
+	}
+	return
+}
+
+func (v *IOPCItemProperties) LookupItemIDs(szItemID string, propertyIDs []uint32) (ppszNewItemIDs []string, ppErrors []int32, err error) {
+	err = v.Call(opIOPCItemPropertiesLookupItemIDs, func(w *ndr.Writer) {
+		w.WriteString(szItemID)
+		w.WriteUint32(uint32(len(propertyIDs)))
+		w.WriteUint32Array(propertyIDs)
+	}, func(r *ndr.Reader) {
+		ppszNewItemIDs = readStringArray(r)
+		ppErrors = readHRESULTArray(r)
+	})
+	return
+}
+
+// readStringArray reads a [out, string, size_is(,n)] LPWSTR** array, NULL elements are returned as empty strings
+func readStringArray(r *ndr.Reader) []string {
+	if !r.ReadPointer() {
+		return nil
+	}
+	n := r.ReadCount(4)
+	values := make([]string, n)
+	for i := range values {
+		if r.ReadPointer() {
+			i := i
+			r.Defer(func() {
+				values[i] = r.ReadString()
+			})
+		}
+	}
+	r.Flush()
+	return values
+}
+
+// writeStringArray writes a [in, string, size_is(n)] LPWSTR* array
+func writeStringArray(w *ndr.Writer, values []string) {
+	w.WriteUint32(uint32(len(values)))
+	for i := range values {
+		value := values[i]
+		w.WritePointer(true)
+		w.Defer(func() {
+			w.WriteString(value)
+		})
+	}
+	w.Flush()
+}
+
+// readVariantArray reads a [out, size_is(,n)] VARIANT** array and the errors of the values that cannot be decoded
+func readVariantArray(r *ndr.Reader) ([]interface{}, []error) {
+	if !r.ReadPointer() {
+		return nil, nil
+	}
+	n := r.ReadCount(4)
+	values := make([]interface{}, n)
+	errs := make([]error, n)
+	for i := range values {
+		if r.ReadPointer() {
+			i := i
+			r.Defer(func() {
+				values[i], errs[i] = ReadVariant(r)
+			})
+		}
+	}
+	r.Flush()
+	return values, errs
+}
+
+// writeVariantArray writes a [in, size_is(n)] VARIANT* array, the values must pass checkVariants
+func writeVariantArray(w *ndr.Writer, values []interface{}) {
+	w.WriteUint32(uint32(len(values)))
+	for i := range values {
+		w.WritePointer(true)
+		value := values[i]
+		w.Defer(func() {
+			_ = WriteVariant(w, value)
+		})
+	}
+	w.Flush()
+}
+
+// checkVariants reports the first value that cannot be sent as a VARIANT
+func checkVariants(values []interface{}) error {
+	for _, value := range values {
+		if _, err := variantType(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
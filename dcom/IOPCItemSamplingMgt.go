@@ -0,0 +1,109 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCItemSamplingMgt is the ORPC proxy of the OPC DA 3.0 IOPCItemSamplingMgt
+type IOPCItemSamplingMgt struct {
+	*Object
+}
+
+const (
+	opIOPCItemSamplingMgtSetItemSamplingRate   = 3
+	opIOPCItemSamplingMgtGetItemSamplingRate   = 4
+	opIOPCItemSamplingMgtClearItemSamplingRate = 5
+	opIOPCItemSamplingMgtSetItemBufferEnable   = 6
+	opIOPCItemSamplingMgtGetItemBufferEnable   = 7
+)
+
+func (sl *IOPCItemSamplingMgt) SetItemSamplingRate(serverHandles []uint32, requestedSamplingRates []uint32) ([]uint32, []int32, error) {
+	var revised []uint32
+	var errors []int32
+	err := sl.Call(opIOPCItemSamplingMgtSetItemSamplingRate, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+		w.WriteUint32Array(requestedSamplingRates)
+	}, func(r *ndr.Reader) {
+		if r.ReadPointer() {
+			revised = r.ReadUint32Array()
+		}
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return revised, errors, nil
+}
+
+func (sl *IOPCItemSamplingMgt) GetItemSamplingRate(serverHandles []uint32) ([]uint32, []int32, error) {
+	var rates []uint32
+	var errors []int32
+	err := sl.Call(opIOPCItemSamplingMgtGetItemSamplingRate, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+	}, func(r *ndr.Reader) {
+		if r.ReadPointer() {
+			rates = r.ReadUint32Array()
+		}
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return rates, errors, nil
+}
+
+func (sl *IOPCItemSamplingMgt) ClearItemSamplingRate(serverHandles []uint32) ([]int32, error) {
+	var errors []int32
+	err := sl.Call(opIOPCItemSamplingMgtClearItemSamplingRate, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+	}, func(r *ndr.Reader) {
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
+
+func (sl *IOPCItemSamplingMgt) SetItemBufferEnable(serverHandles []uint32, enable []bool) ([]int32, error) {
+	var errors []int32
+	err := sl.Call(opIOPCItemSamplingMgtSetItemBufferEnable, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+		w.WriteUint32(uint32(len(enable)))
+		for _, e := range enable {
+			writeBOOL(w, e)
+		}
+	}, func(r *ndr.Reader) {
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
+
+func (sl *IOPCItemSamplingMgt) GetItemBufferEnable(serverHandles []uint32) ([]bool, []int32, error) {
+	var enable []bool
+	var errors []int32
+	err := sl.Call(opIOPCItemSamplingMgtGetItemBufferEnable, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+	}, func(r *ndr.Reader) {
+		if r.ReadPointer() {
+			n := r.ReadCount(4)
+			enable = make([]bool, n)
+			for i := range enable {
+				enable[i] = r.ReadInt32() != 0
+			}
+		}
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return enable, errors, nil
+}
@@ -0,0 +1,120 @@
+package dcom
+
+import (
+	"errors"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCServer is the ORPC proxy of IOPCServer
+type IOPCServer struct {
+	*Object
+}
+
+const (
+	opIOPCServerAddGroup       = 3
+	opIOPCServerGetErrorString = 4
+	opIOPCServerGetStatus      = 6
+	opIOPCServerRemoveGroup    = 7
+)
+
+func (v *IOPCServer) AddGroup(
+	szName string,
+	bActive bool,
+	dwRequestedUpdateRate uint32,
+	hClientGroup uint32,
+	pTimeBias *int32,
+	pPercentDeadband *float32,
+	dwLCID uint32,
+	riid *com.GUID,
+) (phServerGroup uint32, pRevisedUpdateRate uint32, ppUnk *Object, err error) {
+	var objRef []byte
+	err = v.Call(opIOPCServerAddGroup, func(w *ndr.Writer) {
+		w.WriteString(szName)
+		writeBOOL(w, bActive)
+		w.WriteUint32(dwRequestedUpdateRate)
+		w.WriteUint32(hClientGroup)
+		if w.WritePointer(pTimeBias != nil) {
+			w.WriteInt32(*pTimeBias)
+		}
+		if w.WritePointer(pPercentDeadband != nil) {
+			w.WriteFloat32(*pPercentDeadband)
+		}
+		w.WriteUint32(dwLCID)
+		w.WriteGUID(riid)
+	}, func(r *ndr.Reader) {
+		phServerGroup = r.ReadUint32()
+		pRevisedUpdateRate = r.ReadUint32()
+		objRef = ReadInterfacePointer(r)
+	})
+	if err != nil {
+		return
+	}
+	if objRef == nil {
+		err = errors.New("dcom: AddGroup returned a NULL interface pointer")
+		return
+	}
+	ppUnk, err = v.Client().UnmarshalInterface(objRef)
+	return
+}
+
+func (v *IOPCServer) GetErrorString(dwError uint32, dwLocale uint32) (str string, err error) {
+	err = v.Call(opIOPCServerGetErrorString, func(w *ndr.Writer) {
+		w.WriteUint32(dwError)
+		w.WriteUint32(dwLocale)
+	}, func(r *ndr.Reader) {
+		str = r.ReadUniqueString()
+	})
+	return
+}
+
+func (v *IOPCServer) GetStatus() (status *com.ServerStatus, err error) {
+	err = v.Call(opIOPCServerGetStatus, nil, func(r *ndr.Reader) {
+		if !r.ReadPointer() {
+			r.SetErr(errors.New("dcom: GetStatus returned a NULL status"))
+			return
+		}
+		status = &com.ServerStatus{
+			StartTime:      FileTimeToTime(readFileTime(r)),
+			CurrentTime:    FileTimeToTime(readFileTime(r)),
+			LastUpdateTime: FileTimeToTime(readFileTime(r)),
+			ServerState:    com.OPCServerState(r.ReadUint16()),
+		}
+		status.GroupCount = r.ReadUint32()
+		status.BandWidth = r.ReadUint32()
+		status.MajorVersion = r.ReadUint16()
+		status.MinorVersion = r.ReadUint16()
+		status.BuildNumber = r.ReadUint16()
+		status.Reserved = r.ReadUint16()
+		if r.ReadPointer() {
+			r.Defer(func() {
+				status.VendorInfo = r.ReadString()
+			})
+		}
+	})
+	if err != nil {
+		status = nil
+	}
+	return
+}
+
+func (v *IOPCServer) RemoveGroup(hServerGroup uint32, bForce bool) (err error) {
+	return v.Call(opIOPCServerRemoveGroup, func(w *ndr.Writer) {
+		w.WriteUint32(hServerGroup)
+		writeBOOL(w, bForce)
+	}, nil)
+}
+
+// readFileTime reads a FILETIME structure as 100ns intervals
+func readFileTime(r *ndr.Reader) uint64 {
+	low := r.ReadUint32()
+	high := r.ReadUint32()
+	return uint64(high)<<32 | uint64(low)
+}
+
+// writeFileTime writes a FILETIME structure
+func writeFileTime(w *ndr.Writer, ft uint64) {
+	w.WriteUint32(uint32(ft))
+	w.WriteUint32(uint32(ft >> 32))
+}
@@ -0,0 +1,26 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCServerList is the ORPC proxy of IOPCServerList implemented by OpcEnum
+type IOPCServerList struct {
+	*Object
+}
+
+const opIOPCServerListCLSIDFromProgID = 5
+
+func (sl *IOPCServerList) CLSIDFromProgID(szProgID string) (*com.GUID, error) {
+	var clsid com.GUID
+	err := sl.Call(opIOPCServerListCLSIDFromProgID, func(w *ndr.Writer) {
+		w.WriteString(szProgID)
+	}, func(r *ndr.Reader) {
+		clsid = r.ReadGUID()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &clsid, nil
+}
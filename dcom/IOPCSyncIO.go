@@ -0,0 +1,72 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCSyncIO is the ORPC proxy of IOPCSyncIO
+type IOPCSyncIO struct {
+	*Object
+}
+
+const (
+	opIOPCSyncIORead  = 3
+	opIOPCSyncIOWrite = 4
+)
+
+func (sl *IOPCSyncIO) Read(source com.OPCDATASOURCE, serverHandles []uint32) ([]*com.ItemState, []int32, error) {
+	var states []*com.ItemState
+	var errors []int32
+	var decodeErrors []error
+	err := sl.Call(opIOPCSyncIORead, func(w *ndr.Writer) {
+		w.WriteUint16(uint16(source))
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+	}, func(r *ndr.Reader) {
+		if r.ReadPointer() {
+			n := r.ReadCount(20)
+			states = make([]*com.ItemState, n)
+			decodeErrors = make([]error, n)
+			for i := range states {
+				state := &com.ItemState{}
+				state.ClientHandle = r.ReadInt32()
+				state.Timestamp = FileTimeToTime(readFileTime(r))
+				state.Quality = com.Quality(r.ReadUint16())
+				r.ReadUint16()
+				if r.ReadPointer() {
+					i := i
+					r.Defer(func() {
+						state.Value, decodeErrors[i] = ReadVariant(r)
+					})
+				}
+				states[i] = state
+			}
+			r.Flush()
+		}
+		errors = readHRESULTArray(r)
+		setDecodeErrors(errors, decodeErrors)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return states, errors, nil
+}
+
+func (sl *IOPCSyncIO) Write(serverHandles []uint32, values []interface{}) ([]int32, error) {
+	if err := checkVariants(values); err != nil {
+		return nil, err
+	}
+	var errors []int32
+	err := sl.Call(opIOPCSyncIOWrite, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+		writeVariantArray(w, values)
+	}, func(r *ndr.Reader) {
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
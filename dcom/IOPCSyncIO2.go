@@ -0,0 +1,50 @@
+package dcom
+
+import (
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// IOPCSyncIO2 is the ORPC proxy of the OPC DA 3.0 IOPCSyncIO2, the methods of IOPCSyncIO are called through IOPCSyncIO
+type IOPCSyncIO2 struct {
+	*Object
+}
+
+const (
+	opIOPCSyncIO2ReadMaxAge = 5
+	opIOPCSyncIO2WriteVQT   = 6
+)
+
+func (sl *IOPCSyncIO2) ReadMaxAge(serverHandles []uint32, maxAges []uint32) ([]*com.ItemState, []int32, error) {
+	var states []*com.ItemState
+	var errors []int32
+	err := sl.Call(opIOPCSyncIO2ReadMaxAge, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+		w.WriteUint32Array(maxAges)
+	}, func(r *ndr.Reader) {
+		states, errors = readItemValues(r)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return states, errors, nil
+}
+
+func (sl *IOPCSyncIO2) WriteVQT(serverHandles []uint32, items []*com.ItemVQT) ([]int32, error) {
+	if err := checkItemVQTs(items); err != nil {
+		return nil, err
+	}
+	var errors []int32
+	err := sl.Call(opIOPCSyncIO2WriteVQT, func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(serverHandles)))
+		w.WriteUint32Array(serverHandles)
+		writeItemVQTs(w, items)
+	}, func(r *ndr.Reader) {
+		errors = readHRESULTArray(r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
@@ -0,0 +1,501 @@
+package dcom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+const (
+	clsctxLocalServer  = 0x4
+	clsctxRemoteServer = 0x10
+	// MSHCTX_DIFFERENTMACHINE
+	destinationContext = 2
+	// ncacn_ip_tcp protocol sequence identifier
+	protseqTCP = 7
+)
+
+// ActivationRequest is the content of the activation properties sent with RemoteCreateInstance
+type ActivationRequest struct {
+	CLSID          com.GUID
+	IIDs           []com.GUID
+	ServerName     string
+	AuthnLevel     uint32
+	ClientImpLevel uint32
+}
+
+// ActivationInterface is an interface returned by the activation
+type ActivationInterface struct {
+	IID     com.GUID
+	HRESULT uint32
+	// ObjRef is the marshalled interface pointer, nil when HRESULT is a failure
+	ObjRef []byte
+}
+
+// ActivationReply is the content of the activation properties returned by RemoteCreateInstance
+type ActivationReply struct {
+	Interfaces     []ActivationInterface
+	OXID           uint64
+	OXIDBindings   DualStringArray
+	RemUnknownIPID com.GUID
+	AuthnHint      uint32
+	ServerVersion  COMVersion
+}
+
+type activationProperty struct {
+	clsid com.GUID
+	data  []byte
+}
+
+// serializeType encodes a top-level type with the type serialization version 1 headers
+func serializeType(write func(w *ndr.Writer)) []byte {
+	w := ndr.NewWriter()
+	write(w)
+	w.Flush()
+	w.Align(8)
+	b := []byte{0x01, 0x10, 0x08, 0x00, 0xcc, 0xcc, 0xcc, 0xcc}
+	b = binary.LittleEndian.AppendUint32(b, uint32(w.Len()))
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	return append(b, w.Bytes()...)
+}
+
+// deserializeType checks the type serialization version 1 headers and returns a reader over the type
+func deserializeType(b []byte) (*ndr.Reader, error) {
+	if len(b) < 16 {
+		return nil, errors.New("dcom: short serialized type")
+	}
+	if b[0] != 1 || b[1] != 0x10 {
+		return nil, fmt.Errorf("dcom: unsupported type serialization version %d endianness 0x%x", b[0], b[1])
+	}
+	headerLength := int(binary.LittleEndian.Uint16(b[2:]))
+	if headerLength+8 > len(b) {
+		return nil, errors.New("dcom: short serialized type")
+	}
+	length := int(binary.LittleEndian.Uint32(b[headerLength:]))
+	b = b[headerLength+8:]
+	if length > len(b) {
+		return nil, errors.New("dcom: short serialized type")
+	}
+	return ndr.NewReader(b[:length]), nil
+}
+
+func pad8(b []byte) []byte {
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// marshalActivationBlob returns the activation properties BLOB
+func marshalActivationBlob(properties []activationProperty) []byte {
+	sizes := make([]uint32, len(properties))
+	var body []byte
+	for i, p := range properties {
+		data := pad8(p.data)
+		sizes[i] = uint32(len(data))
+		body = append(body, data...)
+	}
+	header := func(totalSize, headerSize uint32) []byte {
+		return serializeType(func(w *ndr.Writer) {
+			w.WriteUint32(totalSize)
+			w.WriteUint32(headerSize)
+			w.WriteUint32(0)
+			w.WriteUint32(destinationContext)
+			w.WriteUint32(uint32(len(properties)))
+			w.WriteGUID(&com.GUID{})
+			w.WritePointer(true)
+			w.Defer(func() {
+				w.WriteUint32(uint32(len(properties)))
+				for i := range properties {
+					w.WriteGUID(&properties[i].clsid)
+				}
+			})
+			w.WritePointer(true)
+			w.Defer(func() {
+				w.WriteUint32Array(sizes)
+			})
+			w.WritePointer(false)
+		})
+	}
+	headerSize := uint32(len(header(0, 0)))
+	totalSize := headerSize + uint32(len(body))
+	b := binary.LittleEndian.AppendUint32(nil, totalSize)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(b, header(totalSize, headerSize)...)
+	return append(b, body...)
+}
+
+// unmarshalActivationBlob splits the activation properties BLOB into its properties
+func unmarshalActivationBlob(b []byte) ([]activationProperty, error) {
+	if len(b) < 8 {
+		return nil, errors.New("dcom: short activation blob")
+	}
+	b = b[8:]
+	r, err := deserializeType(b)
+	if err != nil {
+		return nil, err
+	}
+	r.ReadUint32()
+	headerSize := r.ReadUint32()
+	r.ReadUint32()
+	r.ReadUint32()
+	count := r.ReadUint32()
+	r.ReadGUID()
+	var clsids []com.GUID
+	var sizes []uint32
+	if r.ReadPointer() {
+		r.Defer(func() {
+			n := r.ReadCount(16)
+			clsids = make([]com.GUID, n)
+			for i := range clsids {
+				clsids[i] = r.ReadGUID()
+			}
+		})
+	}
+	if r.ReadPointer() {
+		r.Defer(func() {
+			sizes = r.ReadUint32Array()
+		})
+	}
+	r.ReadPointer()
+	r.Flush()
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+	if len(clsids) != int(count) || len(sizes) != int(count) {
+		return nil, errors.New("dcom: activation header property count mismatch")
+	}
+	if int(headerSize) > len(b) {
+		return nil, errors.New("dcom: short activation blob")
+	}
+	b = b[headerSize:]
+	properties := make([]activationProperty, count)
+	for i := range properties {
+		if int(sizes[i]) > len(b) {
+			return nil, errors.New("dcom: short activation property")
+		}
+		properties[i] = activationProperty{clsid: clsids[i], data: b[:sizes[i]]}
+		b = b[sizes[i]:]
+	}
+	return properties, nil
+}
+
+func marshalActivationObjRef(iid, clsid com.GUID, properties []activationProperty) ([]byte, error) {
+	o := &ObjRef{
+		Flags: ObjRefCustom,
+		IID:   iid,
+		CLSID: clsid,
+		Data:  marshalActivationBlob(properties),
+	}
+	return o.Marshal()
+}
+
+func unmarshalActivationObjRef(b []byte, clsid com.GUID) ([]activationProperty, error) {
+	o, err := UnmarshalObjRef(b)
+	if err != nil {
+		return nil, err
+	}
+	if o.Flags != ObjRefCustom || !com.IsEqualGUID(&o.CLSID, &clsid) {
+		return nil, fmt.Errorf("dcom: unexpected activation properties class %s", o.CLSID.String())
+	}
+	return unmarshalActivationBlob(o.Data)
+}
+
+// Marshal returns the OBJREF_CUSTOM carrying the activation properties
+func (a *ActivationRequest) Marshal() ([]byte, error) {
+	special := serializeType(func(w *ndr.Writer) {
+		w.WriteUint32(0xffffffff)
+		w.WriteUint32(0)
+		w.WriteUint32(0)
+		w.WriteUint32(0)
+		w.WriteUint32(a.AuthnLevel)
+		w.WriteGUID(&com.GUID{})
+		w.WriteUint32(0)
+		w.WriteUint32(clsctxRemoteServer)
+		w.WriteUint32(0)
+		w.WriteUint32(0)
+		w.WriteUint64(0)
+		for i := 0; i < 5; i++ {
+			w.WriteUint32(0)
+		}
+	})
+	instantiation := func(thisSize uint32) []byte {
+		return serializeType(func(w *ndr.Writer) {
+			w.WriteGUID(&a.CLSID)
+			w.WriteUint32(clsctxLocalServer | clsctxRemoteServer)
+			w.WriteUint32(0)
+			w.WriteUint32(0)
+			w.WriteUint32(uint32(len(a.IIDs)))
+			w.WriteUint32(0)
+			w.WritePointer(true)
+			w.Defer(func() {
+				w.WriteUint32(uint32(len(a.IIDs)))
+				for i := range a.IIDs {
+					w.WriteGUID(&a.IIDs[i])
+				}
+			})
+			w.WriteUint32(thisSize)
+			w.WriteUint16(ClientVersion.Major)
+			w.WriteUint16(ClientVersion.Minor)
+		})
+	}
+	instantiationInfo := instantiation(uint32(len(pad8(instantiation(0)))))
+	context := serializeType(func(w *ndr.Writer) {
+		w.WriteUint32(0)
+		w.WriteUint32(0)
+		w.WriteUint32(0)
+		w.WriteUint32(0)
+		w.WritePointer(false)
+		w.WritePointer(false)
+	})
+	security := serializeType(func(w *ndr.Writer) {
+		w.WriteUint32(0)
+		w.WritePointer(true)
+		w.Defer(func() {
+			w.WriteUint32(0)
+			if w.WritePointer(a.ServerName != "") {
+				w.Defer(func() {
+					w.WriteString(a.ServerName)
+				})
+			}
+			w.WritePointer(false)
+			w.WriteUint32(0)
+		})
+		w.WritePointer(false)
+	})
+	location := serializeType(func(w *ndr.Writer) {
+		w.WritePointer(false)
+		w.WriteUint32(0)
+		w.WriteUint32(0)
+		w.WriteUint32(0)
+	})
+	scmRequest := serializeType(func(w *ndr.Writer) {
+		w.WritePointer(false)
+		w.WritePointer(true)
+		w.Defer(func() {
+			w.WriteUint32(a.ClientImpLevel)
+			w.WriteUint16(1)
+			w.WritePointer(true)
+			w.Defer(func() {
+				w.WriteUint32(1)
+				w.WriteUint16(protseqTCP)
+			})
+		})
+	})
+	return marshalActivationObjRef(IID_IActivationPropertiesIn, CLSID_ActivationPropertiesIn, []activationProperty{
+		{clsid: CLSID_SpecialSystemProperties, data: special},
+		{clsid: CLSID_InstantiationInfo, data: instantiationInfo},
+		{clsid: CLSID_ActivationContextInfo, data: context},
+		{clsid: CLSID_SecurityInfo, data: security},
+		{clsid: CLSID_ServerLocationInfo, data: location},
+		{clsid: CLSID_ScmRequestInfo, data: scmRequest},
+	})
+}
+
+// UnmarshalActivationRequest parses the activation properties sent with RemoteCreateInstance
+func UnmarshalActivationRequest(objRef []byte) (*ActivationRequest, error) {
+	properties, err := unmarshalActivationObjRef(objRef, CLSID_ActivationPropertiesIn)
+	if err != nil {
+		return nil, err
+	}
+	a := &ActivationRequest{}
+	found := false
+	for _, p := range properties {
+		r, err := deserializeType(p.data)
+		if err != nil {
+			return nil, err
+		}
+		switch p.clsid {
+		case CLSID_InstantiationInfo:
+			found = true
+			a.CLSID = r.ReadGUID()
+			r.ReadUint32()
+			r.ReadUint32()
+			r.ReadUint32()
+			r.ReadUint32()
+			r.ReadUint32()
+			if r.ReadPointer() {
+				r.Defer(func() {
+					n := r.ReadCount(16)
+					a.IIDs = make([]com.GUID, n)
+					for i := range a.IIDs {
+						a.IIDs[i] = r.ReadGUID()
+					}
+				})
+			}
+			r.ReadUint32()
+			r.ReadUint16()
+			r.ReadUint16()
+			r.Flush()
+		case CLSID_SecurityInfo:
+			r.ReadUint32()
+			if r.ReadPointer() {
+				r.Defer(func() {
+					r.ReadUint32()
+					if r.ReadPointer() {
+						r.Defer(func() {
+							a.ServerName = r.ReadString()
+						})
+					}
+					r.ReadPointer()
+					r.ReadUint32()
+				})
+			}
+			r.ReadPointer()
+			r.Flush()
+		case CLSID_SpecialSystemProperties:
+			r.ReadUint32()
+			r.ReadUint32()
+			r.ReadUint32()
+			r.ReadUint32()
+			a.AuthnLevel = r.ReadUint32()
+		case CLSID_ScmRequestInfo:
+			r.ReadPointer()
+			if r.ReadPointer() {
+				a.ClientImpLevel = r.ReadUint32()
+			}
+		}
+		if r.Err() != nil {
+			return nil, r.Err()
+		}
+	}
+	if !found {
+		return nil, errors.New("dcom: activation properties without InstantiationInfo")
+	}
+	return a, nil
+}
+
+// Marshal returns the OBJREF_CUSTOM carrying the activation reply
+func (a *ActivationReply) Marshal() ([]byte, error) {
+	propsOut := serializeType(func(w *ndr.Writer) {
+		w.WriteUint32(uint32(len(a.Interfaces)))
+		w.WritePointer(true)
+		w.Defer(func() {
+			w.WriteUint32(uint32(len(a.Interfaces)))
+			for i := range a.Interfaces {
+				w.WriteGUID(&a.Interfaces[i].IID)
+			}
+		})
+		w.WritePointer(true)
+		w.Defer(func() {
+			w.WriteUint32(uint32(len(a.Interfaces)))
+			for i := range a.Interfaces {
+				w.WriteUint32(a.Interfaces[i].HRESULT)
+			}
+		})
+		w.WritePointer(true)
+		w.Defer(func() {
+			w.WriteUint32(uint32(len(a.Interfaces)))
+			for i := range a.Interfaces {
+				objRef := a.Interfaces[i].ObjRef
+				if w.WritePointer(objRef != nil) {
+					w.Defer(func() {
+						writeInterfacePointerBody(w, objRef)
+					})
+				}
+			}
+		})
+	})
+	scmReply := serializeType(func(w *ndr.Writer) {
+		w.WritePointer(false)
+		w.WritePointer(true)
+		w.Defer(func() {
+			w.WriteUint64(a.OXID)
+			w.WritePointer(true)
+			w.Defer(func() {
+				a.OXIDBindings.WriteNDR(w)
+			})
+			w.WriteGUID(&a.RemUnknownIPID)
+			w.WriteUint32(a.AuthnHint)
+			w.WriteUint16(a.ServerVersion.Major)
+			w.WriteUint16(a.ServerVersion.Minor)
+		})
+	})
+	return marshalActivationObjRef(IID_IActivationPropertiesOut, CLSID_ActivationPropertiesOut, []activationProperty{
+		{clsid: CLSID_PropsOutInfo, data: propsOut},
+		{clsid: CLSID_ScmReplyInfo, data: scmReply},
+	})
+}
+
+// UnmarshalActivationReply parses the activation properties returned by RemoteCreateInstance
+func UnmarshalActivationReply(objRef []byte) (*ActivationReply, error) {
+	properties, err := unmarshalActivationObjRef(objRef, CLSID_ActivationPropertiesOut)
+	if err != nil {
+		return nil, err
+	}
+	a := &ActivationReply{}
+	var gotProps, gotReply bool
+	for _, p := range properties {
+		r, err := deserializeType(p.data)
+		if err != nil {
+			return nil, err
+		}
+		switch p.clsid {
+		case CLSID_PropsOutInfo:
+			gotProps = true
+			n := int(r.ReadUint32())
+			if n*16 > r.Remaining() {
+				return nil, errors.New("dcom: invalid PropsOutInfo interface count")
+			}
+			a.Interfaces = make([]ActivationInterface, n)
+			if r.ReadPointer() {
+				r.Defer(func() {
+					r.ReadCount(16)
+					for i := range a.Interfaces {
+						a.Interfaces[i].IID = r.ReadGUID()
+					}
+				})
+			}
+			if r.ReadPointer() {
+				r.Defer(func() {
+					r.ReadCount(4)
+					for i := range a.Interfaces {
+						a.Interfaces[i].HRESULT = r.ReadUint32()
+					}
+				})
+			}
+			if r.ReadPointer() {
+				r.Defer(func() {
+					r.ReadCount(4)
+					for i := range a.Interfaces {
+						if r.ReadPointer() {
+							i := i
+							r.Defer(func() {
+								a.Interfaces[i].ObjRef = readInterfacePointerBody(r)
+							})
+						}
+					}
+				})
+			}
+			r.Flush()
+		case CLSID_ScmReplyInfo:
+			gotReply = true
+			r.ReadPointer()
+			if r.ReadPointer() {
+				r.Defer(func() {
+					a.OXID = r.ReadUint64()
+					if r.ReadPointer() {
+						r.Defer(func() {
+							a.OXIDBindings = *ReadDualStringArray(r)
+						})
+					}
+					a.RemUnknownIPID = r.ReadGUID()
+					a.AuthnHint = r.ReadUint32()
+					a.ServerVersion.Major = r.ReadUint16()
+					a.ServerVersion.Minor = r.ReadUint16()
+				})
+			}
+			r.Flush()
+		}
+		if r.Err() != nil {
+			return nil, r.Err()
+		}
+	}
+	if !gotProps || !gotReply {
+		return nil, errors.New("dcom: incomplete activation reply")
+	}
+	return a, nil
+}
@@ -0,0 +1,57 @@
+package dcom
+
+import (
+	"testing"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivationRequestRoundTrip(t *testing.T) {
+	request := &ActivationRequest{
+		CLSID:          com.GUID{Data1: 0xF8582CF2, Data2: 0x88FB, Data3: 0x11D0, Data4: [8]byte{0xB8, 0x50, 0x00, 0xC0, 0xF0, 0x10, 0x43, 0x05}},
+		IIDs:           []com.GUID{com.IID_IOPCServer, com.IID_IOPCCommon},
+		ServerName:     "plc01",
+		AuthnLevel:     defaultAuthnLevel,
+		ClientImpLevel: defaultImpLevel,
+	}
+	objRef, err := request.Marshal()
+	require.NoError(t, err)
+	parsed, err := UnmarshalActivationRequest(objRef)
+	require.NoError(t, err)
+	assert.Equal(t, request, parsed)
+}
+
+func TestActivationReplyRoundTrip(t *testing.T) {
+	std := StdObjRef{PublicRefs: 5, OXID: 0x1122334455667788, OID: 42, IPID: NewCID()}
+	bindings := DualStringArray{
+		StringBindings:   []StringBinding{{TowerID: TowerTCP, NetworkAddr: "10.0.0.1[49154]"}},
+		SecurityBindings: []SecurityBinding{{AuthnSvc: 10, AuthzSvc: 0xffff}},
+	}
+	ref := &ObjRef{Flags: ObjRefStandard, IID: com.IID_IOPCServer, Std: std, ResolverAddr: bindings}
+	serverRef, err := ref.Marshal()
+	require.NoError(t, err)
+	parsedRef, err := UnmarshalObjRef(serverRef)
+	require.NoError(t, err)
+	assert.Equal(t, ref, parsedRef)
+
+	reply := &ActivationReply{
+		Interfaces: []ActivationInterface{
+			{IID: com.IID_IOPCServer, ObjRef: serverRef},
+			{IID: com.IID_IOPCCommon, HRESULT: uint32(ENoInterface)},
+		},
+		OXID:           std.OXID,
+		OXIDBindings:   bindings,
+		RemUnknownIPID: NewCID(),
+		AuthnHint:      2,
+		ServerVersion:  ClientVersion,
+	}
+	objRef, err := reply.Marshal()
+	require.NoError(t, err)
+	parsed, err := UnmarshalActivationReply(objRef)
+	require.NoError(t, err)
+	assert.Equal(t, reply, parsed)
+
+	assert.Equal(t, []Endpoint{{Host: "10.0.0.1", Port: 49154}}, parsed.OXIDBindings.TCPEndpoints())
+}
@@ -0,0 +1,522 @@
+// Package dcom is a pure-Go DCOM client speaking ORPC over DCE-RPC (ncacn_ip_tcp).
+//
+// A Client activates objects on a remote machine through IRemoteSCMActivator, resolves and
+// connects to the object exporters, keeps the exported objects alive with pings and releases
+// them through IRemUnknown. It does not depend on the Windows COM runtime.
+package dcom
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+	"github.com/huskar-t/opcda/dcom/ntlm"
+	"github.com/huskar-t/opcda/dcom/rpc"
+)
+
+const (
+	opRemoteCreateInstance = 4
+	opResolveOxid2         = 4
+	opSimplePing           = 1
+	opComplexPing          = 2
+	opRemQueryInterface    = 3
+	opRemRelease           = 5
+
+	defaultResolverPort = 135
+	defaultTimeout      = 10 * time.Second
+	defaultPingInterval = 2 * time.Minute
+	defaultPublicRefs   = 5
+
+	// RPC_C_IMP_LEVEL_IDENTIFY
+	defaultImpLevel = 2
+	// RPC_C_AUTHN_LEVEL_CONNECT
+	defaultAuthnLevel = 2
+)
+
+// ErrClosed is returned by calls on a closed client
+var ErrClosed = errors.New("dcom: client closed")
+
+// Config configures a Client, the zero value is usable
+type Config struct {
+	// Dial opens the TCP connections, a net.Dialer with Timeout is used when nil
+	Dial func(network, address string) (net.Conn, error)
+	// ResolverPort is the port of the activator and OXID resolver, 135 when zero
+	ResolverPort int
+	// Timeout bounds dialing when Dial is nil, 10 seconds when zero
+	Timeout time.Duration
+	// PingInterval is the period of the pings keeping the remote objects alive, 2 minutes when zero
+	PingInterval time.Duration
+	// Credentials authenticate every connection with NTLMv2, the connections are not authenticated when nil
+	Credentials *ntlm.Credentials
+	// AuthnLevel is the RPC_C_AUTHN_LEVEL_* used with Credentials, packet privacy when zero
+	AuthnLevel uint32
+	// ImpLevel is the RPC_C_IMP_LEVEL_* sent in activation requests, identify when zero
+	ImpLevel uint32
+}
+
+// newConn wraps a connected transport, authenticating it when credentials are configured
+func (c *Config) newConn(conn net.Conn) *rpc.Conn {
+	if c.Credentials == nil {
+		return rpc.NewConn(conn)
+	}
+	return rpc.NewAuthConn(conn, c.Credentials, c.authnLevel())
+}
+
+func (c *Config) authnLevel() uint32 {
+	switch {
+	case c.Credentials == nil:
+		return defaultAuthnLevel
+	case c.AuthnLevel == com.RPC_C_AUTHN_LEVEL_DEFAULT:
+		return com.RPC_C_AUTHN_LEVEL_PKT_PRIVACY
+	}
+	return c.AuthnLevel
+}
+
+func (c *Config) impLevel() uint32 {
+	if c.ImpLevel == com.RPC_C_IMP_LEVEL_DEFAULT {
+		return defaultImpLevel
+	}
+	return c.ImpLevel
+}
+
+func (c *Config) dial(host string, port int) (net.Conn, error) {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	if c.Dial != nil {
+		return c.Dial("tcp", address)
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return net.DialTimeout("tcp", address, timeout)
+}
+
+// Client is a DCOM client connected to one machine
+type Client struct {
+	cfg       Config
+	host      string
+	lock      sync.Mutex
+	resolver  *rpc.Conn
+	exporters map[uint64]*exporter
+	pinger    *pinger
+	closed    bool
+}
+
+// Dial connects to the activator and OXID resolver of host
+func Dial(host string, cfg *Config) (*Client, error) {
+	c := &Client{host: host, exporters: make(map[uint64]*exporter)}
+	if cfg != nil {
+		c.cfg = *cfg
+	}
+	if c.cfg.ResolverPort == 0 {
+		c.cfg.ResolverPort = defaultResolverPort
+	}
+	if c.cfg.PingInterval == 0 {
+		c.cfg.PingInterval = defaultPingInterval
+	}
+	conn, err := c.cfg.dial(host, c.cfg.ResolverPort)
+	if err != nil {
+		return nil, err
+	}
+	c.resolver = c.cfg.newConn(conn)
+	c.pinger = newPinger(c, c.cfg.PingInterval)
+	return c, nil
+}
+
+// Host returns the machine the client is connected to
+func (c *Client) Host() string {
+	return c.host
+}
+
+// Close stops the pinger and closes every connection, remote objects are not released
+func (c *Client) Close() error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return nil
+	}
+	c.closed = true
+	exporters := c.exporters
+	c.exporters = nil
+	c.lock.Unlock()
+	c.pinger.stop()
+	for _, e := range exporters {
+		e.close()
+	}
+	return c.resolver.Close()
+}
+
+// resolverCall invokes a non ORPC method on a resolver interface
+func (c *Client) resolverCall(iid com.GUID, opnum uint16, stub []byte) ([]byte, error) {
+	id, err := c.resolver.Bind(rpc.SyntaxID{UUID: iid})
+	if err != nil {
+		return nil, err
+	}
+	return c.resolver.Call(id, opnum, nil, stub)
+}
+
+// CreateInstance activates clsid on the remote machine and returns the requested interface
+func (c *Client) CreateInstance(clsid com.GUID, iid com.GUID) (*Object, error) {
+	activation := &ActivationRequest{
+		CLSID:          clsid,
+		IIDs:           []com.GUID{iid},
+		ServerName:     c.host,
+		AuthnLevel:     c.cfg.authnLevel(),
+		ClientImpLevel: c.cfg.impLevel(),
+	}
+	properties, err := activation.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	w := ndr.NewWriter()
+	WriteORPCThis(w, NewCID())
+	WriteInterfacePointer(w, nil)
+	WriteInterfacePointer(w, properties)
+	resp, err := c.resolverCall(IID_IRemoteSCMActivator, opRemoteCreateInstance, w.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if err = checkTrailingHRESULT(resp); err != nil {
+		return nil, err
+	}
+	r := ndr.NewReader(resp)
+	ReadORPCThat(r)
+	replyObjRef := ReadInterfacePointer(r)
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+	reply, err := UnmarshalActivationReply(replyObjRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply.Interfaces) != 1 {
+		return nil, fmt.Errorf("dcom: activation returned %d interfaces", len(reply.Interfaces))
+	}
+	if err = checkHRESULT(reply.Interfaces[0].HRESULT); err != nil {
+		return nil, err
+	}
+	e, err := c.exporter(reply.OXID, &reply.OXIDBindings, reply.RemUnknownIPID)
+	if err != nil {
+		return nil, err
+	}
+	objRef, err := UnmarshalObjRef(reply.Interfaces[0].ObjRef)
+	if err != nil {
+		return nil, err
+	}
+	return c.newObject(e, objRef.IID, objRef.Std), nil
+}
+
+// UnmarshalInterface returns the object of an OBJREF received from the server
+func (c *Client) UnmarshalInterface(objRef []byte) (*Object, error) {
+	o, err := UnmarshalObjRef(objRef)
+	if err != nil {
+		return nil, err
+	}
+	if o.Flags != ObjRefStandard {
+		return nil, fmt.Errorf("dcom: unsupported OBJREF flags 0x%x", o.Flags)
+	}
+	e, err := c.exporter(o.Std.OXID, nil, com.GUID{})
+	if err != nil {
+		return nil, err
+	}
+	return c.newObject(e, o.IID, o.Std), nil
+}
+
+func (c *Client) newObject(e *exporter, iid com.GUID, std StdObjRef) *Object {
+	o := &Object{client: c, exporter: e, iid: iid, std: std}
+	if std.Flags&SorfNoPing == 0 {
+		c.pinger.add(std.OID)
+	}
+	return o
+}
+
+// exporter returns the object exporter of oxid, the bindings are resolved when not given
+func (c *Client) exporter(oxid uint64, bindings *DualStringArray, remUnknown com.GUID) (*exporter, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return nil, ErrClosed
+	}
+	if e, ok := c.exporters[oxid]; ok {
+		return e, nil
+	}
+	if bindings == nil {
+		var err error
+		bindings, remUnknown, err = c.resolveOxid(oxid)
+		if err != nil {
+			return nil, err
+		}
+	}
+	e := &exporter{client: c, oxid: oxid, bindings: *bindings, remUnknown: remUnknown}
+	c.exporters[oxid] = e
+	return e, nil
+}
+
+// resolveOxid calls IObjectExporter::ResolveOxid2
+func (c *Client) resolveOxid(oxid uint64) (*DualStringArray, com.GUID, error) {
+	w := ndr.NewWriter()
+	w.WriteUint64(oxid)
+	w.WriteUint16(1)
+	w.WriteUint32(1)
+	w.WriteUint16(protseqTCP)
+	resp, err := c.resolverCall(IID_IObjectExporter, opResolveOxid2, w.Bytes())
+	if err != nil {
+		return nil, com.GUID{}, err
+	}
+	if err = checkTrailingStatus(resp); err != nil {
+		return nil, com.GUID{}, err
+	}
+	r := ndr.NewReader(resp)
+	bindings := &DualStringArray{}
+	if r.ReadPointer() {
+		bindings = ReadDualStringArray(r)
+	}
+	remUnknown := r.ReadGUID()
+	r.ReadUint32()
+	r.ReadUint16()
+	r.ReadUint16()
+	if r.Err() != nil {
+		return nil, com.GUID{}, r.Err()
+	}
+	return bindings, remUnknown, nil
+}
+
+func trailingStatus(stub []byte) (uint32, error) {
+	if len(stub) < 4 {
+		return 0, ndr.ErrShortBuffer
+	}
+	return ndr.NewReader(stub[len(stub)-4:]).ReadUint32(), nil
+}
+
+// checkTrailingHRESULT checks the HRESULT that ends ORPC response stubs, success codes such as S_FALSE pass
+func checkTrailingHRESULT(stub []byte) error {
+	status, err := trailingStatus(stub)
+	if err != nil {
+		return err
+	}
+	return checkHRESULT(status)
+}
+
+// checkTrailingStatus checks the error_status_t that ends the resolver response stubs
+func checkTrailingStatus(stub []byte) error {
+	status, err := trailingStatus(stub)
+	if err != nil {
+		return err
+	}
+	if status == 0 || int32(status) < 0 {
+		return checkHRESULT(status)
+	}
+	return HRESULT(0x80070000 | status&0xffff)
+}
+
+// exporter is a connection to an object exporter (OXID)
+type exporter struct {
+	client     *Client
+	oxid       uint64
+	bindings   DualStringArray
+	remUnknown com.GUID
+	lock       sync.Mutex
+	conn       *rpc.Conn
+}
+
+func (e *exporter) connect() (*rpc.Conn, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.conn != nil {
+		return e.conn, nil
+	}
+	endpoints := e.bindings.TCPEndpoints()
+	if len(endpoints) == 0 {
+		return nil, errors.New("dcom: object exporter has no ncacn_ip_tcp binding")
+	}
+	// the advertised host names are often unreachable from the client, the activation host is tried first
+	var candidates []Endpoint
+	for _, ep := range endpoints {
+		candidates = append(candidates, Endpoint{Host: e.client.host, Port: ep.Port})
+	}
+	candidates = append(candidates, endpoints...)
+	var lastErr error
+	tried := make(map[Endpoint]bool)
+	for _, ep := range candidates {
+		if tried[ep] {
+			continue
+		}
+		tried[ep] = true
+		conn, err := e.client.cfg.dial(ep.Host, ep.Port)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		e.conn = e.client.cfg.newConn(conn)
+		return e.conn, nil
+	}
+	return nil, lastErr
+}
+
+func (e *exporter) close() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.conn != nil {
+		e.conn.Close()
+		e.conn = nil
+	}
+}
+
+// call invokes an ORPC method on ipid through the interface iid
+func (e *exporter) call(iid com.GUID, ipid com.GUID, opnum uint16, in func(w *ndr.Writer), out func(r *ndr.Reader)) error {
+	conn, err := e.connect()
+	if err != nil {
+		return err
+	}
+	id, err := conn.Bind(rpc.SyntaxID{UUID: iid})
+	if err != nil {
+		return err
+	}
+	w := ndr.NewWriter()
+	WriteORPCThis(w, NewCID())
+	if in != nil {
+		in(w)
+		w.Flush()
+	}
+	resp, err := conn.Call(id, opnum, &ipid, w.Bytes())
+	if err != nil {
+		return err
+	}
+	if err = checkTrailingHRESULT(resp); err != nil {
+		return err
+	}
+	r := ndr.NewReader(resp[:len(resp)-4])
+	ReadORPCThat(r)
+	if out != nil {
+		out(r)
+		r.Flush()
+	}
+	return r.Err()
+}
+
+// remRelease calls IRemUnknown::RemRelease for one interface
+func (e *exporter) remRelease(ipid com.GUID, publicRefs uint32) error {
+	return e.call(IID_IRemUnknown, e.remUnknown, opRemRelease, func(w *ndr.Writer) {
+		w.WriteUint16(1)
+		w.WriteUint32(1)
+		w.WriteGUID(&ipid)
+		w.WriteUint32(publicRefs)
+		w.WriteUint32(0)
+	}, nil)
+}
+
+// remQueryInterface calls IRemUnknown::RemQueryInterface for one interface
+func (e *exporter) remQueryInterface(ipid com.GUID, iid com.GUID) (StdObjRef, error) {
+	var hr uint32
+	var std StdObjRef
+	err := e.call(IID_IRemUnknown, e.remUnknown, opRemQueryInterface, func(w *ndr.Writer) {
+		w.WriteGUID(&ipid)
+		w.WriteUint32(defaultPublicRefs)
+		w.WriteUint16(1)
+		w.WriteUint32(1)
+		w.WriteGUID(&iid)
+	}, func(r *ndr.Reader) {
+		if !r.ReadPointer() {
+			r.SetErr(errors.New("dcom: RemQueryInterface returned no result"))
+			return
+		}
+		if r.ReadCount(48) != 1 {
+			r.SetErr(errors.New("dcom: RemQueryInterface returned an unexpected result count"))
+			return
+		}
+		r.Align(8)
+		hr = r.ReadUint32()
+		std = readStdObjRef(r)
+	})
+	if err != nil {
+		return StdObjRef{}, err
+	}
+	if err = checkHRESULT(hr); err != nil {
+		return StdObjRef{}, err
+	}
+	return std, nil
+}
+
+func readStdObjRef(r *ndr.Reader) StdObjRef {
+	var std StdObjRef
+	r.Align(8)
+	std.Flags = r.ReadUint32()
+	std.PublicRefs = r.ReadUint32()
+	std.OXID = r.ReadUint64()
+	std.OID = r.ReadUint64()
+	std.IPID = r.ReadGUID()
+	return std
+}
+
+// WriteStdObjRef writes a STDOBJREF as an NDR structure
+func WriteStdObjRef(w *ndr.Writer, std *StdObjRef) {
+	w.Align(8)
+	w.WriteUint32(std.Flags)
+	w.WriteUint32(std.PublicRefs)
+	w.WriteUint64(std.OXID)
+	w.WriteUint64(std.OID)
+	w.WriteGUID(&std.IPID)
+}
+
+// Object is an interface pointer on a remote object
+type Object struct {
+	client   *Client
+	exporter *exporter
+	iid      com.GUID
+	std      StdObjRef
+	lock     sync.Mutex
+	released bool
+}
+
+// IID returns the interface the object was obtained for
+func (o *Object) IID() com.GUID {
+	return o.iid
+}
+
+// IPID returns the interface pointer identifier
+func (o *Object) IPID() com.GUID {
+	return o.std.IPID
+}
+
+// Client returns the client the object belongs to
+func (o *Object) Client() *Client {
+	return o.client
+}
+
+// Call invokes opnum of the object interface. in writes the [in] parameters after ORPCTHIS,
+// out reads the [out] parameters after ORPCTHAT, the HRESULT is checked before out runs.
+func (o *Object) Call(opnum uint16, in func(w *ndr.Writer), out func(r *ndr.Reader)) error {
+	return o.exporter.call(o.iid, o.std.IPID, opnum, in, out)
+}
+
+// QueryInterface returns another interface of the same object
+func (o *Object) QueryInterface(iid com.GUID) (*Object, error) {
+	std, err := o.exporter.remQueryInterface(o.std.IPID, iid)
+	if err != nil {
+		return nil, err
+	}
+	return o.client.newObject(o.exporter, iid, std), nil
+}
+
+// Release returns the public references held on the interface, further calls are invalid
+func (o *Object) Release() error {
+	o.lock.Lock()
+	if o.released {
+		o.lock.Unlock()
+		return nil
+	}
+	o.released = true
+	o.lock.Unlock()
+	if o.std.Flags&SorfNoPing == 0 {
+		o.client.pinger.remove(o.std.OID)
+	}
+	if o.std.PublicRefs == 0 {
+		return nil
+	}
+	return o.exporter.remRelease(o.std.IPID, o.std.PublicRefs)
+}
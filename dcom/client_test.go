@@ -0,0 +1,180 @@
+package dcom_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom"
+	"github.com/huskar-t/opcda/dcom/dcomtest"
+	"github.com/huskar-t/opcda/dcom/ndr"
+	"github.com/huskar-t/opcda/dcom/ntlm"
+	"github.com/huskar-t/opcda/dcom/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	clsidEcho = com.GUID{Data1: 0x11111111, Data2: 0x2222, Data3: 0x3333, Data4: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+	iidEcho   = com.GUID{Data1: 0x44444444, Data2: 0x5555, Data3: 0x6666, Data4: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+	iidUpper  = com.GUID{Data1: 0x77777777, Data2: 0x8888, Data3: 0x9999, Data4: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+)
+
+// newEchoServer serves an object whose first interface echoes a string and a variant and whose second upper-cases a string
+func newEchoServer(t *testing.T) *dcomtest.Server {
+	server, err := dcomtest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		server.Close()
+	})
+	server.RegisterClass(clsidEcho, func() *dcomtest.Object {
+		return dcomtest.NewObject().
+			Implement(iidEcho, func(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+				switch opnum {
+				case 3:
+					w.WriteString(r.ReadString())
+					return 0
+				case 4:
+					if !r.ReadPointer() {
+						return uint32(dcom.EInvalidArg)
+					}
+					value, err := dcom.ReadVariant(r)
+					if err != nil {
+						return uint32(dcom.EInvalidArg)
+					}
+					w.WritePointer(true)
+					if err := dcom.WriteVariant(w, value); err != nil {
+						return uint32(dcom.EFail)
+					}
+					return 0
+				}
+				return uint32(dcom.ENotImpl)
+			}).
+			Implement(iidUpper, func(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+				w.WriteString(strings.ToUpper(r.ReadString()))
+				return 0
+			})
+	})
+	return server
+}
+
+func TestClient(t *testing.T) {
+	server := newEchoServer(t)
+	cfg := server.Config()
+	cfg.PingInterval = 10 * time.Millisecond
+	client, err := dcom.Dial(server.Host(), cfg)
+	require.NoError(t, err)
+	defer client.Close()
+
+	object, err := client.CreateInstance(clsidEcho, iidEcho)
+	require.NoError(t, err)
+	assert.Equal(t, iidEcho, object.IID())
+
+	var echoed string
+	err = object.Call(3, func(w *ndr.Writer) {
+		w.WriteString("hello")
+	}, func(r *ndr.Reader) {
+		echoed = r.ReadString()
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", echoed)
+
+	var value interface{}
+	err = object.Call(4, func(w *ndr.Writer) {
+		w.WritePointer(true)
+		require.NoError(t, dcom.WriteVariant(w, []float64{1.5, 2.5}))
+	}, func(r *ndr.Reader) {
+		r.ReadPointer()
+		var decodeErr error
+		value, decodeErr = dcom.ReadVariant(r)
+		require.NoError(t, decodeErr)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1.5, 2.5}, value)
+
+	err = object.Call(5, nil, nil)
+	assert.Equal(t, dcom.ENotImpl, err)
+
+	upper, err := object.QueryInterface(iidUpper)
+	require.NoError(t, err)
+	err = upper.Call(3, func(w *ndr.Writer) {
+		w.WriteString("hello")
+	}, func(r *ndr.Reader) {
+		echoed = r.ReadString()
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO", echoed)
+
+	_, err = object.QueryInterface(com.IID_IOPCServer)
+	assert.Equal(t, dcom.ENoInterface, err)
+
+	assert.Eventually(t, func() bool {
+		return server.Pings() > 0
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, 2, server.References())
+	require.NoError(t, upper.Release())
+	require.NoError(t, object.Release())
+	assert.Equal(t, 0, server.References())
+}
+
+func TestClientClassNotRegistered(t *testing.T) {
+	server := newEchoServer(t)
+	client, err := dcom.Dial(server.Host(), server.Config())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.CreateInstance(iidEcho, iidEcho)
+	var hr dcom.HRESULT
+	require.True(t, errors.As(err, &hr))
+	assert.Equal(t, dcom.EClassNotReg, hr)
+}
+
+func TestClientClosed(t *testing.T) {
+	server := newEchoServer(t)
+	client, err := dcom.Dial(server.Host(), server.Config())
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+	_, err = client.CreateInstance(clsidEcho, iidEcho)
+	assert.Error(t, err)
+}
+
+func TestClientCredentials(t *testing.T) {
+	creds := &ntlm.Credentials{Domain: "WORKGROUP", User: "opc", Password: "secret"}
+	server := newEchoServer(t)
+	server.RequireCredentials(creds)
+	for _, level := range []uint32{com.RPC_C_AUTHN_LEVEL_DEFAULT, com.RPC_C_AUTHN_LEVEL_CONNECT, com.RPC_C_AUTHN_LEVEL_PKT_INTEGRITY} {
+		cfg := server.Config()
+		cfg.Credentials = creds
+		cfg.AuthnLevel = level
+		client, err := dcom.Dial(server.Host(), cfg)
+		require.NoError(t, err)
+		object, err := client.CreateInstance(clsidEcho, iidEcho)
+		require.NoError(t, err, level)
+		var echoed string
+		err = object.Call(3, func(w *ndr.Writer) {
+			w.WriteString("hello")
+		}, func(r *ndr.Reader) {
+			echoed = r.ReadString()
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "hello", echoed)
+		require.NoError(t, object.Release())
+		require.NoError(t, client.Close())
+	}
+}
+
+func TestClientWrongCredentials(t *testing.T) {
+	server := newEchoServer(t)
+	server.RequireCredentials(&ntlm.Credentials{Domain: "WORKGROUP", User: "opc", Password: "secret"})
+	for _, creds := range []*ntlm.Credentials{nil, {Domain: "WORKGROUP", User: "opc", Password: "wrong"}} {
+		cfg := server.Config()
+		cfg.Credentials = creds
+		client, err := dcom.Dial(server.Host(), cfg)
+		require.NoError(t, err)
+		_, err = client.CreateInstance(clsidEcho, iidEcho)
+		assert.Equal(t, &rpc.FaultError{Status: rpc.FaultAccessDenied}, err)
+		require.NoError(t, client.Close())
+	}
+}
@@ -0,0 +1,432 @@
+// Package dcomtest provides a stand-in DCOM server for tests.
+//
+// The Server answers the activator, OXID resolver and IRemUnknown calls of dcom.Client on a single
+// local port and dispatches the ORPC calls on exported objects to the Handler of their interface.
+package dcomtest
+
+import (
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom"
+	"github.com/huskar-t/opcda/dcom/ndr"
+	"github.com/huskar-t/opcda/dcom/ntlm"
+	"github.com/huskar-t/opcda/dcom/rpc"
+)
+
+const (
+	opRemoteCreateInstance = 4
+	opSimplePing           = 1
+	opComplexPing          = 2
+	opResolveOxid2         = 4
+	opRemQueryInterface    = 3
+	opRemAddRef            = 4
+	opRemRelease           = 5
+
+	publicRefs = 5
+
+	// nca_s_fault_ndr
+	faultNDR = 0x000006f7
+	// nca_s_op_rng_error
+	faultOpRange = 0x1c010002
+)
+
+// Handler answers a call on one interface of an object. r is positioned after ORPCTHIS and
+// w after ORPCTHAT, the returned HRESULT ends the response. Failed calls do not need to write
+// their [out] parameters.
+type Handler func(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32
+
+// Object is a stand-in COM object, it implements the interfaces it has a Handler for
+type Object struct {
+	interfaces map[com.GUID]Handler
+	oid        uint64
+}
+
+// NewObject returns an object without interfaces
+func NewObject() *Object {
+	return &Object{interfaces: make(map[com.GUID]Handler)}
+}
+
+// Implement registers the handler of iid and returns o
+func (o *Object) Implement(iid com.GUID, handler Handler) *Object {
+	o.interfaces[iid] = handler
+	return o
+}
+
+type exported struct {
+	object *Object
+	iid    com.GUID
+	refs   uint32
+}
+
+// Server is a stand-in DCOM server listening on the loopback interface
+type Server struct {
+	listener   net.Listener
+	port       int
+	oxid       uint64
+	remUnknown com.GUID
+	lock       sync.Mutex
+	classes    map[com.GUID]func() *Object
+	ipids      map[com.GUID]*exported
+	nextOID    uint64
+	nextSetID  uint64
+	pings      int
+	conns      map[net.Conn]bool
+	creds      *ntlm.Credentials
+	wg         sync.WaitGroup
+}
+
+// NewServer starts a server on a random loopback port
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		listener:   listener,
+		port:       listener.Addr().(*net.TCPAddr).Port,
+		oxid:       0x0102030405060708,
+		remUnknown: dcom.NewCID(),
+		classes:    make(map[com.GUID]func() *Object),
+		ipids:      make(map[com.GUID]*exported),
+		nextOID:    1,
+		conns:      make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// Host returns the address clients connect to
+func (s *Server) Host() string {
+	return "127.0.0.1"
+}
+
+// Config returns a client configuration pointing at the server
+func (s *Server) Config() *dcom.Config {
+	return &dcom.Config{ResolverPort: s.port}
+}
+
+// RequireCredentials makes the server authenticate the following connections with NTLM against creds,
+// calls of connections that do not authenticate fail with an access denied fault
+func (s *Server) RequireCredentials(creds *ntlm.Credentials) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.creds = creds
+}
+
+// RegisterClass makes clsid activatable, create is called for every activation
+func (s *Server) RegisterClass(clsid com.GUID, create func() *Object) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.classes[clsid] = create
+}
+
+// Export marshals the iid interface of o, handlers use it to return interface pointers
+func (s *Server) Export(o *Object, iid com.GUID) ([]byte, error) {
+	s.lock.Lock()
+	std, err := s.export(o, iid)
+	s.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	objRef := &dcom.ObjRef{Flags: dcom.ObjRefStandard, IID: iid, Std: std, ResolverAddr: s.bindings()}
+	return objRef.Marshal()
+}
+
+// Pings returns the number of SimplePing and ComplexPing calls received
+func (s *Server) Pings() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.pings
+}
+
+// References returns the number of exported interfaces that still hold references
+func (s *Server) References() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.ipids)
+}
+
+// Close stops the listener and closes every connection
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.lock.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.conns[conn] = true
+		creds := s.creds
+		s.lock.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if creds != nil {
+				_ = rpc.ServeAuthConn(conn, creds, nil, s.handle)
+			} else {
+				_ = rpc.ServeConn(conn, nil, s.handle)
+			}
+			conn.Close()
+			s.lock.Lock()
+			delete(s.conns, conn)
+			s.lock.Unlock()
+		}()
+	}
+}
+
+func (s *Server) bindings() dcom.DualStringArray {
+	return dcom.DualStringArray{
+		StringBindings: []dcom.StringBinding{{TowerID: dcom.TowerTCP, NetworkAddr: "127.0.0.1[" + strconv.Itoa(s.port) + "]"}},
+	}
+}
+
+// export must be called with the lock held
+func (s *Server) export(o *Object, iid com.GUID) (dcom.StdObjRef, error) {
+	if _, ok := o.interfaces[iid]; !ok {
+		return dcom.StdObjRef{}, dcom.ENoInterface
+	}
+	if o.oid == 0 {
+		o.oid = s.nextOID
+		s.nextOID++
+	}
+	ipid := dcom.NewCID()
+	s.ipids[ipid] = &exported{object: o, iid: iid, refs: publicRefs}
+	return dcom.StdObjRef{PublicRefs: publicRefs, OXID: s.oxid, OID: o.oid, IPID: ipid}, nil
+}
+
+func (s *Server) handle(req *rpc.Request) ([]byte, error) {
+	r := ndr.NewReader(req.Stub)
+	w := ndr.NewWriter()
+	switch req.Interface.UUID {
+	case dcom.IID_IRemoteSCMActivator:
+		if req.Opnum != opRemoteCreateInstance {
+			return nil, &rpc.FaultError{Status: faultOpRange}
+		}
+		s.remoteCreateInstance(r, w)
+	case dcom.IID_IObjectExporter:
+		switch req.Opnum {
+		case opSimplePing:
+			r.ReadUint64()
+			s.lock.Lock()
+			s.pings++
+			s.lock.Unlock()
+			w.WriteUint32(0)
+		case opComplexPing:
+			s.complexPing(r, w)
+		case opResolveOxid2:
+			s.resolveOxid2(r, w)
+		default:
+			return nil, &rpc.FaultError{Status: faultOpRange}
+		}
+	default:
+		if req.Object == nil {
+			return nil, &rpc.FaultError{Status: uint32(dcom.EDisconnect)}
+		}
+		dcom.ReadORPCThis(r)
+		dcom.WriteORPCThat(w)
+		var hr uint32
+		if *req.Object == s.remUnknown && req.Interface.UUID == dcom.IID_IRemUnknown {
+			hr = s.remUnknownCall(req.Opnum, r, w)
+		} else {
+			s.lock.Lock()
+			e, ok := s.ipids[*req.Object]
+			s.lock.Unlock()
+			if !ok || e.iid != req.Interface.UUID {
+				return nil, &rpc.FaultError{Status: uint32(dcom.EDisconnect)}
+			}
+			hr = e.object.interfaces[e.iid](req.Opnum, r, w)
+		}
+		w.Flush()
+		w.WriteUint32(hr)
+	}
+	if r.Err() != nil {
+		return nil, &rpc.FaultError{Status: faultNDR}
+	}
+	return w.Bytes(), nil
+}
+
+func (s *Server) remoteCreateInstance(r *ndr.Reader, w *ndr.Writer) {
+	dcom.ReadORPCThis(r)
+	dcom.ReadInterfacePointer(r)
+	properties := dcom.ReadInterfacePointer(r)
+	dcom.WriteORPCThat(w)
+	if r.Err() != nil {
+		return
+	}
+	request, err := dcom.UnmarshalActivationRequest(properties)
+	if err != nil {
+		r.SetErr(err)
+		return
+	}
+	s.lock.Lock()
+	create, ok := s.classes[request.CLSID]
+	s.lock.Unlock()
+	if !ok {
+		dcom.WriteInterfacePointer(w, nil)
+		w.WriteUint32(uint32(dcom.EClassNotReg))
+		return
+	}
+	object := create()
+	reply := &dcom.ActivationReply{
+		OXID:           s.oxid,
+		OXIDBindings:   s.bindings(),
+		RemUnknownIPID: s.remUnknown,
+		AuthnHint:      2,
+		ServerVersion:  dcom.ClientVersion,
+	}
+	for _, iid := range request.IIDs {
+		result := dcom.ActivationInterface{IID: iid}
+		s.lock.Lock()
+		std, err := s.export(object, iid)
+		s.lock.Unlock()
+		if err != nil {
+			result.HRESULT = uint32(dcom.ENoInterface)
+		} else {
+			objRef := &dcom.ObjRef{Flags: dcom.ObjRefStandard, IID: iid, Std: std, ResolverAddr: s.bindings()}
+			result.ObjRef, err = objRef.Marshal()
+			if err != nil {
+				r.SetErr(err)
+				return
+			}
+		}
+		reply.Interfaces = append(reply.Interfaces, result)
+	}
+	replyObjRef, err := reply.Marshal()
+	if err != nil {
+		r.SetErr(err)
+		return
+	}
+	dcom.WriteInterfacePointer(w, replyObjRef)
+	w.WriteUint32(0)
+}
+
+func (s *Server) complexPing(r *ndr.Reader, w *ndr.Writer) {
+	setID := r.ReadUint64()
+	r.ReadUint16()
+	r.ReadUint16()
+	r.ReadUint16()
+	for i := 0; i < 2; i++ {
+		if r.ReadPointer() {
+			n := r.ReadCount(8)
+			for j := 0; j < n; j++ {
+				r.ReadUint64()
+			}
+		}
+	}
+	s.lock.Lock()
+	s.pings++
+	if setID == 0 {
+		s.nextSetID++
+		setID = s.nextSetID
+	}
+	s.lock.Unlock()
+	w.WriteUint64(setID)
+	w.WriteUint16(0)
+	w.WriteUint32(0)
+}
+
+func (s *Server) resolveOxid2(r *ndr.Reader, w *ndr.Writer) {
+	oxid := r.ReadUint64()
+	if oxid != s.oxid {
+		w.WritePointer(false)
+		w.WriteGUID(&com.GUID{})
+		w.WriteUint32(0)
+		w.WriteUint16(0)
+		w.WriteUint16(0)
+		// OR_INVALID_OXID
+		w.WriteUint32(1910)
+		return
+	}
+	bindings := s.bindings()
+	w.WritePointer(true)
+	bindings.WriteNDR(w)
+	w.WriteGUID(&s.remUnknown)
+	w.WriteUint32(2)
+	w.WriteUint16(dcom.ClientVersion.Major)
+	w.WriteUint16(dcom.ClientVersion.Minor)
+	w.WriteUint32(0)
+}
+
+func (s *Server) remUnknownCall(opnum uint16, r *ndr.Reader, w *ndr.Writer) uint32 {
+	switch opnum {
+	case opRemQueryInterface:
+		ipid := r.ReadGUID()
+		r.ReadUint32()
+		r.ReadUint16()
+		n := r.ReadCount(16)
+		iids := make([]com.GUID, n)
+		for i := range iids {
+			iids[i] = r.ReadGUID()
+		}
+		if r.Err() != nil {
+			return uint32(dcom.EInvalidArg)
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		e, ok := s.ipids[ipid]
+		if !ok {
+			return uint32(dcom.EDisconnect)
+		}
+		w.WritePointer(true)
+		w.WriteUint32(uint32(len(iids)))
+		for _, iid := range iids {
+			std, err := s.export(e.object, iid)
+			hr := uint32(0)
+			if err != nil {
+				hr = uint32(dcom.ENoInterface)
+			}
+			w.Align(8)
+			w.WriteUint32(hr)
+			dcom.WriteStdObjRef(w, &std)
+		}
+		return 0
+	case opRemAddRef, opRemRelease:
+		n := int(r.ReadUint16())
+		r.ReadCount(24)
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		for i := 0; i < n; i++ {
+			ipid := r.ReadGUID()
+			refs := r.ReadUint32()
+			r.ReadUint32()
+			e, ok := s.ipids[ipid]
+			if !ok {
+				continue
+			}
+			if opnum == opRemAddRef {
+				e.refs += refs
+				continue
+			}
+			if refs >= e.refs {
+				delete(s.ipids, ipid)
+			} else {
+				e.refs -= refs
+			}
+		}
+		if opnum == opRemAddRef && n > 0 {
+			// pResults
+			w.WritePointer(true)
+			w.WriteUint32(uint32(n))
+			for i := 0; i < n; i++ {
+				w.WriteUint32(0)
+			}
+		}
+		return 0
+	}
+	return uint32(dcom.ENotImpl)
+}
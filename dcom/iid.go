@@ -0,0 +1,39 @@
+package dcom
+
+import "github.com/huskar-t/opcda/com"
+
+// 000001A0-0000-0000-C000-000000000046
+var IID_IRemoteSCMActivator = com.GUID{Data1: 0x000001A0, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+
+// 99FCFEC4-5260-101B-BBCB-00AA0021347A
+var IID_IObjectExporter = com.GUID{Data1: 0x99FCFEC4, Data2: 0x5260, Data3: 0x101B, Data4: [8]byte{0xBB, 0xCB, 0x00, 0xAA, 0x00, 0x21, 0x34, 0x7A}}
+
+// 00000131-0000-0000-C000-000000000046
+var IID_IRemUnknown = com.GUID{Data1: 0x00000131, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+
+// 000001A2-0000-0000-C000-000000000046
+var IID_IActivationPropertiesIn = com.GUID{Data1: 0x000001A2, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+
+// 000001A3-0000-0000-C000-000000000046
+var IID_IActivationPropertiesOut = com.GUID{Data1: 0x000001A3, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+
+// 00000338-0000-0000-C000-000000000046
+var CLSID_ActivationPropertiesIn = com.GUID{Data1: 0x00000338, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+
+// 00000339-0000-0000-C000-000000000046
+var CLSID_ActivationPropertiesOut = com.GUID{Data1: 0x00000339, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+
+// activation property class IDs
+var (
+	CLSID_SpecialSystemProperties = com.GUID{Data1: 0x000001B9, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	CLSID_InstantiationInfo       = com.GUID{Data1: 0x000001AB, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	CLSID_ActivationContextInfo   = com.GUID{Data1: 0x000001A5, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	CLSID_SecurityInfo            = com.GUID{Data1: 0x000001A6, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	CLSID_ServerLocationInfo      = com.GUID{Data1: 0x000001A4, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	CLSID_ScmRequestInfo          = com.GUID{Data1: 0x000001AA, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	CLSID_PropsOutInfo            = com.GUID{Data1: 0x00000339, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	CLSID_ScmReplyInfo            = com.GUID{Data1: 0x000001B6, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+)
+
+// 00000101-0000-0000-C000-000000000046
+var IID_IEnumString = com.GUID{Data1: 0x00000101, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
@@ -0,0 +1,352 @@
+// Package ndr implements the little-endian NDR20 transfer syntax used by DCE-RPC.
+//
+// Alignment is relative to the start of the stub. Pointees of embedded pointers are
+// deferred with Defer and written or read by Flush once the containing parameter is complete.
+package ndr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf16"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+// ErrShortBuffer is returned when the stub ends before the data being read
+var ErrShortBuffer = errors.New("ndr: short buffer")
+
+const firstReferentID = 0x00020000
+
+// Writer marshals values into an NDR stub
+type Writer struct {
+	buf      []byte
+	referent uint32
+	deferred []func()
+}
+
+// NewWriter returns an empty Writer
+func NewWriter() *Writer {
+	return &Writer{referent: firstReferentID}
+}
+
+// Bytes returns the marshalled stub
+func (w *Writer) Bytes() []byte {
+	return w.buf
+}
+
+// Len returns the number of bytes written
+func (w *Writer) Len() int {
+	return len(w.buf)
+}
+
+// Align pads the stub with zeros to a multiple of n
+func (w *Writer) Align(n int) {
+	for len(w.buf)%n != 0 {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *Writer) WriteUint8(v uint8) {
+	w.buf = append(w.buf, v)
+}
+
+func (w *Writer) WriteUint16(v uint16) {
+	w.Align(2)
+	w.buf = binary.LittleEndian.AppendUint16(w.buf, v)
+}
+
+func (w *Writer) WriteUint32(v uint32) {
+	w.Align(4)
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, v)
+}
+
+func (w *Writer) WriteUint64(v uint64) {
+	w.Align(8)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, v)
+}
+
+func (w *Writer) WriteInt32(v int32) {
+	w.WriteUint32(uint32(v))
+}
+
+func (w *Writer) WriteFloat32(v float32) {
+	w.WriteUint32(math.Float32bits(v))
+}
+
+func (w *Writer) WriteFloat64(v float64) {
+	w.WriteUint64(math.Float64bits(v))
+}
+
+// PatchUint32 overwrites the uint32 previously written at off
+func (w *Writer) PatchUint32(off int, v uint32) {
+	binary.LittleEndian.PutUint32(w.buf[off:], v)
+}
+
+// WriteBytes writes b without alignment
+func (w *Writer) WriteBytes(b []byte) {
+	w.buf = append(w.buf, b...)
+}
+
+// WriteGUID writes a GUID, aligned to 4
+func (w *Writer) WriteGUID(g *com.GUID) {
+	w.WriteUint32(g.Data1)
+	w.WriteUint16(g.Data2)
+	w.WriteUint16(g.Data3)
+	w.WriteBytes(g.Data4[:])
+}
+
+// WritePointer writes a referent ID for a unique or full pointer, zero when present is false
+func (w *Writer) WritePointer(present bool) bool {
+	if !present {
+		w.WriteUint32(0)
+		return false
+	}
+	w.WriteUint32(w.referent)
+	w.referent += 4
+	return true
+}
+
+// Defer queues the marshalling of an embedded pointee until Flush
+func (w *Writer) Defer(f func()) {
+	w.deferred = append(w.deferred, f)
+}
+
+// Flush writes the deferred pointees, pointees queued while writing a pointee follow it directly
+func (w *Writer) Flush() {
+	for len(w.deferred) > 0 {
+		queue := w.deferred
+		w.deferred = nil
+		for _, f := range queue {
+			f()
+			w.Flush()
+		}
+	}
+}
+
+// WriteString writes a conformant varying NUL terminated wide string ([string] wchar_t*)
+func (w *Writer) WriteString(s string) {
+	chars := utf16.Encode([]rune(s))
+	n := uint32(len(chars) + 1)
+	w.WriteUint32(n)
+	w.WriteUint32(0)
+	w.WriteUint32(n)
+	for _, c := range chars {
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, c)
+	}
+	w.buf = binary.LittleEndian.AppendUint16(w.buf, 0)
+}
+
+// WriteUniqueString writes a [unique, string] wchar_t* parameter, the empty string is sent as NULL when null is true
+func (w *Writer) WriteUniqueString(s string, null bool) {
+	if w.WritePointer(!null) {
+		w.WriteString(s)
+	}
+}
+
+// WriteUint32Array writes a conformant array of uint32
+func (w *Writer) WriteUint32Array(values []uint32) {
+	w.WriteUint32(uint32(len(values)))
+	for _, v := range values {
+		w.WriteUint32(v)
+	}
+}
+
+// Reader unmarshals values from an NDR stub, the first error is kept and reported by Err
+type Reader struct {
+	buf      []byte
+	off      int
+	err      error
+	deferred []func()
+}
+
+// NewReader returns a Reader over b
+func NewReader(b []byte) *Reader {
+	return &Reader{buf: b}
+}
+
+// Err returns the first error encountered
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// SetErr records err unless an error is already recorded
+func (r *Reader) SetErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Offset returns the current position in the stub
+func (r *Reader) Offset() int {
+	return r.off
+}
+
+// Remaining returns the number of unread bytes
+func (r *Reader) Remaining() int {
+	return len(r.buf) - r.off
+}
+
+// Align skips padding up to a multiple of n
+func (r *Reader) Align(n int) {
+	for r.off%n != 0 {
+		r.off++
+	}
+	if r.off > len(r.buf) {
+		r.SetErr(ErrShortBuffer)
+		r.off = len(r.buf)
+	}
+}
+
+func (r *Reader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if n < 0 || r.off+n > len(r.buf) {
+		r.SetErr(ErrShortBuffer)
+		return make([]byte, n)
+	}
+	b := r.buf[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *Reader) ReadUint8() uint8 {
+	return r.next(1)[0]
+}
+
+func (r *Reader) ReadUint16() uint16 {
+	r.Align(2)
+	return binary.LittleEndian.Uint16(r.next(2))
+}
+
+func (r *Reader) ReadUint32() uint32 {
+	r.Align(4)
+	return binary.LittleEndian.Uint32(r.next(4))
+}
+
+func (r *Reader) ReadUint64() uint64 {
+	r.Align(8)
+	return binary.LittleEndian.Uint64(r.next(8))
+}
+
+func (r *Reader) ReadInt32() int32 {
+	return int32(r.ReadUint32())
+}
+
+func (r *Reader) ReadFloat32() float32 {
+	return math.Float32frombits(r.ReadUint32())
+}
+
+func (r *Reader) ReadFloat64() float64 {
+	return math.Float64frombits(r.ReadUint64())
+}
+
+// ReadBytes reads n bytes without alignment, the result is a copy
+func (r *Reader) ReadBytes(n int) []byte {
+	b := r.next(n)
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// Skip advances n bytes without alignment
+func (r *Reader) Skip(n int) {
+	if r.err != nil {
+		return
+	}
+	if n < 0 || n > r.Remaining() {
+		r.SetErr(ErrShortBuffer)
+		r.off = len(r.buf)
+		return
+	}
+	r.off += n
+}
+
+// ReadGUID reads a GUID, aligned to 4
+func (r *Reader) ReadGUID() com.GUID {
+	var g com.GUID
+	g.Data1 = r.ReadUint32()
+	g.Data2 = r.ReadUint16()
+	g.Data3 = r.ReadUint16()
+	copy(g.Data4[:], r.next(8))
+	return g
+}
+
+// ReadPointer reads a referent ID and reports whether the pointer is not NULL
+func (r *Reader) ReadPointer() bool {
+	return r.ReadUint32() != 0
+}
+
+// Defer queues the unmarshalling of an embedded pointee until Flush
+func (r *Reader) Defer(f func()) {
+	r.deferred = append(r.deferred, f)
+}
+
+// Flush reads the deferred pointees in the order Writer.Flush writes them
+func (r *Reader) Flush() {
+	for len(r.deferred) > 0 {
+		queue := r.deferred
+		r.deferred = nil
+		for _, f := range queue {
+			f()
+			r.Flush()
+		}
+	}
+}
+
+// ReadCount reads a conformance or variance value and checks it against the remaining stub
+func (r *Reader) ReadCount(elementSize int) int {
+	n := r.ReadUint32()
+	if r.err != nil {
+		return 0
+	}
+	if elementSize > 0 && uint64(n)*uint64(elementSize) > uint64(r.Remaining()) {
+		r.SetErr(fmt.Errorf("ndr: count %d exceeds the remaining %d bytes", n, r.Remaining()))
+		return 0
+	}
+	return int(n)
+}
+
+// ReadString reads a conformant varying wide string and drops the terminating NUL
+func (r *Reader) ReadString() string {
+	r.ReadCount(0)
+	offset := r.ReadUint32()
+	n := r.ReadCount(2)
+	if r.err != nil {
+		return ""
+	}
+	r.next(int(offset) * 2)
+	return r.readChars(n)
+}
+
+func (r *Reader) readChars(n int) string {
+	raw := r.next(n * 2)
+	chars := make([]uint16, n)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(raw[i*2:])
+	}
+	for len(chars) > 0 && chars[len(chars)-1] == 0 {
+		chars = chars[:len(chars)-1]
+	}
+	return string(utf16.Decode(chars))
+}
+
+// ReadUniqueString reads a [unique, string] wchar_t*, NULL is returned as the empty string
+func (r *Reader) ReadUniqueString() string {
+	if !r.ReadPointer() {
+		return ""
+	}
+	return r.ReadString()
+}
+
+// ReadUint32Array reads a conformant array of uint32
+func (r *Reader) ReadUint32Array() []uint32 {
+	n := r.ReadCount(4)
+	values := make([]uint32, n)
+	for i := range values {
+		values[i] = r.ReadUint32()
+	}
+	return values
+}
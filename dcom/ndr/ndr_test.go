@@ -0,0 +1,89 @@
+package ndr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterAlignment(t *testing.T) {
+	w := NewWriter()
+	w.WriteUint8(1)
+	w.WriteUint16(2)
+	w.WriteUint32(3)
+	w.WriteUint8(4)
+	w.WriteUint64(5)
+	assert.Equal(t, []byte{
+		1, 0, 2, 0,
+		3, 0, 0, 0,
+		4, 0, 0, 0, 0, 0, 0, 0,
+		5, 0, 0, 0, 0, 0, 0, 0,
+	}, w.Bytes())
+}
+
+func TestWriteString(t *testing.T) {
+	w := NewWriter()
+	w.WriteString("ab")
+	assert.Equal(t, []byte{
+		3, 0, 0, 0,
+		0, 0, 0, 0,
+		3, 0, 0, 0,
+		'a', 0, 'b', 0, 0, 0,
+	}, w.Bytes())
+	r := NewReader(w.Bytes())
+	assert.Equal(t, "ab", r.ReadString())
+	require.NoError(t, r.Err())
+}
+
+func TestDeferredPointees(t *testing.T) {
+	w := NewWriter()
+	// struct { wchar_t *a; long b; wchar_t *c; }
+	w.WritePointer(true)
+	w.Defer(func() {
+		w.WriteString("a")
+	})
+	w.WriteUint32(7)
+	w.WritePointer(false)
+	w.Flush()
+	assert.Equal(t, []byte{
+		0x00, 0x00, 0x02, 0x00,
+		7, 0, 0, 0,
+		0, 0, 0, 0,
+		2, 0, 0, 0,
+		0, 0, 0, 0,
+		2, 0, 0, 0,
+		'a', 0, 0, 0,
+	}, w.Bytes())
+
+	r := NewReader(w.Bytes())
+	var a string
+	if r.ReadPointer() {
+		r.Defer(func() {
+			a = r.ReadString()
+		})
+	}
+	assert.Equal(t, uint32(7), r.ReadUint32())
+	assert.False(t, r.ReadPointer())
+	r.Flush()
+	require.NoError(t, r.Err())
+	assert.Equal(t, "a", a)
+	assert.Equal(t, 0, r.Remaining())
+}
+
+func TestReaderShortBuffer(t *testing.T) {
+	r := NewReader([]byte{1, 0})
+	r.ReadUint32()
+	assert.ErrorIs(t, r.Err(), ErrShortBuffer)
+	assert.Equal(t, uint16(0), r.ReadUint16())
+
+	r = NewReader([]byte{0xff, 0xff, 0xff, 0x7f})
+	assert.Equal(t, 0, r.ReadCount(4))
+	assert.Error(t, r.Err())
+	r = NewReader([]byte{1, 2, 3})
+	r.Skip(2)
+	assert.Equal(t, uint8(3), r.ReadUint8())
+	r.Skip(1 << 40)
+	assert.ErrorIs(t, r.Err(), ErrShortBuffer)
+	assert.Zero(t, r.Remaining())
+}
@@ -0,0 +1,140 @@
+package ntlm
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// Client runs the client side of an NTLMv2 authentication
+type Client struct {
+	creds     Credentials
+	flags     uint32
+	negotiate []byte
+	session   *Session
+
+	rand io.Reader
+	now  func() time.Time
+}
+
+// NewClient creates a client authenticating with creds
+func NewClient(creds *Credentials) *Client {
+	return &Client{
+		creds: *creds,
+		flags: defaultFlags,
+		rand:  rand.Reader,
+		now:   time.Now,
+	}
+}
+
+// Negotiate returns the NEGOTIATE_MESSAGE that starts the authentication
+func (c *Client) Negotiate() []byte {
+	c.negotiate = marshalNegotiate(c.flags)
+	return c.negotiate
+}
+
+// Authenticate answers the CHALLENGE_MESSAGE of the server with an AUTHENTICATE_MESSAGE.
+// The session is available from Session once Authenticate succeeds.
+func (c *Client) Authenticate(challenge []byte) ([]byte, error) {
+	if c.negotiate == nil {
+		return nil, errors.New("ntlm: Authenticate called before Negotiate")
+	}
+	if err := checkMessage(challenge, messageChallenge, 48); err != nil {
+		return nil, err
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:]) & c.flags
+	if flags&NegotiateExtendedSessionSecurity == 0 || flags&NegotiateNTLM == 0 {
+		return nil, errors.New("ntlm: server does not support NTLMv2 with extended session security")
+	}
+	if flags&NegotiateUnicode == 0 {
+		return nil, errors.New("ntlm: server does not support unicode")
+	}
+	serverChallenge := challenge[24:32]
+	targetInfo, err := readField(challenge, 40)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := parseAVPairs(append(targetInfo[:len(targetInfo):len(targetInfo)], 0, 0, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	clientChallenge := make([]byte, 8)
+	if _, err = io.ReadFull(c.rand, clientChallenge); err != nil {
+		return nil, err
+	}
+	timestamp := timeToFileTime(c.now())
+	value, withMIC := findAVPair(pairs, avTimestamp)
+	if withMIC && len(value) == 8 {
+		timestamp = binary.LittleEndian.Uint64(value)
+	}
+	if withMIC {
+		pairs = setAVFlags(pairs, avFlagMIC)
+	}
+
+	responseKey := ntowfv2(c.creds.Password, c.creds.User, c.creds.Domain)
+	nt, lm, sessionBaseKey := computeResponse(responseKey, serverChallenge, clientChallenge, timestamp, marshalAVPairs(pairs))
+	if withMIC {
+		// the server provided a timestamp, LMv2 is not sent
+		lm = make([]byte, 24)
+	}
+	exportedSessionKey := sessionBaseKey
+	var encryptedKey []byte
+	if flags&NegotiateKeyExch != 0 {
+		exportedSessionKey = make([]byte, 16)
+		if _, err = io.ReadFull(c.rand, exportedSessionKey); err != nil {
+			return nil, err
+		}
+		encryptedKey = rc4K(sessionBaseKey, exportedSessionKey)
+	}
+
+	msg := marshalAuthenticate(flags, lm, nt, c.creds.Domain, c.creds.User, c.creds.Workstation, encryptedKey)
+	if withMIC {
+		copy(msg[micOffset:], hmacMD5(exportedSessionKey, c.negotiate, challenge, msg))
+	}
+	c.session = newSession(flags, exportedSessionKey, true)
+	return msg, nil
+}
+
+// Session returns the session established by Authenticate, nil before
+func (c *Client) Session() *Session {
+	return c.session
+}
+
+// setAVFlags ORs flags into MsvAvFlags, adding the pair before the list end when missing
+func setAVFlags(pairs []avPair, flags uint32) []avPair {
+	out := make([]avPair, 0, len(pairs)+1)
+	found := false
+	for _, p := range pairs {
+		if p.id == avFlags && len(p.value) == 4 {
+			value := make([]byte, 4)
+			binary.LittleEndian.PutUint32(value, binary.LittleEndian.Uint32(p.value)|flags)
+			p.value = value
+			found = true
+		}
+		out = append(out, p)
+	}
+	if !found {
+		value := make([]byte, 4)
+		binary.LittleEndian.PutUint32(value, flags)
+		out = append(out, avPair{id: avFlags, value: value})
+	}
+	return out
+}
+
+func marshalAuthenticate(flags uint32, lm, nt []byte, domain, user, workstation string, encryptedKey []byte) []byte {
+	p := &payload{header: make([]byte, authenticateHeaderLength)}
+	copy(p.header, signature)
+	binary.LittleEndian.PutUint32(p.header[8:], messageAuthenticate)
+	p.field(28, utf16le(domain))
+	p.field(36, utf16le(user))
+	p.field(44, utf16le(workstation))
+	p.field(12, lm)
+	p.field(20, nt)
+	p.field(52, encryptedKey)
+	binary.LittleEndian.PutUint32(p.header[60:], flags)
+	copy(p.header[64:], version)
+	return p.bytes()
+}
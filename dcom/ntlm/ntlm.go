@@ -0,0 +1,247 @@
+// Package ntlm implements the NTLMv2 authentication protocol (MS-NLMP) used to authenticate DCE-RPC connections.
+//
+// Only NTLMv2 with extended session security is supported. A Client produces the NEGOTIATE and AUTHENTICATE
+// messages, a Server answers them for stand-in servers in tests, and both end with a Session that signs and
+// seals the messages of the connection.
+package ntlm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Negotiate flags
+const (
+	NegotiateUnicode                 = 0x00000001
+	NegotiateOEM                     = 0x00000002
+	RequestTarget                    = 0x00000004
+	NegotiateSign                    = 0x00000010
+	NegotiateSeal                    = 0x00000020
+	NegotiateNTLM                    = 0x00000200
+	NegotiateAlwaysSign              = 0x00008000
+	TargetTypeDomain                 = 0x00010000
+	TargetTypeServer                 = 0x00020000
+	NegotiateExtendedSessionSecurity = 0x00080000
+	NegotiateTargetInfo              = 0x00800000
+	NegotiateVersion                 = 0x02000000
+	Negotiate128                     = 0x20000000
+	NegotiateKeyExch                 = 0x40000000
+	Negotiate56                      = 0x80000000
+)
+
+// defaultFlags are the flags requested by the client
+const defaultFlags = NegotiateUnicode | RequestTarget | NegotiateSign | NegotiateSeal | NegotiateNTLM |
+	NegotiateAlwaysSign | NegotiateExtendedSessionSecurity | NegotiateTargetInfo | NegotiateVersion |
+	Negotiate128 | NegotiateKeyExch | Negotiate56
+
+// AV pair identifiers
+const (
+	avEOL             = 0
+	avNbComputerName  = 1
+	avNbDomainName    = 2
+	avDNSComputerName = 3
+	avDNSDomainName   = 4
+	avFlags           = 6
+	avTimestamp       = 7
+)
+
+// avFlagMIC is set in MsvAvFlags when the AUTHENTICATE message carries a MIC
+const avFlagMIC = 0x00000002
+
+const (
+	messageNegotiate    = 1
+	messageChallenge    = 2
+	messageAuthenticate = 3
+
+	authenticateHeaderLength = 88
+	micOffset                = 72
+)
+
+var signature = []byte("NTLMSSP\x00")
+
+// version is the VERSION structure sent by the client, Windows 6.1 build 7601 with NTLMSSP_REVISION_W2K3
+var version = []byte{6, 1, 0xb1, 0x1d, 0, 0, 0, 15}
+
+// ErrAuthentication is returned by Server.Authenticate when the response does not match the credentials
+var ErrAuthentication = errors.New("ntlm: authentication failed")
+
+// Credentials identify the account used for the authentication
+type Credentials struct {
+	Domain   string
+	User     string
+	Password string
+	// Workstation is the name of the client machine sent to the server, it may be empty
+	Workstation string
+}
+
+func utf16le(s string) []byte {
+	chars := utf16.Encode([]rune(s))
+	b := make([]byte, 0, len(chars)*2)
+	for _, c := range chars {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return b
+}
+
+func fromUTF16le(b []byte) string {
+	chars := make([]uint16, len(b)/2)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(chars))
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func rc4K(key, data []byte) []byte {
+	cipher, err := rc4.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	out := make([]byte, len(data))
+	cipher.XORKeyStream(out, data)
+	return out
+}
+
+// ntowfv2 is NTOWFv2 and LMOWFv2, the response key derived from the password
+func ntowfv2(password, user, domain string) []byte {
+	hash := md4(utf16le(password))
+	return hmacMD5(hash[:], utf16le(strings.ToUpper(user)+domain))
+}
+
+// ntlmv2Temp returns the NTLMv2_CLIENT_CHALLENGE structure hashed into the response
+func ntlmv2Temp(timestamp uint64, clientChallenge []byte, targetInfo []byte) []byte {
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = binary.LittleEndian.AppendUint64(temp, timestamp)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	return append(temp, 0, 0, 0, 0)
+}
+
+// computeResponse returns the NT and LM challenge responses and the session base key
+func computeResponse(responseKey, serverChallenge, clientChallenge []byte, timestamp uint64, targetInfo []byte) (nt, lm, sessionBaseKey []byte) {
+	temp := ntlmv2Temp(timestamp, clientChallenge, targetInfo)
+	proof := hmacMD5(responseKey, serverChallenge, temp)
+	nt = append(proof, temp...)
+	lm = append(hmacMD5(responseKey, serverChallenge, clientChallenge), clientChallenge...)
+	sessionBaseKey = hmacMD5(responseKey, proof)
+	return
+}
+
+type avPair struct {
+	id    uint16
+	value []byte
+}
+
+func parseAVPairs(b []byte) ([]avPair, error) {
+	var pairs []avPair
+	for {
+		if len(b) < 4 {
+			return nil, errors.New("ntlm: truncated AV pair list")
+		}
+		id := binary.LittleEndian.Uint16(b)
+		n := int(binary.LittleEndian.Uint16(b[2:]))
+		if id == avEOL {
+			return pairs, nil
+		}
+		if len(b) < 4+n {
+			return nil, errors.New("ntlm: truncated AV pair")
+		}
+		pairs = append(pairs, avPair{id: id, value: b[4 : 4+n]})
+		b = b[4+n:]
+	}
+}
+
+func marshalAVPairs(pairs []avPair) []byte {
+	var b []byte
+	for _, p := range pairs {
+		b = binary.LittleEndian.AppendUint16(b, p.id)
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(p.value)))
+		b = append(b, p.value...)
+	}
+	return append(b, 0, 0, 0, 0)
+}
+
+func findAVPair(pairs []avPair, id uint16) ([]byte, bool) {
+	for _, p := range pairs {
+		if p.id == id {
+			return p.value, true
+		}
+	}
+	return nil, false
+}
+
+// payload builds the variable part of a message, fields point into it
+type payload struct {
+	header []byte
+	data   []byte
+}
+
+// field writes the length, maximum length and offset of value at off in the header
+func (p *payload) field(off int, value []byte) {
+	binary.LittleEndian.PutUint16(p.header[off:], uint16(len(value)))
+	binary.LittleEndian.PutUint16(p.header[off+2:], uint16(len(value)))
+	binary.LittleEndian.PutUint32(p.header[off+4:], uint32(len(p.header)+len(p.data)))
+	p.data = append(p.data, value...)
+}
+
+func (p *payload) bytes() []byte {
+	return append(p.header, p.data...)
+}
+
+// readField returns the value a length, maximum length and offset field at off points to
+func readField(msg []byte, off int) ([]byte, error) {
+	if len(msg) < off+8 {
+		return nil, errors.New("ntlm: truncated message")
+	}
+	n := int(binary.LittleEndian.Uint16(msg[off:]))
+	start := int(binary.LittleEndian.Uint32(msg[off+4:]))
+	if n == 0 {
+		return nil, nil
+	}
+	if start < 0 || start+n > len(msg) {
+		return nil, fmt.Errorf("ntlm: field at %d points outside the message", off)
+	}
+	return msg[start : start+n], nil
+}
+
+func checkMessage(msg []byte, messageType uint32, minLength int) error {
+	if len(msg) < minLength || !bytes.Equal(msg[:8], signature) {
+		return errors.New("ntlm: invalid message")
+	}
+	if t := binary.LittleEndian.Uint32(msg[8:]); t != messageType {
+		return fmt.Errorf("ntlm: unexpected message type %d", t)
+	}
+	return nil
+}
+
+func marshalNegotiate(flags uint32) []byte {
+	p := &payload{header: make([]byte, 40)}
+	copy(p.header, signature)
+	binary.LittleEndian.PutUint32(p.header[8:], messageNegotiate)
+	binary.LittleEndian.PutUint32(p.header[12:], flags)
+	p.field(16, nil)
+	p.field(24, nil)
+	copy(p.header[32:], version)
+	return p.bytes()
+}
+
+// timeToFileTime converts t into 100ns intervals since 1601
+func timeToFileTime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100 + 116444736000000000)
+}
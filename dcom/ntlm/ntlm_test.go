@@ -0,0 +1,117 @@
+package ntlm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// MS-NLMP 4.2.4 NTLMv2 Authentication
+var (
+	testFlags           = uint32(0xe28a8233)
+	testServerChallenge = "0123456789abcdef"
+	testClientChallenge = "aaaaaaaaaaaaaaaa"
+	testRandomKey       = bytes.Repeat([]byte{0x55}, 16)
+	testTargetInfo      = "02000c0044006f006d00610069006e0001000c0053006500720076006500720000000000"
+)
+
+func TestNTOWFv2(t *testing.T) {
+	assert.Equal(t, "0c868a403bfd7a93a3001ef22ef02e3f", hex.EncodeToString(ntowfv2("Password", "User", "Domain")))
+}
+
+func TestComputeResponse(t *testing.T) {
+	key := ntowfv2("Password", "User", "Domain")
+	nt, lm, sessionBaseKey := computeResponse(key, unhex(t, testServerChallenge), unhex(t, testClientChallenge), 0, unhex(t, testTargetInfo))
+	assert.Equal(t, "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa", hex.EncodeToString(lm))
+	assert.Equal(t, "68cd0ab851e51c96aabc927bebef6a1c", hex.EncodeToString(nt[:16]))
+	assert.Equal(t, "8de40ccadbc14a82f15cb0ad0de95ca3", hex.EncodeToString(sessionBaseKey))
+	assert.Equal(t, "c5dad2544fc9799094ce1ce90bc9d03e", hex.EncodeToString(rc4K(sessionBaseKey, testRandomKey)))
+}
+
+func TestSessionKeys(t *testing.T) {
+	s := newSession(testFlags, testRandomKey, true)
+	assert.Equal(t, "4788dc861b4782f35d43fd98fe1a2d39", hex.EncodeToString(s.signKey))
+	assert.Equal(t, "59f600973cc4960a25480a7c196e4c58", hex.EncodeToString(sealKey(testFlags, testRandomKey, clientSealing)))
+}
+
+func TestSeal(t *testing.T) {
+	s := newSession(testFlags, testRandomKey, true)
+	data := utf16le("Plaintext")
+	sig := s.Seal(data, append([]byte(nil), data...))
+	assert.Equal(t, "54e50165bf1936dc996020c1811b0f06fb5f", hex.EncodeToString(data))
+	assert.Equal(t, "010000007fb38ec5c55d497600000000", hex.EncodeToString(sig))
+}
+
+func TestHandshake(t *testing.T) {
+	creds := &Credentials{Domain: "Domain", User: "User", Password: "Password", Workstation: "COMPUTER"}
+	client := NewClient(creds)
+	server := NewServer(creds)
+	server.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	challenge, err := server.Challenge(client.Negotiate())
+	require.NoError(t, err)
+	authenticate, err := client.Authenticate(challenge)
+	require.NoError(t, err)
+	require.NoError(t, server.Authenticate(authenticate))
+
+	cs, ss := client.Session(), server.Session()
+	require.NotNil(t, cs)
+	require.NotNil(t, ss)
+	assert.Equal(t, cs.Flags(), ss.Flags())
+	for i := 0; i < 3; i++ {
+		msg := []byte("request")
+		sig := cs.Sign(msg)
+		assert.NoError(t, ss.Verify(msg, sig))
+
+		plain := []byte("header|response")
+		msg = append([]byte(nil), plain...)
+		sig = ss.Seal(msg[7:], append([]byte(nil), msg...))
+		assert.NotEqual(t, plain, msg)
+		// the signature covers the plaintext
+		assert.NoError(t, cs.Unseal(msg[7:], plain, sig))
+		assert.Equal(t, plain, msg)
+	}
+	assert.ErrorIs(t, ss.Verify([]byte("replayed"), cs.Sign([]byte("other"))), ErrSignature)
+}
+
+func TestHandshakeWrongPassword(t *testing.T) {
+	client := NewClient(&Credentials{Domain: "Domain", User: "User", Password: "wrong"})
+	server := NewServer(&Credentials{Domain: "Domain", User: "User", Password: "Password"})
+	challenge, err := server.Challenge(client.Negotiate())
+	require.NoError(t, err)
+	authenticate, err := client.Authenticate(challenge)
+	require.NoError(t, err)
+	assert.ErrorIs(t, server.Authenticate(authenticate), ErrAuthentication)
+	assert.Nil(t, server.Session())
+}
+
+func TestHandshakeTamperedMIC(t *testing.T) {
+	creds := &Credentials{Domain: "Domain", User: "User", Password: "Password"}
+	client := NewClient(creds)
+	server := NewServer(creds)
+	challenge, err := server.Challenge(client.Negotiate())
+	require.NoError(t, err)
+	authenticate, err := client.Authenticate(challenge)
+	require.NoError(t, err)
+	authenticate[micOffset] ^= 0xff
+	assert.ErrorIs(t, server.Authenticate(authenticate), ErrAuthentication)
+}
+
+func TestInvalidChallenge(t *testing.T) {
+	client := NewClient(&Credentials{User: "User"})
+	client.Negotiate()
+	_, err := client.Authenticate([]byte("NTLMSSP\x00"))
+	assert.Error(t, err)
+	_, err = client.Authenticate(client.Negotiate())
+	assert.Error(t, err)
+}
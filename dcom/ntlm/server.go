@@ -0,0 +1,125 @@
+package ntlm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// serverFlags are the flags a Server accepts
+const serverFlags = defaultFlags | TargetTypeDomain
+
+// Server runs the server side of an NTLMv2 authentication against a single account, it is meant for stand-in
+// servers in tests
+type Server struct {
+	creds           Credentials
+	negotiate       []byte
+	challenge       []byte
+	serverChallenge []byte
+	session         *Session
+
+	rand io.Reader
+	now  func() time.Time
+}
+
+// NewServer creates a server accepting creds, creds.Workstation is used as the server name
+func NewServer(creds *Credentials) *Server {
+	return &Server{
+		creds: *creds,
+		rand:  rand.Reader,
+		now:   time.Now,
+	}
+}
+
+// Challenge answers a NEGOTIATE_MESSAGE with a CHALLENGE_MESSAGE
+func (s *Server) Challenge(negotiate []byte) ([]byte, error) {
+	if err := checkMessage(negotiate, messageNegotiate, 16); err != nil {
+		return nil, err
+	}
+	flags := binary.LittleEndian.Uint32(negotiate[12:])&serverFlags | NegotiateTargetInfo | TargetTypeDomain
+	s.serverChallenge = make([]byte, 8)
+	if _, err := io.ReadFull(s.rand, s.serverChallenge); err != nil {
+		return nil, err
+	}
+	timestamp := make([]byte, 8)
+	binary.LittleEndian.PutUint64(timestamp, timeToFileTime(s.now()))
+	targetInfo := marshalAVPairs([]avPair{
+		{id: avNbDomainName, value: utf16le(s.creds.Domain)},
+		{id: avNbComputerName, value: utf16le(s.creds.Workstation)},
+		{id: avTimestamp, value: timestamp},
+	})
+
+	p := &payload{header: make([]byte, 56)}
+	copy(p.header, signature)
+	binary.LittleEndian.PutUint32(p.header[8:], messageChallenge)
+	p.field(12, utf16le(s.creds.Domain))
+	binary.LittleEndian.PutUint32(p.header[20:], flags)
+	copy(p.header[24:], s.serverChallenge)
+	p.field(40, targetInfo)
+	copy(p.header[48:], version)
+	s.negotiate = append([]byte(nil), negotiate...)
+	s.challenge = p.bytes()
+	return s.challenge, nil
+}
+
+// Authenticate checks an AUTHENTICATE_MESSAGE, it returns ErrAuthentication when the credentials do not match
+func (s *Server) Authenticate(authenticate []byte) error {
+	if s.challenge == nil {
+		return errors.New("ntlm: Authenticate called before Challenge")
+	}
+	if err := checkMessage(authenticate, messageAuthenticate, authenticateHeaderLength); err != nil {
+		return err
+	}
+	var fields [6][]byte
+	for i, off := range []int{12, 20, 28, 36, 44, 52} {
+		value, err := readField(authenticate, off)
+		if err != nil {
+			return err
+		}
+		fields[i] = value
+	}
+	nt, domain, user, encryptedKey := fields[1], fromUTF16le(fields[2]), fromUTF16le(fields[3]), fields[5]
+	flags := binary.LittleEndian.Uint32(authenticate[60:])
+	if len(nt) < 16+28 || !strings.EqualFold(user, s.creds.User) || !strings.EqualFold(domain, s.creds.Domain) {
+		return ErrAuthentication
+	}
+
+	responseKey := ntowfv2(s.creds.Password, user, domain)
+	proof, temp := nt[:16], nt[16:]
+	if !hmac.Equal(proof, hmacMD5(responseKey, s.serverChallenge, temp)) {
+		return ErrAuthentication
+	}
+	sessionBaseKey := hmacMD5(responseKey, proof)
+	exportedSessionKey := sessionBaseKey
+	if flags&NegotiateKeyExch != 0 {
+		if len(encryptedKey) != 16 {
+			return ErrAuthentication
+		}
+		exportedSessionKey = rc4K(sessionBaseKey, encryptedKey)
+	}
+
+	pairs, err := parseAVPairs(temp[28:])
+	if err != nil {
+		return err
+	}
+	if value, ok := findAVPair(pairs, avFlags); ok && len(value) == 4 && binary.LittleEndian.Uint32(value)&avFlagMIC != 0 {
+		msg := append([]byte(nil), authenticate...)
+		mic := append([]byte(nil), msg[micOffset:micOffset+16]...)
+		copy(msg[micOffset:micOffset+16], make([]byte, 16))
+		if !bytes.Equal(mic, hmacMD5(exportedSessionKey, s.negotiate, s.challenge, msg)) {
+			return ErrAuthentication
+		}
+	}
+	s.session = newSession(flags, exportedSessionKey, false)
+	return nil
+}
+
+// Session returns the session established by Authenticate, nil before
+func (s *Server) Session() *Session {
+	return s.session
+}
@@ -0,0 +1,150 @@
+package ntlm
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// SignatureLength is the length of the NTLMSSP_MESSAGE_SIGNATURE produced by Sign and Seal
+const SignatureLength = 16
+
+// ErrSignature is returned when a message signature does not verify
+var ErrSignature = errors.New("ntlm: invalid message signature")
+
+const (
+	clientSigning = "session key to client-to-server signing key magic constant\x00"
+	serverSigning = "session key to server-to-client signing key magic constant\x00"
+	clientSealing = "session key to client-to-server sealing key magic constant\x00"
+	serverSealing = "session key to server-to-client sealing key magic constant\x00"
+)
+
+// Session signs and seals the messages of an authenticated connection.
+// Outgoing and incoming messages each keep their own keys, cipher state and sequence number, so the
+// same Session is used for both directions of a connection-oriented transport.
+type Session struct {
+	flags uint32
+
+	outLock    sync.Mutex
+	signKey    []byte
+	sealHandle *rc4.Cipher
+	seq        uint32
+
+	inLock       sync.Mutex
+	verifyKey    []byte
+	unsealHandle *rc4.Cipher
+	peerSeq      uint32
+}
+
+func signKey(exportedSessionKey []byte, magic string) []byte {
+	h := md5.New()
+	h.Write(exportedSessionKey)
+	h.Write([]byte(magic))
+	return h.Sum(nil)
+}
+
+func sealKey(flags uint32, exportedSessionKey []byte, magic string) []byte {
+	key := exportedSessionKey
+	switch {
+	case flags&Negotiate128 != 0:
+	case flags&Negotiate56 != 0:
+		key = key[:7]
+	default:
+		key = key[:5]
+	}
+	return signKey(key, magic)
+}
+
+// newSession derives the signing and sealing keys from the exported session key, client selects the direction
+func newSession(flags uint32, exportedSessionKey []byte, client bool) *Session {
+	outSign, inSign, outSeal, inSeal := clientSigning, serverSigning, clientSealing, serverSealing
+	if !client {
+		outSign, inSign, outSeal, inSeal = serverSigning, clientSigning, serverSealing, clientSealing
+	}
+	s := &Session{
+		flags:     flags,
+		signKey:   signKey(exportedSessionKey, outSign),
+		verifyKey: signKey(exportedSessionKey, inSign),
+	}
+	s.sealHandle, _ = rc4.NewCipher(sealKey(flags, exportedSessionKey, outSeal))
+	s.unsealHandle, _ = rc4.NewCipher(sealKey(flags, exportedSessionKey, inSeal))
+	return s
+}
+
+// Flags returns the negotiated flags
+func (s *Session) Flags() uint32 {
+	return s.flags
+}
+
+// checksum computes the signature of message with key and seq, encrypting the checksum with handle
+func (s *Session) checksum(key []byte, handle *rc4.Cipher, seq uint32, message []byte) []byte {
+	var seqBytes [4]byte
+	binary.LittleEndian.PutUint32(seqBytes[:], seq)
+	mac := hmacMD5(key, seqBytes[:], message)[:8]
+	if s.flags&NegotiateKeyExch != 0 {
+		handle.XORKeyStream(mac, mac)
+	}
+	sig := make([]byte, SignatureLength)
+	binary.LittleEndian.PutUint32(sig, 1)
+	copy(sig[4:], mac)
+	copy(sig[12:], seqBytes[:])
+	return sig
+}
+
+// Sign returns the signature of an outgoing message
+func (s *Session) Sign(message []byte) []byte {
+	s.outLock.Lock()
+	defer s.outLock.Unlock()
+	sig := s.checksum(s.signKey, s.sealHandle, s.seq, message)
+	s.seq++
+	return sig
+}
+
+// Verify checks the signature of an incoming message
+func (s *Session) Verify(message, sig []byte) error {
+	s.inLock.Lock()
+	defer s.inLock.Unlock()
+	expected := s.checksum(s.verifyKey, s.unsealHandle, s.peerSeq, message)
+	s.peerSeq++
+	if !hmac.Equal(expected, sig) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// Seal encrypts data in place and returns the signature of message.
+// message is signed before the encryption, data is usually a part of message such as the stub of a PDU whose
+// header is signed but not encrypted.
+func (s *Session) Seal(data, message []byte) []byte {
+	s.outLock.Lock()
+	defer s.outLock.Unlock()
+	var seqBytes [4]byte
+	binary.LittleEndian.PutUint32(seqBytes[:], s.seq)
+	mac := hmacMD5(s.signKey, seqBytes[:], message)[:8]
+	s.sealHandle.XORKeyStream(data, data)
+	if s.flags&NegotiateKeyExch != 0 {
+		s.sealHandle.XORKeyStream(mac, mac)
+	}
+	s.seq++
+	sig := make([]byte, SignatureLength)
+	binary.LittleEndian.PutUint32(sig, 1)
+	copy(sig[4:], mac)
+	copy(sig[12:], seqBytes[:])
+	return sig
+}
+
+// Unseal decrypts data in place and checks the signature of message, data is usually a part of message
+func (s *Session) Unseal(data, message, sig []byte) error {
+	s.inLock.Lock()
+	defer s.inLock.Unlock()
+	s.unsealHandle.XORKeyStream(data, data)
+	expected := s.checksum(s.verifyKey, s.unsealHandle, s.peerSeq, message)
+	s.peerSeq++
+	if !hmac.Equal(expected, sig) {
+		return ErrSignature
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package dcom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// OBJREF flags
+const (
+	ObjRefStandard = 0x1
+	ObjRefHandler  = 0x2
+	ObjRefCustom   = 0x4
+	ObjRefExtended = 0x8
+)
+
+// SorfNoPing marks a STDOBJREF whose object does not need to be pinged
+const SorfNoPing = 0x1000
+
+// TowerTCP is the tower ID of ncacn_ip_tcp string bindings
+const TowerTCP = 0x0007
+
+const objRefSignature = 0x574f454d
+
+// StdObjRef is STDOBJREF
+type StdObjRef struct {
+	Flags      uint32
+	PublicRefs uint32
+	OXID       uint64
+	OID        uint64
+	IPID       com.GUID
+}
+
+// StringBinding is an entry of the string binding part of DUALSTRINGARRAY
+type StringBinding struct {
+	TowerID     uint16
+	NetworkAddr string
+}
+
+// SecurityBinding is an entry of the security binding part of DUALSTRINGARRAY
+type SecurityBinding struct {
+	AuthnSvc      uint16
+	AuthzSvc      uint16
+	PrincipalName string
+}
+
+// DualStringArray is DUALSTRINGARRAY, the bindings of an object exporter
+type DualStringArray struct {
+	StringBindings   []StringBinding
+	SecurityBindings []SecurityBinding
+}
+
+// TCPEndpoints returns the host and port of every ncacn_ip_tcp binding
+func (d *DualStringArray) TCPEndpoints() []Endpoint {
+	var endpoints []Endpoint
+	for _, b := range d.StringBindings {
+		if b.TowerID != TowerTCP {
+			continue
+		}
+		host, port, ok := splitNetworkAddr(b.NetworkAddr)
+		if ok {
+			endpoints = append(endpoints, Endpoint{Host: host, Port: port})
+		}
+	}
+	return endpoints
+}
+
+// Endpoint is an ncacn_ip_tcp address
+type Endpoint struct {
+	Host string
+	Port int
+}
+
+// splitNetworkAddr splits "host[port]"
+func splitNetworkAddr(addr string) (string, int, bool) {
+	open := strings.IndexByte(addr, '[')
+	if open < 0 || !strings.HasSuffix(addr, "]") {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(addr[open+1 : len(addr)-1])
+	if err != nil {
+		return "", 0, false
+	}
+	return addr[:open], port, true
+}
+
+func appendWideZ(b []byte, s string) []byte {
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return binary.LittleEndian.AppendUint16(b, 0)
+}
+
+// entries returns the aStringArray entries and the security offset
+func (d *DualStringArray) entries() ([]uint16, uint16) {
+	var raw []byte
+	for _, b := range d.StringBindings {
+		raw = binary.LittleEndian.AppendUint16(raw, b.TowerID)
+		raw = appendWideZ(raw, b.NetworkAddr)
+	}
+	if len(d.StringBindings) == 0 {
+		raw = binary.LittleEndian.AppendUint16(raw, 0)
+	}
+	raw = binary.LittleEndian.AppendUint16(raw, 0)
+	securityOffset := uint16(len(raw) / 2)
+	for _, b := range d.SecurityBindings {
+		raw = binary.LittleEndian.AppendUint16(raw, b.AuthnSvc)
+		raw = binary.LittleEndian.AppendUint16(raw, b.AuthzSvc)
+		raw = appendWideZ(raw, b.PrincipalName)
+	}
+	if len(d.SecurityBindings) == 0 {
+		raw = binary.LittleEndian.AppendUint16(raw, 0)
+	}
+	raw = binary.LittleEndian.AppendUint16(raw, 0)
+	entries := make([]uint16, len(raw)/2)
+	for i := range entries {
+		entries[i] = binary.LittleEndian.Uint16(raw[i*2:])
+	}
+	return entries, securityOffset
+}
+
+func (d *DualStringArray) parseEntries(entries []uint16, securityOffset int) error {
+	if securityOffset > len(entries) {
+		return errors.New("dcom: DUALSTRINGARRAY security offset out of range")
+	}
+	readZ := func(a []uint16, i int) (string, int) {
+		start := i
+		for i < len(a) && a[i] != 0 {
+			i++
+		}
+		return string(utf16.Decode(a[start:i])), i + 1
+	}
+	strs := entries[:securityOffset]
+	for i := 0; i < len(strs) && strs[i] != 0; {
+		tower := strs[i]
+		addr, next := readZ(strs, i+1)
+		d.StringBindings = append(d.StringBindings, StringBinding{TowerID: tower, NetworkAddr: addr})
+		i = next
+	}
+	sec := entries[securityOffset:]
+	for i := 0; i+1 < len(sec) && sec[i] != 0; {
+		authn, authz := sec[i], sec[i+1]
+		name, next := readZ(sec, i+2)
+		d.SecurityBindings = append(d.SecurityBindings, SecurityBinding{AuthnSvc: authn, AuthzSvc: authz, PrincipalName: name})
+		i = next
+	}
+	return nil
+}
+
+// appendRaw appends the DUALSTRINGARRAY as embedded in an OBJREF, without NDR conformance
+func (d *DualStringArray) appendRaw(b []byte) []byte {
+	entries, securityOffset := d.entries()
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(entries)))
+	b = binary.LittleEndian.AppendUint16(b, securityOffset)
+	for _, e := range entries {
+		b = binary.LittleEndian.AppendUint16(b, e)
+	}
+	return b
+}
+
+// WriteNDR writes the DUALSTRINGARRAY as an NDR conformant structure
+func (d *DualStringArray) WriteNDR(w *ndr.Writer) {
+	entries, securityOffset := d.entries()
+	w.WriteUint32(uint32(len(entries)))
+	w.WriteUint16(uint16(len(entries)))
+	w.WriteUint16(securityOffset)
+	for _, e := range entries {
+		w.WriteUint16(e)
+	}
+}
+
+// ReadDualStringArray reads a DUALSTRINGARRAY written as an NDR conformant structure
+func ReadDualStringArray(r *ndr.Reader) *DualStringArray {
+	r.ReadCount(2)
+	n := int(r.ReadUint16())
+	securityOffset := int(r.ReadUint16())
+	if n*2 > r.Remaining() {
+		r.SetErr(ndr.ErrShortBuffer)
+		return &DualStringArray{}
+	}
+	entries := make([]uint16, n)
+	for i := range entries {
+		entries[i] = r.ReadUint16()
+	}
+	d := &DualStringArray{}
+	if r.Err() == nil {
+		if err := d.parseEntries(entries, securityOffset); err != nil {
+			r.SetErr(err)
+		}
+	}
+	return d
+}
+
+// ObjRef is an OBJREF, the marshalled form of an interface pointer
+type ObjRef struct {
+	Flags uint32
+	IID   com.GUID
+	// Std and ResolverAddr are set for standard, handler and extended references
+	Std          StdObjRef
+	ResolverAddr DualStringArray
+	// CLSID is set for handler and custom references
+	CLSID com.GUID
+	// Extension and Data are set for custom references
+	Extension []byte
+	Data      []byte
+}
+
+// Marshal returns the wire form of a standard or custom OBJREF
+func (o *ObjRef) Marshal() ([]byte, error) {
+	b := binary.LittleEndian.AppendUint32(nil, objRefSignature)
+	b = binary.LittleEndian.AppendUint32(b, o.Flags)
+	b = appendGUID(b, &o.IID)
+	switch o.Flags {
+	case ObjRefStandard:
+		b = binary.LittleEndian.AppendUint32(b, o.Std.Flags)
+		b = binary.LittleEndian.AppendUint32(b, o.Std.PublicRefs)
+		b = binary.LittleEndian.AppendUint64(b, o.Std.OXID)
+		b = binary.LittleEndian.AppendUint64(b, o.Std.OID)
+		b = appendGUID(b, &o.Std.IPID)
+		b = o.ResolverAddr.appendRaw(b)
+	case ObjRefCustom:
+		b = appendGUID(b, &o.CLSID)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(o.Extension)))
+		// reserved, Windows fills it with the size of the object data plus 8
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(o.Data)+8))
+		b = append(b, o.Extension...)
+		b = append(b, o.Data...)
+	default:
+		return nil, fmt.Errorf("dcom: cannot marshal OBJREF with flags 0x%x", o.Flags)
+	}
+	return b, nil
+}
+
+// UnmarshalObjRef parses the wire form of an OBJREF
+func UnmarshalObjRef(b []byte) (*ObjRef, error) {
+	if len(b) < 24 {
+		return nil, errors.New("dcom: short OBJREF")
+	}
+	if binary.LittleEndian.Uint32(b) != objRefSignature {
+		return nil, errors.New("dcom: invalid OBJREF signature")
+	}
+	o := &ObjRef{Flags: binary.LittleEndian.Uint32(b[4:]), IID: readGUID(b[8:])}
+	b = b[24:]
+	switch o.Flags {
+	case ObjRefStandard, ObjRefHandler, ObjRefExtended:
+		if len(b) < 40 {
+			return nil, errors.New("dcom: short STDOBJREF")
+		}
+		o.Std = StdObjRef{
+			Flags:      binary.LittleEndian.Uint32(b),
+			PublicRefs: binary.LittleEndian.Uint32(b[4:]),
+			OXID:       binary.LittleEndian.Uint64(b[8:]),
+			OID:        binary.LittleEndian.Uint64(b[16:]),
+			IPID:       readGUID(b[24:]),
+		}
+		b = b[40:]
+		if o.Flags == ObjRefHandler {
+			if len(b) < 16 {
+				return nil, errors.New("dcom: short OBJREF_HANDLER")
+			}
+			o.CLSID = readGUID(b)
+			b = b[16:]
+		}
+		if o.Flags == ObjRefExtended {
+			// the signature of the extended reference and its element array are not needed
+			if len(b) < 8 {
+				return nil, errors.New("dcom: short OBJREF_EXTENDED")
+			}
+			b = b[8:]
+		}
+		if len(b) < 4 {
+			return nil, errors.New("dcom: short DUALSTRINGARRAY")
+		}
+		n := int(binary.LittleEndian.Uint16(b))
+		securityOffset := int(binary.LittleEndian.Uint16(b[2:]))
+		b = b[4:]
+		if len(b) < n*2 {
+			return nil, errors.New("dcom: short DUALSTRINGARRAY")
+		}
+		entries := make([]uint16, n)
+		for i := range entries {
+			entries[i] = binary.LittleEndian.Uint16(b[i*2:])
+		}
+		if err := o.ResolverAddr.parseEntries(entries, securityOffset); err != nil {
+			return nil, err
+		}
+	case ObjRefCustom:
+		if len(b) < 24 {
+			return nil, errors.New("dcom: short OBJREF_CUSTOM")
+		}
+		o.CLSID = readGUID(b)
+		extension := int(binary.LittleEndian.Uint32(b[16:]))
+		b = b[24:]
+		if extension > len(b) {
+			return nil, errors.New("dcom: short OBJREF_CUSTOM extension")
+		}
+		o.Extension = b[:extension]
+		o.Data = b[extension:]
+	default:
+		return nil, fmt.Errorf("dcom: unsupported OBJREF flags 0x%x", o.Flags)
+	}
+	return o, nil
+}
+
+// WriteInterfacePointer writes a [unique] MInterfacePointer holding objRef, nil is written as NULL
+func WriteInterfacePointer(w *ndr.Writer, objRef []byte) {
+	if w.WritePointer(objRef != nil) {
+		writeInterfacePointerBody(w, objRef)
+	}
+}
+
+func writeInterfacePointerBody(w *ndr.Writer, objRef []byte) {
+	w.WriteUint32(uint32(len(objRef)))
+	w.WriteUint32(uint32(len(objRef)))
+	w.WriteBytes(objRef)
+}
+
+// ReadInterfacePointer reads a [unique] MInterfacePointer and returns the OBJREF bytes, nil for NULL
+func ReadInterfacePointer(r *ndr.Reader) []byte {
+	if !r.ReadPointer() {
+		return nil
+	}
+	return readInterfacePointerBody(r)
+}
+
+func readInterfacePointerBody(r *ndr.Reader) []byte {
+	r.ReadCount(1)
+	n := r.ReadCount(1)
+	return r.ReadBytes(n)
+}
+
+func appendGUID(b []byte, g *com.GUID) []byte {
+	b = binary.LittleEndian.AppendUint32(b, g.Data1)
+	b = binary.LittleEndian.AppendUint16(b, g.Data2)
+	b = binary.LittleEndian.AppendUint16(b, g.Data3)
+	return append(b, g.Data4[:]...)
+}
+
+func readGUID(b []byte) com.GUID {
+	var g com.GUID
+	g.Data1 = binary.LittleEndian.Uint32(b)
+	g.Data2 = binary.LittleEndian.Uint16(b[4:])
+	g.Data3 = binary.LittleEndian.Uint16(b[6:])
+	copy(g.Data4[:], b[8:16])
+	return g
+}
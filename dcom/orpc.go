@@ -0,0 +1,143 @@
+package dcom
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// COMVersion is COMVERSION
+type COMVersion struct {
+	Major uint16
+	Minor uint16
+}
+
+// ClientVersion is the protocol version sent in ORPCTHIS
+var ClientVersion = COMVersion{Major: 5, Minor: 7}
+
+// HRESULT is a failed COM status code returned by the server
+type HRESULT uint32
+
+func (h HRESULT) Error() string {
+	if name, ok := hresultNames[uint32(h)]; ok {
+		return fmt.Sprintf("HRESULT 0x%08X: %s", uint32(h), name)
+	}
+	return fmt.Sprintf("HRESULT 0x%08X", uint32(h))
+}
+
+var hresultNames = map[uint32]string{
+	0x80004001: "not implemented",
+	0x80004002: "no such interface supported",
+	0x80004005: "unspecified error",
+	0x80070005: "access denied",
+	0x80070057: "invalid argument",
+	0x8007000E: "out of memory",
+	0x80040154: "class not registered",
+	0x800401F3: "invalid class string",
+	0x80010108: "the object invoked has disconnected from its clients",
+	0x80070776: "the object exporter specified was not found",
+}
+
+// Well known HRESULT values
+const (
+	ENotImpl     HRESULT = 0x80004001
+	ENoInterface HRESULT = 0x80004002
+	EFail        HRESULT = 0x80004005
+	EInvalidArg  HRESULT = 0x80070057
+	EClassNotReg HRESULT = 0x80040154
+	EDisconnect  HRESULT = 0x80010108
+)
+
+// checkHRESULT converts a failed status code into an error
+func checkHRESULT(hr uint32) error {
+	if int32(hr) < 0 {
+		return HRESULT(hr)
+	}
+	return nil
+}
+
+// NewCID returns a random causality ID
+func NewCID() com.GUID {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return readGUID(b[:])
+}
+
+// WriteORPCThis writes ORPCTHIS without extensions
+func WriteORPCThis(w *ndr.Writer, cid com.GUID) {
+	w.WriteUint16(ClientVersion.Major)
+	w.WriteUint16(ClientVersion.Minor)
+	w.WriteUint32(0)
+	w.WriteUint32(0)
+	w.WriteGUID(&cid)
+	w.WritePointer(false)
+}
+
+// ReadORPCThis reads ORPCTHIS and skips its extensions
+func ReadORPCThis(r *ndr.Reader) (version COMVersion, cid com.GUID) {
+	version.Major = r.ReadUint16()
+	version.Minor = r.ReadUint16()
+	r.ReadUint32()
+	r.ReadUint32()
+	cid = r.ReadGUID()
+	readExtentArray(r)
+	return
+}
+
+// WriteORPCThat writes ORPCTHAT without extensions
+func WriteORPCThat(w *ndr.Writer) {
+	w.WriteUint32(0)
+	w.WritePointer(false)
+}
+
+// ReadORPCThat reads ORPCTHAT and skips its extensions
+func ReadORPCThat(r *ndr.Reader) {
+	r.ReadUint32()
+	readExtentArray(r)
+}
+
+// readExtentArray skips a [unique] ORPC_EXTENT_ARRAY
+func readExtentArray(r *ndr.Reader) {
+	if !r.ReadPointer() {
+		return
+	}
+	r.ReadUint32()
+	r.ReadUint32()
+	if !r.ReadPointer() {
+		return
+	}
+	n := r.ReadCount(4)
+	present := make([]bool, n)
+	for i := range present {
+		present[i] = r.ReadPointer()
+	}
+	for _, p := range present {
+		if !p {
+			continue
+		}
+		r.ReadCount(1)
+		r.ReadGUID()
+		size := r.ReadUint32()
+		r.ReadBytes(int((size + 7) &^ 7))
+	}
+}
+
+// readHRESULTArray reads a [out, size_is(,n)] HRESULT** array, NULL is returned as nil
+func readHRESULTArray(r *ndr.Reader) []int32 {
+	if !r.ReadPointer() {
+		return nil
+	}
+	n := r.ReadCount(4)
+	values := make([]int32, n)
+	for i := range values {
+		values[i] = r.ReadInt32()
+	}
+	return values
+}
+
+// writeBOOL writes a Win32 BOOL
+func writeBOOL(w *ndr.Writer, b bool) {
+	w.WriteInt32(com.BoolToComBOOL(b))
+}
@@ -0,0 +1,156 @@
+package dcom
+
+import (
+	"sync"
+	"time"
+
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// pinger keeps the referenced objects alive with IObjectExporter::ComplexPing and SimplePing
+type pinger struct {
+	client   *Client
+	lock     sync.Mutex
+	refs     map[uint64]int
+	added    map[uint64]bool
+	removed  map[uint64]bool
+	setID    uint64
+	sequence uint16
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newPinger(client *Client, interval time.Duration) *pinger {
+	p := &pinger{
+		client:  client,
+		refs:    make(map[uint64]int),
+		added:   make(map[uint64]bool),
+		removed: make(map[uint64]bool),
+		done:    make(chan struct{}),
+	}
+	p.wg.Add(1)
+	go p.loop(interval)
+	return p
+}
+
+func (p *pinger) add(oid uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.refs[oid]++
+	if p.refs[oid] == 1 {
+		if p.removed[oid] {
+			delete(p.removed, oid)
+		} else {
+			p.added[oid] = true
+		}
+	}
+}
+
+func (p *pinger) remove(oid uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.refs[oid] == 0 {
+		return
+	}
+	p.refs[oid]--
+	if p.refs[oid] == 0 {
+		delete(p.refs, oid)
+		if p.added[oid] {
+			delete(p.added, oid)
+		} else {
+			p.removed[oid] = true
+		}
+	}
+}
+
+func (p *pinger) stop() {
+	close(p.done)
+	p.wg.Wait()
+}
+
+func (p *pinger) loop(interval time.Duration) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			// a failed ping is retried on the next tick, the server only drops objects after three missed pings
+			_ = p.ping()
+		}
+	}
+}
+
+// ping sends a ComplexPing when the set changed and a SimplePing otherwise
+func (p *pinger) ping() error {
+	p.lock.Lock()
+	added := keys(p.added)
+	removed := keys(p.removed)
+	setID := p.setID
+	empty := len(p.refs) == 0
+	p.lock.Unlock()
+	if setID == 0 && empty {
+		return nil
+	}
+	if setID != 0 && len(added) == 0 && len(removed) == 0 {
+		w := ndr.NewWriter()
+		w.WriteUint64(setID)
+		resp, err := p.client.resolverCall(IID_IObjectExporter, opSimplePing, w.Bytes())
+		if err != nil {
+			return err
+		}
+		return checkTrailingStatus(resp)
+	}
+	p.lock.Lock()
+	p.sequence++
+	sequence := p.sequence
+	p.lock.Unlock()
+	w := ndr.NewWriter()
+	w.WriteUint64(setID)
+	w.WriteUint16(sequence)
+	w.WriteUint16(uint16(len(added)))
+	w.WriteUint16(uint16(len(removed)))
+	writeOIDs(w, added)
+	writeOIDs(w, removed)
+	resp, err := p.client.resolverCall(IID_IObjectExporter, opComplexPing, w.Bytes())
+	if err != nil {
+		return err
+	}
+	if err = checkTrailingStatus(resp); err != nil {
+		return err
+	}
+	r := ndr.NewReader(resp)
+	newSetID := r.ReadUint64()
+	if r.Err() != nil {
+		return r.Err()
+	}
+	p.lock.Lock()
+	p.setID = newSetID
+	for _, oid := range added {
+		delete(p.added, oid)
+	}
+	for _, oid := range removed {
+		delete(p.removed, oid)
+	}
+	p.lock.Unlock()
+	return nil
+}
+
+func writeOIDs(w *ndr.Writer, oids []uint64) {
+	if w.WritePointer(len(oids) > 0) {
+		w.WriteUint32(uint32(len(oids)))
+		for _, oid := range oids {
+			w.WriteUint64(oid)
+		}
+	}
+}
+
+func keys(m map[uint64]bool) []uint64 {
+	result := make([]uint64, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
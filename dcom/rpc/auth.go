@@ -0,0 +1,119 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ntlm"
+)
+
+// AuthnWinNT is the NTLM authentication service, RPC_C_AUTHN_WINNT
+const AuthnWinNT = 10
+
+// FaultAccessDenied is the status sent when a call is not authenticated
+const FaultAccessDenied = 0x00000005
+
+const (
+	secTrailerLength = 8
+	// authPadAlignment is the alignment of the stub in authenticated PDUs, Windows pads to 16 bytes
+	authPadAlignment = 16
+	// authContextID is the only security context of a connection
+	authContextID = 0
+)
+
+// ErrAuthentication is returned when the peer does not answer the authentication handshake
+var ErrAuthentication = errors.New("rpc: authentication failed")
+
+// security holds the negotiated security context of a connection
+type security struct {
+	level   uint8
+	session *ntlm.Session
+}
+
+func secTrailer(level uint8, pad int) []byte {
+	b := []byte{AuthnWinNT, level, byte(pad), 0}
+	return binary.LittleEndian.AppendUint32(b, authContextID)
+}
+
+// parseSecTrailer returns the level, pad length and auth value of an authentication trailer
+func parseSecTrailer(auth []byte) (level uint8, pad int, value []byte, err error) {
+	if len(auth) < secTrailerLength {
+		return 0, 0, nil, errors.New("rpc: short security trailer")
+	}
+	if auth[0] != AuthnWinNT {
+		return 0, 0, nil, fmt.Errorf("rpc: unsupported authentication service %d", auth[0])
+	}
+	return auth[1], int(auth[2]), auth[secTrailerLength:], nil
+}
+
+// perPacket reports whether every PDU carries a verifier, levels below pkt only authenticate the connection
+func (s *security) perPacket() bool {
+	return s != nil && uint32(s.level) >= com.RPC_C_AUTHN_LEVEL_PKT
+}
+
+// maxStub returns the largest stub fragment fitting in maxXmit after overhead bytes of headers,
+// authenticated fragments keep room for the verifier and stay aligned so that only the last one is padded
+func (s *security) maxStub(maxXmit, overhead int) int {
+	if !s.perPacket() {
+		return maxXmit - overhead
+	}
+	return (maxXmit - overhead - secTrailerLength - ntlm.SignatureLength) &^ (authPadAlignment - 1)
+}
+
+// marshal returns the wire form of p, signing or sealing the stub starting at stubOffset in the body
+func (s *security) marshal(p *PDU, stubOffset int) []byte {
+	if !s.perPacket() {
+		return p.Marshal()
+	}
+	pad := (authPadAlignment - (len(p.Body)-stubOffset)%authPadAlignment) % authPadAlignment
+	p.Body = append(p.Body, make([]byte, pad)...)
+	p.Auth = append(secTrailer(s.level, pad), make([]byte, ntlm.SignatureLength)...)
+	b := p.Marshal()
+	sigStart := len(b) - ntlm.SignatureLength
+	var sig []byte
+	if uint32(s.level) == com.RPC_C_AUTHN_LEVEL_PKT_PRIVACY {
+		sig = s.session.Seal(b[headerLength+stubOffset:headerLength+len(p.Body)], b[:sigStart])
+	} else {
+		sig = s.session.Sign(b[:sigStart])
+	}
+	copy(b[sigStart:], sig)
+	return b
+}
+
+// unmarshal checks the verifier of p, decrypts the stub starting at stubOffset in the body and strips the padding
+func (s *security) unmarshal(p *PDU, stubOffset int) error {
+	if !s.perPacket() {
+		return nil
+	}
+	_, pad, sig, err := parseSecTrailer(p.Auth)
+	if err != nil {
+		return err
+	}
+	if len(sig) != ntlm.SignatureLength || pad > len(p.Body)-stubOffset {
+		return errors.New("rpc: invalid verifier")
+	}
+	sigStart := len(p.raw) - ntlm.SignatureLength
+	if uint32(s.level) == com.RPC_C_AUTHN_LEVEL_PKT_PRIVACY {
+		err = s.session.Unseal(p.raw[headerLength+stubOffset:headerLength+len(p.Body)], p.raw[:sigStart], sig)
+	} else {
+		err = s.session.Verify(p.raw[:sigStart], sig)
+	}
+	if err != nil {
+		return err
+	}
+	p.Body = p.Body[:len(p.Body)-pad]
+	return nil
+}
+
+// requestStubOffset is the offset of the stub in the body of a request PDU
+func requestStubOffset(p *PDU) int {
+	if p.Flags&PfcObjectUUID != 0 {
+		return 24
+	}
+	return 8
+}
+
+// responseStubOffset is the offset of the stub in the body of a response PDU
+const responseStubOffset = 8
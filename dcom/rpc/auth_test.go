@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ntlm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testCredentials = &ntlm.Credentials{Domain: "WORKGROUP", User: "opc", Password: "secret", Workstation: "SERVER"}
+
+// recordConn keeps every byte written by the client
+type recordConn struct {
+	net.Conn
+	lock    sync.Mutex
+	written bytes.Buffer
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	c.written.Write(b)
+	c.lock.Unlock()
+	return c.Conn.Write(b)
+}
+
+func newAuthTestConn(t *testing.T, creds *ntlm.Credentials, level uint32, handler Handler) (*Conn, *recordConn) {
+	client, server := net.Pipe()
+	go func() {
+		_ = ServeAuthConn(server, testCredentials, nil, handler)
+		server.Close()
+	}()
+	record := &recordConn{Conn: client}
+	conn := NewAuthConn(record, creds, level)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn, record
+}
+
+func TestAuthenticatedCall(t *testing.T) {
+	for _, level := range []uint32{com.RPC_C_AUTHN_LEVEL_CONNECT, com.RPC_C_AUTHN_LEVEL_CALL, com.RPC_C_AUTHN_LEVEL_PKT_INTEGRITY, com.RPC_C_AUTHN_LEVEL_PKT_PRIVACY} {
+		conn, record := newAuthTestConn(t, testCredentials, level, func(req *Request) ([]byte, error) {
+			resp := append([]byte(nil), req.Stub...)
+			return append(resp, resp...), nil
+		})
+		id, err := conn.Bind(testInterface)
+		require.NoError(t, err)
+		stub := bytes.Repeat([]byte("opc data access "), defaultFragmentSize/4)
+		for _, object := range []*com.GUID{nil, &testInterface.UUID} {
+			resp, err := conn.Call(id, 3, object, stub[:len(stub)-3])
+			require.NoError(t, err, level)
+			assert.Equal(t, append(append([]byte(nil), stub[:len(stub)-3]...), stub[:len(stub)-3]...), resp, level)
+		}
+		assert.Equal(t, level == com.RPC_C_AUTHN_LEVEL_PKT_PRIVACY, !bytes.Contains(record.written.Bytes(), []byte("opc data access")), level)
+	}
+}
+
+func TestAuthenticationFailed(t *testing.T) {
+	creds := *testCredentials
+	creds.Password = "wrong"
+	conn, _ := newAuthTestConn(t, &creds, com.RPC_C_AUTHN_LEVEL_PKT_PRIVACY, func(req *Request) ([]byte, error) {
+		return nil, nil
+	})
+	id, err := conn.Bind(testInterface)
+	require.NoError(t, err)
+	_, err = conn.Call(id, 3, nil, []byte{1, 2, 3})
+	assert.Equal(t, &FaultError{Status: FaultAccessDenied}, err)
+}
+
+func TestUnauthenticatedCallDenied(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		_ = ServeAuthConn(server, testCredentials, nil, func(req *Request) ([]byte, error) {
+			return nil, nil
+		})
+		server.Close()
+	}()
+	conn := NewConn(client)
+	defer conn.Close()
+	id, err := conn.Bind(testInterface)
+	require.NoError(t, err)
+	_, err = conn.Call(id, 3, nil, nil)
+	assert.Equal(t, &FaultError{Status: FaultAccessDenied}, err)
+}
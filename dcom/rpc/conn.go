@@ -0,0 +1,281 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ntlm"
+)
+
+// FaultError is returned when the server answers a call with a fault PDU
+type FaultError struct {
+	Status uint32
+}
+
+func (e *FaultError) Error() string {
+	if name, ok := faultNames[e.Status]; ok {
+		return fmt.Sprintf("rpc: fault 0x%08x (%s)", e.Status, name)
+	}
+	return fmt.Sprintf("rpc: fault 0x%08x", e.Status)
+}
+
+var faultNames = map[uint32]string{
+	0x00000005: "access denied",
+	0x000006f7: "bad stub data",
+	0x1c010002: "operation out of range",
+	0x1c010003: "unknown interface",
+	0x1c00001c: "remote error",
+	0x80010113: "disconnected",
+}
+
+// BindError is returned when the server rejects a presentation context
+type BindError struct {
+	Result uint16
+	Reason uint16
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("rpc: presentation context rejected, result %d reason %d", e.Result, e.Reason)
+}
+
+// Conn is a client association over a connected transport, calls are serialized
+type Conn struct {
+	conn       net.Conn
+	lock       sync.Mutex
+	callID     uint32
+	maxXmit    uint16
+	maxRecv    uint16
+	assocGroup uint32
+	bound      bool
+	contexts   map[com.GUID]uint16
+	auth       *ntlm.Client
+	level      uint8
+	sec        *security
+}
+
+// NewConn wraps a connected transport
+func NewConn(conn net.Conn) *Conn {
+	return &Conn{
+		conn:     conn,
+		maxXmit:  defaultFragmentSize,
+		maxRecv:  defaultFragmentSize,
+		contexts: make(map[com.GUID]uint16),
+	}
+}
+
+// NewAuthConn wraps a connected transport authenticating with NTLM on the first bind.
+// level is one of the com.RPC_C_AUTHN_LEVEL_* values, levels below connect are raised to connect and call to
+// pkt as Windows does for connection-oriented protocols.
+func NewAuthConn(conn net.Conn, creds *ntlm.Credentials, level uint32) *Conn {
+	c := NewConn(conn)
+	c.auth = ntlm.NewClient(creds)
+	switch {
+	case level < com.RPC_C_AUTHN_LEVEL_CONNECT:
+		level = com.RPC_C_AUTHN_LEVEL_CONNECT
+	case level == com.RPC_C_AUTHN_LEVEL_CALL:
+		level = com.RPC_C_AUTHN_LEVEL_PKT
+	case level > com.RPC_C_AUTHN_LEVEL_PKT_PRIVACY:
+		level = com.RPC_C_AUTHN_LEVEL_PKT_PRIVACY
+	}
+	c.level = uint8(level)
+	return c
+}
+
+// RemoteAddr returns the address of the server
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close closes the transport
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Bind negotiates a presentation context for the interface with the NDR transfer syntax.
+// The first call sends bind, the following ones alter_context, already bound interfaces are reused.
+func (c *Conn) Bind(iface SyntaxID) (uint16, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if id, ok := c.contexts[iface.UUID]; ok {
+		return id, nil
+	}
+	id := uint16(len(c.contexts))
+	body := &BindBody{
+		MaxXmitFrag:  c.maxXmit,
+		MaxRecvFrag:  c.maxRecv,
+		AssocGroupID: c.assocGroup,
+		Contexts: []ContextElement{{
+			ContextID:      id,
+			AbstractSyntax: iface,
+			TransferSyntax: []SyntaxID{NDRSyntax},
+		}},
+	}
+	ptype := uint8(PtypeBind)
+	if c.bound {
+		ptype = PtypeAlterContext
+	}
+	c.callID++
+	pdu := &PDU{
+		Header: Header{Type: ptype, Flags: PfcFirstFrag | PfcLastFrag, CallID: c.callID},
+		Body:   body.Marshal(),
+	}
+	authenticate := c.auth != nil && !c.bound
+	if authenticate {
+		pdu.Auth = append(secTrailer(c.level, 0), c.auth.Negotiate()...)
+	}
+	if _, err := c.conn.Write(pdu.Marshal()); err != nil {
+		return 0, err
+	}
+	resp, err := ReadPDU(c.conn)
+	if err != nil {
+		return 0, err
+	}
+	switch resp.Type {
+	case PtypeBindAck, PtypeAlterContextResp:
+	case PtypeBindNak:
+		return 0, errors.New("rpc: bind rejected")
+	case PtypeFault:
+		fault, err := ParseResponseBody(resp.Body, true)
+		if err != nil {
+			return 0, err
+		}
+		return 0, &FaultError{Status: fault.Status}
+	default:
+		return 0, fmt.Errorf("rpc: unexpected PDU type %d in response to bind", resp.Type)
+	}
+	ack, err := ParseBindAckBody(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if len(ack.Results) != 1 {
+		return 0, fmt.Errorf("rpc: bind returned %d results", len(ack.Results))
+	}
+	if ack.Results[0].Result != ResultAcceptance {
+		return 0, &BindError{Result: ack.Results[0].Result, Reason: ack.Results[0].Reason}
+	}
+	if authenticate {
+		if err = c.authenticate(resp); err != nil {
+			return 0, err
+		}
+	}
+	if !c.bound {
+		c.bound = true
+		c.assocGroup = ack.AssocGroupID
+		if ack.MaxXmitFrag != 0 && ack.MaxXmitFrag < c.maxRecv {
+			c.maxRecv = ack.MaxXmitFrag
+		}
+		if ack.MaxRecvFrag != 0 && ack.MaxRecvFrag < c.maxXmit {
+			c.maxXmit = ack.MaxRecvFrag
+		}
+	}
+	c.contexts[iface.UUID] = id
+	return id, nil
+}
+
+// authenticate answers the challenge of bind_ack with auth3, the server does not reply to auth3
+func (c *Conn) authenticate(ack *PDU) error {
+	if ack.Auth == nil {
+		return ErrAuthentication
+	}
+	_, _, challenge, err := parseSecTrailer(ack.Auth)
+	if err != nil {
+		return err
+	}
+	msg, err := c.auth.Authenticate(challenge)
+	if err != nil {
+		return err
+	}
+	pdu := &PDU{
+		Header: Header{Type: PtypeAuth3, Flags: PfcFirstFrag | PfcLastFrag, CallID: c.callID},
+		Body:   make([]byte, 4),
+		Auth:   append(secTrailer(c.level, 0), msg...),
+	}
+	if _, err = c.conn.Write(pdu.Marshal()); err != nil {
+		return err
+	}
+	c.sec = &security{level: c.level, session: c.auth.Session()}
+	return nil
+}
+
+// Call invokes opnum on a bound presentation context and returns the response stub.
+// object is sent as the object UUID when not nil, DCOM uses it for the IPID.
+func (c *Conn) Call(contextID uint16, opnum uint16, object *com.GUID, stub []byte) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.callID++
+	callID := c.callID
+	overhead := headerLength + 8
+	if object != nil {
+		overhead += 16
+	}
+	maxStub := c.sec.maxStub(int(c.maxXmit), overhead)
+	remaining := stub
+	first := true
+	for first || len(remaining) > 0 {
+		n := len(remaining)
+		if n > maxStub {
+			n = maxStub
+		}
+		flags := uint8(0)
+		if first {
+			flags |= PfcFirstFrag
+		}
+		if n == len(remaining) {
+			flags |= PfcLastFrag
+		}
+		if object != nil {
+			flags |= PfcObjectUUID
+		}
+		req := &RequestBody{
+			AllocHint: uint32(len(remaining)),
+			ContextID: contextID,
+			Opnum:     opnum,
+			Object:    object,
+			Stub:      remaining[:n],
+		}
+		pdu := &PDU{
+			Header: Header{Type: PtypeRequest, Flags: flags, CallID: callID},
+			Body:   req.Marshal(),
+		}
+		if _, err := c.conn.Write(c.sec.marshal(pdu, requestStubOffset(pdu))); err != nil {
+			return nil, err
+		}
+		remaining = remaining[n:]
+		first = false
+	}
+	var result []byte
+	for {
+		pdu, err := ReadPDU(c.conn)
+		if err != nil {
+			return nil, err
+		}
+		if pdu.CallID != callID {
+			return nil, fmt.Errorf("rpc: response for call %d while waiting for %d", pdu.CallID, callID)
+		}
+		switch pdu.Type {
+		case PtypeResponse:
+			if err = c.sec.unmarshal(pdu, responseStubOffset); err != nil {
+				return nil, err
+			}
+			resp, err := ParseResponseBody(pdu.Body, false)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, resp.Stub...)
+		case PtypeFault:
+			fault, err := ParseResponseBody(pdu.Body, true)
+			if err != nil {
+				return nil, err
+			}
+			return nil, &FaultError{Status: fault.Status}
+		default:
+			return nil, fmt.Errorf("rpc: unexpected PDU type %d in response to request", pdu.Type)
+		}
+		if pdu.Flags&PfcLastFrag != 0 {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testInterface = SyntaxID{UUID: com.GUID{Data1: 0x12345678, Data2: 0x1234, Data3: 0xabcd, Data4: [8]byte{0xef, 0, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab}}, Version: 1}
+
+func TestBindPDU(t *testing.T) {
+	body := &BindBody{
+		MaxXmitFrag: 5840,
+		MaxRecvFrag: 5840,
+		Contexts:    []ContextElement{{AbstractSyntax: testInterface, TransferSyntax: []SyntaxID{NDRSyntax}}},
+	}
+	pdu := &PDU{Header: Header{Type: PtypeBind, Flags: PfcFirstFrag | PfcLastFrag, CallID: 1}, Body: body.Marshal()}
+	b := pdu.Marshal()
+	assert.Equal(t, []byte{5, 0, 11, 3, 0x10, 0, 0, 0, 72, 0, 0, 0, 1, 0, 0, 0}, b[:16])
+	assert.Equal(t, []byte{0xd0, 0x16, 0xd0, 0x16, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 1, 0}, b[16:32])
+	assert.Equal(t, []byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0xcd, 0xab}, b[32:40])
+	assert.Equal(t, []byte{0x04, 0x5d, 0x88, 0x8a, 0xeb, 0x1c, 0xc9, 0x11}, b[52:60])
+
+	read, err := ReadPDU(bytes.NewReader(b))
+	require.NoError(t, err)
+	parsed, err := ParseBindBody(read.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, parsed)
+}
+
+func TestBindAckPDU(t *testing.T) {
+	body := &BindAckBody{
+		MaxXmitFrag:   4280,
+		MaxRecvFrag:   4280,
+		AssocGroupID:  0x1234,
+		SecondaryAddr: "135",
+		Results:       []ContextResult{{Result: ResultAcceptance, TransferSyntax: NDRSyntax}},
+	}
+	parsed, err := ParseBindAckBody(body.Marshal())
+	require.NoError(t, err)
+	assert.Equal(t, body, parsed)
+}
+
+func newTestConn(t *testing.T, handler Handler) *Conn {
+	client, server := net.Pipe()
+	go func() {
+		_ = ServeConn(server, func(iface SyntaxID) bool {
+			return iface.UUID == testInterface.UUID
+		}, handler)
+		server.Close()
+	}()
+	conn := NewConn(client)
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn
+}
+
+func TestCallFragments(t *testing.T) {
+	conn := newTestConn(t, func(req *Request) ([]byte, error) {
+		resp := append([]byte(nil), req.Stub...)
+		return append(resp, resp...), nil
+	})
+	id, err := conn.Bind(testInterface)
+	require.NoError(t, err)
+	stub := make([]byte, 3*defaultFragmentSize+17)
+	for i := range stub {
+		stub[i] = byte(i)
+	}
+	resp, err := conn.Call(id, 3, nil, stub)
+	require.NoError(t, err)
+	assert.Equal(t, append(append([]byte(nil), stub...), stub...), resp)
+}
+
+func TestCallFault(t *testing.T) {
+	conn := newTestConn(t, func(req *Request) ([]byte, error) {
+		return nil, &FaultError{Status: 0x1c010002}
+	})
+	id, err := conn.Bind(testInterface)
+	require.NoError(t, err)
+	_, err = conn.Call(id, 9, nil, nil)
+	assert.Equal(t, &FaultError{Status: 0x1c010002}, err)
+}
+
+func TestBindRejected(t *testing.T) {
+	conn := newTestConn(t, func(req *Request) ([]byte, error) {
+		return nil, nil
+	})
+	_, err := conn.Bind(SyntaxID{UUID: NDRSyntax.UUID})
+	var bindErr *BindError
+	assert.ErrorAs(t, err, &bindErr)
+}
@@ -0,0 +1,415 @@
+// Package rpc implements the connection-oriented DCE-RPC protocol (ncacn_ip_tcp) used by DCOM.
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+// PDU types
+const (
+	PtypeRequest          = 0
+	PtypeResponse         = 2
+	PtypeFault            = 3
+	PtypeBind             = 11
+	PtypeBindAck          = 12
+	PtypeBindNak          = 13
+	PtypeAlterContext     = 14
+	PtypeAlterContextResp = 15
+	PtypeAuth3            = 16
+)
+
+// PDU flags
+const (
+	PfcFirstFrag  = 0x01
+	PfcLastFrag   = 0x02
+	PfcObjectUUID = 0x80
+)
+
+const (
+	headerLength         = 16
+	defaultFragmentSize  = 5840
+	dataRepresentationLE = 0x00000010
+	rpcVersion           = 5
+	rpcVersionMinor      = 0
+	// ResultAcceptance and ResultProviderRejection are the presentation context results of bind_ack
+	ResultAcceptance        = 0
+	ResultProviderRejection = 2
+)
+
+// SyntaxID identifies an interface or a transfer syntax
+type SyntaxID struct {
+	UUID    com.GUID
+	Version uint16
+	Minor   uint16
+}
+
+// NDRSyntax is the NDR20 transfer syntax 8a885d04-1ceb-11c9-9fe8-08002b104860 v2.0
+var NDRSyntax = SyntaxID{
+	UUID:    com.GUID{Data1: 0x8a885d04, Data2: 0x1ceb, Data3: 0x11c9, Data4: [8]byte{0x9f, 0xe8, 0x08, 0x00, 0x2b, 0x10, 0x48, 0x60}},
+	Version: 2,
+}
+
+// Header is the common header of every connection-oriented PDU
+type Header struct {
+	Type       uint8
+	Flags      uint8
+	FragLength uint16
+	AuthLength uint16
+	CallID     uint32
+}
+
+func (h *Header) marshal(b []byte) {
+	b[0] = rpcVersion
+	b[1] = rpcVersionMinor
+	b[2] = h.Type
+	b[3] = h.Flags
+	binary.LittleEndian.PutUint32(b[4:], dataRepresentationLE)
+	binary.LittleEndian.PutUint16(b[8:], h.FragLength)
+	binary.LittleEndian.PutUint16(b[10:], h.AuthLength)
+	binary.LittleEndian.PutUint32(b[12:], h.CallID)
+}
+
+func parseHeader(b []byte) (*Header, error) {
+	if b[0] != rpcVersion || b[1] != rpcVersionMinor {
+		return nil, fmt.Errorf("rpc: unsupported version %d.%d", b[0], b[1])
+	}
+	if b[4]&0xf0 != 0x10 {
+		return nil, errors.New("rpc: big-endian data representation is not supported")
+	}
+	h := &Header{
+		Type:       b[2],
+		Flags:      b[3],
+		FragLength: binary.LittleEndian.Uint16(b[8:]),
+		AuthLength: binary.LittleEndian.Uint16(b[10:]),
+		CallID:     binary.LittleEndian.Uint32(b[12:]),
+	}
+	if h.FragLength < headerLength {
+		return nil, fmt.Errorf("rpc: invalid fragment length %d", h.FragLength)
+	}
+	return h, nil
+}
+
+// PDU is a single fragment read from the wire
+type PDU struct {
+	Header
+	// Body is the PDU without the common header and without the authentication trailer
+	Body []byte
+	// Auth is the authentication verifier including the security trailer, nil when AuthLength is zero
+	Auth []byte
+	// raw is the fragment as read, Body and Auth point into it
+	raw []byte
+}
+
+// ReadPDU reads one fragment
+func ReadPDU(r io.Reader) (*PDU, error) {
+	head := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	h, err := parseHeader(head)
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]byte, h.FragLength)
+	copy(raw, head)
+	if _, err = io.ReadFull(r, raw[headerLength:]); err != nil {
+		return nil, err
+	}
+	body := raw[headerLength:]
+	p := &PDU{Header: *h, Body: body, raw: raw}
+	if h.AuthLength > 0 {
+		trailer := int(h.AuthLength) + 8
+		if trailer > len(body) {
+			return nil, fmt.Errorf("rpc: invalid auth length %d", h.AuthLength)
+		}
+		p.Body = body[:len(body)-trailer]
+		p.Auth = body[len(body)-trailer:]
+	}
+	return p, nil
+}
+
+// Marshal returns the wire representation of the PDU, FragLength and AuthLength are computed
+func (p *PDU) Marshal() []byte {
+	b := make([]byte, headerLength, headerLength+len(p.Body)+len(p.Auth))
+	b = append(b, p.Body...)
+	b = append(b, p.Auth...)
+	h := p.Header
+	h.FragLength = uint16(len(b))
+	if len(p.Auth) >= 8 {
+		h.AuthLength = uint16(len(p.Auth) - 8)
+	} else {
+		h.AuthLength = 0
+	}
+	h.marshal(b)
+	return b
+}
+
+// ContextElement is a presentation context offered in bind or alter_context
+type ContextElement struct {
+	ContextID      uint16
+	AbstractSyntax SyntaxID
+	TransferSyntax []SyntaxID
+}
+
+// BindBody is the body of bind and alter_context
+type BindBody struct {
+	MaxXmitFrag  uint16
+	MaxRecvFrag  uint16
+	AssocGroupID uint32
+	Contexts     []ContextElement
+}
+
+func putSyntax(b []byte, s *SyntaxID) []byte {
+	b = appendGUID(b, &s.UUID)
+	b = binary.LittleEndian.AppendUint16(b, s.Version)
+	b = binary.LittleEndian.AppendUint16(b, s.Minor)
+	return b
+}
+
+func readSyntax(b []byte) SyntaxID {
+	return SyntaxID{UUID: readGUID(b), Version: binary.LittleEndian.Uint16(b[16:]), Minor: binary.LittleEndian.Uint16(b[18:])}
+}
+
+func appendGUID(b []byte, g *com.GUID) []byte {
+	b = binary.LittleEndian.AppendUint32(b, g.Data1)
+	b = binary.LittleEndian.AppendUint16(b, g.Data2)
+	b = binary.LittleEndian.AppendUint16(b, g.Data3)
+	return append(b, g.Data4[:]...)
+}
+
+func readGUID(b []byte) com.GUID {
+	var g com.GUID
+	g.Data1 = binary.LittleEndian.Uint32(b)
+	g.Data2 = binary.LittleEndian.Uint16(b[4:])
+	g.Data3 = binary.LittleEndian.Uint16(b[6:])
+	copy(g.Data4[:], b[8:16])
+	return g
+}
+
+// Marshal returns the bind body
+func (b *BindBody) Marshal() []byte {
+	out := make([]byte, 0, 12+len(b.Contexts)*44)
+	out = binary.LittleEndian.AppendUint16(out, b.MaxXmitFrag)
+	out = binary.LittleEndian.AppendUint16(out, b.MaxRecvFrag)
+	out = binary.LittleEndian.AppendUint32(out, b.AssocGroupID)
+	out = append(out, byte(len(b.Contexts)), 0, 0, 0)
+	for i := range b.Contexts {
+		c := &b.Contexts[i]
+		out = binary.LittleEndian.AppendUint16(out, c.ContextID)
+		out = append(out, byte(len(c.TransferSyntax)), 0)
+		out = putSyntax(out, &c.AbstractSyntax)
+		for j := range c.TransferSyntax {
+			out = putSyntax(out, &c.TransferSyntax[j])
+		}
+	}
+	return out
+}
+
+// ParseBindBody parses the body of bind and alter_context
+func ParseBindBody(b []byte) (*BindBody, error) {
+	if len(b) < 12 {
+		return nil, errors.New("rpc: short bind")
+	}
+	body := &BindBody{
+		MaxXmitFrag:  binary.LittleEndian.Uint16(b),
+		MaxRecvFrag:  binary.LittleEndian.Uint16(b[2:]),
+		AssocGroupID: binary.LittleEndian.Uint32(b[4:]),
+	}
+	n := int(b[8])
+	off := 12
+	for i := 0; i < n; i++ {
+		if off+24 > len(b) {
+			return nil, errors.New("rpc: short bind context")
+		}
+		c := ContextElement{ContextID: binary.LittleEndian.Uint16(b[off:])}
+		count := int(b[off+2])
+		c.AbstractSyntax = readSyntax(b[off+4:])
+		off += 24
+		for j := 0; j < count; j++ {
+			if off+20 > len(b) {
+				return nil, errors.New("rpc: short transfer syntax")
+			}
+			c.TransferSyntax = append(c.TransferSyntax, readSyntax(b[off:]))
+			off += 20
+		}
+		body.Contexts = append(body.Contexts, c)
+	}
+	return body, nil
+}
+
+// ContextResult is the answer to one presentation context
+type ContextResult struct {
+	Result         uint16
+	Reason         uint16
+	TransferSyntax SyntaxID
+}
+
+// BindAckBody is the body of bind_ack and alter_context_resp
+type BindAckBody struct {
+	MaxXmitFrag   uint16
+	MaxRecvFrag   uint16
+	AssocGroupID  uint32
+	SecondaryAddr string
+	Results       []ContextResult
+}
+
+// Marshal returns the bind_ack body
+func (b *BindAckBody) Marshal() []byte {
+	out := make([]byte, 0, 64)
+	out = binary.LittleEndian.AppendUint16(out, b.MaxXmitFrag)
+	out = binary.LittleEndian.AppendUint16(out, b.MaxRecvFrag)
+	out = binary.LittleEndian.AppendUint32(out, b.AssocGroupID)
+	if b.SecondaryAddr == "" {
+		out = binary.LittleEndian.AppendUint16(out, 0)
+	} else {
+		out = binary.LittleEndian.AppendUint16(out, uint16(len(b.SecondaryAddr)+1))
+		out = append(out, b.SecondaryAddr...)
+		out = append(out, 0)
+	}
+	for (len(out)+headerLength)%4 != 0 {
+		out = append(out, 0)
+	}
+	out = append(out, byte(len(b.Results)), 0, 0, 0)
+	for i := range b.Results {
+		r := &b.Results[i]
+		out = binary.LittleEndian.AppendUint16(out, r.Result)
+		out = binary.LittleEndian.AppendUint16(out, r.Reason)
+		out = putSyntax(out, &r.TransferSyntax)
+	}
+	return out
+}
+
+// ParseBindAckBody parses the body of bind_ack and alter_context_resp
+func ParseBindAckBody(b []byte) (*BindAckBody, error) {
+	if len(b) < 10 {
+		return nil, errors.New("rpc: short bind_ack")
+	}
+	body := &BindAckBody{
+		MaxXmitFrag:  binary.LittleEndian.Uint16(b),
+		MaxRecvFrag:  binary.LittleEndian.Uint16(b[2:]),
+		AssocGroupID: binary.LittleEndian.Uint32(b[4:]),
+	}
+	n := int(binary.LittleEndian.Uint16(b[8:]))
+	off := 10
+	if off+n > len(b) {
+		return nil, errors.New("rpc: short secondary address")
+	}
+	if n > 0 {
+		body.SecondaryAddr = string(b[off : off+n-1])
+	}
+	off += n
+	for (off+headerLength)%4 != 0 {
+		off++
+	}
+	if off+4 > len(b) {
+		return nil, errors.New("rpc: short result list")
+	}
+	count := int(b[off])
+	off += 4
+	for i := 0; i < count; i++ {
+		if off+24 > len(b) {
+			return nil, errors.New("rpc: short result")
+		}
+		body.Results = append(body.Results, ContextResult{
+			Result:         binary.LittleEndian.Uint16(b[off:]),
+			Reason:         binary.LittleEndian.Uint16(b[off+2:]),
+			TransferSyntax: readSyntax(b[off+4:]),
+		})
+		off += 24
+	}
+	return body, nil
+}
+
+// RequestBody is the fixed part of a request PDU followed by its stub fragment
+type RequestBody struct {
+	AllocHint uint32
+	ContextID uint16
+	Opnum     uint16
+	Object    *com.GUID
+	Stub      []byte
+}
+
+// Marshal returns the request body
+func (r *RequestBody) Marshal() []byte {
+	out := make([]byte, 0, 24+len(r.Stub))
+	out = binary.LittleEndian.AppendUint32(out, r.AllocHint)
+	out = binary.LittleEndian.AppendUint16(out, r.ContextID)
+	out = binary.LittleEndian.AppendUint16(out, r.Opnum)
+	if r.Object != nil {
+		out = appendGUID(out, r.Object)
+	}
+	return append(out, r.Stub...)
+}
+
+// ParseRequestBody parses the body of a request PDU, hasObject is taken from PfcObjectUUID
+func ParseRequestBody(b []byte, hasObject bool) (*RequestBody, error) {
+	if len(b) < 8 {
+		return nil, errors.New("rpc: short request")
+	}
+	r := &RequestBody{
+		AllocHint: binary.LittleEndian.Uint32(b),
+		ContextID: binary.LittleEndian.Uint16(b[4:]),
+		Opnum:     binary.LittleEndian.Uint16(b[6:]),
+	}
+	b = b[8:]
+	if hasObject {
+		if len(b) < 16 {
+			return nil, errors.New("rpc: short object uuid")
+		}
+		g := readGUID(b)
+		r.Object = &g
+		b = b[16:]
+	}
+	r.Stub = b
+	return r, nil
+}
+
+// ResponseBody is the fixed part of a response or fault PDU followed by its stub fragment
+type ResponseBody struct {
+	AllocHint   uint32
+	ContextID   uint16
+	CancelCount uint8
+	// Status is only present in fault PDUs
+	Status uint32
+	Stub   []byte
+}
+
+// Marshal returns the response body, fault adds the status field
+func (r *ResponseBody) Marshal(fault bool) []byte {
+	out := make([]byte, 0, 16+len(r.Stub))
+	out = binary.LittleEndian.AppendUint32(out, r.AllocHint)
+	out = binary.LittleEndian.AppendUint16(out, r.ContextID)
+	out = append(out, r.CancelCount, 0)
+	if fault {
+		out = binary.LittleEndian.AppendUint32(out, r.Status)
+		out = append(out, 0, 0, 0, 0)
+	}
+	return append(out, r.Stub...)
+}
+
+// ParseResponseBody parses the body of a response or fault PDU
+func ParseResponseBody(b []byte, fault bool) (*ResponseBody, error) {
+	if len(b) < 8 || fault && len(b) < 12 {
+		return nil, errors.New("rpc: short response")
+	}
+	r := &ResponseBody{
+		AllocHint:   binary.LittleEndian.Uint32(b),
+		ContextID:   binary.LittleEndian.Uint16(b[4:]),
+		CancelCount: b[6],
+	}
+	b = b[8:]
+	if fault {
+		r.Status = binary.LittleEndian.Uint32(b)
+		b = b[4:]
+		if len(b) >= 4 {
+			b = b[4:]
+		}
+	}
+	r.Stub = b
+	return r, nil
+}
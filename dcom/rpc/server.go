@@ -0,0 +1,204 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ntlm"
+)
+
+// FaultRemoteError is the status sent when a handler fails with an error that is not a *FaultError
+const FaultRemoteError = 0x1c00001c
+
+// Request is a reassembled call received by ServeConn
+type Request struct {
+	Interface SyntaxID
+	Opnum     uint16
+	Object    *com.GUID
+	Stub      []byte
+}
+
+// Handler answers a request with the response stub, a *FaultError is sent as a fault PDU
+type Handler func(req *Request) ([]byte, error)
+
+// ServeConn answers bind, alter_context and request PDUs on conn until it fails or is closed.
+// accept decides which interfaces may be bound, nil accepts every interface.
+// It is the server side of Conn and is meant for stand-in servers in tests.
+func ServeConn(conn net.Conn, accept func(iface SyntaxID) bool, handler Handler) error {
+	return serveConn(conn, nil, accept, handler)
+}
+
+// ServeAuthConn is ServeConn requiring NTLM authentication with creds.
+// Requests on a connection that did not authenticate, or whose verifier does not check, are answered with an
+// access denied fault.
+func ServeAuthConn(conn net.Conn, creds *ntlm.Credentials, accept func(iface SyntaxID) bool, handler Handler) error {
+	return serveConn(conn, creds, accept, handler)
+}
+
+func serveConn(conn net.Conn, creds *ntlm.Credentials, accept func(iface SyntaxID) bool, handler Handler) error {
+	contexts := make(map[uint16]SyntaxID)
+	var auth *ntlm.Server
+	var level uint8
+	var sec *security
+	maxXmit := uint16(defaultFragmentSize)
+	var pending *Request
+	var pendingID uint32
+	for {
+		pdu, err := ReadPDU(conn)
+		if err != nil {
+			return err
+		}
+		switch pdu.Type {
+		case PtypeBind, PtypeAlterContext:
+			bind, err := ParseBindBody(pdu.Body)
+			if err != nil {
+				return err
+			}
+			if pdu.Type == PtypeBind && bind.MaxRecvFrag != 0 && bind.MaxRecvFrag < maxXmit {
+				maxXmit = bind.MaxRecvFrag
+			}
+			ack := &BindAckBody{MaxXmitFrag: defaultFragmentSize, MaxRecvFrag: defaultFragmentSize, AssocGroupID: bind.AssocGroupID}
+			if ack.AssocGroupID == 0 {
+				ack.AssocGroupID = 0x1234
+			}
+			if pdu.Type == PtypeBind {
+				ack.SecondaryAddr = "135"
+			}
+			for _, c := range bind.Contexts {
+				result := ContextResult{Result: ResultProviderRejection, Reason: 1}
+				if supportsNDR(c.TransferSyntax) && (accept == nil || accept(c.AbstractSyntax)) {
+					result = ContextResult{Result: ResultAcceptance, TransferSyntax: NDRSyntax}
+					contexts[c.ContextID] = c.AbstractSyntax
+				}
+				ack.Results = append(ack.Results, result)
+			}
+			ptype := uint8(PtypeBindAck)
+			if pdu.Type == PtypeAlterContext {
+				ptype = PtypeAlterContextResp
+			}
+			resp := &PDU{Header: Header{Type: ptype, Flags: PfcFirstFrag | PfcLastFrag, CallID: pdu.CallID}, Body: ack.Marshal()}
+			if creds != nil && pdu.Type == PtypeBind && pdu.Auth != nil {
+				var negotiate []byte
+				level, _, negotiate, err = parseSecTrailer(pdu.Auth)
+				if err != nil {
+					return err
+				}
+				auth = ntlm.NewServer(creds)
+				challenge, err := auth.Challenge(negotiate)
+				if err != nil {
+					return err
+				}
+				resp.Auth = append(secTrailer(level, 0), challenge...)
+			}
+			if _, err = conn.Write(resp.Marshal()); err != nil {
+				return err
+			}
+		case PtypeAuth3:
+			if auth == nil {
+				return errors.New("rpc: auth3 without bind")
+			}
+			_, _, authenticate, err := parseSecTrailer(pdu.Auth)
+			if err != nil {
+				return err
+			}
+			if auth.Authenticate(authenticate) == nil {
+				sec = &security{level: level, session: auth.Session()}
+			}
+		case PtypeRequest:
+			if creds != nil && (sec == nil || sec.unmarshal(pdu, requestStubOffset(pdu)) != nil) {
+				if pdu.Flags&PfcLastFrag != 0 {
+					if err = writeFault(conn, pdu.CallID, 0, FaultAccessDenied); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			body, err := ParseRequestBody(pdu.Body, pdu.Flags&PfcObjectUUID != 0)
+			if err != nil {
+				return err
+			}
+			if pdu.Flags&PfcFirstFrag != 0 {
+				iface, ok := contexts[body.ContextID]
+				if !ok {
+					return fmt.Errorf("rpc: request on unknown context %d", body.ContextID)
+				}
+				pending = &Request{Interface: iface, Opnum: body.Opnum, Object: body.Object}
+				pendingID = pdu.CallID
+			} else if pending == nil || pendingID != pdu.CallID {
+				return errors.New("rpc: unexpected request fragment")
+			}
+			pending.Stub = append(pending.Stub, body.Stub...)
+			if pdu.Flags&PfcLastFrag == 0 {
+				continue
+			}
+			req := pending
+			pending = nil
+			stub, err := handler(req)
+			if err != nil {
+				var fault *FaultError
+				status := uint32(FaultRemoteError)
+				if errors.As(err, &fault) {
+					status = fault.Status
+				}
+				if err = writeFault(conn, pdu.CallID, body.ContextID, status); err != nil {
+					return err
+				}
+				continue
+			}
+			if err = writeResponse(conn, sec, pdu.CallID, body.ContextID, stub, int(maxXmit)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("rpc: unexpected PDU type %d", pdu.Type)
+		}
+	}
+}
+
+func supportsNDR(syntaxes []SyntaxID) bool {
+	for _, s := range syntaxes {
+		if s == NDRSyntax {
+			return true
+		}
+	}
+	return false
+}
+
+func writeFault(conn net.Conn, callID uint32, contextID uint16, status uint32) error {
+	resp := &PDU{
+		Header: Header{Type: PtypeFault, Flags: PfcFirstFrag | PfcLastFrag, CallID: callID},
+		Body:   (&ResponseBody{ContextID: contextID, Status: status}).Marshal(true),
+	}
+	_, err := conn.Write(resp.Marshal())
+	return err
+}
+
+func writeResponse(conn net.Conn, sec *security, callID uint32, contextID uint16, stub []byte, maxXmit int) error {
+	maxStub := sec.maxStub(maxXmit, headerLength+responseStubOffset)
+	remaining := stub
+	first := true
+	for first || len(remaining) > 0 {
+		n := len(remaining)
+		if n > maxStub {
+			n = maxStub
+		}
+		flags := uint8(0)
+		if first {
+			flags |= PfcFirstFrag
+		}
+		if n == len(remaining) {
+			flags |= PfcLastFrag
+		}
+		resp := &PDU{
+			Header: Header{Type: PtypeResponse, Flags: flags, CallID: callID},
+			Body:   (&ResponseBody{AllocHint: uint32(len(remaining)), ContextID: contextID, Stub: remaining[:n]}).Marshal(false),
+		}
+		if _, err := conn.Write(sec.marshal(resp, responseStubOffset)); err != nil {
+			return err
+		}
+		remaining = remaining[n:]
+		first = false
+	}
+	return nil
+}
@@ -0,0 +1,784 @@
+package dcom
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+	"unicode/utf16"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+)
+
+// SAFEARRAY union discriminants
+const (
+	sfI1      = 0x10
+	sfI2      = 0x02
+	sfI4      = 0x03
+	sfI8      = 0x14
+	sfBSTR    = 0x08
+	sfVariant = 0x0c
+)
+
+// SAFEARRAY features
+const (
+	fadfHaveVarType = 0x0080
+	fadfBSTR        = 0x0100
+	fadfVariant     = 0x0800
+)
+
+// WriteVariant writes the _wireVARIANT pointee of a VARIANT, the caller writes the pointer
+func WriteVariant(w *ndr.Writer, value interface{}) error {
+	vt, err := variantType(value)
+	if err != nil {
+		return err
+	}
+	w.Align(8)
+	start := w.Len()
+	w.WriteUint32(0)
+	w.WriteUint32(0)
+	w.WriteUint16(uint16(vt))
+	w.WriteUint16(0)
+	w.WriteUint16(0)
+	w.WriteUint16(0)
+	w.WriteUint32(uint32(vt))
+	if vt&com.VT_BYREF != 0 {
+		// the union holds a pointer to the value of the referenced type
+		w.WritePointer(true)
+		vt &^= com.VT_BYREF
+		if vt == com.VT_VARIANT {
+			w.WritePointer(true)
+			err = WriteVariant(w, *value.(*interface{}))
+			if err != nil {
+				return err
+			}
+		} else {
+			writeValue(w, vt, reflect.ValueOf(value).Elem().Interface())
+		}
+	} else {
+		writeValue(w, vt, value)
+	}
+	w.PatchUint32(start, uint32((w.Len()-start+7)/8))
+	return nil
+}
+
+// writeValue writes the union arm of a VARIANT that is not VT_BYREF
+func writeValue(w *ndr.Writer, vt com.VT, value interface{}) {
+	if vt&com.VT_ARRAY != 0 {
+		w.WritePointer(true)
+		w.WritePointer(true)
+		writeSafeArray(w, vt&^com.VT_ARRAY, value)
+		return
+	}
+	writeScalar(w, vt, value)
+}
+
+// variantType returns the VARIANT type of value, a pointer is sent as a VT_BYREF of the type it points to
+//
+//gocyclo:ignore
+func variantType(value interface{}) (com.VT, error) {
+	switch value.(type) {
+	case nil:
+		return com.VT_EMPTY, nil
+	case com.Currency:
+		return com.VT_CY, nil
+	case com.Decimal:
+		return com.VT_DECIMAL, nil
+	case com.SCode:
+		return com.VT_ERROR, nil
+	case []com.Currency:
+		return com.VT_ARRAY | com.VT_CY, nil
+	case []com.SCode:
+		return com.VT_ARRAY | com.VT_ERROR, nil
+	case []com.Decimal:
+		return 0, errors.New("dcom: a SAFEARRAY of VT_DECIMAL has no wire representation, use []interface{}")
+	case *interface{}:
+		p := value.(*interface{})
+		if p == nil {
+			return 0, errors.New("dcom: nil VT_BYREF pointer")
+		}
+		if _, err := variantType(*p); err != nil {
+			return 0, err
+		}
+		return com.VT_BYREF | com.VT_VARIANT, nil
+	case int8:
+		return com.VT_I1, nil
+	case uint8:
+		return com.VT_UI1, nil
+	case int16:
+		return com.VT_I2, nil
+	case uint16:
+		return com.VT_UI2, nil
+	case int32:
+		return com.VT_I4, nil
+	case uint32:
+		return com.VT_UI4, nil
+	case int64:
+		return com.VT_I8, nil
+	case uint64:
+		return com.VT_UI8, nil
+	case int:
+		return com.VT_INT, nil
+	case uint:
+		return com.VT_UINT, nil
+	case float32:
+		return com.VT_R4, nil
+	case float64:
+		return com.VT_R8, nil
+	case string:
+		return com.VT_BSTR, nil
+	case time.Time:
+		return com.VT_DATE, nil
+	case bool:
+		return com.VT_BOOL, nil
+	case []int8:
+		return com.VT_ARRAY | com.VT_I1, nil
+	case []uint8:
+		return com.VT_ARRAY | com.VT_UI1, nil
+	case []int16:
+		return com.VT_ARRAY | com.VT_I2, nil
+	case []uint16:
+		return com.VT_ARRAY | com.VT_UI2, nil
+	case []int32:
+		return com.VT_ARRAY | com.VT_I4, nil
+	case []uint32:
+		return com.VT_ARRAY | com.VT_UI4, nil
+	case []int64:
+		return com.VT_ARRAY | com.VT_I8, nil
+	case []uint64:
+		return com.VT_ARRAY | com.VT_UI8, nil
+	case []int:
+		return com.VT_ARRAY | com.VT_INT, nil
+	case []uint:
+		return com.VT_ARRAY | com.VT_UINT, nil
+	case []float32:
+		return com.VT_ARRAY | com.VT_R4, nil
+	case []float64:
+		return com.VT_ARRAY | com.VT_R8, nil
+	case []string:
+		return com.VT_ARRAY | com.VT_BSTR, nil
+	case []time.Time:
+		return com.VT_ARRAY | com.VT_DATE, nil
+	case []bool:
+		return com.VT_ARRAY | com.VT_BOOL, nil
+	case []interface{}:
+		for _, e := range value.([]interface{}) {
+			if _, err := variantType(e); err != nil {
+				return 0, err
+			}
+		}
+		return com.VT_ARRAY | com.VT_VARIANT, nil
+	case com.Array:
+		return arrayType(value.(com.Array))
+	}
+	if com.IsNestedSlice(value) {
+		array, err := com.NewArray(value)
+		if err != nil {
+			return 0, err
+		}
+		return arrayType(array)
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, errors.New("dcom: nil VT_BYREF pointer")
+		}
+		vt, err := variantType(v.Elem().Interface())
+		if err != nil {
+			return 0, err
+		}
+		if vt&com.VT_BYREF != 0 || vt == com.VT_EMPTY {
+			return 0, fmt.Errorf("unsupported type: %T", value)
+		}
+		return com.VT_BYREF | vt, nil
+	}
+	return 0, fmt.Errorf("unsupported type: %T", value)
+}
+
+func arrayType(array com.Array) (com.VT, error) {
+	err := array.Validate()
+	if err != nil {
+		return 0, err
+	}
+	vt, _ := array.ElementType()
+	switch vt {
+	case com.VT_DECIMAL:
+		return 0, errors.New("dcom: a SAFEARRAY of VT_DECIMAL has no wire representation, use []interface{}")
+	case com.VT_VARIANT:
+		for _, e := range array.Values.([]interface{}) {
+			if _, err := variantType(e); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return com.VT_ARRAY | vt, nil
+}
+
+//gocyclo:ignore
+func writeScalar(w *ndr.Writer, vt com.VT, value interface{}) {
+	switch v := value.(type) {
+	case int8:
+		w.WriteUint8(uint8(v))
+	case uint8:
+		w.WriteUint8(v)
+	case int16:
+		w.WriteUint16(uint16(v))
+	case uint16:
+		w.WriteUint16(v)
+	case int32:
+		w.WriteUint32(uint32(v))
+	case uint32:
+		w.WriteUint32(v)
+	case int64:
+		w.WriteUint64(uint64(v))
+	case uint64:
+		w.WriteUint64(v)
+	case int:
+		w.WriteUint32(uint32(int32(v)))
+	case uint:
+		w.WriteUint32(uint32(v))
+	case float32:
+		w.WriteFloat32(v)
+	case float64:
+		w.WriteFloat64(v)
+	case string:
+		w.WritePointer(true)
+		writeBSTR(w, v)
+	case time.Time:
+		w.WriteFloat64(TimeToOLEDate(v))
+	case bool:
+		if v {
+			w.WriteUint16(0xffff)
+		} else {
+			w.WriteUint16(0)
+		}
+	case com.Currency:
+		w.WriteUint64(uint64(v))
+	case com.SCode:
+		w.WriteUint32(uint32(v))
+	case com.Decimal:
+		writeDecimal(w, v)
+	}
+}
+
+// writeDecimal writes a DECIMAL, the sign byte is 0x80 for negative values
+func writeDecimal(w *ndr.Writer, d com.Decimal) {
+	w.Align(8)
+	w.WriteUint16(0)
+	w.WriteUint8(d.Scale)
+	if d.Negative {
+		w.WriteUint8(0x80)
+	} else {
+		w.WriteUint8(0)
+	}
+	w.WriteUint32(d.Hi32)
+	w.WriteUint64(d.Lo64)
+}
+
+// writeBSTR writes the FLAGGED_WORD_BLOB of a BSTR
+func writeBSTR(w *ndr.Writer, s string) {
+	chars := utf16.Encode([]rune(s))
+	w.WriteUint32(uint32(len(chars)))
+	w.WriteUint32(uint32(len(chars) * 2))
+	w.WriteUint32(uint32(len(chars)))
+	for _, c := range chars {
+		w.WriteUint16(c)
+	}
+}
+
+func safeArrayLayout(vt com.VT) (sfType uint32, elementSize uint32, features uint16) {
+	switch vt {
+	case com.VT_I1, com.VT_UI1:
+		return sfI1, 1, fadfHaveVarType
+	case com.VT_I2, com.VT_UI2, com.VT_BOOL:
+		return sfI2, 2, fadfHaveVarType
+	case com.VT_I8, com.VT_UI8, com.VT_R8, com.VT_DATE, com.VT_CY:
+		return sfI8, 8, fadfHaveVarType
+	case com.VT_BSTR:
+		return sfBSTR, 4, fadfHaveVarType | fadfBSTR
+	case com.VT_VARIANT:
+		return sfVariant, 16, fadfHaveVarType | fadfVariant
+	}
+	return sfI4, 4, fadfHaveVarType
+}
+
+// writeSafeArray writes a wireSAFEARRAY, a slice has one dimension with a lower bound of zero
+func writeSafeArray(w *ndr.Writer, vt com.VT, value interface{}) {
+	bounds, elements := arrayElements(value)
+	sfType, elementSize, features := safeArrayLayout(vt)
+	w.WriteUint32(uint32(len(bounds)))
+	w.WriteUint16(uint16(len(bounds)))
+	w.WriteUint16(features)
+	w.WriteUint32(elementSize)
+	w.WriteUint32(uint32(vt) << 16)
+	w.WriteUint32(sfType)
+	w.WriteUint32(uint32(len(elements)))
+	w.WritePointer(true)
+	// the bounds are in reverse order like in the memory layout, the rightmost dimension first
+	for i := len(bounds) - 1; i >= 0; i-- {
+		w.WriteUint32(bounds[i].Elements)
+		w.WriteInt32(bounds[i].LowerBound)
+	}
+	w.WriteUint32(uint32(len(elements)))
+	switch sfType {
+	case sfBSTR:
+		for range elements {
+			w.WritePointer(true)
+		}
+		for _, e := range elements {
+			writeBSTR(w, e.(string))
+		}
+	case sfVariant:
+		for range elements {
+			w.WritePointer(true)
+		}
+		for _, e := range elements {
+			// the element types were checked by variantType of the caller
+			_ = WriteVariant(w, e)
+		}
+	default:
+		for _, e := range elements {
+			writeScalar(w, vt, e)
+		}
+	}
+}
+
+// arrayElements returns the bounds from the leftmost dimension and the elements of a slice, an Array or nested
+// slices, which were checked by variantType
+func arrayElements(value interface{}) ([]com.SafeArrayBound, []interface{}) {
+	if com.IsNestedSlice(value) {
+		value, _ = com.NewArray(value)
+	}
+	if array, ok := value.(com.Array); ok {
+		return array.Dims, sliceElements(array.Values)
+	}
+	elements := sliceElements(value)
+	return []com.SafeArrayBound{{Elements: uint32(len(elements))}}, elements
+}
+
+//gocyclo:ignore
+func sliceElements(value interface{}) []interface{} {
+	var elements []interface{}
+	switch v := value.(type) {
+	case []int8:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []uint8:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []int16:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []uint16:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []int32:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []uint32:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []int64:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []uint64:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []int:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []uint:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []float32:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []float64:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []string:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []time.Time:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []bool:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []com.Currency:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []com.SCode:
+		for _, e := range v {
+			elements = append(elements, e)
+		}
+	case []interface{}:
+		elements = v
+	}
+	return elements
+}
+
+// ReadVariant reads the _wireVARIANT pointee of a VARIANT. A value that cannot be decoded is skipped with the size
+// of the VARIANT and returned as a *com.DecodeError, failures of the stub are recorded in r.
+func ReadVariant(r *ndr.Reader) (interface{}, error) {
+	r.Align(8)
+	start := r.Offset()
+	size := r.ReadUint32()
+	r.ReadUint32()
+	vt := com.VT(r.ReadUint16())
+	r.ReadUint16()
+	r.ReadUint16()
+	r.ReadUint16()
+	r.ReadUint32()
+	if r.Err() != nil {
+		return nil, nil
+	}
+	value, err := readVariantValue(r, vt)
+	if err != nil {
+		// clSize counts 8 byte units and includes the pointees, the VARIANT is never skipped backwards
+		if n := start + int(size)*8 - r.Offset(); n > 0 {
+			r.Skip(n)
+		}
+		return nil, com.NewDecodeError(vt, err)
+	}
+	return value, nil
+}
+
+// setDecodeErrors sets the error of the items whose value cannot be decoded to com.BadVarType
+func setDecodeErrors(errors []int32, decodeErrors []error) {
+	for i, err := range decodeErrors {
+		if err != nil && i < len(errors) && errors[i] >= 0 {
+			errors[i] = com.BadVarType
+		}
+	}
+}
+
+func readVariantValue(r *ndr.Reader, vt com.VT) (interface{}, error) {
+	if vt&com.VT_BYREF != 0 {
+		// the referenced value is returned, a null reference reads as nil
+		if !r.ReadPointer() {
+			return nil, nil
+		}
+		vt &^= com.VT_BYREF
+		if vt == com.VT_VARIANT {
+			if !r.ReadPointer() {
+				return nil, nil
+			}
+			return ReadVariant(r)
+		}
+	}
+	if vt&com.VT_ARRAY != 0 {
+		if !r.ReadPointer() {
+			return nil, nil
+		}
+		r.ReadPointer()
+		return readSafeArray(r, vt&^com.VT_ARRAY)
+	}
+	return readScalar(r, vt)
+}
+
+//gocyclo:ignore
+func readScalar(r *ndr.Reader, vt com.VT) (interface{}, error) {
+	switch vt {
+	case com.VT_EMPTY, com.VT_NULL:
+		return nil, nil
+	case com.VT_I1:
+		return int8(r.ReadUint8()), nil
+	case com.VT_UI1:
+		return r.ReadUint8(), nil
+	case com.VT_I2:
+		return int16(r.ReadUint16()), nil
+	case com.VT_UI2:
+		return r.ReadUint16(), nil
+	case com.VT_I4:
+		return r.ReadInt32(), nil
+	case com.VT_UI4:
+		return r.ReadUint32(), nil
+	case com.VT_INT:
+		return int(r.ReadInt32()), nil
+	case com.VT_UINT:
+		return uint(r.ReadUint32()), nil
+	case com.VT_I8:
+		return int64(r.ReadUint64()), nil
+	case com.VT_UI8:
+		return r.ReadUint64(), nil
+	case com.VT_R4:
+		return r.ReadFloat32(), nil
+	case com.VT_R8:
+		return r.ReadFloat64(), nil
+	case com.VT_DATE:
+		return OLEDateToTime(r.ReadFloat64()), nil
+	case com.VT_BOOL:
+		return r.ReadUint16() != 0, nil
+	case com.VT_BSTR:
+		if !r.ReadPointer() {
+			return "", nil
+		}
+		return readBSTR(r), nil
+	case com.VT_ERROR:
+		return com.SCode(r.ReadInt32()), nil
+	case com.VT_CY:
+		return com.Currency(r.ReadUint64()), nil
+	case com.VT_DECIMAL:
+		return readDecimal(r), nil
+	}
+	return nil, com.ErrUnsupportedType
+}
+
+func readDecimal(r *ndr.Reader) com.Decimal {
+	r.Align(8)
+	r.ReadUint16()
+	d := com.Decimal{Scale: r.ReadUint8()}
+	d.Negative = r.ReadUint8()&0x80 != 0
+	d.Hi32 = r.ReadUint32()
+	d.Lo64 = r.ReadUint64()
+	return d
+}
+
+func readBSTR(r *ndr.Reader) string {
+	r.ReadCount(0)
+	byteLength := r.ReadUint32()
+	n := r.ReadCount(2)
+	if byteLength == 0xffffffff {
+		return ""
+	}
+	chars := make([]uint16, n)
+	for i := range chars {
+		chars[i] = r.ReadUint16()
+	}
+	return string(utf16.Decode(chars))
+}
+
+// readSafeArray reads a wireSAFEARRAY, a SAFEARRAY of more than one dimension is returned as a com.Array
+func readSafeArray(r *ndr.Reader, vt com.VT) (interface{}, error) {
+	bounds, values, err := readSafeArrayElements(r, vt)
+	if err != nil || len(bounds) < 2 || values == nil {
+		return values, err
+	}
+	return com.Array{Dims: bounds, Values: values}, nil
+}
+
+// readSafeArrayElements reads a wireSAFEARRAY and returns the bounds from the leftmost dimension and the elements
+//
+//gocyclo:ignore
+func readSafeArrayElements(r *ndr.Reader, vt com.VT) ([]com.SafeArrayBound, interface{}, error) {
+	r.ReadCount(8)
+	dims := int(r.ReadUint16())
+	features := r.ReadUint16()
+	r.ReadUint32()
+	locks := r.ReadUint32()
+	if features&fadfHaveVarType != 0 && locks>>16 != 0 {
+		vt = com.VT(locks >> 16)
+	}
+	sfType := r.ReadUint32()
+	size := int(r.ReadUint32())
+	present := r.ReadPointer()
+	total := uint64(1)
+	bounds := make([]com.SafeArrayBound, dims)
+	for i := dims - 1; i >= 0; i-- {
+		bounds[i].Elements = r.ReadUint32()
+		bounds[i].LowerBound = r.ReadInt32()
+		// the product is capped so that it cannot wrap around to the size
+		total *= uint64(bounds[i].Elements)
+		if total > math.MaxUint32 {
+			total = math.MaxUint32 + 1
+		}
+	}
+	if r.Err() != nil {
+		return nil, nil, nil
+	}
+	if dims == 0 {
+		total = 0
+	}
+	if total != uint64(size) {
+		r.SetErr(fmt.Errorf("dcom: SAFEARRAY holds %d elements but its bounds describe %d", size, total))
+		return nil, nil, nil
+	}
+	if !present {
+		return nil, nil, nil
+	}
+	n := r.ReadCount(1)
+	if n != size {
+		r.SetErr(fmt.Errorf("dcom: SAFEARRAY data holds %d elements, expected %d", n, size))
+		return nil, nil, nil
+	}
+	switch sfType {
+	case sfBSTR:
+		values := make([]string, n)
+		present := make([]bool, n)
+		for i := range present {
+			present[i] = r.ReadPointer()
+		}
+		for i := range values {
+			if present[i] {
+				values[i] = readBSTR(r)
+			}
+		}
+		return bounds, values, nil
+	case sfVariant:
+		values := make([]interface{}, n)
+		present := make([]bool, n)
+		for i := range present {
+			present[i] = r.ReadPointer()
+		}
+		// every element is read to keep the stub in step, the first element that cannot be decoded fails the array
+		var err error
+		for i := range values {
+			if present[i] {
+				value, elementErr := ReadVariant(r)
+				if elementErr != nil && err == nil {
+					err = elementErr
+				}
+				values[i] = value
+			}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return bounds, values, nil
+	case sfI1, sfI2, sfI4, sfI8:
+	default:
+		return nil, nil, fmt.Errorf("%w: SAFEARRAY type %d", com.ErrUnsupportedType, sfType)
+	}
+	switch vt {
+	case com.VT_I1:
+		values := make([]int8, n)
+		for i := range values {
+			values[i] = int8(r.ReadUint8())
+		}
+		return bounds, values, nil
+	case com.VT_UI1:
+		return bounds, r.ReadBytes(n), nil
+	case com.VT_I2:
+		values := make([]int16, n)
+		for i := range values {
+			values[i] = int16(r.ReadUint16())
+		}
+		return bounds, values, nil
+	case com.VT_UI2:
+		values := make([]uint16, n)
+		for i := range values {
+			values[i] = r.ReadUint16()
+		}
+		return bounds, values, nil
+	case com.VT_BOOL:
+		values := make([]bool, n)
+		for i := range values {
+			values[i] = r.ReadUint16() != 0
+		}
+		return bounds, values, nil
+	case com.VT_I4:
+		values := make([]int32, n)
+		for i := range values {
+			values[i] = r.ReadInt32()
+		}
+		return bounds, values, nil
+	case com.VT_UI4:
+		values := make([]uint32, n)
+		for i := range values {
+			values[i] = r.ReadUint32()
+		}
+		return bounds, values, nil
+	case com.VT_INT:
+		values := make([]int, n)
+		for i := range values {
+			values[i] = int(r.ReadInt32())
+		}
+		return bounds, values, nil
+	case com.VT_UINT:
+		values := make([]uint, n)
+		for i := range values {
+			values[i] = uint(r.ReadUint32())
+		}
+		return bounds, values, nil
+	case com.VT_R4:
+		values := make([]float32, n)
+		for i := range values {
+			values[i] = r.ReadFloat32()
+		}
+		return bounds, values, nil
+	case com.VT_I8:
+		values := make([]int64, n)
+		for i := range values {
+			values[i] = int64(r.ReadUint64())
+		}
+		return bounds, values, nil
+	case com.VT_UI8:
+		values := make([]uint64, n)
+		for i := range values {
+			values[i] = r.ReadUint64()
+		}
+		return bounds, values, nil
+	case com.VT_R8:
+		values := make([]float64, n)
+		for i := range values {
+			values[i] = r.ReadFloat64()
+		}
+		return bounds, values, nil
+	case com.VT_DATE:
+		values := make([]time.Time, n)
+		for i := range values {
+			values[i] = OLEDateToTime(r.ReadFloat64())
+		}
+		return bounds, values, nil
+	case com.VT_CY:
+		values := make([]com.Currency, n)
+		for i := range values {
+			values[i] = com.Currency(r.ReadUint64())
+		}
+		return bounds, values, nil
+	case com.VT_ERROR:
+		values := make([]com.SCode, n)
+		for i := range values {
+			values[i] = com.SCode(r.ReadInt32())
+		}
+		return bounds, values, nil
+	}
+	return nil, nil, fmt.Errorf("%w: SAFEARRAY element type 0x%x", com.ErrUnsupportedType, uint16(vt))
+}
+
+// OLEDateToTime converts an OLE automation date, see com.OLEDateToTime
+func OLEDateToTime(date float64) time.Time {
+	return com.OLEDateToTime(date)
+}
+
+// TimeToOLEDate converts t to an OLE automation date, see com.TimeToOLEDate
+func TimeToOLEDate(t time.Time) float64 {
+	return com.TimeToOLEDate(t)
+}
+
+// FileTimeToTime converts a FILETIME given as 100ns intervals since 1601
+func FileTimeToTime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	const unixEpoch = 116444736000000000
+	ns := (int64(ft) - unixEpoch) * 100
+	return time.Unix(0, ns)
+}
+
+// TimeToFileTime is the inverse of FileTimeToTime
+func TimeToFileTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	const unixEpoch = 116444736000000000
+	return uint64(t.UnixNano()/100 + unixEpoch)
+}
@@ -0,0 +1,318 @@
+package dcom
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/dcom/ndr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVariantRoundTrip(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	values := []interface{}{
+		nil,
+		int8(-1), uint8(2), int16(-3), uint16(4), int32(-5), uint32(6), int64(-7), uint64(8),
+		int(-9), uint(10), float32(1.5), float64(-2.25), "text", "", true, false, now,
+		[]int8{-1, 2}, []uint8{3, 4}, []int16{-5}, []uint16{6}, []int32{-7, 8}, []uint32{9},
+		[]int64{-10}, []uint64{11}, []float32{1.5}, []float64{2.5, 3.5}, []string{"a", "", "bc"},
+		[]bool{true, false}, []time.Time{now},
+		[]interface{}{int32(1), "two", 3.0},
+		com.Currency(-125000), com.Decimal{Scale: 2, Negative: true, Hi32: 1, Lo64: 150}, com.SCode(-2147352572),
+		[]com.Currency{1, -2}, []com.SCode{0, -2147467259},
+		[]interface{}{com.Currency(10000), com.Decimal{Scale: 1, Lo64: 15}, []interface{}{"nested", nil}},
+	}
+	for _, value := range values {
+		w := ndr.NewWriter()
+		w.WriteUint32(0xdeadbeef)
+		require.NoError(t, WriteVariant(w, value), "%T", value)
+		r := ndr.NewReader(w.Bytes())
+		r.ReadUint32()
+		got, err := ReadVariant(r)
+		require.NoError(t, err, "%T", value)
+		require.NoError(t, r.Err(), "%T", value)
+		assert.Equal(t, value, got, "%T", value)
+		assert.Zero(t, r.Remaining(), "%T", value)
+	}
+}
+
+func TestVariantSize(t *testing.T) {
+	w := ndr.NewWriter()
+	require.NoError(t, WriteVariant(w, int32(7)))
+	assert.Equal(t, []byte{
+		3, 0, 0, 0,
+		0, 0, 0, 0,
+		3, 0, 0, 0, 0, 0, 0, 0,
+		3, 0, 0, 0,
+		7, 0, 0, 0,
+	}, w.Bytes())
+}
+
+func TestVariantDecimalLayout(t *testing.T) {
+	w := ndr.NewWriter()
+	require.NoError(t, WriteVariant(w, com.Decimal{Scale: 2, Negative: true, Hi32: 1, Lo64: 150}))
+	assert.Equal(t, []byte{
+		5, 0, 0, 0,
+		0, 0, 0, 0,
+		14, 0, 0, 0, 0, 0, 0, 0,
+		14, 0, 0, 0,
+		0, 0, 0, 0,
+		0, 0, 2, 0x80, 1, 0, 0, 0,
+		150, 0, 0, 0, 0, 0, 0, 0,
+	}, w.Bytes())
+}
+
+func TestVariantArrayLayout(t *testing.T) {
+	w := ndr.NewWriter()
+	require.NoError(t, WriteVariant(w, com.Array{
+		Dims:   []com.SafeArrayBound{{Elements: 2, LowerBound: 1}, {Elements: 3}},
+		Values: []int16{1, 2, 3, 4, 5, 6},
+	}))
+	assert.Equal(t, []byte{
+		11, 0, 0, 0,
+		0, 0, 0, 0,
+		0x02, 0x20, 0, 0, 0, 0, 0, 0,
+		0x02, 0x20, 0, 0,
+		// the pointers to the SAFEARRAY
+		0, 0, 2, 0,
+		4, 0, 2, 0,
+		// the conformance of the bounds, the dimensions and the features
+		2, 0, 0, 0,
+		2, 0, 0x80, 0,
+		// the element size, the type in the high word of the locks and the union discriminant
+		2, 0, 0, 0,
+		0, 0, 2, 0,
+		2, 0, 0, 0,
+		// the size and the pointer of the data
+		6, 0, 0, 0,
+		8, 0, 2, 0,
+		// the bounds from the rightmost dimension
+		3, 0, 0, 0, 0, 0, 0, 0,
+		2, 0, 0, 0, 1, 0, 0, 0,
+		// the data, the leftmost dimension varies fastest
+		6, 0, 0, 0,
+		1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 6, 0,
+	}, w.Bytes())
+}
+
+func TestVariantMultiDimensional(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	values := []struct {
+		value interface{}
+		want  com.Array
+	}{
+		{
+			[][]float64{{1, 2, 3}, {4, 5, 6}},
+			com.Array{Dims: []com.SafeArrayBound{{Elements: 2}, {Elements: 3}}, Values: []float64{1, 4, 2, 5, 3, 6}},
+		},
+		{
+			[][][]uint8{{{1, 2}}, {{3, 4}}, {{5, 6}}},
+			com.Array{Dims: []com.SafeArrayBound{{Elements: 3}, {Elements: 1}, {Elements: 2}}, Values: []uint8{1, 3, 5, 2, 4, 6}},
+		},
+		{
+			[][]string{{"a", ""}, {"b", "c"}},
+			com.Array{Dims: []com.SafeArrayBound{{Elements: 2}, {Elements: 2}}, Values: []string{"a", "b", "", "c"}},
+		},
+		{
+			[][]interface{}{{int32(1), "two"}, {nil, []int16{3}}},
+			com.Array{Dims: []com.SafeArrayBound{{Elements: 2}, {Elements: 2}}, Values: []interface{}{int32(1), nil, "two", []int16{3}}},
+		},
+		{
+			com.Array{Dims: []com.SafeArrayBound{{Elements: 1, LowerBound: -5}, {Elements: 2, LowerBound: 7}}, Values: []time.Time{now, now}},
+			com.Array{Dims: []com.SafeArrayBound{{Elements: 1, LowerBound: -5}, {Elements: 2, LowerBound: 7}}, Values: []time.Time{now, now}},
+		},
+		{
+			com.Array{Dims: []com.SafeArrayBound{{Elements: 2}, {Elements: 0}}, Values: []com.Currency{}},
+			com.Array{Dims: []com.SafeArrayBound{{Elements: 2}, {Elements: 0}}, Values: []com.Currency{}},
+		},
+	}
+	for _, v := range values {
+		w := ndr.NewWriter()
+		require.NoError(t, WriteVariant(w, v.value), "%T", v.value)
+		r := ndr.NewReader(w.Bytes())
+		got, err := ReadVariant(r)
+		require.NoError(t, err, "%T", v.value)
+		require.NoError(t, r.Err(), "%T", v.value)
+		assert.Equal(t, v.want, got, "%T", v.value)
+		assert.Zero(t, r.Remaining(), "%T", v.value)
+		if nested, ok := v.value.([][]float64); ok {
+			back, err := got.(com.Array).Nested()
+			require.NoError(t, err)
+			assert.Equal(t, nested, back)
+		}
+	}
+	// one dimension is still read as a slice, the lower bound is dropped
+	w := ndr.NewWriter()
+	require.NoError(t, WriteVariant(w, com.Array{Dims: []com.SafeArrayBound{{Elements: 2, LowerBound: 1}}, Values: []int32{1, 2}}))
+	r := ndr.NewReader(w.Bytes())
+	got, err := ReadVariant(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Err())
+	assert.Equal(t, []int32{1, 2}, got)
+}
+
+func TestVariantArrayBoundsMismatch(t *testing.T) {
+	w := ndr.NewWriter()
+	require.NoError(t, WriteVariant(w, [][]int16{{1, 2}, {3, 4}}))
+	b := w.Bytes()
+	// the rightmost dimension claims 3 elements
+	b[64] = 3
+	r := ndr.NewReader(b)
+	got, _ := ReadVariant(r)
+	assert.Nil(t, got)
+	assert.Error(t, r.Err())
+}
+
+func TestVariantByRef(t *testing.T) {
+	i1 := int8(-3)
+	text := "text"
+	currency := com.Currency(5)
+	decimal := com.Decimal{Scale: 1, Lo64: 15}
+	array := []int32{1, 2}
+	var variant interface{} = []interface{}{"a", int16(2)}
+	values := []struct {
+		pointer interface{}
+		vt      com.VT
+		want    interface{}
+	}{
+		{&i1, com.VT_BYREF | com.VT_I1, i1},
+		{&text, com.VT_BYREF | com.VT_BSTR, text},
+		{&currency, com.VT_BYREF | com.VT_CY, currency},
+		{&decimal, com.VT_BYREF | com.VT_DECIMAL, decimal},
+		{&array, com.VT_BYREF | com.VT_ARRAY | com.VT_I4, array},
+		{&variant, com.VT_BYREF | com.VT_VARIANT, variant},
+	}
+	for _, v := range values {
+		w := ndr.NewWriter()
+		require.NoError(t, WriteVariant(w, v.pointer), "%T", v.pointer)
+		b := w.Bytes()
+		assert.Equal(t, v.vt, com.VT(binary.LittleEndian.Uint16(b[8:])), "%T", v.pointer)
+		r := ndr.NewReader(b)
+		got, err := ReadVariant(r)
+		require.NoError(t, err, "%T", v.pointer)
+		require.NoError(t, r.Err(), "%T", v.pointer)
+		assert.Equal(t, v.want, got, "%T", v.pointer)
+		assert.Zero(t, r.Remaining(), "%T", v.pointer)
+	}
+	// a null reference reads as nil
+	w := ndr.NewWriter()
+	require.NoError(t, WriteVariant(w, &i1))
+	b := w.Bytes()[:24]
+	b[0] = 3
+	copy(b[20:], []byte{0, 0, 0, 0})
+	r := ndr.NewReader(b)
+	got, err := ReadVariant(r)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+	assert.NoError(t, r.Err())
+}
+
+func TestVariantUnsupported(t *testing.T) {
+	for _, value := range []interface{}{
+		struct{}{},
+		[]interface{}{1, struct{}{}},
+		[]com.Decimal{{Lo64: 1}},
+		[][]com.Decimal{{{Lo64: 1}}},
+		[][]int32{{1}, {2, 3}},
+		[][]interface{}{{struct{}{}}},
+		com.Array{Dims: []com.SafeArrayBound{{Elements: 2}, {Elements: 2}}, Values: []int32{1}},
+		(*int32)(nil),
+		new(struct{}),
+	} {
+		w := ndr.NewWriter()
+		assert.Error(t, WriteVariant(w, value), "%T", value)
+	}
+	// VT_UNKNOWN is not supported by the reader, it is skipped and returned as a decode error
+	w := ndr.NewWriter()
+	require.NoError(t, WriteVariant(w, int32(7)))
+	b := w.Bytes()
+	b[8], b[16] = byte(com.VT_UNKNOWN), byte(com.VT_UNKNOWN)
+	r := ndr.NewReader(b)
+	got, err := ReadVariant(r)
+	assert.Nil(t, got)
+	var decodeErr *com.DecodeError
+	require.ErrorAs(t, err, &decodeErr)
+	assert.Equal(t, com.VT_UNKNOWN, decodeErr.VT)
+	assert.ErrorIs(t, err, com.ErrUnsupportedType)
+	assert.NoError(t, r.Err())
+	assert.Zero(t, r.Remaining())
+}
+
+func TestVariantDecodeError(t *testing.T) {
+	w := ndr.NewWriter()
+	w.WritePointer(true)
+	writeVariantArray(w, []interface{}{"one", []interface{}{int32(2), int32(3)}, int32(4)})
+	b := w.Bytes()
+	// the second element of the nested array becomes VT_UNKNOWN
+	b[184], b[192] = byte(com.VT_UNKNOWN), byte(com.VT_UNKNOWN)
+	r := ndr.NewReader(b)
+	values, errs := readVariantArray(r)
+	require.NoError(t, r.Err())
+	assert.Zero(t, r.Remaining())
+	assert.Equal(t, []interface{}{"one", nil, int32(4)}, values)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[2])
+	var decodeErr *com.DecodeError
+	require.ErrorAs(t, errs[1], &decodeErr)
+	assert.Equal(t, com.VT_UNKNOWN, decodeErr.VT)
+	assert.ErrorIs(t, errs[1], com.ErrUnsupportedType)
+
+	itemErrors := []int32{0, 0, -1}
+	setDecodeErrors(itemErrors, []error{nil, errs[1], errs[1]})
+	assert.Equal(t, []int32{0, com.BadVarType, -1}, itemErrors)
+}
+
+func FuzzReadVariant(f *testing.F) {
+	for _, value := range []interface{}{
+		int32(1), "text", 2.5, com.Decimal{Scale: 1, Lo64: 15}, []string{"a", "b"}, []interface{}{int8(1), "x", nil},
+		[][]float64{{1, 2}, {3, 4}}, time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+	} {
+		w := ndr.NewWriter()
+		require.NoError(f, WriteVariant(w, value))
+		f.Add(w.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := ndr.NewReader(data)
+		value, err := ReadVariant(r)
+		if err != nil {
+			var decodeErr *com.DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("%v is not a DecodeError", err)
+			}
+			return
+		}
+		if r.Err() != nil {
+			return
+		}
+		// a decoded value can be written again
+		if err := WriteVariant(ndr.NewWriter(), value); err != nil {
+			t.Fatalf("write %#v: %v", value, err)
+		}
+	})
+}
+
+func TestOLEDate(t *testing.T) {
+	cases := []struct {
+		date float64
+		time time.Time
+	}{
+		{0, time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)},
+		{2.25, time.Date(1900, 1, 1, 6, 0, 0, 0, time.UTC)},
+		{-1.25, time.Date(1899, 12, 29, 6, 0, 0, 0, time.UTC)},
+		{45000.5, time.Date(2023, 3, 15, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.time, OLEDateToTime(c.date), "%v", c.date)
+		assert.Equal(t, c.date, TimeToOLEDate(c.time), "%v", c.time)
+	}
+}
+
+func TestFileTime(t *testing.T) {
+	now := time.Unix(1700000000, 123456700)
+	assert.Equal(t, now, FileTimeToTime(TimeToFileTime(now)))
+	assert.True(t, FileTimeToTime(0).IsZero())
+}
@@ -0,0 +1,234 @@
+package opcda
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DeliveryPolicy decides what happens to a callback event when the channel of its subscriber is full
+type DeliveryPolicy int
+
+const (
+	// DropNewest drops the event, it is the default
+	DropNewest DeliveryPolicy = iota
+	// Block waits until the channel has room, the timeout set with WithBlockTimeout expires, the channel is
+	// unsubscribed or the group is released. It holds up the events of every subscriber of the group while it waits,
+	// an event that times out is dropped.
+	Block
+	// DropOldest drops the oldest events waiting in the channel to make room, the channel acts as a ring buffer
+	DropOldest
+	// Coalesce merges the data changes waiting in the channel with the event, keeping the latest value, quality,
+	// timestamp and error of every client handle. Other events are delivered like DropOldest.
+	Coalesce
+)
+
+func (p DeliveryPolicy) String() string {
+	switch p {
+	case DropNewest:
+		return "DropNewest"
+	case Block:
+		return "Block"
+	case DropOldest:
+		return "DropOldest"
+	case Coalesce:
+		return "Coalesce"
+	}
+	return "Unknown"
+}
+
+// DeliveryOption configures how the events of a Register call are delivered
+type DeliveryOption func(*deliveryOptions)
+
+type deliveryOptions struct {
+	policy  DeliveryPolicy
+	timeout time.Duration
+}
+
+// WithDeliveryPolicy sets the policy applied when the channel is full, the default is DropNewest. DropOldest and
+// Coalesce take events back from the channel, which must not be shared with other registrations then.
+func WithDeliveryPolicy(policy DeliveryPolicy) DeliveryOption {
+	return func(o *deliveryOptions) {
+		o.policy = policy
+	}
+}
+
+// WithBlockTimeout sets how long the Block policy waits for room in the channel, zero waits until the channel is
+// unsubscribed or the group is released. It is zero by default.
+func WithBlockTimeout(d time.Duration) DeliveryOption {
+	return func(o *deliveryOptions) {
+		o.timeout = d
+	}
+}
+
+// DeliveryStats counts the callback events that did not reach a subscriber as they were
+type DeliveryStats struct {
+	// Dropped is the number of events dropped because a channel was full
+	Dropped uint64
+	// Coalesced is the number of data changes merged into a later one
+	Coalesced uint64
+}
+
+type deliveryStats struct {
+	dropped   atomic.Uint64
+	coalesced atomic.Uint64
+}
+
+// GetDeliveryStats get the number of callback events dropped and coalesced since the group was created
+func (g *OPCGroup) GetDeliveryStats() DeliveryStats {
+	return DeliveryStats{
+		Dropped:   g.deliveryStats.dropped.Load(),
+		Coalesced: g.deliveryStats.coalesced.Load(),
+	}
+}
+
+// subscriber is a channel registered for the events of a group with its delivery options
+type subscriber[T any] struct {
+	ch      chan T
+	options deliveryOptions
+	// lock is held while an event is delivered, closed is set once the subscriber is unsubscribed
+	lock   sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+func newSubscriber[T any](ch chan T, opts []DeliveryOption) *subscriber[T] {
+	s := &subscriber[T]{ch: ch, done: make(chan struct{})}
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+	return s
+}
+
+// close stops the delivery to the subscriber, it wakes up a blocked delivery and waits for it to return
+func (s *subscriber[T]) close() {
+	close(s.done)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+}
+
+// deliver sends an event to the subscriber according to its policy, merge coalesces events and is nil for the events
+// that cannot be coalesced. ctx is done when the group is released.
+func (s *subscriber[T]) deliver(ctx context.Context, stats *deliveryStats, event T, merge func(events []T) T) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- event:
+		return
+	default:
+	}
+	policy := s.options.policy
+	if policy == Coalesce && merge == nil {
+		policy = DropOldest
+	}
+	if cap(s.ch) == 0 && (policy == DropOldest || policy == Coalesce) {
+		// an unbuffered channel holds no event to drop
+		policy = DropNewest
+	}
+	switch policy {
+	case Block:
+		var timeout <-chan time.Time
+		if s.options.timeout > 0 {
+			timer := time.NewTimer(s.options.timeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case s.ch <- event:
+			return
+		case <-timeout:
+		case <-ctx.Done():
+		case <-s.done:
+		}
+	case DropOldest:
+		for {
+			select {
+			case <-s.ch:
+				stats.dropped.Add(1)
+			default:
+			}
+			select {
+			case s.ch <- event:
+				return
+			default:
+			}
+		}
+	case Coalesce:
+		var events []T
+	drain:
+		for len(events) < cap(s.ch) {
+			select {
+			case e := <-s.ch:
+				events = append(events, e)
+			default:
+				break drain
+			}
+		}
+		if len(events) > 0 {
+			event = merge(append(events, event))
+			stats.coalesced.Add(uint64(len(events)))
+		}
+		select {
+		case s.ch <- event:
+			return
+		default:
+		}
+	}
+	stats.dropped.Add(1)
+}
+
+// mergeDataChanges merges data changes into the last one, every client handle keeps its latest value in the order
+// the handles first appeared
+func mergeDataChanges(events []*DataChangeCallBackData) *DataChangeCallBackData {
+	last := events[len(events)-1]
+	merged := &DataChangeCallBackData{
+		TransID:       last.TransID,
+		GroupHandle:   last.GroupHandle,
+		MasterQuality: last.MasterQuality,
+		MasterErr:     last.MasterErr,
+	}
+	index := make(map[uint32]int)
+	for _, e := range events {
+		for i, handle := range e.ItemClientHandles {
+			j, ok := index[handle]
+			if !ok {
+				j = len(merged.ItemClientHandles)
+				index[handle] = j
+				merged.ItemClientHandles = append(merged.ItemClientHandles, handle)
+				merged.Values = append(merged.Values, nil)
+				merged.Qualities = append(merged.Qualities, 0)
+				merged.TimeStamps = append(merged.TimeStamps, time.Time{})
+				merged.Errors = append(merged.Errors, nil)
+			}
+			if i < len(e.Values) {
+				merged.Values[j] = e.Values[i]
+			}
+			if i < len(e.Qualities) {
+				merged.Qualities[j] = e.Qualities[i]
+			}
+			if i < len(e.TimeStamps) {
+				merged.TimeStamps[j] = e.TimeStamps[i]
+			}
+			if i < len(e.Errors) {
+				merged.Errors[j] = e.Errors[i]
+			}
+		}
+	}
+	return merged
+}
+
+// deliverAll sends an event to every subscriber of a list, list is copied under lock so that a blocking subscriber
+// does not hold up registrations
+func deliverAll[T any](ctx context.Context, g *OPCGroup, list *[]*subscriber[T], event T, merge func(events []T) T) {
+	g.subscriberLock.Lock()
+	subscribers := append([]*subscriber[T](nil), *list...)
+	g.subscriberLock.Unlock()
+	for _, s := range subscribers {
+		s.deliver(ctx, &g.deliveryStats, event, merge)
+	}
+}
@@ -0,0 +1,143 @@
+package opcda_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deliveryGroup returns a group of a simulated server without items and a function that registers a data change
+// channel and returns the data callback of the group to fire synthetic callbacks
+func deliveryGroup(t *testing.T) (*opcda.OPCGroup, func(ch chan *opcda.DataChangeCallBackData, opts ...opcda.DeliveryOption) opcda.DataCallback) {
+	captured := &capturedCallback{}
+	_, server := connectSim(t, withDecorator(wrapTransport(nil, captured.wrap)))
+	group := addGroup(t, server, "delivery")
+	return group, func(ch chan *opcda.DataChangeCallBackData, opts ...opcda.DeliveryOption) opcda.DataCallback {
+		require.NoError(t, group.RegisterDataChange(ch, opts...))
+		callback := captured.get()
+		require.NotNil(t, callback)
+		return callback
+	}
+}
+
+func dataChange(transID uint32, handle uint32, value float64) *opcda.CDataChangeCallBackData {
+	return &opcda.CDataChangeCallBackData{
+		TransID:           transID,
+		ItemClientHandles: []uint32{handle},
+		Values:            []interface{}{value},
+		Qualities:         []com.Quality{com.QualityGood},
+		TimeStamps:        []time.Time{time.Unix(int64(transID), 0)},
+		Errors:            []int32{0},
+	}
+}
+
+func waitStats(t *testing.T, group *opcda.OPCGroup, want opcda.DeliveryStats) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return group.GetDeliveryStats() == want
+	}, 5*time.Second, time.Millisecond, "%+v", group.GetDeliveryStats())
+}
+
+func receiveTransIDs(ch chan *opcda.DataChangeCallBackData) []uint32 {
+	var ids []uint32
+	for {
+		select {
+		case data := <-ch:
+			ids = append(ids, data.TransID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestDeliveryDropNewest(t *testing.T) {
+	group, register := deliveryGroup(t)
+	ch := make(chan *opcda.DataChangeCallBackData, 1)
+	callback := register(ch)
+	for i := uint32(1); i <= 3; i++ {
+		callback.OnDataChange(dataChange(i, 1, float64(i)))
+	}
+	waitStats(t, group, opcda.DeliveryStats{Dropped: 2})
+	assert.Equal(t, []uint32{1}, receiveTransIDs(ch))
+}
+
+func TestDeliveryDropOldest(t *testing.T) {
+	group, register := deliveryGroup(t)
+	ch := make(chan *opcda.DataChangeCallBackData, 2)
+	callback := register(ch, opcda.WithDeliveryPolicy(opcda.DropOldest))
+	for i := uint32(1); i <= 5; i++ {
+		callback.OnDataChange(dataChange(i, 1, float64(i)))
+	}
+	waitStats(t, group, opcda.DeliveryStats{Dropped: 3})
+	assert.Equal(t, []uint32{4, 5}, receiveTransIDs(ch))
+}
+
+func TestDeliveryCoalesce(t *testing.T) {
+	group, register := deliveryGroup(t)
+	ch := make(chan *opcda.DataChangeCallBackData, 2)
+	callback := register(ch, opcda.WithDeliveryPolicy(opcda.Coalesce))
+	callback.OnDataChange(dataChange(1, 1, 1))
+	callback.OnDataChange(dataChange(2, 2, 2))
+	callback.OnDataChange(dataChange(3, 1, 3))
+	waitStats(t, group, opcda.DeliveryStats{Coalesced: 2})
+	data := <-ch
+	assert.Equal(t, uint32(3), data.TransID)
+	assert.Equal(t, []uint32{1, 2}, data.ItemClientHandles)
+	assert.Equal(t, []interface{}{3.0, 2.0}, data.Values)
+	assert.Equal(t, []time.Time{time.Unix(3, 0), time.Unix(2, 0)}, data.TimeStamps)
+	assert.Equal(t, []error{nil, nil}, data.Errors)
+	assert.Empty(t, ch)
+}
+
+func TestDeliveryBlock(t *testing.T) {
+	group, register := deliveryGroup(t)
+	ch := make(chan *opcda.DataChangeCallBackData, 1)
+	callback := register(ch, opcda.WithDeliveryPolicy(opcda.Block), opcda.WithBlockTimeout(time.Second))
+	// a slow consumer gets every event
+	received := make(chan []uint32)
+	go func() {
+		var ids []uint32
+		for len(ids) < 4 {
+			time.Sleep(20 * time.Millisecond)
+			ids = append(ids, (<-ch).TransID)
+		}
+		received <- ids
+	}()
+	for i := uint32(1); i <= 4; i++ {
+		callback.OnDataChange(dataChange(i, 1, float64(i)))
+	}
+	select {
+	case ids := <-received:
+		assert.Equal(t, []uint32{1, 2, 3, 4}, ids)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	assert.Equal(t, opcda.DeliveryStats{}, group.GetDeliveryStats())
+
+	// without a consumer the event times out
+	callback.OnDataChange(dataChange(5, 1, 5))
+	callback.OnDataChange(dataChange(6, 1, 6))
+	waitStats(t, group, opcda.DeliveryStats{Dropped: 1})
+	assert.Equal(t, []uint32{5}, receiveTransIDs(ch))
+}
+
+func TestDeliveryCancelCompleteDoesNotStall(t *testing.T) {
+	group, register := deliveryGroup(t)
+	cancelComplete := make(chan *opcda.CancelCompleteCallBackData)
+	require.NoError(t, group.RegisterCancelComplete(cancelComplete))
+	ch := make(chan *opcda.DataChangeCallBackData, 1)
+	callback := register(ch)
+	callback.OnCancelComplete(&opcda.CCancelCompleteCallBackData{TransID: 1})
+	callback.OnDataChange(dataChange(2, 1, 2))
+	select {
+	case data := <-ch:
+		assert.Equal(t, uint32(2), data.TransID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the unread cancel complete stalled the group")
+	}
+	waitStats(t, group, opcda.DeliveryStats{Dropped: 1})
+}
@@ -99,7 +99,7 @@ func loop(itemList []*opcda.OPCItem, ch chan *opcda.ReadCompleteCallBackData, fi
 						tag = item.GetItemID()
 					}
 				}
-				log.Printf("%s:\t%s\t%d\t%v\n", tag, data.TimeStamps[i], data.Qualities[i], data.Values[i])
+				log.Printf("%s:\t%s\t%s\t%v\n", tag, data.TimeStamps[i], data.Qualities[i], data.Values[i])
 			}
 			close(finishChan)
 			return
@@ -73,7 +73,7 @@ func main() {
 	}
 	for i, item := range status {
 		if resultErrs[i] == nil {
-			log.Printf("%s:\t%s\t%d\t%v\n", tags[i], item.Timestamp, item.Quality, item.Value)
+			log.Printf("%s:\t%s\t%s\t%v\n", tags[i], item.Timestamp, item.Quality, item.Value)
 		} else {
 			log.Printf("%s: error %v", tags[i], resultErrs[i])
 		}
@@ -85,7 +85,7 @@ func main() {
 		if err != nil {
 			log.Printf("%s: error %v\n", tags[i], err)
 		} else {
-			log.Printf("%s:\t%s\t%d\t%v\n", tags[i], timestamp, quality, value)
+			log.Printf("%s:\t%s\t%s\t%v\n", tags[i], timestamp, quality, value)
 		}
 	}
 }
@@ -71,7 +71,7 @@ func main() {
 							tag = item.GetItemID()
 						}
 					}
-					log.Printf("item %s\ttimestamp: %s\tquality: %d\tvalue: %v\n", tag, data.TimeStamps[i], data.Qualities[i], data.Values[i])
+					log.Printf("item %s\ttimestamp: %s\tquality: %s\tvalue: %v\n", tag, data.TimeStamps[i], data.Qualities[i], data.Values[i])
 				}
 			case <-timer.C:
 				log.Printf("stop\n")
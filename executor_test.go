@@ -0,0 +1,89 @@
+package opcda_test
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/opcsim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goroutineID returns the ID of the calling goroutine from its stack header
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	id, _ := strconv.ParseUint(string(buf[:bytes.IndexByte(buf, ' ')]), 10, 64)
+	return id
+}
+
+// goroutineRecorder records the goroutines the backend calls of a server run on
+type goroutineRecorder struct {
+	opcda.ServerBackend
+	lock       sync.Mutex
+	goroutines map[string]uint64
+}
+
+func (r *goroutineRecorder) record(method string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.goroutines[method] = goroutineID()
+}
+
+func (r *goroutineRecorder) Release() {
+	r.record("IOPCServer.Release")
+	r.ServerBackend.Release()
+}
+
+func TestWithExecutor(t *testing.T) {
+	executor, err := com.NewExecutor()
+	require.NoError(t, err)
+	defer executor.Close()
+	var worker uint64
+	require.NoError(t, executor.Do(func() error {
+		worker = goroutineID()
+		return nil
+	}))
+
+	sim := newSim(t)
+	recorder := &goroutineRecorder{goroutines: make(map[string]uint64)}
+	transport := opcda.InterceptTransport(opcda.TransportFunc(func(progID, node string, credentials *opcda.Credentials) (opcda.ServerBackend, error) {
+		backend, err := sim.Connect(progID, node, credentials)
+		recorder.ServerBackend = backend
+		return recorder, err
+	}), func(method string, invoke func() error) error {
+		recorder.record(method)
+		return invoke()
+	})
+	server, err := opcda.Connect(opcsim.DefaultProgID, "", opcda.WithTransport(transport), opcda.WithExecutor(executor))
+	require.NoError(t, err)
+	group := addGroup(t, server, "executor")
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	require.NoError(t, item.Write(4.5))
+	value, _, _, err := item.Read(opcda.OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, 4.5, value)
+	require.NoError(t, server.Disconnect())
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	assert.Contains(t, recorder.goroutines, "Transport.Connect")
+	assert.Contains(t, recorder.goroutines, "IOPCSyncIO.Read")
+	assert.Contains(t, recorder.goroutines, "IOPCServer.Release")
+	for method, goroutine := range recorder.goroutines {
+		assert.Equal(t, worker, goroutine, method)
+	}
+	// the executor given to Connect is not closed by Disconnect
+	assert.NoError(t, executor.Do(func() error { return nil }))
+
+	executor.Close()
+	_, err = opcda.Connect(opcsim.DefaultProgID, "", opcda.WithTransport(sim), opcda.WithExecutor(executor))
+	assert.ErrorIs(t, err, com.ErrExecutorClosed)
+}
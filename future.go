@@ -0,0 +1,234 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+// ErrTransactionCancelled is the error of a Future whose transaction was cancelled, it wraps the error of the context
+// that cancelled it
+var ErrTransactionCancelled = errors.New("the transaction was cancelled")
+
+// ErrGroupReleased is the error of a Future whose group was released before its transaction completed
+var ErrGroupReleased = errors.New("the group was released")
+
+// futureTransactionBase is the first transaction ID allocated to futures, the IDs passed to AsyncRead and the like
+// should stay below it to be told apart
+const futureTransactionBase = 0x80000000
+
+// Future is the result of an asynchronous transaction, it resolves with the completion callback of the transaction
+type Future[T any] struct {
+	transactionID uint32
+	cancelID      uint32
+	once          sync.Once
+	done          chan struct{}
+	value         T
+	err           error
+	// lock guards cause, the error of the context that cancelled the transaction
+	lock  sync.Mutex
+	cause error
+}
+
+// transaction is a pending Future of a group
+type transaction interface {
+	complete(data interface{})
+	cancelComplete()
+	abort(err error)
+}
+
+// GetTransactionID get the client transaction ID of the transaction
+func (f *Future[T]) GetTransactionID() uint32 {
+	return f.transactionID
+}
+
+// GetCancelID get the cancel ID the server returned for the transaction, zero when no transaction was started
+func (f *Future[T]) GetCancelID() uint32 {
+	return f.cancelID
+}
+
+// Done returns a channel that is closed when the future resolves
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits until the future resolves and returns its result, or until ctx is done and returns its error. ctx only
+// bounds the wait, it does not cancel the transaction.
+func (f *Future[T]) Wait(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// resolve sets the result of the future once
+func (f *Future[T]) resolve(value T, err error) {
+	f.once.Do(func() {
+		f.value, f.err = value, err
+		close(f.done)
+	})
+}
+
+func (f *Future[T]) complete(data interface{}) {
+	if value, ok := data.(T); ok {
+		f.resolve(value, nil)
+	}
+}
+
+func (f *Future[T]) cancelComplete() {
+	f.lock.Lock()
+	cause := f.cause
+	f.lock.Unlock()
+	var zero T
+	if cause == nil {
+		// the transaction was cancelled with its cancel ID
+		f.resolve(zero, ErrTransactionCancelled)
+		return
+	}
+	f.resolve(zero, fmt.Errorf("%w: %w", ErrTransactionCancelled, cause))
+}
+
+func (f *Future[T]) abort(err error) {
+	var zero T
+	f.resolve(zero, err)
+}
+
+// AsyncReadFuture reads items like AsyncRead with a transaction ID of its own, the Future resolves with the read
+// complete callback of the transaction. When ctx is done first, the transaction is cancelled with AsyncCancel and the
+// Future resolves with ErrTransactionCancelled on the cancel complete callback, or at once when AsyncCancel fails.
+// When every item fails no transaction is started and the Future is resolved with a nil result.
+func (g *OPCGroup) AsyncReadFuture(ctx context.Context, serverHandles []uint32) (future *Future[*ReadCompleteCallBackData], errs []error, err error) {
+	future, err = startTransaction[*ReadCompleteCallBackData](ctx, g, func(transactionID uint32) (cancelID uint32, started bool, err error) {
+		cancelID, errs, err = g.AsyncRead(serverHandles, transactionID)
+		return cancelID, anySucceeded(errs), err
+	})
+	return future, errs, err
+}
+
+// AsyncWriteFuture writes items like AsyncWrite with a transaction ID of its own, the Future resolves with the write
+// complete callback of the transaction. Cancellation and failed items are handled like AsyncReadFuture.
+func (g *OPCGroup) AsyncWriteFuture(ctx context.Context, serverHandles []uint32, values []interface{}) (future *Future[*WriteCompleteCallBackData], errs []error, err error) {
+	future, err = startTransaction[*WriteCompleteCallBackData](ctx, g, func(transactionID uint32) (cancelID uint32, started bool, err error) {
+		cancelID, errs, err = g.AsyncWrite(serverHandles, values, transactionID)
+		return cancelID, anySucceeded(errs), err
+	})
+	return future, errs, err
+}
+
+// AsyncRefreshFuture refreshes the active items like AsyncRefresh with a transaction ID of its own, the Future resolves
+// with the data change callback of the transaction. Cancellation is handled like AsyncReadFuture.
+func (g *OPCGroup) AsyncRefreshFuture(ctx context.Context, source com.OPCDATASOURCE) (*Future[*DataChangeCallBackData], error) {
+	return startTransaction[*DataChangeCallBackData](ctx, g, func(transactionID uint32) (cancelID uint32, started bool, err error) {
+		cancelID, err = g.AsyncRefresh(source, transactionID)
+		return cancelID, true, err
+	})
+}
+
+// anySucceeded reports whether an item of a call succeeded
+func anySucceeded(errs []error) bool {
+	for _, err := range errs {
+		if err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// startTransaction starts a transaction with call and returns its Future. The data callback stays advised until the
+// Future resolves.
+func startTransaction[T any](ctx context.Context, g *OPCGroup, call func(transactionID uint32) (cancelID uint32, started bool, err error)) (*Future[T], error) {
+	f := &Future[T]{
+		transactionID: futureTransactionBase | atomic.AddUint32(&g.transactionID, 1),
+		done:          make(chan struct{}),
+	}
+	err := g.acquire(func() {
+		g.transactionLock.Lock()
+		defer g.transactionLock.Unlock()
+		if g.transactions == nil {
+			g.transactions = make(map[uint32]transaction)
+		}
+		g.transactions[f.transactionID] = f
+	})
+	if err != nil {
+		return nil, err
+	}
+	finish := func() {
+		g.release(func() {
+			g.transactionLock.Lock()
+			defer g.transactionLock.Unlock()
+			delete(g.transactions, f.transactionID)
+		})
+	}
+	cancelID, started, err := call(f.transactionID)
+	if err != nil {
+		finish()
+		return nil, err
+	}
+	if !started {
+		finish()
+		f.abort(nil)
+		return f, nil
+	}
+	f.cancelID = cancelID
+	go func() {
+		defer finish()
+		select {
+		case <-f.done:
+			return
+		case <-ctx.Done():
+		}
+		f.lock.Lock()
+		f.cause = ctx.Err()
+		f.lock.Unlock()
+		// when the server cannot cancel the transaction anymore it completes it, when the cancel fails no callback may
+		// resolve the future
+		if err := g.AsyncCancel(cancelID); err != nil {
+			var zero T
+			f.resolve(zero, fmt.Errorf("%w: %w: %w", ErrTransactionCancelled, ctx.Err(), err))
+			return
+		}
+		<-f.done
+	}()
+	return f, nil
+}
+
+// transaction returns the pending transaction with an ID
+func (g *OPCGroup) transaction(transactionID uint32) transaction {
+	g.transactionLock.Lock()
+	defer g.transactionLock.Unlock()
+	return g.transactions[transactionID]
+}
+
+// completeTransaction resolves the Future of a transaction with its completion callback
+func (g *OPCGroup) completeTransaction(transactionID uint32, data interface{}) {
+	if t := g.transaction(transactionID); t != nil {
+		t.complete(data)
+	}
+}
+
+// cancelTransaction resolves the Future of a cancelled transaction
+func (g *OPCGroup) cancelTransaction(transactionID uint32) {
+	if t := g.transaction(transactionID); t != nil {
+		t.cancelComplete()
+	}
+}
+
+// abortTransactions resolves the Futures of every pending transaction with err
+func (g *OPCGroup) abortTransactions(err error) {
+	g.transactionLock.Lock()
+	pending := make([]transaction, 0, len(g.transactions))
+	for _, t := range g.transactions {
+		pending = append(pending, t)
+	}
+	g.transactionLock.Unlock()
+	for _, t := range pending {
+		t.abort(err)
+	}
+}
@@ -0,0 +1,138 @@
+package opcda_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/opcsim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func futureGroup(t *testing.T, opts ...simOption) (*opcsim.Server, *opcda.OPCGroup, *opcda.OPCItem) {
+	sim, server := connectSim(t, opts...)
+	group := addGroup(t, server, "future")
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	return sim, group, item
+}
+
+func TestFuture(t *testing.T) {
+	sim, group, item := futureGroup(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	write, errs, err := group.AsyncWriteFuture(ctx, []uint32{item.GetServerHandle()}, []interface{}{2.5})
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	written, err := write.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, write.GetTransactionID(), written.TransID)
+	assert.Equal(t, []uint32{item.GetClientHandle()}, written.ItemClientHandles)
+	assert.Equal(t, []error{nil}, written.Errors)
+	stored, _, _, err := sim.Value("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	assert.Equal(t, 2.5, stored)
+
+	read, errs, err := group.AsyncReadFuture(ctx, []uint32{item.GetServerHandle(), 0xffff})
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	assertItemError(t, opcda.OPCInvalidHandle, errs[1])
+	data, err := read.Wait(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, write.GetTransactionID(), read.GetTransactionID())
+	assert.Equal(t, read.GetTransactionID(), data.TransID)
+	assert.Equal(t, []interface{}{2.5}, data.Values)
+
+	refresh, err := group.AsyncRefreshFuture(ctx, opcda.OPC_DS_CACHE)
+	require.NoError(t, err)
+	changed, err := refresh.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, refresh.GetTransactionID(), changed.TransID)
+	assert.Equal(t, []uint32{item.GetClientHandle()}, changed.ItemClientHandles)
+
+	// no transaction is started when every item fails
+	read, errs, err = group.AsyncReadFuture(ctx, []uint32{0xffff})
+	require.NoError(t, err)
+	assertItemError(t, opcda.OPCInvalidHandle, errs[0])
+	data, err = read.Wait(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, data)
+	assert.Equal(t, uint32(0), read.GetCancelID())
+}
+
+func TestFutureCancel(t *testing.T) {
+	sim, group, item := futureGroup(t, withSimOptions(opcsim.WithLatency(time.Second)))
+	cancelComplete := make(chan *opcda.CancelCompleteCallBackData, 1)
+	require.NoError(t, group.RegisterCancelComplete(cancelComplete))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	write, _, err := group.AsyncWriteFuture(ctx, []uint32{item.GetServerHandle()}, []interface{}{7.0})
+	require.NoError(t, err)
+	cancel()
+	_, err = write.Wait(context.Background())
+	assert.ErrorIs(t, err, opcda.ErrTransactionCancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+	select {
+	case data := <-cancelComplete:
+		assert.Equal(t, write.GetTransactionID(), data.TransID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	time.Sleep(1500 * time.Millisecond)
+	stored, _, _, err := sim.Value("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	assert.NotEqual(t, 7.0, stored)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	read, _, err := group.AsyncReadFuture(ctx, []uint32{item.GetServerHandle()})
+	require.NoError(t, err)
+	_, err = read.Wait(context.Background())
+	assert.ErrorIs(t, err, opcda.ErrTransactionCancelled)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFutureCancelFailure(t *testing.T) {
+	errCancel := errors.New("cancel")
+	_, group, item := futureGroup(t, withSimOptions(opcsim.WithLatency(5*time.Second)), withDecorator(wrapTransport(func(method string, invoke func() error) error {
+		if method == "IOPCAsyncIO2.Cancel2" {
+			return errCancel
+		}
+		return invoke()
+	}, nil)))
+
+	// the future resolves with the error of the cancel instead of waiting for the transaction
+	ctx, cancel := context.WithCancel(context.Background())
+	read, _, err := group.AsyncReadFuture(ctx, []uint32{item.GetServerHandle()})
+	require.NoError(t, err)
+	cancel()
+	wait, cancelWait := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelWait()
+	_, err = read.Wait(wait)
+	assert.ErrorIs(t, err, opcda.ErrTransactionCancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, errCancel)
+}
+
+func TestFutureRelease(t *testing.T) {
+	_, group, item := futureGroup(t, withSimOptions(opcsim.WithLatency(time.Minute)))
+	groups := group.GetParent()
+	read, _, err := group.AsyncReadFuture(context.Background(), []uint32{item.GetServerHandle()})
+	require.NoError(t, err)
+	waitCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = read.Wait(waitCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, groups.Remove(group.GetServerHandle()))
+	select {
+	case <-read.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	_, err = read.Wait(context.Background())
+	assert.ErrorIs(t, err, opcda.ErrGroupReleased)
+}
@@ -0,0 +1,229 @@
+package opcda
+
+import (
+	"context"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+// HealthEventType is the kind of a HealthEvent
+type HealthEventType int
+
+const (
+	// HealthStateChanged is sent on the first successful poll and when the server state changes
+	HealthStateChanged HealthEventType = iota + 1
+	// HealthUnreachable is sent when GetStatus starts failing, the next successful poll sends HealthStateChanged
+	HealthUnreachable
+	// HealthStale is sent when LastUpdateTime falls behind CurrentTime by more than the stale limit
+	HealthStale
+	// HealthFresh is sent when a stale server updates again
+	HealthFresh
+	// HealthClockSkew is sent when CurrentTime differs from the local clock by more than the skew limit
+	HealthClockSkew
+	// HealthClockSynced is sent when the skew is back within the limit
+	HealthClockSynced
+)
+
+func (t HealthEventType) String() string {
+	switch t {
+	case HealthStateChanged:
+		return "StateChanged"
+	case HealthUnreachable:
+		return "Unreachable"
+	case HealthStale:
+		return "Stale"
+	case HealthFresh:
+		return "Fresh"
+	case HealthClockSkew:
+		return "ClockSkew"
+	case HealthClockSynced:
+		return "ClockSynced"
+	}
+	return "Unknown"
+}
+
+// HealthEvent is a transition reported by Watch
+type HealthEvent struct {
+	Type HealthEventType
+	// Time is the local time of the poll
+	Time time.Time
+	// Status is the polled status, nil for HealthUnreachable
+	Status *com.ServerStatus
+	// PreviousState is zero when the state was unknown
+	PreviousState com.OPCServerState
+	State         com.OPCServerState
+	// Err is the GetStatus error of HealthUnreachable
+	Err error
+	// Skew is the server CurrentTime minus the local time
+	Skew time.Duration
+	// Staleness is the server CurrentTime minus LastUpdateTime
+	Staleness time.Duration
+}
+
+// WatchOption configures Watch
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	clock       Clock
+	staleAfter  time.Duration
+	maxSkew     time.Duration
+	pollTimeout time.Duration
+}
+
+// WithClock sets the clock that drives the polls and is compared to the server time
+func WithClock(clock Clock) WatchOption {
+	return func(o *watchOptions) {
+		o.clock = clock
+	}
+}
+
+// WithStaleAfter reports the server as stale when LastUpdateTime is older than d, zero disables the check.
+// Servers only update LastUpdateTime while they have active groups. The check is disabled by default.
+func WithStaleAfter(d time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.staleAfter = d
+	}
+}
+
+// WithMaxClockSkew reports a clock skew when the server time and the local time differ by more than d,
+// zero disables the check. The default is 30 seconds.
+func WithMaxClockSkew(d time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.maxSkew = d
+	}
+}
+
+// WithPollTimeout sets how long a GetStatus poll may take before the server is reported unreachable, the late answer
+// is then abandoned like with GetStatusContext. Zero waits without limit. The default is the interval of Watch.
+func WithPollTimeout(d time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.pollTimeout = d
+	}
+}
+
+// defaultWatchInterval is the interval of Watch when the given one is not positive
+const defaultWatchInterval = 10 * time.Second
+
+// Watch polls GetStatus every interval, at once first, and sends the health transitions on the returned channel.
+// An interval that is not positive is replaced by ten seconds. The channel is closed when ctx is done, also while a
+// poll waits for the server.
+func (s *OPCServer) Watch(ctx context.Context, interval time.Duration, opts ...WatchOption) <-chan *HealthEvent {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	options := &watchOptions{
+		clock:       SystemClock(),
+		maxSkew:     30 * time.Second,
+		pollTimeout: interval,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	ch := make(chan *HealthEvent, 16)
+	go func() {
+		defer close(ch)
+		ticker := options.clock.NewTicker(interval)
+		defer ticker.Stop()
+		w := &healthWatcher{options: options}
+		for {
+			events := w.poll(func() (*com.ServerStatus, error) {
+				return s.pollStatus(ctx, options.pollTimeout)
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			for _, event := range events {
+				select {
+				case ch <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+			}
+		}
+	}()
+	return ch
+}
+
+// pollStatus calls GetStatus until ctx is done or timeout elapses, zero waits without limit
+func (s *OPCServer) pollStatus(ctx context.Context, timeout time.Duration) (*com.ServerStatus, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return s.GetStatusContext(ctx)
+}
+
+// healthWatcher turns successive polls into transitions
+type healthWatcher struct {
+	options     *watchOptions
+	state       com.OPCServerState
+	unreachable bool
+	stale       bool
+	skewed      bool
+}
+
+func (w *healthWatcher) poll(getStatus func() (*com.ServerStatus, error)) []*HealthEvent {
+	status, err := getStatus()
+	now := w.options.clock.Now()
+	if err != nil {
+		if w.unreachable {
+			return nil
+		}
+		w.unreachable = true
+		event := &HealthEvent{Type: HealthUnreachable, Time: now, PreviousState: w.state, Err: err}
+		w.state = 0
+		w.stale = false
+		w.skewed = false
+		return []*HealthEvent{event}
+	}
+	w.unreachable = false
+	// the events of a poll share the state before it
+	previousState := w.state
+	newEvent := func(t HealthEventType) *HealthEvent {
+		return &HealthEvent{
+			Type:          t,
+			Time:          now,
+			Status:        status,
+			PreviousState: previousState,
+			State:         status.ServerState,
+			Skew:          status.CurrentTime.Sub(now),
+			Staleness:     status.CurrentTime.Sub(status.LastUpdateTime),
+		}
+	}
+	var events []*HealthEvent
+	if status.ServerState != w.state {
+		events = append(events, newEvent(HealthStateChanged))
+		w.state = status.ServerState
+	}
+	if w.options.staleAfter > 0 && !status.LastUpdateTime.IsZero() {
+		stale := status.CurrentTime.Sub(status.LastUpdateTime) > w.options.staleAfter
+		if stale != w.stale {
+			w.stale = stale
+			if stale {
+				events = append(events, newEvent(HealthStale))
+			} else {
+				events = append(events, newEvent(HealthFresh))
+			}
+		}
+	}
+	if w.options.maxSkew > 0 {
+		skew := status.CurrentTime.Sub(now)
+		skewed := skew > w.options.maxSkew || skew < -w.options.maxSkew
+		if skewed != w.skewed {
+			w.skewed = skewed
+			if skewed {
+				events = append(events, newEvent(HealthClockSkew))
+			} else {
+				events = append(events, newEvent(HealthClockSynced))
+			}
+		}
+	}
+	return events
+}
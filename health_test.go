@@ -0,0 +1,233 @@
+package opcda_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/opcsim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manual clock, tick advances the time and fires the tickers
+type fakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	ch chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTicker) Stop() {}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(time.Duration) opcda.Ticker {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	t := &fakeTicker{ch: make(chan time.Time)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+func (c *fakeClock) tick(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	tickers := c.tickers
+	c.lock.Unlock()
+	for _, t := range tickers {
+		t.ch <- now
+	}
+}
+
+// scriptedStatus replaces GetStatus of a simulated server
+type scriptedStatus struct {
+	opcda.ServerBackend
+	lock   sync.Mutex
+	status *com.ServerStatus
+	err    error
+}
+
+func (s *scriptedStatus) GetStatus() (*com.ServerStatus, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	status := *s.status
+	return &status, nil
+}
+
+func (s *scriptedStatus) set(f func(status *com.ServerStatus), err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f(s.status)
+	s.err = err
+}
+
+func nextEvent(t *testing.T, ch <-chan *opcda.HealthEvent) *opcda.HealthEvent {
+	t.Helper()
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+		return nil
+	}
+}
+
+func TestWatch(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	scripted := &scriptedStatus{status: &com.ServerStatus{
+		ServerState:    opcda.OPC_STATUS_RUNNING,
+		CurrentTime:    start,
+		LastUpdateTime: start,
+	}}
+	_, server := connectSim(t, withDecorator(func(transport opcda.Transport) opcda.Transport {
+		return opcda.TransportFunc(func(progID, node string, credentials *opcda.Credentials) (opcda.ServerBackend, error) {
+			backend, err := transport.Connect(progID, node, credentials)
+			scripted.ServerBackend = backend
+			return scripted, err
+		})
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := server.Watch(ctx, time.Second, opcda.WithClock(clock), opcda.WithStaleAfter(10*time.Second), opcda.WithMaxClockSkew(5*time.Second))
+	event := nextEvent(t, events)
+	assert.Equal(t, opcda.HealthStateChanged, event.Type)
+	assert.Equal(t, com.OPCServerState(0), event.PreviousState)
+	assert.Equal(t, opcda.OPC_STATUS_RUNNING, event.State)
+	assert.Equal(t, start, event.Time)
+
+	// the server time follows the local time and the server is updating
+	scripted.set(func(status *com.ServerStatus) {
+		status.CurrentTime = start.Add(time.Second)
+		status.LastUpdateTime = start.Add(time.Second)
+		status.ServerState = opcda.OPC_STATUS_COMM_FAULT
+	}, nil)
+	clock.tick(time.Second)
+	event = nextEvent(t, events)
+	assert.Equal(t, opcda.HealthStateChanged, event.Type)
+	assert.Equal(t, opcda.OPC_STATUS_RUNNING, event.PreviousState)
+	assert.Equal(t, opcda.OPC_STATUS_COMM_FAULT, event.State)
+
+	// the server stops updating and its clock runs ahead
+	scripted.set(func(status *com.ServerStatus) {
+		status.CurrentTime = start.Add(20 * time.Second)
+	}, nil)
+	clock.tick(time.Second)
+	event = nextEvent(t, events)
+	assert.Equal(t, opcda.HealthStale, event.Type)
+	assert.Equal(t, 19*time.Second, event.Staleness)
+	event = nextEvent(t, events)
+	assert.Equal(t, opcda.HealthClockSkew, event.Type)
+	assert.Equal(t, 18*time.Second, event.Skew)
+
+	scripted.set(func(status *com.ServerStatus) {
+		status.CurrentTime = start.Add(3 * time.Second)
+		status.LastUpdateTime = start.Add(3 * time.Second)
+	}, nil)
+	clock.tick(time.Second)
+	assert.Equal(t, opcda.HealthFresh, nextEvent(t, events).Type)
+	assert.Equal(t, opcda.HealthClockSynced, nextEvent(t, events).Type)
+
+	// nothing changes, nothing is sent
+	clock.tick(0)
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %v", event.Type)
+	default:
+	}
+
+	errDown := errors.New("down")
+	scripted.set(func(*com.ServerStatus) {}, errDown)
+	clock.tick(time.Second)
+	event = nextEvent(t, events)
+	assert.Equal(t, opcda.HealthUnreachable, event.Type)
+	assert.Equal(t, opcda.OPC_STATUS_COMM_FAULT, event.PreviousState)
+	assert.ErrorIs(t, event.Err, errDown)
+	assert.Nil(t, event.Status)
+	clock.tick(time.Second)
+
+	scripted.set(func(*com.ServerStatus) {}, nil)
+	clock.tick(time.Second)
+	event = nextEvent(t, events)
+	assert.Equal(t, opcda.HealthStateChanged, event.Type)
+	assert.Equal(t, com.OPCServerState(0), event.PreviousState)
+
+	// the events of a poll report the state before it
+	scripted.set(func(status *com.ServerStatus) {
+		status.ServerState = opcda.OPC_STATUS_RUNNING
+		status.CurrentTime = start.Add(30 * time.Second)
+	}, nil)
+	clock.tick(time.Second)
+	for _, eventType := range []opcda.HealthEventType{opcda.HealthStateChanged, opcda.HealthStale, opcda.HealthClockSkew} {
+		event = nextEvent(t, events)
+		assert.Equal(t, eventType, event.Type)
+		assert.Equal(t, opcda.OPC_STATUS_COMM_FAULT, event.PreviousState)
+		assert.Equal(t, opcda.OPC_STATUS_RUNNING, event.State)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchSystemClock(t *testing.T) {
+	_, server := connectSim(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	event := nextEvent(t, server.Watch(ctx, time.Millisecond))
+	assert.Equal(t, opcda.HealthStateChanged, event.Type)
+	// an interval that is not positive is replaced by the default
+	assert.Equal(t, opcda.HealthStateChanged, nextEvent(t, server.Watch(ctx, 0)).Type)
+	assert.Equal(t, opcda.OPC_STATUS_RUNNING, event.State)
+	assert.Equal(t, "opcsim", event.Status.VendorInfo)
+	assert.Equal(t, "StateChanged", event.Type.String())
+}
+
+func TestWatchPollTimeout(t *testing.T) {
+	blocking := &blocker{}
+	sim, server := connectSim(t, withDecorator(wrapTransport(blocking.intercept, nil)))
+
+	// a server that stops answering is reported unreachable after the poll timeout
+	blocking.block("IOPCServer.GetStatus")
+	ctx, cancel := context.WithCancel(context.Background())
+	events := server.Watch(ctx, time.Hour, opcda.WithPollTimeout(50*time.Millisecond))
+	event := nextEvent(t, events)
+	assert.Equal(t, opcda.HealthUnreachable, event.Type)
+	assert.ErrorIs(t, event.Err, context.DeadlineExceeded)
+
+	// the channel is closed when ctx is done during a poll that waits without limit, the first connection is suspect
+	// until the abandoned poll returns
+	other, err := opcda.Connect(opcsim.DefaultProgID, "", opcda.WithTransport(wrapTransport(blocking.intercept, nil)(sim)))
+	require.NoError(t, err)
+	defer other.Disconnect()
+	events = other.Watch(ctx, time.Hour, opcda.WithPollTimeout(0))
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watch ignores ctx during a poll")
+	}
+	blocking.unblock()
+}
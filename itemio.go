@@ -0,0 +1,89 @@
+package opcda
+
+import (
+	"errors"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+// queryItemIO returns the IOPCItemIO of the server, the interface is kept once the query succeeds
+func (s *OPCServer) queryItemIO() (ItemIOBackend, error) {
+	s.queryLock.Lock()
+	defer s.queryLock.Unlock()
+	if s.iItemIO == nil {
+		itemIO, err := s.iServer.QueryItemIO()
+		if err != nil {
+			return nil, NewOPCWrapperError("query interface IOPCItemIO", err)
+		}
+		s.iItemIO = itemIO
+	}
+	return s.iItemIO, nil
+}
+
+// ReadItems reads items by item ID without a group, it requires the OPC DA 3.0 IOPCItemIO.
+// maxAges holds the accepted age of the cached value of each item in milliseconds, 0 reads from the device and
+// 0xFFFFFFFF from the cache, nil reads every item from the device. The state of a failed item is nil.
+func (s *OPCServer) ReadItems(itemIDs []string, maxAges []uint32) ([]*com.ItemState, []error, error) {
+	if maxAges == nil {
+		maxAges = make([]uint32, len(itemIDs))
+	}
+	if len(maxAges) != len(itemIDs) {
+		return nil, nil, errors.New("maxAges and itemIDs have different lengths")
+	}
+	itemIO, err := s.queryItemIO()
+	if err != nil {
+		return nil, nil, err
+	}
+	states, errs, err := itemIO.Read(itemIDs, maxAges)
+	if err != nil {
+		return nil, nil, err
+	}
+	return states, s.errors(errs), nil
+}
+
+// WriteItemsVQT writes the values of items by item ID without a group, it requires the OPC DA 3.0 IOPCItemIO.
+// qualities and timestamps are optional: the server keeps its own quality when qualities is nil and its own
+// timestamp when timestamps is nil or the timestamp of an item is zero.
+func (s *OPCServer) WriteItemsVQT(itemIDs []string, values []interface{}, qualities []com.Quality, timestamps []time.Time) ([]error, error) {
+	vqts, err := newItemVQTs(len(itemIDs), values, qualities, timestamps)
+	if err != nil {
+		return nil, err
+	}
+	itemIO, err := s.queryItemIO()
+	if err != nil {
+		return nil, err
+	}
+	errs, err := itemIO.WriteVQT(itemIDs, vqts)
+	if err != nil {
+		return nil, err
+	}
+	return s.errors(errs), nil
+}
+
+// newItemVQTs combines the values, qualities and timestamps of count items
+func newItemVQTs(count int, values []interface{}, qualities []com.Quality, timestamps []time.Time) ([]*com.ItemVQT, error) {
+	if len(values) != count {
+		return nil, errors.New("values and items have different lengths")
+	}
+	if qualities != nil && len(qualities) != count {
+		return nil, errors.New("qualities and items have different lengths")
+	}
+	if timestamps != nil && len(timestamps) != count {
+		return nil, errors.New("timestamps and items have different lengths")
+	}
+	vqts := make([]*com.ItemVQT, count)
+	for i := range vqts {
+		vqt := &com.ItemVQT{Value: values[i]}
+		if qualities != nil {
+			vqt.QualitySpecified = true
+			vqt.Quality = qualities[i]
+		}
+		if timestamps != nil && !timestamps[i].IsZero() {
+			vqt.TimestampSpecified = true
+			vqt.Timestamp = timestamps[i]
+		}
+		vqts[i] = vqt
+	}
+	return vqts, nil
+}
@@ -0,0 +1,79 @@
+package opcda_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/opcsim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadItems(t *testing.T) {
+	sim, server := connectSim(t)
+
+	require.NoError(t, sim.SetValue("Bucket Brigade.Int4", int32(12)))
+	states, errs, err := server.ReadItems([]string{"Bucket Brigade.Int4", "Unknown", "Write Only.Int4"}, nil)
+	require.NoError(t, err)
+	require.Len(t, states, 3)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, int32(12), states[0].Value)
+	assert.Equal(t, opcsim.QualityGood, states[0].Quality)
+	assert.Nil(t, states[1])
+	var opcErr *opcda.OPCError
+	require.ErrorAs(t, errs[1], &opcErr)
+	assert.Equal(t, int32(opcda.OPCUnknownItemID), opcErr.ErrorCode)
+	require.ErrorAs(t, errs[2], &opcErr)
+	assert.Equal(t, int32(opcda.OPCBadRights), opcErr.ErrorCode)
+
+	_, _, err = server.ReadItems([]string{"Bucket Brigade.Int4"}, []uint32{0, 0})
+	assert.Error(t, err)
+}
+
+func TestWriteItemsVQT(t *testing.T) {
+	sim, server := connectSim(t)
+
+	timestamp := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	errs, err := server.WriteItemsVQT(
+		[]string{"Bucket Brigade.Real8", "Write Only.Int4", "Bucket Brigade.Int2"},
+		[]interface{}{1.25, int32(5), "not a number"},
+		[]com.Quality{opcsim.QualityOutOfService, opcsim.QualityGood, opcsim.QualityGood},
+		[]time.Time{timestamp, {}, {}},
+	)
+	require.NoError(t, err)
+	require.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Error(t, errs[2])
+	value, quality, ts, err := sim.Value("Bucket Brigade.Real8")
+	require.NoError(t, err)
+	assert.Equal(t, 1.25, value)
+	assert.Equal(t, opcsim.QualityOutOfService, quality)
+	assert.True(t, ts.Equal(timestamp))
+	value, _, _, err = sim.Value("Write Only.Int4")
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), value)
+
+	states, errs2, err := server.ReadItems([]string{"Bucket Brigade.Real8"}, []uint32{0xFFFFFFFF})
+	require.NoError(t, err)
+	assert.NoError(t, errs2[0])
+	assert.Equal(t, opcsim.QualityOutOfService, states[0].Quality)
+	assert.True(t, states[0].Timestamp.Equal(timestamp))
+
+	errs, err = server.WriteItemsVQT([]string{"Bucket Brigade.Int4"}, []interface{}{int32(8)}, nil, nil)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	_, err = server.WriteItemsVQT([]string{"Bucket Brigade.Int4"}, []interface{}{int32(8)}, []com.Quality{}, nil)
+	assert.Error(t, err)
+}
+
+func TestItemIONotSupported(t *testing.T) {
+	_, server := connectSim(t, withSimOptions(opcsim.WithoutDA3()))
+
+	_, _, err := server.ReadItems([]string{"Bucket Brigade.Int4"}, nil)
+	assert.Error(t, err)
+	_, err = server.WriteItemsVQT([]string{"Bucket Brigade.Int4"}, []interface{}{int32(1)}, nil, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StallEventType is the kind of a StallEvent
+type StallEventType int
+
+const (
+	// GroupStalled is sent when neither a data change nor a keep-alive callback arrived within the window
+	GroupStalled StallEventType = iota + 1
+	// GroupResumed is sent when a stalled group receives a callback again
+	GroupResumed
+)
+
+func (t StallEventType) String() string {
+	switch t {
+	case GroupStalled:
+		return "Stalled"
+	case GroupResumed:
+		return "Resumed"
+	}
+	return "Unknown"
+}
+
+// StallEvent is a transition reported by WatchStall
+type StallEvent struct {
+	Type StallEventType
+	// Time is the local time the transition was detected
+	Time time.Time
+	// LastCallback is the local time of the last callback, the start of the watch when none arrived
+	LastCallback time.Time
+}
+
+// WatchStall watches the data change and keep-alive callbacks of the group and sends a GroupStalled event when none
+// arrives within window, then a GroupResumed event with the next callback. A zero window uses twice the keep-alive
+// time of the group, which must be set then, a negative window is rejected. Only the WithClock option applies. The
+// channel is closed when ctx is done.
+func (g *OPCGroup) WatchStall(ctx context.Context, window time.Duration, opts ...WatchOption) (<-chan *StallEvent, error) {
+	options := &watchOptions{
+		clock: SystemClock(),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if window < 0 {
+		return nil, fmt.Errorf("invalid stall window %s", window)
+	}
+	if window == 0 {
+		keepAlive, err := g.GetKeepAlive()
+		if err != nil {
+			return nil, err
+		}
+		if keepAlive == 0 {
+			return nil, errors.New("the keep-alive time of the group is not set")
+		}
+		window = 2 * time.Duration(keepAlive) * time.Millisecond
+	}
+	// the window is checked four times per window, at least once for a window under four nanoseconds
+	interval := window / 4
+	if interval == 0 {
+		interval = window
+	}
+	activity := make(chan struct{}, 1)
+	err := g.acquire(func() {
+		g.activityLock.Lock()
+		defer g.activityLock.Unlock()
+		g.activityList = append(g.activityList, activity)
+	})
+	if err != nil {
+		return nil, err
+	}
+	ticker := options.clock.NewTicker(interval)
+	last := options.clock.Now()
+	ch := make(chan *StallEvent, 16)
+	go func() {
+		defer close(ch)
+		defer g.release(func() {
+			g.removeActivity(activity)
+		})
+		defer ticker.Stop()
+		stalled := false
+		for {
+			var event *StallEvent
+			select {
+			case <-ctx.Done():
+				return
+			case <-activity:
+				last = options.clock.Now()
+				if stalled {
+					stalled = false
+					event = &StallEvent{Type: GroupResumed, Time: last, LastCallback: last}
+				}
+			case <-ticker.C():
+				now := options.clock.Now()
+				if !stalled && now.Sub(last) > window {
+					stalled = true
+					event = &StallEvent{Type: GroupStalled, Time: now, LastCallback: last}
+				}
+			}
+			if event == nil {
+				continue
+			}
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// fireActivity wakes up the stall watchdogs
+func (g *OPCGroup) fireActivity() {
+	g.activityLock.Lock()
+	defer g.activityLock.Unlock()
+	for _, activity := range g.activityList {
+		select {
+		case activity <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (g *OPCGroup) removeActivity(activity chan struct{}) {
+	g.activityLock.Lock()
+	defer g.activityLock.Unlock()
+	for i, a := range g.activityList {
+		if a == activity {
+			g.activityList = append(g.activityList[:i], g.activityList[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package opcda_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nextStall(t *testing.T, ch <-chan *opcda.StallEvent) *opcda.StallEvent {
+	t.Helper()
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+		return nil
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	_, server := connectSim(t)
+	group := addGroup(t, server, "keep-alive")
+	assert.True(t, group.SupportsKeepAlive())
+	_, err := group.OPCItems().AddItem("Bucket Brigade.Int4")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = group.WatchStall(ctx, 0)
+	assert.EqualError(t, err, "the keep-alive time of the group is not set")
+	_, err = group.WatchStall(ctx, -time.Second)
+	assert.EqualError(t, err, "invalid stall window -1s")
+	short, cancelShort := context.WithCancel(ctx)
+	_, err = group.WatchStall(short, time.Nanosecond)
+	assert.NoError(t, err)
+	cancelShort()
+
+	revised, err := group.SetKeepAlive(20)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(20), revised)
+	keepAlive, err := group.GetKeepAlive()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(20), keepAlive)
+	ch := make(chan *opcda.DataChangeCallBackData, 10)
+	require.NoError(t, group.RegisterDataChange(ch))
+	events, err := group.WatchStall(ctx, 200*time.Millisecond)
+	require.NoError(t, err)
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	// the keep-alive callbacks keep the group alive and are not delivered as data changes
+	time.Sleep(500 * time.Millisecond)
+	assert.Empty(t, events)
+	assert.Empty(t, ch)
+
+	_, err = group.SetKeepAlive(0)
+	require.NoError(t, err)
+	event := nextStall(t, events)
+	assert.Equal(t, opcda.GroupStalled, event.Type)
+	assert.Equal(t, "Stalled", event.Type.String())
+	assert.True(t, event.Time.Sub(event.LastCallback) > 200*time.Millisecond)
+}
+
+func TestWatchStall(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	_, server := connectSim(t)
+	group := addGroup(t, server, "stall")
+	_, err := group.OPCItems().AddItem("Bucket Brigade.Int4")
+	require.NoError(t, err)
+	require.NoError(t, group.SetIsActive(false))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := group.WatchStall(ctx, time.Second, opcda.WithClock(clock))
+	require.NoError(t, err)
+	// an inactive group receives no callbacks
+	clock.tick(500 * time.Millisecond)
+	clock.tick(time.Second)
+	event := nextStall(t, events)
+	assert.Equal(t, opcda.GroupStalled, event.Type)
+	assert.Equal(t, start, event.LastCallback)
+	assert.Equal(t, start.Add(1500*time.Millisecond), event.Time)
+	clock.tick(time.Second)
+	assert.Empty(t, events)
+
+	require.NoError(t, group.SetIsActive(true))
+	event = nextStall(t, events)
+	assert.Equal(t, opcda.GroupResumed, event.Type)
+	assert.Equal(t, start.Add(2500*time.Millisecond), event.LastCallback)
+
+	cancel()
+	for range events {
+	}
+}
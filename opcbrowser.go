@@ -2,13 +2,12 @@ package opcda
 
 import (
 	"errors"
-	"unsafe"
 
 	"github.com/huskar-t/opcda/com"
 )
 
 type OPCBrowser struct {
-	iBrowseServerAddressSpace *com.IOPCBrowseServerAddressSpace
+	iBrowseServerAddressSpace BrowseServerAddressSpaceBackend
 	filter                    string
 	dataType                  uint16
 	accessRights              uint32
@@ -17,13 +16,12 @@ type OPCBrowser struct {
 }
 
 func NewOPCBrowser(parent *OPCServer) (*OPCBrowser, error) {
-	var iBrowseServerAddressSpace *com.IUnknown
-	err := parent.iServer.QueryInterface(&com.IID_IOPCBrowseServerAddressSpace, unsafe.Pointer(&iBrowseServerAddressSpace))
+	iBrowseServerAddressSpace, err := parent.iServer.QueryBrowseServerAddressSpace()
 	if err != nil {
 		return nil, NewOPCWrapperError("query interface IOPCBrowseServerAddressSpace", err)
 	}
 	return &OPCBrowser{
-		iBrowseServerAddressSpace: &com.IOPCBrowseServerAddressSpace{IUnknown: iBrowseServerAddressSpace},
+		iBrowseServerAddressSpace: iBrowseServerAddressSpace,
 		parent:                    parent,
 		accessRights:              OPC_READABLE | OPC_WRITEABLE,
 	}, nil
@@ -2,6 +2,8 @@ package opcda
 
 import (
 	"fmt"
+
+	"github.com/huskar-t/opcda/com"
 )
 
 type OPCError struct {
@@ -36,6 +38,13 @@ var opcErrors = map[int32]string{
 	int32(OPCInvalidConfig):   "The server's configuration file is an invalid format",
 	int32(OPCNotFound):        "Requested Object was not found",
 	int32(OPCInvalidPID):      "The passed property ID is not valid for the item",
+
+	int32(OPCDeadbandNotSet):           "The item deadband has not been set for this item",
+	int32(OPCDeadbandNotSupported):     "The item does not support deadband",
+	int32(OPCRateNotSet):               "There is no sampling rate set for the specified item",
+	int32(OPCInvalidContinuationPoint): "The continuation point is not valid",
+
+	com.BadVarType: "The value has a VARIANT type that cannot be decoded",
 }
 
 var (
@@ -55,6 +64,11 @@ var (
 	OPCInvalidConfig   = uint32(0xC0040010)
 	OPCNotFound        = uint32(0xC0040011)
 	OPCInvalidPID      = uint32(0xC0040203)
+
+	OPCDeadbandNotSet           = uint32(0xC0040400)
+	OPCDeadbandNotSupported     = uint32(0xC0040401)
+	OPCRateNotSet               = uint32(0xC0040402)
+	OPCInvalidContinuationPoint = uint32(0xC0040403)
 )
 
 type OPCWrapperError struct {
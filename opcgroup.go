@@ -2,77 +2,104 @@ package opcda
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"sync"
 	"time"
-	"unsafe"
 
 	"github.com/huskar-t/opcda/com"
 )
 
 type OPCGroup struct {
 	parent             *OPCGroups
-	groupStateMgt      *com.IOPCGroupStateMgt
-	syncIO             *com.IOPCSyncIO
-	asyncIO2           *com.IOPCAsyncIO2
-	iCommon            *com.IOPCCommon
+	groupStateMgt      GroupBackend
+	syncIO             SyncIOBackend
+	asyncIO2           AsyncIO2Backend
+	syncIO2            SyncIO2Backend
+	asyncIO3           AsyncIO3Backend
+	deadbandMgt        ItemDeadbandMgtBackend
+	samplingMgt        ItemSamplingMgtBackend
+	stateMgt2          GroupStateMgt2Backend
+	asyncIO2Err        error
+	syncIO2Err         error
+	asyncIO3Err        error
+	deadbandMgtErr     error
+	samplingMgtErr     error
+	stateMgt2Err       error
+	iCommon            CommonBackend
 	clientGroupHandle  uint32
 	serverGroupHandle  uint32
 	groupName          string
 	revisedUpdateRate  uint32
 	items              *OPCItems
 	callbackLock       sync.Mutex
-	container          *com.IConnectionPointContainer
-	point              *com.IConnectionPoint
-	event              *DataEventReceiver
+	advised            bool
+	released           bool
+	subscribers        int
 	cookie             uint32
 	ctx                context.Context
 	cancel             context.CancelFunc
-	dataChangeList     []chan *DataChangeCallBackData
-	readCompleteList   []chan *ReadCompleteCallBackData
-	writeCompleteList  []chan *WriteCompleteCallBackData
-	cancelCompleteList []chan *CancelCompleteCallBackData
+	dataChangeList     []*subscriber[*DataChangeCallBackData]
+	readCompleteList   []*subscriber[*ReadCompleteCallBackData]
+	writeCompleteList  []*subscriber[*WriteCompleteCallBackData]
+	cancelCompleteList []*subscriber[*CancelCompleteCallBackData]
+	// subscriberLock guards the subscriber lists
+	subscriberLock sync.Mutex
+	deliveryStats  deliveryStats
+	// activityLock guards activityList, the channels of the stall watchdogs
+	activityLock sync.Mutex
+	activityList []chan struct{}
+	// transactionLock guards transactions, the pending futures by transaction ID
+	transactionLock sync.Mutex
+	transactions    map[uint32]transaction
+	transactionID   uint32
 }
 
 func NewOPCGroup(
 	opcGroups *OPCGroups,
-	iUnknown *com.IUnknown,
+	groupBackend GroupBackend,
 	clientGroupHandle uint32,
 	serverGroupHandle uint32,
 	groupName string,
 	revisedUpdateRate uint32,
 ) (*OPCGroup, error) {
-	var iUnknownSyncIO *com.IUnknown
-	err := iUnknown.QueryInterface(&com.IID_IOPCSyncIO, unsafe.Pointer(&iUnknownSyncIO))
+	syncIO, err := groupBackend.QuerySyncIO()
 	if err != nil {
 		return nil, NewOPCWrapperError("query interface IOPCSyncIO", err)
 	}
-	var iUnknownAsyncIO2 *com.IUnknown
-	err = iUnknown.QueryInterface(&com.IID_IOPCAsyncIO2, unsafe.Pointer(&iUnknownAsyncIO2))
-	if err != nil {
-		iUnknownSyncIO.Release()
-		return nil, NewOPCWrapperError("query interface IOPCAsyncIO2", err)
+	// a transport that cannot receive callbacks has no IOPCAsyncIO2, the asynchronous methods then return its error
+	asyncIO2, asyncIO2Err := groupBackend.QueryAsyncIO2()
+	if asyncIO2Err != nil && !errors.Is(asyncIO2Err, ErrCallbackNotSupported) {
+		syncIO.Release()
+		return nil, NewOPCWrapperError("query interface IOPCAsyncIO2", asyncIO2Err)
 	}
-	var iUnknownItemMgt *com.IUnknown
-	err = iUnknown.QueryInterface(&com.IID_IOPCItemMgt, unsafe.Pointer(&iUnknownItemMgt))
+	itemMgt, err := groupBackend.QueryItemMgt()
 	if err != nil {
-		iUnknownSyncIO.Release()
-		iUnknownAsyncIO2.Release()
+		syncIO.Release()
+		if asyncIO2 != nil {
+			asyncIO2.Release()
+		}
 		return nil, NewOPCWrapperError("query interface IOPCItemMgt", err)
 	}
 
 	o := &OPCGroup{
 		parent:            opcGroups,
-		groupStateMgt:     &com.IOPCGroupStateMgt{IUnknown: iUnknown},
-		syncIO:            &com.IOPCSyncIO{IUnknown: iUnknownSyncIO},
-		asyncIO2:          &com.IOPCAsyncIO2{IUnknown: iUnknownAsyncIO2},
+		groupStateMgt:     groupBackend,
+		syncIO:            syncIO,
+		asyncIO2:          asyncIO2,
+		asyncIO2Err:       asyncIO2Err,
 		clientGroupHandle: clientGroupHandle,
 		serverGroupHandle: serverGroupHandle,
 		groupName:         groupName,
 		revisedUpdateRate: revisedUpdateRate,
 		iCommon:           opcGroups.iCommon,
 	}
-	o.items = NewOPCItems(o, &com.IOPCItemMgt{IUnknown: iUnknownItemMgt}, opcGroups.iCommon)
+	// the OPC DA 3.0 interfaces are optional, their methods return the query error when they are missing
+	o.syncIO2, o.syncIO2Err = groupBackend.QuerySyncIO2()
+	o.asyncIO3, o.asyncIO3Err = groupBackend.QueryAsyncIO3()
+	o.deadbandMgt, o.deadbandMgtErr = groupBackend.QueryItemDeadbandMgt()
+	o.samplingMgt, o.samplingMgtErr = groupBackend.QueryItemSamplingMgt()
+	o.stateMgt2, o.stateMgt2Err = groupBackend.QueryGroupStateMgt2()
+	o.items = NewOPCItems(o, itemMgt, opcGroups.iCommon)
 	return o, nil
 }
 
@@ -107,8 +134,7 @@ func (g *OPCGroup) GetIsActive() bool {
 
 // SetIsActive set whether the group is active
 func (g *OPCGroup) SetIsActive(isActive bool) error {
-	v := com.BoolToComBOOL(isActive)
-	_, err := g.groupStateMgt.SetState(nil, &v, nil, nil, nil, nil)
+	_, err := g.groupStateMgt.SetState(nil, &isActive, nil, nil, nil, nil)
 	return err
 }
 
@@ -186,6 +212,30 @@ func (g *OPCGroup) SetUpdateRate(updateRate uint32) error {
 	return err
 }
 
+// SupportsKeepAlive reports whether the server implements the OPC DA 3.0 IOPCGroupStateMgt2 used by SetKeepAlive and GetKeepAlive
+func (g *OPCGroup) SupportsKeepAlive() bool {
+	return g.stateMgt2 != nil
+}
+
+// GetKeepAlive get the keep-alive time of the group in milliseconds, zero when keep-alive callbacks are disabled.
+// It requires the OPC DA 3.0 IOPCGroupStateMgt2.
+func (g *OPCGroup) GetKeepAlive() (uint32, error) {
+	if g.stateMgt2 == nil {
+		return 0, NewOPCWrapperError("query interface IOPCGroupStateMgt2", g.stateMgt2Err)
+	}
+	return g.stateMgt2.GetKeepAlive()
+}
+
+// SetKeepAlive set the keep-alive time of the group in milliseconds, the server sends a data change without items
+// when no other data change was sent within that time. Zero disables the keep-alive callbacks.
+// It returns the keep-alive time revised by the server and requires the OPC DA 3.0 IOPCGroupStateMgt2.
+func (g *OPCGroup) SetKeepAlive(keepAliveTime uint32) (uint32, error) {
+	if g.stateMgt2 == nil {
+		return 0, NewOPCWrapperError("query interface IOPCGroupStateMgt2", g.stateMgt2Err)
+	}
+	return g.stateMgt2.SetKeepAlive(keepAliveTime)
+}
+
 // OPCItems A collection of OPCItem objects
 func (g *OPCGroup) OPCItems() *OPCItems {
 	return g.items
@@ -204,60 +254,53 @@ func (g *OPCGroup) SyncRead(source com.OPCDATASOURCE, serverHandles []uint32) ([
 			resultErrs[i] = g.getError(e)
 		}
 	}
-
+	g.items.convertStates(serverHandles, values, resultErrs)
 	return values, resultErrs, nil
 }
 
 // SyncWrite Writes values to one or more items in a group
 func (g *OPCGroup) SyncWrite(serverHandles []uint32, values []interface{}) ([]error, error) {
-	variants := make([]com.VARIANT, len(values))
-	variantWrappers := make([]*com.VariantWrapper, len(values))
-	defer func() {
-		for _, variant := range variantWrappers {
-			err := variant.Clear()
-			if err != nil {
-				fmt.Println(err)
-			}
-		}
-	}()
-	for i, v := range values {
-		variant, err := com.NewVariant(v)
-		if err != nil {
-			return nil, err
-		}
-		variantWrappers[i] = variant
-		variants[i] = *variant.Variant
-	}
-	errList, err := g.syncIO.Write(serverHandles, variants)
-	if err != nil {
-		return nil, err
-	}
-	errs := make([]error, len(errList))
-	for i, e := range errList {
-		if e < 0 {
-			errs[i] = g.getError(e)
-		}
-	}
-	return errs, nil
+	return g.writeConverted(serverHandles, values, func(serverHandles []uint32, values []interface{}, _ []int) ([]int32, error) {
+		return g.syncIO.Write(serverHandles, values)
+	})
 }
 
 // Release Releases the resources used by the group
 func (g *OPCGroup) Release() {
-	if g.event != nil {
-		g.point.Unadvise(g.cookie)
-		g.point.Release()
-		g.container.Release()
-		g.event = nil
-	}
-	if g.cancel != nil {
-		g.cancel()
-	}
+	// no subscriber or transaction is added once the group is released
+	g.callbackLock.Lock()
+	g.released = true
+	g.callbackLock.Unlock()
+	g.abortTransactions(ErrGroupReleased)
+	g.callbackLock.Lock()
+	g.unadvise()
+	g.callbackLock.Unlock()
 	g.items.Release()
 	g.groupStateMgt.Release()
 	g.syncIO.Release()
-	g.asyncIO2.Release()
+	if g.asyncIO2 != nil {
+		g.asyncIO2.Release()
+	}
+	if g.syncIO2 != nil {
+		g.syncIO2.Release()
+	}
+	if g.asyncIO3 != nil {
+		g.asyncIO3.Release()
+	}
+	if g.deadbandMgt != nil {
+		g.deadbandMgt.Release()
+	}
+	if g.samplingMgt != nil {
+		g.samplingMgt.Release()
+	}
+	if g.stateMgt2 != nil {
+		g.stateMgt2.Release()
+	}
 }
 
+// DataChangeCallBackData is a data change of a group, the item slices are parallel. An item with buffering enabled
+// appears once per buffered sample, in the order the samples were taken and with the timestamp of each sample.
+// Keep-alive callbacks carry no items and are not delivered, see WatchStall.
 type DataChangeCallBackData struct {
 	TransID           uint32
 	GroupHandle       uint32
@@ -265,49 +308,33 @@ type DataChangeCallBackData struct {
 	MasterErr         error
 	ItemClientHandles []uint32
 	Values            []interface{}
-	Qualities         []uint16
+	Qualities         []com.Quality
 	TimeStamps        []time.Time
 	Errors            []error
 }
 
-// RegisterDataChange Register to receive data change events
-func (g *OPCGroup) RegisterDataChange(ch chan *DataChangeCallBackData) error {
-	err := g.advise()
-	if err != nil {
-		return err
-	}
-	g.dataChangeList = append(g.dataChangeList, ch)
-	return nil
+// RegisterDataChange Register to receive data change events, opts decide what happens to an event when ch is full
+func (g *OPCGroup) RegisterDataChange(ch chan *DataChangeCallBackData, opts ...DeliveryOption) error {
+	_, err := subscribe(g, &g.dataChangeList, ch, opts)
+	return err
 }
 
-// RegisterReadComplete Register to receive read complete events
-func (g *OPCGroup) RegisterReadComplete(ch chan *ReadCompleteCallBackData) error {
-	err := g.advise()
-	if err != nil {
-		return err
-	}
-	g.readCompleteList = append(g.readCompleteList, ch)
-	return nil
+// RegisterReadComplete Register to receive read complete events, opts decide what happens to an event when ch is full
+func (g *OPCGroup) RegisterReadComplete(ch chan *ReadCompleteCallBackData, opts ...DeliveryOption) error {
+	_, err := subscribe(g, &g.readCompleteList, ch, opts)
+	return err
 }
 
-// RegisterWriteComplete Register to receive write complete events
-func (g *OPCGroup) RegisterWriteComplete(ch chan *WriteCompleteCallBackData) error {
-	err := g.advise()
-	if err != nil {
-		return err
-	}
-	g.writeCompleteList = append(g.writeCompleteList, ch)
-	return nil
+// RegisterWriteComplete Register to receive write complete events, opts decide what happens to an event when ch is full
+func (g *OPCGroup) RegisterWriteComplete(ch chan *WriteCompleteCallBackData, opts ...DeliveryOption) error {
+	_, err := subscribe(g, &g.writeCompleteList, ch, opts)
+	return err
 }
 
-// RegisterCancelComplete Register to receive cancel complete events
-func (g *OPCGroup) RegisterCancelComplete(ch chan *CancelCompleteCallBackData) error {
-	err := g.advise()
-	if err != nil {
-		return err
-	}
-	g.cancelCompleteList = append(g.cancelCompleteList, ch)
-	return nil
+// RegisterCancelComplete Register to receive cancel complete events, opts decide what happens to an event when ch is full
+func (g *OPCGroup) RegisterCancelComplete(ch chan *CancelCompleteCallBackData, opts ...DeliveryOption) error {
+	_, err := subscribe(g, &g.cancelCompleteList, ch, opts)
+	return err
 }
 
 type ReadCompleteCallBackData struct {
@@ -317,7 +344,7 @@ type ReadCompleteCallBackData struct {
 	MasterErr         error
 	ItemClientHandles []uint32
 	Values            []interface{}
-	Qualities         []uint16
+	Qualities         []com.Quality
 	TimeStamps        []time.Time
 	Errors            []error
 }
@@ -335,45 +362,42 @@ type CancelCompleteCallBackData struct {
 	GroupHandle uint32
 }
 
+// advise advises the data callback and starts the loop that delivers its events, the caller holds callbackLock
 func (g *OPCGroup) advise() (err error) {
-	g.callbackLock.Lock()
-	defer g.callbackLock.Unlock()
-	if g.event != nil {
+	if g.advised {
 		return nil
 	}
-	var iUnknownContainer *com.IUnknown
-	err = g.groupStateMgt.QueryInterface(&com.IID_IConnectionPointContainer, unsafe.Pointer(&iUnknownContainer))
-	if err != nil {
-		return NewOPCWrapperError("query interface IConnectionPointContainer", err)
-	}
-	defer func() {
-		if err != nil {
-			iUnknownContainer.Release()
-		}
-	}()
-	container := &com.IConnectionPointContainer{IUnknown: iUnknownContainer}
-	var point *com.IConnectionPoint
-	point, err = container.FindConnectionPoint(&IID_IOPCDataCallback)
-	if err != nil {
-		return
-	}
 	dataChangeCB := make(chan *CDataChangeCallBackData, 100)
 	readCB := make(chan *CReadCompleteCallBackData, 100)
 	writeCB := make(chan *CWriteCompleteCallBackData, 100)
 	cancelCB := make(chan *CCancelCompleteCallBackData, 100)
-	event := NewDataEventReceiver(dataChangeCB, readCB, writeCB, cancelCB)
-	var cookie uint32
-	cookie, err = point.Advise((*com.IUnknown)(unsafe.Pointer(event)))
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	callback := &channelDataCallback{
+		done:                   g.ctx.Done(),
+		dataChangeReceiver:     dataChangeCB,
+		readCompleteReceiver:   readCB,
+		writeCompleteReceiver:  writeCB,
+		cancelCompleteReceiver: cancelCB,
+	}
+	cookie, err := g.groupStateMgt.AdviseDataCallback(callback)
 	if err != nil {
-		return
+		g.cancel()
+		return err
 	}
-	g.ctx, g.cancel = context.WithCancel(context.Background())
 	go g.loop(g.ctx, dataChangeCB, readCB, writeCB, cancelCB)
-	g.container = container
-	g.point = point
-	g.event = event
+	g.advised = true
 	g.cookie = cookie
-	return
+	return nil
+}
+
+// unadvise unadvises the data callback and stops the loop, the caller holds callbackLock
+func (g *OPCGroup) unadvise() {
+	if !g.advised {
+		return
+	}
+	g.groupStateMgt.UnadviseDataCallback(g.cookie)
+	g.advised = false
+	g.cancel()
 }
 
 func (g *OPCGroup) loop(ctx context.Context, dataChangeCB chan *CDataChangeCallBackData, readCB chan *CReadCompleteCallBackData, writeCB chan *CWriteCompleteCallBackData, cancelCB chan *CCancelCompleteCallBackData) {
@@ -382,78 +406,70 @@ func (g *OPCGroup) loop(ctx context.Context, dataChangeCB chan *CDataChangeCallB
 		case <-ctx.Done():
 			return
 		case cbData := <-dataChangeCB:
-			g.fireDataChange(cbData)
+			g.fireDataChange(ctx, cbData)
 		case cbData := <-readCB:
-			g.fireReadComplete(cbData)
+			g.fireReadComplete(ctx, cbData)
 		case cbData := <-writeCB:
-			g.fireWriteComplete(cbData)
+			g.fireWriteComplete(ctx, cbData)
 		case cbData := <-cancelCB:
-			g.fireCancelComplete(cbData)
+			g.fireCancelComplete(ctx, cbData)
 		}
 	}
 }
 
-func (g *OPCGroup) fireDataChange(cbData *CDataChangeCallBackData) {
+func (g *OPCGroup) fireDataChange(ctx context.Context, cbData *CDataChangeCallBackData) {
+	g.fireActivity()
+	if len(cbData.ItemClientHandles) == 0 {
+		// keep-alive callbacks only feed the stall watchdogs
+		return
+	}
 	masterError := error(nil)
 	if (cbData.MasterErr) < 0 {
 		masterError = g.getError(cbData.MasterErr)
 	}
-	itemErrors := make([]error, len(cbData.Errors))
-	for i, e := range cbData.Errors {
-		if e < 0 {
-			itemErrors[i] = g.getError(e)
-		}
-	}
+	itemErrors := g.getItemErrors(cbData.Errors, cbData.DecodeErrors)
+	values, qualities := g.items.convertCallback(cbData.ItemClientHandles, cbData.Values, cbData.Qualities, itemErrors)
 	data := &DataChangeCallBackData{
 		TransID:           cbData.TransID,
 		GroupHandle:       cbData.GroupHandle,
 		MasterQuality:     cbData.MasterQuality,
 		MasterErr:         masterError,
 		ItemClientHandles: cbData.ItemClientHandles,
-		Values:            cbData.Values,
-		Qualities:         cbData.Qualities,
+		Values:            values,
+		Qualities:         qualities,
 		TimeStamps:        cbData.TimeStamps,
 		Errors:            itemErrors,
 	}
-	for _, backData := range g.dataChangeList {
-		select {
-		case backData <- data:
-		default:
-		}
+	if data.TransID != 0 {
+		// the data change of a refresh
+		g.completeTransaction(data.TransID, data)
 	}
+	deliverAll(ctx, g, &g.dataChangeList, data, mergeDataChanges)
 }
 
-func (g *OPCGroup) fireReadComplete(cbData *CReadCompleteCallBackData) {
+func (g *OPCGroup) fireReadComplete(ctx context.Context, cbData *CReadCompleteCallBackData) {
 	masterError := error(nil)
 	if (cbData.MasterErr) < 0 {
 		masterError = g.getError(cbData.MasterErr)
 	}
-	itemErrors := make([]error, len(cbData.Errors))
-	for i, e := range cbData.Errors {
-		if e < 0 {
-			itemErrors[i] = g.getError(e)
-		}
-	}
+	itemErrors := g.getItemErrors(cbData.Errors, cbData.DecodeErrors)
+	values, qualities := g.items.convertCallback(cbData.ItemClientHandles, cbData.Values, cbData.Qualities, itemErrors)
 	data := &ReadCompleteCallBackData{
 		TransID:           cbData.TransID,
 		GroupHandle:       cbData.GroupHandle,
 		MasterQuality:     cbData.MasterQuality,
 		MasterErr:         masterError,
 		ItemClientHandles: cbData.ItemClientHandles,
-		Values:            cbData.Values,
-		Qualities:         cbData.Qualities,
+		Values:            values,
+		Qualities:         qualities,
 		TimeStamps:        cbData.TimeStamps,
 		Errors:            itemErrors,
 	}
-	for _, backData := range g.readCompleteList {
-		select {
-		case backData <- data:
-		default:
-		}
-	}
+	g.completeTransaction(data.TransID, data)
+	deliverAll(ctx, g, &g.readCompleteList, data, nil)
 }
 
-func (g *OPCGroup) fireWriteComplete(cbData *CWriteCompleteCallBackData) {
+func (g *OPCGroup) fireWriteComplete(ctx context.Context, cbData *CWriteCompleteCallBackData) {
 	masterError := error(nil)
 	if (cbData.MasterErr) < 0 {
 		masterError = g.getError(cbData.MasterErr)
@@ -471,22 +487,17 @@ func (g *OPCGroup) fireWriteComplete(cbData *CWriteCompleteCallBackData) {
 		ItemClientHandles: cbData.ItemClientHandles,
 		Errors:            itemErrors,
 	}
-	for _, backData := range g.writeCompleteList {
-		select {
-		case backData <- data:
-		default:
-		}
-	}
+	g.completeTransaction(data.TransID, data)
+	deliverAll(ctx, g, &g.writeCompleteList, data, nil)
 }
 
-func (g *OPCGroup) fireCancelComplete(cbData *CCancelCompleteCallBackData) {
+func (g *OPCGroup) fireCancelComplete(ctx context.Context, cbData *CCancelCompleteCallBackData) {
 	data := &CancelCompleteCallBackData{
 		TransID:     cbData.TransID,
 		GroupHandle: cbData.GroupHandle,
 	}
-	for _, backData := range g.cancelCompleteList {
-		backData <- data
-	}
+	g.cancelTransaction(data.TransID)
+	deliverAll(ctx, g, &g.cancelCompleteList, data, nil)
 }
 
 // AsyncRead Read one or more items in a group. The results are returned via the AsyncReadComplete event associated with the OPCGroup object.
@@ -494,6 +505,9 @@ func (g *OPCGroup) AsyncRead(
 	serverHandles []uint32,
 	clientTransactionID uint32,
 ) (cancelID uint32, errs []error, err error) {
+	if g.asyncIO2 == nil {
+		return 0, nil, NewOPCWrapperError("query interface IOPCAsyncIO2", g.asyncIO2Err)
+	}
 	var es []int32
 	cancelID, es, err = g.asyncIO2.Read(
 		serverHandles,
@@ -517,37 +531,17 @@ func (g *OPCGroup) AsyncWrite(
 	values []interface{},
 	clientTransactionID uint32,
 ) (cancelID uint32, errs []error, err error) {
-	variants := make([]com.VARIANT, len(values))
-	variantWrappers := make([]*com.VariantWrapper, len(values))
-
-	defer func() {
-		for _, variant := range variants {
-			variant.Clear()
-		}
-	}()
-	for i, v := range values {
-		variant, err := com.NewVariant(v)
-		if err != nil {
-			return 0, nil, err
-		}
-		variantWrappers[i] = variant
-		variants[i] = *variant.Variant
-	}
-	var es []int32
-	cancelID, es, err = g.asyncIO2.Write(
-		serverHandles,
-		variants,
-		clientTransactionID,
-	)
-	if err != nil {
+	if g.asyncIO2 == nil {
+		return 0, nil, NewOPCWrapperError("query interface IOPCAsyncIO2", g.asyncIO2Err)
+	}
+	errs, err = g.writeConverted(serverHandles, values, func(serverHandles []uint32, values []interface{}, _ []int) (es []int32, err error) {
+		cancelID, es, err = g.asyncIO2.Write(
+			serverHandles,
+			values,
+			clientTransactionID,
+		)
 		return
-	}
-	errs = make([]error, len(es))
-	for i, e := range es {
-		if e < 0 {
-			errs[i] = g.getError(e)
-		}
-	}
+	})
 	return
 }
 
@@ -558,6 +552,9 @@ func (g *OPCGroup) AsyncRefresh(
 	source com.OPCDATASOURCE,
 	clientTransactionID uint32,
 ) (cancelID uint32, err error) {
+	if g.asyncIO2 == nil {
+		return 0, NewOPCWrapperError("query interface IOPCAsyncIO2", g.asyncIO2Err)
+	}
 	cancelID, err = g.asyncIO2.Refresh2(
 		source,
 		clientTransactionID,
@@ -568,9 +565,150 @@ func (g *OPCGroup) AsyncRefresh(
 // AsyncCancel Request that the server cancel an outstanding transaction. An AsyncCancelComplete event will
 // occur indicating whether or not the cancel succeeded.
 func (g *OPCGroup) AsyncCancel(cancelID uint32) error {
+	if g.asyncIO2 == nil {
+		return NewOPCWrapperError("query interface IOPCAsyncIO2", g.asyncIO2Err)
+	}
 	return g.asyncIO2.Cancel2(cancelID)
 }
 
+// SupportsSyncIO2 reports whether the server implements the OPC DA 3.0 IOPCSyncIO2 used by SyncReadMaxAge and SyncWriteVQT
+func (g *OPCGroup) SupportsSyncIO2() bool {
+	return g.syncIO2 != nil
+}
+
+// SupportsAsyncIO3 reports whether the server implements the OPC DA 3.0 IOPCAsyncIO3 used by AsyncReadMaxAge and AsyncWriteVQT
+func (g *OPCGroup) SupportsAsyncIO3() bool {
+	return g.asyncIO3 != nil
+}
+
+// SupportsItemDeadband reports whether the server implements the OPC DA 3.0 IOPCItemDeadbandMgt used by the item deadband methods
+func (g *OPCGroup) SupportsItemDeadband() bool {
+	return g.deadbandMgt != nil
+}
+
+// itemDeadbandMgt returns the IOPCItemDeadbandMgt of the group or the error of its query
+func (g *OPCGroup) itemDeadbandMgt() (ItemDeadbandMgtBackend, error) {
+	if g.deadbandMgt == nil {
+		return nil, NewOPCWrapperError("query interface IOPCItemDeadbandMgt", g.deadbandMgtErr)
+	}
+	return g.deadbandMgt, nil
+}
+
+// SupportsItemSampling reports whether the server implements the OPC DA 3.0 IOPCItemSamplingMgt used by the item sampling rate and buffer methods
+func (g *OPCGroup) SupportsItemSampling() bool {
+	return g.samplingMgt != nil
+}
+
+// itemSamplingMgt returns the IOPCItemSamplingMgt of the group or the error of its query
+func (g *OPCGroup) itemSamplingMgt() (ItemSamplingMgtBackend, error) {
+	if g.samplingMgt == nil {
+		return nil, NewOPCWrapperError("query interface IOPCItemSamplingMgt", g.samplingMgtErr)
+	}
+	return g.samplingMgt, nil
+}
+
+// SyncReadMaxAge reads one or more items in a group, the server reads an item from the device when its cached value
+// is older than its max age in milliseconds. 0 always reads from the device and 0xFFFFFFFF from the cache.
+// The state of a failed item is nil. It requires the OPC DA 3.0 IOPCSyncIO2.
+func (g *OPCGroup) SyncReadMaxAge(serverHandles []uint32, maxAges []uint32) ([]*com.ItemState, []error, error) {
+	if g.syncIO2 == nil {
+		return nil, nil, NewOPCWrapperError("query interface IOPCSyncIO2", g.syncIO2Err)
+	}
+	if len(maxAges) != len(serverHandles) {
+		return nil, nil, errors.New("maxAges and serverHandles have different lengths")
+	}
+	states, errList, err := g.syncIO2.ReadMaxAge(serverHandles, maxAges)
+	if err != nil {
+		return nil, nil, err
+	}
+	errs := g.getErrors(errList)
+	g.items.convertStates(serverHandles, states, errs)
+	return states, errs, nil
+}
+
+// SyncWriteVQT writes the values of one or more items in a group with their qualities and timestamps.
+// qualities and timestamps are optional: the server keeps its own quality when qualities is nil and its own
+// timestamp when timestamps is nil or the timestamp of an item is zero. It requires the OPC DA 3.0 IOPCSyncIO2.
+func (g *OPCGroup) SyncWriteVQT(serverHandles []uint32, values []interface{}, qualities []com.Quality, timestamps []time.Time) ([]error, error) {
+	if g.syncIO2 == nil {
+		return nil, NewOPCWrapperError("query interface IOPCSyncIO2", g.syncIO2Err)
+	}
+	vqts, err := newItemVQTs(len(serverHandles), values, qualities, timestamps)
+	if err != nil {
+		return nil, err
+	}
+	return g.writeConverted(serverHandles, values, func(serverHandles []uint32, values []interface{}, index []int) ([]int32, error) {
+		return g.syncIO2.WriteVQT(serverHandles, convertedVQTs(vqts, values, index))
+	})
+}
+
+// AsyncReadMaxAge reads one or more items in a group like SyncReadMaxAge. The results are returned via the
+// AsyncReadComplete event associated with the OPCGroup object. It requires the OPC DA 3.0 IOPCAsyncIO3.
+func (g *OPCGroup) AsyncReadMaxAge(
+	serverHandles []uint32,
+	maxAges []uint32,
+	clientTransactionID uint32,
+) (cancelID uint32, errs []error, err error) {
+	if g.asyncIO3 == nil {
+		return 0, nil, NewOPCWrapperError("query interface IOPCAsyncIO3", g.asyncIO3Err)
+	}
+	if len(maxAges) != len(serverHandles) {
+		return 0, nil, errors.New("maxAges and serverHandles have different lengths")
+	}
+	var es []int32
+	cancelID, es, err = g.asyncIO3.ReadMaxAge(serverHandles, maxAges, clientTransactionID)
+	if err != nil {
+		return
+	}
+	errs = g.getErrors(es)
+	return
+}
+
+// AsyncWriteVQT writes one or more items in a group like SyncWriteVQT. The results are returned via the
+// AsyncWriteComplete event associated with the OPCGroup object. It requires the OPC DA 3.0 IOPCAsyncIO3.
+func (g *OPCGroup) AsyncWriteVQT(
+	serverHandles []uint32,
+	values []interface{},
+	qualities []com.Quality,
+	timestamps []time.Time,
+	clientTransactionID uint32,
+) (cancelID uint32, errs []error, err error) {
+	if g.asyncIO3 == nil {
+		return 0, nil, NewOPCWrapperError("query interface IOPCAsyncIO3", g.asyncIO3Err)
+	}
+	vqts, err := newItemVQTs(len(serverHandles), values, qualities, timestamps)
+	if err != nil {
+		return 0, nil, err
+	}
+	errs, err = g.writeConverted(serverHandles, values, func(serverHandles []uint32, values []interface{}, index []int) (es []int32, err error) {
+		cancelID, es, err = g.asyncIO3.WriteVQT(serverHandles, convertedVQTs(vqts, values, index), clientTransactionID)
+		return
+	})
+	return
+}
+
+// getErrors converts the item error codes of a call, the error of a succeeded item is nil
+func (g *OPCGroup) getErrors(errorCodes []int32) []error {
+	errs := make([]error, len(errorCodes))
+	for i, e := range errorCodes {
+		if e < 0 {
+			errs[i] = g.getError(e)
+		}
+	}
+	return errs
+}
+
+// getItemErrors converts the item errors of a callback, a value that could not be decoded gets its decode error
+func (g *OPCGroup) getItemErrors(errorCodes []int32, decodeErrors []error) []error {
+	errs := g.getErrors(errorCodes)
+	for i, err := range decodeErrors {
+		if err != nil && i < len(errs) {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
 func (g *OPCGroup) getError(errorCode int32) error {
 	errStr, _ := g.iCommon.GetErrorString(uint32(errorCode))
 	return &OPCError{
@@ -1,19 +1,17 @@
-package opcda
+package opcda_test
 
 import (
 	"testing"
 	"time"
 
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/opcsim"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestOPCGroup_SetName(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -27,12 +25,7 @@ func TestOPCGroup_SetName(t *testing.T) {
 }
 
 func TestOPCGroup_IsActive(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -48,12 +41,7 @@ func TestOPCGroup_IsActive(t *testing.T) {
 }
 
 func TestOPCGroup_Handle(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -69,12 +57,7 @@ func TestOPCGroup_Handle(t *testing.T) {
 }
 
 func TestOPCGroup_LocaleID(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -84,20 +67,15 @@ func TestOPCGroup_LocaleID(t *testing.T) {
 	localID, err := group.GetLocaleID()
 	assert.NoError(t, err)
 	assert.Greater(t, localID, uint32(0))
-	err = group.SetLocaleID(1024)
+	err = group.SetLocaleID(0x0804)
 	assert.NoError(t, err)
 	localID, err = group.GetLocaleID()
 	assert.NoError(t, err)
-	assert.Equal(t, uint32(1024), localID)
+	assert.Equal(t, uint32(0x0804), localID)
 }
 
 func TestOPCGroup_TimeBias(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -115,12 +93,7 @@ func TestOPCGroup_TimeBias(t *testing.T) {
 }
 
 func TestOPCGroup_Deadband(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -138,12 +111,7 @@ func TestOPCGroup_Deadband(t *testing.T) {
 }
 
 func TestOPCGroup_UpdateRate(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -161,12 +129,7 @@ func TestOPCGroup_UpdateRate(t *testing.T) {
 }
 
 func TestOPCGroup_Items(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -174,7 +137,7 @@ func TestOPCGroup_Items(t *testing.T) {
 	assert.NotNil(t, group)
 	assert.Equal(t, "test", group.GetName())
 
-	items := group.items
+	items := group.OPCItems()
 	assert.NotNil(t, items)
 	items2 := group.OPCItems()
 	assert.Equal(t, items, items2)
@@ -185,12 +148,7 @@ func TestOPCGroup_Items(t *testing.T) {
 }
 
 func TestOPCGroup_SyncRead(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	sim, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_group_read")
@@ -198,58 +156,43 @@ func TestOPCGroup_SyncRead(t *testing.T) {
 	assert.NotNil(t, group)
 	assert.Equal(t, "test_group_read", group.GetName())
 
-	items := group.items
+	items := group.OPCItems()
 	assert.NotNil(t, items)
 	items2 := group.OPCItems()
 	assert.Equal(t, items, items2)
 	item, err := items.AddItem(TestSyncWriteItem)
 	assert.NoError(t, err)
-	match := false
 	err = item.SetIsActive(true)
 	assert.NoError(t, err)
-	for i := 0; i < 5; i++ {
-		time.Sleep(time.Second)
-		status, resultErrs, err := group.SyncRead(OPC_DS_CACHE, []uint32{item.GetServerHandle()})
-		assert.NoError(t, err)
-		assert.Equal(t, 1, len(resultErrs))
-		assert.NoError(t, resultErrs[0])
-		t.Log(status[0])
-		assert.Equal(t, 1, len(status))
-		if status[0].Quality != uint16(192) {
-			continue
-		}
-		value, quality, ts, err := item.Read(OPC_DS_CACHE)
-		assert.NoError(t, err)
-		assert.Equal(t, uint16(192), quality)
-		if status[0].Timestamp != ts {
-			continue
-		}
-		assert.Equal(t, status[0].Value, value)
-		match = true
-		break
-	}
-	assert.True(t, match)
+	assert.NoError(t, sim.SetValue(TestSyncWriteItem, int32(5)))
+	status, resultErrs, err := group.SyncRead(opcda.OPC_DS_CACHE, []uint32{item.GetServerHandle()})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(resultErrs))
+	assert.NoError(t, resultErrs[0])
+	assert.Equal(t, 1, len(status))
+	assert.Equal(t, com.QualityGood, status[0].Quality)
+	assert.Equal(t, int32(5), status[0].Value)
+	value, quality, ts, err := item.Read(opcda.OPC_DS_CACHE)
+	assert.NoError(t, err)
+	assert.Equal(t, com.QualityGood, quality)
+	assert.Equal(t, status[0].Timestamp, ts)
+	assert.Equal(t, status[0].Value, value)
 }
 
 func TestOPCGroup_ReadError(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_group_read_error")
 	assert.NoError(t, err)
 	assert.NotNil(t, group)
-	items := group.items
+	items := group.OPCItems()
 	assert.NotNil(t, items)
 	items2 := group.OPCItems()
 	assert.Equal(t, items, items2)
 	item, err := items.AddItem(TestReadErrorItem)
 	assert.NoError(t, err)
-	_, resultErrs, err := group.SyncRead(OPC_DS_CACHE, []uint32{item.GetServerHandle()})
+	_, resultErrs, err := group.SyncRead(opcda.OPC_DS_CACHE, []uint32{item.GetServerHandle()})
 	// Read operation itself should succeed, but the individual read should fail
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(resultErrs))
@@ -257,12 +200,7 @@ func TestOPCGroup_ReadError(t *testing.T) {
 }
 
 func TestOPCGroup_SyncWrite(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_group_write")
@@ -270,27 +208,20 @@ func TestOPCGroup_SyncWrite(t *testing.T) {
 	assert.NotNil(t, group)
 	assert.Equal(t, "test_group_write", group.GetName())
 
-	items := group.items
+	items := group.OPCItems()
 	assert.NotNil(t, items)
 	item, err := items.AddItem(TestSyncWriteItem)
 	assert.NoError(t, err)
-	ch := make(chan *DataChangeCallBackData, 1)
+	ch := make(chan *opcda.DataChangeCallBackData, 1)
 	err = group.RegisterDataChange(ch)
 	assert.NoError(t, err)
-	var value interface{}
-	for i := 0; i < 5; i++ {
-		errs, err := group.SyncWrite([]uint32{item.GetServerHandle()}, []interface{}{int32(11)})
-		assert.NoError(t, err)
-		for _, err := range errs {
-			assert.NoError(t, err)
-		}
-		value, _, _, err = item.Read(OPC_DS_CACHE)
+	errs, err := group.SyncWrite([]uint32{item.GetServerHandle()}, []interface{}{int32(11)})
+	assert.NoError(t, err)
+	for _, err := range errs {
 		assert.NoError(t, err)
-		if value == int32(11) {
-			break
-		}
-		time.Sleep(time.Second)
 	}
+	value, _, _, err := item.Read(opcda.OPC_DS_CACHE)
+	assert.NoError(t, err)
 	assert.Equal(t, int32(11), value)
 	timeout := time.NewTimer(time.Second * 5)
 	defer timeout.Stop()
@@ -307,22 +238,17 @@ func TestOPCGroup_SyncWrite(t *testing.T) {
 }
 
 func TestOPCGroup_WriteError(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_group_write_error")
 	assert.NoError(t, err)
 	assert.NotNil(t, group)
-	items := group.items
+	items := group.OPCItems()
 	assert.NotNil(t, items)
 	item, err := items.AddItem(TestWriteErrorItem)
 	assert.NoError(t, err)
-	ch := make(chan *DataChangeCallBackData, 1)
+	ch := make(chan *opcda.DataChangeCallBackData, 1)
 	err = group.RegisterDataChange(ch)
 	assert.NoError(t, err)
 	errs, err := group.SyncWrite([]uint32{item.GetServerHandle()}, []interface{}{int32(11)})
@@ -333,19 +259,14 @@ func TestOPCGroup_WriteError(t *testing.T) {
 }
 
 func TestOPCGroup_AsyncRead(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_group_async_read")
 	assert.NoError(t, err)
 	assert.NotNil(t, group)
-	ch := make(chan *ReadCompleteCallBackData, 1)
-	out := make(chan *ReadCompleteCallBackData, 1)
+	ch := make(chan *opcda.ReadCompleteCallBackData, 1)
+	out := make(chan *opcda.ReadCompleteCallBackData, 1)
 	go func() {
 		select {
 		case data := <-ch:
@@ -354,11 +275,10 @@ func TestOPCGroup_AsyncRead(t *testing.T) {
 	}()
 	err = group.RegisterReadComplete(ch)
 	assert.NoError(t, err)
-	items := group.items
+	items := group.OPCItems()
 	item, err := items.AddItem(TestBoolItem)
 	assert.NoError(t, err)
-	time.Sleep(time.Second)
-	cancelID, errs, err := group.AsyncRead([]uint32{item.serverHandle}, 100)
+	cancelID, errs, err := group.AsyncRead([]uint32{item.GetServerHandle()}, 100)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(errs))
 	assert.Nil(t, errs[0])
@@ -370,26 +290,21 @@ func TestOPCGroup_AsyncRead(t *testing.T) {
 		assert.Equal(t, group.GetClientHandle(), data.GroupHandle)
 		assert.Equal(t, uint32(100), data.TransID)
 		assert.Equal(t, 1, len(data.ItemClientHandles))
-		assert.Equal(t, item.clientHandle, data.ItemClientHandles[0])
+		assert.Equal(t, item.GetClientHandle(), data.ItemClientHandles[0])
 	case <-timeout.C:
 		t.Fatal("timeout")
 	}
 }
 
 func TestOPCGroup_AsyncWrite(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_group_async_write")
 	assert.NoError(t, err)
 	assert.NotNil(t, group)
-	ch := make(chan *WriteCompleteCallBackData, 1)
-	out := make(chan *WriteCompleteCallBackData, 1)
+	ch := make(chan *opcda.WriteCompleteCallBackData, 1)
+	out := make(chan *opcda.WriteCompleteCallBackData, 1)
 	go func() {
 		select {
 		case data := <-ch:
@@ -398,11 +313,10 @@ func TestOPCGroup_AsyncWrite(t *testing.T) {
 	}()
 	err = group.RegisterWriteComplete(ch)
 	assert.NoError(t, err)
-	items := group.items
+	items := group.OPCItems()
 	item, err := items.AddItem(TestAsyncWriteItem)
 	assert.NoError(t, err)
-	time.Sleep(time.Second)
-	cancelID, errs, err := group.AsyncWrite([]uint32{item.serverHandle}, []interface{}{int32(14)}, 100)
+	cancelID, errs, err := group.AsyncWrite([]uint32{item.GetServerHandle()}, []interface{}{int32(14)}, 100)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(errs))
 	assert.Nil(t, errs[0])
@@ -414,7 +328,7 @@ func TestOPCGroup_AsyncWrite(t *testing.T) {
 		assert.Equal(t, group.GetClientHandle(), data.GroupHandle)
 		assert.Equal(t, uint32(100), data.TransID)
 		assert.Equal(t, 1, len(data.ItemClientHandles))
-		assert.Equal(t, item.clientHandle, data.ItemClientHandles[0])
+		assert.Equal(t, item.GetClientHandle(), data.ItemClientHandles[0])
 		assert.Equal(t, 1, len(data.Errors))
 		assert.Nil(t, data.Errors[0])
 	case <-timeout.C:
@@ -423,19 +337,14 @@ func TestOPCGroup_AsyncWrite(t *testing.T) {
 }
 
 func TestOPCGroup_AsyncRefresh(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_group_async_refresh")
 	assert.NoError(t, err)
 	assert.NotNil(t, group)
-	ch := make(chan *DataChangeCallBackData, 1)
-	out := make(chan *DataChangeCallBackData, 1)
+	ch := make(chan *opcda.DataChangeCallBackData, 1)
+	out := make(chan *opcda.DataChangeCallBackData, 1)
 	go func() {
 		select {
 		case data := <-ch:
@@ -445,11 +354,10 @@ func TestOPCGroup_AsyncRefresh(t *testing.T) {
 	}()
 	err = group.RegisterDataChange(ch)
 	assert.NoError(t, err)
-	items := group.items
+	items := group.OPCItems()
 	item, err := items.AddItem(TestBoolItem)
 	assert.NoError(t, err)
-	time.Sleep(time.Second)
-	cancelID, err := group.AsyncRefresh(OPC_DS_CACHE, 100)
+	cancelID, err := group.AsyncRefresh(opcda.OPC_DS_CACHE, 100)
 	assert.NoError(t, err)
 	t.Log(cancelID)
 	timeout := time.NewTimer(time.Second)
@@ -458,26 +366,21 @@ func TestOPCGroup_AsyncRefresh(t *testing.T) {
 		t.Log(data)
 		assert.Equal(t, group.GetClientHandle(), data.GroupHandle)
 		assert.Equal(t, 1, len(data.ItemClientHandles))
-		assert.Equal(t, item.clientHandle, data.ItemClientHandles[0])
+		assert.Equal(t, item.GetClientHandle(), data.ItemClientHandles[0])
 	case <-timeout.C:
 		t.Fatal("timeout")
 	}
 }
 
 func TestOPCGroup_AsyncCancel(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t, withSimOptions(opcsim.WithLatency(time.Second)))
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_group_async_cancel")
 	assert.NoError(t, err)
 	assert.NotNil(t, group)
-	ch := make(chan *CancelCompleteCallBackData, 1)
-	out := make(chan *CancelCompleteCallBackData, 1)
+	ch := make(chan *opcda.CancelCompleteCallBackData, 1)
+	out := make(chan *opcda.CancelCompleteCallBackData, 1)
 	go func() {
 		select {
 		case data := <-ch:
@@ -486,12 +389,11 @@ func TestOPCGroup_AsyncCancel(t *testing.T) {
 	}()
 	err = group.RegisterCancelComplete(ch)
 	assert.NoError(t, err)
-	items := group.items
+	items := group.OPCItems()
 	item, err := items.AddItem(TestAsyncWriteItem)
 	assert.NoError(t, err)
-	time.Sleep(time.Second)
 	for i := 0; i < 300; i++ {
-		cancelID, errs, err := group.AsyncWrite([]uint32{item.serverHandle}, []interface{}{int32(14)}, 100)
+		cancelID, errs, err := group.AsyncWrite([]uint32{item.GetServerHandle()}, []interface{}{int32(14)}, 100)
 		assert.NoError(t, err)
 		assert.Equal(t, 1, len(errs))
 		assert.Nil(t, errs[0])
@@ -4,13 +4,11 @@ import (
 	"errors"
 	"sync"
 	"sync/atomic"
-
-	"github.com/huskar-t/opcda/com"
 )
 
 type OPCGroups struct {
-	iServer                *com.IOPCServer
-	iCommon                *com.IOPCCommon
+	iServer                ServerBackend
+	iCommon                CommonBackend
 	parent                 *OPCServer
 	groupID                uint32
 	defaultActive          bool
@@ -124,22 +122,26 @@ func (gs *OPCGroups) Add(szName string) (*OPCGroup, error) {
 	gs.Lock()
 	defer gs.Unlock()
 	hClientGroup := atomic.AddUint32(&gs.groupID, 1)
-	phServerGroup, pRevisedUpdateRate, ppUnk, err := gs.iServer.AddGroup(
-		szName,
-		gs.defaultActive,
-		gs.defaultGroupUpdateRate,
-		hClientGroup,
-		&gs.defaultGroupTimeBias,
-		&gs.defaultDeadband,
-		gs.defaultLocaleID,
-		&com.IID_IOPCGroupStateMgt,
+	return gs.addGroup(szName, hClientGroup, gs.defaultActive, gs.defaultGroupUpdateRate, gs.defaultGroupTimeBias, gs.defaultDeadband, gs.defaultLocaleID)
+}
+
+// addGroup creates a group with explicit settings, the caller holds the lock
+func (gs *OPCGroups) addGroup(name string, clientGroup uint32, active bool, updateRate uint32, timeBias int32, deadband float32, localeID uint32) (*OPCGroup, error) {
+	phServerGroup, pRevisedUpdateRate, groupBackend, err := gs.iServer.AddGroup(
+		name,
+		active,
+		updateRate,
+		clientGroup,
+		&timeBias,
+		&deadband,
+		localeID,
 	)
 	if err != nil {
 		return nil, err
 	}
-	opcGroup, err := NewOPCGroup(gs, ppUnk, hClientGroup, phServerGroup, szName, pRevisedUpdateRate)
+	opcGroup, err := NewOPCGroup(gs, groupBackend, clientGroup, phServerGroup, name, pRevisedUpdateRate)
 	if err != nil {
-		ppUnk.Release()
+		groupBackend.Release()
 		return nil, err
 	}
 	gs.groups = append(gs.groups, opcGroup)
@@ -1,4 +1,4 @@
-package opcda
+package opcda_test
 
 import (
 	"testing"
@@ -8,207 +8,131 @@ import (
 
 // parent
 func TestOPCGroups_Parent(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	parent := groups.GetParent()
-	assert.NoError(t, err)
 	assert.Equal(t, server, parent)
 }
 
 func TestOPCGroups_GetDefaultGroupIsActive(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	isActive := groups.GetDefaultGroupIsActive()
-	assert.NoError(t, err)
 	assert.Equal(t, true, isActive)
 }
 
 func TestOPCGroups_SetDefaultGroupIsActive(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	groups.SetDefaultGroupIsActive(false)
-	assert.NoError(t, err)
 	isActive := groups.GetDefaultGroupIsActive()
-	assert.NoError(t, err)
 	assert.Equal(t, false, isActive)
+	group := addGroup(t, server, "inactive")
+	assert.False(t, group.GetIsActive())
 }
 
 // GetDefaultGroupUpdateRate
 func TestOPCGroups_GetDefaultGroupUpdateRate(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	updateRate := groups.GetDefaultGroupUpdateRate()
-	assert.NoError(t, err)
 	assert.Equal(t, uint32(1000), updateRate)
 }
 
 // SetDefaultGroupUpdateRate
 func TestOPCGroups_SetDefaultGroupUpdateRate(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	groups.SetDefaultGroupUpdateRate(2000)
-	assert.NoError(t, err)
 	updateRate := groups.GetDefaultGroupUpdateRate()
+	assert.Equal(t, uint32(2000), updateRate)
+	group := addGroup(t, server, "rate")
+	updateRate, err := group.GetUpdateRate()
 	assert.NoError(t, err)
 	assert.Equal(t, uint32(2000), updateRate)
 }
 
 // GetDefaultGroupDeadband
 func TestOPCGroups_GetDefaultGroupDeadband(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	deadband := groups.GetDefaultGroupDeadband()
-	assert.NoError(t, err)
 	assert.Equal(t, float32(0.0), deadband)
 }
 
 // SetDefaultGroupDeadband
 func TestOPCGroups_SetDefaultGroupDeadband(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	groups.SetDefaultGroupDeadband(1.0)
-	assert.NoError(t, err)
 	deadband := groups.GetDefaultGroupDeadband()
-	assert.NoError(t, err)
 	assert.Equal(t, float32(1.0), deadband)
 }
 
 // GetDefaultGroupLocaleID
 func TestOPCGroups_GetDefaultGroupLocaleID(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	localeID := groups.GetDefaultGroupLocaleID()
-	assert.NoError(t, err)
 	assert.Equal(t, uint32(0x0400), localeID)
 }
 
 // SetDefaultGroupLocaleID
 func TestOPCGroups_SetDefaultGroupLocaleID(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	groups.SetDefaultGroupLocaleID(0x0401)
-	assert.NoError(t, err)
 	localeID := groups.GetDefaultGroupLocaleID()
-	assert.NoError(t, err)
 	assert.Equal(t, uint32(0x0401), localeID)
 }
 
 // GetDefaultGroupTimeBias
 func TestOPCGroups_GetDefaultGroupTimeBias(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 
 	assert.NotNil(t, groups)
 	timeBias := groups.GetDefaultGroupTimeBias()
-	assert.NoError(t, err)
 	assert.Equal(t, int32(0), timeBias)
 }
 
 // SetDefaultGroupTimeBias
 func TestOPCGroups_SetDefaultGroupTimeBias(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 
 	assert.NotNil(t, groups)
 	groups.SetDefaultGroupTimeBias(1)
-	assert.NoError(t, err)
 	timeBias := groups.GetDefaultGroupTimeBias()
-	assert.NoError(t, err)
 	assert.Equal(t, int32(1), timeBias)
 }
 
 // GetCount
 func TestOPCGroups_GetCount(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 
 	assert.NotNil(t, groups)
 	count := groups.GetCount()
-	assert.NoError(t, err)
 	assert.Equal(t, 0, count)
 }
 
 func TestOPCGroups_AddGroup(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
 	assert.NoError(t, err)
 	assert.NotNil(t, group)
-	assert.Equal(t, "test", group.groupName)
+	assert.Equal(t, "test", group.GetName())
 	count := groups.GetCount()
 	assert.Equal(t, 1, count)
 	g, err := groups.Item(0)
@@ -8,11 +8,11 @@ import (
 )
 
 type OPCItem struct {
-	itemMgt           *com.IOPCItemMgt
-	syncIO            *com.IOPCSyncIO
-	iCommon           *com.IOPCCommon
+	itemMgt           ItemMgtBackend
+	syncIO            SyncIOBackend
+	iCommon           CommonBackend
 	value             interface{}
-	quality           uint16
+	quality           com.Quality
 	timestamp         time.Time
 	serverHandle      uint32
 	clientHandle      uint32
@@ -22,7 +22,9 @@ type OPCItem struct {
 	isActive          bool
 	requestedDataType com.VT
 	nativeDataType    com.VT
-	parent            *OPCItems
+	// clientConverted is set when the server returns the canonical data type and the client converts the values
+	clientConverted bool
+	parent          *OPCItems
 }
 
 // GetParent Returns reference to the parent OPCItems object.
@@ -78,19 +80,39 @@ func (i *OPCItem) GetRequestedDataType() com.VT {
 	return i.requestedDataType
 }
 
-// SetRequestedDataType set the requested data type for the item.
+// SetRequestedDataType set the requested data type for the item. When the server rejects it and client conversion
+// is enabled, see OPCItems.SetClientConversion, the server returns the canonical data type and the client converts it.
 func (i *OPCItem) SetRequestedDataType(requestedDataType com.VT) error {
 	errs, err := i.itemMgt.SetDatatypes([]uint32{i.serverHandle}, []com.VT{requestedDataType})
 	if err != nil {
 		return err
 	}
+	if errs[0] == int32(OPCBadType) && requestedDataType != com.VT_EMPTY && i.parent.GetClientConversion() {
+		errs, err = i.itemMgt.SetDatatypes([]uint32{i.serverHandle}, []com.VT{com.VT_EMPTY})
+		if err != nil {
+			return err
+		}
+		if errs[0] != 0 {
+			return i.getError(errs[0])
+		}
+		i.parent.setRequestedDataType(i, requestedDataType, true)
+		return nil
+	}
 	if errs[0] != 0 {
 		return i.getError(errs[0])
 	}
-	i.requestedDataType = requestedDataType
+	i.parent.setRequestedDataType(i, requestedDataType, false)
 	return nil
 }
 
+// GetClientConverted reports whether the client converts the values of the item between its requested and canonical
+// data types because the server rejected the requested data type
+func (i *OPCItem) GetClientConverted() bool {
+	i.parent.RLock()
+	defer i.parent.RUnlock()
+	return i.clientConverted
+}
+
 // SetIsActive set the active state for the item.
 func (i *OPCItem) SetIsActive(isActive bool) error {
 	errs, err := i.itemMgt.SetActiveState([]uint32{i.serverHandle}, isActive)
@@ -104,13 +126,149 @@ func (i *OPCItem) SetIsActive(isActive bool) error {
 	return nil
 }
 
+// GetDeadband get the percent deadband of the item, it fails with OPCDeadbandNotSet when the item uses the deadband
+// of its group. It requires the OPC DA 3.0 IOPCItemDeadbandMgt.
+func (i *OPCItem) GetDeadband() (float32, error) {
+	deadbandMgt, err := i.parent.parent.itemDeadbandMgt()
+	if err != nil {
+		return 0, err
+	}
+	deadbands, errs, err := deadbandMgt.GetItemDeadband([]uint32{i.serverHandle})
+	if err != nil {
+		return 0, err
+	}
+	if errs[0] < 0 {
+		return 0, i.getError(errs[0])
+	}
+	return deadbands[0], nil
+}
+
+// SetDeadband set the percent deadband of the item, it overrides the deadband of the group for analog items.
+// It requires the OPC DA 3.0 IOPCItemDeadbandMgt.
+func (i *OPCItem) SetDeadband(percentDeadband float32) error {
+	deadbandMgt, err := i.parent.parent.itemDeadbandMgt()
+	if err != nil {
+		return err
+	}
+	errs, err := deadbandMgt.SetItemDeadband([]uint32{i.serverHandle}, []float32{percentDeadband})
+	if err != nil {
+		return err
+	}
+	if errs[0] < 0 {
+		return i.getError(errs[0])
+	}
+	return nil
+}
+
+// ClearDeadband clear the percent deadband of the item, the item uses the deadband of its group again.
+// It requires the OPC DA 3.0 IOPCItemDeadbandMgt.
+func (i *OPCItem) ClearDeadband() error {
+	deadbandMgt, err := i.parent.parent.itemDeadbandMgt()
+	if err != nil {
+		return err
+	}
+	errs, err := deadbandMgt.ClearItemDeadband([]uint32{i.serverHandle})
+	if err != nil {
+		return err
+	}
+	if errs[0] < 0 {
+		return i.getError(errs[0])
+	}
+	return nil
+}
+
+// GetSamplingRate get the sampling rate of the item in milliseconds, it fails with OPCRateNotSet when the item is
+// sampled at the update rate of its group. It requires the OPC DA 3.0 IOPCItemSamplingMgt.
+func (i *OPCItem) GetSamplingRate() (uint32, error) {
+	samplingMgt, err := i.parent.parent.itemSamplingMgt()
+	if err != nil {
+		return 0, err
+	}
+	rates, errs, err := samplingMgt.GetItemSamplingRate([]uint32{i.serverHandle})
+	if err != nil {
+		return 0, err
+	}
+	if errs[0] < 0 {
+		return 0, i.getError(errs[0])
+	}
+	return rates[0], nil
+}
+
+// SetSamplingRate set the rate in milliseconds at which the server samples the item, independent of the update rate
+// of its group. It returns the rate revised by the server. It requires the OPC DA 3.0 IOPCItemSamplingMgt.
+func (i *OPCItem) SetSamplingRate(samplingRate uint32) (uint32, error) {
+	samplingMgt, err := i.parent.parent.itemSamplingMgt()
+	if err != nil {
+		return 0, err
+	}
+	rates, errs, err := samplingMgt.SetItemSamplingRate([]uint32{i.serverHandle}, []uint32{samplingRate})
+	if err != nil {
+		return 0, err
+	}
+	if errs[0] < 0 {
+		return 0, i.getError(errs[0])
+	}
+	return rates[0], nil
+}
+
+// ClearSamplingRate clear the sampling rate of the item, the item is sampled at the update rate of its group again.
+// It requires the OPC DA 3.0 IOPCItemSamplingMgt.
+func (i *OPCItem) ClearSamplingRate() error {
+	samplingMgt, err := i.parent.parent.itemSamplingMgt()
+	if err != nil {
+		return err
+	}
+	errs, err := samplingMgt.ClearItemSamplingRate([]uint32{i.serverHandle})
+	if err != nil {
+		return err
+	}
+	if errs[0] < 0 {
+		return i.getError(errs[0])
+	}
+	return nil
+}
+
+// GetBufferEnable get whether the server buffers the samples of the item taken between two updates of its group.
+// It requires the OPC DA 3.0 IOPCItemSamplingMgt.
+func (i *OPCItem) GetBufferEnable() (bool, error) {
+	samplingMgt, err := i.parent.parent.itemSamplingMgt()
+	if err != nil {
+		return false, err
+	}
+	enable, errs, err := samplingMgt.GetItemBufferEnable([]uint32{i.serverHandle})
+	if err != nil {
+		return false, err
+	}
+	if errs[0] < 0 {
+		return false, i.getError(errs[0])
+	}
+	return enable[0], nil
+}
+
+// SetBufferEnable set whether the server buffers the samples of the item taken between two updates of its group,
+// the buffered samples are delivered in order in the next data change. It requires the OPC DA 3.0 IOPCItemSamplingMgt.
+func (i *OPCItem) SetBufferEnable(enable bool) error {
+	samplingMgt, err := i.parent.parent.itemSamplingMgt()
+	if err != nil {
+		return err
+	}
+	errs, err := samplingMgt.SetItemBufferEnable([]uint32{i.serverHandle}, []bool{enable})
+	if err != nil {
+		return err
+	}
+	if errs[0] < 0 {
+		return i.getError(errs[0])
+	}
+	return nil
+}
+
 // GetValue Returns the latest value read from the server
 func (i *OPCItem) GetValue() interface{} {
 	return i.value
 }
 
 // GetQuality Returns the latest quality read from the server
-func (i *OPCItem) GetQuality() uint16 {
+func (i *OPCItem) GetQuality() com.Quality {
 	return i.quality
 }
 
@@ -182,7 +340,7 @@ func NewOPCItem(
 }
 
 // Read makes a blocking call to read this item from the server.
-func (i *OPCItem) Read(source com.OPCDATASOURCE) (value interface{}, quality uint16, timestamp time.Time, err error) {
+func (i *OPCItem) Read(source com.OPCDATASOURCE) (value interface{}, quality com.Quality, timestamp time.Time, err error) {
 	values, errs, err := i.syncIO.Read(source, []uint32{i.serverHandle})
 	if err != nil {
 		return nil, 0, time.Time{}, err
@@ -191,10 +349,13 @@ func (i *OPCItem) Read(source com.OPCDATASOURCE) (value interface{}, quality uin
 		err = i.getError(errs[0])
 		return
 	}
-	value = values[0].Value
+	value, err = i.convertRead(values[0].Value)
+	if err != nil {
+		return nil, 0, time.Time{}, err
+	}
 	quality = values[0].Quality
 	timestamp = values[0].Timestamp
-	i.value = values[0].Value
+	i.value = value
 	i.quality = values[0].Quality
 	i.timestamp = values[0].Timestamp
 	return
@@ -202,12 +363,11 @@ func (i *OPCItem) Read(source com.OPCDATASOURCE) (value interface{}, quality uin
 
 // Write makes a blocking call to write this value to the server
 func (i *OPCItem) Write(value interface{}) error {
-	variant, err := com.NewVariant(value)
+	value, err := i.convertWrite(value)
 	if err != nil {
 		return err
 	}
-	defer variant.Clear()
-	errList, err := i.syncIO.Write([]uint32{i.serverHandle}, []com.VARIANT{*variant.Variant})
+	errList, err := i.syncIO.Write([]uint32{i.serverHandle}, []interface{}{value})
 	if err != nil {
 		return err
 	}
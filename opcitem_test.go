@@ -1,20 +1,19 @@
-package opcda
+package opcda_test
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/huskar-t/opcda"
 	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/opcsim"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestOPCItem_GetParent(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -29,12 +28,7 @@ func TestOPCItem_GetParent(t *testing.T) {
 }
 
 func TestOPCItem_GetItemID(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -48,12 +42,7 @@ func TestOPCItem_GetItemID(t *testing.T) {
 }
 
 func TestOPCItem_GetAccessPath(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -67,12 +56,7 @@ func TestOPCItem_GetAccessPath(t *testing.T) {
 }
 
 func TestOPCItem_GetIsActive(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -86,12 +70,7 @@ func TestOPCItem_GetIsActive(t *testing.T) {
 }
 
 func TestOPCItem_GetAccessRights(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -105,12 +84,7 @@ func TestOPCItem_GetAccessRights(t *testing.T) {
 }
 
 func TestOPCItem_GetEUType(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -126,12 +100,7 @@ func TestOPCItem_GetEUType(t *testing.T) {
 }
 
 func TestOPCItem_GetEUInfo(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -147,12 +116,7 @@ func TestOPCItem_GetEUInfo(t *testing.T) {
 }
 
 func TestOPCItem_GetCanonicalDataType(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -168,12 +132,7 @@ func TestOPCItem_GetCanonicalDataType(t *testing.T) {
 }
 
 func TestOPCItem_WriteError(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_item_write_error")
@@ -183,25 +142,18 @@ func TestOPCItem_WriteError(t *testing.T) {
 	item, err := items.AddItem(TestWriteErrorItem)
 	assert.NoError(t, err)
 	assert.NotNil(t, item)
-	time.Sleep(time.Millisecond * 100)
-	v, q, ts, err := item.Read(OPC_DS_CACHE)
+	v, q, ts, err := item.Read(opcda.OPC_DS_CACHE)
 	assert.NoError(t, err)
 	t.Log(v, q, ts)
 	assert.Equal(t, v, item.GetValue())
 	assert.Equal(t, q, item.GetQuality())
 	assert.Equal(t, ts, item.GetTimestamp())
 	err = item.Write(int32(12))
-	t.Log(err)
-	assert.Error(t, err)
+	assertItemError(t, opcda.OPCBadRights, err)
 }
 
 func TestOPCItem_ReadError(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_item_read_error")
@@ -211,21 +163,16 @@ func TestOPCItem_ReadError(t *testing.T) {
 	item, err := items.AddItem(TestReadErrorItem)
 	assert.NoError(t, err)
 	assert.NotNil(t, item)
-	time.Sleep(time.Millisecond * 100)
-	_, _, _, err = item.Read(OPC_DS_CACHE)
-	assert.Error(t, err)
+	_, _, _, err = item.Read(opcda.OPC_DS_CACHE)
+	assertItemError(t, opcda.OPCBadRights, err)
 }
 
 func TestOPCItemRead(t *testing.T) {
 	tags := []string{
-		"Random.ArrayOfReal8",
-		"Random.ArrayOfString",
 		"Random.Boolean",
 		"Random.Int1",
 		"Random.Int2",
 		"Random.Int4",
-		"Random.Int8",
-		"Random.Qualities",
 		"Random.Real4",
 		"Random.Real8",
 		"Random.String",
@@ -233,14 +180,8 @@ func TestOPCItemRead(t *testing.T) {
 		"Random.UInt1",
 		"Random.UInt2",
 		"Random.UInt4",
-		"Random.UInt8",
 	}
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_item_read")
@@ -256,15 +197,13 @@ func TestOPCItemRead(t *testing.T) {
 			t.Fatalf("add item %s failed: %s\n", tags[i], err)
 		}
 	}
-	time.Sleep(time.Second * 2)
 	for i, item := range itemList {
-		value, quality, timestamp, err := item.Read(OPC_DS_CACHE)
+		value, quality, timestamp, err := item.Read(opcda.OPC_DS_CACHE)
 		if err != nil {
 			t.Fatalf("read item failed: %s\n", err)
 		}
-		if tags[i] != "Random.Qualities" {
-			assert.Equal(t, uint16(192), quality)
-		}
+		assert.Equal(t, com.QualityGood, quality)
+		assert.NotNil(t, value)
 		t.Logf("%s:\t%s\t%d\t%v\n", tags[i], timestamp, quality, value)
 	}
 }
@@ -284,25 +223,19 @@ func TestOPCItemWrite(t *testing.T) {
 		"Bucket Brigade.UInt1",
 		"Bucket Brigade.UInt2",
 		"Bucket Brigade.UInt4",
-		".ArrayOfBool",
-		".ArrayOfByte",
-		".ArrayOfChar",
-		".ArrayOfDate",
-		".ArrayOfReal4",
-		".ArrayOfReal8",
-		".ArrayOfShort",
-		".ArrayOfString",
-		".ArrayOfUlong",
-		".ArrayOfUshort",
-		".ArrayOfLong",
-		".ArrayOfInt",
-		".ArrayOfUint",
-		".ArrayOfInt64",
-		".ArrayOfUint64",
-		".Int",
-		".Uint",
-		".Int64",
-		".Uint64",
+		"Arrays.ArrayOfBool",
+		"Arrays.ArrayOfByte",
+		"Arrays.ArrayOfChar",
+		"Arrays.ArrayOfDate",
+		"Arrays.ArrayOfReal4",
+		"Arrays.ArrayOfShort",
+		"Arrays.ArrayOfUlong",
+		"Arrays.ArrayOfUshort",
+		"Arrays.ArrayOfLong",
+		"Arrays.ArrayOfInt64",
+		"Arrays.ArrayOfUint64",
+		"Arrays.Int64",
+		"Arrays.Uint64",
 	}
 	now := time.Now()
 	millisecond := now.UnixMilli()
@@ -326,27 +259,23 @@ func TestOPCItemWrite(t *testing.T) {
 		[]int8{'a', 'b', 'c'},
 		[]time.Time{writeNow, writeNow},
 		[]float32{1.2, 2.3, 3.4},
-		[]float64{1.2, 2.3, 3.4},
 		[]int16{1, 2, 3},
-		[]string{"hello", "world"},
 		[]uint32{1, 2, 3},
 		[]uint16{1, 2, 3},
 		[]int32{1, 2, 3},
-		[]int{4, 5, 6},
-		[]uint{7, 8, 9},
 		[]int64{10, 11, 13},
 		[]uint64{20, 21, 23},
-		int(100),
-		uint(200),
 		int64(300),
 		uint64(400),
 	}
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	// the default tags have no items of these types
+	simTags := opcsim.DefaultTags()
+	for i, tag := range tags {
+		if strings.HasPrefix(tag, "Arrays.") {
+			simTags = append(simTags, opcsim.Tag{ItemID: tag, Value: reflect.Zero(reflect.TypeOf(values[i])).Interface()})
+		}
+	}
+	_, server := connectSim(t, withSimOptions(opcsim.WithTags(simTags...)))
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test_item_write")
@@ -362,28 +291,17 @@ func TestOPCItemWrite(t *testing.T) {
 			t.Fatalf("add item %s failed: %s\n", tags[i], err)
 		}
 	}
-	time.Sleep(time.Second * 2)
 	for i, item := range itemList {
-		var value interface{}
-		var quality uint16
-		for j := 0; j < 50; j++ {
-			err := item.Write(values[i])
-			if err != nil {
-				t.Fatalf("write item %s failed: %s\n", tags[i], err)
-			}
-			var timestamp time.Time
-			value, quality, timestamp, err = item.Read(OPC_DS_CACHE)
-			t.Logf("%s:\t%s\t%d\t%v\n", tags[i], timestamp, quality, value)
-			if err != nil {
-				t.Fatalf("read item failed: %s\n", err)
-			}
-			if !assert.ObjectsAreEqual(values[i], value) {
-				time.Sleep(time.Millisecond * 500)
-				continue
-			} else {
-				break
-			}
+		err := item.Write(values[i])
+		if err != nil {
+			t.Fatalf("write item %s failed: %s\n", tags[i], err)
 		}
+		value, quality, timestamp, err := item.Read(opcda.OPC_DS_CACHE)
+		if err != nil {
+			t.Fatalf("read item failed: %s\n", err)
+		}
+		t.Logf("%s:\t%s\t%d\t%v\n", tags[i], timestamp, quality, value)
+		assert.Equal(t, com.QualityGood, quality)
 		assert.Equal(t, values[i], value)
 	}
 }
@@ -6,26 +6,27 @@ import (
 	"sync/atomic"
 
 	"github.com/huskar-t/opcda/com"
-
-	"golang.org/x/sys/windows"
 )
 
 type OPCItems struct {
-	itemMgt                  *com.IOPCItemMgt
-	iCommon                  *com.IOPCCommon
+	itemMgt                  ItemMgtBackend
+	iCommon                  CommonBackend
 	parent                   *OPCGroup
 	itemID                   uint32
 	defaultRequestedDataType com.VT
 	defaultAccessPath        string
 	defaultActive            bool
+	clientConversion         bool
 	items                    []*OPCItem
+	// converted holds the items whose values the client converts by server handle
+	converted map[uint32]*OPCItem
 	sync.RWMutex
 }
 
 func NewOPCItems(
 	parent *OPCGroup,
-	itemMgt *com.IOPCItemMgt,
-	iCommon *com.IOPCCommon,
+	itemMgt ItemMgtBackend,
+	iCommon CommonBackend,
 ) *OPCItems {
 	return &OPCItems{
 		parent:                   parent,
@@ -34,6 +35,7 @@ func NewOPCItems(
 		defaultAccessPath:        "",
 		defaultActive:            true,
 		iCommon:                  iCommon,
+		converted:                make(map[uint32]*OPCItem),
 	}
 }
 
@@ -76,6 +78,8 @@ func (is *OPCItems) SetDefaultActive(defaultActive bool) {
 
 // GetCount get the number of items in the collection
 func (is *OPCItems) GetCount() int {
+	is.RLock()
+	defer is.RUnlock()
 	return len(is.items)
 }
 
@@ -129,21 +133,28 @@ func (is *OPCItems) AddItem(tag string) (*OPCItem, error) {
 func (is *OPCItems) AddItems(tags []string) ([]*OPCItem, []error, error) {
 	is.Lock()
 	defer is.Unlock()
-	accessPath := is.defaultAccessPath
-	active := is.defaultActive
-	dt := is.defaultRequestedDataType
-	items := is.createDefinitions(tags, accessPath, active, dt)
+	return is.addItems(is.createDefinitions(tags, is.defaultAccessPath, is.defaultActive, is.defaultRequestedDataType))
+}
+
+// addItems adds items with explicit definitions, the caller holds the lock
+func (is *OPCItems) addItems(items []com.ItemDefinition) ([]*OPCItem, []error, error) {
 	results, errs, err := is.itemMgt.AddItems(items)
 	if err != nil {
 		return nil, nil, err
 	}
-	var resultErrors = make([]error, len(tags))
-	var opcItems = make([]*OPCItem, len(tags))
-	for j := 0; j < len(tags); j++ {
+	converted := is.addConverted(items, results, errs)
+	var resultErrors = make([]error, len(items))
+	var opcItems = make([]*OPCItem, len(items))
+	for j := 0; j < len(items); j++ {
 		if errs[j] < 0 {
 			resultErrors[j] = is.getError(errs[j])
 		} else {
-			item := NewOPCItem(is, tags[j], results[j], items[j].HClient, accessPath, active)
+			item := NewOPCItem(is, items[j].ItemID, results[j], items[j].ClientHandle, items[j].AccessPath, items[j].Active)
+			item.requestedDataType = items[j].RequestedDataType
+			if converted != nil && converted[j] {
+				item.clientConverted = true
+				is.converted[item.serverHandle] = item
+			}
 			opcItems[j] = item
 			is.items = append(is.items, item)
 		}
@@ -164,6 +175,7 @@ func (is *OPCItems) Remove(serverHandles []uint32) {
 	var removedHandles []uint32
 	for _, item := range is.items {
 		if _, ok := toDelete[item.serverHandle]; ok {
+			delete(is.converted, item.serverHandle)
 			removedItems = append(removedItems, item)
 			removedHandles = append(removedHandles, item.serverHandle)
 			continue
@@ -183,23 +195,20 @@ func (is *OPCItems) Remove(serverHandles []uint32) {
 
 // Validate Determines if one or more OPCItems could be successfully created via the Add method (but does not add them).
 func (is *OPCItems) Validate(tags []string, requestedDataTypes *[]com.VT, accessPaths *[]string) ([]error, error) {
-	var definitions []com.TagOPCITEMDEF
+	var definitions []com.ItemDefinition
 	for i, v := range tags {
 		cHandle := atomic.AddUint32(&is.itemID, 1)
-		item := com.TagOPCITEMDEF{
-			SzAccessPath: windows.StringToUTF16Ptr(""),
-			SzItemID:     windows.StringToUTF16Ptr(v),
-			BActive:      com.BoolToComBOOL(false),
-			HClient:      cHandle,
-			DwBlobSize:   0,
-			PBlob:        nil,
-			VtRequested:  uint16(is.defaultRequestedDataType),
+		item := com.ItemDefinition{
+			ItemID:            v,
+			Active:            false,
+			ClientHandle:      cHandle,
+			RequestedDataType: is.defaultRequestedDataType,
 		}
 		if requestedDataTypes != nil {
-			item.VtRequested = uint16((*requestedDataTypes)[i])
+			item.RequestedDataType = (*requestedDataTypes)[i]
 		}
 		if accessPaths != nil {
-			item.SzAccessPath = windows.StringToUTF16Ptr((*accessPaths)[i])
+			item.AccessPath = (*accessPaths)[i]
 		}
 		definitions = append(definitions, item)
 	}
@@ -267,6 +276,143 @@ func (is *OPCItems) SetDataTypes(serverHandles []uint32, requestedDataTypes []co
 	return resultErrors
 }
 
+// GetDeadbands Returns the percent deadbands of one or more Items, see OPCItem.GetDeadband
+func (is *OPCItems) GetDeadbands(serverHandles []uint32) ([]float32, []error) {
+	deadbands := make([]float32, len(serverHandles))
+	resultErrors := make([]error, len(serverHandles))
+	for i, handle := range serverHandles {
+		item, err := is.GetOPCItem(handle)
+		if err != nil {
+			resultErrors[i] = err
+			continue
+		}
+		deadbands[i], resultErrors[i] = item.GetDeadband()
+	}
+	return deadbands, resultErrors
+}
+
+// SetDeadbands Changes the percent deadbands of one or more Items, see OPCItem.SetDeadband
+func (is *OPCItems) SetDeadbands(serverHandles []uint32, percentDeadbands []float32) ([]error, error) {
+	if len(percentDeadbands) != len(serverHandles) {
+		return nil, errors.New("percentDeadbands and serverHandles have different lengths")
+	}
+	resultErrors := make([]error, len(serverHandles))
+	for i, handle := range serverHandles {
+		item, err := is.GetOPCItem(handle)
+		if err != nil {
+			resultErrors[i] = err
+			continue
+		}
+		err = item.SetDeadband(percentDeadbands[i])
+		if err != nil {
+			resultErrors[i] = err
+		}
+	}
+	return resultErrors, nil
+}
+
+// ClearDeadbands Clears the percent deadbands of one or more Items, see OPCItem.ClearDeadband
+func (is *OPCItems) ClearDeadbands(serverHandles []uint32) []error {
+	resultErrors := make([]error, len(serverHandles))
+	for i, handle := range serverHandles {
+		item, err := is.GetOPCItem(handle)
+		if err != nil {
+			resultErrors[i] = err
+			continue
+		}
+		err = item.ClearDeadband()
+		if err != nil {
+			resultErrors[i] = err
+		}
+	}
+	return resultErrors
+}
+
+// GetSamplingRates Returns the sampling rates of one or more Items, see OPCItem.GetSamplingRate
+func (is *OPCItems) GetSamplingRates(serverHandles []uint32) ([]uint32, []error) {
+	samplingRates := make([]uint32, len(serverHandles))
+	resultErrors := make([]error, len(serverHandles))
+	for i, handle := range serverHandles {
+		item, err := is.GetOPCItem(handle)
+		if err != nil {
+			resultErrors[i] = err
+			continue
+		}
+		samplingRates[i], resultErrors[i] = item.GetSamplingRate()
+	}
+	return samplingRates, resultErrors
+}
+
+// SetSamplingRates Changes the sampling rates of one or more Items and returns the revised rates, see OPCItem.SetSamplingRate
+func (is *OPCItems) SetSamplingRates(serverHandles []uint32, samplingRates []uint32) ([]uint32, []error, error) {
+	if len(samplingRates) != len(serverHandles) {
+		return nil, nil, errors.New("samplingRates and serverHandles have different lengths")
+	}
+	revisedSamplingRates := make([]uint32, len(serverHandles))
+	resultErrors := make([]error, len(serverHandles))
+	for i, handle := range serverHandles {
+		item, err := is.GetOPCItem(handle)
+		if err != nil {
+			resultErrors[i] = err
+			continue
+		}
+		revisedSamplingRates[i], resultErrors[i] = item.SetSamplingRate(samplingRates[i])
+	}
+	return revisedSamplingRates, resultErrors, nil
+}
+
+// ClearSamplingRates Clears the sampling rates of one or more Items, see OPCItem.ClearSamplingRate
+func (is *OPCItems) ClearSamplingRates(serverHandles []uint32) []error {
+	resultErrors := make([]error, len(serverHandles))
+	for i, handle := range serverHandles {
+		item, err := is.GetOPCItem(handle)
+		if err != nil {
+			resultErrors[i] = err
+			continue
+		}
+		err = item.ClearSamplingRate()
+		if err != nil {
+			resultErrors[i] = err
+		}
+	}
+	return resultErrors
+}
+
+// GetBufferEnable Returns whether the samples of one or more Items are buffered, see OPCItem.GetBufferEnable
+func (is *OPCItems) GetBufferEnable(serverHandles []uint32) ([]bool, []error) {
+	enable := make([]bool, len(serverHandles))
+	resultErrors := make([]error, len(serverHandles))
+	for i, handle := range serverHandles {
+		item, err := is.GetOPCItem(handle)
+		if err != nil {
+			resultErrors[i] = err
+			continue
+		}
+		enable[i], resultErrors[i] = item.GetBufferEnable()
+	}
+	return enable, resultErrors
+}
+
+// SetBufferEnable Changes whether the samples of one or more Items are buffered, see OPCItem.SetBufferEnable
+func (is *OPCItems) SetBufferEnable(serverHandles []uint32, enable []bool) ([]error, error) {
+	if len(enable) != len(serverHandles) {
+		return nil, errors.New("enable and serverHandles have different lengths")
+	}
+	resultErrors := make([]error, len(serverHandles))
+	for i, handle := range serverHandles {
+		item, err := is.GetOPCItem(handle)
+		if err != nil {
+			resultErrors[i] = err
+			continue
+		}
+		err = item.SetBufferEnable(enable[i])
+		if err != nil {
+			resultErrors[i] = err
+		}
+	}
+	return resultErrors, nil
+}
+
 // Release Releases the OPCItems collection and all associated resources.
 func (is *OPCItems) Release() {
 	for _, item := range is.items {
@@ -275,18 +421,16 @@ func (is *OPCItems) Release() {
 	is.itemMgt.Release()
 }
 
-func (is *OPCItems) createDefinitions(tags []string, accessPath string, active bool, requestedDataType com.VT) []com.TagOPCITEMDEF {
-	var definitions []com.TagOPCITEMDEF
+func (is *OPCItems) createDefinitions(tags []string, accessPath string, active bool, requestedDataType com.VT) []com.ItemDefinition {
+	var definitions []com.ItemDefinition
 	for _, v := range tags {
 		cHandle := atomic.AddUint32(&is.itemID, 1)
-		definitions = append(definitions, com.TagOPCITEMDEF{
-			SzAccessPath: windows.StringToUTF16Ptr(accessPath),
-			SzItemID:     windows.StringToUTF16Ptr(v),
-			BActive:      com.BoolToComBOOL(active),
-			HClient:      cHandle,
-			DwBlobSize:   0,
-			PBlob:        nil,
-			VtRequested:  uint16(requestedDataType),
+		definitions = append(definitions, com.ItemDefinition{
+			AccessPath:        accessPath,
+			ItemID:            v,
+			Active:            active,
+			ClientHandle:      cHandle,
+			RequestedDataType: requestedDataType,
 		})
 	}
 	return definitions
@@ -1,20 +1,14 @@
-package opcda
+package opcda_test
 
 import (
 	"testing"
 
 	"github.com/huskar-t/opcda/com"
-
 	"github.com/stretchr/testify/assert"
 )
 
 func TestOPCItems_RequestedDataType(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -30,12 +24,7 @@ func TestOPCItems_RequestedDataType(t *testing.T) {
 }
 
 func TestOPCItems_DefaultAccessPath(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -50,12 +39,7 @@ func TestOPCItems_DefaultAccessPath(t *testing.T) {
 }
 
 func TestOPCItems_DefaultActive(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -69,12 +53,7 @@ func TestOPCItems_DefaultActive(t *testing.T) {
 }
 
 func TestOPCItems_AddItems(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -136,12 +115,7 @@ func TestOPCItems_AddItems(t *testing.T) {
 }
 
 func TestOPCItems_Validate(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -176,12 +150,7 @@ func TestOPCItems_Validate(t *testing.T) {
 }
 
 func TestOPCItems_SetActive(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -207,12 +176,7 @@ func TestOPCItems_SetActive(t *testing.T) {
 }
 
 func TestOPCItems_SetClientHandles(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
@@ -238,12 +202,7 @@ func TestOPCItems_SetClientHandles(t *testing.T) {
 }
 
 func TestOPCItems_SetDataTypes(t *testing.T) {
-	server, err := Connect(TestProgID, TestHost)
-	assert.NoError(t, err)
-	defer func() {
-		err = server.Disconnect()
-		assert.NoError(t, err)
-	}()
+	_, server := connectSim(t)
 	groups := server.GetOPCGroups()
 	assert.NotNil(t, groups)
 	group, err := groups.Add("test")
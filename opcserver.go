@@ -1,352 +1,167 @@
 package opcda
 
 import (
-	"errors"
-	"fmt"
+	"sync"
 	"time"
-	"unsafe"
 
 	"github.com/huskar-t/opcda/com"
-	"golang.org/x/sys/windows/registry"
-
-	"golang.org/x/sys/windows"
 )
 
 type OPCServer struct {
-	iServer       *com.IOPCServer
-	iCommon       *com.IOPCCommon
-	iItemProperty *com.IOPCItemProperties
+	iServer       ServerBackend
+	iCommon       CommonBackend
+	iItemProperty ItemPropertiesBackend
 	groups        *OPCGroups
 	Name          string
 	Node          string
 	clientName    string
-	location      com.CLSCTX
 
-	container *com.IConnectionPointContainer
-	point     *com.IConnectionPoint
-	event     *ShutdownEventReceiver
-	cookie    uint32
+	shutdownLock      sync.Mutex
+	shutdownAdvised   bool
+	shutdownCookie    uint32
+	shutdownReceivers []chan string
+
+	queryLock     sync.Mutex
+	browseQueried bool
+	iBrowse       BrowseBackend
+	iItemIO       ItemIOBackend
+
+	state connectionState
+	// executor is the executor Connect started for the server, it is closed by Disconnect
+	executor *com.Executor
 }
 
-// Connect connect to OPC server
-func Connect(progID, node string) (opcServer *OPCServer, err error) {
-	location := com.CLSCTX_LOCAL_SERVER
-	if !com.IsLocal(node) {
-		location = com.CLSCTX_REMOTE_SERVER
-	}
-	clsid, err := getClsID(progID, node, location)
-	if err != nil {
-		return nil, NewOPCWrapperError("get clsid", err)
-	}
-	iUnknownServer, err := com.MakeCOMObjectEx(node, location, clsid, &com.IID_IOPCServer)
-	if err != nil {
-		return nil, NewOPCWrapperError("make com object IOPCServer", err)
-	}
-	defer func() {
-		if err != nil {
-			iUnknownServer.Release()
-		}
-	}()
-	var iUnknownCommon *com.IUnknown
-	err = iUnknownServer.QueryInterface(&com.IID_IOPCCommon, unsafe.Pointer(&iUnknownCommon))
-	if err != nil {
-		return nil, NewOPCWrapperError("server query interface IOPCCommon", err)
-	}
-	defer func() {
-		if err != nil {
-			iUnknownCommon.Release()
-		}
-	}()
-	var iUnknownItemProperties *com.IUnknown
-	err = iUnknownServer.QueryInterface(&com.IID_IOPCItemProperties, unsafe.Pointer(&iUnknownItemProperties))
-	if err != nil {
-		return nil, NewOPCWrapperError("server query interface IOPCItemProperties", err)
-	}
-	defer func() {
-		if err != nil {
-			iUnknownItemProperties.Release()
-		}
-	}()
-	server := &com.IOPCServer{IUnknown: iUnknownServer}
-	common := &com.IOPCCommon{IUnknown: iUnknownCommon}
-	itemProperties := &com.IOPCItemProperties{IUnknown: iUnknownItemProperties}
-	opcServer = &OPCServer{
-		iServer:       server,
-		iCommon:       common,
-		iItemProperty: itemProperties,
-		Name:          progID,
-		Node:          node,
-		location:      location,
-	}
-	opcServer.groups = NewOPCGroups(opcServer)
-	return opcServer, nil
+type connectOptions struct {
+	transport   Transport
+	credentials *Credentials
+	executor    *com.Executor
+	initConfig  *com.InitConfig
 }
 
-func getClsID(progID, node string, location com.CLSCTX) (clsid *windows.GUID, err error) {
-	if location == com.CLSCTX_LOCAL_SERVER {
-		id, err := windows.GUIDFromString(progID)
-		if err != nil {
-			return nil, NewOPCWrapperError("windows.GUIDFromString", err)
-		}
-		return &id, nil
-	} else {
-		var errorList []error
-		// try get clsid from server list
-		clsid, err = getClsIDFromServerListV2(progID, node, location)
-		if err == nil {
-			return clsid, nil
-		}
-		errorList = append(errorList, fmt.Errorf("get clsid from server list v2 error: %v", err))
-		// try v1
-		clsid, err = getClsIDFromServerListV1(progID, node, location)
-		if err == nil {
-			return clsid, nil
-		}
-		errorList = append(errorList, fmt.Errorf("get clsid from server list v1 error: %v", err))
-		// try get clsid from windows reg
-		clsid, err = getClsIDFromReg(progID, node)
-		if err == nil {
-			return clsid, nil
-		}
-		errorList = append(errorList, fmt.Errorf("get clsid from reg error: %v", err))
-		return nil, errors.Join(errorList...)
-	}
+// Credentials authenticate the connection to a remote server with NTLMv2 instead of the current Windows user
+type Credentials struct {
+	Domain   string
+	User     string
+	Password string
+	// AuthnLevel is one of the com.RPC_C_AUTHN_LEVEL_* values, packet privacy when zero
+	AuthnLevel uint32
+	// ImpLevel is one of the com.RPC_C_IMP_LEVEL_* values, impersonate when zero
+	ImpLevel uint32
 }
 
-func getClsIDFromServerListV2(progID, node string, location com.CLSCTX) (*windows.GUID, error) {
-	iCatInfo, err := com.MakeCOMObjectEx(node, location, &com.CLSID_OpcServerList, &com.IID_IOPCServerList2)
-	if err != nil {
-		return nil, err
+func (c *Credentials) authnLevel() uint32 {
+	if c.AuthnLevel == com.RPC_C_AUTHN_LEVEL_DEFAULT {
+		return com.RPC_C_AUTHN_LEVEL_PKT_PRIVACY
 	}
-	defer iCatInfo.Release()
-	sl := &com.IOPCServerList2{IUnknown: iCatInfo}
-	clsid, err := sl.CLSIDFromProgID(progID)
-	if err != nil {
-		return nil, err
-	}
-	return clsid, nil
+	return c.AuthnLevel
 }
 
-func getClsIDFromServerListV1(progID, node string, location com.CLSCTX) (*windows.GUID, error) {
-	iCatInfo, err := com.MakeCOMObjectEx(node, location, &com.CLSID_OpcServerList, &com.IID_IOPCServerList)
-	if err != nil {
-		return nil, err
+func (c *Credentials) impLevel() uint32 {
+	if c.ImpLevel == com.RPC_C_IMP_LEVEL_DEFAULT {
+		return com.RPC_C_IMP_LEVEL_IMPERSONATE
 	}
-	defer iCatInfo.Release()
-	sl := &com.IOPCServerList{IUnknown: iCatInfo}
-	clsid, err := sl.CLSIDFromProgID(progID)
-	if err != nil {
-		return nil, err
-	}
-	return clsid, nil
+	return c.ImpLevel
 }
 
-func getClsIDFromReg(progID, node string) (*windows.GUID, error) {
-	var clsid *windows.GUID
-	var err error
-	hKey, err := registry.OpenRemoteKey(node, registry.CLASSES_ROOT)
-	if err != nil {
-		return nil, err
-	}
-	defer hKey.Close()
+// ConnectOption configures Connect
+type ConnectOption func(*connectOptions)
 
-	hProgIDKey, err := registry.OpenKey(hKey, progID, registry.READ)
-	if err != nil {
-		return nil, err
+// WithTransport selects the transport used to reach the server, see COMTransport and DCOMTransport.
+func WithTransport(transport Transport) ConnectOption {
+	return func(o *connectOptions) {
+		o.transport = transport
 	}
-	defer hProgIDKey.Close()
-	_, clsid, err = getClsidFromProgIDKey(hProgIDKey)
-	return clsid, err
 }
 
-func getClsidFromProgIDKey(hProgIDKey registry.Key) (string, *windows.GUID, error) {
-	hClsidKey, err := registry.OpenKey(hProgIDKey, "CLSID", registry.READ)
-	if err != nil {
-		return "", nil, err
-	}
-	defer hClsidKey.Close()
-	clsidStr, _, err := hClsidKey.GetStringValue("")
-	if err != nil {
-		return "", nil, err
+// WithCredentials authenticates to a remote node with an explicit account, see Credentials.
+// The COM transport applies them to remote servers only, local servers always run as the current user.
+func WithCredentials(credentials *Credentials) ConnectOption {
+	return func(o *connectOptions) {
+		o.credentials = credentials
 	}
-	clsid, err := windows.GUIDFromString(clsidStr)
-	return clsidStr, &clsid, err
 }
 
-type ServerInfo struct {
-	ProgID       string
-	ClsStr       string
-	VerIndProgID string
-	ClsID        *windows.GUID
+// WithExecutor runs the connection and every backend call of the server, Release included, on the threads of
+// executor. The executor may be shared by several servers and is not closed by Disconnect. Without it the COM
+// transport starts an executor of its own for each server, see WithInitConfig, the other transports call the backend
+// directly.
+func WithExecutor(executor *com.Executor) ConnectOption {
+	return func(o *connectOptions) {
+		o.executor = executor
+	}
 }
 
-// GetOPCServers get OPC servers from node
-func GetOPCServers(node string) ([]*ServerInfo, error) {
-	var errorList []error
-	result, err := getServersFromOpcServerListV2(node)
-	if err == nil {
-		return result, nil
+// WithInitConfig initializes COM with config on the executor the COM transport starts when WithExecutor is not given,
+// the Apartment of config selects an MTA or an STA executor. It is ignored with WithExecutor and by the other
+// transports.
+func WithInitConfig(config *com.InitConfig) ConnectOption {
+	return func(o *connectOptions) {
+		o.initConfig = config
 	}
-	errorList = append(errorList, fmt.Errorf("get servers from opc server list v2 error: %v", err))
-	// try v1
-	result, err = getServersFromOpcServerListV1(node)
-	if err == nil {
-		return result, nil
-	}
-	errorList = append(errorList, fmt.Errorf("get servers from opc server list v1 error: %v", err))
-	// try windows reg
-	result, err = getServersFromReg(node)
-	if err == nil {
-		return result, nil
-	}
-	errorList = append(errorList, fmt.Errorf("get servers from reg error: %v", err))
-	return nil, errors.Join(errorList...)
 }
 
-func getServersFromOpcServerListV2(node string) ([]*ServerInfo, error) {
-	location := com.CLSCTX_LOCAL_SERVER
-	if !com.IsLocal(node) {
-		location = com.CLSCTX_REMOTE_SERVER
+// Connect connect to OPC server.
+// The COM transport is used on Windows and the DCOM transport elsewhere unless WithTransport is given.
+func Connect(progID, node string, opts ...ConnectOption) (opcServer *OPCServer, err error) {
+	options := &connectOptions{}
+	for _, opt := range opts {
+		opt(options)
 	}
-	iCatInfo, err := com.MakeCOMObjectEx(node, location, &com.CLSID_OpcServerList, &com.IID_IOPCServerList2)
-	if err != nil {
-		return nil, NewOPCWrapperError("make com object IOPCServerListV2", err)
+	if options.transport == nil {
+		options.transport = defaultTransport()
 	}
-	cids := []windows.GUID{IID_CATID_OPCDAServer10, IID_CATID_OPCDAServer20}
-	defer iCatInfo.Release()
-	sl := &com.IOPCServerList2{IUnknown: iCatInfo}
-	iEnum, err := sl.EnumClassesOfCategories(cids, nil)
-	if err != nil {
-		return nil, NewOPCWrapperError("enum classes of categories with IOPCServerListV2", err)
-	}
-	defer iEnum.Release()
-	var result []*ServerInfo
-	for {
-		var classID windows.GUID
-		var actual uint32
-		err = iEnum.Next(1, &classID, &actual)
+	executor := options.executor
+	var ownExecutor *com.Executor
+	if executor == nil {
+		ownExecutor, err = defaultExecutor(options.transport, options.initConfig)
 		if err != nil {
-			break
+			return nil, NewOPCWrapperError("start executor", err)
 		}
-		server, err := getServer(sl, &classID)
-		if err != nil {
-			return nil, NewOPCWrapperError("IOPCServerListV2 getServer", err)
-		}
-		result = append(result, server)
-	}
-	return result, nil
-}
-
-func getServersFromOpcServerListV1(node string) ([]*ServerInfo, error) {
-	location := com.CLSCTX_LOCAL_SERVER
-	if !com.IsLocal(node) {
-		location = com.CLSCTX_REMOTE_SERVER
+		executor = ownExecutor
 	}
-	iCatInfo, err := com.MakeCOMObjectEx(node, location, &com.CLSID_OpcServerList, &com.IID_IOPCServerList)
-	if err != nil {
-		return nil, NewOPCWrapperError("make com object IOPCServerListV1", err)
+	if ownExecutor != nil {
+		defer func() {
+			if err != nil {
+				ownExecutor.Close()
+			}
+		}()
 	}
-	cids := []windows.GUID{IID_CATID_OPCDAServer10, IID_CATID_OPCDAServer20}
-	defer iCatInfo.Release()
-	sl := &com.IOPCServerList{IUnknown: iCatInfo}
-	iEnum, err := sl.EnumClassesOfCategories(cids, nil)
-	if err != nil {
-		return nil, NewOPCWrapperError("enum classes of categories with IOPCServerListV1", err)
-	}
-	defer iEnum.Release()
-	var result []*ServerInfo
-	for {
-		var classID windows.GUID
-		var actual uint32
-		err = iEnum.Next(1, &classID, &actual)
-		if err != nil {
-			break
-		}
-		server, err := getServerV1(sl, &classID)
-		if err != nil {
-			return nil, NewOPCWrapperError("IOPCServerListV1 getServer", err)
-		}
-		result = append(result, server)
+	transport := options.transport
+	if executor != nil {
+		transport = executorTransport(transport, executor)
 	}
-	return result, nil
-}
-
-func getServersFromReg(node string) ([]*ServerInfo, error) {
-	var result []*ServerInfo
-	var err error
-	hKey, err := registry.OpenRemoteKey(node, registry.CLASSES_ROOT)
+	server, err := transport.Connect(progID, node, options.credentials)
 	if err != nil {
 		return nil, err
 	}
-	defer hKey.Close()
-	tsKeys, _ := hKey.ReadSubKeyNames(-1)
-	for _, tsKey := range tsKeys {
-		info := getServersFromKey(hKey, tsKey)
-		if info != nil {
-			result = append(result, info)
+	defer func() {
+		if err != nil {
+			server.Release()
 		}
-	}
-	return result, nil
-}
-
-func getServersFromKey(hKey registry.Key, progID string) *ServerInfo {
-	hProgIDKey, err := registry.OpenKey(hKey, progID, registry.READ)
-	if err != nil {
-		return nil
-	}
-	defer hProgIDKey.Close()
-	hOPCKey, err := registry.OpenKey(hProgIDKey, "OPC", registry.READ)
-	if err != nil {
-		return nil
-	}
-	defer hOPCKey.Close()
-	clsidStr, clsid, err := getClsidFromProgIDKey(hProgIDKey)
-	if err != nil {
-		return nil
-	}
-	return &ServerInfo{
-		ProgID:       progID,
-		ClsStr:       clsidStr,
-		VerIndProgID: progID,
-		ClsID:        clsid,
-	}
-}
-
-func getServer(sl *com.IOPCServerList2, classID *windows.GUID) (*ServerInfo, error) {
-	progID, userType, VerIndProgID, err := sl.GetClassDetails(classID)
+	}()
+	common, err := server.QueryCommon()
 	if err != nil {
-		return nil, fmt.Errorf("FAILED to get prog ID from class ID: %w", err)
+		return nil, NewOPCWrapperError("server query interface IOPCCommon", err)
 	}
 	defer func() {
-		com.CoTaskMemFree(unsafe.Pointer(progID))
-		com.CoTaskMemFree(unsafe.Pointer(userType))
-		com.CoTaskMemFree(unsafe.Pointer(VerIndProgID))
+		if err != nil {
+			common.Release()
+		}
 	}()
-	clsStr := classID.String()
-	return &ServerInfo{
-		ProgID:       windows.UTF16PtrToString(progID),
-		ClsStr:       clsStr,
-		ClsID:        classID,
-		VerIndProgID: windows.UTF16PtrToString(VerIndProgID),
-	}, nil
-}
-
-func getServerV1(sl *com.IOPCServerList, classID *windows.GUID) (*ServerInfo, error) {
-	progID, userType, err := sl.GetClassDetails(classID)
+	itemProperties, err := server.QueryItemProperties()
 	if err != nil {
-		return nil, fmt.Errorf("FAILED to get prog ID from class ID: %w", err)
+		return nil, NewOPCWrapperError("server query interface IOPCItemProperties", err)
 	}
-	defer func() {
-		com.CoTaskMemFree(unsafe.Pointer(progID))
-		com.CoTaskMemFree(unsafe.Pointer(userType))
-	}()
-	clsStr := classID.String()
-	return &ServerInfo{
-		ProgID:       windows.UTF16PtrToString(progID),
-		ClsStr:       clsStr,
-		ClsID:        classID,
-		VerIndProgID: "",
-	}, nil
+	opcServer = &OPCServer{
+		iServer:       server,
+		iCommon:       common,
+		iItemProperty: itemProperties,
+		Name:          progID,
+		Node:          node,
+		executor:      ownExecutor,
+	}
+	opcServer.groups = NewOPCGroups(opcServer)
+	return opcServer, nil
 }
 
 // GetLocaleID get locale ID
@@ -355,6 +170,11 @@ func (s *OPCServer) GetLocaleID() (uint32, error) {
 	return localeID, err
 }
 
+// GetStatus Returns the status of the server, the other status getters each call it once
+func (s *OPCServer) GetStatus() (*com.ServerStatus, error) {
+	return s.iServer.GetStatus()
+}
+
 // GetStartTime Returns the time the server started running
 func (s *OPCServer) GetStartTime() (time.Time, error) {
 	status, err := s.iServer.GetStatus()
@@ -536,58 +356,53 @@ func (s *OPCServer) errors(errs []int32) []error {
 
 // RegisterServerShutDown register server shut down event
 func (s *OPCServer) RegisterServerShutDown(ch chan string) error {
-	if s.event == nil {
-		var err error
-		var iUnknownContainer *com.IUnknown
-		var point *com.IConnectionPoint
-		var cookie uint32
-
-		err = s.iServer.IUnknown.QueryInterface(&com.IID_IConnectionPointContainer, unsafe.Pointer(&iUnknownContainer))
-		if err != nil {
-			return NewOPCWrapperError("query interface IConnectionPointContainer", err)
-		}
-		defer func() {
-			if err != nil {
-				iUnknownContainer.Release()
-			}
-		}()
-		container := &com.IConnectionPointContainer{IUnknown: iUnknownContainer}
-		point, err = container.FindConnectionPoint(&IID_IOPCShutdown)
-		if err != nil {
-			return NewOPCWrapperError("container find connect point", err)
-		}
-		defer func() {
-			if err != nil {
-				point.Release()
-			}
-		}()
-		event := NewShutdownEventReceiver()
-		cookie, err = point.Advise((*com.IUnknown)(unsafe.Pointer(event)))
+	s.shutdownLock.Lock()
+	defer s.shutdownLock.Unlock()
+	if !s.shutdownAdvised {
+		cookie, err := s.iServer.AdviseShutdown(s.fireShutdown)
 		if err != nil {
-			return NewOPCWrapperError("point advise", err)
+			return err
 		}
-		s.container = container
-		s.point = point
-		s.event = event
-		s.cookie = cookie
+		s.shutdownAdvised = true
+		s.shutdownCookie = cookie
 	}
-	s.event.AddReceiver(ch)
+	s.shutdownReceivers = append(s.shutdownReceivers, ch)
 	return nil
 }
 
+func (s *OPCServer) fireShutdown(reason string) {
+	s.shutdownLock.Lock()
+	defer s.shutdownLock.Unlock()
+	for _, ch := range s.shutdownReceivers {
+		select {
+		case ch <- reason:
+		default:
+		}
+	}
+}
+
 // Disconnect from OPC server
 func (s *OPCServer) Disconnect() error {
 	var err error
-	if s.point != nil {
-		err = s.point.Unadvise(s.cookie)
-		s.point.Release()
-	}
-	if s.container != nil {
-		s.container.Release()
+	s.shutdownLock.Lock()
+	if s.shutdownAdvised {
+		err = s.iServer.UnadviseShutdown(s.shutdownCookie)
+		s.shutdownAdvised = false
 	}
+	s.shutdownLock.Unlock()
 	if s.groups != nil {
 		s.groups.Release()
 	}
+	s.queryLock.Lock()
+	if s.iBrowse != nil {
+		s.iBrowse.Release()
+		s.iBrowse = nil
+	}
+	if s.iItemIO != nil {
+		s.iItemIO.Release()
+		s.iItemIO = nil
+	}
+	s.queryLock.Unlock()
 	if s.iItemProperty != nil {
 		s.iItemProperty.Release()
 	}
@@ -597,5 +412,8 @@ func (s *OPCServer) Disconnect() error {
 	if s.iServer != nil {
 		s.iServer.Release()
 	}
+	if s.executor != nil {
+		s.executor.Close()
+	}
 	return err
 }
@@ -0,0 +1,361 @@
+//go:build windows
+// +build windows
+
+package opcda
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const TestProgID = "Matrikon.OPC.Simulation.1"
+const TestHost = "localhost"
+const TestServiceName = "MatrikonOPC Server for Simulation and Testing"
+
+func TestMain(m *testing.M) {
+	err := com.Initialize()
+	if err != nil {
+		panic(err)
+	}
+	com.Uninitialize()
+	err = com.Initialize()
+	if err != nil {
+		panic(err)
+	}
+	defer com.Uninitialize()
+	os.Exit(m.Run())
+}
+func TestServers(t *testing.T) {
+	serverInfos, err := GetOPCServers(TestHost)
+	assert.NoError(t, err)
+	assert.Greater(t, len(serverInfos), 0)
+	for i := 0; i < len(serverInfos); i++ {
+		if serverInfos[i].ProgID == TestProgID {
+			return
+		}
+	}
+	t.Fatalf("not found progID %s", TestProgID)
+}
+
+func TestServersFromOpcV1(t *testing.T) {
+	serverInfos, err := getServersFromOpcServerListV1(TestHost)
+	assert.NoError(t, err)
+	assert.Greater(t, len(serverInfos), 0)
+	for i := 0; i < len(serverInfos); i++ {
+		if serverInfos[i].ProgID == TestProgID {
+			return
+		}
+	}
+	t.Fatalf("not found progID %s", TestProgID)
+}
+
+func TestServersFromOpcV2(t *testing.T) {
+	serverInfos, err := getServersFromOpcServerListV2(TestHost)
+	assert.NoError(t, err)
+	assert.Greater(t, len(serverInfos), 0)
+	for i := 0; i < len(serverInfos); i++ {
+		if serverInfos[i].ProgID == TestProgID {
+			return
+		}
+	}
+	t.Fatalf("not found progID %s", TestProgID)
+}
+
+func TestServersFromOPCMixed(t *testing.T) {
+	serverInfosV1, err := getServersFromOpcServerListV1(TestHost)
+	assert.NoError(t, err)
+	assert.Greater(t, len(serverInfosV1), 0)
+	serverInfosV2, err := getServersFromOpcServerListV2(TestHost)
+	assert.NoError(t, err)
+	assert.Greater(t, len(serverInfosV2), 0)
+	assert.Equal(t, len(serverInfosV1), len(serverInfosV2))
+
+	sort.Slice(serverInfosV1, func(i, j int) bool {
+		return serverInfosV1[i].ProgID < serverInfosV1[j].ProgID
+	})
+	sort.Slice(serverInfosV2, func(i, j int) bool {
+		return serverInfosV2[i].ProgID < serverInfosV2[j].ProgID
+	})
+	for i := 0; i < len(serverInfosV1); i++ {
+		assert.Equal(t, serverInfosV1[i].ProgID, serverInfosV2[i].ProgID)
+		assert.Equal(t, serverInfosV1[i].ClsStr, serverInfosV2[i].ClsStr)
+		assert.Equal(t, serverInfosV1[i].ClsID, serverInfosV2[i].ClsID)
+		assert.Empty(t, serverInfosV1[i].VerIndProgID)
+		assert.NotEmpty(t, serverInfosV2[i].VerIndProgID)
+	}
+}
+
+// Can be tested manually, but cannot be tested automatically
+func TestOPCServer_RegisterServerShutDown(t *testing.T) {
+	server, err := Connect(TestProgID, TestHost)
+	assert.NoError(t, err)
+	defer func() {
+		err = server.Disconnect()
+		// error expected here because service is stopped in the test
+		assert.Error(t, err)
+	}()
+	ch := make(chan string, 1)
+	err = server.RegisterServerShutDown(ch)
+	assert.NoError(t, err)
+	done := make(chan struct{})
+	go func() {
+		manager, err := mgr.Connect()
+		assert.NoError(t, err)
+		defer func() {
+			err = manager.Disconnect()
+			assert.NoError(t, err)
+		}()
+		serviceObj, err := manager.OpenService(TestServiceName)
+		assert.NoError(t, err)
+		defer func() {
+			err = serviceObj.Close()
+			assert.NoError(t, err)
+		}()
+		defer func() {
+			for i := 0; i < 10; i++ {
+				time.Sleep(time.Second)
+				status, err := serviceObj.Query()
+				assert.NoError(t, err)
+				t.Log(status.State)
+				if status.State == svc.Stopped {
+					err = serviceObj.Start()
+					assert.NoError(t, err)
+					break
+				}
+			}
+			close(done)
+		}()
+		_, err = serviceObj.Control(svc.Stop)
+		assert.NoError(t, err)
+
+		t.Logf("Service %s stopped", TestServiceName)
+	}()
+	select {
+	case reason := <-ch:
+		t.Log(reason)
+	}
+	<-done
+}
+
+func Test_getClsIDFromReg(t *testing.T) {
+	id, err := windows.GUIDFromString(TestProgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localNode, err := windows.ComputerName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	type args struct {
+		progID string
+		node   string
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		want    *windows.GUID
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "normal",
+			args: args{
+				progID: TestProgID,
+				node:   localNode,
+			},
+			want:    &id,
+			wantErr: assert.NoError,
+		},
+		{
+			name: "wrong node",
+			args: args{
+				progID: TestProgID,
+				node:   "wrong",
+			},
+			want:    nil,
+			wantErr: assert.Error,
+		},
+		{
+			name: "wrong progID",
+			args: args{
+				progID: "wrong",
+				node:   localNode,
+			},
+			want:    nil,
+			wantErr: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getClsIDFromReg(tt.args.progID, tt.args.node)
+			if !tt.wantErr(t, err, fmt.Sprintf("getClsIDFromReg(%v, %v)", tt.args.progID, tt.args.node)) {
+				return
+			}
+			assert.Equalf(t, tt.want, got, "getClsIDFromReg(%v, %v)", tt.args.progID, tt.args.node)
+		})
+	}
+}
+
+func Test_getClsIDFromServerList(t *testing.T) {
+	id, err := windows.GUIDFromString(TestProgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type args struct {
+		progID   string
+		node     string
+		location com.CLSCTX
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *windows.GUID
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "Test with valid progID and node",
+			args: args{
+				progID:   TestProgID,
+				node:     TestHost,
+				location: com.CLSCTX_LOCAL_SERVER,
+			},
+			want:    &id,
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Test with invalid progID",
+			args: args{
+				progID:   "InvalidProgID",
+				node:     TestHost,
+				location: com.CLSCTX_LOCAL_SERVER,
+			},
+			want:    nil,
+			wantErr: assert.Error,
+		},
+		{
+			name: "Test with invalid node",
+			args: args{
+				progID:   TestProgID,
+				node:     "InvalidNode",
+				location: com.CLSCTX_REMOTE_SERVER,
+			},
+			want:    nil,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getClsIDFromServerListV2(tt.args.progID, tt.args.node, tt.args.location, nil)
+			if !tt.wantErr(t, err, fmt.Sprintf("getClsIDFromServerListV2(%v, %v, %v)", tt.args.progID, tt.args.node, tt.args.location)) {
+				return
+			}
+			assert.Equalf(t, tt.want, got, "getClsIDFromServerListV2(%v, %v, %v)", tt.args.progID, tt.args.node, tt.args.location)
+		})
+	}
+}
+
+func Test_getClsIDFromServerListV1(t *testing.T) {
+	id, err := windows.GUIDFromString(TestProgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type args struct {
+		progID   string
+		node     string
+		location com.CLSCTX
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *windows.GUID
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "Test with valid progID and node",
+			args: args{
+				progID:   TestProgID,
+				node:     TestHost,
+				location: com.CLSCTX_LOCAL_SERVER,
+			},
+			want:    &id,
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Test with invalid progID",
+			args: args{
+				progID:   "InvalidProgID",
+				node:     TestHost,
+				location: com.CLSCTX_LOCAL_SERVER,
+			},
+			want:    nil,
+			wantErr: assert.Error,
+		},
+		{
+			name: "Test with invalid node",
+			args: args{
+				progID:   TestProgID,
+				node:     "InvalidNode",
+				location: com.CLSCTX_REMOTE_SERVER,
+			},
+			want:    nil,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getClsIDFromServerListV1(tt.args.progID, tt.args.node, tt.args.location, nil)
+			if !tt.wantErr(t, err, fmt.Sprintf("getClsIDFromServerListV1(%v, %v, %v)", tt.args.progID, tt.args.node, tt.args.location)) {
+				return
+			}
+			assert.Equalf(t, tt.want, got, "getClsIDFromServerListV1(%v, %v, %v)", tt.args.progID, tt.args.node, tt.args.location)
+		})
+	}
+}
+
+func Test_getServersFromReg(t *testing.T) {
+	localNode, err := windows.ComputerName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers, err := getServersFromReg(localNode)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, servers)
+	for _, server := range servers {
+		t.Log(server)
+	}
+}
+
+func TestDefaultExecutorDecorated(t *testing.T) {
+	transport := InterceptTransport(COMTransport(), func(method string, invoke func() error) error {
+		return invoke()
+	})
+	executor, err := defaultExecutor(transport, nil)
+	assert.NoError(t, err)
+	if assert.NotNil(t, executor) {
+		assert.Equal(t, com.MultiThreaded, executor.GetApartment())
+		executor.Close()
+	}
+	// the apartment of the InitConfig given to Connect
+	config := com.DefaultInitConfig()
+	config.Apartment = com.SingleThreaded
+	executor, err = defaultExecutor(transport, config)
+	assert.NoError(t, err)
+	if assert.NotNil(t, executor) {
+		assert.Equal(t, com.SingleThreaded, executor.GetApartment())
+		executor.Close()
+	}
+	// a TransportFunc hides the transport it calls
+	executor, err = defaultExecutor(TransportFunc(COMTransport().Connect), nil)
+	assert.NoError(t, err)
+	assert.Nil(t, executor)
+}
@@ -0,0 +1,342 @@
+package opcsim
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+)
+
+// separator separates the branches of item IDs
+const separator = "."
+
+// browser implements IOPCBrowseServerAddressSpace, the address space is the hierarchy of the item IDs
+type browser struct {
+	conn     *connection
+	position string
+}
+
+// QueryOrganization implements IOPCBrowseServerAddressSpace::QueryOrganization
+func (b *browser) QueryOrganization() (com.OPCNAMESPACETYPE, error) {
+	return opcda.OPC_NS_HIERARCHIAL, nil
+}
+
+// join returns the ID of name below branch
+func join(branch, name string) string {
+	if branch == "" {
+		return name
+	}
+	return branch + separator + name
+}
+
+// isBranch reports whether id is a branch of the address space, the caller holds the lock
+func (s *Server) isBranch(id string) bool {
+	if id == "" {
+		return true
+	}
+	prefix := id + separator
+	for itemID := range s.tags {
+		if strings.HasPrefix(itemID, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeBrowsePosition implements IOPCBrowseServerAddressSpace::ChangeBrowsePosition
+func (b *browser) ChangeBrowsePosition(direction com.OPCBROWSEDIRECTION, name string) error {
+	b.conn.server.lock.Lock()
+	defer b.conn.server.lock.Unlock()
+	if err := b.conn.check(); err != nil {
+		return err
+	}
+	switch direction {
+	case opcda.OPC_BROWSE_UP:
+		if b.position == "" {
+			return newError(EFail)
+		}
+		if i := strings.LastIndex(b.position, separator); i >= 0 {
+			b.position = b.position[:i]
+		} else {
+			b.position = ""
+		}
+	case opcda.OPC_BROWSE_DOWN:
+		id := join(b.position, name)
+		if name == "" || !b.conn.server.isBranch(id) {
+			return newError(EInvalidArg)
+		}
+		b.position = id
+	case opcda.OPC_BROWSE_TO:
+		if !b.conn.server.isBranch(name) {
+			return newError(EInvalidArg)
+		}
+		b.position = name
+	default:
+		return newError(EInvalidArg)
+	}
+	return nil
+}
+
+// BrowseOPCItemIDs implements IOPCBrowseServerAddressSpace::BrowseOPCItemIDs.
+// Branches and leaves are returned by name, OPC_FLAT returns the item IDs of every leaf below the position.
+func (b *browser) BrowseOPCItemIDs(browseType com.OPCBROWSETYPE, filter string, dataType uint16, accessRights uint32) ([]string, error) {
+	b.conn.server.lock.Lock()
+	defer b.conn.server.lock.Unlock()
+	if err := b.conn.check(); err != nil {
+		return nil, err
+	}
+	if browseType != opcda.OPC_BRANCH && browseType != opcda.OPC_LEAF && browseType != opcda.OPC_FLAT {
+		return nil, newError(EInvalidArg)
+	}
+	prefix := ""
+	if b.position != "" {
+		prefix = b.position + separator
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for itemID, t := range b.conn.server.tags {
+		if !strings.HasPrefix(itemID, prefix) {
+			continue
+		}
+		rest := itemID[len(prefix):]
+		i := strings.Index(rest, separator)
+		var name string
+		switch browseType {
+		case opcda.OPC_BRANCH:
+			if i < 0 {
+				continue
+			}
+			name = rest[:i]
+		case opcda.OPC_LEAF:
+			if i >= 0 || !matchLeaf(t, dataType, accessRights) {
+				continue
+			}
+			name = rest
+		default:
+			if !matchLeaf(t, dataType, accessRights) {
+				continue
+			}
+			name = itemID
+		}
+		if seen[name] || filter != "" && !match(filter, name) {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// matchLeaf applies the data type and access rights filters of a browse
+func matchLeaf(t *tag, dataType uint16, accessRights uint32) bool {
+	if com.VT(dataType) != com.VT_EMPTY && com.VT(dataType) != t.vt {
+		return false
+	}
+	return accessRights == 0 || t.accessRights()&accessRights != 0
+}
+
+// match reports whether name matches filter, * matches any characters, ? any character and # any digit
+func match(filter, name string) bool {
+	for len(filter) > 0 {
+		switch filter[0] {
+		case '*':
+			for i := 0; i <= len(name); i++ {
+				if match(filter[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(name) == 0 {
+				return false
+			}
+		case '#':
+			if len(name) == 0 || name[0] < '0' || name[0] > '9' {
+				return false
+			}
+		default:
+			if len(name) == 0 || name[0] != filter[0] {
+				return false
+			}
+		}
+		filter, name = filter[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// GetItemID implements IOPCBrowseServerAddressSpace::GetItemID, an empty name returns the current position
+func (b *browser) GetItemID(itemDataID string) (string, error) {
+	b.conn.server.lock.Lock()
+	defer b.conn.server.lock.Unlock()
+	if err := b.conn.check(); err != nil {
+		return "", err
+	}
+	if itemDataID == "" {
+		return b.position, nil
+	}
+	id := join(b.position, itemDataID)
+	if _, ok := b.conn.server.tags[id]; ok || b.conn.server.isBranch(id) {
+		return id, nil
+	}
+	return "", newError(EInvalidArg)
+}
+
+// Release does nothing
+func (b *browser) Release() {}
+
+// Browse implements IOPCBrowse::Browse.
+// The server supports continuation points, they are the offset of the next element, so moreElements is always false.
+func (c *connection) Browse(
+	itemID string,
+	continuationPoint string,
+	maxElements uint32,
+	browseFilter com.OPCBROWSEFILTER,
+	elementNameFilter string,
+	vendorFilter string,
+	returnAllProperties bool,
+	returnPropertyValues bool,
+	propertyIDs []uint32,
+) (bool, string, []*com.BrowseElement, error) {
+	s := c.server
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := c.check(); err != nil {
+		return false, "", nil, err
+	}
+	if browseFilter < opcda.OPC_BROWSE_FILTER_ALL || browseFilter > opcda.OPC_BROWSE_FILTER_ITEMS {
+		return false, "", nil, newError(EInvalidArg)
+	}
+	if !s.isBranch(itemID) {
+		if _, ok := s.tags[itemID]; ok {
+			return false, "", nil, nil
+		}
+		return false, "", nil, newError(opcda.OPCUnknownItemID)
+	}
+	start := 0
+	if continuationPoint != "" {
+		var err error
+		start, err = strconv.Atoi(continuationPoint)
+		if err != nil || start < 0 {
+			return false, "", nil, newError(opcda.OPCInvalidContinuationPoint)
+		}
+	}
+	var elements []*com.BrowseElement
+	for _, element := range s.children(itemID) {
+		switch {
+		case browseFilter == opcda.OPC_BROWSE_FILTER_BRANCHES && element.Flags&opcda.OPC_BROWSE_HASCHILDREN == 0,
+			browseFilter == opcda.OPC_BROWSE_FILTER_ITEMS && element.Flags&opcda.OPC_BROWSE_ISITEM == 0,
+			elementNameFilter != "" && !match(elementNameFilter, element.Name):
+			continue
+		}
+		elements = append(elements, element)
+	}
+	if start > len(elements) {
+		return false, "", nil, newError(opcda.OPCInvalidContinuationPoint)
+	}
+	elements = elements[start:]
+	next := ""
+	if maxElements > 0 && uint32(len(elements)) > maxElements {
+		elements = elements[:maxElements]
+		next = strconv.Itoa(start + len(elements))
+	}
+	if returnAllProperties || len(propertyIDs) > 0 {
+		now := time.Now()
+		for _, element := range elements {
+			if t, ok := s.tags[element.ItemID]; ok {
+				t.sample(s.startTime, now)
+				ids := propertyIDs
+				if returnAllProperties {
+					ids = t.properties()
+				}
+				element.Properties = t.itemProperties(ids, returnPropertyValues)
+			}
+		}
+	}
+	return false, next, elements, nil
+}
+
+// children returns the elements below branch sorted by name, the caller holds the lock
+func (s *Server) children(branch string) []*com.BrowseElement {
+	prefix := ""
+	if branch != "" {
+		prefix = branch + separator
+	}
+	elements := make(map[string]*com.BrowseElement)
+	for itemID := range s.tags {
+		if !strings.HasPrefix(itemID, prefix) {
+			continue
+		}
+		name := itemID[len(prefix):]
+		flag := opcda.OPC_BROWSE_ISITEM
+		if i := strings.Index(name, separator); i >= 0 {
+			name = name[:i]
+			flag = opcda.OPC_BROWSE_HASCHILDREN
+		}
+		element, ok := elements[name]
+		if !ok {
+			element = &com.BrowseElement{Name: name, ItemID: join(branch, name)}
+			elements[name] = element
+		}
+		element.Flags |= flag
+	}
+	result := make([]*com.BrowseElement, 0, len(elements))
+	for _, element := range elements {
+		result = append(result, element)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// GetProperties implements IOPCBrowse::GetProperties, every property of the items is returned when propertyIDs is empty
+func (c *connection) GetProperties(itemIDs []string, returnPropertyValues bool, propertyIDs []uint32) ([]*com.ItemProperties, error) {
+	s := c.server
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := make([]*com.ItemProperties, len(itemIDs))
+	for i, itemID := range itemIDs {
+		t, code := c.findTag(itemID)
+		if code != 0 {
+			result[i] = &com.ItemProperties{Error: code}
+			continue
+		}
+		t.sample(s.startTime, now)
+		ids := propertyIDs
+		if len(ids) == 0 {
+			ids = t.properties()
+		}
+		properties := t.itemProperties(ids, returnPropertyValues)
+		result[i] = &properties
+	}
+	return result, nil
+}
+
+// itemProperties returns the properties of a tag selected by ids, the error is S_FALSE when one of them is not valid
+func (t *tag) itemProperties(ids []uint32, returnValues bool) com.ItemProperties {
+	var result com.ItemProperties
+	for _, id := range ids {
+		property := &com.ItemProperty{PropertyID: id}
+		result.Properties = append(result.Properties, property)
+		value, ok := t.property(id)
+		if !ok {
+			property.Error = int32(opcda.OPCInvalidPID)
+			result.Error = sFalse
+			continue
+		}
+		property.DataType = uint16(t.propertyType(id))
+		property.Description = propertyDescriptions[id]
+		if returnValues {
+			property.Value = value
+		}
+	}
+	return result
+}
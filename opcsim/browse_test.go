@@ -0,0 +1,28 @@
+package opcsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	for _, c := range []struct {
+		filter string
+		name   string
+		match  bool
+	}{
+		{"*", "", true},
+		{"*", "Int4", true},
+		{"Int?", "Int4", true},
+		{"Int?", "Int", false},
+		{"Int#", "Int4", true},
+		{"Int#", "IntX", false},
+		{"*Int*", "UInt2", true},
+		{"Real*8", "Real8", true},
+		{"Real*8", "Real4", false},
+		{"Int4", "int4", false},
+	} {
+		assert.Equal(t, c.match, match(c.filter, c.name), "%s %s", c.filter, c.name)
+	}
+}
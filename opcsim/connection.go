@@ -0,0 +1,459 @@
+package opcsim
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+)
+
+// locales supported by the simulator
+var availableLocales = []uint32{0x0409, 0x0804}
+
+const defaultLocale = 0x0409
+
+// connection is the server object created for one client
+type connection struct {
+	server     *Server
+	localeID   uint32
+	clientName string
+	groups     map[uint32]*group
+	nextGroup  uint32
+	shutdown   map[uint32]func(string)
+	nextCookie uint32
+	closed     bool
+	// browser is shared by the queries of IOPCBrowseServerAddressSpace, like the single object of a COM server
+	browser *browser
+}
+
+func newConnection(s *Server) *connection {
+	c := &connection{
+		server:   s,
+		localeID: defaultLocale,
+		groups:   make(map[uint32]*group),
+		shutdown: make(map[uint32]func(string)),
+	}
+	c.browser = &browser{conn: c}
+	return c
+}
+
+// check returns the error of calls on a released connection, the caller holds the server lock
+func (c *connection) check() error {
+	if c.closed {
+		return newError(EDisconnected)
+	}
+	return nil
+}
+
+// resolveLocale maps the neutral locales to the default one
+func resolveLocale(localeID uint32) (uint32, bool) {
+	switch localeID {
+	case 0, 0x0400, 0x0800:
+		return defaultLocale, true
+	}
+	for _, l := range availableLocales {
+		if l == localeID {
+			return l, true
+		}
+	}
+	return 0, false
+}
+
+// AddGroup implements IOPCServer::AddGroup
+func (c *connection) AddGroup(name string, active bool, requestedUpdateRate uint32, clientGroup uint32, timeBias *int32, percentDeadband *float32, localeID uint32) (uint32, uint32, opcda.GroupBackend, error) {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	if err := c.check(); err != nil {
+		return 0, 0, nil, err
+	}
+	var deadband float32
+	if percentDeadband != nil {
+		deadband = *percentDeadband
+	}
+	if deadband < 0 || deadband > 100 {
+		return 0, 0, nil, newError(EInvalidArg)
+	}
+	locale, ok := resolveLocale(localeID)
+	if !ok {
+		return 0, 0, nil, newError(EInvalidArg)
+	}
+	if name == "" {
+		for i := len(c.groups); ; i++ {
+			name = fmt.Sprintf("Group%d", i)
+			if c.groupByName(name) == nil {
+				break
+			}
+		}
+	} else if c.groupByName(name) != nil {
+		return 0, 0, nil, newError(opcda.OPCDuplicateName)
+	}
+	var bias int32
+	if timeBias != nil {
+		bias = *timeBias
+	} else {
+		_, offset := time.Now().Zone()
+		bias = int32(-offset / 60)
+	}
+	c.nextGroup++
+	g := newGroup(c, c.nextGroup, name)
+	g.active = active
+	g.updateRate = reviseUpdateRate(requestedUpdateRate)
+	g.clientGroup = clientGroup
+	g.timeBias = bias
+	g.deadband = deadband
+	g.localeID = locale
+	c.groups[g.serverGroup] = g
+	g.start()
+	return g.serverGroup, g.updateRate, g, nil
+}
+
+func (c *connection) groupByName(name string) *group {
+	for _, g := range c.groups {
+		if g.name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// reviseUpdateRate returns the rate the server actually uses for a requested rate
+func reviseUpdateRate(requested uint32) uint32 {
+	if requested < minUpdateRate {
+		return minUpdateRate
+	}
+	return requested
+}
+
+// GetStatus implements IOPCServer::GetStatus
+func (c *connection) GetStatus() (*com.ServerStatus, error) {
+	s := c.server
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	lastUpdate := s.startTime
+	for _, g := range c.groups {
+		if g.lastUpdate.After(lastUpdate) {
+			lastUpdate = g.lastUpdate
+		}
+	}
+	return &com.ServerStatus{
+		StartTime:      s.startTime,
+		CurrentTime:    now,
+		LastUpdateTime: lastUpdate,
+		ServerState:    s.state,
+		GroupCount:     uint32(len(c.groups)),
+		BandWidth:      0xFFFFFFFF,
+		MajorVersion:   1,
+		VendorInfo:     s.vendorInfo,
+	}, nil
+}
+
+// RemoveGroup implements IOPCServer::RemoveGroup
+func (c *connection) RemoveGroup(serverGroup uint32, force bool) error {
+	c.server.lock.Lock()
+	if err := c.check(); err != nil {
+		c.server.lock.Unlock()
+		return err
+	}
+	g, ok := c.groups[serverGroup]
+	if !ok {
+		c.server.lock.Unlock()
+		return newError(EInvalidArg)
+	}
+	delete(c.groups, serverGroup)
+	c.server.lock.Unlock()
+	g.stop()
+	return nil
+}
+
+// QueryCommon implements QueryInterface for IOPCCommon
+func (c *connection) QueryCommon() (opcda.CommonBackend, error) {
+	return c, nil
+}
+
+// QueryItemProperties implements QueryInterface for IOPCItemProperties
+func (c *connection) QueryItemProperties() (opcda.ItemPropertiesBackend, error) {
+	return c, nil
+}
+
+// QueryBrowseServerAddressSpace implements QueryInterface for IOPCBrowseServerAddressSpace,
+// every call returns the browser of the connection, so they share the browse position
+func (c *connection) QueryBrowseServerAddressSpace() (opcda.BrowseServerAddressSpaceBackend, error) {
+	return c.browser, nil
+}
+
+// QueryBrowse implements QueryInterface for IOPCBrowse
+func (c *connection) QueryBrowse() (opcda.BrowseBackend, error) {
+	if c.server.withoutDA3 {
+		return nil, newError(ENoInterface)
+	}
+	return c, nil
+}
+
+// AdviseShutdown implements IConnectionPoint::Advise for IOPCShutdown
+func (c *connection) AdviseShutdown(onShutdown func(reason string)) (uint32, error) {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	if err := c.check(); err != nil {
+		return 0, err
+	}
+	c.nextCookie++
+	c.shutdown[c.nextCookie] = onShutdown
+	return c.nextCookie, nil
+}
+
+// UnadviseShutdown implements IConnectionPoint::Unadvise for IOPCShutdown
+func (c *connection) UnadviseShutdown(cookie uint32) error {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	if _, ok := c.shutdown[cookie]; !ok {
+		return newError(ENoConnection)
+	}
+	delete(c.shutdown, cookie)
+	return nil
+}
+
+// Release drops the connection and its groups
+func (c *connection) Release() {
+	c.server.lock.Lock()
+	if c.closed {
+		c.server.lock.Unlock()
+		return
+	}
+	delete(c.server.connections, c)
+	c.server.lock.Unlock()
+	c.close()
+}
+
+// close stops the groups of the connection
+func (c *connection) close() {
+	c.server.lock.Lock()
+	c.closed = true
+	groups := c.groups
+	c.groups = make(map[uint32]*group)
+	c.shutdown = make(map[uint32]func(string))
+	c.server.lock.Unlock()
+	for _, g := range groups {
+		g.stop()
+	}
+}
+
+// SetLocaleID implements IOPCCommon::SetLocaleID
+func (c *connection) SetLocaleID(localeID uint32) error {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	if err := c.check(); err != nil {
+		return err
+	}
+	locale, ok := resolveLocale(localeID)
+	if !ok {
+		return newError(EInvalidArg)
+	}
+	c.localeID = locale
+	return nil
+}
+
+// GetLocaleID implements IOPCCommon::GetLocaleID
+func (c *connection) GetLocaleID() (uint32, error) {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	if err := c.check(); err != nil {
+		return 0, err
+	}
+	return c.localeID, nil
+}
+
+// QueryAvailableLocaleIDs implements IOPCCommon::QueryAvailableLocaleIDs
+func (c *connection) QueryAvailableLocaleIDs() ([]uint32, error) {
+	return append([]uint32(nil), availableLocales...), nil
+}
+
+// GetErrorString implements IOPCCommon::GetErrorString
+func (c *connection) GetErrorString(errorCode uint32) (string, error) {
+	if message, ok := errorStrings[errorCode]; ok {
+		return message, nil
+	}
+	return "", newError(EInvalidArg)
+}
+
+// SetClientName implements IOPCCommon::SetClientName
+func (c *connection) SetClientName(name string) error {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	if err := c.check(); err != nil {
+		return err
+	}
+	c.clientName = name
+	return nil
+}
+
+var propertyDescriptions = map[uint32]string{
+	propertyDataType:     "Item Canonical Data Type",
+	propertyValue:        "Item Value",
+	propertyQuality:      "Item Quality",
+	propertyTimestamp:    "Item Timestamp",
+	propertyAccessRights: "Item Access Rights",
+	propertyScanRate:     "Server Scan Rate",
+	propertyEUType:       "Item EU Type",
+	propertyEUInfo:       "Item EU Info",
+	propertyDescription:  "Item Description",
+}
+
+// properties returns the property IDs of a tag
+func (t *tag) properties() []uint32 {
+	ids := []uint32{propertyDataType, propertyValue, propertyQuality, propertyTimestamp, propertyAccessRights, propertyScanRate, propertyEUType}
+	if t.analog() {
+		ids = append(ids, propertyEUInfo)
+	}
+	if t.Description != "" {
+		ids = append(ids, propertyDescription)
+	}
+	return ids
+}
+
+// property returns the value of a property of a tag, ok is false when the tag does not have it
+func (t *tag) property(id uint32) (value interface{}, ok bool) {
+	switch id {
+	case propertyDataType:
+		return int16(t.vt), true
+	case propertyValue:
+		return t.value, true
+	case propertyQuality:
+		return int16(t.quality), true
+	case propertyTimestamp:
+		return t.timestamp, true
+	case propertyAccessRights:
+		return int32(t.accessRights()), true
+	case propertyScanRate:
+		return float32(minUpdateRate), true
+	case propertyEUType:
+		if t.analog() {
+			return int32(1), true
+		}
+		return int32(0), true
+	case propertyEUInfo:
+		if t.analog() {
+			return []float64{t.EULow, t.EUHigh}, true
+		}
+	case propertyDescription:
+		if t.Description != "" {
+			return t.Description, true
+		}
+	}
+	return nil, false
+}
+
+// propertyType returns the VARIANT type of a property of a tag
+func (t *tag) propertyType(id uint32) com.VT {
+	switch id {
+	case propertyValue:
+		return t.vt
+	case propertyDataType, propertyQuality:
+		return com.VT_I2
+	case propertyTimestamp:
+		return com.VT_DATE
+	case propertyAccessRights, propertyEUType:
+		return com.VT_I4
+	case propertyScanRate:
+		return com.VT_R4
+	case propertyEUInfo:
+		return com.VT_ARRAY | com.VT_R8
+	case propertyDescription:
+		return com.VT_BSTR
+	}
+	return com.VT_VARIANT
+}
+
+// findTag returns the tag of itemID or the error code why it does not exist, the caller holds the server lock
+func (c *connection) findTag(itemID string) (*tag, int32) {
+	if itemID == "" {
+		return nil, int32(opcda.OPCInvalidItemID)
+	}
+	t, ok := c.server.tags[itemID]
+	if !ok {
+		return nil, int32(opcda.OPCUnknownItemID)
+	}
+	return t, 0
+}
+
+// lookupTag returns the tag of itemID, the caller holds the server lock
+func (c *connection) lookupTag(itemID string) (*tag, error) {
+	t, code := c.findTag(itemID)
+	if code != 0 {
+		return nil, newError(uint32(code))
+	}
+	return t, nil
+}
+
+// QueryAvailableProperties implements IOPCItemProperties::QueryAvailableProperties
+func (c *connection) QueryAvailableProperties(itemID string) ([]uint32, []string, []uint16, error) {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	if err := c.check(); err != nil {
+		return nil, nil, nil, err
+	}
+	t, err := c.lookupTag(itemID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ids := t.properties()
+	descriptions := make([]string, len(ids))
+	dataTypes := make([]uint16, len(ids))
+	for i, id := range ids {
+		descriptions[i] = propertyDescriptions[id]
+		dataTypes[i] = uint16(t.propertyType(id))
+	}
+	return ids, descriptions, dataTypes, nil
+}
+
+// GetItemProperties implements IOPCItemProperties::GetItemProperties
+func (c *connection) GetItemProperties(itemID string, propertyIDs []uint32) ([]interface{}, []int32, error) {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	if err := c.check(); err != nil {
+		return nil, nil, err
+	}
+	t, err := c.lookupTag(itemID)
+	if err != nil {
+		return nil, nil, err
+	}
+	t.sample(c.server.startTime, time.Now())
+	data := make([]interface{}, len(propertyIDs))
+	errs := make([]int32, len(propertyIDs))
+	for i, id := range propertyIDs {
+		value, ok := t.property(id)
+		if !ok {
+			errs[i] = int32(opcda.OPCInvalidPID)
+			continue
+		}
+		data[i] = value
+	}
+	return data, errs, nil
+}
+
+// LookupItemIDs implements IOPCItemProperties::LookupItemIDs, the simulator has no properties with item IDs
+func (c *connection) LookupItemIDs(itemID string, propertyIDs []uint32) ([]string, []int32, error) {
+	c.server.lock.Lock()
+	defer c.server.lock.Unlock()
+	if err := c.check(); err != nil {
+		return nil, nil, err
+	}
+	t, err := c.lookupTag(itemID)
+	if err != nil {
+		return nil, nil, err
+	}
+	itemIDs := make([]string, len(propertyIDs))
+	errs := make([]int32, len(propertyIDs))
+	for i, id := range propertyIDs {
+		if _, ok := t.property(id); !ok || id <= propertyScanRate {
+			errs[i] = int32(opcda.OPCInvalidPID)
+			continue
+		}
+		errs[i] = int32(opcda.OPCNotFound)
+	}
+	return itemIDs, errs, nil
+}
@@ -0,0 +1,298 @@
+package opcsim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+var (
+	errBadType = errors.New("opcsim: unsupported conversion")
+	errRange   = errors.New("opcsim: value out of range")
+)
+
+var scalarTypes = map[com.VT]reflect.Type{
+	com.VT_BOOL:    reflect.TypeOf(false),
+	com.VT_I1:      reflect.TypeOf(int8(0)),
+	com.VT_I2:      reflect.TypeOf(int16(0)),
+	com.VT_I4:      reflect.TypeOf(int32(0)),
+	com.VT_I8:      reflect.TypeOf(int64(0)),
+	com.VT_UI1:     reflect.TypeOf(uint8(0)),
+	com.VT_UI2:     reflect.TypeOf(uint16(0)),
+	com.VT_UI4:     reflect.TypeOf(uint32(0)),
+	com.VT_UI8:     reflect.TypeOf(uint64(0)),
+	com.VT_R4:      reflect.TypeOf(float32(0)),
+	com.VT_R8:      reflect.TypeOf(float64(0)),
+	com.VT_BSTR:    reflect.TypeOf(""),
+	com.VT_DATE:    reflect.TypeOf(time.Time{}),
+	com.VT_CY:      reflect.TypeOf(com.Currency(0)),
+	com.VT_DECIMAL: reflect.TypeOf(com.Decimal{}),
+	com.VT_ERROR:   reflect.TypeOf(com.SCode(0)),
+	// only arrays have elements of type VARIANT
+	com.VT_VARIANT: reflect.TypeOf((*interface{})(nil)).Elem(),
+}
+
+// typeOf returns the VARIANT type of a Go value
+func typeOf(value interface{}) (com.VT, error) {
+	if value == nil {
+		return com.VT_EMPTY, nil
+	}
+	t := reflect.TypeOf(value)
+	array := com.VT(0)
+	if t.Kind() == reflect.Slice {
+		array = com.VT_ARRAY
+		t = t.Elem()
+	}
+	for vt, st := range scalarTypes {
+		if st == t {
+			return array | vt, nil
+		}
+	}
+	return 0, fmt.Errorf("opcsim: unsupported value type %T", value)
+}
+
+// convert changes value to the VARIANT type vt, VT_EMPTY keeps the value
+func convert(value interface{}, vt com.VT) (interface{}, error) {
+	if vt == com.VT_EMPTY {
+		return value, nil
+	}
+	from, err := typeOf(value)
+	if err != nil {
+		return nil, err
+	}
+	if from == vt {
+		return value, nil
+	}
+	if vt&com.VT_ARRAY != 0 {
+		if from&com.VT_ARRAY == 0 {
+			return nil, errBadType
+		}
+		st, ok := scalarTypes[vt&^com.VT_ARRAY]
+		if !ok {
+			return nil, errBadType
+		}
+		in := reflect.ValueOf(value)
+		out := reflect.MakeSlice(reflect.SliceOf(st), in.Len(), in.Len())
+		for i := 0; i < in.Len(); i++ {
+			element, err := convertScalar(in.Index(i).Interface(), vt&^com.VT_ARRAY)
+			if err != nil {
+				return nil, err
+			}
+			out.Index(i).Set(reflect.ValueOf(element))
+		}
+		return out.Interface(), nil
+	}
+	if from&com.VT_ARRAY != 0 {
+		return nil, errBadType
+	}
+	return convertScalar(value, vt)
+}
+
+func convertScalar(value interface{}, vt com.VT) (interface{}, error) {
+	switch vt {
+	case com.VT_VARIANT:
+		return value, nil
+	case com.VT_ERROR:
+		if v, ok := value.(com.SCode); ok {
+			return v, nil
+		}
+		return nil, errBadType
+	case com.VT_BSTR:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		case float32:
+			return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		}
+		return fmt.Sprint(value), nil
+	case com.VT_DATE:
+		switch v := value.(type) {
+		case time.Time:
+			return v, nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, errBadType
+			}
+			return t, nil
+		}
+		return nil, errBadType
+	case com.VT_BOOL:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, errBadType
+			}
+			return b, nil
+		}
+		f, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		return f != 0, nil
+	}
+	if _, ok := scalarTypes[vt]; !ok {
+		return nil, errBadType
+	}
+	var f float64
+	switch v := value.(type) {
+	case uint64:
+		// float64 loses the low bits of large uint64
+		if vt == com.VT_UI8 {
+			return v, nil
+		}
+		f = float64(v)
+	case int64:
+		if vt == com.VT_I8 {
+			return v, nil
+		}
+		f = float64(v)
+	default:
+		var err error
+		if f, err = toFloat(value); err != nil {
+			return nil, err
+		}
+	}
+	return fromFloat(f, vt)
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case com.Currency:
+		return v.Float64(), nil
+	case com.Decimal:
+		return v.Float64(), nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, errBadType
+		}
+		return f, nil
+	}
+	return 0, errBadType
+}
+
+func fromFloat(f float64, vt com.VT) (interface{}, error) {
+	if math.IsNaN(f) && vt != com.VT_R4 && vt != com.VT_R8 {
+		return nil, errRange
+	}
+	// integers are rounded like VariantChangeType does
+	r := math.RoundToEven(f)
+	switch vt {
+	case com.VT_I1:
+		if r < math.MinInt8 || r > math.MaxInt8 {
+			return nil, errRange
+		}
+		return int8(r), nil
+	case com.VT_I2:
+		if r < math.MinInt16 || r > math.MaxInt16 {
+			return nil, errRange
+		}
+		return int16(r), nil
+	case com.VT_I4:
+		if r < math.MinInt32 || r > math.MaxInt32 {
+			return nil, errRange
+		}
+		return int32(r), nil
+	case com.VT_I8:
+		if r < math.MinInt64 || r >= math.MaxInt64 {
+			return nil, errRange
+		}
+		return int64(r), nil
+	case com.VT_UI1:
+		if r < 0 || r > math.MaxUint8 {
+			return nil, errRange
+		}
+		return uint8(r), nil
+	case com.VT_UI2:
+		if r < 0 || r > math.MaxUint16 {
+			return nil, errRange
+		}
+		return uint16(r), nil
+	case com.VT_UI4:
+		if r < 0 || r > math.MaxUint32 {
+			return nil, errRange
+		}
+		return uint32(r), nil
+	case com.VT_UI8:
+		if r < 0 || r >= math.MaxUint64 {
+			return nil, errRange
+		}
+		return uint64(r), nil
+	case com.VT_R4:
+		if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+			return nil, errRange
+		}
+		return float32(f), nil
+	case com.VT_R8:
+		return f, nil
+	case com.VT_CY, com.VT_DECIMAL:
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, errRange
+		}
+		// the shortest decimal form of f is converted, 0.1 becomes 0.1 and not the exact binary fraction
+		r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+		if vt == com.VT_CY {
+			c, err := com.NewCurrency(r)
+			if err != nil {
+				return nil, errRange
+			}
+			return c, nil
+		}
+		d, err := com.NewDecimal(r)
+		if err != nil {
+			return nil, errRange
+		}
+		return d, nil
+	}
+	return nil, errBadType
+}
+
+// equal reports whether two values of the same type are identical
+func equal(a, b interface{}) bool {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+	return reflect.DeepEqual(a, b)
+}
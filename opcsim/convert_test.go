@@ -0,0 +1,68 @@
+package opcsim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, c := range []struct {
+		value    interface{}
+		vt       com.VT
+		expected interface{}
+		err      error
+	}{
+		{int32(1), com.VT_EMPTY, int32(1), nil},
+		{int32(1), com.VT_R8, float64(1), nil},
+		{2.5, com.VT_I4, int32(2), nil},
+		{3.5, com.VT_I4, int32(4), nil},
+		{int32(300), com.VT_UI1, nil, errRange},
+		{int32(-1), com.VT_UI4, nil, errRange},
+		{"12", com.VT_I2, int16(12), nil},
+		{"x", com.VT_I2, nil, errBadType},
+		{int16(12), com.VT_BSTR, "12", nil},
+		{true, com.VT_I4, int32(1), nil},
+		{int32(0), com.VT_BOOL, false, nil},
+		{date, com.VT_BSTR, "2024-01-02T03:04:05Z", nil},
+		{"2024-01-02T03:04:05Z", com.VT_DATE, date, nil},
+		{int32(1), com.VT_DATE, nil, errBadType},
+		{[]int32{1, 2}, com.VT_ARRAY | com.VT_R8, []float64{1, 2}, nil},
+		{[]int32{1, 2}, com.VT_R8, nil, errBadType},
+		{int32(1), com.VT_ARRAY | com.VT_R8, nil, errBadType},
+		{uint64(1<<63 + 1), com.VT_UI8, uint64(1<<63 + 1), nil},
+		{0.1, com.VT_CY, com.Currency(1000), nil},
+		{int32(-2), com.VT_DECIMAL, com.Decimal{Negative: true, Lo64: 2}, nil},
+		{0.1, com.VT_DECIMAL, com.Decimal{Scale: 1, Lo64: 1}, nil},
+		{1e300, com.VT_CY, nil, errRange},
+		{com.Currency(25000), com.VT_R8, 2.5, nil},
+		{com.Decimal{Scale: 1, Lo64: 15}, com.VT_BSTR, "1.5", nil},
+		{com.SCode(-2147352572), com.VT_ERROR, com.SCode(-2147352572), nil},
+		{int32(1), com.VT_ERROR, nil, errBadType},
+		{[]int32{1}, com.VT_ARRAY | com.VT_VARIANT, []interface{}{int32(1)}, nil},
+		{[]interface{}{int32(1), "2"}, com.VT_ARRAY | com.VT_R8, []float64{1, 2}, nil},
+	} {
+		v, err := convert(c.value, c.vt)
+		assert.Equal(t, c.err, err, "%v %v", c.value, c.vt)
+		assert.Equal(t, c.expected, v, "%v %v", c.value, c.vt)
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	ramp := Ramp(0, 100, 10*time.Second)
+	assert.Equal(t, 0.0, ramp(0))
+	assert.Equal(t, 50.0, ramp(5*time.Second))
+	assert.Equal(t, 0.0, ramp(10*time.Second))
+	sine := Sine(-1, 1, 4*time.Second)
+	assert.InDelta(t, 0, sine(0), 1e-9)
+	assert.InDelta(t, 1, sine(time.Second), 1e-9)
+	assert.InDelta(t, -1, sine(3*time.Second), 1e-9)
+	random := Random(10, 20)
+	for i := 0; i < 100; i++ {
+		v := random(0).(float64)
+		assert.True(t, v >= 10 && v < 20)
+	}
+}
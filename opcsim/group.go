@@ -0,0 +1,1005 @@
+package opcsim
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+)
+
+// item is an item added to a group
+type item struct {
+	serverHandle  uint32
+	clientHandle  uint32
+	tag           *tag
+	active        bool
+	requestedType com.VT
+	// deadband overrides the deadband of the group when deadbandSet
+	deadband    float32
+	deadbandSet bool
+	// samplingRate overrides the update rate of the group for sampling the tag when it is not zero,
+	// the changed samples are kept in buffer until the next update when bufferEnable
+	samplingRate uint32
+	bufferEnable bool
+	nextSample   time.Time
+	buffer       []sample
+	// the state last sent with OnDataChange
+	sent        bool
+	sentValue   interface{}
+	sentQuality com.Quality
+}
+
+// sample is a state of the tag of an item
+type sample struct {
+	value     interface{}
+	quality   com.Quality
+	timestamp time.Time
+}
+
+// maxBufferedSamples bounds the buffer of an item, the oldest samples are dropped first
+const maxBufferedSamples = 100
+
+// transaction is an asynchronous call waiting for the worker of the group
+type transaction struct {
+	cancelID      uint32
+	transactionID uint32
+	kind          int
+	due           time.Time
+	source        com.OPCDATASOURCE
+	items         []*item
+	values        []interface{}
+	// maxAges and vqts are set by the OPC DA 3.0 calls instead of sampling every item and writing values
+	maxAges []uint32
+	vqts    []*com.ItemVQT
+}
+
+const (
+	transactionRead = iota
+	transactionWrite
+	transactionRefresh
+	transactionCancel
+)
+
+// group is a group of a connection
+type group struct {
+	conn        *connection
+	serverGroup uint32
+	name        string
+	active      bool
+	updateRate  uint32
+	clientGroup uint32
+	timeBias    int32
+	deadband    float32
+	localeID    uint32
+	items       map[uint32]*item
+	nextItem    uint32
+	callbacks   map[uint32]opcda.DataCallback
+	nextCookie  uint32
+	pending     []*transaction
+	nextCancel  uint32
+	lastUpdate  time.Time
+	nextUpdate  time.Time
+	// keepAlive is the keep-alive time in milliseconds, an empty OnDataChange is sent when no other one
+	// was sent since lastCallback
+	keepAlive    uint32
+	lastCallback time.Time
+	wake         chan struct{}
+	done         chan struct{}
+}
+
+func newGroup(c *connection, serverGroup uint32, name string) *group {
+	return &group{
+		conn:        c,
+		serverGroup: serverGroup,
+		name:        name,
+		items:       make(map[uint32]*item),
+		callbacks:   make(map[uint32]opcda.DataCallback),
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+}
+
+func (g *group) start() {
+	go g.run()
+}
+
+// stop ends the worker of the group, pending transactions are dropped.
+// It does not wait for the worker, which may be blocked in a callback of a client that stopped receiving.
+func (g *group) stop() {
+	select {
+	case <-g.done:
+	default:
+		close(g.done)
+	}
+}
+
+// notify wakes up the worker, the caller may hold the server lock
+func (g *group) notify() {
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+// removed reports whether the group was removed from its connection, the caller holds the server lock
+func (g *group) removed() bool {
+	return g.conn.closed || g.conn.groups[g.serverGroup] != g
+}
+
+func (g *group) check() error {
+	if g.conn.closed {
+		return newError(EDisconnected)
+	}
+	if g.removed() {
+		return newError(EFail)
+	}
+	return nil
+}
+
+func (g *group) lock() {
+	g.conn.server.lock.Lock()
+}
+
+func (g *group) unlock() {
+	g.conn.server.lock.Unlock()
+}
+
+// run samples the items every update period and completes the asynchronous calls,
+// it ticks at the fastest sampling rate of the items when it is faster than the update rate
+func (g *group) run() {
+	g.lock()
+	period := g.period()
+	g.unlock()
+	ticker := time.NewTicker(time.Duration(period) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		tick := false
+		select {
+		case <-g.done:
+			return
+		case <-ticker.C:
+			tick = true
+		case <-g.wake:
+		}
+		g.lock()
+		if p := g.period(); p != period {
+			period = p
+			ticker.Reset(time.Duration(period) * time.Millisecond)
+		}
+		var calls []func()
+		now := time.Now()
+		pending := g.pending[:0]
+		for _, t := range g.pending {
+			if t.due.After(now) {
+				pending = append(pending, t)
+				continue
+			}
+			calls = append(calls, g.complete(t)...)
+		}
+		g.pending = pending
+		if tick {
+			g.sampleItems(now, period)
+			if due(g.nextUpdate, now, period) {
+				g.nextUpdate = now.Add(time.Duration(g.updateRate) * time.Millisecond)
+				calls = append(calls, g.update()...)
+			}
+			if g.keepAlive != 0 && g.active && len(g.callbacks) > 0 &&
+				due(g.lastCallback.Add(time.Duration(g.keepAlive)*time.Millisecond), now, period) {
+				calls = append(calls, g.dataChange(0, nil, nil)...)
+			}
+		}
+		g.unlock()
+		for _, call := range calls {
+			select {
+			case <-g.done:
+				return
+			default:
+			}
+			call()
+		}
+	}
+}
+
+// period returns the tick period of the worker, the update rate, the keep-alive time
+// or the fastest sampling rate of the items
+func (g *group) period() uint32 {
+	period := g.updateRate
+	if g.keepAlive != 0 && g.keepAlive < period {
+		period = g.keepAlive
+	}
+	for _, it := range g.items {
+		if it.samplingRate != 0 && it.samplingRate < period {
+			period = it.samplingRate
+		}
+	}
+	return period
+}
+
+// due reports whether a deadline is reached on a tick, ticks arriving early by less than half a period count
+func due(deadline, now time.Time, period uint32) bool {
+	return !now.Before(deadline.Add(-time.Duration(period) * time.Millisecond / 2))
+}
+
+// sampleItems samples the active items with their own sampling rate and buffers their changed samples
+func (g *group) sampleItems(now time.Time, period uint32) {
+	if !g.active || len(g.callbacks) == 0 {
+		return
+	}
+	for _, handle := range g.handles() {
+		it := g.items[handle]
+		if !it.active || it.samplingRate == 0 || !due(it.nextSample, now, period) {
+			continue
+		}
+		it.nextSample = now.Add(time.Duration(it.samplingRate) * time.Millisecond)
+		it.tag.sample(g.conn.server.startTime, now)
+		if it.bufferEnable && g.changed(it) {
+			it.buffer = append(it.buffer, it.current())
+			if len(it.buffer) > maxBufferedSamples {
+				it.buffer = it.buffer[1:]
+			}
+		}
+	}
+}
+
+// update samples the active items and returns the OnDataChange calls for the changed ones,
+// an item with buffered samples is sent once per sample
+func (g *group) update() []func() {
+	if !g.active || len(g.callbacks) == 0 {
+		return nil
+	}
+	now := time.Now()
+	var changed []*item
+	var samples []sample
+	for _, handle := range g.handles() {
+		it := g.items[handle]
+		if !it.active {
+			continue
+		}
+		if len(it.buffer) > 0 {
+			for _, s := range it.buffer {
+				changed = append(changed, it)
+				samples = append(samples, s)
+			}
+			it.buffer = nil
+			continue
+		}
+		if it.samplingRate == 0 {
+			it.tag.sample(g.conn.server.startTime, now)
+		}
+		if g.changed(it) {
+			changed = append(changed, it)
+			samples = append(samples, it.current())
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	g.lastUpdate = now
+	return g.dataChange(0, changed, samples)
+}
+
+// changed reports whether the state of an item differs from the one last sent or buffered,
+// beyond the deadband for analog items
+func (g *group) changed(it *item) bool {
+	if n := len(it.buffer); n > 0 {
+		return g.exceeds(it, it.buffer[n-1].value, it.buffer[n-1].quality)
+	}
+	if !it.sent {
+		return true
+	}
+	return g.exceeds(it, it.sentValue, it.sentQuality)
+}
+
+// exceeds reports whether the state of the tag of an item differs from value and quality,
+// beyond the deadband for analog items
+func (g *group) exceeds(it *item, value interface{}, quality com.Quality) bool {
+	t := it.tag
+	if quality != t.quality {
+		return true
+	}
+	if equal(value, t.value) {
+		return false
+	}
+	deadband := g.deadband
+	if it.deadbandSet {
+		deadband = it.deadband
+	}
+	if deadband == 0 || !t.analog() {
+		return true
+	}
+	last, err1 := toFloat(value)
+	current, err2 := toFloat(t.value)
+	if err1 != nil || err2 != nil {
+		return true
+	}
+	return math.Abs(current-last) > float64(deadband)/100*(t.EUHigh-t.EULow)
+}
+
+// dataChange records the samples of items as sent and returns the OnDataChange calls
+func (g *group) dataChange(transactionID uint32, items []*item, samples []sample) []func() {
+	data := &opcda.CDataChangeCallBackData{
+		TransID:     transactionID,
+		GroupHandle: g.clientGroup,
+	}
+	data.ItemClientHandles, data.Values, data.Qualities, data.TimeStamps, data.Errors, data.MasterQuality, data.MasterErr = g.states(items, samples)
+	for i, it := range items {
+		it.sent = true
+		it.sentValue = samples[i].value
+		it.sentQuality = samples[i].quality
+	}
+	g.lastCallback = time.Now()
+	return g.broadcast(func(cb opcda.DataCallback) { cb.OnDataChange(data) })
+}
+
+// states returns the callback fields for the samples of items, the values are converted to the requested types
+func (g *group) states(items []*item, samples []sample) (clientHandles []uint32, values []interface{}, qualities []com.Quality, timestamps []time.Time, errs []int32, masterQuality int32, masterErr int32) {
+	masterQuality = int32(QualityGood)
+	for i, it := range items {
+		value, quality, err := it.read(samples[i])
+		clientHandles = append(clientHandles, it.clientHandle)
+		values = append(values, value)
+		qualities = append(qualities, quality)
+		timestamps = append(timestamps, samples[i].timestamp)
+		errs = append(errs, err)
+		if err != 0 {
+			masterErr = int32(sFalse)
+		}
+		if !quality.IsGood() {
+			masterQuality = int32(sFalse)
+		}
+	}
+	return
+}
+
+// current returns the current state of the tag of the item
+func (it *item) current() sample {
+	return sample{value: it.tag.value, quality: it.tag.quality, timestamp: it.tag.timestamp}
+}
+
+// currentSamples returns the current states of the tags of items
+func currentSamples(items []*item) []sample {
+	samples := make([]sample, len(items))
+	for i, it := range items {
+		samples[i] = it.current()
+	}
+	return samples
+}
+
+// read returns the value of a sample of the item converted to the requested type
+func (it *item) read(s sample) (interface{}, com.Quality, int32) {
+	value, err := convert(s.value, it.requestedType)
+	if err != nil {
+		return nil, QualityBad, conversionError(err)
+	}
+	return value, s.quality, 0
+}
+
+// conversionError maps a conversion failure to the OPC error code
+func conversionError(err error) int32 {
+	if errors.Is(err, errRange) {
+		return int32(opcda.OPCRange)
+	}
+	return int32(opcda.OPCBadType)
+}
+
+// broadcast returns a call of f for every advised callback
+func (g *group) broadcast(f func(opcda.DataCallback)) []func() {
+	calls := make([]func(), 0, len(g.callbacks))
+	for _, cookie := range sortedKeys(g.callbacks) {
+		cb := g.callbacks[cookie]
+		calls = append(calls, func() { f(cb) })
+	}
+	return calls
+}
+
+func sortedKeys(m map[uint32]opcda.DataCallback) []uint32 {
+	keys := make([]uint32, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sortHandles(keys)
+	return keys
+}
+
+// handles returns the server handles of the group in creation order
+func (g *group) handles() []uint32 {
+	handles := make([]uint32, 0, len(g.items))
+	for h := range g.items {
+		handles = append(handles, h)
+	}
+	sortHandles(handles)
+	return handles
+}
+
+func sortHandles(handles []uint32) {
+	for i := 1; i < len(handles); i++ {
+		for j := i; j > 0 && handles[j] < handles[j-1]; j-- {
+			handles[j], handles[j-1] = handles[j-1], handles[j]
+		}
+	}
+}
+
+// complete executes an asynchronous call and returns its callbacks
+func (g *group) complete(t *transaction) []func() {
+	now := time.Now()
+	switch t.kind {
+	case transactionRead:
+		for i, it := range t.items {
+			if t.maxAges != nil {
+				it.tag.sampleMaxAge(g.conn.server.startTime, now, t.maxAges[i])
+				continue
+			}
+			it.tag.sample(g.conn.server.startTime, now)
+		}
+		data := &opcda.CReadCompleteCallBackData{
+			TransID:     t.transactionID,
+			GroupHandle: g.clientGroup,
+		}
+		data.ItemClientHandles, data.Values, data.Qualities, data.TimeStamps, data.Errors, data.MasterQuality, data.MasterErr = g.states(t.items, currentSamples(t.items))
+		return g.broadcast(func(cb opcda.DataCallback) { cb.OnReadComplete(data) })
+	case transactionWrite:
+		data := &opcda.CWriteCompleteCallBackData{
+			TransID:     t.transactionID,
+			GroupHandle: g.clientGroup,
+		}
+		for i, it := range t.items {
+			var code int32
+			var err error
+			if t.vqts != nil {
+				err = it.tag.setVQT(t.vqts[i], now)
+			} else {
+				err = it.tag.set(t.values[i], now)
+			}
+			if err != nil {
+				code = conversionError(err)
+				data.MasterErr = int32(sFalse)
+			}
+			data.ItemClientHandles = append(data.ItemClientHandles, it.clientHandle)
+			data.Errors = append(data.Errors, code)
+		}
+		return g.broadcast(func(cb opcda.DataCallback) { cb.OnWriteComplete(data) })
+	case transactionCancel:
+		data := &opcda.CCancelCompleteCallBackData{TransID: t.transactionID, GroupHandle: g.clientGroup}
+		return g.broadcast(func(cb opcda.DataCallback) { cb.OnCancelComplete(data) })
+	default:
+		var items []*item
+		for _, handle := range g.handles() {
+			it := g.items[handle]
+			if !it.active {
+				continue
+			}
+			if t.source == opcda.OPC_DS_DEVICE {
+				it.tag.sample(g.conn.server.startTime, now)
+			}
+			items = append(items, it)
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return g.dataChange(t.transactionID, items, currentSamples(items))
+	}
+}
+
+// enqueue queues an asynchronous call for the worker after latency and returns its cancel ID,
+// the caller holds the server lock
+func (g *group) enqueue(t *transaction, latency time.Duration) uint32 {
+	g.nextCancel++
+	t.cancelID = g.nextCancel
+	t.due = time.Now().Add(latency)
+	g.pending = append(g.pending, t)
+	if latency > 0 {
+		time.AfterFunc(latency, g.notify)
+	} else {
+		g.notify()
+	}
+	return t.cancelID
+}
+
+// GetState implements IOPCGroupStateMgt::GetState
+func (g *group) GetState() (uint32, bool, string, int32, float32, uint32, uint32, uint32, error) {
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return 0, false, "", 0, 0, 0, 0, 0, err
+	}
+	return g.updateRate, g.active, g.name, g.timeBias, g.deadband, g.localeID, g.clientGroup, g.serverGroup, nil
+}
+
+// SetState implements IOPCGroupStateMgt::SetState
+func (g *group) SetState(requestedUpdateRate *uint32, active *bool, timeBias *int32, percentDeadband *float32, localeID *uint32, clientGroup *uint32) (uint32, error) {
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return 0, err
+	}
+	if percentDeadband != nil && (*percentDeadband < 0 || *percentDeadband > 100) {
+		return 0, newError(EInvalidArg)
+	}
+	var locale uint32
+	if localeID != nil {
+		var ok bool
+		if locale, ok = resolveLocale(*localeID); !ok {
+			return 0, newError(EInvalidArg)
+		}
+		g.localeID = locale
+	}
+	if requestedUpdateRate != nil {
+		g.updateRate = reviseUpdateRate(*requestedUpdateRate)
+		g.notify()
+	}
+	if active != nil {
+		if *active && !g.active {
+			g.resetSent()
+		}
+		g.active = *active
+	}
+	if timeBias != nil {
+		g.timeBias = *timeBias
+	}
+	if percentDeadband != nil {
+		g.deadband = *percentDeadband
+	}
+	if clientGroup != nil {
+		g.clientGroup = *clientGroup
+	}
+	return g.updateRate, nil
+}
+
+// resetSent makes the next update send every active item
+func (g *group) resetSent() {
+	for _, it := range g.items {
+		it.sent = false
+	}
+}
+
+// SetName implements IOPCGroupStateMgt::SetName
+func (g *group) SetName(name string) error {
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return err
+	}
+	if name == "" {
+		return newError(EInvalidArg)
+	}
+	if other := g.conn.groupByName(name); other != nil && other != g {
+		return newError(opcda.OPCDuplicateName)
+	}
+	g.name = name
+	return nil
+}
+
+// QuerySyncIO implements QueryInterface for IOPCSyncIO
+func (g *group) QuerySyncIO() (opcda.SyncIOBackend, error) {
+	return (*syncIO)(g), nil
+}
+
+// QueryAsyncIO2 implements QueryInterface for IOPCAsyncIO2
+func (g *group) QueryAsyncIO2() (opcda.AsyncIO2Backend, error) {
+	return (*asyncIO2)(g), nil
+}
+
+// QuerySyncIO2 implements QueryInterface for IOPCSyncIO2
+func (g *group) QuerySyncIO2() (opcda.SyncIO2Backend, error) {
+	if g.conn.server.withoutDA3 {
+		return nil, newError(ENoInterface)
+	}
+	return (*syncIO2)(g), nil
+}
+
+// QueryAsyncIO3 implements QueryInterface for IOPCAsyncIO3
+func (g *group) QueryAsyncIO3() (opcda.AsyncIO3Backend, error) {
+	if g.conn.server.withoutDA3 {
+		return nil, newError(ENoInterface)
+	}
+	return (*asyncIO3)(g), nil
+}
+
+// QueryItemMgt implements QueryInterface for IOPCItemMgt
+func (g *group) QueryItemMgt() (opcda.ItemMgtBackend, error) {
+	return (*itemMgt)(g), nil
+}
+
+// QueryItemDeadbandMgt implements QueryInterface for IOPCItemDeadbandMgt
+func (g *group) QueryItemDeadbandMgt() (opcda.ItemDeadbandMgtBackend, error) {
+	if g.conn.server.withoutDA3 {
+		return nil, newError(ENoInterface)
+	}
+	return (*itemDeadbandMgt)(g), nil
+}
+
+// QueryGroupStateMgt2 implements QueryInterface for IOPCGroupStateMgt2
+func (g *group) QueryGroupStateMgt2() (opcda.GroupStateMgt2Backend, error) {
+	if g.conn.server.withoutDA3 {
+		return nil, newError(ENoInterface)
+	}
+	return (*groupStateMgt2)(g), nil
+}
+
+// QueryItemSamplingMgt implements QueryInterface for IOPCItemSamplingMgt
+func (g *group) QueryItemSamplingMgt() (opcda.ItemSamplingMgtBackend, error) {
+	if g.conn.server.withoutDA3 {
+		return nil, newError(ENoInterface)
+	}
+	return (*itemSamplingMgt)(g), nil
+}
+
+// AdviseDataCallback implements IConnectionPoint::Advise for IOPCDataCallback
+func (g *group) AdviseDataCallback(callback opcda.DataCallback) (uint32, error) {
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return 0, err
+	}
+	g.nextCookie++
+	g.callbacks[g.nextCookie] = callback
+	g.resetSent()
+	return g.nextCookie, nil
+}
+
+// UnadviseDataCallback implements IConnectionPoint::Unadvise for IOPCDataCallback
+func (g *group) UnadviseDataCallback(cookie uint32) error {
+	g.lock()
+	defer g.unlock()
+	if _, ok := g.callbacks[cookie]; !ok {
+		return newError(ENoConnection)
+	}
+	delete(g.callbacks, cookie)
+	return nil
+}
+
+// Release does nothing, the group lives until RemoveGroup
+func (g *group) Release() {}
+
+// syncIO implements IOPCSyncIO on a group
+type syncIO group
+
+// Read implements IOPCSyncIO::Read
+func (s *syncIO) Read(source com.OPCDATASOURCE, serverHandles []uint32) ([]*com.ItemState, []int32, error) {
+	g := (*group)(s)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	states := make([]*com.ItemState, len(serverHandles))
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		states[i] = &com.ItemState{}
+		it, ok := g.items[handle]
+		if !ok {
+			errs[i] = int32(opcda.OPCInvalidHandle)
+			continue
+		}
+		states[i].ClientHandle = int32(it.clientHandle)
+		if it.tag.accessRights()&opcda.OPC_READABLE == 0 {
+			errs[i] = int32(opcda.OPCBadRights)
+			continue
+		}
+		if source == opcda.OPC_DS_DEVICE || g.active && it.active {
+			it.tag.sample(g.conn.server.startTime, now)
+		}
+		value, quality, code := it.read(it.current())
+		if source == opcda.OPC_DS_CACHE && !(g.active && it.active) {
+			quality = QualityOutOfService
+		}
+		states[i].Value = value
+		states[i].Quality = quality
+		states[i].Timestamp = it.tag.timestamp
+		errs[i] = code
+	}
+	return states, errs, nil
+}
+
+// Write implements IOPCSyncIO::Write
+func (s *syncIO) Write(serverHandles []uint32, values []interface{}) ([]int32, error) {
+	g := (*group)(s)
+	if len(values) != len(serverHandles) {
+		return nil, newError(EInvalidArg)
+	}
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, code := g.writable(handle)
+		if code != 0 {
+			errs[i] = code
+			continue
+		}
+		if err := it.tag.set(values[i], now); err != nil {
+			errs[i] = conversionError(err)
+		}
+	}
+	return errs, nil
+}
+
+// Release does nothing
+func (s *syncIO) Release() {}
+
+// readable returns the item of a handle or the error code why it cannot be read
+func (g *group) readable(handle uint32) (*item, int32) {
+	it, ok := g.items[handle]
+	if !ok {
+		return nil, int32(opcda.OPCInvalidHandle)
+	}
+	if it.tag.accessRights()&opcda.OPC_READABLE == 0 {
+		return nil, int32(opcda.OPCBadRights)
+	}
+	return it, 0
+}
+
+// writable returns the item of a handle or the error code why it cannot be written
+func (g *group) writable(handle uint32) (*item, int32) {
+	it, ok := g.items[handle]
+	if !ok {
+		return nil, int32(opcda.OPCInvalidHandle)
+	}
+	if it.tag.accessRights()&opcda.OPC_WRITEABLE == 0 {
+		return nil, int32(opcda.OPCBadRights)
+	}
+	return it, 0
+}
+
+// asyncIO2 implements IOPCAsyncIO2 on a group
+type asyncIO2 group
+
+// Read implements IOPCAsyncIO2::Read, the values are read from the device
+func (a *asyncIO2) Read(serverHandles []uint32, transactionID uint32) (uint32, []int32, error) {
+	g := (*group)(a)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return 0, nil, err
+	}
+	if len(g.callbacks) == 0 {
+		return 0, nil, newError(ENoConnection)
+	}
+	t := &transaction{transactionID: transactionID, kind: transactionRead}
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, code := g.readable(handle)
+		if code != 0 {
+			errs[i] = code
+			continue
+		}
+		t.items = append(t.items, it)
+	}
+	if len(t.items) == 0 {
+		return 0, errs, nil
+	}
+	return g.enqueue(t, g.conn.server.latency), errs, nil
+}
+
+// Write implements IOPCAsyncIO2::Write
+func (a *asyncIO2) Write(serverHandles []uint32, values []interface{}, transactionID uint32) (uint32, []int32, error) {
+	g := (*group)(a)
+	if len(values) != len(serverHandles) {
+		return 0, nil, newError(EInvalidArg)
+	}
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return 0, nil, err
+	}
+	if len(g.callbacks) == 0 {
+		return 0, nil, newError(ENoConnection)
+	}
+	t := &transaction{transactionID: transactionID, kind: transactionWrite}
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, code := g.writable(handle)
+		if code != 0 {
+			errs[i] = code
+			continue
+		}
+		t.items = append(t.items, it)
+		t.values = append(t.values, values[i])
+	}
+	if len(t.items) == 0 {
+		return 0, errs, nil
+	}
+	return g.enqueue(t, g.conn.server.latency), errs, nil
+}
+
+// Refresh2 implements IOPCAsyncIO2::Refresh2, every active item is sent with OnDataChange
+func (a *asyncIO2) Refresh2(source com.OPCDATASOURCE, transactionID uint32) (uint32, error) {
+	g := (*group)(a)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return 0, err
+	}
+	if len(g.callbacks) == 0 {
+		return 0, newError(ENoConnection)
+	}
+	active := false
+	for _, it := range g.items {
+		active = active || it.active
+	}
+	if !g.active || !active {
+		return 0, newError(EFail)
+	}
+	return g.enqueue(&transaction{transactionID: transactionID, kind: transactionRefresh, source: source}, g.conn.server.latency), nil
+}
+
+// Cancel2 implements IOPCAsyncIO2::Cancel2, only calls the worker has not started can be cancelled
+func (a *asyncIO2) Cancel2(cancelID uint32) error {
+	g := (*group)(a)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return err
+	}
+	for i, t := range g.pending {
+		if t.cancelID != cancelID || t.kind == transactionCancel {
+			continue
+		}
+		g.pending = append(g.pending[:i], g.pending[i+1:]...)
+		g.enqueue(&transaction{transactionID: t.transactionID, kind: transactionCancel}, 0)
+		return nil
+	}
+	return newError(EFail)
+}
+
+// Release does nothing
+func (a *asyncIO2) Release() {}
+
+// itemMgt implements IOPCItemMgt on a group
+type itemMgt group
+
+// validate checks an item definition, the caller holds the server lock
+func (g *group) validate(def com.ItemDefinition) (*tag, com.TagOPCITEMRESULTStruct, int32) {
+	var result com.TagOPCITEMRESULTStruct
+	if def.ItemID == "" {
+		return nil, result, int32(opcda.OPCInvalidItemID)
+	}
+	t, ok := g.conn.server.tags[def.ItemID]
+	if !ok {
+		return nil, result, int32(opcda.OPCUnknownItemID)
+	}
+	if def.AccessPath != "" {
+		return nil, result, int32(opcda.OPCUnknownPath)
+	}
+	if !convertible(t, def.RequestedDataType) {
+		return nil, result, int32(opcda.OPCBadType)
+	}
+	result.NativeType = uint16(t.vt)
+	result.AccessRights = t.accessRights()
+	return t, result, 0
+}
+
+// convertible reports whether the values of a tag can be returned as vt
+func convertible(t *tag, vt com.VT) bool {
+	_, err := convert(t.value, vt)
+	return err == nil || errors.Is(err, errRange)
+}
+
+// AddItems implements IOPCItemMgt::AddItems
+func (m *itemMgt) AddItems(items []com.ItemDefinition) ([]com.TagOPCITEMRESULTStruct, []int32, error) {
+	g := (*group)(m)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return nil, nil, err
+	}
+	results := make([]com.TagOPCITEMRESULTStruct, len(items))
+	errs := make([]int32, len(items))
+	for i, def := range items {
+		t, result, code := g.validate(def)
+		if code != 0 {
+			errs[i] = code
+			continue
+		}
+		g.nextItem++
+		g.items[g.nextItem] = &item{
+			serverHandle:  g.nextItem,
+			clientHandle:  def.ClientHandle,
+			tag:           t,
+			active:        def.Active,
+			requestedType: def.RequestedDataType,
+		}
+		result.Server = g.nextItem
+		results[i] = result
+	}
+	return results, errs, nil
+}
+
+// ValidateItems implements IOPCItemMgt::ValidateItems
+func (m *itemMgt) ValidateItems(items []com.ItemDefinition, blobUpdate bool) ([]com.TagOPCITEMRESULTStruct, []int32, error) {
+	g := (*group)(m)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return nil, nil, err
+	}
+	results := make([]com.TagOPCITEMRESULTStruct, len(items))
+	errs := make([]int32, len(items))
+	for i, def := range items {
+		_, results[i], errs[i] = g.validate(def)
+	}
+	return results, errs, nil
+}
+
+// RemoveItems implements IOPCItemMgt::RemoveItems
+func (m *itemMgt) RemoveItems(serverHandles []uint32) ([]int32, error) {
+	return m.each(serverHandles, func(g *group, _ int, it *item) {
+		delete(g.items, it.serverHandle)
+	})
+}
+
+// SetActiveState implements IOPCItemMgt::SetActiveState
+func (m *itemMgt) SetActiveState(serverHandles []uint32, active bool) ([]int32, error) {
+	return m.each(serverHandles, func(_ *group, _ int, it *item) {
+		if active && !it.active {
+			it.sent = false
+		}
+		it.active = active
+	})
+}
+
+// SetClientHandles implements IOPCItemMgt::SetClientHandles
+func (m *itemMgt) SetClientHandles(serverHandles []uint32, clientHandles []uint32) ([]int32, error) {
+	if len(clientHandles) != len(serverHandles) {
+		return nil, newError(EInvalidArg)
+	}
+	return m.each(serverHandles, func(_ *group, i int, it *item) {
+		it.clientHandle = clientHandles[i]
+	})
+}
+
+// SetDatatypes implements IOPCItemMgt::SetDatatypes
+func (m *itemMgt) SetDatatypes(serverHandles []uint32, requestedDataTypes []com.VT) ([]int32, error) {
+	if len(requestedDataTypes) != len(serverHandles) {
+		return nil, newError(EInvalidArg)
+	}
+	g := (*group)(m)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, ok := g.items[handle]
+		if !ok {
+			errs[i] = int32(opcda.OPCInvalidHandle)
+			continue
+		}
+		if !convertible(it.tag, requestedDataTypes[i]) {
+			errs[i] = int32(opcda.OPCBadType)
+			continue
+		}
+		it.requestedType = requestedDataTypes[i]
+	}
+	return errs, nil
+}
+
+// each applies f to the items of serverHandles with their index
+func (m *itemMgt) each(serverHandles []uint32, f func(g *group, i int, it *item)) ([]int32, error) {
+	g := (*group)(m)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, ok := g.items[handle]
+		if !ok {
+			errs[i] = int32(opcda.OPCInvalidHandle)
+			continue
+		}
+		f(g, i, it)
+	}
+	return errs, nil
+}
+
+// Release does nothing
+func (m *itemMgt) Release() {}
@@ -0,0 +1,359 @@
+package opcsim
+
+import (
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+)
+
+// syncIO2 implements the methods IOPCSyncIO2 adds to IOPCSyncIO on a group
+type syncIO2 group
+
+// ReadMaxAge implements IOPCSyncIO2::ReadMaxAge, an item is sampled unless its last sample is younger than its max age
+func (s *syncIO2) ReadMaxAge(serverHandles []uint32, maxAges []uint32) ([]*com.ItemState, []int32, error) {
+	g := (*group)(s)
+	if len(maxAges) != len(serverHandles) {
+		return nil, nil, newError(EInvalidArg)
+	}
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	states := make([]*com.ItemState, len(serverHandles))
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, code := g.readable(handle)
+		if code != 0 {
+			errs[i] = code
+			continue
+		}
+		it.tag.sampleMaxAge(g.conn.server.startTime, now, maxAges[i])
+		value, quality, code := it.read(it.current())
+		if code != 0 {
+			errs[i] = code
+			continue
+		}
+		states[i] = &com.ItemState{
+			ClientHandle: int32(it.clientHandle),
+			Value:        value,
+			Quality:      quality,
+			Timestamp:    it.tag.timestamp,
+		}
+	}
+	return states, errs, nil
+}
+
+// WriteVQT implements IOPCSyncIO2::WriteVQT
+func (s *syncIO2) WriteVQT(serverHandles []uint32, items []*com.ItemVQT) ([]int32, error) {
+	g := (*group)(s)
+	if len(items) != len(serverHandles) {
+		return nil, newError(EInvalidArg)
+	}
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, code := g.writable(handle)
+		if code != 0 {
+			errs[i] = code
+			continue
+		}
+		if err := it.tag.setVQT(items[i], now); err != nil {
+			errs[i] = conversionError(err)
+		}
+	}
+	return errs, nil
+}
+
+// Release does nothing
+func (s *syncIO2) Release() {}
+
+// asyncIO3 implements the methods IOPCAsyncIO3 adds to IOPCAsyncIO2 on a group
+type asyncIO3 group
+
+// ReadMaxAge implements IOPCAsyncIO3::ReadMaxAge, the results are sent with OnReadComplete
+func (a *asyncIO3) ReadMaxAge(serverHandles []uint32, maxAges []uint32, transactionID uint32) (uint32, []int32, error) {
+	g := (*group)(a)
+	if len(maxAges) != len(serverHandles) {
+		return 0, nil, newError(EInvalidArg)
+	}
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return 0, nil, err
+	}
+	if len(g.callbacks) == 0 {
+		return 0, nil, newError(ENoConnection)
+	}
+	t := &transaction{transactionID: transactionID, kind: transactionRead, maxAges: []uint32{}}
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, code := g.readable(handle)
+		if code != 0 {
+			errs[i] = code
+			continue
+		}
+		t.items = append(t.items, it)
+		t.maxAges = append(t.maxAges, maxAges[i])
+	}
+	if len(t.items) == 0 {
+		return 0, errs, nil
+	}
+	return g.enqueue(t, g.conn.server.latency), errs, nil
+}
+
+// WriteVQT implements IOPCAsyncIO3::WriteVQT, the results are sent with OnWriteComplete
+func (a *asyncIO3) WriteVQT(serverHandles []uint32, items []*com.ItemVQT, transactionID uint32) (uint32, []int32, error) {
+	g := (*group)(a)
+	if len(items) != len(serverHandles) {
+		return 0, nil, newError(EInvalidArg)
+	}
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return 0, nil, err
+	}
+	if len(g.callbacks) == 0 {
+		return 0, nil, newError(ENoConnection)
+	}
+	t := &transaction{transactionID: transactionID, kind: transactionWrite, vqts: []*com.ItemVQT{}}
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, code := g.writable(handle)
+		if code != 0 {
+			errs[i] = code
+			continue
+		}
+		t.items = append(t.items, it)
+		t.vqts = append(t.vqts, items[i])
+	}
+	if len(t.items) == 0 {
+		return 0, errs, nil
+	}
+	return g.enqueue(t, g.conn.server.latency), errs, nil
+}
+
+// Release does nothing
+func (a *asyncIO3) Release() {}
+
+// itemDeadbandMgt implements IOPCItemDeadbandMgt on a group
+type itemDeadbandMgt group
+
+// SetItemDeadband implements IOPCItemDeadbandMgt::SetItemDeadband, only analog items support a deadband
+func (m *itemDeadbandMgt) SetItemDeadband(serverHandles []uint32, percentDeadbands []float32) ([]int32, error) {
+	if len(percentDeadbands) != len(serverHandles) {
+		return nil, newError(EInvalidArg)
+	}
+	return m.each(serverHandles, func(i int, it *item) int32 {
+		if percentDeadbands[i] < 0 || percentDeadbands[i] > 100 {
+			return int32(opcda.OPCRange)
+		}
+		it.deadband = percentDeadbands[i]
+		it.deadbandSet = true
+		return 0
+	})
+}
+
+// GetItemDeadband implements IOPCItemDeadbandMgt::GetItemDeadband
+func (m *itemDeadbandMgt) GetItemDeadband(serverHandles []uint32) ([]float32, []int32, error) {
+	deadbands := make([]float32, len(serverHandles))
+	errs, err := m.each(serverHandles, func(i int, it *item) int32 {
+		if !it.deadbandSet {
+			return int32(opcda.OPCDeadbandNotSet)
+		}
+		deadbands[i] = it.deadband
+		return 0
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return deadbands, errs, nil
+}
+
+// ClearItemDeadband implements IOPCItemDeadbandMgt::ClearItemDeadband
+func (m *itemDeadbandMgt) ClearItemDeadband(serverHandles []uint32) ([]int32, error) {
+	return m.each(serverHandles, func(i int, it *item) int32 {
+		if !it.deadbandSet {
+			return int32(opcda.OPCDeadbandNotSet)
+		}
+		it.deadband = 0
+		it.deadbandSet = false
+		return 0
+	})
+}
+
+// each calls f with the analog items of the handles under the server lock and returns the error codes
+func (m *itemDeadbandMgt) each(serverHandles []uint32, f func(i int, it *item) int32) ([]int32, error) {
+	g := (*group)(m)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, ok := g.items[handle]
+		switch {
+		case !ok:
+			errs[i] = int32(opcda.OPCInvalidHandle)
+		case !it.tag.analog():
+			errs[i] = int32(opcda.OPCDeadbandNotSupported)
+		default:
+			errs[i] = f(i, it)
+		}
+	}
+	return errs, nil
+}
+
+// Release does nothing
+func (m *itemDeadbandMgt) Release() {}
+
+// itemSamplingMgt implements IOPCItemSamplingMgt on a group
+type itemSamplingMgt group
+
+// SetItemSamplingRate implements IOPCItemSamplingMgt::SetItemSamplingRate, rates are revised like update rates
+func (m *itemSamplingMgt) SetItemSamplingRate(serverHandles []uint32, requestedSamplingRates []uint32) ([]uint32, []int32, error) {
+	if len(requestedSamplingRates) != len(serverHandles) {
+		return nil, nil, newError(EInvalidArg)
+	}
+	revised := make([]uint32, len(serverHandles))
+	errs, err := m.each(serverHandles, func(i int, it *item) int32 {
+		revised[i] = reviseUpdateRate(requestedSamplingRates[i])
+		it.samplingRate = revised[i]
+		it.nextSample = time.Time{}
+		if revised[i] != requestedSamplingRates[i] {
+			return int32(opcda.OPCUnsupportedRate)
+		}
+		return 0
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	(*group)(m).notify()
+	return revised, errs, nil
+}
+
+// GetItemSamplingRate implements IOPCItemSamplingMgt::GetItemSamplingRate
+func (m *itemSamplingMgt) GetItemSamplingRate(serverHandles []uint32) ([]uint32, []int32, error) {
+	rates := make([]uint32, len(serverHandles))
+	errs, err := m.each(serverHandles, func(i int, it *item) int32 {
+		if it.samplingRate == 0 {
+			return int32(opcda.OPCRateNotSet)
+		}
+		rates[i] = it.samplingRate
+		return 0
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return rates, errs, nil
+}
+
+// ClearItemSamplingRate implements IOPCItemSamplingMgt::ClearItemSamplingRate, the buffered samples are kept
+// for the next update
+func (m *itemSamplingMgt) ClearItemSamplingRate(serverHandles []uint32) ([]int32, error) {
+	errs, err := m.each(serverHandles, func(i int, it *item) int32 {
+		if it.samplingRate == 0 {
+			return int32(opcda.OPCRateNotSet)
+		}
+		it.samplingRate = 0
+		return 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	(*group)(m).notify()
+	return errs, nil
+}
+
+// SetItemBufferEnable implements IOPCItemSamplingMgt::SetItemBufferEnable, disabling drops the buffered samples
+func (m *itemSamplingMgt) SetItemBufferEnable(serverHandles []uint32, enable []bool) ([]int32, error) {
+	if len(enable) != len(serverHandles) {
+		return nil, newError(EInvalidArg)
+	}
+	return m.each(serverHandles, func(i int, it *item) int32 {
+		it.bufferEnable = enable[i]
+		if !enable[i] {
+			it.buffer = nil
+		}
+		return 0
+	})
+}
+
+// GetItemBufferEnable implements IOPCItemSamplingMgt::GetItemBufferEnable
+func (m *itemSamplingMgt) GetItemBufferEnable(serverHandles []uint32) ([]bool, []int32, error) {
+	enable := make([]bool, len(serverHandles))
+	errs, err := m.each(serverHandles, func(i int, it *item) int32 {
+		enable[i] = it.bufferEnable
+		return 0
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return enable, errs, nil
+}
+
+// each calls f with the items of the handles under the server lock and returns the error codes
+func (m *itemSamplingMgt) each(serverHandles []uint32, f func(i int, it *item) int32) ([]int32, error) {
+	g := (*group)(m)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return nil, err
+	}
+	errs := make([]int32, len(serverHandles))
+	for i, handle := range serverHandles {
+		it, ok := g.items[handle]
+		if !ok {
+			errs[i] = int32(opcda.OPCInvalidHandle)
+			continue
+		}
+		errs[i] = f(i, it)
+	}
+	return errs, nil
+}
+
+// Release does nothing
+func (m *itemSamplingMgt) Release() {}
+
+// groupStateMgt2 implements the methods IOPCGroupStateMgt2 adds to IOPCGroupStateMgt on a group
+type groupStateMgt2 group
+
+// SetKeepAlive implements IOPCGroupStateMgt2::SetKeepAlive, zero disables the keep-alive callbacks
+// and other times are revised like update rates
+func (m *groupStateMgt2) SetKeepAlive(keepAliveTime uint32) (uint32, error) {
+	g := (*group)(m)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return 0, err
+	}
+	if keepAliveTime != 0 {
+		keepAliveTime = reviseUpdateRate(keepAliveTime)
+	}
+	g.keepAlive = keepAliveTime
+	g.lastCallback = time.Now()
+	g.notify()
+	return g.keepAlive, nil
+}
+
+// GetKeepAlive implements IOPCGroupStateMgt2::GetKeepAlive
+func (m *groupStateMgt2) GetKeepAlive() (uint32, error) {
+	g := (*group)(m)
+	g.lock()
+	defer g.unlock()
+	if err := g.check(); err != nil {
+		return 0, err
+	}
+	return g.keepAlive, nil
+}
+
+// Release does nothing
+func (m *groupStateMgt2) Release() {}
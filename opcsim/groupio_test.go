@@ -0,0 +1,244 @@
+package opcsim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncIO2(t *testing.T) {
+	sim, server := connect(t)
+	group, err := server.GetOPCGroups().Add("sync2")
+	require.NoError(t, err)
+	assert.True(t, group.SupportsSyncIO2())
+	added, _, err := group.OPCItems().AddItems([]string{"Saw-toothed Waves.Real8", "Bucket Brigade.Int4", "Write Only.Int4"})
+	require.NoError(t, err)
+	handles := []uint32{added[0].GetServerHandle(), added[1].GetServerHandle(), added[2].GetServerHandle()}
+
+	first, errs, err := group.SyncReadMaxAge(handles[:1], []uint32{0})
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	time.Sleep(20 * time.Millisecond)
+	cached, errs, err := group.SyncReadMaxAge(handles[:1], []uint32{0xFFFFFFFF})
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	assert.Equal(t, first[0].Value, cached[0].Value)
+	assert.Equal(t, int32(added[0].GetClientHandle()), cached[0].ClientHandle)
+	device, _, err := group.SyncReadMaxAge(handles[:1], []uint32{1})
+	require.NoError(t, err)
+	assert.NotEqual(t, first[0].Value, device[0].Value)
+
+	timestamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	errs, err = group.SyncWriteVQT(handles[1:], []interface{}{int32(21), int32(22)}, []com.Quality{QualityOutOfService, QualityGood}, []time.Time{timestamp, {}})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	value, quality, ts, err := sim.Value("Bucket Brigade.Int4")
+	require.NoError(t, err)
+	assert.Equal(t, int32(21), value)
+	assert.Equal(t, QualityOutOfService, quality)
+	assert.True(t, ts.Equal(timestamp))
+
+	states, errs, err := group.SyncReadMaxAge(handles[1:], []uint32{0, 0})
+	require.NoError(t, err)
+	assert.Equal(t, int32(21), states[0].Value)
+	assert.True(t, states[0].Timestamp.Equal(timestamp))
+	assert.Nil(t, states[1])
+	assertOPCError(t, opcda.OPCBadRights, errs[1])
+
+	_, _, err = group.SyncReadMaxAge(handles, []uint32{0})
+	assert.Error(t, err)
+	_, err = group.SyncWriteVQT(handles[1:2], []interface{}{int32(1)}, []com.Quality{}, nil)
+	assert.Error(t, err)
+}
+
+func TestAsyncIO3(t *testing.T) {
+	sim, server := connect(t)
+	group, err := server.GetOPCGroups().Add("async3")
+	require.NoError(t, err)
+	assert.True(t, group.SupportsAsyncIO3())
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Int4")
+	require.NoError(t, err)
+	handles := []uint32{item.GetServerHandle()}
+
+	_, _, err = group.AsyncReadMaxAge(handles, []uint32{0}, 1)
+	assertOPCError(t, ENoConnection, err)
+
+	readCh := make(chan *opcda.ReadCompleteCallBackData, 1)
+	writeCh := make(chan *opcda.WriteCompleteCallBackData, 1)
+	require.NoError(t, group.RegisterReadComplete(readCh))
+	require.NoError(t, group.RegisterWriteComplete(writeCh))
+
+	timestamp := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	_, errs, err := group.AsyncWriteVQT(handles, []interface{}{int32(31)}, []com.Quality{QualityOutOfService}, []time.Time{timestamp}, 200)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	written := receive(t, writeCh)
+	assert.Equal(t, uint32(200), written.TransID)
+	assert.Equal(t, []uint32{item.GetClientHandle()}, written.ItemClientHandles)
+	assert.NoError(t, written.Errors[0])
+	_, quality, _, err := sim.Value("Bucket Brigade.Int4")
+	require.NoError(t, err)
+	assert.Equal(t, QualityOutOfService, quality)
+
+	_, errs, err = group.AsyncReadMaxAge(append(handles, 1000), []uint32{0xFFFFFFFF, 0}, 201)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assertOPCError(t, opcda.OPCInvalidHandle, errs[1])
+	read := receive(t, readCh)
+	assert.Equal(t, uint32(201), read.TransID)
+	assert.Equal(t, []interface{}{int32(31)}, read.Values)
+	assert.Equal(t, []com.Quality{QualityOutOfService}, read.Qualities)
+	assert.True(t, read.TimeStamps[0].Equal(timestamp))
+}
+
+func TestGroupWithoutDA3(t *testing.T) {
+	_, server := connect(t, WithoutDA3())
+	group, err := server.GetOPCGroups().Add("da2")
+	require.NoError(t, err)
+	assert.False(t, group.SupportsSyncIO2())
+	assert.False(t, group.SupportsAsyncIO3())
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Int4")
+	require.NoError(t, err)
+	handles := []uint32{item.GetServerHandle()}
+
+	_, _, err = group.SyncReadMaxAge(handles, []uint32{0})
+	assertOPCError(t, ENoInterface, err)
+	_, err = group.SyncWriteVQT(handles, []interface{}{int32(1)}, nil, nil)
+	assertOPCError(t, ENoInterface, err)
+	_, _, err = group.AsyncReadMaxAge(handles, []uint32{0}, 1)
+	assertOPCError(t, ENoInterface, err)
+	_, _, err = group.AsyncWriteVQT(handles, []interface{}{int32(1)}, nil, nil, 2)
+	assertOPCError(t, ENoInterface, err)
+	assert.False(t, group.SupportsItemDeadband())
+	assertOPCError(t, ENoInterface, item.SetDeadband(1))
+	errs := group.OPCItems().ClearDeadbands(handles)
+	assertOPCError(t, ENoInterface, errs[0])
+	assert.False(t, group.SupportsItemSampling())
+	_, err = item.SetSamplingRate(10)
+	assertOPCError(t, ENoInterface, err)
+	errs, err = group.OPCItems().SetBufferEnable(handles, []bool{true})
+	require.NoError(t, err)
+	assertOPCError(t, ENoInterface, errs[0])
+	assert.False(t, group.SupportsKeepAlive())
+	_, err = group.SetKeepAlive(1000)
+	assertOPCError(t, ENoInterface, err)
+}
+
+func TestItemDeadband(t *testing.T) {
+	sim, server := connect(t, WithTags(
+		Tag{ItemID: "Analog", Value: float64(0), EULow: 0, EUHigh: 100},
+		Tag{ItemID: "Digital", Value: false},
+	))
+	groups := server.GetOPCGroups()
+	groups.SetDefaultGroupUpdateRate(20)
+	groups.SetDefaultGroupDeadband(10)
+	group, err := groups.Add("item deadband")
+	require.NoError(t, err)
+	assert.True(t, group.SupportsItemDeadband())
+	items := group.OPCItems()
+	added, _, err := items.AddItems([]string{"Analog", "Digital"})
+	require.NoError(t, err)
+	analog := added[0]
+	handles := []uint32{added[0].GetServerHandle(), added[1].GetServerHandle(), 1000}
+
+	_, err = analog.GetDeadband()
+	assertOPCError(t, opcda.OPCDeadbandNotSet, err)
+	_, err = items.SetDeadbands(handles, []float32{1, 1})
+	assert.EqualError(t, err, "percentDeadbands and serverHandles have different lengths")
+	errs, err := items.SetDeadbands(handles, []float32{1, 1, 1})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assertOPCError(t, opcda.OPCDeadbandNotSupported, errs[1])
+	assert.Error(t, errs[2])
+	deadbands, errs := items.GetDeadbands(handles[:1])
+	assert.NoError(t, errs[0])
+	assert.Equal(t, []float32{1}, deadbands)
+	assertOPCError(t, opcda.OPCRange, analog.SetDeadband(101))
+
+	ch := make(chan *opcda.DataChangeCallBackData, 10)
+	require.NoError(t, group.RegisterDataChange(ch))
+	receive(t, ch)
+	// the item deadband of 1% overrides the group deadband of 10%
+	require.NoError(t, sim.SetValue("Analog", 5.0))
+	data := receive(t, ch)
+	assert.Equal(t, []interface{}{5.0}, data.Values)
+
+	errs = items.ClearDeadbands(handles[:1])
+	assert.NoError(t, errs[0])
+	assertOPCError(t, opcda.OPCDeadbandNotSet, analog.ClearDeadband())
+	require.NoError(t, sim.SetValue("Analog", 10.0))
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, ch)
+}
+
+func TestItemSampling(t *testing.T) {
+	_, server := connect(t, WithTags(
+		Tag{ItemID: "Vibration", Value: float64(0), Generator: func(elapsed time.Duration) interface{} {
+			return float64(elapsed.Milliseconds())
+		}},
+		Tag{ItemID: "Setpoint", Value: float64(0)},
+	))
+	groups := server.GetOPCGroups()
+	groups.SetDefaultGroupUpdateRate(200)
+	group, err := groups.Add("item sampling")
+	require.NoError(t, err)
+	assert.True(t, group.SupportsItemSampling())
+	items := group.OPCItems()
+	added, _, err := items.AddItems([]string{"Vibration", "Setpoint"})
+	require.NoError(t, err)
+	vibration, setpoint := added[0], added[1]
+	handles := []uint32{vibration.GetServerHandle(), setpoint.GetServerHandle(), 1000}
+
+	_, err = vibration.GetSamplingRate()
+	assertOPCError(t, opcda.OPCRateNotSet, err)
+	assertOPCError(t, opcda.OPCRateNotSet, setpoint.ClearSamplingRate())
+	_, _, err = items.SetSamplingRates(handles, []uint32{20})
+	assert.EqualError(t, err, "samplingRates and serverHandles have different lengths")
+	revised, errs, err := items.SetSamplingRates(handles, []uint32{20, 1, 20})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Error(t, errs[2])
+	assert.Equal(t, []uint32{20, minUpdateRate, 0}, revised)
+	rates, errs := items.GetSamplingRates(handles[:1])
+	assert.NoError(t, errs[0])
+	assert.Equal(t, []uint32{20}, rates)
+	assert.NoError(t, setpoint.ClearSamplingRate())
+	_, err = items.SetBufferEnable(handles, []bool{true})
+	assert.EqualError(t, err, "enable and serverHandles have different lengths")
+	errs, err = items.SetBufferEnable(handles[:1], []bool{true})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	enable, errs := items.GetBufferEnable(handles[:2])
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, []bool{true, false}, enable)
+
+	ch := make(chan *opcda.DataChangeCallBackData, 10)
+	require.NoError(t, group.RegisterDataChange(ch))
+	receive(t, ch)
+	// the samples taken every 20ms are delivered in order with the next update
+	data := receive(t, ch)
+	require.Greater(t, len(data.ItemClientHandles), 2)
+	for i := range data.ItemClientHandles {
+		assert.Equal(t, vibration.GetClientHandle(), data.ItemClientHandles[i])
+		if i > 0 {
+			assert.True(t, data.TimeStamps[i].After(data.TimeStamps[i-1]))
+			assert.Greater(t, data.Values[i].(float64), data.Values[i-1].(float64))
+		}
+	}
+
+	errs = items.ClearSamplingRates(handles[:1])
+	assert.NoError(t, errs[0])
+	_, err = vibration.GetSamplingRate()
+	assertOPCError(t, opcda.OPCRateNotSet, err)
+	assert.NoError(t, vibration.SetBufferEnable(false))
+	// the item is sampled at the update rate of the group again
+	receive(t, ch)
+	data = receive(t, ch)
+	assert.Equal(t, []uint32{vibration.GetClientHandle()}, data.ItemClientHandles)
+}
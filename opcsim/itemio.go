@@ -0,0 +1,100 @@
+package opcsim
+
+import (
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+)
+
+// itemIO implements IOPCItemIO on a connection
+type itemIO connection
+
+// QueryItemIO implements QueryInterface for IOPCItemIO
+func (c *connection) QueryItemIO() (opcda.ItemIOBackend, error) {
+	if c.server.withoutDA3 {
+		return nil, newError(ENoInterface)
+	}
+	return (*itemIO)(c), nil
+}
+
+// Read implements IOPCItemIO::Read, the values are sampled unless the last sample is younger than the max age
+func (i *itemIO) Read(itemIDs []string, maxAges []uint32) ([]*com.ItemState, []int32, error) {
+	c := (*connection)(i)
+	if len(maxAges) != len(itemIDs) {
+		return nil, nil, newError(EInvalidArg)
+	}
+	s := c.server
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := c.check(); err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	states := make([]*com.ItemState, len(itemIDs))
+	errs := make([]int32, len(itemIDs))
+	for n, itemID := range itemIDs {
+		t, code := c.readable(itemID)
+		if code != 0 {
+			errs[n] = code
+			continue
+		}
+		t.sampleMaxAge(s.startTime, now, maxAges[n])
+		states[n] = &com.ItemState{Value: t.value, Quality: t.quality, Timestamp: t.timestamp}
+	}
+	return states, errs, nil
+}
+
+// WriteVQT implements IOPCItemIO::WriteVQT
+func (i *itemIO) WriteVQT(itemIDs []string, items []*com.ItemVQT) ([]int32, error) {
+	c := (*connection)(i)
+	if len(items) != len(itemIDs) {
+		return nil, newError(EInvalidArg)
+	}
+	s := c.server
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	errs := make([]int32, len(itemIDs))
+	for n, itemID := range itemIDs {
+		t, code := c.writable(itemID)
+		if code != 0 {
+			errs[n] = code
+			continue
+		}
+		if err := t.setVQT(items[n], now); err != nil {
+			errs[n] = conversionError(err)
+		}
+	}
+	return errs, nil
+}
+
+// Release does nothing
+func (i *itemIO) Release() {}
+
+// readable returns the tag of an item ID or the error code why it cannot be read, the caller holds the lock
+func (c *connection) readable(itemID string) (*tag, int32) {
+	t, code := c.findTag(itemID)
+	if code != 0 {
+		return nil, code
+	}
+	if t.accessRights()&opcda.OPC_READABLE == 0 {
+		return nil, int32(opcda.OPCBadRights)
+	}
+	return t, 0
+}
+
+// writable returns the tag of an item ID or the error code why it cannot be written, the caller holds the lock
+func (c *connection) writable(itemID string) (*tag, int32) {
+	t, code := c.findTag(itemID)
+	if code != 0 {
+		return nil, code
+	}
+	if t.accessRights()&opcda.OPC_WRITEABLE == 0 {
+		return nil, int32(opcda.OPCBadRights)
+	}
+	return t, 0
+}
@@ -0,0 +1,388 @@
+// Package opcsim is an in-memory OPC DA server for tests.
+//
+// A Server simulates a configurable tag table and implements the server side of IOPCServer, IOPCCommon,
+// IOPCItemProperties, IOPCBrowseServerAddressSpace, IOPCBrowse, IOPCItemIO and of the group interfaces: handles,
+// update rates, deadband, qualities, synchronous and asynchronous IO with the max age reads and VQT writes of
+// OPC DA 3.0 and data change callbacks. It is an opcda.Transport,
+// so the whole client API runs in-process on every platform:
+//
+//	sim, err := opcsim.NewServer()
+//	if err != nil {
+//		return err
+//	}
+//	defer sim.Close()
+//	server, err := opcda.Connect(opcsim.DefaultProgID, "", opcda.WithTransport(sim))
+package opcsim
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+)
+
+// DefaultProgID is the ProgID served when WithProgID is not given
+const DefaultProgID = "OPCSim.Simulation.1"
+
+// Item qualities used by the simulator
+const (
+	QualityBad          = com.QualityBad
+	QualityOutOfService = com.QualityOutOfService
+	QualityGood         = com.QualityGood
+)
+
+// HRESULT values returned by the simulator besides the OPC errors of opcda
+const (
+	EFail         = uint32(0x80004005)
+	EInvalidArg   = uint32(0x80070057)
+	EClassNotReg  = uint32(0x80040154)
+	ENoConnection = uint32(0x80040200)
+	EDisconnected = uint32(0x80010108)
+	ENoInterface  = uint32(0x80004002)
+)
+
+// sFalse is the S_FALSE master error and quality of callbacks with failed items
+const sFalse = 1
+
+// standard item properties
+const (
+	propertyDataType     = 1
+	propertyValue        = 2
+	propertyQuality      = 3
+	propertyTimestamp    = 4
+	propertyAccessRights = 5
+	propertyScanRate     = 6
+	propertyEUType       = 7
+	propertyEUInfo       = 8
+	propertyDescription  = 101
+)
+
+// minUpdateRate is the fastest update rate of the groups in milliseconds
+const minUpdateRate = 10
+
+// defaultLatency is the time asynchronous calls wait before they are executed
+const defaultLatency = 10 * time.Millisecond
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithTags replaces the default tag table
+func WithTags(tags ...Tag) Option {
+	return func(s *Server) {
+		s.initial = tags
+	}
+}
+
+// WithProgID sets the ProgID accepted by Connect, a CLSID string is accepted as well
+func WithProgID(progID string) Option {
+	return func(s *Server) {
+		s.progID = progID
+	}
+}
+
+// WithLatency sets how long asynchronous calls wait before they are executed, Cancel2 succeeds within that time
+func WithLatency(latency time.Duration) Option {
+	return func(s *Server) {
+		s.latency = latency
+	}
+}
+
+// WithVendorInfo sets the vendor information returned by GetStatus
+func WithVendorInfo(vendorInfo string) Option {
+	return func(s *Server) {
+		s.vendorInfo = vendorInfo
+	}
+}
+
+// WithoutDA3 makes the server implement the OPC DA 2.0 interfaces only, querying IOPCBrowse fails with ENoInterface
+func WithoutDA3() Option {
+	return func(s *Server) {
+		s.withoutDA3 = true
+	}
+}
+
+// tag is the state of a simulated item
+type tag struct {
+	Tag
+	vt        com.VT
+	value     interface{}
+	quality   com.Quality
+	timestamp time.Time
+	sampled   time.Time
+}
+
+// Server is an in-memory OPC DA server
+type Server struct {
+	progID      string
+	vendorInfo  string
+	latency     time.Duration
+	withoutDA3  bool
+	initial     []Tag
+	startTime   time.Time
+	lock        sync.Mutex
+	tags        map[string]*tag
+	state       com.OPCServerState
+	connections map[*connection]bool
+	closed      bool
+}
+
+// NewServer creates a server serving DefaultTags unless WithTags is given, it fails if a tag cannot be added, see
+// AddTag
+func NewServer(opts ...Option) (*Server, error) {
+	s := &Server{
+		progID:      DefaultProgID,
+		vendorInfo:  "opcsim",
+		latency:     defaultLatency,
+		startTime:   time.Now(),
+		tags:        make(map[string]*tag),
+		state:       opcda.OPC_STATUS_RUNNING,
+		connections: make(map[*connection]bool),
+	}
+	s.initial = DefaultTags()
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, t := range s.initial {
+		if err := s.AddTag(t); err != nil {
+			return nil, err
+		}
+	}
+	s.initial = nil
+	return s, nil
+}
+
+// AddTag adds an item to the address space
+func (s *Server) AddTag(t Tag) error {
+	if t.ItemID == "" {
+		return fmt.Errorf("opcsim: empty item ID")
+	}
+	vt, err := typeOf(t.Value)
+	if err != nil {
+		return err
+	}
+	if vt == com.VT_EMPTY {
+		return fmt.Errorf("opcsim: item %s has no initial value", t.ItemID)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.tags[t.ItemID]; ok {
+		return fmt.Errorf("opcsim: duplicate item %s", t.ItemID)
+	}
+	s.tags[t.ItemID] = &tag{Tag: t, vt: vt, value: t.Value, quality: QualityGood, timestamp: s.startTime}
+	return nil
+}
+
+// SetValue sets the value of an item as if the device changed it
+func (s *Server) SetValue(itemID string, value interface{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	t, ok := s.tags[itemID]
+	if !ok {
+		return fmt.Errorf("opcsim: unknown item %s", itemID)
+	}
+	return t.set(value, time.Now())
+}
+
+// SetQuality sets the quality of an item
+func (s *Server) SetQuality(itemID string, quality com.Quality) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	t, ok := s.tags[itemID]
+	if !ok {
+		return fmt.Errorf("opcsim: unknown item %s", itemID)
+	}
+	if t.quality != quality {
+		t.quality = quality
+		t.timestamp = time.Now()
+	}
+	return nil
+}
+
+// Value returns the current value, quality and timestamp of an item
+func (s *Server) Value(itemID string) (interface{}, com.Quality, time.Time, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	t, ok := s.tags[itemID]
+	if !ok {
+		return nil, 0, time.Time{}, fmt.Errorf("opcsim: unknown item %s", itemID)
+	}
+	t.sample(s.startTime, time.Now())
+	return t.value, t.quality, t.timestamp, nil
+}
+
+// ItemIDs returns the sorted item IDs of the address space
+func (s *Server) ItemIDs() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.itemIDs()
+}
+
+func (s *Server) itemIDs() []string {
+	ids := make([]string, 0, len(s.tags))
+	for id := range s.tags {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SetState changes the server state returned by GetStatus
+func (s *Server) SetState(state com.OPCServerState) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.state = state
+}
+
+// Connections returns the number of connected clients
+func (s *Server) Connections() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.connections)
+}
+
+// Shutdown notifies the clients advised on IOPCShutdown with reason
+func (s *Server) Shutdown(reason string) {
+	s.lock.Lock()
+	var callbacks []func(string)
+	for c := range s.connections {
+		for _, cb := range c.shutdown {
+			callbacks = append(callbacks, cb)
+		}
+	}
+	s.lock.Unlock()
+	for _, cb := range callbacks {
+		cb(reason)
+	}
+}
+
+// Close disconnects every client, the following calls fail with RPC_E_DISCONNECTED
+func (s *Server) Close() error {
+	s.lock.Lock()
+	s.closed = true
+	connections := s.connections
+	s.connections = make(map[*connection]bool)
+	s.lock.Unlock()
+	for c := range connections {
+		c.close()
+	}
+	return nil
+}
+
+// Connect implements opcda.Transport, node and credentials are ignored
+func (s *Server) Connect(progID, node string, credentials *opcda.Credentials) (opcda.ServerBackend, error) {
+	if progID != s.progID && progID != clsidOf(s.progID) {
+		return nil, newError(EClassNotReg)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil, newError(EDisconnected)
+	}
+	c := newConnection(s)
+	s.connections[c] = true
+	return c, nil
+}
+
+// clsidOf derives a stable CLSID string from a ProgID
+func clsidOf(progID string) string {
+	var h [16]byte
+	for i := 0; i < len(progID); i++ {
+		h[i%16] = h[i%16]*31 + progID[i]
+	}
+	return fmt.Sprintf("{%02X%02X%02X%02X-%02X%02X-%02X%02X-%02X%02X-%02X%02X%02X%02X%02X%02X}",
+		h[0], h[1], h[2], h[3], h[4], h[5], h[6], h[7], h[8], h[9], h[10], h[11], h[12], h[13], h[14], h[15])
+}
+
+// CLSID returns the CLSID string that Connect accepts in place of the ProgID
+func (s *Server) CLSID() string {
+	return clsidOf(s.progID)
+}
+
+// sample evaluates the generator of the tag, the timestamp changes with the value
+func (t *tag) sample(start, now time.Time) {
+	t.sampled = now
+	if t.Generator == nil {
+		return
+	}
+	_ = t.set(t.Generator(now.Sub(start)), now)
+}
+
+// sampleMaxAge samples the tag unless the last sample is at most maxAge milliseconds old,
+// zero always samples and 0xFFFFFFFF never does
+func (t *tag) sampleMaxAge(start, now time.Time, maxAge uint32) {
+	if maxAge == 0xFFFFFFFF || maxAge != 0 && now.Sub(t.sampled) <= time.Duration(maxAge)*time.Millisecond {
+		return
+	}
+	t.sample(start, now)
+}
+
+// set converts value to the canonical type of the tag and stores it
+func (t *tag) set(value interface{}, now time.Time) error {
+	v, err := convert(value, t.vt)
+	if err != nil {
+		return err
+	}
+	if !equal(v, t.value) {
+		t.value = v
+		t.timestamp = now
+	}
+	return nil
+}
+
+// setVQT stores the value of vqt with its quality and timestamp when they are specified
+func (t *tag) setVQT(vqt *com.ItemVQT, now time.Time) error {
+	v, err := convert(vqt.Value, t.vt)
+	if err != nil {
+		return err
+	}
+	t.value = v
+	t.timestamp = now
+	if vqt.QualitySpecified {
+		t.quality = vqt.Quality
+	}
+	if vqt.TimestampSpecified {
+		t.timestamp = vqt.Timestamp
+	}
+	return nil
+}
+
+var errorStrings = map[uint32]string{
+	EFail:         "Unspecified error",
+	EInvalidArg:   "The parameter is incorrect",
+	EClassNotReg:  "Class not registered",
+	ENoConnection: "The client has no callback registered",
+	EDisconnected: "The object invoked has disconnected from its clients",
+	ENoInterface:  "No such interface supported",
+}
+
+func init() {
+	for code, message := range map[*uint32]string{
+		&opcda.OPCInvalidHandle:   "The value of the handle is invalid",
+		&opcda.OPCBadType:         "The server cannot convert the data between the requested data type and the canonical data type",
+		&opcda.OPCBadRights:       "The item's access rights do not allow the operation",
+		&opcda.OPCUnknownItemID:   "The item ID is not defined in the server address space",
+		&opcda.OPCInvalidItemID:   "The item ID does not conform to the server's syntax",
+		&opcda.OPCInvalidFilter:   "The filter string was not valid",
+		&opcda.OPCUnknownPath:     "The item's access path is not known to the server",
+		&opcda.OPCRange:           "The value was out of range",
+		&opcda.OPCDuplicateName:   "Duplicate name not allowed",
+		&opcda.OPCUnsupportedRate: "The server does not support the requested data rate but will use the closest available rate",
+		&opcda.OPCNotFound:        "Requested object was not found",
+		&opcda.OPCInvalidPID:      "The passed property ID is not valid for the item",
+
+		&opcda.OPCDeadbandNotSet:           "The item deadband has not been set for this item",
+		&opcda.OPCDeadbandNotSupported:     "The item does not support deadband",
+		&opcda.OPCRateNotSet:               "There is no sampling rate set for the specified item",
+		&opcda.OPCInvalidContinuationPoint: "The continuation point is not valid",
+	} {
+		errorStrings[*code] = message
+	}
+}
+
+// newError returns the error of a failed call
+func newError(code uint32) error {
+	return &opcda.OPCError{ErrorCode: int32(code), ErrorMessage: errorStrings[code]}
+}
@@ -0,0 +1,490 @@
+package opcsim
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func connect(t *testing.T, opts ...Option) (*Server, *opcda.OPCServer) {
+	sim, err := NewServer(opts...)
+	require.NoError(t, err)
+	server, err := opcda.Connect(DefaultProgID, "", opcda.WithTransport(sim))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, server.Disconnect())
+		assert.NoError(t, sim.Close())
+	})
+	return sim, server
+}
+
+func assertOPCError(t *testing.T, code uint32, err error) {
+	t.Helper()
+	var opcErr *opcda.OPCError
+	if assert.True(t, errors.As(err, &opcErr), "%v", err) {
+		assert.Equal(t, int32(code), opcErr.ErrorCode)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	sim, err := NewServer()
+	require.NoError(t, err)
+	defer sim.Close()
+	_, err = opcda.Connect("Unknown.ProgID", "", opcda.WithTransport(sim))
+	assertOPCError(t, EClassNotReg, err)
+	server, err := opcda.Connect(sim.CLSID(), "", opcda.WithTransport(sim))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sim.Connections())
+	assert.NoError(t, server.Disconnect())
+	assert.Equal(t, 0, sim.Connections())
+}
+
+func TestNewServerInvalidTag(t *testing.T) {
+	_, err := NewServer(WithTags(Tag{ItemID: "Empty"}))
+	assert.EqualError(t, err, "opcsim: item Empty has no initial value")
+	_, err = NewServer(WithTags(Tag{ItemID: "Twice", Value: int32(1)}, Tag{ItemID: "Twice", Value: int32(2)}))
+	assert.EqualError(t, err, "opcsim: duplicate item Twice")
+}
+
+func TestServerStatus(t *testing.T) {
+	sim, server := connect(t, WithVendorInfo("test vendor"))
+	vendor, err := server.GetVendorInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "test vendor", vendor)
+	state, err := server.GetServerState()
+	assert.NoError(t, err)
+	assert.Equal(t, opcda.OPC_STATUS_RUNNING, state)
+	sim.SetState(opcda.OPC_STATUS_SUSPENDED)
+	state, err = server.GetServerState()
+	assert.NoError(t, err)
+	assert.Equal(t, opcda.OPC_STATUS_SUSPENDED, state)
+	start, err := server.GetStartTime()
+	assert.NoError(t, err)
+	current, err := server.GetCurrentTime()
+	assert.NoError(t, err)
+	assert.False(t, current.Before(start))
+
+	locales, err := server.QueryAvailableLocaleIDs()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{0x0409, 0x0804}, locales)
+	assert.NoError(t, server.SetLocaleID(0x0804))
+	locale, err := server.GetLocaleID()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0x0804), locale)
+	assertOPCError(t, EInvalidArg, server.SetLocaleID(0x0407))
+	assert.NoError(t, server.SetClientName("test"))
+
+	message, err := server.GetErrorString(int32(opcda.OPCInvalidHandle))
+	assert.NoError(t, err)
+	assert.Equal(t, "The value of the handle is invalid", message)
+}
+
+func TestGroups(t *testing.T) {
+	_, server := connect(t)
+	groups := server.GetOPCGroups()
+	group, err := groups.Add("group")
+	require.NoError(t, err)
+	_, err = groups.Add("group")
+	assertOPCError(t, opcda.OPCDuplicateName, err)
+	unnamed, err := groups.Add("")
+	require.NoError(t, err)
+	_, err = groups.Add("")
+	require.NoError(t, err)
+	other, err := groups.Add("other")
+	require.NoError(t, err)
+
+	assert.NoError(t, group.SetName("renamed"))
+	assert.Equal(t, "renamed", group.GetName())
+	assertOPCError(t, opcda.OPCDuplicateName, group.SetName(other.GetName()))
+	assert.NoError(t, group.SetIsActive(false))
+	assert.False(t, group.GetIsActive())
+	assert.NoError(t, group.SetUpdateRate(1))
+	rate, err := group.GetUpdateRate()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(minUpdateRate), rate)
+	assert.NoError(t, group.SetDeadband(10))
+	deadband, err := group.GetDeadband()
+	assert.NoError(t, err)
+	assert.Equal(t, float32(10), deadband)
+	assertOPCError(t, EInvalidArg, group.SetDeadband(101))
+	assert.NoError(t, group.SetTimeBias(60))
+	bias, err := group.GetTimeBias()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(60), bias)
+	assert.NoError(t, group.SetClientHandle(42))
+	assert.Equal(t, uint32(42), group.GetClientHandle())
+
+	assert.NoError(t, groups.Remove(unnamed.GetServerHandle()))
+	assert.Equal(t, 3, groups.GetCount())
+}
+
+func TestItems(t *testing.T) {
+	_, server := connect(t)
+	group, err := server.GetOPCGroups().Add("items")
+	require.NoError(t, err)
+	items := group.OPCItems()
+	added, errs, err := items.AddItems([]string{"Random.Int4", "Unknown.Item", ""})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assertOPCError(t, opcda.OPCUnknownItemID, errs[1])
+	assertOPCError(t, opcda.OPCInvalidItemID, errs[2])
+	assert.Equal(t, com.VT_I4, added[0].GetCanonicalDataType())
+	assert.Equal(t, opcda.OPC_READABLE, added[0].GetAccessRights())
+
+	paths := []string{"path"}
+	errs, err = items.Validate([]string{"Random.Int4"}, nil, &paths)
+	require.NoError(t, err)
+	assertOPCError(t, opcda.OPCUnknownPath, errs[0])
+	types := []com.VT{com.VT_DATE}
+	errs, err = items.Validate([]string{"Random.Int4"}, &types, nil)
+	require.NoError(t, err)
+	assertOPCError(t, opcda.OPCBadType, errs[0])
+
+	errs = items.SetActive([]uint32{added[0].GetServerHandle()}, false)
+	assert.NoError(t, errs[0])
+	assert.False(t, added[0].GetIsActive())
+	assert.NoError(t, added[0].SetRequestedDataType(com.VT_R8))
+	assertOPCError(t, opcda.OPCBadType, added[0].SetRequestedDataType(com.VT_DATE))
+	assert.NoError(t, added[0].SetClientHandle(7))
+	items.Remove([]uint32{added[0].GetServerHandle()})
+	assert.Equal(t, 0, items.GetCount())
+}
+
+func TestSyncIO(t *testing.T) {
+	sim, server := connect(t)
+	group, err := server.GetOPCGroups().Add("sync")
+	require.NoError(t, err)
+	items := group.OPCItems()
+	added, errs, err := items.AddItems([]string{"Bucket Brigade.Int2", "Bucket Brigade.ArrayOfString", "Write Only.Int4", "Random.Real8"})
+	require.NoError(t, err)
+	for _, e := range errs {
+		require.NoError(t, e)
+	}
+	handles := []uint32{added[0].GetServerHandle(), added[1].GetServerHandle(), added[2].GetServerHandle(), added[3].GetServerHandle()}
+
+	errs, err = group.SyncWrite(handles[:3], []interface{}{int32(12), []string{"a", "b"}, int32(1)})
+	require.NoError(t, err)
+	for _, e := range errs {
+		assert.NoError(t, e)
+	}
+	states, errs, err := group.SyncRead(opcda.OPC_DS_DEVICE, handles)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.Equal(t, int16(12), states[0].Value)
+	assert.Equal(t, QualityGood, states[0].Quality)
+	assert.Equal(t, []string{"a", "b"}, states[1].Value)
+	assertOPCError(t, opcda.OPCBadRights, errs[2])
+	assert.NoError(t, errs[3])
+	assert.IsType(t, float64(0), states[3].Value)
+
+	errs, err = group.SyncWrite(handles[:1], []interface{}{int32(40000)})
+	require.NoError(t, err)
+	assertOPCError(t, opcda.OPCRange, errs[0])
+	errs, err = group.SyncWrite(handles[3:], []interface{}{1.0})
+	require.NoError(t, err)
+	assertOPCError(t, opcda.OPCBadRights, errs[0])
+
+	assert.NoError(t, sim.SetQuality("Bucket Brigade.Int2", QualityBad))
+	value, quality, _, err := added[0].Read(opcda.OPC_DS_CACHE)
+	assert.NoError(t, err)
+	assert.Equal(t, int16(12), value)
+	assert.Equal(t, QualityBad, quality)
+
+	assert.NoError(t, added[0].SetIsActive(false))
+	_, quality, _, err = added[0].Read(opcda.OPC_DS_CACHE)
+	assert.NoError(t, err)
+	assert.Equal(t, QualityOutOfService, quality)
+
+	assert.NoError(t, added[0].SetRequestedDataType(com.VT_BSTR))
+	value, _, _, err = added[0].Read(opcda.OPC_DS_DEVICE)
+	assert.NoError(t, err)
+	assert.Equal(t, "12", value)
+}
+
+func receive[T any](t *testing.T, ch chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	var zero T
+	return zero
+}
+
+func TestDataChange(t *testing.T) {
+	sim, server := connect(t)
+	groups := server.GetOPCGroups()
+	groups.SetDefaultGroupUpdateRate(20)
+	group, err := groups.Add("data change")
+	require.NoError(t, err)
+	ch := make(chan *opcda.DataChangeCallBackData, 10)
+	require.NoError(t, group.RegisterDataChange(ch))
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Real8")
+	require.NoError(t, err)
+
+	data := receive(t, ch)
+	assert.Equal(t, group.GetClientHandle(), data.GroupHandle)
+	assert.Equal(t, []uint32{item.GetClientHandle()}, data.ItemClientHandles)
+	assert.Equal(t, []interface{}{float64(0)}, data.Values)
+
+	require.NoError(t, sim.SetValue("Bucket Brigade.Real8", 5.0))
+	data = receive(t, ch)
+	assert.Equal(t, []interface{}{float64(5)}, data.Values)
+	assert.Equal(t, []com.Quality{QualityGood}, data.Qualities)
+
+	require.NoError(t, sim.SetQuality("Bucket Brigade.Real8", QualityBad))
+	data = receive(t, ch)
+	assert.Equal(t, []com.Quality{QualityBad}, data.Qualities)
+
+	// an inactive group does not send data changes
+	require.NoError(t, group.SetIsActive(false))
+	require.NoError(t, sim.SetValue("Bucket Brigade.Real8", 6.0))
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, ch)
+	require.NoError(t, group.SetIsActive(true))
+	data = receive(t, ch)
+	assert.Equal(t, []interface{}{float64(6)}, data.Values)
+}
+
+func TestDeadband(t *testing.T) {
+	sim, server := connect(t, WithTags(Tag{ItemID: "Analog", Value: float64(0), EULow: 0, EUHigh: 100}))
+	groups := server.GetOPCGroups()
+	groups.SetDefaultGroupUpdateRate(20)
+	groups.SetDefaultGroupDeadband(10)
+	group, err := groups.Add("deadband")
+	require.NoError(t, err)
+	ch := make(chan *opcda.DataChangeCallBackData, 10)
+	require.NoError(t, group.RegisterDataChange(ch))
+	_, err = group.OPCItems().AddItem("Analog")
+	require.NoError(t, err)
+	receive(t, ch)
+
+	require.NoError(t, sim.SetValue("Analog", 5.0))
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, ch)
+	require.NoError(t, sim.SetValue("Analog", 10.5))
+	data := receive(t, ch)
+	assert.Equal(t, []interface{}{10.5}, data.Values)
+}
+
+func TestGeneratedValues(t *testing.T) {
+	_, server := connect(t)
+	groups := server.GetOPCGroups()
+	groups.SetDefaultGroupUpdateRate(20)
+	group, err := groups.Add("generators")
+	require.NoError(t, err)
+	ch := make(chan *opcda.DataChangeCallBackData, 10)
+	require.NoError(t, group.RegisterDataChange(ch))
+	_, err = group.OPCItems().AddItem("Saw-toothed Waves.Real8")
+	require.NoError(t, err)
+	first := receive(t, ch)
+	second := receive(t, ch)
+	assert.NotEqual(t, first.Values, second.Values)
+	assert.True(t, second.TimeStamps[0].After(first.TimeStamps[0]))
+}
+
+func TestAsyncIO(t *testing.T) {
+	sim, server := connect(t)
+	group, err := server.GetOPCGroups().Add("async")
+	require.NoError(t, err)
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Int4")
+	require.NoError(t, err)
+	handles := []uint32{item.GetServerHandle()}
+
+	_, _, err = group.AsyncRead(handles, 1)
+	assertOPCError(t, ENoConnection, err)
+
+	readCh := make(chan *opcda.ReadCompleteCallBackData, 1)
+	writeCh := make(chan *opcda.WriteCompleteCallBackData, 1)
+	refreshCh := make(chan *opcda.DataChangeCallBackData, 10)
+	require.NoError(t, group.RegisterReadComplete(readCh))
+	require.NoError(t, group.RegisterWriteComplete(writeCh))
+
+	_, errs, err := group.AsyncWrite(handles, []interface{}{int32(14)}, 100)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	written := receive(t, writeCh)
+	assert.Equal(t, uint32(100), written.TransID)
+	assert.Equal(t, []uint32{item.GetClientHandle()}, written.ItemClientHandles)
+	assert.NoError(t, written.Errors[0])
+	value, _, _, err := sim.Value("Bucket Brigade.Int4")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(14), value)
+
+	_, errs, err = group.AsyncRead(append(handles, 1000), 101)
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assertOPCError(t, opcda.OPCInvalidHandle, errs[1])
+	read := receive(t, readCh)
+	assert.Equal(t, uint32(101), read.TransID)
+	assert.Equal(t, []interface{}{int32(14)}, read.Values)
+
+	require.NoError(t, group.RegisterDataChange(refreshCh))
+	_, err = group.AsyncRefresh(opcda.OPC_DS_CACHE, 102)
+	require.NoError(t, err)
+	for {
+		data := receive(t, refreshCh)
+		if data.TransID == 102 {
+			assert.Equal(t, []interface{}{int32(14)}, data.Values)
+			break
+		}
+	}
+	require.NoError(t, item.SetIsActive(false))
+	_, err = group.AsyncRefresh(opcda.OPC_DS_CACHE, 103)
+	assertOPCError(t, EFail, err)
+}
+
+func TestAsyncCancel(t *testing.T) {
+	_, server := connect(t, WithLatency(time.Second))
+	group, err := server.GetOPCGroups().Add("cancel")
+	require.NoError(t, err)
+	item, err := group.OPCItems().AddItem("Bucket Brigade.Int4")
+	require.NoError(t, err)
+	cancelCh := make(chan *opcda.CancelCompleteCallBackData, 1)
+	writeCh := make(chan *opcda.WriteCompleteCallBackData, 1)
+	require.NoError(t, group.RegisterCancelComplete(cancelCh))
+	require.NoError(t, group.RegisterWriteComplete(writeCh))
+	cancelID, _, err := group.AsyncWrite([]uint32{item.GetServerHandle()}, []interface{}{int32(1)}, 100)
+	require.NoError(t, err)
+	require.NoError(t, group.AsyncCancel(cancelID))
+	data := receive(t, cancelCh)
+	assert.Equal(t, uint32(100), data.TransID)
+	assert.Equal(t, group.GetClientHandle(), data.GroupHandle)
+	assertOPCError(t, EFail, group.AsyncCancel(cancelID))
+	time.Sleep(1200 * time.Millisecond)
+	assert.Empty(t, writeCh)
+}
+
+func TestItemProperties(t *testing.T) {
+	_, server := connect(t, WithTags(
+		Tag{ItemID: "Analog", Value: float32(1), EULow: -10, EUHigh: 10, Description: "analog"},
+		Tag{ItemID: "Digital", Value: false},
+	))
+	ids, descriptions, types, err := server.QueryAvailableProperties("Analog")
+	require.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2, 3, 4, 5, 6, 7, 8, 101}, ids)
+	assert.Equal(t, "Item Value", descriptions[1])
+	assert.Equal(t, uint16(com.VT_R4), types[1])
+	_, _, _, err = server.QueryAvailableProperties("Unknown")
+	assertOPCError(t, opcda.OPCUnknownItemID, err)
+
+	data, errs, err := server.GetItemProperties("Analog", []uint32{1, 2, 5, 8, 101, 9999})
+	require.NoError(t, err)
+	assert.Equal(t, int16(com.VT_R4), data[0])
+	assert.Equal(t, float32(1), data[1])
+	assert.Equal(t, int32(opcda.OPC_READABLE|opcda.OPC_WRITEABLE), data[2])
+	assert.Equal(t, []float64{-10, 10}, data[3])
+	assert.Equal(t, "analog", data[4])
+	assertOPCError(t, opcda.OPCInvalidPID, errs[5])
+
+	_, errs, err = server.LookupItemIDs("Analog", []uint32{2, 8})
+	require.NoError(t, err)
+	assertOPCError(t, opcda.OPCInvalidPID, errs[0])
+	assertOPCError(t, opcda.OPCNotFound, errs[1])
+
+	group, err := server.GetOPCGroups().Add("properties")
+	require.NoError(t, err)
+	items, _, err := group.OPCItems().AddItems([]string{"Analog", "Digital"})
+	require.NoError(t, err)
+	info, err := items[0].GetEUInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{-10, 10}, info)
+	info, err = items[1].GetEUInfo()
+	assert.NoError(t, err)
+	assert.Nil(t, info)
+}
+
+func TestBrowse(t *testing.T) {
+	_, server := connect(t, WithTags(
+		Tag{ItemID: "Plant.Line1.Speed", Value: float64(0)},
+		Tag{ItemID: "Plant.Line1.Count", Value: int32(0), AccessRights: opcda.OPC_READABLE},
+		Tag{ItemID: "Plant.Line2.Speed", Value: float64(0)},
+		Tag{ItemID: "Plant.State", Value: ""},
+		Tag{ItemID: "Alarm", Value: false},
+	))
+	browser, err := server.CreateBrowser()
+	require.NoError(t, err)
+	defer browser.Release()
+	organization, err := browser.GetOrganization()
+	assert.NoError(t, err)
+	assert.Equal(t, opcda.OPC_NS_HIERARCHIAL, organization)
+
+	names := func() []string {
+		var result []string
+		for i := 0; i < browser.GetCount(); i++ {
+			name, err := browser.Item(i)
+			assert.NoError(t, err)
+			result = append(result, name)
+		}
+		return result
+	}
+	require.NoError(t, browser.ShowBranches())
+	assert.Equal(t, []string{"Plant"}, names())
+	require.NoError(t, browser.ShowLeafs(false))
+	assert.Equal(t, []string{"Alarm"}, names())
+	require.NoError(t, browser.ShowLeafs(true))
+	assert.Equal(t, []string{"Alarm", "Plant.Line1.Count", "Plant.Line1.Speed", "Plant.Line2.Speed", "Plant.State"}, names())
+
+	require.NoError(t, browser.MoveTo([]string{"Plant", "Line1"}))
+	position, err := browser.GetCurrentPosition()
+	assert.NoError(t, err)
+	assert.Equal(t, "Plant.Line1", position)
+	id, err := browser.GetItemID("Speed")
+	assert.NoError(t, err)
+	assert.Equal(t, "Plant.Line1.Speed", id)
+	_, err = browser.GetItemID("Unknown")
+	assert.Error(t, err)
+	assert.Error(t, browser.MoveDown("Unknown"))
+
+	browser.SetFilter("S*")
+	require.NoError(t, browser.ShowLeafs(false))
+	assert.Equal(t, []string{"Speed"}, names())
+	browser.SetFilter("")
+	browser.SetDataType(uint16(com.VT_I4))
+	require.NoError(t, browser.ShowLeafs(false))
+	assert.Equal(t, []string{"Count"}, names())
+	browser.SetDataType(uint16(com.VT_EMPTY))
+	require.NoError(t, browser.SetAccessRights(opcda.OPC_WRITEABLE))
+	require.NoError(t, browser.ShowLeafs(false))
+	assert.Equal(t, []string{"Speed"}, names())
+
+	require.NoError(t, browser.MoveUp())
+	browser.SetFilter("Line#")
+	require.NoError(t, browser.ShowBranches())
+	assert.Equal(t, []string{"Line1", "Line2"}, names())
+	browser.MoveToRoot()
+	position, err = browser.GetCurrentPosition()
+	assert.NoError(t, err)
+	assert.Equal(t, "", position)
+}
+
+func TestShutdown(t *testing.T) {
+	sim, server := connect(t)
+	ch := make(chan string, 1)
+	require.NoError(t, server.RegisterServerShutDown(ch))
+	sim.Shutdown("maintenance")
+	assert.Equal(t, "maintenance", receive(t, ch))
+}
+
+func TestClose(t *testing.T) {
+	sim, err := NewServer()
+	require.NoError(t, err)
+	server, err := opcda.Connect(DefaultProgID, "", opcda.WithTransport(sim))
+	require.NoError(t, err)
+	group, err := server.GetOPCGroups().Add("closed")
+	require.NoError(t, err)
+	assert.NoError(t, sim.Close())
+	_, err = server.GetServerState()
+	assertOPCError(t, EDisconnected, err)
+	assertOPCError(t, EDisconnected, group.SetIsActive(false))
+	_, err = opcda.Connect(DefaultProgID, "", opcda.WithTransport(sim))
+	assertOPCError(t, EDisconnected, err)
+	assert.NoError(t, server.Disconnect())
+}
@@ -0,0 +1,120 @@
+package opcsim
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/huskar-t/opcda"
+)
+
+// Tag describes a simulated item
+type Tag struct {
+	// ItemID is the fully qualified item ID, branches of the browse tree are separated by dots
+	ItemID string
+	// Value is the initial value, its type defines the canonical data type of the item
+	Value interface{}
+	// Generator computes the value of the item while the server runs, the item keeps Value when nil
+	Generator Generator
+	// AccessRights is a combination of opcda.OPC_READABLE and opcda.OPC_WRITEABLE.
+	// Readable items with a generator and readable and writeable items without are used when zero.
+	AccessRights uint32
+	// EULow and EUHigh are the engineering unit range of analog items, the item has no range when EUHigh <= EULow.
+	// The percent deadband of the groups applies to items with a range.
+	EULow  float64
+	EUHigh float64
+	// Description is exposed as the item description property when not empty
+	Description string
+}
+
+func (t *Tag) accessRights() uint32 {
+	if t.AccessRights != 0 {
+		return t.AccessRights
+	}
+	if t.Generator != nil {
+		return opcda.OPC_READABLE
+	}
+	return opcda.OPC_READABLE | opcda.OPC_WRITEABLE
+}
+
+func (t *Tag) analog() bool {
+	return t.EUHigh > t.EULow
+}
+
+// Generator computes the value of a tag from the time elapsed since the server started.
+// The result is converted to the canonical data type of the tag.
+type Generator func(elapsed time.Duration) interface{}
+
+// Random returns a generator of uniformly distributed values in [min, max)
+func Random(min, max float64) Generator {
+	return func(time.Duration) interface{} {
+		return min + rand.Float64()*(max-min)
+	}
+}
+
+// Ramp returns a generator rising linearly from min to max over every period
+func Ramp(min, max float64, period time.Duration) Generator {
+	return func(elapsed time.Duration) interface{} {
+		phase := float64(elapsed%period) / float64(period)
+		return min + phase*(max-min)
+	}
+}
+
+// Sine returns a generator oscillating between min and max with period, starting at the middle of the range
+func Sine(min, max float64, period time.Duration) Generator {
+	return func(elapsed time.Duration) interface{} {
+		phase := float64(elapsed%period) / float64(period)
+		return min + (max-min)*(1+math.Sin(2*math.Pi*phase))/2
+	}
+}
+
+// DefaultTags returns a tag table modelled after the Matrikon OPC simulation server.
+// Random, Saw-toothed Waves and Sine Waves items are generated and read-only, Bucket Brigade items keep the last written value.
+func DefaultTags() []Tag {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tags []Tag
+	numeric := []struct {
+		name  string
+		value interface{}
+		low   float64
+		high  float64
+	}{
+		{"Int1", int8(0), -128, 127},
+		{"Int2", int16(0), -32768, 32767},
+		{"Int4", int32(0), -2147483648, 2147483647},
+		{"UInt1", uint8(0), 0, 255},
+		{"UInt2", uint16(0), 0, 65535},
+		{"UInt4", uint32(0), 0, 4294967295},
+		{"Real4", float32(0), -1000, 1000},
+		{"Real8", float64(0), -1000, 1000},
+	}
+	for _, n := range numeric {
+		tags = append(tags,
+			Tag{ItemID: "Random." + n.name, Value: n.value, Generator: Random(n.low, n.high), EULow: n.low, EUHigh: n.high},
+			Tag{ItemID: "Saw-toothed Waves." + n.name, Value: n.value, Generator: Ramp(n.low, n.high, time.Minute), EULow: n.low, EUHigh: n.high},
+			Tag{ItemID: "Bucket Brigade." + n.name, Value: n.value},
+		)
+	}
+	tags = append(tags,
+		Tag{ItemID: "Random.Boolean", Value: false, Generator: func(time.Duration) interface{} {
+			return rand.Intn(2) == 1
+		}},
+		Tag{ItemID: "Random.String", Value: "", Generator: func(time.Duration) interface{} {
+			return randomWords[rand.Intn(len(randomWords))]
+		}},
+		Tag{ItemID: "Random.Time", Value: epoch, Generator: func(time.Duration) interface{} {
+			return time.Now()
+		}},
+		Tag{ItemID: "Sine Waves.Real4", Value: float32(0), Generator: Sine(-100, 100, time.Minute), EULow: -100, EUHigh: 100},
+		Tag{ItemID: "Sine Waves.Real8", Value: float64(0), Generator: Sine(-100, 100, time.Minute), EULow: -100, EUHigh: 100},
+		Tag{ItemID: "Bucket Brigade.Boolean", Value: false},
+		Tag{ItemID: "Bucket Brigade.String", Value: ""},
+		Tag{ItemID: "Bucket Brigade.Time", Value: epoch},
+		Tag{ItemID: "Bucket Brigade.ArrayOfReal8", Value: []float64{0, 0, 0}},
+		Tag{ItemID: "Bucket Brigade.ArrayOfString", Value: []string{"", "", ""}},
+		Tag{ItemID: "Write Only.Int4", Value: int32(0), AccessRights: opcda.OPC_WRITEABLE},
+	)
+	return tags
+}
+
+var randomWords = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel"}
@@ -0,0 +1,285 @@
+package opcda
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/huskar-t/opcda/com"
+	"golang.org/x/sys/windows/registry"
+
+	"golang.org/x/sys/windows"
+)
+
+func getClsID(progID, node string, location com.CLSCTX, auth *com.COAUTHINFO) (clsid *windows.GUID, err error) {
+	if location == com.CLSCTX_LOCAL_SERVER {
+		id, err := windows.GUIDFromString(progID)
+		if err != nil {
+			return nil, NewOPCWrapperError("windows.GUIDFromString", err)
+		}
+		return &id, nil
+	} else {
+		var errorList []error
+		// try get clsid from server list
+		clsid, err = getClsIDFromServerListV2(progID, node, location, auth)
+		if err == nil {
+			return clsid, nil
+		}
+		errorList = append(errorList, fmt.Errorf("get clsid from server list v2 error: %v", err))
+		// try v1
+		clsid, err = getClsIDFromServerListV1(progID, node, location, auth)
+		if err == nil {
+			return clsid, nil
+		}
+		errorList = append(errorList, fmt.Errorf("get clsid from server list v1 error: %v", err))
+		// try get clsid from windows reg
+		clsid, err = getClsIDFromReg(progID, node)
+		if err == nil {
+			return clsid, nil
+		}
+		errorList = append(errorList, fmt.Errorf("get clsid from reg error: %v", err))
+		return nil, errors.Join(errorList...)
+	}
+}
+
+func getClsIDFromServerListV2(progID, node string, location com.CLSCTX, auth *com.COAUTHINFO) (*windows.GUID, error) {
+	iCatInfo, err := com.MakeCOMObjectWithAuth(node, location, &com.CLSID_OpcServerList, &com.IID_IOPCServerList2, auth)
+	if err != nil {
+		return nil, err
+	}
+	defer iCatInfo.Release()
+	if err = setBlanket(iCatInfo, auth); err != nil {
+		return nil, err
+	}
+	sl := &com.IOPCServerList2{IUnknown: iCatInfo}
+	clsid, err := sl.CLSIDFromProgID(progID)
+	if err != nil {
+		return nil, err
+	}
+	return clsid, nil
+}
+
+func getClsIDFromServerListV1(progID, node string, location com.CLSCTX, auth *com.COAUTHINFO) (*windows.GUID, error) {
+	iCatInfo, err := com.MakeCOMObjectWithAuth(node, location, &com.CLSID_OpcServerList, &com.IID_IOPCServerList, auth)
+	if err != nil {
+		return nil, err
+	}
+	defer iCatInfo.Release()
+	if err = setBlanket(iCatInfo, auth); err != nil {
+		return nil, err
+	}
+	sl := &com.IOPCServerList{IUnknown: iCatInfo}
+	clsid, err := sl.CLSIDFromProgID(progID)
+	if err != nil {
+		return nil, err
+	}
+	return clsid, nil
+}
+
+func getClsIDFromReg(progID, node string) (*windows.GUID, error) {
+	var clsid *windows.GUID
+	var err error
+	hKey, err := registry.OpenRemoteKey(node, registry.CLASSES_ROOT)
+	if err != nil {
+		return nil, err
+	}
+	defer hKey.Close()
+
+	hProgIDKey, err := registry.OpenKey(hKey, progID, registry.READ)
+	if err != nil {
+		return nil, err
+	}
+	defer hProgIDKey.Close()
+	_, clsid, err = getClsidFromProgIDKey(hProgIDKey)
+	return clsid, err
+}
+
+func getClsidFromProgIDKey(hProgIDKey registry.Key) (string, *windows.GUID, error) {
+	hClsidKey, err := registry.OpenKey(hProgIDKey, "CLSID", registry.READ)
+	if err != nil {
+		return "", nil, err
+	}
+	defer hClsidKey.Close()
+	clsidStr, _, err := hClsidKey.GetStringValue("")
+	if err != nil {
+		return "", nil, err
+	}
+	clsid, err := windows.GUIDFromString(clsidStr)
+	return clsidStr, &clsid, err
+}
+
+type ServerInfo struct {
+	ProgID       string
+	ClsStr       string
+	VerIndProgID string
+	ClsID        *windows.GUID
+}
+
+// GetOPCServers get OPC servers from node
+func GetOPCServers(node string) ([]*ServerInfo, error) {
+	var errorList []error
+	result, err := getServersFromOpcServerListV2(node)
+	if err == nil {
+		return result, nil
+	}
+	errorList = append(errorList, fmt.Errorf("get servers from opc server list v2 error: %v", err))
+	// try v1
+	result, err = getServersFromOpcServerListV1(node)
+	if err == nil {
+		return result, nil
+	}
+	errorList = append(errorList, fmt.Errorf("get servers from opc server list v1 error: %v", err))
+	// try windows reg
+	result, err = getServersFromReg(node)
+	if err == nil {
+		return result, nil
+	}
+	errorList = append(errorList, fmt.Errorf("get servers from reg error: %v", err))
+	return nil, errors.Join(errorList...)
+}
+
+func getServersFromOpcServerListV2(node string) ([]*ServerInfo, error) {
+	location := com.CLSCTX_LOCAL_SERVER
+	if !com.IsLocal(node) {
+		location = com.CLSCTX_REMOTE_SERVER
+	}
+	iCatInfo, err := com.MakeCOMObjectEx(node, location, &com.CLSID_OpcServerList, &com.IID_IOPCServerList2)
+	if err != nil {
+		return nil, NewOPCWrapperError("make com object IOPCServerListV2", err)
+	}
+	cids := []windows.GUID{IID_CATID_OPCDAServer10, IID_CATID_OPCDAServer20}
+	defer iCatInfo.Release()
+	sl := &com.IOPCServerList2{IUnknown: iCatInfo}
+	iEnum, err := sl.EnumClassesOfCategories(cids, nil)
+	if err != nil {
+		return nil, NewOPCWrapperError("enum classes of categories with IOPCServerListV2", err)
+	}
+	defer iEnum.Release()
+	var result []*ServerInfo
+	for {
+		var classID windows.GUID
+		var actual uint32
+		err = iEnum.Next(1, &classID, &actual)
+		if err != nil {
+			break
+		}
+		server, err := getServer(sl, &classID)
+		if err != nil {
+			return nil, NewOPCWrapperError("IOPCServerListV2 getServer", err)
+		}
+		result = append(result, server)
+	}
+	return result, nil
+}
+
+func getServersFromOpcServerListV1(node string) ([]*ServerInfo, error) {
+	location := com.CLSCTX_LOCAL_SERVER
+	if !com.IsLocal(node) {
+		location = com.CLSCTX_REMOTE_SERVER
+	}
+	iCatInfo, err := com.MakeCOMObjectEx(node, location, &com.CLSID_OpcServerList, &com.IID_IOPCServerList)
+	if err != nil {
+		return nil, NewOPCWrapperError("make com object IOPCServerListV1", err)
+	}
+	cids := []windows.GUID{IID_CATID_OPCDAServer10, IID_CATID_OPCDAServer20}
+	defer iCatInfo.Release()
+	sl := &com.IOPCServerList{IUnknown: iCatInfo}
+	iEnum, err := sl.EnumClassesOfCategories(cids, nil)
+	if err != nil {
+		return nil, NewOPCWrapperError("enum classes of categories with IOPCServerListV1", err)
+	}
+	defer iEnum.Release()
+	var result []*ServerInfo
+	for {
+		var classID windows.GUID
+		var actual uint32
+		err = iEnum.Next(1, &classID, &actual)
+		if err != nil {
+			break
+		}
+		server, err := getServerV1(sl, &classID)
+		if err != nil {
+			return nil, NewOPCWrapperError("IOPCServerListV1 getServer", err)
+		}
+		result = append(result, server)
+	}
+	return result, nil
+}
+
+func getServersFromReg(node string) ([]*ServerInfo, error) {
+	var result []*ServerInfo
+	var err error
+	hKey, err := registry.OpenRemoteKey(node, registry.CLASSES_ROOT)
+	if err != nil {
+		return nil, err
+	}
+	defer hKey.Close()
+	tsKeys, _ := hKey.ReadSubKeyNames(-1)
+	for _, tsKey := range tsKeys {
+		info := getServersFromKey(hKey, tsKey)
+		if info != nil {
+			result = append(result, info)
+		}
+	}
+	return result, nil
+}
+
+func getServersFromKey(hKey registry.Key, progID string) *ServerInfo {
+	hProgIDKey, err := registry.OpenKey(hKey, progID, registry.READ)
+	if err != nil {
+		return nil
+	}
+	defer hProgIDKey.Close()
+	hOPCKey, err := registry.OpenKey(hProgIDKey, "OPC", registry.READ)
+	if err != nil {
+		return nil
+	}
+	defer hOPCKey.Close()
+	clsidStr, clsid, err := getClsidFromProgIDKey(hProgIDKey)
+	if err != nil {
+		return nil
+	}
+	return &ServerInfo{
+		ProgID:       progID,
+		ClsStr:       clsidStr,
+		VerIndProgID: progID,
+		ClsID:        clsid,
+	}
+}
+
+func getServer(sl *com.IOPCServerList2, classID *windows.GUID) (*ServerInfo, error) {
+	progID, userType, VerIndProgID, err := sl.GetClassDetails(classID)
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to get prog ID from class ID: %w", err)
+	}
+	defer func() {
+		com.CoTaskMemFree(unsafe.Pointer(progID))
+		com.CoTaskMemFree(unsafe.Pointer(userType))
+		com.CoTaskMemFree(unsafe.Pointer(VerIndProgID))
+	}()
+	clsStr := classID.String()
+	return &ServerInfo{
+		ProgID:       windows.UTF16PtrToString(progID),
+		ClsStr:       clsStr,
+		ClsID:        classID,
+		VerIndProgID: windows.UTF16PtrToString(VerIndProgID),
+	}, nil
+}
+
+func getServerV1(sl *com.IOPCServerList, classID *windows.GUID) (*ServerInfo, error) {
+	progID, userType, err := sl.GetClassDetails(classID)
+	if err != nil {
+		return nil, fmt.Errorf("FAILED to get prog ID from class ID: %w", err)
+	}
+	defer func() {
+		com.CoTaskMemFree(unsafe.Pointer(progID))
+		com.CoTaskMemFree(unsafe.Pointer(userType))
+	}()
+	clsStr := classID.String()
+	return &ServerInfo{
+		ProgID:       windows.UTF16PtrToString(progID),
+		ClsStr:       clsStr,
+		ClsID:        classID,
+		VerIndProgID: "",
+	}, nil
+}
@@ -1,3 +1,6 @@
+//go:build windows
+// +build windows
+
 package opcda
 
 import (
@@ -10,10 +13,11 @@ import (
 )
 
 type ShutdownEventReceiver struct {
-	lpVtbl   *ShutdownEventReceiverVtbl
-	ref      int32
-	clsid    *windows.GUID
-	receiver []chan string
+	lpVtbl     *ShutdownEventReceiverVtbl
+	ref        int32
+	clsid      *windows.GUID
+	receiver   []chan string
+	onShutdown func(reason string)
 }
 
 type ShutdownEventReceiverVtbl struct {
@@ -24,6 +28,10 @@ type ShutdownEventReceiverVtbl struct {
 }
 
 func NewShutdownEventReceiver() *ShutdownEventReceiver {
+	return newShutdownEventReceiver(nil)
+}
+
+func newShutdownEventReceiver(onShutdown func(reason string)) *ShutdownEventReceiver {
 	return &ShutdownEventReceiver{
 		lpVtbl: &ShutdownEventReceiverVtbl{
 			pQueryInterface:  syscall.NewCallback(ShutdownQueryInterface),
@@ -31,8 +39,9 @@ func NewShutdownEventReceiver() *ShutdownEventReceiver {
 			pRelease:         syscall.NewCallback(ShutdownRelease),
 			pShutdownRequest: syscall.NewCallback(ShutdownRequest),
 		},
-		ref:   0,
-		clsid: &IID_IOPCShutdown,
+		ref:        0,
+		clsid:      &IID_IOPCShutdown,
+		onShutdown: onShutdown,
 	}
 }
 
@@ -60,6 +69,9 @@ func ShutdownRequest(this *com.IUnknown, pReason *uint16) uintptr {
 		default:
 		}
 	}
+	if er.onShutdown != nil {
+		er.onShutdown(reason)
+	}
 	return uintptr(com.S_OK)
 }
 
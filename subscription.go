@@ -0,0 +1,96 @@
+package opcda
+
+import (
+	"sync"
+)
+
+// Subscription is a channel subscribed to the events of a group, see SubscribeDataChange
+type Subscription struct {
+	once        sync.Once
+	unsubscribe func()
+}
+
+// Unsubscribe detaches the channel, no event is sent to it once Unsubscribe returns so that it may be closed. The data
+// callback of the group is unadvised when its last subscriber leaves. Calling Unsubscribe again does nothing.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(s.unsubscribe)
+}
+
+// SubscribeDataChange subscribes ch to the data change events until the subscription is unsubscribed, opts decide
+// what happens to an event when ch is full
+func (g *OPCGroup) SubscribeDataChange(ch chan *DataChangeCallBackData, opts ...DeliveryOption) (*Subscription, error) {
+	return subscribe(g, &g.dataChangeList, ch, opts)
+}
+
+// SubscribeReadComplete subscribes ch to the read complete events until the subscription is unsubscribed, opts decide
+// what happens to an event when ch is full
+func (g *OPCGroup) SubscribeReadComplete(ch chan *ReadCompleteCallBackData, opts ...DeliveryOption) (*Subscription, error) {
+	return subscribe(g, &g.readCompleteList, ch, opts)
+}
+
+// SubscribeWriteComplete subscribes ch to the write complete events until the subscription is unsubscribed, opts
+// decide what happens to an event when ch is full
+func (g *OPCGroup) SubscribeWriteComplete(ch chan *WriteCompleteCallBackData, opts ...DeliveryOption) (*Subscription, error) {
+	return subscribe(g, &g.writeCompleteList, ch, opts)
+}
+
+// SubscribeCancelComplete subscribes ch to the cancel complete events until the subscription is unsubscribed, opts
+// decide what happens to an event when ch is full
+func (g *OPCGroup) SubscribeCancelComplete(ch chan *CancelCompleteCallBackData, opts ...DeliveryOption) (*Subscription, error) {
+	return subscribe(g, &g.cancelCompleteList, ch, opts)
+}
+
+// subscribe adds a subscriber for ch to list
+func subscribe[T any](g *OPCGroup, list *[]*subscriber[T], ch chan T, opts []DeliveryOption) (*Subscription, error) {
+	s := newSubscriber(ch, opts)
+	err := g.acquire(func() {
+		g.subscriberLock.Lock()
+		defer g.subscriberLock.Unlock()
+		*list = append(*list, s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Subscription{unsubscribe: func() {
+		// a blocked delivery is woken up before the data callback may be unadvised
+		s.close()
+		g.release(func() {
+			g.subscriberLock.Lock()
+			defer g.subscriberLock.Unlock()
+			for i, e := range *list {
+				if e == s {
+					*list = append((*list)[:i:i], (*list)[i+1:]...)
+					return
+				}
+			}
+		})
+	}}, nil
+}
+
+// acquire advises the data callback for the first subscriber and adds a subscriber with add, it returns
+// ErrGroupReleased once the group is released
+func (g *OPCGroup) acquire(add func()) error {
+	g.callbackLock.Lock()
+	defer g.callbackLock.Unlock()
+	if g.released {
+		return ErrGroupReleased
+	}
+	err := g.advise()
+	if err != nil {
+		return err
+	}
+	add()
+	g.subscribers++
+	return nil
+}
+
+// release removes a subscriber with remove and unadvises the data callback when it was the last one
+func (g *OPCGroup) release(remove func()) {
+	g.callbackLock.Lock()
+	defer g.callbackLock.Unlock()
+	remove()
+	g.subscribers--
+	if g.subscribers == 0 {
+		g.unadvise()
+	}
+}
@@ -0,0 +1,125 @@
+package opcda_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adviseCounter counts the data callbacks the groups of a simulated server advise and unadvise, its intercept is
+// passed to wrapTransport
+type adviseCounter struct {
+	advised   atomic.Int32
+	unadvised atomic.Int32
+}
+
+func (c *adviseCounter) intercept(method string, invoke func() error) error {
+	switch method {
+	case "IOPCGroupStateMgt.AdviseDataCallback":
+		c.advised.Add(1)
+	case "IOPCGroupStateMgt.UnadviseDataCallback":
+		c.unadvised.Add(1)
+	}
+	return invoke()
+}
+
+func TestSubscriptionUnadvise(t *testing.T) {
+	counter := &adviseCounter{}
+	_, server := connectSim(t, withDecorator(wrapTransport(counter.intercept, nil)))
+	group := addGroup(t, server, "subscription")
+	first, err := group.SubscribeDataChange(make(chan *opcda.DataChangeCallBackData, 1))
+	require.NoError(t, err)
+	second, err := group.SubscribeWriteComplete(make(chan *opcda.WriteCompleteCallBackData, 1))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), counter.advised.Load())
+
+	first.Unsubscribe()
+	assert.Equal(t, int32(0), counter.unadvised.Load())
+	second.Unsubscribe()
+	second.Unsubscribe()
+	assert.Equal(t, int32(1), counter.unadvised.Load())
+
+	// the next subscriber advises the data callback again and receives data changes
+	ch := make(chan *opcda.DataChangeCallBackData, 10)
+	third, err := group.SubscribeDataChange(ch)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), counter.advised.Load())
+	_, err = group.OPCItems().AddItem("Random.Int4")
+	require.NoError(t, err)
+	select {
+	case data := <-ch:
+		assert.Len(t, data.ItemClientHandles, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	third.Unsubscribe()
+	assert.Equal(t, int32(2), counter.unadvised.Load())
+}
+
+func TestSubscriptionConcurrent(t *testing.T) {
+	counter := &adviseCounter{}
+	_, server := connectSim(t, withDecorator(wrapTransport(counter.intercept, nil)))
+	group := addGroup(t, server, "concurrent")
+	require.NoError(t, group.SetUpdateRate(10))
+	_, err := group.OPCItems().AddItem("Random.Int4")
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(policy opcda.DeliveryPolicy) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				ch := make(chan *opcda.DataChangeCallBackData, 1)
+				subscription, err := group.SubscribeDataChange(ch, opcda.WithDeliveryPolicy(policy))
+				if !assert.NoError(t, err) {
+					return
+				}
+				time.Sleep(time.Millisecond)
+				subscription.Unsubscribe()
+				// no event is sent to the channel once Unsubscribe returns
+				close(ch)
+			}
+		}(opcda.DeliveryPolicy(i % 4))
+	}
+	wg.Wait()
+	assert.Equal(t, counter.advised.Load(), counter.unadvised.Load())
+}
+
+func TestSubscriptionLateCallback(t *testing.T) {
+	captured := &capturedCallback{}
+	_, server := connectSim(t, withDecorator(wrapTransport(nil, captured.wrap)))
+	group := addGroup(t, server, "late")
+	subscription, err := group.SubscribeDataChange(make(chan *opcda.DataChangeCallBackData))
+	require.NoError(t, err)
+	callback := captured.get()
+	require.NotNil(t, callback)
+	subscription.Unsubscribe()
+
+	// the callbacks of the server after the unadvise are dropped instead of blocking it
+	fired := make(chan struct{})
+	go func() {
+		defer close(fired)
+		for i := uint32(1); i <= 300; i++ {
+			callback.OnDataChange(dataChange(i, 1, float64(i)))
+			callback.OnReadComplete(&opcda.CReadCompleteCallBackData{TransID: i})
+		}
+	}()
+	select {
+	case <-fired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the callbacks blocked after the unadvise")
+	}
+
+	// a released group takes no subscriber
+	require.NoError(t, server.GetOPCGroups().Remove(group.GetServerHandle()))
+	_, err = group.SubscribeDataChange(make(chan *opcda.DataChangeCallBackData))
+	assert.ErrorIs(t, err, opcda.ErrGroupReleased)
+	_, err = group.AsyncRefreshFuture(context.Background(), opcda.OPC_DS_CACHE)
+	assert.ErrorIs(t, err, opcda.ErrGroupReleased)
+}
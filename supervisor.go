@@ -0,0 +1,933 @@
+package opcda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+// ErrNotConnected is returned by supervised groups and items while the supervisor reconnects
+var ErrNotConnected = errors.New("opcda: not connected")
+
+// ErrGroupNotFound is returned by Supervisor.RemoveGroup for a group that is not supervised
+var ErrGroupNotFound = errors.New("opcda: group not found")
+
+// SupervisorOption configures NewSupervisor
+type SupervisorOption func(*supervisorOptions)
+
+type supervisorOptions struct {
+	connectOptions []ConnectOption
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	statusInterval time.Duration
+	statusTimeout  time.Duration
+}
+
+// WithConnectOptions sets the options of every Connect made by the supervisor
+func WithConnectOptions(opts ...ConnectOption) SupervisorOption {
+	return func(o *supervisorOptions) {
+		o.connectOptions = opts
+	}
+}
+
+// WithBackoff sets the delay before the first reconnect attempt, it doubles after each failed attempt up to max.
+// The defaults are one second and one minute.
+func WithBackoff(min, max time.Duration) SupervisorOption {
+	return func(o *supervisorOptions) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// WithStatusInterval sets how often GetStatus is polled to detect a dead server, zero disables polling.
+// The default is ten seconds.
+func WithStatusInterval(interval time.Duration) SupervisorOption {
+	return func(o *supervisorOptions) {
+		o.statusInterval = interval
+	}
+}
+
+// WithStatusTimeout sets how long a GetStatus poll may take before the server is considered dead, a hung server is
+// then reconnected like a failing one. Zero waits without limit, the default is five seconds.
+func WithStatusTimeout(timeout time.Duration) SupervisorOption {
+	return func(o *supervisorOptions) {
+		o.statusTimeout = timeout
+	}
+}
+
+// ConnectionEvent reports a change of the connection of a Supervisor
+type ConnectionEvent struct {
+	// Connected is false when the connection was lost and true when it was restored
+	Connected bool
+	// Err is the cause of a lost connection, or the errors of the items that could not be restored
+	Err error
+}
+
+// Supervisor is a managed connection to an OPC server.
+// It remembers the groups, items and callback channels created through it, detects a dead server through the
+// shutdown notification or a failing GetStatus, then reconnects with exponential backoff and re-creates them.
+// The client handles of groups and items are kept, so callback consumers do not notice a reconnect.
+type Supervisor struct {
+	progID         string
+	node           string
+	options        supervisorOptions
+	lock           sync.Mutex
+	server         *OPCServer
+	shutdown       chan string
+	groups         []*SupervisedGroup
+	nextGroup      uint32
+	eventReceivers []chan *ConnectionEvent
+	done           chan struct{}
+	stopped        chan struct{}
+	// version counts the changes of the groups and items, a restore made outside the lock is only kept when they did
+	// not change meanwhile
+	version uint64
+}
+
+// NewSupervisor connects to the server and starts supervising the connection, it fails when the first connection fails
+func NewSupervisor(progID, node string, opts ...SupervisorOption) (*Supervisor, error) {
+	s := &Supervisor{
+		progID: progID,
+		node:   node,
+		options: supervisorOptions{
+			minBackoff:     time.Second,
+			maxBackoff:     time.Minute,
+			statusInterval: 10 * time.Second,
+			statusTimeout:  5 * time.Second,
+		},
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+	server, shutdown, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	s.server = server
+	s.shutdown = shutdown
+	go s.run()
+	return s, nil
+}
+
+// connect connects to the server and subscribes to its shutdown notification
+func (s *Supervisor) connect() (*OPCServer, chan string, error) {
+	server, err := Connect(s.progID, s.node, s.options.connectOptions...)
+	if err != nil {
+		return nil, nil, err
+	}
+	shutdown := make(chan string, 1)
+	// polling still detects failures when the transport does not support callbacks
+	_ = server.RegisterServerShutDown(shutdown)
+	return server, shutdown, nil
+}
+
+// run waits for the connection to fail and restores it
+func (s *Supervisor) run() {
+	defer close(s.stopped)
+	var tick <-chan time.Time
+	if s.options.statusInterval > 0 {
+		ticker := time.NewTicker(s.options.statusInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		s.lock.Lock()
+		server, shutdown := s.server, s.shutdown
+		s.lock.Unlock()
+		var cause error
+		select {
+		case <-s.done:
+			return
+		case reason := <-shutdown:
+			cause = fmt.Errorf("server shut down: %s", reason)
+		case <-tick:
+			err := s.poll(server)
+			if err == nil {
+				continue
+			}
+			cause = err
+		}
+		s.disconnect(cause)
+		if !s.reconnect() {
+			return
+		}
+	}
+}
+
+// poll checks that the server answers GetStatus within the status timeout
+func (s *Supervisor) poll(server *OPCServer) error {
+	ctx := context.Background()
+	if s.options.statusTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.options.statusTimeout)
+		defer cancel()
+	}
+	_, err := server.GetStatusContext(ctx)
+	return err
+}
+
+// disconnect drops the current connection, the server is disconnected in the background since a hung server would
+// block the supervisor
+func (s *Supervisor) disconnect(cause error) {
+	s.lock.Lock()
+	server := s.server
+	s.server = nil
+	s.shutdown = nil
+	for _, g := range s.groups {
+		g.detach()
+	}
+	s.lock.Unlock()
+	if server != nil {
+		go server.Disconnect()
+	}
+	s.fireEvent(&ConnectionEvent{Connected: false, Err: cause})
+}
+
+// reconnect connects with backoff until the tree is restored, it returns false when the supervisor is closed
+func (s *Supervisor) reconnect() bool {
+	delay := s.options.minBackoff
+	for {
+		select {
+		case <-s.done:
+			return false
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > s.options.maxBackoff {
+			delay = s.options.maxBackoff
+		}
+		server, shutdown, err := s.connect()
+		if err != nil {
+			continue
+		}
+		itemErrs, err := s.restore(server, shutdown)
+		if err != nil {
+			go server.Disconnect()
+			continue
+		}
+		s.fireEvent(&ConnectionEvent{Connected: true, Err: itemErrs})
+		return true
+	}
+}
+
+// restoreAttempts is the number of restores made outside the lock before the lock is held for the whole restore
+const restoreAttempts = 3
+
+// restore re-creates the groups on server and makes it the current connection. The groups are built outside the lock
+// from a snapshot, so that the supervisor stays usable during the network calls, and published with the connection when
+// nothing changed meanwhile. After restoreAttempts changed snapshots the lock is held for the whole restore.
+// err fails the whole restore, itemErrs reports the items the server rejected.
+func (s *Supervisor) restore(server *OPCServer, shutdown chan string) (itemErrs error, err error) {
+	for attempt := 0; attempt < restoreAttempts; attempt++ {
+		s.lock.Lock()
+		version := s.version
+		snapshots := make([]*groupSnapshot, len(s.groups))
+		for i, g := range s.groups {
+			snapshots[i] = g.snapshot()
+		}
+		s.lock.Unlock()
+		attachments := make([]*attachment, 0, len(snapshots))
+		var errs []error
+		for _, snapshot := range snapshots {
+			a, err := snapshot.build(server)
+			if err != nil {
+				removeAttachments(server, attachments)
+				return nil, NewOPCWrapperError(fmt.Sprintf("restore group %s", snapshot.name), err)
+			}
+			attachments = append(attachments, a)
+			if a.itemErrs != nil {
+				errs = append(errs, a.itemErrs)
+			}
+		}
+		s.lock.Lock()
+		if s.version == version {
+			for _, a := range attachments {
+				a.publish()
+			}
+			s.server = server
+			s.shutdown = shutdown
+			s.lock.Unlock()
+			return errors.Join(errs...), nil
+		}
+		s.lock.Unlock()
+		removeAttachments(server, attachments)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var errs []error
+	for _, g := range s.groups {
+		itemErr, err := g.attach(server)
+		if err != nil {
+			for _, g := range s.groups {
+				g.detach()
+			}
+			return nil, NewOPCWrapperError(fmt.Sprintf("restore group %s", g.name), err)
+		}
+		if itemErr != nil {
+			errs = append(errs, itemErr)
+		}
+	}
+	s.server = server
+	s.shutdown = shutdown
+	return errors.Join(errs...), nil
+}
+
+// removeAttachments removes the groups of a discarded restore from server
+func removeAttachments(server *OPCServer, attachments []*attachment) {
+	for _, a := range attachments {
+		server.GetOPCGroups().Remove(a.group.GetServerHandle())
+	}
+}
+
+// RegisterConnectionEvent registers ch to receive the connection changes
+func (s *Supervisor) RegisterConnectionEvent(ch chan *ConnectionEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.eventReceivers = append(s.eventReceivers, ch)
+}
+
+func (s *Supervisor) fireEvent(event *ConnectionEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, ch := range s.eventReceivers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// GetServer returns the current connection, nil while reconnecting
+func (s *Supervisor) GetServer() *OPCServer {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.server
+}
+
+// IsConnected reports whether the supervisor is connected
+func (s *Supervisor) IsConnected() bool {
+	return s.GetServer() != nil
+}
+
+// AddGroup adds a group with the defaults of OPCGroups.
+// The group is created on the server now when connected, and on the next connection otherwise.
+func (s *Supervisor) AddGroup(name string) (*SupervisedGroup, error) {
+	s.lock.Lock()
+	s.nextGroup++
+	g := &SupervisedGroup{
+		supervisor:    s,
+		name:          name,
+		clientHandle:  s.nextGroup,
+		active:        true,
+		updateRate:    1000,
+		localeID:      0x0400,
+		defaultActive: true,
+	}
+	server := s.server
+	s.lock.Unlock()
+	for {
+		// the group is not shared yet, it is built without the lock and kept when the connection did not change
+		var a *attachment
+		if server != nil {
+			var err error
+			if a, err = g.snapshot().build(server); err != nil {
+				return nil, err
+			}
+		}
+		s.lock.Lock()
+		if s.server == server {
+			if a != nil {
+				a.publish()
+			}
+			s.groups = append(s.groups, g)
+			s.version++
+			s.lock.Unlock()
+			return g, nil
+		}
+		server = s.server
+		s.lock.Unlock()
+	}
+}
+
+// RemoveGroup removes a group and forgets it, it returns ErrGroupNotFound when the group is not supervised
+func (s *Supervisor) RemoveGroup(group *SupervisedGroup) error {
+	for {
+		s.lock.Lock()
+		i := s.indexOf(group)
+		if i < 0 {
+			s.lock.Unlock()
+			return ErrGroupNotFound
+		}
+		opcGroup, server := group.group, s.server
+		if opcGroup == nil {
+			s.groups = append(s.groups[:i], s.groups[i+1:]...)
+			s.version++
+			s.lock.Unlock()
+			return nil
+		}
+		s.lock.Unlock()
+		if err := server.GetOPCGroups().Remove(opcGroup.GetServerHandle()); err != nil {
+			return err
+		}
+		// the group is forgotten when it was not re-created on a new connection meanwhile
+		s.lock.Lock()
+		if group.group == opcGroup {
+			group.detach()
+		}
+		s.lock.Unlock()
+	}
+}
+
+// indexOf returns the index of group in the supervised groups or -1, the caller holds the lock
+func (s *Supervisor) indexOf(group *SupervisedGroup) int {
+	for i, g := range s.groups {
+		if g == group {
+			return i
+		}
+	}
+	return -1
+}
+
+// Close stops supervising and disconnects from the server
+func (s *Supervisor) Close() error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+		close(s.done)
+	}
+	<-s.stopped
+	s.lock.Lock()
+	server := s.server
+	s.server = nil
+	for _, g := range s.groups {
+		g.detach()
+	}
+	s.lock.Unlock()
+	if server != nil {
+		return server.Disconnect()
+	}
+	return nil
+}
+
+// SupervisedGroup is a group re-created by its Supervisor after a reconnect.
+// Its settings, items and registered channels are kept across connections.
+type SupervisedGroup struct {
+	supervisor               *Supervisor
+	name                     string
+	clientHandle             uint32
+	active                   bool
+	updateRate               uint32
+	deadband                 float32
+	timeBias                 int32
+	localeID                 uint32
+	defaultActive            bool
+	defaultAccessPath        string
+	defaultRequestedDataType com.VT
+	clientConversion         bool
+	items                    []*SupervisedItem
+	nextItem                 uint32
+	dataChangeList           []registration[*DataChangeCallBackData]
+	readCompleteList         []registration[*ReadCompleteCallBackData]
+	writeCompleteList        []registration[*WriteCompleteCallBackData]
+	cancelCompleteList       []registration[*CancelCompleteCallBackData]
+	group                    *OPCGroup
+}
+
+// groupSnapshot is the state of a supervised group to re-create on a connection, taken under the supervisor lock
+type groupSnapshot struct {
+	group              *SupervisedGroup
+	name               string
+	clientHandle       uint32
+	active             bool
+	updateRate         uint32
+	deadband           float32
+	timeBias           int32
+	localeID           uint32
+	clientConversion   bool
+	items              []*SupervisedItem
+	definitions        []com.ItemDefinition
+	dataChangeList     []registration[*DataChangeCallBackData]
+	readCompleteList   []registration[*ReadCompleteCallBackData]
+	writeCompleteList  []registration[*WriteCompleteCallBackData]
+	cancelCompleteList []registration[*CancelCompleteCallBackData]
+}
+
+// attachment is a group built on a connection from a snapshot, and its items
+type attachment struct {
+	snapshot *groupSnapshot
+	group    *OPCGroup
+	items    []*OPCItem
+	itemErrs error
+}
+
+// snapshot copies the state of the group, the caller holds the supervisor lock
+func (g *SupervisedGroup) snapshot() *groupSnapshot {
+	return &groupSnapshot{
+		group:              g,
+		name:               g.name,
+		clientHandle:       g.clientHandle,
+		active:             g.active,
+		updateRate:         g.updateRate,
+		deadband:           g.deadband,
+		timeBias:           g.timeBias,
+		localeID:           g.localeID,
+		clientConversion:   g.clientConversion,
+		items:              append([]*SupervisedItem(nil), g.items...),
+		definitions:        itemDefinitions(g.items),
+		dataChangeList:     append([]registration[*DataChangeCallBackData](nil), g.dataChangeList...),
+		readCompleteList:   append([]registration[*ReadCompleteCallBackData](nil), g.readCompleteList...),
+		writeCompleteList:  append([]registration[*WriteCompleteCallBackData](nil), g.writeCompleteList...),
+		cancelCompleteList: append([]registration[*CancelCompleteCallBackData](nil), g.cancelCompleteList...),
+	}
+}
+
+// build creates the group of the snapshot and its items on server, it does not touch the supervised group
+func (sn *groupSnapshot) build(server *OPCServer) (*attachment, error) {
+	groups := server.GetOPCGroups()
+	groups.Lock()
+	group, err := groups.addGroup(sn.name, sn.clientHandle, sn.active, sn.updateRate, sn.timeBias, sn.deadband, sn.localeID)
+	groups.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range sn.dataChangeList {
+		err = errors.Join(err, group.RegisterDataChange(r.ch, r.opts...))
+	}
+	for _, r := range sn.readCompleteList {
+		err = errors.Join(err, group.RegisterReadComplete(r.ch, r.opts...))
+	}
+	for _, r := range sn.writeCompleteList {
+		err = errors.Join(err, group.RegisterWriteComplete(r.ch, r.opts...))
+	}
+	for _, r := range sn.cancelCompleteList {
+		err = errors.Join(err, group.RegisterCancelComplete(r.ch, r.opts...))
+	}
+	if err != nil {
+		groups.Remove(group.GetServerHandle())
+		return nil, err
+	}
+	group.OPCItems().SetClientConversion(sn.clientConversion)
+	a := &attachment{snapshot: sn, group: group}
+	if len(sn.definitions) == 0 {
+		return a, nil
+	}
+	opcItems := group.OPCItems()
+	opcItems.Lock()
+	items, errs, err := opcItems.addItems(sn.definitions)
+	opcItems.Unlock()
+	if err != nil {
+		groups.Remove(group.GetServerHandle())
+		return nil, err
+	}
+	a.items = items
+	for i, it := range sn.items {
+		if errs[i] != nil {
+			a.itemErrs = errors.Join(a.itemErrs, NewOPCWrapperError(fmt.Sprintf("restore item %s", it.itemID), errs[i]))
+		}
+	}
+	return a, nil
+}
+
+// publish makes the group and items of the attachment those of the supervised group, the caller holds the supervisor
+// lock
+func (a *attachment) publish() {
+	a.snapshot.group.group = a.group
+	if a.items == nil {
+		return
+	}
+	for i, it := range a.snapshot.items {
+		it.item = a.items[i]
+	}
+}
+
+// attach creates the group and its items on server, the caller holds the supervisor lock
+func (g *SupervisedGroup) attach(server *OPCServer) (itemErrs error, err error) {
+	a, err := g.snapshot().build(server)
+	if err != nil {
+		return nil, err
+	}
+	a.publish()
+	return a.itemErrs, nil
+}
+
+// detach forgets the objects of a dead connection, the caller holds the supervisor lock
+func (g *SupervisedGroup) detach() {
+	g.group = nil
+	for _, it := range g.items {
+		it.item = nil
+	}
+}
+
+// itemDefinitions returns the definitions of items with their client handles, the caller holds the supervisor lock
+func itemDefinitions(items []*SupervisedItem) []com.ItemDefinition {
+	definitions := make([]com.ItemDefinition, len(items))
+	for i, it := range items {
+		definitions[i] = com.ItemDefinition{
+			AccessPath:        it.accessPath,
+			ItemID:            it.itemID,
+			Active:            it.active,
+			ClientHandle:      it.clientHandle,
+			RequestedDataType: it.requestedDataType,
+		}
+	}
+	return definitions
+}
+
+// apply runs f on the attached group and records the setting with set when it succeeds or the group is detached.
+// f is called without the supervisor lock, so that a hung server does not block the supervisor. The setting is
+// recorded when the group was not re-created on a new connection meanwhile, otherwise f is run again on the new group.
+func (g *SupervisedGroup) apply(f func(group *OPCGroup) error, set func()) error {
+	s := g.supervisor
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for {
+		group := g.group
+		if group != nil {
+			s.lock.Unlock()
+			err := f(group)
+			s.lock.Lock()
+			if err != nil {
+				return err
+			}
+			if g.group != nil && g.group != group {
+				continue
+			}
+		}
+		set()
+		s.version++
+		return nil
+	}
+}
+
+// GetName returns the name of the group
+func (g *SupervisedGroup) GetName() string {
+	return g.name
+}
+
+// GetClientHandle returns the client handle of the group, it does not change across reconnects
+func (g *SupervisedGroup) GetClientHandle() uint32 {
+	return g.clientHandle
+}
+
+// GetGroup returns the group of the current connection, nil while reconnecting
+func (g *SupervisedGroup) GetGroup() *OPCGroup {
+	g.supervisor.lock.Lock()
+	defer g.supervisor.lock.Unlock()
+	return g.group
+}
+
+// SetIsActive set whether the group is active
+func (g *SupervisedGroup) SetIsActive(isActive bool) error {
+	return g.apply(func(group *OPCGroup) error {
+		return group.SetIsActive(isActive)
+	}, func() {
+		g.active = isActive
+	})
+}
+
+// SetUpdateRate set the update rate
+func (g *SupervisedGroup) SetUpdateRate(updateRate uint32) error {
+	return g.apply(func(group *OPCGroup) error {
+		return group.SetUpdateRate(updateRate)
+	}, func() {
+		g.updateRate = updateRate
+	})
+}
+
+// SetDeadband set the percent deadband
+func (g *SupervisedGroup) SetDeadband(deadband float32) error {
+	return g.apply(func(group *OPCGroup) error {
+		return group.SetDeadband(deadband)
+	}, func() {
+		g.deadband = deadband
+	})
+}
+
+// SetTimeBias set the time bias
+func (g *SupervisedGroup) SetTimeBias(timeBias int32) error {
+	return g.apply(func(group *OPCGroup) error {
+		return group.SetTimeBias(timeBias)
+	}, func() {
+		g.timeBias = timeBias
+	})
+}
+
+// SetLocaleID set the locale identifier
+func (g *SupervisedGroup) SetLocaleID(localeID uint32) error {
+	return g.apply(func(group *OPCGroup) error {
+		return group.SetLocaleID(localeID)
+	}, func() {
+		g.localeID = localeID
+	})
+}
+
+// SetDefaultActive set the active state of the items added afterwards
+func (g *SupervisedGroup) SetDefaultActive(defaultActive bool) {
+	g.supervisor.lock.Lock()
+	defer g.supervisor.lock.Unlock()
+	g.defaultActive = defaultActive
+}
+
+// SetDefaultAccessPath set the access path of the items added afterwards
+func (g *SupervisedGroup) SetDefaultAccessPath(defaultAccessPath string) {
+	g.supervisor.lock.Lock()
+	defer g.supervisor.lock.Unlock()
+	g.defaultAccessPath = defaultAccessPath
+}
+
+// SetDefaultRequestedDataType set the requested data type of the items added afterwards
+func (g *SupervisedGroup) SetDefaultRequestedDataType(defaultRequestedDataType com.VT) {
+	g.supervisor.lock.Lock()
+	defer g.supervisor.lock.Unlock()
+	g.defaultRequestedDataType = defaultRequestedDataType
+}
+
+// SetClientConversion set whether the client converts the values of the items whose requested data type the server
+// rejects, see OPCItems.SetClientConversion
+func (g *SupervisedGroup) SetClientConversion(clientConversion bool) {
+	g.supervisor.lock.Lock()
+	defer g.supervisor.lock.Unlock()
+	g.clientConversion = clientConversion
+	g.supervisor.version++
+	if g.group != nil {
+		g.group.OPCItems().SetClientConversion(clientConversion)
+	}
+}
+
+// AddItem adds an item to the group
+func (g *SupervisedGroup) AddItem(itemID string) (*SupervisedItem, error) {
+	items, errs, err := g.AddItems([]string{itemID})
+	if err != nil {
+		return nil, err
+	}
+	if errs[0] != nil {
+		return nil, errs[0]
+	}
+	return items[0], nil
+}
+
+// AddItems adds items to the group.
+// While connected the items the server rejects are not added, while reconnecting every item is added and checked on the next connection.
+func (g *SupervisedGroup) AddItems(itemIDs []string) ([]*SupervisedItem, []error, error) {
+	s := g.supervisor
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	items := make([]*SupervisedItem, len(itemIDs))
+	for i, itemID := range itemIDs {
+		g.nextItem++
+		items[i] = &SupervisedItem{
+			group:             g,
+			itemID:            itemID,
+			accessPath:        g.defaultAccessPath,
+			requestedDataType: g.defaultRequestedDataType,
+			active:            g.defaultActive,
+			clientHandle:      g.nextItem,
+		}
+	}
+	definitions := itemDefinitions(items)
+	errs := make([]error, len(itemIDs))
+	for group := g.group; group != nil; group = g.group {
+		// the items are added without the lock and kept when the group was not re-created on a new connection
+		// meanwhile, the server calls of a re-created group are dropped with its connection
+		s.lock.Unlock()
+		opcItems := group.OPCItems()
+		opcItems.Lock()
+		added, addErrs, err := opcItems.addItems(definitions)
+		opcItems.Unlock()
+		s.lock.Lock()
+		if err != nil {
+			return nil, nil, err
+		}
+		if g.group != group {
+			continue
+		}
+		for i := range items {
+			if addErrs[i] != nil {
+				errs[i] = addErrs[i]
+				items[i] = nil
+				continue
+			}
+			items[i].item = added[i]
+		}
+		break
+	}
+	for _, it := range items {
+		if it != nil {
+			g.items = append(g.items, it)
+		}
+	}
+	g.supervisor.version++
+	return items, errs, nil
+}
+
+// RemoveItems removes items from the group
+func (g *SupervisedGroup) RemoveItems(items []*SupervisedItem) {
+	g.supervisor.lock.Lock()
+	remove := make(map[*SupervisedItem]bool, len(items))
+	var serverHandles []uint32
+	for _, it := range items {
+		remove[it] = true
+		if it.item != nil {
+			serverHandles = append(serverHandles, it.item.GetServerHandle())
+		}
+		it.item = nil
+	}
+	kept := g.items[:0]
+	for _, it := range g.items {
+		if !remove[it] {
+			kept = append(kept, it)
+		}
+	}
+	g.items = kept
+	g.supervisor.version++
+	group := g.group
+	g.supervisor.lock.Unlock()
+	// the items are forgotten first, so that they are not restored, and removed from the server without the lock
+	if group != nil && len(serverHandles) > 0 {
+		group.OPCItems().Remove(serverHandles)
+	}
+}
+
+// GetItems returns the items of the group
+func (g *SupervisedGroup) GetItems() []*SupervisedItem {
+	g.supervisor.lock.Lock()
+	defer g.supervisor.lock.Unlock()
+	return append([]*SupervisedItem(nil), g.items...)
+}
+
+// RegisterDataChange Register to receive data change events, the registration is kept across reconnects
+func (g *SupervisedGroup) RegisterDataChange(ch chan *DataChangeCallBackData, opts ...DeliveryOption) error {
+	return g.apply(func(group *OPCGroup) error {
+		return group.RegisterDataChange(ch, opts...)
+	}, func() {
+		g.dataChangeList = append(g.dataChangeList, registration[*DataChangeCallBackData]{ch: ch, opts: opts})
+	})
+}
+
+// RegisterReadComplete Register to receive read complete events, the registration is kept across reconnects
+func (g *SupervisedGroup) RegisterReadComplete(ch chan *ReadCompleteCallBackData, opts ...DeliveryOption) error {
+	return g.apply(func(group *OPCGroup) error {
+		return group.RegisterReadComplete(ch, opts...)
+	}, func() {
+		g.readCompleteList = append(g.readCompleteList, registration[*ReadCompleteCallBackData]{ch: ch, opts: opts})
+	})
+}
+
+// RegisterWriteComplete Register to receive write complete events, the registration is kept across reconnects
+func (g *SupervisedGroup) RegisterWriteComplete(ch chan *WriteCompleteCallBackData, opts ...DeliveryOption) error {
+	return g.apply(func(group *OPCGroup) error {
+		return group.RegisterWriteComplete(ch, opts...)
+	}, func() {
+		g.writeCompleteList = append(g.writeCompleteList, registration[*WriteCompleteCallBackData]{ch: ch, opts: opts})
+	})
+}
+
+// RegisterCancelComplete Register to receive cancel complete events, the registration is kept across reconnects
+func (g *SupervisedGroup) RegisterCancelComplete(ch chan *CancelCompleteCallBackData, opts ...DeliveryOption) error {
+	return g.apply(func(group *OPCGroup) error {
+		return group.RegisterCancelComplete(ch, opts...)
+	}, func() {
+		g.cancelCompleteList = append(g.cancelCompleteList, registration[*CancelCompleteCallBackData]{ch: ch, opts: opts})
+	})
+}
+
+// registration is a channel registered on a supervised group with its delivery options
+type registration[T any] struct {
+	ch   chan T
+	opts []DeliveryOption
+}
+
+// SupervisedItem is an item re-created by its Supervisor after a reconnect
+type SupervisedItem struct {
+	group             *SupervisedGroup
+	itemID            string
+	accessPath        string
+	requestedDataType com.VT
+	active            bool
+	clientHandle      uint32
+	item              *OPCItem
+}
+
+// GetItemID returns the item ID
+func (i *SupervisedItem) GetItemID() string {
+	return i.itemID
+}
+
+// GetClientHandle returns the client handle of the item, it does not change across reconnects
+func (i *SupervisedItem) GetClientHandle() uint32 {
+	return i.clientHandle
+}
+
+// GetItem returns the item of the current connection, nil while reconnecting or when the server rejected the item
+func (i *SupervisedItem) GetItem() *OPCItem {
+	i.group.supervisor.lock.Lock()
+	defer i.group.supervisor.lock.Unlock()
+	return i.item
+}
+
+// apply runs f on the attached item and records the setting with set when it succeeds or the item is detached, like
+// SupervisedGroup.apply
+func (i *SupervisedItem) apply(f func(item *OPCItem) error, set func()) error {
+	s := i.group.supervisor
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for {
+		item := i.item
+		if item != nil {
+			s.lock.Unlock()
+			err := f(item)
+			s.lock.Lock()
+			if err != nil {
+				return err
+			}
+			if i.item != nil && i.item != item {
+				continue
+			}
+		}
+		set()
+		s.version++
+		return nil
+	}
+}
+
+// SetIsActive set the active state of the item
+func (i *SupervisedItem) SetIsActive(isActive bool) error {
+	return i.apply(func(item *OPCItem) error {
+		return item.SetIsActive(isActive)
+	}, func() {
+		i.active = isActive
+	})
+}
+
+// SetRequestedDataType set the requested data type of the item
+func (i *SupervisedItem) SetRequestedDataType(requestedDataType com.VT) error {
+	return i.apply(func(item *OPCItem) error {
+		return item.SetRequestedDataType(requestedDataType)
+	}, func() {
+		i.requestedDataType = requestedDataType
+	})
+}
+
+// Read reads the item from the server, it returns ErrNotConnected while reconnecting
+func (i *SupervisedItem) Read(source com.OPCDATASOURCE) (value interface{}, quality com.Quality, timestamp time.Time, err error) {
+	item := i.GetItem()
+	if item == nil {
+		return nil, 0, time.Time{}, ErrNotConnected
+	}
+	return item.Read(source)
+}
+
+// Write writes a value to the item, it returns ErrNotConnected while reconnecting
+func (i *SupervisedItem) Write(value interface{}) error {
+	item := i.GetItem()
+	if item == nil {
+		return ErrNotConnected
+	}
+	return item.Write(value)
+}
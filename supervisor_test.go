@@ -0,0 +1,317 @@
+package opcda_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/huskar-t/opcda/opcsim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restartable is a transport to a simulation server that can be restarted
+type restartable struct {
+	t    testing.TB
+	lock sync.Mutex
+	sim  *opcsim.Server
+	down bool
+}
+
+func newRestartable(t testing.TB) *restartable {
+	return &restartable{t: t, sim: newSim(t)}
+}
+
+func (r *restartable) Connect(progID, node string, credentials *opcda.Credentials) (opcda.ServerBackend, error) {
+	r.lock.Lock()
+	sim := r.sim
+	r.lock.Unlock()
+	return sim.Connect(progID, node, credentials)
+}
+
+// restart closes the running server and starts a new one, the previous values are lost
+func (r *restartable) restart(shutdown bool) *opcsim.Server {
+	r.lock.Lock()
+	old := r.sim
+	r.sim = newSim(r.t)
+	sim := r.sim
+	r.lock.Unlock()
+	if shutdown {
+		old.Shutdown("restart")
+	}
+	old.Close()
+	return sim
+}
+
+func (r *restartable) current() *opcsim.Server {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.sim
+}
+
+func receiveEvent(t *testing.T, ch chan *opcda.ConnectionEvent) *opcda.ConnectionEvent {
+	t.Helper()
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+		return nil
+	}
+}
+
+func TestSupervisorReconnect(t *testing.T) {
+	transport := newRestartable(t)
+	supervisor, err := opcda.NewSupervisor(opcsim.DefaultProgID, "",
+		opcda.WithConnectOptions(opcda.WithTransport(transport)),
+		opcda.WithBackoff(10*time.Millisecond, 50*time.Millisecond),
+		opcda.WithStatusInterval(0),
+	)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, supervisor.Close())
+	}()
+	events := make(chan *opcda.ConnectionEvent, 10)
+	supervisor.RegisterConnectionEvent(events)
+
+	group, err := supervisor.AddGroup("supervised")
+	require.NoError(t, err)
+	require.NoError(t, group.SetUpdateRate(20))
+	require.NoError(t, group.SetDeadband(5))
+	group.SetDefaultRequestedDataType(com.VT_R8)
+	dataChange := make(chan *opcda.DataChangeCallBackData, 100)
+	require.NoError(t, group.RegisterDataChange(dataChange))
+	item, err := group.AddItem("Bucket Brigade.Int4")
+	require.NoError(t, err)
+	_, err = group.AddItem("Unknown")
+	assert.Error(t, err)
+	require.NoError(t, item.Write(int32(5)))
+	value, _, _, err := item.Read(opcda.OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, float64(5), value)
+
+	sim := transport.restart(true)
+	event := receiveEvent(t, events)
+	assert.False(t, event.Connected)
+	assert.ErrorContains(t, event.Err, "restart")
+	event = receiveEvent(t, events)
+	assert.True(t, event.Connected)
+	assert.NoError(t, event.Err)
+	assert.True(t, supervisor.IsConnected())
+	assert.Equal(t, 1, sim.Connections())
+
+	restored := group.GetGroup()
+	require.NotNil(t, restored)
+	assert.Equal(t, group.GetClientHandle(), restored.GetClientHandle())
+	rate, err := restored.GetUpdateRate()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(20), rate)
+	deadband, err := restored.GetDeadband()
+	assert.NoError(t, err)
+	assert.Equal(t, float32(5), deadband)
+	assert.Equal(t, com.VT_R8, item.GetItem().GetRequestedDataType())
+	assert.Equal(t, item.GetClientHandle(), item.GetItem().GetClientHandle())
+
+	// the registered channel keeps receiving with the same handles
+	require.NoError(t, sim.SetValue("Bucket Brigade.Int4", int32(9)))
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case data := <-dataChange:
+			if len(data.Values) == 1 && data.Values[0] == float64(9) {
+				assert.Equal(t, group.GetClientHandle(), data.GroupHandle)
+				assert.Equal(t, []uint32{item.GetClientHandle()}, data.ItemClientHandles)
+				return
+			}
+		case <-deadline:
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestSupervisorStatusPolling(t *testing.T) {
+	transport := newRestartable(t)
+	supervisor, err := opcda.NewSupervisor(opcsim.DefaultProgID, "",
+		opcda.WithConnectOptions(opcda.WithTransport(transport)),
+		opcda.WithBackoff(10*time.Millisecond, 50*time.Millisecond),
+		opcda.WithStatusInterval(10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	defer supervisor.Close()
+	events := make(chan *opcda.ConnectionEvent, 10)
+	supervisor.RegisterConnectionEvent(events)
+	group, err := supervisor.AddGroup("polled")
+	require.NoError(t, err)
+	item, err := group.AddItem("Bucket Brigade.String")
+	require.NoError(t, err)
+
+	// the server dies without a shutdown notification
+	transport.restart(false)
+	event := receiveEvent(t, events)
+	assert.False(t, event.Connected)
+	assert.Error(t, event.Err)
+	event = receiveEvent(t, events)
+	assert.True(t, event.Connected)
+	require.NoError(t, item.Write("after restart"))
+	value, _, _, err := transport.current().Value("Bucket Brigade.String")
+	assert.NoError(t, err)
+	assert.Equal(t, "after restart", value)
+}
+
+func TestSupervisorOffline(t *testing.T) {
+	transport := newRestartable(t)
+	supervisor, err := opcda.NewSupervisor(opcsim.DefaultProgID, "",
+		opcda.WithConnectOptions(opcda.WithTransport(transport)),
+		opcda.WithBackoff(200*time.Millisecond, time.Second),
+		opcda.WithStatusInterval(0),
+	)
+	require.NoError(t, err)
+	defer supervisor.Close()
+	events := make(chan *opcda.ConnectionEvent, 10)
+	supervisor.RegisterConnectionEvent(events)
+	transport.restart(true)
+	assert.False(t, receiveEvent(t, events).Connected)
+
+	// groups and items added while reconnecting are created on the next connection
+	group, err := supervisor.AddGroup("offline")
+	require.NoError(t, err)
+	require.NoError(t, group.SetIsActive(false))
+	items, errs, err := group.AddItems([]string{"Random.Int4", "Unknown"})
+	require.NoError(t, err)
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	_, _, _, err = items[0].Read(opcda.OPC_DS_DEVICE)
+	assert.ErrorIs(t, err, opcda.ErrNotConnected)
+
+	event := receiveEvent(t, events)
+	assert.True(t, event.Connected)
+	assert.ErrorContains(t, event.Err, "restore item Unknown")
+	assert.False(t, group.GetGroup().GetIsActive())
+	assert.NotNil(t, items[0].GetItem())
+	assert.Nil(t, items[1].GetItem())
+	_, _, _, err = items[0].Read(opcda.OPC_DS_DEVICE)
+	assert.NoError(t, err)
+
+	require.NoError(t, supervisor.RemoveGroup(group))
+	assert.Equal(t, 0, supervisor.GetServer().GetOPCGroups().GetCount())
+}
+
+func TestSupervisorConnectError(t *testing.T) {
+	_, err := opcda.NewSupervisor("Unknown", "", opcda.WithConnectOptions(opcda.WithTransport(newSim(t))))
+	assert.Error(t, err)
+}
+
+func TestSupervisorHungServer(t *testing.T) {
+	sim := newSim(t)
+	blocking := &blocker{}
+	supervisor, err := opcda.NewSupervisor(opcsim.DefaultProgID, "",
+		opcda.WithConnectOptions(opcda.WithTransport(wrapTransport(blocking.intercept, nil)(sim))),
+		opcda.WithBackoff(10*time.Millisecond, 50*time.Millisecond),
+		opcda.WithStatusInterval(10*time.Millisecond),
+		opcda.WithStatusTimeout(50*time.Millisecond),
+	)
+	require.NoError(t, err)
+	defer supervisor.Close()
+	events := make(chan *opcda.ConnectionEvent, 10)
+	supervisor.RegisterConnectionEvent(events)
+
+	// the server stops answering GetStatus without failing it
+	blocking.block("IOPCServer.GetStatus")
+	defer blocking.unblock()
+	event := receiveEvent(t, events)
+	assert.False(t, event.Connected)
+	assert.ErrorIs(t, event.Err, context.DeadlineExceeded)
+	assert.True(t, receiveEvent(t, events).Connected)
+}
+
+func TestSupervisorRestoreUnlocked(t *testing.T) {
+	sim := newSim(t)
+	blocking := &blocker{}
+	supervisor, err := opcda.NewSupervisor(opcsim.DefaultProgID, "",
+		opcda.WithConnectOptions(opcda.WithTransport(wrapTransport(blocking.intercept, nil)(sim))),
+		opcda.WithBackoff(10*time.Millisecond, 50*time.Millisecond),
+		opcda.WithStatusInterval(0),
+	)
+	require.NoError(t, err)
+	defer supervisor.Close()
+	events := make(chan *opcda.ConnectionEvent, 10)
+	supervisor.RegisterConnectionEvent(events)
+	first, err := supervisor.AddGroup("first")
+	require.NoError(t, err)
+	item, err := first.AddItem("Random.Int4")
+	require.NoError(t, err)
+
+	// the restore waits for the server to add the items
+	blocking.block("IOPCItemMgt.AddItems")
+	sim.Shutdown("restart")
+	assert.False(t, receiveEvent(t, events).Connected)
+	time.Sleep(50 * time.Millisecond)
+
+	// the supervisor stays usable during the restore, the changes made meanwhile are restored too
+	done := make(chan struct{})
+	var second *opcda.SupervisedGroup
+	go func() {
+		defer close(done)
+		assert.Nil(t, supervisor.GetServer())
+		second, err = supervisor.AddGroup("second")
+		assert.NoError(t, err)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the supervisor is locked during the restore")
+	}
+	blocking.unblock()
+	event := receiveEvent(t, events)
+	assert.True(t, event.Connected)
+	assert.NoError(t, event.Err)
+	assert.NotNil(t, first.GetGroup())
+	assert.NotNil(t, second.GetGroup())
+	assert.NotNil(t, item.GetItem())
+	assert.Equal(t, 2, supervisor.GetServer().GetOPCGroups().GetCount())
+}
+
+func TestSupervisorHungCall(t *testing.T) {
+	sim := newSim(t)
+	blocking := &blocker{}
+	supervisor, err := opcda.NewSupervisor(opcsim.DefaultProgID, "",
+		opcda.WithConnectOptions(opcda.WithTransport(wrapTransport(blocking.intercept, nil)(sim))),
+		opcda.WithStatusInterval(0),
+	)
+	require.NoError(t, err)
+	defer supervisor.Close()
+	group, err := supervisor.AddGroup("hung")
+	require.NoError(t, err)
+
+	// the server does not answer a group call, the supervisor stays usable meanwhile
+	blocking.block("IOPCGroupStateMgt.SetState")
+	done := make(chan error, 1)
+	go func() {
+		done <- group.SetUpdateRate(20)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	unlocked := make(chan struct{})
+	go func() {
+		defer close(unlocked)
+		assert.True(t, supervisor.IsConnected())
+		other, err := supervisor.AddGroup("other")
+		assert.NoError(t, err)
+		_, err = other.AddItem("Random.Int4")
+		assert.NoError(t, err)
+		assert.NoError(t, supervisor.RemoveGroup(other))
+		assert.ErrorIs(t, supervisor.RemoveGroup(other), opcda.ErrGroupNotFound)
+	}()
+	select {
+	case <-unlocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the supervisor is locked during a server call")
+	}
+	blocking.unblock()
+	require.NoError(t, <-done)
+	rate, err := group.GetGroup().GetUpdateRate()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(20), rate)
+}
@@ -0,0 +1,140 @@
+package opcda
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/huskar-t/opcda/com"
+)
+
+// TagValue is the Go type of a Tag, one of the types a VARIANT decodes to and their one-dimensional arrays
+type TagValue interface {
+	bool | int8 | int16 | int32 | int64 | uint8 | uint16 | uint32 | uint64 | float32 | float64 | string | time.Time |
+		com.Currency | com.Decimal | com.SCode |
+		[]bool | []int8 | []int16 | []int32 | []int64 | []uint8 | []uint16 | []uint32 | []uint64 | []float32 |
+		[]float64 | []string | []time.Time | []com.Currency | []com.Decimal | []com.SCode | []interface{}
+}
+
+// TagTypeError is returned by NewTag when the canonical data type of the item is not the type of the tag
+type TagTypeError struct {
+	ItemID string
+	// Type is the VARIANT type of the tag
+	Type com.VT
+	// CanonicalDataType is the canonical data type of the item
+	CanonicalDataType com.VT
+}
+
+func (e *TagTypeError) Error() string {
+	return fmt.Sprintf("item %s has the canonical data type 0x%x, not 0x%x", e.ItemID, uint16(e.CanonicalDataType), uint16(e.Type))
+}
+
+// Tag is an item of a group whose values are of type T
+type Tag[T TagValue] struct {
+	group *OPCGroup
+	item  *OPCItem
+}
+
+// TagSample is a data change of a Tag. Value is the zero value when the item has no value, Err is the item error.
+type TagSample[T TagValue] struct {
+	Value     T
+	Quality   com.Quality
+	Timestamp time.Time
+	Err       error
+}
+
+// NewTag adds an item to the group with the default access path and active state of its items and checks that its
+// canonical data type is the type of T. When it is not, the item is removed again and a *TagTypeError is returned.
+func NewTag[T TagValue](group *OPCGroup, itemID string) (*Tag[T], error) {
+	var zero T
+	vt, err := com.VariantType(zero)
+	if err != nil {
+		return nil, err
+	}
+	is := group.OPCItems()
+	is.Lock()
+	items, errs, err := is.addItems(is.createDefinitions([]string{itemID}, is.defaultAccessPath, is.defaultActive, com.VT_EMPTY))
+	is.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if errs[0] != nil {
+		return nil, errs[0]
+	}
+	item := items[0]
+	if item.GetCanonicalDataType() != vt {
+		is.Remove([]uint32{item.GetServerHandle()})
+		return nil, &TagTypeError{ItemID: itemID, Type: vt, CanonicalDataType: item.GetCanonicalDataType()}
+	}
+	return &Tag[T]{group: group, item: item}, nil
+}
+
+// GetItem get the item of the tag
+func (t *Tag[T]) GetItem() *OPCItem {
+	return t.item
+}
+
+// Read makes a blocking call to read the tag from the server, the value is the zero value when the item has none
+func (t *Tag[T]) Read(source com.OPCDATASOURCE) (value T, quality com.Quality, timestamp time.Time, err error) {
+	v, quality, timestamp, err := t.item.Read(source)
+	if err != nil {
+		return value, quality, timestamp, err
+	}
+	value, err = t.value(v)
+	return value, quality, timestamp, err
+}
+
+// Write makes a blocking call to write the value of the tag to the server
+func (t *Tag[T]) Write(value T) error {
+	return t.item.Write(value)
+}
+
+// Subscribe sends the data changes of the tag, the group must be active to receive them. A sample is dropped when the
+// channel is full. The channel is closed when ctx is done.
+func (t *Tag[T]) Subscribe(ctx context.Context) (<-chan *TagSample[T], error) {
+	dataChange := make(chan *DataChangeCallBackData, 100)
+	subscription, err := t.group.SubscribeDataChange(dataChange)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *TagSample[T], 100)
+	go func() {
+		defer close(ch)
+		defer subscription.Unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case data := <-dataChange:
+				clientHandle := t.item.GetClientHandle()
+				for i, handle := range data.ItemClientHandles {
+					if handle != clientHandle {
+						continue
+					}
+					sample := &TagSample[T]{Quality: data.Qualities[i], Timestamp: data.TimeStamps[i], Err: data.Errors[i]}
+					if sample.Err == nil {
+						sample.Value, sample.Err = t.value(data.Values[i])
+					}
+					select {
+					case ch <- sample:
+					default:
+					}
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// value returns a value of the item as a T
+func (t *Tag[T]) value(v interface{}) (T, error) {
+	var value T
+	if v == nil {
+		return value, nil
+	}
+	value, ok := v.(T)
+	if !ok {
+		return value, fmt.Errorf("item %s returned a %T, not a %T", t.item.GetItemID(), v, value)
+	}
+	return value, nil
+}
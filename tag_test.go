@@ -0,0 +1,69 @@
+package opcda_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/com"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTag(t *testing.T) {
+	sim, server := connectSim(t)
+	group := addGroup(t, server, "tag")
+
+	_, err := opcda.NewTag[int32](group, "Bucket Brigade.Real8")
+	var typeErr *opcda.TagTypeError
+	require.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, com.VT_I4, typeErr.Type)
+	assert.Equal(t, com.VT_R8, typeErr.CanonicalDataType)
+	assert.Equal(t, 0, group.OPCItems().GetCount())
+	_, err = opcda.NewTag[float64](group, "Unknown.Item")
+	assertItemError(t, opcda.OPCUnknownItemID, err)
+
+	real8, err := opcda.NewTag[float64](group, "Bucket Brigade.Real8")
+	require.NoError(t, err)
+	assert.Equal(t, "Bucket Brigade.Real8", real8.GetItem().GetItemID())
+	require.NoError(t, real8.Write(1.25))
+	value, quality, _, err := real8.Read(opcda.OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, 1.25, value)
+	assert.Equal(t, com.QualityGood, quality)
+
+	array, err := opcda.NewTag[[]float64](group, "Bucket Brigade.ArrayOfReal8")
+	require.NoError(t, err)
+	require.NoError(t, array.Write([]float64{1, 2, 3}))
+	values, _, _, err := array.Read(opcda.OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 2, 3}, values)
+
+	date, err := opcda.NewTag[time.Time](group, "Bucket Brigade.Time")
+	require.NoError(t, err)
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, date.Write(now))
+	read, _, _, err := date.Read(opcda.OPC_DS_DEVICE)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(read))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	samples, err := real8.Subscribe(ctx)
+	require.NoError(t, err)
+	require.NoError(t, sim.SetValue("Bucket Brigade.ArrayOfReal8", []float64{4, 5, 6}))
+	require.NoError(t, sim.SetValue("Bucket Brigade.Real8", -3.5))
+	// the first sample may still hold the previous value
+	for changed := false; !changed; {
+		select {
+		case sample := <-samples:
+			require.NoError(t, sample.Err)
+			changed = sample.Value == -3.5
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	cancel()
+	for range samples {
+	}
+}
@@ -0,0 +1,116 @@
+package opcda_test
+
+import (
+	"testing"
+
+	"github.com/huskar-t/opcda"
+	"github.com/huskar-t/opcda/opcsim"
+	"github.com/stretchr/testify/require"
+)
+
+// simConfig is the setup of connectSim
+type simConfig struct {
+	simOptions     []opcsim.Option
+	decorate       func(opcda.Transport) opcda.Transport
+	connectOptions []opcda.ConnectOption
+}
+
+// simOption configures connectSim
+type simOption func(*simConfig)
+
+// withSimOptions starts the simulation server with opts
+func withSimOptions(opts ...opcsim.Option) simOption {
+	return func(c *simConfig) {
+		c.simOptions = opts
+	}
+}
+
+// withDecorator connects through the transport decorate returns for the simulation server, see wrapTransport
+func withDecorator(decorate func(opcda.Transport) opcda.Transport) simOption {
+	return func(c *simConfig) {
+		c.decorate = decorate
+	}
+}
+
+// withConnectOptions passes opts to Connect besides the transport
+func withConnectOptions(opts ...opcda.ConnectOption) simOption {
+	return func(c *simConfig) {
+		c.connectOptions = opts
+	}
+}
+
+// newSim starts a simulation server that is closed when the test ends
+func newSim(t testing.TB, opts ...opcsim.Option) *opcsim.Server {
+	t.Helper()
+	sim, err := opcsim.NewServer(opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sim.Close()
+	})
+	return sim
+}
+
+// connectSim starts a simulation server and connects to it, the client is disconnected and the server closed when
+// the test ends
+func connectSim(t testing.TB, opts ...simOption) (*opcsim.Server, *opcda.OPCServer) {
+	t.Helper()
+	config := &simConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	sim := newSim(t, config.simOptions...)
+	var transport opcda.Transport = sim
+	if config.decorate != nil {
+		transport = config.decorate(transport)
+	}
+	server, err := opcda.Connect(opcsim.DefaultProgID, "", append([]opcda.ConnectOption{opcda.WithTransport(transport)}, config.connectOptions...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		server.Disconnect()
+	})
+	return sim, server
+}
+
+// addGroup adds a group to server
+func addGroup(t testing.TB, server *opcda.OPCServer, name string) *opcda.OPCGroup {
+	t.Helper()
+	group, err := server.GetOPCGroups().Add(name)
+	require.NoError(t, err)
+	return group
+}
+
+// wrapTransport returns a decorator for the tests that need to observe or alter the backends of a server. interceptor
+// sees every call through InterceptTransport, wrap wraps the groups the servers add to change the arguments and
+// results of their methods. Either may be nil.
+func wrapTransport(interceptor opcda.Interceptor, wrap func(opcda.GroupBackend) opcda.GroupBackend) func(opcda.Transport) opcda.Transport {
+	return func(transport opcda.Transport) opcda.Transport {
+		if wrap != nil {
+			inner := transport
+			transport = opcda.TransportFunc(func(progID, node string, credentials *opcda.Credentials) (opcda.ServerBackend, error) {
+				backend, err := inner.Connect(progID, node, credentials)
+				if err != nil {
+					return nil, err
+				}
+				return &wrappedServer{ServerBackend: backend, wrap: wrap}, nil
+			})
+		}
+		if interceptor != nil {
+			transport = opcda.InterceptTransport(transport, interceptor)
+		}
+		return transport
+	}
+}
+
+// wrappedServer wraps the groups it adds, see wrapTransport
+type wrappedServer struct {
+	opcda.ServerBackend
+	wrap func(opcda.GroupBackend) opcda.GroupBackend
+}
+
+func (s *wrappedServer) AddGroup(name string, active bool, requestedUpdateRate uint32, clientGroup uint32, timeBias *int32, percentDeadband *float32, localeID uint32) (uint32, uint32, opcda.GroupBackend, error) {
+	serverGroup, revised, group, err := s.ServerBackend.AddGroup(name, active, requestedUpdateRate, clientGroup, timeBias, percentDeadband, localeID)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return serverGroup, revised, s.wrap(group), nil
+}